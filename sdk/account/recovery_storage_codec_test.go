@@ -98,3 +98,30 @@ func equalKnowledgeRecoveryBundle(left, right KnowledgeRecoveryBundle) bool {
 	rightJSON, _ := json.Marshal(right)
 	return bytes.Equal(leftJSON, rightJSON)
 }
+
+func TestNormalizeBackupChildDerivation(t *testing.T) {
+	backup := testBackup()
+	backup.Wallets = append(backup.Wallets, WalletBackup{
+		Name: "child", AccountCount: 1, SubAccounts: []SubAccount{{Index: 0}},
+		Derivation: &ChildDerivation{Parent: 0, Index: 3, Words: 12},
+	})
+	normalized, err := NormalizeBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derivation := normalized.Wallets[1].Derivation; derivation == nil || *derivation != *backup.Wallets[1].Derivation {
+		t.Fatalf("derivation=%+v", derivation)
+	}
+	backup.Wallets[1].Derivation = &ChildDerivation{Parent: 1, Index: 3, Words: 12}
+	if _, err := NormalizeBackup(backup); err == nil {
+		t.Fatal("child derived from itself was accepted")
+	}
+	backup.Wallets[1].Derivation = &ChildDerivation{Parent: 0, Index: 3, Words: 13}
+	if _, err := NormalizeBackup(backup); err == nil {
+		t.Fatal("invalid mnemonic length was accepted")
+	}
+	backup.Wallets[1].Derivation = nil
+	if _, err := NormalizeBackup(backup); err == nil {
+		t.Fatal("wallet without mnemonic was accepted")
+	}
+}
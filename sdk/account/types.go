@@ -42,11 +42,20 @@ type SubAccount struct {
 	DID   string `json:"did"`
 }
 
+// ChildDerivation marks a wallet derived from Wallets[Parent] with BIP85
+// (m/83696968'/39'/0'/Words'/Index'). Its mnemonic is not stored in the backup.
+type ChildDerivation struct {
+	Parent uint32 `json:"parent"`
+	Index  uint32 `json:"index"`
+	Words  uint32 `json:"words"`
+}
+
 type WalletBackup struct {
-	Name         string       `json:"name"`
-	Mnemonic     string       `json:"mnemonic"`
-	AccountCount uint32       `json:"account_count"`
-	SubAccounts  []SubAccount `json:"sub_accounts"`
+	Name         string           `json:"name"`
+	Mnemonic     string           `json:"mnemonic"`
+	AccountCount uint32           `json:"account_count"`
+	SubAccounts  []SubAccount     `json:"sub_accounts"`
+	Derivation   *ChildDerivation `json:"derivation,omitempty"`
 }
 
 type Backup struct {
@@ -44,7 +44,19 @@ func NormalizeBackup(value Backup) (Backup, error) {
 	for walletIndex, wallet := range value.Wallets {
 		name := normalizeSpace(wallet.Name)
 		mnemonic := normalizeSpace(wallet.Mnemonic)
-		if name == "" || !bip39.IsMnemonicValid(mnemonic) || wallet.AccountCount == 0 || int(wallet.AccountCount) != len(wallet.SubAccounts) {
+		if name == "" || wallet.AccountCount == 0 || int(wallet.AccountCount) != len(wallet.SubAccounts) {
+			return Backup{}, ErrInvalidBackup
+		}
+		var derivation *ChildDerivation
+		if wallet.Derivation != nil {
+			// parent must come first so that restore can derive in order
+			if int(wallet.Derivation.Parent) >= walletIndex || !validChildWords(wallet.Derivation.Words) {
+				return Backup{}, fmt.Errorf("%w: invalid child derivation", ErrInvalidBackup)
+			}
+			value := *wallet.Derivation
+			derivation = &value
+		}
+		if (derivation == nil || mnemonic != "") && !bip39.IsMnemonicValid(mnemonic) {
 			return Backup{}, ErrInvalidBackup
 		}
 		if _, ok := walletNames[name]; ok {
@@ -79,11 +91,15 @@ func NormalizeBackup(value Backup) (Backup, error) {
 				return Backup{}, ErrInvalidBackup
 			}
 		}
-		out.Wallets[walletIndex] = WalletBackup{Name: name, Mnemonic: mnemonic, AccountCount: wallet.AccountCount, SubAccounts: subAccounts}
+		out.Wallets[walletIndex] = WalletBackup{Name: name, Mnemonic: mnemonic, AccountCount: wallet.AccountCount, SubAccounts: subAccounts, Derivation: derivation}
 	}
 	return out, nil
 }
 
+func validChildWords(words uint32) bool {
+	return words == 12 || words == 18 || words == 24
+}
+
 // RootBootstrapBackup keeps only the first wallet material needed to decrypt
 // and locate account/state on a new device. The managed state is authoritative
 // for the complete wallet and subaccount inventory.
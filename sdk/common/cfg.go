@@ -39,9 +39,39 @@ type RPCService struct {
 }
 
 type WalletCfg struct {
-	Mode       string `yaml:"mode"`
-	StakeAsset bool   `yaml:"stake"`
-	Mnemonic   string `yaml:"mnemonic"` // 用于初始化时使用，然后删除
-	Password   string `yaml:"password"` // 用于初始化时使用，然后删除
-	PSFile     string `yaml:"psfile"`   // 参考lnd中对保存钱包密码的设置。
+	Mode          string `yaml:"mode"`
+	StakeAsset    bool   `yaml:"stake"`
+	Mnemonic      string `yaml:"mnemonic"`      // 用于初始化时使用，然后删除
+	Password      string `yaml:"password"`      // 用于初始化时使用，然后删除
+	PSFile        string `yaml:"psfile"`        // 参考lnd中对保存钱包密码的设置。
+	ChangeAddress bool   `yaml:"changeAddress"` // 白聪找零输出到找零链地址（change=1）
+	GapLimit      int    `yaml:"gapLimit"`      // 导入钱包时扫描子账户的gap limit，默认20
+	Signer        string `yaml:"signer"`        // 外部签名器，例如 unix:///run/sat20/signer.sock，设置后本地不保存私钥
+
+	// 钱包加密的密钥派生参数，修改后旧的记录在下次解锁时自动升级
+	Kdf           string `yaml:"kdf"`           // argon2id（默认）或者 scrypt
+	KdfMemory     uint32 `yaml:"kdfMemory"`     // argon2id 内存，单位KB，默认65536
+	KdfIterations uint32 `yaml:"kdfIterations"` // argon2id 迭代次数，默认3；scrypt 为log2(N)，默认17
+	KdfThreads    uint8  `yaml:"kdfThreads"`    // argon2id 并行度，默认1
+
+	MaxUnlockAttempts int  `yaml:"maxUnlockAttempts"` // 连续解锁失败多少次后开始锁定，默认5，-1 不锁定
+	UnlockBackoff     int  `yaml:"unlockBackoff"`     // 第一次锁定的秒数，之后每次失败翻倍，默认30
+	PasswordMinLength int  `yaml:"passwordMinLength"` // 默认8
+	PasswordClasses   int  `yaml:"passwordClasses"`   // 至少包含几类字符（小写，大写，数字，符号），默认2
+	PasswordPolicy    bool `yaml:"passwordPolicy"`    // 设置新密码时强制检查密码强度
+
+	// L1 费率
+	FeeEstimator string           `yaml:"feeEstimator"` // indexer（默认），static，local
+	StaticFees   map[string]int64 `yaml:"staticFees"`   // static 模式的费率表，key: fast/normal/economy
+	MinRelayFee  int64            `yaml:"minRelayFee"`  // 最低转发费率 sat/vB，默认1
+	MaxFeeRate   int64            `yaml:"maxFeeRate"`   // 费率上限 sat/vB，默认1000，超过时拒绝构造交易
+	MaxFeeRatio  float64          `yaml:"maxFeeRatio"`  // 网络费占输入聪的比例上限，默认0.5，超过时拒绝签名
+
+	// 选币策略
+	CoinSelection      string `yaml:"coinSelection"`      // bnb（默认），consolidate，privacy，legacy
+	ConsolidateFeeRate int64  `yaml:"consolidateFeeRate"` // consolidate 模式和自动合并时，费率不超过这个值才合并utxo，默认5
+
+	// 自动合并碎片utxo
+	AutoConsolidate     []string `yaml:"autoConsolidate"`     // 需要自动合并的资产，例如 ::（白聪），ordx:f:pearl，runes:f:xxx
+	ConsolidateMinUtxos int      `yaml:"consolidateMinUtxos"` // 资产的utxo数量达到这个值才合并，默认10
 }
@@ -43,6 +43,9 @@ type Wallet interface {
 	GetAddress() string
 	GetPubKeyByIndex(uint32) *secp256k1.PublicKey
 	GetAddressByIndex(uint32) string
+	GetAddressByType(addrType string, index uint32) string // P2TR, P2WPKH, P2SH-P2WPKH, P2PKH
+	GetAddressesByIndex(uint32) map[string]string          // key: address type
+	GetChangeAddressByIndex(uint32) string                 // p2tr, change = 1
 	GetNodePubKey() *secp256k1.PublicKey // subAccount = 0
 
 	// default channel wallet, CWId = 0
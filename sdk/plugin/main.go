@@ -45,6 +45,15 @@ func InitWalletMgr(dbPath string) error {
 	}
 	_mgr = mgr
 
+	if lcfg.Wallet.Signer != "" {
+		// 私钥在外部签名器中，不使用本地钱包
+		if _, err := _mgr.ConnectSigner(lcfg.Wallet.Signer); err != nil {
+			wallet.Log.Errorf("ConnectSigner %s failed, %v", lcfg.Wallet.Signer, err)
+			return fmt.Errorf("ConnectSigner %s failed, %v", lcfg.Wallet.Signer, err)
+		}
+		return nil
+	}
+
 	if lcfg.Wallet.PSFile != "" {
 		pw, loadErr := wallet.LoadPassword(lcfg.DB + "/" + lcfg.Wallet.PSFile)
 		if loadErr == nil {
@@ -103,6 +112,21 @@ func SignMsg(msg []byte) ([]byte, error) {
 	return w.SignMessage(msg)
 }
 
+// BIP322 消息签名，format: simple/full，签名是base64编码
+func SignMessageBIP322(msg, address, format string) (string, error) {
+	if _mgr == nil {
+		return "", fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SignMessageBIP322(msg, address, format)
+}
+
+func VerifyMessageBIP322(address, msg, signature string) (bool, error) {
+	if _mgr == nil {
+		return false, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.VerifyMessageBIP322(address, msg, signature)
+}
+
 func SignPsbt_SatsNet(packet *spsbt.Packet) error {
 	if _mgr == nil {
 		return fmt.Errorf("STPManager not init")
@@ -146,6 +170,378 @@ func ImportWallet(mn, pw string) error {
 	return err
 }
 
+// BIP85 派生子钱包，words: 12/18/24
+func CreateChildWallet(parentId int64, index uint32, words int, pw string) (int64, error) {
+	if _mgr == nil {
+		return -1, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CreateChildWallet(parentId, index, words, pw)
+}
+
+func GetFeeEstimates() (*wallet.FeeEstimates, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetFeeEstimates(), nil
+}
+
+func PreviewSendFees(destAddr, assetName, amt string, customFeeRate int64) ([]*wallet.FeePreview, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.PreviewSendFees(destAddr, assetName, amt, customFeeRate)
+}
+
+func SetCoinSelection(strategy string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SetCoinSelection(strategy)
+}
+
+func SendAssetsWithCoinControl(destAddr, assetName, amt string, n int, feeRate int64,
+	include, exclude []string) (string, int64, error) {
+	if _mgr == nil {
+		return "", 0, fmt.Errorf("STPManager not init")
+	}
+	tx, fee, err := _mgr.BatchSendAssetsWithCoinControl(destAddr, assetName, amt, n, feeRate, nil,
+		&wallet.CoinControl{
+			Include: include,
+			Exclude: exclude,
+		})
+	if err != nil {
+		return "", 0, err
+	}
+	return tx.TxID(), fee, nil
+}
+
+func PlanConsolidation(address, assetName string, feeRate int64) (*wallet.ConsolidationPlan, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.PlanConsolidation(address, assetName, feeRate)
+}
+
+func ExecuteConsolidation(plan *wallet.ConsolidationPlan) (string, error) {
+	if _mgr == nil {
+		return "", fmt.Errorf("STPManager not init")
+	}
+	return _mgr.ExecuteConsolidation(plan)
+}
+
+func CancelConsolidation(plan *wallet.ConsolidationPlan) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CancelConsolidation(plan)
+}
+
+func SetAutoConsolidation(assets []string, maxFeeRate int64, minUtxos int) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	_mgr.SetAutoConsolidation(&wallet.AutoConsolidation{
+		Assets:     assets,
+		MaxFeeRate: maxFeeRate,
+		MinUtxos:   minUtxos,
+	})
+	return nil
+}
+
+// 收款文件可以是 csv 或者 json
+func NewPayoutFromFile(path, assetName string, satsNet bool, feeRate int64) (int64, error) {
+	if _mgr == nil {
+		return 0, fmt.Errorf("STPManager not init")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	resv, err := _mgr.NewPayout(data, assetName, satsNet, feeRate, nil)
+	if err != nil {
+		return 0, err
+	}
+	return resv.Id, nil
+}
+
+func RunPayout(id int64) (*wallet.PayoutReport, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	err := _mgr.RunPayout(id)
+	report, reportErr := _mgr.GetPayoutReport(id)
+	if err != nil {
+		return report, err
+	}
+	return report, reportErr
+}
+
+func CancelPayout(id int64) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CancelPayout(id)
+}
+
+func GetPayoutReport(id int64) (*wallet.PayoutReport, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetPayoutReport(id)
+}
+
+// interval 为0时只在 startHeight 发送一次
+func NewScheduledSend(address, assetName, amt string, value int64, satsNet bool, feeRate int64,
+	startHeight, interval, count int) (*wallet.ScheduledSendInfo, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	resv, err := _mgr.NewScheduledSend(address, assetName, amt, value, satsNet, feeRate, nil,
+		startHeight, interval, count)
+	if err != nil {
+		return nil, err
+	}
+	return resv.Info(), nil
+}
+
+func CancelScheduledSend(id int64) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CancelScheduledSend(id)
+}
+
+func GetScheduledSends() ([]*wallet.ScheduledSendInfo, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	result := make([]*wallet.ScheduledSendInfo, 0)
+	for _, resv := range _mgr.GetScheduledSends() {
+		result = append(result, resv.Info())
+	}
+	return result, nil
+}
+
+func SetLiquidityPolicy(policy *wallet.LiquidityPolicy) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SetLiquidityPolicy(policy)
+}
+
+func DeleteLiquidityPolicy(assetName string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.DeleteLiquidityPolicy(assetName)
+}
+
+func GetLiquidityPolicies() ([]*wallet.LiquidityPolicy, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetLiquidityPolicies(), nil
+}
+
+func GetLiquidityDecisions(assetName string, limit int) ([]*wallet.LiquidityDecision, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetLiquidityDecisions(assetName, limit), nil
+}
+
+// expiry 是一层的绝对高度
+func AddChannelHtlc(channelId string, amt int64, paymentHash string, expiry int, destNodeId string) (uint64, error) {
+	if _mgr == nil {
+		return 0, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.AddChannelHtlc(channelId, amt, paymentHash, expiry, destNodeId, nil)
+}
+
+func AcceptChannelHtlc(channelId, paymentHash string, amt int64) (uint64, error) {
+	if _mgr == nil {
+		return 0, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.AcceptChannelHtlc(channelId, paymentHash, amt)
+}
+
+func SettleChannelHtlc(channelId string, htlcId uint64, preimage string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SettleChannelHtlc(channelId, htlcId, preimage)
+}
+
+func FailChannelHtlc(channelId string, htlcId uint64, reason string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.FailChannelHtlc(channelId, htlcId, reason)
+}
+
+func GetChannelHtlcs(channelId string) ([]*wallet.ChannelHtlcInfo, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetChannelHtlcs(channelId)
+}
+
+// channelId 为空时收款到 destAddr
+func CreatePaymentRequest(assetName, amt, channelId, destAddr string, expiry int64,
+	description, paymentHash string) (string, error) {
+	if _mgr == nil {
+		return "", fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CreatePaymentRequest(assetName, amt, channelId, destAddr, expiry, description, paymentHash)
+}
+
+func DecodePaymentRequest(request string) (*wallet.PaymentRequest, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.DecodePaymentRequest(request)
+}
+
+func PayRequest(request, channelId string, feeUtxos []string) (*wallet.PaymentRequestResult, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.PayRequest(request, channelId, feeUtxos)
+}
+
+func GetPeerNodes() ([]*wallet.PeerInfo, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.GetPeerInfos(), nil
+}
+
+// url: http://host[:port]/stp/testnet
+func AddPeerNode(nodeId, url string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	_, err := _mgr.AddPeerNode(nodeId, url)
+	return err
+}
+
+func RemovePeerNode(nodeId string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.RemovePeerNode(nodeId)
+}
+
+func DiscoverPeerNodes() (int, error) {
+	if _mgr == nil {
+		return 0, fmt.Errorf("STPManager not init")
+	}
+	nodes, err := _mgr.DiscoverPeerNodes()
+	return len(nodes), err
+}
+
+// nodeId 为空时跟默认服务节点开通道
+func OpenChannelWithPeer(nodeId string, feeRate, amt int64, utxos []string, memo string) (string, error) {
+	if _mgr == nil {
+		return "", fmt.Errorf("STPManager not init")
+	}
+	return _mgr.OpenChannelWithPeer(nodeId, feeRate, amt, utxos, memo)
+}
+
+func SetStaticChannelBackupHandler(handler wallet.StaticChannelBackupHandler) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	_mgr.SetStaticChannelBackupHandler(handler)
+	return nil
+}
+
+func ExportStaticChannelBackup() ([]byte, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.ExportStaticChannelBackup()
+}
+
+func RecoverChannelsFromStaticBackup(data []byte, feeRate int64) ([]*wallet.ChannelRecoveryResult, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.RecoverChannelsFromStaticBackup(data, feeRate)
+}
+
+func ListChannels() ([]*wallet.ChannelSummary, error) {
+	if _mgr == nil {
+		return nil, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.ListChannels(), nil
+}
+
+func SetLabel(label *wallet.Label) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SetLabel(label)
+}
+
+func GetLabel(typ, ref string) *wallet.Label {
+	if _mgr == nil {
+		return nil
+	}
+	return _mgr.GetLabel(typ, ref)
+}
+
+func ImportLabelsFromFile(path string) (int, error) {
+	if _mgr == nil {
+		return 0, fmt.Errorf("STPManager not init")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return _mgr.ImportLabels(data)
+}
+
+func ExportLabelsToFile(path string) error {
+	if _mgr == nil {
+		return fmt.Errorf("STPManager not init")
+	}
+	data, err := _mgr.ExportLabels()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func GetActivity(filter *wallet.ActivityFilter) ([]*wallet.ActivityItem, int, error) {
+	if _mgr == nil {
+		return nil, 0, fmt.Errorf("STPManager not init")
+	}
+	items, total := _mgr.GetActivity(filter)
+	return items, total, nil
+}
+
+func SignPsbt(psbtHex string, bExtract bool, override *wallet.TxPolicyOverride) (string, error) {
+	if _mgr == nil {
+		return "", fmt.Errorf("STPManager not init")
+	}
+	return _mgr.SignPsbtWithPolicy(psbtHex, bExtract, override)
+}
+
+func BumpFee(txId string, feeRate int64) (string, int64, error) {
+	if _mgr == nil {
+		return "", 0, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.BumpFee(txId, feeRate)
+}
+
+func CPFP(txId string, feeRate int64) (string, int64, error) {
+	if _mgr == nil {
+		return "", 0, fmt.Errorf("STPManager not init")
+	}
+	return _mgr.CPFP(txId, feeRate)
+}
+
 func GetPubKey() ([]byte, error) {
 	if _mgr == nil {
 		return nil, fmt.Errorf("STPManager not init")
@@ -1068,6 +1068,15 @@ func cloneWalletInfoForAccountSync(info *WalletInfo) *WalletInfo {
 	clone := *info
 	clone.Mnemonic = append([]byte(nil), info.Mnemonic...)
 	clone.Salt = append([]byte(nil), info.Salt...)
+	if info.Kdf != nil {
+		kdf := *info.Kdf
+		kdf.Salt = append([]byte(nil), info.Kdf.Salt...)
+		clone.Kdf = &kdf
+	}
+	if info.Bip85 != nil {
+		bip85 := *info.Bip85
+		clone.Bip85 = &bip85
+	}
 	clone.AccountNames = make(map[uint32]string, len(info.AccountNames))
 	for key, value := range info.AccountNames {
 		clone.AccountNames[key] = value
@@ -1103,15 +1112,9 @@ func (p *Manager) newWalletInfoFromManagedWalletLocked(remote account.ManagedWal
 		info.AccountNames[sub.Index] = sub.Name
 		info.AccountDIDs[sub.Index] = sub.DID
 	}
-	key, err := p.newSnaclKey(password)
-	if err != nil {
-		return nil, err
-	}
-	info.Mnemonic, err = key.Encrypt([]byte(remote.Mnemonic))
-	if err != nil {
+	if err := p.encryptWalletSecret(remote.Mnemonic, password, &info.WalletInDB); err != nil {
 		return nil, err
 	}
-	info.Salt = key.Marshal()
 	return info, nil
 }
 
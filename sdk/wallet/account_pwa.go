@@ -490,13 +490,17 @@ func (p *Manager) prepareAccountRestoreLocked(value account.Backup, password str
 	if len(p.walletInfoMap) != 0 || p.wallet != nil {
 		return nil, fmt.Errorf("account restore requires an empty wallet database")
 	}
+	derivations, err := resolveBackupChildWallets(&backup)
+	if err != nil {
+		return nil, err
+	}
 	prepared := &preparedAccountRestore{
 		wallets: make(map[int64]*WalletInfo, len(backup.Wallets)),
 		results: make([]RestoredWalletResult, 0, len(backup.Wallets)),
 		status:  cloneStatusForAccountRestore(p.status),
 	}
 	fingerprints := make(map[string]struct{}, len(backup.Wallets))
-	for _, item := range backup.Wallets {
+	for position, item := range backup.Wallets {
 		walletValue := NewInternalWalletWithMnemonic(item.Mnemonic, "", GetChainParam())
 		if walletValue == nil {
 			return nil, fmt.Errorf("restore wallet %q: invalid mnemonic", item.Name)
@@ -512,6 +516,7 @@ func (p *Manager) prepareAccountRestoreLocked(value account.Backup, password str
 		}
 		info := &WalletInfo{WalletInDB: WalletInDB{
 			Id: id, Accounts: int(item.AccountCount), Type: WALLET_TYPE_MNEMONIC, Name: item.Name,
+			Bip85:        derivations[position],
 			AccountNames: make(map[uint32]string, len(item.SubAccounts)),
 			AccountDIDs:  make(map[uint32]string, len(item.SubAccounts)),
 		}, Wallet: walletValue}
@@ -519,16 +524,9 @@ func (p *Manager) prepareAccountRestoreLocked(value account.Backup, password str
 			info.AccountNames[sub.Index] = sub.Name
 			info.AccountDIDs[sub.Index] = sub.DID
 		}
-		key, err := p.newSnaclKey(password)
-		if err != nil {
-			return nil, fmt.Errorf("derive restored wallet key %q: %w", item.Name, err)
-		}
-		ciphertext, err := key.Encrypt([]byte(item.Mnemonic))
-		if err != nil {
+		if err := p.encryptWalletSecret(item.Mnemonic, password, &info.WalletInDB); err != nil {
 			return nil, fmt.Errorf("encrypt restored wallet %q: %w", item.Name, err)
 		}
-		info.Mnemonic = ciphertext
-		info.Salt = key.Marshal()
 		accounts := make([]RestoredSubAccountResult, 0, len(item.SubAccounts))
 		for _, sub := range item.SubAccounts {
 			pubKey := walletValue.GetPubKeyByIndex(sub.Index)
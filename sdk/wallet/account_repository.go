@@ -65,6 +65,8 @@ func (p *Manager) ExportAccountBackup(password string, metadata map[int64]Accoun
 	defer p.mutex.RUnlock()
 	infos := p.canonicalWalletInfosLocked()
 	backup := account.Backup{Version: account.Version, Wallets: make([]account.WalletBackup, 0, len(infos))}
+	positions := make(map[string]int, len(infos))
+	mnemonics := make([]string, 0, len(infos))
 	for _, info := range infos {
 		id := info.Id
 		if info == nil || info.Type != WALLET_TYPE_MNEMONIC {
@@ -96,7 +98,17 @@ func (p *Manager) ExportAccountBackup(password string, metadata map[int64]Accoun
 				Index: uint32(index), Name: accountName, DID: did,
 			}
 		}
-		backup.Wallets = append(backup.Wallets, account.WalletBackup{Name: name, Mnemonic: mnemonic, AccountCount: uint32(info.Accounts), SubAccounts: subAccounts})
+		item := account.WalletBackup{Name: name, Mnemonic: mnemonic, AccountCount: uint32(info.Accounts), SubAccounts: subAccounts}
+		// 子钱包只需要主钱包的助记词就可以恢复
+		if derivation := childBackupDerivation(info, mnemonic, positions, mnemonics); derivation != nil {
+			item.Mnemonic = ""
+			item.Derivation = derivation
+		}
+		if fingerprint := walletFingerprint(info.Wallet); fingerprint != "" {
+			positions[fingerprint] = len(backup.Wallets)
+		}
+		mnemonics = append(mnemonics, mnemonic)
+		backup.Wallets = append(backup.Wallets, item)
 	}
 	return account.NormalizeBackup(backup)
 }
@@ -109,7 +121,11 @@ func (p *Manager) RestoreAccountBackup(value account.Backup, password string) er
 	if p.IsWalletExist() {
 		return fmt.Errorf("account restore requires an empty wallet database")
 	}
-	for _, item := range backup.Wallets {
+	derivations, err := resolveBackupChildWallets(&backup)
+	if err != nil {
+		return err
+	}
+	for position, item := range backup.Wallets {
 		id, err := p.ImportWallet(item.Mnemonic, password)
 		if err != nil {
 			return err
@@ -121,6 +137,7 @@ func (p *Manager) RestoreAccountBackup(value account.Backup, password string) er
 			return fmt.Errorf("restored wallet %d was not persisted", id)
 		}
 		info.Name = item.Name
+		info.Bip85 = derivations[position]
 		info.Accounts = int(item.AccountCount)
 		info.AccountNames = make(map[uint32]string, len(item.SubAccounts))
 		info.AccountDIDs = make(map[uint32]string, len(item.SubAccounts))
@@ -72,8 +72,9 @@ func (p *Manager) PerformLocalAction(action string, actionParam any,
 	if p.wallet == nil {
 		return "", -1, fmt.Errorf("wallet is not created/unlocked")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", -1, err
 	}
 
 	resv, err := NewLocalActionPerformData(p.GenerateNewResvId(), action, actionParam,
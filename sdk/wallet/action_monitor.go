@@ -89,6 +89,11 @@ func (p *Manager) actionMonitorThread(stop <-chan struct{}, sendTxInL1 bool) {
 		p.HandleRemoteActionStatus(sendTxInL1)
 		p.HandleLocalActionStatus(sendTxInL1)
 		p.handleBTCLuckyMonitorTick(sendTxInL1)
+		p.handleConsolidationMonitorTick(sendTxInL1)
+		p.handlePayoutMonitorTick(sendTxInL1)
+		p.handleScheduleMonitorTick(sendTxInL1)
+		p.handleLiquidityMonitorTick(sendTxInL1)
+		p.handleActivityMonitorTick(sendTxInL1)
 		p.notifyMonitorTick(sendTxInL1)
 	}
 
@@ -35,8 +35,9 @@ func (p *Manager) PerformRemoteAction(doAction StartRemoteAction, action string,
 		return "", -1, nil, fmt.Errorf("peer is offline")
 	}
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", -1, nil, err
 	}
 
 	resv, client, serverPubkey, err := p.newRemoteAction(action, actionParam, more, feeRate, sendTxInL1, toBootstrap)
@@ -288,8 +289,9 @@ func (p *Manager) DeployRunes_Remote(assetName string, symbol int32, maxSupply i
 	if p.wallet == nil {
 		return "", 0, "", fmt.Errorf("wallet is not created/unlocked")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, "", err
 	}
 
 	param, err := EncodeRemoteDeployRunesParam(&RemoteDeployRunesParam{
@@ -367,11 +369,13 @@ func (p *Manager) StakeToBeMinner(bCoreNode bool, feeRate int64) (string, int64,
 		return "", 0, fmt.Errorf("invalid asset name %s", assetName)
 	}
 
-	var serverPubkey []byte
-	if bCoreNode {
-		serverPubkey, _ = hex.DecodeString(indexer.GetBootstrapPubKey())
-	} else {
-		serverPubkey = p.serverNode.Pubkey.SerializeCompressed()
+	// 质押到处理这个请求的节点的通道中
+	_, node, err := p.remoteActionClient(bCoreNode)
+	if err != nil {
+		return "", 0, err
+	}
+	serverPubkey := node.Pubkey.SerializeCompressed()
+	if !bCoreNode {
 		isServerCoreNode, err := p.l2IndexerClient.IsCoreNode(serverPubkey)
 		if err != nil {
 			return "", 0, err
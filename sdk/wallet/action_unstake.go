@@ -18,12 +18,6 @@ func (p *Manager) MinnerUnstake(feeRate int64) (string, int64, error) {
 	if p.wallet == nil {
 		return "", -1, fmt.Errorf("wallet is not created/unlocked")
 	}
-	if p.serverNode == nil || p.serverNode.client == nil {
-		return "", -1, fmt.Errorf("server node is not ready")
-	}
-	if !p.checkSuperNodeStatus() {
-		return "", -1, fmt.Errorf("peer is offline")
-	}
 	if p.hasLocalAction(LOCAL_ACTION_UNSTAKE_MINER) {
 		return "", -1, fmt.Errorf("same type of resv exists")
 	}
@@ -32,6 +26,14 @@ func (p *Manager) MinnerUnstake(feeRate int64) (string, int64, error) {
 	if err != nil {
 		return "", 0, err
 	}
+	// 质押资产所在通道的对端节点
+	node, err := p.getPeerNodeWithChannelAddress(p.wallet, param.MinerInfo.ChannelAddr)
+	if err != nil {
+		return "", -1, err
+	}
+	if !p.isNodeOnline(node, true) {
+		return "", -1, fmt.Errorf("peer is offline")
+	}
 
 	return p.PerformLocalAction(LOCAL_ACTION_UNSTAKE_MINER, param, feeRate)
 }
@@ -168,20 +168,69 @@ func cloneAssetSummary(summary *indexerwire.AssetSummary) *indexerwire.AssetSumm
 	return result
 }
 
+// 把 more 中的资产加到 summary 中
+func addAssetSummaryData(summary, more *indexerwire.AssetSummary) error {
+	for _, asset := range more.Data {
+		if asset == nil {
+			continue
+		}
+		merged := false
+		for _, current := range summary.Data {
+			if current != nil && current.Name == asset.Name {
+				if err := current.Add(asset); err != nil {
+					return err
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			summary.Data = append(summary.Data, asset.Clone())
+		}
+	}
+	return nil
+}
+
+// AssetSummary is the asset summary of an address with the address label.
+type AssetSummary struct {
+	*indexerwire.AssetSummary
+	Label string `json:"label,omitempty"`
+}
+
 // GetAssetSummary returns the normal L1 Indexer summary plus validated RGB11
 // allocations for local wallet addresses. RGB11 carrier sats remain part of
 // the physical BTC total but are removed from spendable plain sats.
-func (p *Manager) GetAssetSummary(address string) (*indexerwire.AssetSummary, error) {
+func (p *Manager) GetAssetSummary(address string) (*AssetSummary, error) {
 	if address == "" {
 		if p.wallet == nil {
 			return nil, ErrRGB11WalletLocked
 		}
 		address = p.wallet.GetAddress()
 	}
+	summary, err := p.mergedAssetSummary(address)
+	if err != nil {
+		return nil, err
+	}
+	return &AssetSummary{
+		AssetSummary: summary,
+		Label:        p.GetLabelText(LABEL_TYPE_ADDR, address),
+	}, nil
+}
+
+func (p *Manager) mergedAssetSummary(address string) (*indexerwire.AssetSummary, error) {
 	base := cloneAssetSummary(p.l1IndexerClient.GetAssetSummaryWithAddress(address))
 	if base == nil {
 		return nil, fmt.Errorf("get L1 asset summary for %s failed", address)
 	}
+	// 找零地址上的资产属于同一个子账户
+	if changeAddr := p.changeAddressOf(address); changeAddr != "" {
+		change := p.l1IndexerClient.GetAssetSummaryWithAddress(changeAddr)
+		if change != nil {
+			if err := addAssetSummaryData(base, change); err != nil {
+				Log.Errorf("merge change address %s summary failed: %v", changeAddr, err)
+			}
+		}
+	}
 	result := cloneAssetSummary(base)
 
 	rgbAssets, carrierSats, err := p.localRGB11Assets(address)
@@ -83,10 +83,16 @@ func TestGetAssetSummaryMergesLocalRGB11AndExcludesCarrierSats(t *testing.T) {
 		carrierSats += stored.OutValue.Value
 	}
 
+	if err := manager.SetLabel(&Label{Type: LABEL_TYPE_ADDR, Ref: wallet.GetAddress(), Label: "savings"}); err != nil {
+		t.Fatal(err)
+	}
 	summary, err := manager.GetAssetSummary(wallet.GetAddress())
 	if err != nil {
 		t.Fatal(err)
 	}
+	if summary.Label != "savings" {
+		t.Fatalf("summary label=%q", summary.Label)
+	}
 	var plain, total, rgb *indexer.AssetInfo
 	for _, asset := range summary.Data {
 		switch {
@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+/*
+BIP322 通用消息签名
+签名不是直接对消息签名，而是构造两个虚拟交易:
+  to_spend: 输入是空的outpoint，scriptSig中包含消息的tagged hash，唯一的输出(value=0)是被签名地址的锁定脚本
+  to_sign:  花费to_spend的输出，唯一的输出是OP_RETURN
+对to_sign的签名就是消息的签名，所以所有钱包能花费的地址类型都能签名，包括p2tr。
+  simple: 只返回to_sign输入的见证数据，只能用于原生隔离见证地址（P2SH-P2WPKH 的scriptSig不在见证数据中，无法验证）
+  full:   返回整个to_sign交易，可以用于所有地址类型，包括P2PKH
+  legacy: 旧的 "Bitcoin Signed Message" 签名（SignWalletMessage），只用于验证P2PKH等非taproot地址
+*/
+
+const (
+	BIP322_FORMAT_SIMPLE = "simple"
+	BIP322_FORMAT_FULL   = "full"
+	BIP322_FORMAT_LEGACY = "legacy"
+
+	bip322Tag = "BIP0322-signed-message"
+)
+
+func Bip322MessageHash(msg []byte) []byte {
+	hash := chainhash.TaggedHash([]byte(bip322Tag), msg)
+	return hash[:]
+}
+
+func bip322ToSpendTx(msg []byte, pkScript []byte) (*wire.MsgTx, error) {
+	sigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(Bip322MessageHash(msg)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(0)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 0xffffffff},
+		SignatureScript:  sigScript,
+		Sequence:         0,
+	})
+	tx.AddTxOut(&wire.TxOut{Value: 0, PkScript: pkScript})
+	return tx, nil
+}
+
+func bip322ToSignTx(toSpend *wire.MsgTx) *wire.MsgTx {
+	tx := wire.NewMsgTx(0)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: toSpend.TxHash(), Index: 0},
+		Sequence:         0,
+	})
+	tx.AddTxOut(&wire.TxOut{Value: 0, PkScript: []byte{txscript.OP_RETURN}})
+	return tx
+}
+
+// 用钱包当前子账户下address对应的私钥签名，address必须是钱包的地址
+func SignMessageBIP322(w common.Wallet, msg []byte, address, format string) ([]byte, error) {
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = BIP322_FORMAT_SIMPLE
+	}
+	switch format {
+	case BIP322_FORMAT_SIMPLE:
+		if !txscript.IsWitnessProgram(pkScript) {
+			return nil, fmt.Errorf("simple format needs a native segwit address, use %s format", BIP322_FORMAT_FULL)
+		}
+	case BIP322_FORMAT_FULL:
+	default:
+		return nil, fmt.Errorf("unsupported BIP322 format %s", format)
+	}
+
+	toSpend, err := bip322ToSpendTx(msg, pkScript)
+	if err != nil {
+		return nil, err
+	}
+	toSign := bip322ToSignTx(toSpend)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	prevFetcher.AddPrevOut(toSign.TxIn[0].PreviousOutPoint, toSpend.TxOut[0])
+	packet, err := CreatePsbt(toSign, prevFetcher, nil, w)
+	if err != nil {
+		return nil, err
+	}
+	err = w.SignPsbt(packet)
+	if err != nil {
+		Log.Errorf("SignPsbt failed. %v", err)
+		return nil, err
+	}
+	err = psbt.MaybeFinalizeAll(packet)
+	if err != nil {
+		return nil, fmt.Errorf("address %s is not controlled by the wallet, %v", address, err)
+	}
+	signedTx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == BIP322_FORMAT_SIMPLE {
+		err = psbt.WriteTxWitness(&buf, signedTx.TxIn[0].Witness)
+	} else {
+		err = signedTx.Serialize(&buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 验证签名，自动识别simple，full和legacy格式
+func VerifyMessageBIP322(address string, msg []byte, sig []byte) error {
+	return verifyMessageBIP322(address, msg, sig, GetChainParam())
+}
+
+func verifyMessageBIP322(address string, msg []byte, sig []byte, params *chaincfg.Params) error {
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return fmt.Errorf("invalid address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return err
+	}
+
+	toSpend, err := bip322ToSpendTx(msg, pkScript)
+	if err != nil {
+		return err
+	}
+	toSign := bip322ToSignTx(toSpend)
+
+	if witness, err := parseBip322Witness(sig); err == nil {
+		toSign.TxIn[0].Witness = witness
+		return executeBip322(toSpend, toSign)
+	}
+
+	var fullTx wire.MsgTx
+	if err := fullTx.Deserialize(bytes.NewReader(sig)); err == nil {
+		if err := checkBip322ToSign(toSign, &fullTx); err != nil {
+			return err
+		}
+		return executeBip322(toSpend, &fullTx)
+	}
+
+	if len(sig) == 65 {
+		return verifyBip322Legacy(addr, msg, sig, params)
+	}
+	return fmt.Errorf("invalid BIP322 signature")
+}
+
+// simple格式是序列化的见证数据，必须正好用完所有数据
+func parseBip322Witness(sig []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(sig)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > uint64(len(sig)) {
+		return nil, fmt.Errorf("invalid witness count %d", count)
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("trailing data in witness")
+	}
+	return witness, nil
+}
+
+// full格式的to_sign交易必须跟期望的结构一致，不支持附加输入（proof of funds）
+func checkBip322ToSign(expected, tx *wire.MsgTx) error {
+	if tx.Version != 0 && tx.Version != 2 {
+		return fmt.Errorf("invalid to_sign version %d", tx.Version)
+	}
+	if len(tx.TxIn) != 1 {
+		return fmt.Errorf("to_sign with %d inputs is not supported", len(tx.TxIn))
+	}
+	if tx.TxIn[0].PreviousOutPoint != expected.TxIn[0].PreviousOutPoint {
+		return fmt.Errorf("to_sign does not spend to_spend")
+	}
+	if len(tx.TxOut) != 1 || tx.TxOut[0].Value != 0 ||
+		!bytes.Equal(tx.TxOut[0].PkScript, expected.TxOut[0].PkScript) {
+		return fmt.Errorf("invalid to_sign output")
+	}
+	return nil
+}
+
+func executeBip322(toSpend, toSign *wire.MsgTx) error {
+	prevOut := toSpend.TxOut[0]
+	prevFetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+	sigHashes := txscript.NewTxSigHashes(toSign, prevFetcher)
+	vm, err := txscript.NewEngine(prevOut.PkScript, toSign, 0,
+		txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, prevFetcher)
+	if err != nil {
+		return err
+	}
+	err = vm.Execute()
+	if err != nil {
+		return fmt.Errorf("invalid BIP322 signature, %v", err)
+	}
+	return nil
+}
+
+// legacy格式的签名只能验证公钥哈希类的地址
+func verifyBip322Legacy(addr btcutil.Address, msg []byte, sig []byte, params *chaincfg.Params) error {
+	pubKey, _, err := ecdsa.RecoverCompact(sig, magicMsgHash(string(msg)))
+	if err != nil {
+		return err
+	}
+	for _, addrType := range SupportedAddressTypes {
+		if addrType == ADDRESS_TYPE_P2TR {
+			continue
+		}
+		recovered, err := getAddressFromPubKey(pubKey, addrType, params)
+		if err != nil {
+			continue
+		}
+		if recovered.EncodeAddress() == addr.EncodeAddress() {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid legacy signature")
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestBip322MessageHash(t *testing.T) {
+	vectors := map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	}
+	for msg, expected := range vectors {
+		hash := hex.EncodeToString(Bip322MessageHash([]byte(msg)))
+		if hash != expected {
+			t.Fatalf("message hash of %q is %s, expected %s", msg, hash, expected)
+		}
+	}
+
+	// BIP322 测试向量
+	sig, _ := base64.StdEncoding.DecodeString("AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=")
+	address := "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	err := verifyMessageBIP322(address, []byte("Hello World"), sig, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("verify test vector failed. %v", err)
+	}
+	err = verifyMessageBIP322(address, []byte(""), sig, &chaincfg.MainNetParams)
+	if err == nil {
+		t.Fatalf("signature of another message should be rejected")
+	}
+}
+
+func TestSignMessageBIP322(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	w := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	if w == nil {
+		t.Fatal("NewInternalWalletWithMnemonic failed")
+	}
+	msg := []byte("hello sat20")
+
+	for _, addrType := range SupportedAddressTypes {
+		address := w.GetAddressByType(addrType, 0)
+		formats := []string{BIP322_FORMAT_SIMPLE, BIP322_FORMAT_FULL}
+		if addrType == ADDRESS_TYPE_P2PKH || addrType == ADDRESS_TYPE_P2SH_P2WPKH {
+			if _, err := SignMessageBIP322(w, msg, address, BIP322_FORMAT_SIMPLE); err == nil {
+				t.Fatalf("simple format should not support %s", addrType)
+			}
+			formats = formats[1:]
+		}
+		for _, format := range formats {
+			sig, err := SignMessageBIP322(w, msg, address, format)
+			if err != nil {
+				t.Fatalf("%s %s sign failed. %v", addrType, format, err)
+			}
+			err = VerifyMessageBIP322(address, msg, sig)
+			if err != nil {
+				t.Fatalf("%s %s verify failed. %v", addrType, format, err)
+			}
+			err = VerifyMessageBIP322(address, []byte("another message"), sig)
+			if err == nil {
+				t.Fatalf("%s %s should reject another message", addrType, format)
+			}
+		}
+	}
+
+	// 不是钱包的地址
+	other := w.GetAddressByType(ADDRESS_TYPE_P2TR, 1)
+	if _, err := SignMessageBIP322(w, msg, other, BIP322_FORMAT_SIMPLE); err == nil {
+		t.Fatalf("address of another sub account should not be signed")
+	}
+
+	// legacy 签名
+	legacySig, _ := w.SignWalletMessage(string(msg))
+	p2tr := w.GetAddress()
+	if err := VerifyMessageBIP322(p2tr, msg, legacySig); err == nil {
+		t.Fatalf("legacy signature should not verify a taproot address")
+	}
+}
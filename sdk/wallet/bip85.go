@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/tyler-smith/go-bip39"
+)
+
+/*
+BIP85：从主钱包的根密钥确定性派生子钱包的助记词
+路径 m/83696968'/39'/{language}'/{words}'/{index}'，只支持英文助记词（language=0）
+子钱包的助记词跟其他钱包一样加密保存，但是只要有主钱包的助记词，就可以重新派生出来
+*/
+
+const (
+	BIP85_PURPOSE          = 83696968
+	BIP85_APP_BIP39        = 39
+	BIP85_APP_HEX          = 128169
+	BIP85_LANGUAGE_ENGLISH = 0
+
+	bip85HmacKey = "bip-entropy-from-k"
+)
+
+// 子钱包的派生信息，Parent是主钱包的指纹，在不同设备上保持不变
+type Bip85Derivation struct {
+	Parent string `json:"parent"`
+	Index  uint32 `json:"index"`
+	Words  int    `json:"words"`
+}
+
+// 按照路径派生子密钥，返回64字节的熵
+func DeriveBip85Entropy(masterkey *hdkeychain.ExtendedKey, path []uint32) ([]byte, error) {
+	if masterkey == nil || !masterkey.IsPrivate() {
+		return nil, fmt.Errorf("private master key is required")
+	}
+	key := masterkey
+	for _, index := range path {
+		child, err := key.Derive(hdkeychain.HardenedKeyStart + index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha512.New, []byte(bip85HmacKey))
+	mac.Write(privKey.Serialize())
+	return mac.Sum(nil), nil
+}
+
+func bip85EntropySize(words int) (int, error) {
+	switch words {
+	case 12, 18, 24:
+		return words * 4 / 3, nil
+	default:
+		return 0, fmt.Errorf("unsupported mnemonic length %d, should be 12, 18 or 24", words)
+	}
+}
+
+// 派生子钱包的助记词
+func DeriveBip85Mnemonic(masterkey *hdkeychain.ExtendedKey, words int, index uint32) (string, error) {
+	size, err := bip85EntropySize(words)
+	if err != nil {
+		return "", err
+	}
+	entropy, err := DeriveBip85Entropy(masterkey,
+		[]uint32{BIP85_PURPOSE, BIP85_APP_BIP39, BIP85_LANGUAGE_ENGLISH, uint32(words), index})
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy[:size])
+}
+
+func (p *InternalWallet) DeriveChildMnemonic(words int, index uint32) (string, error) {
+	if p.masterkey == nil {
+		return "", fmt.Errorf("wallet has no master key")
+	}
+	return DeriveBip85Mnemonic(p.masterkey, words, index)
+}
+
+// 从主钱包的助记词派生子钱包的助记词
+func deriveChildMnemonic(parentMnemonic string, words int, index uint32) (string, error) {
+	parent := NewInternalWalletWithMnemonic(parentMnemonic, "", GetChainParam())
+	if parent == nil {
+		return "", fmt.Errorf("invalid parent mnemonic")
+	}
+	return parent.DeriveChildMnemonic(words, index)
+}
@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+func TestDeriveBip85Mnemonic(t *testing.T) {
+	// BIP85 测试向量
+	masterkey, err := hdkeychain.NewKeyFromString("xprv9s21ZrQH143K2LBWUUQRFXhucrQqBpKdRRxNVq2zBqsx8HVqFk2uYo8kmbaLLHRdqtQpUm98uKfu3vca1LqdGhUtyoFnCNkfmXRyPXLjbKb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, err := DeriveBip85Mnemonic(masterkey, 12, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "girl mad pet galaxy egg matter matrix prison refuse sense ordinary nose"
+	if mnemonic != expected {
+		t.Fatalf("derived %s, expected %s", mnemonic, expected)
+	}
+	if _, err := DeriveBip85Mnemonic(masterkey, 15, 0); err == nil {
+		t.Fatal("15 words should not be supported")
+	}
+	for _, words := range []int{18, 24} {
+		mnemonic, err := DeriveBip85Mnemonic(masterkey, words, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam()) == nil {
+			t.Fatalf("%d words mnemonic is not accepted", words)
+		}
+	}
+}
+
+func TestCreateChildWallet(t *testing.T) {
+	oldChain := _chain
+	_chain = "testnet"
+	defer func() { _chain = oldChain }()
+
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	source := newAccountManagementAutoTestManager(t)
+	parentID, err := source.ImportWallet(mnemonic, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateChildWallet(parentID, 0, 12, "wrong"); err == nil {
+		t.Fatal("wrong password should fail")
+	}
+	childID, err := source.CreateChildWallet(parentID, 0, 12, "password")
+	if err != nil {
+		t.Fatalf("CreateChildWallet failed. %v", err)
+	}
+	if _, err := source.CreateChildWallet(parentID, 0, 12, "password"); err == nil {
+		t.Fatal("same child wallet should not be created twice")
+	}
+	if _, err := source.CreateChildWallet(parentID, 1, 24, "password"); err != nil {
+		t.Fatalf("CreateChildWallet failed. %v", err)
+	}
+	if source.GetWallet().GetId() != parentID {
+		t.Fatal("current wallet should not be switched")
+	}
+
+	parentWallet := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	expected, _ := parentWallet.DeriveChildMnemonic(12, 0)
+	if source.GetMnemonic(childID, "password") != expected {
+		t.Fatal("child mnemonic mismatch")
+	}
+	catalog := source.GetWalletCatalog()
+	if len(catalog) != 3 || catalog[0].Parent != nil {
+		t.Fatalf("unexpected catalog %+v", catalog)
+	}
+	for _, entry := range catalog[1:] {
+		if entry.Parent == nil || entry.Parent.ParentID != parentID ||
+			entry.Parent.Fingerprint != catalog[0].Fingerprint {
+			t.Fatalf("child wallet %d has no parent link", entry.ID)
+		}
+	}
+
+	// 账户备份只保存主钱包的助记词
+	backup, err := source.ExportAccountBackup("password", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Wallets) != 3 || backup.Wallets[0].Mnemonic != mnemonic {
+		t.Fatalf("unexpected backup %+v", backup)
+	}
+	for _, item := range backup.Wallets[1:] {
+		if item.Mnemonic != "" || item.Derivation == nil || item.Derivation.Parent != 0 {
+			t.Fatalf("child wallet %s should be stored as derivation", item.Name)
+		}
+	}
+
+	target := newAccountManagementAutoTestManager(t)
+	if err := target.RestoreAccountBackup(backup, "password"); err != nil {
+		t.Fatalf("RestoreAccountBackup failed. %v", err)
+	}
+	restored := target.GetWalletCatalog()
+	if len(restored) != len(catalog) {
+		t.Fatalf("restored %d wallets, expected %d", len(restored), len(catalog))
+	}
+	for i, entry := range catalog {
+		if restored[i].Fingerprint != entry.Fingerprint || restored[i].Name != entry.Name {
+			t.Fatalf("wallet %d mismatch: %+v, expected %+v", i, restored[i], entry)
+		}
+		if (entry.Parent == nil) != (restored[i].Parent == nil) {
+			t.Fatalf("wallet %d parent link mismatch", i)
+		}
+	}
+	if restored[1].Parent.ParentID != restored[0].ID || restored[2].Parent.Words != 24 {
+		t.Fatalf("unexpected parent link %+v", restored[2].Parent)
+	}
+}
@@ -29,8 +29,9 @@ func (p *Manager) inscribeV2(srcUtxoMgr *UtxoMgr, destAddr string,
 		destAddr = srcAddr
 	}
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 
 	p.utxoLockerL1.Reload(srcAddr)
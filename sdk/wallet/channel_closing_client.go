@@ -16,8 +16,8 @@ func (p *Manager) IsCoreChannel(channel *Channel) bool {
 	if p.IsBootstrapNode() {
 		return true
 	}
-	if p.ServerIsBootstrapNode() {
-		return channel != nil && channel.IsInitiator
+	if channel != nil && p.isBootstrapPeer(p.getPeerNode(channel.PeerNodeId)) {
+		return channel.IsInitiator
 	}
 	return false
 }
@@ -162,11 +162,15 @@ func (p *Manager) CloserInitCoopCloseProcess(channelID string, feeRate int64) (s
 	channel.Mutex.Lock()
 	defer channel.Mutex.Unlock()
 
+	if err := channel.checkNoPendingHtlcs(); err != nil {
+		return "", "", err
+	}
 	if err := p.SaveBackupChannelToDB(&channel.ChannelInDB); err != nil {
 		return "", "", err
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", "", err
 	}
 	priv, err := btcec.NewPrivateKey()
 	if err != nil {
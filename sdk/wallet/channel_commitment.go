@@ -36,6 +36,8 @@ type ChannelCommitmentV1 struct {
 
 	PrevTxs []*wire.MsgTx // 在CommitTx之前需要广播的tx，已经签名
 	NextTxs []*wire.MsgTx // 在CommitTx之后需要广播的tx，已经签名
+
+	Htlcs []*ChannelHtlc // 未完成的htlc，每个在CommitTx中有一个单独的输出
 }
 
 type CommitmentTx struct {
@@ -124,6 +124,13 @@ func (p *Manager) CreateCommitTx3(whoseCommit int, channel *Channel, keyRing *Co
 	}
 	index := len(commitTx.TxOut)
 
+	// htlc amounts have been taken out of the plain balances, so the outputs
+	// are placed before the plain outputs and keep their indexes stable.
+	err = AddCommitmentHtlcOutputs(commitTx, channel, keyRing, SelectCommitHtlcs(channel, serverCommit), &weightEstimate)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("CreateCommitTx3 %w", err)
+	}
+
 	// Rebalance may switch plain-sats ownership for reopen/splicing scenarios,
 	// but it must preserve the invariant that the delayed side has enough white
 	// sats for fee-sensitive follow-up transactions.
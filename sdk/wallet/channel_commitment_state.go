@@ -22,6 +22,17 @@ func SelectCommitBalances(channel *Channel, serverCommit bool) (map[AssetName]*D
 	return channel.GetCommitRemoteBalance(), channel.GetCommitLocalBalance()
 }
 
+// 跟SelectCommitBalances一样，返回的htlc是commitment所有者视角
+func SelectCommitHtlcs(channel *Channel, serverCommit bool) []*ChannelHtlc {
+	if channel == nil {
+		return nil
+	}
+	if serverCommit != channel.IsInitiator {
+		return channel.LocalCommitment.Htlcs
+	}
+	return channel.RemoteCommitment.Htlcs
+}
+
 func ClampCommitmentFeeRate(channel *Channel, feeRate int64) int64 {
 	if channel == nil || channel.FeeCfg == nil {
 		return feeRate
@@ -162,12 +162,12 @@ func (p *Manager) AddExistingOpeningAnchorOutput(resv *FundingReservation) error
 }
 
 func (p *Manager) SendFundingBroadcastedReq(resv *FundingReservation) {
-	if resv == nil || resv.FundingBroadcasted == nil || p.serverNode == nil || p.serverNode.client == nil {
+	if resv == nil || resv.FundingBroadcasted == nil || resv.Channel == nil {
 		return
 	}
-	client, ok := p.serverNode.client.(NodeRPCClient)
+	client, ok := p.GetPeerNodeClient(&resv.Channel.ChannelInDB).(NodeRPCClient)
 	if !ok {
-		Log.Warnf("SendFundingBroadcastedReq skipped: server node client does not support reservation helper")
+		Log.Warnf("SendFundingBroadcastedReq skipped: peer node client does not support reservation helper")
 		return
 	}
 	if err := client.SendFundingBroadcastedReq(resv); err != nil {
@@ -159,6 +159,12 @@ func (c *ChannelInDB) Clone() *ChannelInDB {
 	for k, v := range c.RemoteCommitment.RemoteBalance {
 		n.RemoteCommitment.RemoteBalance[k] = v.Clone()
 	}
+	for _, h := range c.LocalCommitment.Htlcs {
+		n.LocalCommitment.Htlcs = append(n.LocalCommitment.Htlcs, h.Clone())
+	}
+	for _, h := range c.RemoteCommitment.Htlcs {
+		n.RemoteCommitment.Htlcs = append(n.RemoteCommitment.Htlcs, h.Clone())
+	}
 
 	return n
 }
@@ -8,6 +8,7 @@ const (
 	MSG_CHANNEL_UNEXPECTEDLY_CLOSED = "channelclosedunexpectedly"
 	MSG_CHANNEL_PUNISHED            = "channelpunished"
 	MSG_CHANNEL_SWEPT               = "channelswept"
+	MSG_CHANNEL_BACKUP_UPDATED      = "channelbackupupdated"
 	MSG_SPLICING_IN                 = "splicingin"
 	MSG_SPLICING_OUT                = "splicingout"
 	MSG_EXPANDED                    = "expanded"
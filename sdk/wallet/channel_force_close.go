@@ -44,8 +44,9 @@ func (p *Manager) ForcelyCloseChannel(channel *Channel, feeRate int64) (string,
 		return "", "", fmt.Errorf("can't find lastest commitment deAnchor TX")
 	}
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", "", err
 	}
 
 	resv := &ClosingReservation{
@@ -202,7 +203,8 @@ func (p *Manager) HandleChannelForceCloseWaitToSweep(resv *ClosingReservation, h
 	if resv == nil || resv.Channel == nil {
 		return fmt.Errorf("invalid force close reservation")
 	}
-	if height-resv.CloseHeight <= int(resv.Channel.CsvDelay) {
+	// 对端的commitment中，自己的输出直接回到通道地址，只有htlc需要清扫，不需要等待CSV
+	if !resv.RemoteCommit && height-resv.CloseHeight <= int(resv.Channel.CsvDelay) {
 		return nil
 	}
 
@@ -211,11 +213,40 @@ func (p *Manager) HandleChannelForceCloseWaitToSweep(resv *ClosingReservation, h
 		return nil
 	}
 
-	sweepTxPackage, err := p.BuildSignedSweepTxForClient(resv.Channel, height, p.GetFeeRate())
+	// 自己提供的htlc要等超时后才能取回，跟delayed output一起清扫
+	commitTx := resv.Channel.LocalCommitment.CommitTx
+	if resv.RemoteCommit {
+		commitTx = resv.CloseTx
+	}
+	pending, err := p.hasPendingHtlcOutputs(resv.Channel, commitTx, height)
+	if err != nil {
+		Log.Errorf("hasPendingHtlcOutputs failed. %v", err)
+		return err
+	}
+	if pending {
+		return nil
+	}
+
+	var sweepTxPackage *SignedSweepTxPackage
+	if !resv.RemoteCommit {
+		sweepTxPackage, err = p.BuildSignedSweepTxForClient(resv.Channel, height, p.GetFeeRate())
+		if err != nil {
+			Log.Errorf("BuildSignedSweepTxForClient failed. %v", err)
+			return err
+		}
+	}
+	htlcTxPackage, err := p.BuildSignedHtlcSweepTxForClient(resv.Channel, commitTx, height, p.GetFeeRate())
 	if err != nil {
-		Log.Errorf("BuildSignedSweepTxForClient failed. %v", err)
+		Log.Errorf("BuildSignedHtlcSweepTxForClient failed. %v", err)
 		return err
 	}
+	if htlcTxPackage != nil {
+		if sweepTxPackage == nil || sweepTxPackage.SweepTx == nil {
+			sweepTxPackage = htlcTxPackage
+		} else {
+			sweepTxPackage.Txs = append(sweepTxPackage.Txs, htlcTxPackage.Txs...)
+		}
+	}
 	if sweepTxPackage == nil || sweepTxPackage.SweepTx == nil {
 		Log.Warningf("output too small to sweep")
 		return p.HandleChannelForceCloseSweepConfirmed(resv)
@@ -251,3 +282,55 @@ func (p *Manager) HandleChannelForceCloseSweepConfirmed(resv *ClosingReservation
 	p.SendMessageToUpper(MSG_CHANNEL_SWEPT, channel.ClosingTx.TxID())
 	return nil
 }
+
+// 对端广播了它最新的commitment，自己的资产直接回到通道地址，htlc输出由清扫流程处理
+func (p *Manager) HandleRemoteCommitBroadcasted(channel *Channel, commitTx *wire.MsgTx) error {
+	if channel == nil || commitTx == nil {
+		return fmt.Errorf("invalid remote commitment")
+	}
+	if channel.Status >= CS_CLOSING_STARTED {
+		return nil
+	}
+
+	Log.Warnf("channel %s forcely closed by peer, commit tx %s", channel.ChannelId, commitTx.TxID())
+	resv := &ClosingReservation{
+		ClosingDataInDB: ClosingDataInDB{
+			ReservationBase: NewReservationBase(p.GenerateNewResvId(), false, 0, p.wallet),
+			ChannelId:       channel.ChannelId,
+			FeeRate:         p.GetFeeRate(),
+			CloseTx:         commitTx,
+			RemoteCommit:    true,
+		},
+		Channel: channel,
+	}
+	resv.InitRuntime()
+
+	p.AddResv(resv)
+	p.DisableChannel(channel)
+	channel.Status = CS_CLOSE_FORCELY_BROADCASTED
+	channel.ClosingTx = commitTx
+	if err := p.SaveWalletReservation(resv); err != nil {
+		return err
+	}
+	return p.SaveChannelToDB(channel)
+}
+
+// 检查对端是否广播了当前的commitment。已撤销的commitment由watchtower处理
+func (p *Manager) checkRemoteCommitBroadcasted() {
+	for _, channel := range p.GetAllChannels() {
+		if channel == nil || channel.Status != CS_READY ||
+			channel.RemoteCommitment == nil || channel.RemoteCommitment.CommitTx == nil {
+			continue
+		}
+		commitTx := channel.RemoteCommitment.CommitTx
+		if !p.GetIndexerRPCClient().IsTxConfirmed(commitTx.TxID()) {
+			continue
+		}
+		channel.Mutex.Lock()
+		err := p.HandleRemoteCommitBroadcasted(channel, commitTx)
+		channel.Mutex.Unlock()
+		if err != nil {
+			Log.Errorf("HandleRemoteCommitBroadcasted %s failed. %v", channel.ChannelId, err)
+		}
+	}
+}
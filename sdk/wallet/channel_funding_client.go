@@ -16,14 +16,15 @@ func (p *Manager) funderProcessAcceptChannel(resv *FundingReservation) error {
 	if resv == nil || resv.Accept == nil || resv.Channel == nil {
 		return fmt.Errorf("invalid funding reservation")
 	}
-	if p.serverNode == nil || p.serverNode.Pubkey == nil {
-		return fmt.Errorf("server node is not configured")
+	peer := p.getPeerNode(resv.Channel.PeerNodeId)
+	if peer == nil || peer.Pubkey == nil {
+		return fmt.Errorf("peer node %s is not configured", hex.EncodeToString(resv.Channel.PeerNodeId))
 	}
 
 	msg := resv.Accept
-	if !bytes.Equal(msg.FundingKey, p.serverNode.Pubkey.SerializeCompressed()) {
-		return fmt.Errorf("server node use a different wallet public key %s %s",
-			hex.EncodeToString(msg.FundingKey), hex.EncodeToString(p.serverNode.Pubkey.SerializeCompressed()))
+	if !bytes.Equal(msg.FundingKey, peer.Pubkey.SerializeCompressed()) {
+		return fmt.Errorf("peer node use a different wallet public key %s %s",
+			hex.EncodeToString(msg.FundingKey), hex.EncodeToString(peer.Pubkey.SerializeCompressed()))
 	}
 
 	remoteFundingKey, err := utils.BytesToPublicKey(msg.FundingKey)
@@ -219,16 +220,24 @@ func (p *Manager) FunderProcessFundingSigned(resv *FundingReservation) error {
 }
 
 func (p *Manager) FunderInitFundingProcess(feeRate, amt int64, utxos []string, memo string, l2DrainTxId string) (string, error) {
-	peerWallet := p.GetServerWalletAddress()
+	return p.funderInitFundingProcess(p.serverNode, feeRate, amt, utxos, memo, l2DrainTxId)
+}
+
+func (p *Manager) funderInitFundingProcess(peer *Node, feeRate, amt int64, utxos []string, memo string, l2DrainTxId string) (string, error) {
+	if peer == nil || peer.Pubkey == nil || peer.RPCClient() == nil {
+		return "", fmt.Errorf("peer node is not configured")
+	}
+	peerWallet := PublicKeyToP2TRAddress(peer.Pubkey)
 	if c := p.GetChannelByPeerWallet(peerWallet); c != nil {
 		return "", fmt.Errorf("channel exists")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", err
 	}
 	if len(utxos) == 0 {
 		var err error
-		utxos, err = p.SelectWalletFundingOutpoints(feeRate, amt, nil)
+		utxos, err = p.SelectWalletFundingOutpoints(feeRate, amt, p.GetPeerFeeConfig(nodeKey(peer)))
 		if err != nil {
 			return "", err
 		}
@@ -241,16 +250,18 @@ func (p *Manager) FunderInitFundingProcess(feeRate, amt int64, utxos []string, m
 		Memo:              memo,
 		NeedSendFundingTx: true,
 		L2DrainTxId:       l2DrainTxId,
+		PeerNodeId:        peer.NodeId.SerializeCompressed(),
 	})
 	if err != nil {
 		return "", err
 	}
 
 	for {
-		if err = p.serverNode.client.SendOpenChannelReq(resv); err != nil {
+		if err = peer.client.SendOpenChannelReq(resv); err != nil {
 			break
 		}
 		resv.Channel.FeeCfg = NewFromOpenChannelFee(resv.Accept.OpenFee)
+		p.setPeerFeeConfig(resv.Channel.PeerNodeId, resv.Channel.FeeCfg)
 		resv.Channel.Capacity = amt - resv.Channel.FeeCfg.FeeToDAO()
 		resv.FundingUtxos, err = p.AllowOpen(feeRate, amt, utxos, resv.Channel.FeeCfg)
 		if err != nil {
@@ -259,7 +270,7 @@ func (p *Manager) FunderInitFundingProcess(feeRate, amt int64, utxos []string, m
 		if err = p.funderProcessAcceptChannel(resv); err != nil {
 			break
 		}
-		if err = p.serverNode.client.SendFundingCreatedReq(resv); err != nil {
+		if err = peer.client.SendFundingCreatedReq(resv); err != nil {
 			break
 		}
 		if err = p.funderProcessFundingSigned(resv); err != nil {
@@ -292,8 +303,8 @@ func (p *Manager) FunderInitFundingProcess(feeRate, amt int64, utxos []string, m
 
 	if err != nil {
 		p.DelResvWithId(resv.Id)
-		if resv.Id != 0 && p.serverNode != nil && p.serverNode.client != nil {
-			_ = p.serverNode.client.SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, -1, err.Error())
+		if resv.Id != 0 {
+			_ = peer.client.SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, -1, err.Error())
 		}
 		return "", err
 	}
@@ -302,7 +313,16 @@ func (p *Manager) FunderInitFundingProcess(feeRate, amt int64, utxos []string, m
 
 func (p *Manager) FunderInitReOpenProcess(amt int64, fundingUtxo *TxOutput, memo string, needSendFundingTx bool,
 	skipOpeningAnchorTx bool, fundingFeeCfg *ChannelFeeConfig, l2DrainTxId string) (string, error) {
-	peerWallet := p.GetServerWalletAddress()
+	return p.funderInitReOpenProcess(p.serverNode, amt, fundingUtxo, memo, needSendFundingTx,
+		skipOpeningAnchorTx, fundingFeeCfg, l2DrainTxId)
+}
+
+func (p *Manager) funderInitReOpenProcess(peer *Node, amt int64, fundingUtxo *TxOutput, memo string, needSendFundingTx bool,
+	skipOpeningAnchorTx bool, fundingFeeCfg *ChannelFeeConfig, l2DrainTxId string) (string, error) {
+	if peer == nil || peer.Pubkey == nil || peer.RPCClient() == nil {
+		return "", fmt.Errorf("peer node is not configured")
+	}
+	peerWallet := PublicKeyToP2TRAddress(peer.Pubkey)
 	if c := p.GetChannelByPeerWallet(peerWallet); c != nil {
 		return "", fmt.Errorf("channel exists")
 	}
@@ -327,16 +347,18 @@ func (p *Manager) FunderInitReOpenProcess(amt int64, fundingUtxo *TxOutput, memo
 		SkipOpeningAnchorTx: skipOpeningAnchorTx,
 		L2DrainTxId:         l2DrainTxId,
 		InitialCapacity:     amt,
+		PeerNodeId:          peer.NodeId.SerializeCompressed(),
 	})
 	if err != nil {
 		return "", err
 	}
 
 	for {
-		if err = p.serverNode.client.SendOpenChannelReq(resv); err != nil {
+		if err = peer.client.SendOpenChannelReq(resv); err != nil {
 			break
 		}
 		resv.Channel.FeeCfg = NewFromOpenChannelFee(resv.Accept.OpenFee)
+		p.setPeerFeeConfig(resv.Channel.PeerNodeId, resv.Channel.FeeCfg)
 		if resv.NeedSendFundingTx {
 			resv.Channel.Capacity = amt - resv.Channel.FeeCfg.FeeToDAO()
 			resv.FundingUtxos, err = p.AllowOpen(feeRate, amt, outpoints, resv.Channel.FeeCfg)
@@ -347,7 +369,7 @@ func (p *Manager) FunderInitReOpenProcess(amt int64, fundingUtxo *TxOutput, memo
 		if err = p.funderProcessAcceptChannel(resv); err != nil {
 			break
 		}
-		if err = p.serverNode.client.SendFundingCreatedReq(resv); err != nil {
+		if err = peer.client.SendFundingCreatedReq(resv); err != nil {
 			break
 		}
 		if err = p.funderProcessFundingSigned(resv); err != nil {
@@ -390,8 +412,8 @@ func (p *Manager) FunderInitReOpenProcess(amt int64, fundingUtxo *TxOutput, memo
 
 	if err != nil {
 		p.DelResvWithId(resv.Id)
-		if resv.Id != 0 && p.serverNode != nil && p.serverNode.client != nil {
-			_ = p.serverNode.client.SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, -1, err.Error())
+		if resv.Id != 0 {
+			_ = peer.client.SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, -1, err.Error())
 		}
 		return "", err
 	}
@@ -20,6 +20,7 @@ type FundingInitOptions struct {
 	SkipOpeningAnchorTx bool
 	L2DrainTxId         string
 	InitialCapacity     int64
+	PeerNodeId          []byte // 为空时跟默认服务节点开通道
 }
 
 func (p *Manager) GetServerWalletAddress() string {
@@ -78,8 +79,9 @@ func (p *Manager) InitInitiatorFundingReservation(opts FundingInitOptions) (*Fun
 	if p.wallet == nil {
 		return nil, fmt.Errorf("wallet is not created/unlocked")
 	}
-	if p.serverNode == nil || p.serverNode.NodeId == nil || p.serverNode.Pubkey == nil {
-		return nil, fmt.Errorf("server node is not configured")
+	peer := p.getPeerNode(opts.PeerNodeId)
+	if peer == nil || peer.NodeId == nil || peer.Pubkey == nil {
+		return nil, fmt.Errorf("peer node is not configured")
 	}
 
 	resv := &FundingReservation{}
@@ -128,7 +130,8 @@ func (p *Manager) InitInitiatorFundingReservation(opts FundingInitOptions) (*Fun
 	paymentKey := resv.LocalWallet().GetPaymentPubKey()
 
 	resv.Channel = NewChannel(nil, p)
-	resv.Channel.PeerNodeId = p.serverNode.NodeId.SerializeCompressed()
+	resv.Channel.PeerNodeId = peer.NodeId.SerializeCompressed()
+	resv.Channel.PeerRPC = peer.RPCClient()
 	resv.Channel.IsInitiator = true
 	resv.Channel.LocalChanCfg.PaymentKey = paymentKey
 	resv.Channel.LocalChanCfg.RevocationBasePoint = revBaseKey
@@ -0,0 +1,683 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+	wwire "github.com/sat20-labs/sat20wallet/sdk/wire"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+const (
+	MAX_CHANNEL_HTLCS     = 16 // 一个commitment tx中最多的htlc输出
+	HTLC_MIN_EXPIRY_DELTA = 18 // 超时高度至少要比当前高度多出 CsvDelay + 该值，保证能在超时前上链领取
+)
+
+// 通道内的条件支付，目前只支持白聪。
+// 加入时金额从提供方余额中扣除，在commitment tx中单独作为一个输出；
+// 收款方用原像结算后金额归收款方，失败或超时后退回提供方。
+type ChannelHtlc struct {
+	Id          uint64
+	Incoming    bool // 从所属commitment的视角，是否是对端提供的htlc
+	Amount      int64
+	PaymentHash [32]byte
+	Expiry      uint32 // 一层绝对高度
+	DestNodeId  []byte
+	Memo        []byte
+	Preimage    []byte // 收款方结算前先保存，强制关闭时用来上链领取
+
+	// 在所属commitment tx中的输出位置和脚本，构造commitment时记录，sweep/punish时使用
+	OutputIndex   int
+	WitnessScript []byte
+}
+
+type ChannelHtlcInfo struct {
+	Id          uint64 `json:"id"`
+	Incoming    bool   `json:"incoming"`
+	Amount      int64  `json:"amount"`
+	PaymentHash string `json:"paymentHash"`
+	Expiry      uint32 `json:"expiry"`
+	DestNodeId  string `json:"destNodeId,omitempty"`
+	HasPreimage bool   `json:"hasPreimage"`
+}
+
+func (h *ChannelHtlc) Clone() *ChannelHtlc {
+	n := *h
+	n.DestNodeId = append([]byte(nil), h.DestNodeId...)
+	n.Memo = append([]byte(nil), h.Memo...)
+	n.Preimage = append([]byte(nil), h.Preimage...)
+	n.WitnessScript = append([]byte(nil), h.WitnessScript...)
+	return &n
+}
+
+// 对端视角的htlc，输出位置和脚本由对端的commitment决定
+func (h *ChannelHtlc) flip() *ChannelHtlc {
+	n := h.Clone()
+	n.Incoming = !h.Incoming
+	n.OutputIndex = 0
+	n.WitnessScript = nil
+	return n
+}
+
+func (h *ChannelHtlc) IsExpired(height int) bool {
+	return height >= 0 && uint32(height) >= h.Expiry
+}
+
+// 服务节点不结算也不失败htlc时，需要强制关闭通道上链处理：
+// 对端提供并且已经有原像的htlc，要留出CSV和上链的时间，在超时前领取；
+// 自己提供的htlc超时后对端还可以用原像领取，超时后马上取回
+func (h *ChannelHtlc) needForceClose(csvDelay uint16, height int) bool {
+	if h.Incoming {
+		if len(h.Preimage) == 0 {
+			return false
+		}
+		return int64(height) >= int64(h.Expiry)-int64(csvDelay)-HTLC_MIN_EXPIRY_DELTA
+	}
+	return h.IsExpired(height)
+}
+
+func (h *ChannelHtlc) Info() *ChannelHtlcInfo {
+	info := &ChannelHtlcInfo{
+		Id:          h.Id,
+		Incoming:    h.Incoming,
+		Amount:      h.Amount,
+		PaymentHash: hex.EncodeToString(h.PaymentHash[:]),
+		Expiry:      h.Expiry,
+		HasPreimage: len(h.Preimage) != 0,
+	}
+	if len(h.DestNodeId) != 0 {
+		info.DestNodeId = hex.EncodeToString(h.DestNodeId)
+	}
+	return info
+}
+
+func (p *ChannelInDB) GetCommitHtlcs() []*ChannelHtlc {
+	if p.LocalCommitment == nil {
+		return nil
+	}
+	result := make([]*ChannelHtlc, 0, len(p.LocalCommitment.Htlcs))
+	for _, h := range p.LocalCommitment.Htlcs {
+		result = append(result, h.Clone())
+	}
+	return result
+}
+
+// 有未结算的htlc时不能协商关闭和拼接，新的commitment中没有htlc输出
+func (p *ChannelInDB) checkNoPendingHtlcs() error {
+	if p.LocalCommitment != nil && len(p.LocalCommitment.Htlcs) != 0 {
+		return fmt.Errorf("channel %s has %d pending htlcs, settle or fail them first",
+			p.ChannelId, len(p.LocalCommitment.Htlcs))
+	}
+	return nil
+}
+
+func (p *ChannelInDB) getCommitHtlc(id uint64) *ChannelHtlc {
+	if p.LocalCommitment == nil {
+		return nil
+	}
+	for _, h := range p.LocalCommitment.Htlcs {
+		if h.Id == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// 跟余额一样，两个commitment同时更新，RemoteCommitment中保存对端视角的htlc
+func (p *ChannelInDB) SetCommitHtlcs(htlcs []*ChannelHtlc) {
+	var local, remote []*ChannelHtlc
+	for _, h := range htlcs {
+		local = append(local, h.Clone())
+		remote = append(remote, h.flip())
+	}
+	p.LocalCommitment.Htlcs = local
+	p.RemoteCommitment.Htlcs = remote
+}
+
+func (p *ChannelInDB) setHtlcPreimage(id uint64, preimage []byte) {
+	for _, commit := range []*ChannelCommitment{p.LocalCommitment, p.RemoteCommitment} {
+		for _, h := range commit.Htlcs {
+			if h.Id == id {
+				h.Preimage = append([]byte(nil), preimage...)
+			}
+		}
+	}
+}
+
+func CommitHtlcScript(channel *Channel, keyRing *CommitmentKeyRing, htlc *ChannelHtlc) (utils.ScriptDescriptor, error) {
+	// keyRing.ToLocalKey 属于commitment的所有者，htlc.Incoming 也是所有者视角
+	senderKey, receiverKey := keyRing.ToLocalKey, keyRing.ToRemoteKey
+	if htlc.Incoming {
+		senderKey, receiverKey = receiverKey, senderKey
+	}
+	witnessScript, err := utils.ChannelHTLCScript(uint32(channel.CsvDelay), htlc.Expiry,
+		senderKey, receiverKey, keyRing.RevocationKey, htlc.PaymentHash[:], !htlc.Incoming)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := utils.WitnessScriptHash(witnessScript)
+	if err != nil {
+		return nil, err
+	}
+	return &WitnessScriptDesc{
+		OutputScript:  pkScript,
+		WitnessScript: witnessScript,
+	}, nil
+}
+
+func AddCommitmentHtlcOutputs(commitTx *wire.MsgTx, channel *Channel, keyRing *CommitmentKeyRing,
+	htlcs []*ChannelHtlc, weightEstimate *utils.TxWeightEstimator) error {
+	for _, htlc := range htlcs {
+		if htlc.Amount < DUST_LIMIT {
+			return fmt.Errorf("htlc %d amount %d is too small", htlc.Id, htlc.Amount)
+		}
+		desc, err := CommitHtlcScript(channel, keyRing, htlc)
+		if err != nil {
+			return err
+		}
+		txOut := &wire.TxOut{PkScript: desc.PkScript(), Value: htlc.Amount}
+		htlc.OutputIndex = len(commitTx.TxOut)
+		htlc.WitnessScript = desc.WitnessScriptToSign()
+		commitTx.AddTxOut(txOut)
+		weightEstimate.AddTxOutput(txOut)
+	}
+	return nil
+}
+
+// 确认htlc记录的输出确实在commitTx中
+func findHtlcOutput(commitTx *wire.MsgTx, htlc *ChannelHtlc) (*wire.TxOut, bool) {
+	if commitTx == nil || len(htlc.WitnessScript) == 0 ||
+		htlc.OutputIndex < 0 || htlc.OutputIndex >= len(commitTx.TxOut) {
+		return nil, false
+	}
+	pkScript, err := utils.WitnessScriptHash(htlc.WitnessScript)
+	if err != nil {
+		return nil, false
+	}
+	txOut := commitTx.TxOut[htlc.OutputIndex]
+	if !bytes.Equal(txOut.PkScript, pkScript) {
+		return nil, false
+	}
+	return txOut, true
+}
+
+func commitPlainValue(value *Decimal, delta int64) *Decimal {
+	result := indexer.DecimalAdd(value, indexer.NewDefaultDecimal(delta))
+	if result == nil {
+		result = indexer.NewDefaultDecimal(0)
+	}
+	return result
+}
+
+// 按本地视角更新通道内的htlc和白聪余额，只修改内存中的状态
+func applyHtlcUpdate(channel *ChannelInDB, add *wwire.UpdateAddHtlc,
+	settle *wwire.UpdateSettleHtlc, fail *wwire.UpdateFailHtlc) error {
+	htlcs := channel.GetCommitHtlcs()
+	localValue := channel.GetCommitLocalValue(&PLAIN_ASSET)
+	remoteValue := channel.GetCommitRemoteValue(&PLAIN_ASSET)
+
+	switch {
+	case add != nil:
+		if add.Amount < DUST_LIMIT {
+			return fmt.Errorf("htlc amount %d is too small", add.Amount)
+		}
+		if len(add.PaymentHash) != 32 {
+			return fmt.Errorf("invalid payment hash")
+		}
+		if len(htlcs) >= MAX_CHANNEL_HTLCS {
+			return fmt.Errorf("too many pending htlcs")
+		}
+		for _, h := range htlcs {
+			if h.Id == add.HtlcId {
+				return fmt.Errorf("htlc %d already exists", add.HtlcId)
+			}
+			// 相同的脚本无法区分输出
+			if bytes.Equal(h.PaymentHash[:], add.PaymentHash) && h.Incoming == add.Incoming {
+				return fmt.Errorf("htlc with payment hash %x already exists", add.PaymentHash)
+			}
+		}
+		htlc := &ChannelHtlc{
+			Id:         add.HtlcId,
+			Incoming:   add.Incoming,
+			Amount:     add.Amount,
+			Expiry:     add.Expiry,
+			DestNodeId: add.DestNodeId,
+			Memo:       add.Memo,
+		}
+		copy(htlc.PaymentHash[:], add.PaymentHash)
+		if add.Incoming {
+			if remoteValue.Int64() < add.Amount {
+				return fmt.Errorf("no enough remote balance for htlc, require %d but %d", add.Amount, remoteValue.Int64())
+			}
+			remoteValue = commitPlainValue(remoteValue, -add.Amount)
+		} else {
+			if localValue.Int64() < add.Amount {
+				return fmt.Errorf("no enough local balance for htlc, require %d but %d", add.Amount, localValue.Int64())
+			}
+			localValue = commitPlainValue(localValue, -add.Amount)
+		}
+		htlcs = append(htlcs, htlc)
+
+	case settle != nil:
+		index := -1
+		for i, h := range htlcs {
+			if h.Id == settle.HtlcId {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("can't find htlc %d", settle.HtlcId)
+		}
+		htlc := htlcs[index]
+		hash := sha256.Sum256(settle.Preimage)
+		if len(settle.Preimage) != 32 || hash != htlc.PaymentHash {
+			return fmt.Errorf("preimage mismatch for htlc %d", htlc.Id)
+		}
+		if htlc.Incoming {
+			localValue = commitPlainValue(localValue, htlc.Amount)
+			channel.TotalSatReceived += htlc.Amount
+		} else {
+			remoteValue = commitPlainValue(remoteValue, htlc.Amount)
+			channel.TotalSatSent += htlc.Amount
+		}
+		htlcs = append(htlcs[:index], htlcs[index+1:]...)
+
+	case fail != nil:
+		index := -1
+		for i, h := range htlcs {
+			if h.Id == fail.HtlcId {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("can't find htlc %d", fail.HtlcId)
+		}
+		htlc := htlcs[index]
+		if htlc.Incoming {
+			remoteValue = commitPlainValue(remoteValue, htlc.Amount)
+		} else {
+			localValue = commitPlainValue(localValue, htlc.Amount)
+		}
+		htlcs = append(htlcs[:index], htlcs[index+1:]...)
+
+	default:
+		return fmt.Errorf("empty htlc update")
+	}
+
+	channel.SetCommitLocalValue(&PLAIN_ASSET, localValue)
+	channel.SetCommitRemoteValue(&PLAIN_ASSET, remoteValue)
+	channel.SetCommitHtlcs(htlcs)
+	return nil
+}
+
+// 服务节点确认的htlc必须跟请求一致，只允许服务节点分配id，以及补充incoming htlc的超时高度
+func checkHtlcAddResp(req, resp *wwire.UpdateAddHtlc, csvDelay uint16, height int) (*wwire.UpdateAddHtlc, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("peer didn't confirm the htlc")
+	}
+	if resp.Incoming != req.Incoming || !bytes.Equal(resp.PaymentHash, req.PaymentHash) {
+		return nil, fmt.Errorf("htlc confirmed by peer mismatch")
+	}
+	if req.Amount != 0 && resp.Amount != req.Amount {
+		return nil, fmt.Errorf("htlc amount mismatch, expected %d but %d", req.Amount, resp.Amount)
+	}
+	if !req.Incoming && resp.Expiry != req.Expiry {
+		return nil, fmt.Errorf("htlc expiry mismatch, expected %d but %d", req.Expiry, resp.Expiry)
+	}
+	if int64(resp.Expiry) < int64(height)+int64(csvDelay)+HTLC_MIN_EXPIRY_DELTA {
+		return nil, fmt.Errorf("htlc expiry %d is too close to current height %d", resp.Expiry, height)
+	}
+	return resp, nil
+}
+
+// 监控线程调用，有htlc快到期还没有处理时强制关闭通道
+func (p *Manager) checkHtlcDeadline() {
+	height := p.GetIndexerRPCClient().GetSyncHeight()
+	if height <= 0 {
+		return
+	}
+	for _, channel := range p.GetAllChannels() {
+		if channel == nil || channel.Status != CS_READY || channel.LocalCommitment == nil {
+			continue
+		}
+		channel.Mutex.RLock()
+		var expiring *ChannelHtlc
+		for _, h := range channel.LocalCommitment.Htlcs {
+			if h.needForceClose(channel.CsvDelay, height) {
+				expiring = h
+				break
+			}
+		}
+		channel.Mutex.RUnlock()
+		if expiring == nil {
+			continue
+		}
+
+		Log.Warnf("channel %s htlc %d (incoming %v, expiry %d) is not resolved by peer at height %d, force close",
+			channel.ChannelId, expiring.Id, expiring.Incoming, expiring.Expiry, height)
+		_, _, err := p.CloserForcelyClose(channel.ChannelId, 0)
+		if err != nil {
+			Log.Errorf("CloserForcelyClose %s failed. %v", channel.ChannelId, err)
+		}
+	}
+}
+
+func (p *Manager) GetChannelHtlcs(channelId string) ([]*ChannelHtlcInfo, error) {
+	channel := p.GetChannel(channelId)
+	if channel == nil {
+		return nil, fmt.Errorf("can't find channel %s", channelId)
+	}
+	channel.Mutex.RLock()
+	defer channel.Mutex.RUnlock()
+
+	result := make([]*ChannelHtlcInfo, 0)
+	for _, h := range channel.GetCommitHtlcs() {
+		result = append(result, h.Info())
+	}
+	return result, nil
+}
+
+// 提供一个htlc给服务节点，服务节点拿到原像后才能获得这笔钱
+func (p *Manager) AddChannelHtlc(channelId string, amt int64, paymentHash string, expiry int,
+	destNodeId string, memo []byte) (uint64, error) {
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return 0, fmt.Errorf("invalid payment hash %s", paymentHash)
+	}
+	var destNode []byte
+	if destNodeId != "" {
+		destNode, err = hex.DecodeString(destNodeId)
+		if err != nil {
+			return 0, fmt.Errorf("invalid dest node id %s", destNodeId)
+		}
+	}
+	if expiry <= 0 {
+		return 0, fmt.Errorf("invalid expiry %d", expiry)
+	}
+
+	add := &wwire.UpdateAddHtlc{
+		Amount:      amt,
+		PaymentHash: hash,
+		Expiry:      uint32(expiry),
+		DestNodeId:  destNode,
+		Memo:        memo,
+	}
+	result, err := p.updateChannelHtlc(channelId, &wwire.HtlcUpdateRequest{Add: add})
+	if err != nil {
+		return 0, err
+	}
+	return result.HtlcId, nil
+}
+
+// 收款方接受服务节点转过来的htlc，服务节点必须已经持有上游相同hash的htlc
+func (p *Manager) AcceptChannelHtlc(channelId string, paymentHash string, amt int64) (uint64, error) {
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return 0, fmt.Errorf("invalid payment hash %s", paymentHash)
+	}
+
+	add := &wwire.UpdateAddHtlc{
+		Incoming:    true,
+		Amount:      amt,
+		PaymentHash: hash,
+	}
+	result, err := p.updateChannelHtlc(channelId, &wwire.HtlcUpdateRequest{Add: add})
+	if err != nil {
+		return 0, err
+	}
+	return result.HtlcId, nil
+}
+
+func (p *Manager) SettleChannelHtlc(channelId string, htlcId uint64, preimage string) error {
+	image, err := hex.DecodeString(preimage)
+	if err != nil || len(image) != 32 {
+		return fmt.Errorf("invalid preimage")
+	}
+
+	channel := p.GetChannel(channelId)
+	if channel == nil {
+		return fmt.Errorf("can't find channel %s", channelId)
+	}
+	channel.Mutex.Lock()
+	htlc := channel.getCommitHtlc(htlcId)
+	if htlc == nil {
+		channel.Mutex.Unlock()
+		return fmt.Errorf("can't find htlc %d", htlcId)
+	}
+	if sha256.Sum256(image) != htlc.PaymentHash {
+		channel.Mutex.Unlock()
+		return fmt.Errorf("preimage mismatch for htlc %d", htlcId)
+	}
+	if htlc.Incoming && len(htlc.Preimage) == 0 {
+		// 先保存原像，即使服务节点不配合结算，也可以强制关闭后上链领取
+		channel.setHtlcPreimage(htlcId, image)
+		if err := p.SaveChannelToDB(channel); err != nil {
+			channel.Mutex.Unlock()
+			return err
+		}
+	}
+	channel.Mutex.Unlock()
+
+	settle := &wwire.UpdateSettleHtlc{
+		HtlcId:   htlcId,
+		Preimage: image,
+	}
+	_, err = p.updateChannelHtlc(channelId, &wwire.HtlcUpdateRequest{Settle: settle})
+	return err
+}
+
+func (p *Manager) FailChannelHtlc(channelId string, htlcId uint64, reason string) error {
+	fail := &wwire.UpdateFailHtlc{
+		HtlcId: htlcId,
+		Reason: reason,
+	}
+	_, err := p.updateChannelHtlc(channelId, &wwire.HtlcUpdateRequest{Fail: fail})
+	return err
+}
+
+func (p *Manager) updateChannelHtlc(channelId string, req *wwire.HtlcUpdateRequest) (*wwire.UpdateAddHtlc, error) {
+	if !p.IsReady() {
+		return nil, fmt.Errorf("not ready")
+	}
+	channel := p.GetChannel(channelId)
+	if channel == nil {
+		return nil, fmt.Errorf("can't find channel %s", channelId)
+	}
+	if channel.Status != CS_READY {
+		return nil, fmt.Errorf("channel %s is not ready", channelId)
+	}
+	if !channel.IsInitiator {
+		return nil, fmt.Errorf("can't perform this action from remote peer")
+	}
+	if !p.IsPeerOnline(channel.PeerNodeId) {
+		return nil, fmt.Errorf("peer is offline")
+	}
+
+	channel.Mutex.Lock()
+	defer channel.Mutex.Unlock()
+	if channel.Status != CS_READY {
+		return nil, fmt.Errorf("channel %s is not ready", channelId)
+	}
+	if channel.IsBusy() {
+		return nil, fmt.Errorf("%s %s", CHANNEL_IS_BUSY, channelId)
+	}
+	if channel.PeerRPC == nil {
+		return nil, fmt.Errorf("peer rpc client is not initialized")
+	}
+	if req.Settle != nil || req.Fail != nil {
+		id := uint64(0)
+		if req.Settle != nil {
+			id = req.Settle.HtlcId
+		} else {
+			id = req.Fail.HtlcId
+		}
+		if channel.getCommitHtlc(id) == nil {
+			return nil, fmt.Errorf("can't find htlc %d", id)
+		}
+	}
+
+	req.MsgHeader = wwire.NewMsgHeader()
+	req.ChannelId = channelId
+	req.CommitHeight = channel.CommitHeight
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	reqSig, err := channel.LocalWallet().SignMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	oldChannel, err := p.LoadChannel(channelId)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SaveBackupChannelToDB(&oldChannel.ChannelInDB); err != nil {
+		return nil, err
+	}
+
+	info := &RevocationInfo{
+		OldChannel: oldChannel,
+		Channel:    channel,
+		FeeRate:    p.GetFeeRate(),
+	}
+	var resvId int64
+	var add *wwire.UpdateAddHtlc
+	for {
+		var resp *wwire.HtlcUpdateResp
+		resp, err = channel.PeerRPC.SendChannelHtlcUpdateReq(&wwire.HtlcUpdateReq{
+			HtlcUpdateRequest: *req,
+			Sig:               reqSig,
+		})
+		if err != nil {
+			Log.Errorf("SendChannelHtlcUpdateReq %s failed. %v", channelId, err)
+			break
+		}
+		resvId = resp.Id
+		channel.ResvId = resp.Id
+		info.RemoteRevKey = resp.RevKey
+		info.RemoteNextRevKey = resp.NextRevKey
+		if resp.FeeRate != 0 {
+			info.FeeRate = resp.FeeRate
+		}
+		if channel.CommitHeight != req.CommitHeight {
+			err = fmt.Errorf("commit height changed. old %d current %d", req.CommitHeight, channel.CommitHeight)
+			break
+		}
+
+		if req.Add != nil {
+			add, err = checkHtlcAddResp(req.Add, resp.Add, channel.CsvDelay, p.GetSyncHeightL1())
+			if err != nil {
+				break
+			}
+		}
+		err = applyHtlcUpdate(&channel.ChannelInDB, add, req.Settle, req.Fail)
+		if err != nil {
+			Log.Errorf("applyHtlcUpdate %s failed. %v", channelId, err)
+			break
+		}
+
+		err = p.SignNextCommitment(info)
+		if err != nil {
+			Log.Errorf("SignNextCommitment %s failed. %v", channelId, err)
+			break
+		}
+		info.LocalRevKey, err = p.GetCurrRevocationKey(channel)
+		if err != nil {
+			break
+		}
+		info.LocalNextRevKey, err = p.GetNextRevocationKey(channel)
+		if err != nil {
+			break
+		}
+
+		commitReq := &wwire.HtlcCommitSigReq{
+			ChannelId:     channelId,
+			Id:            resvId,
+			CommitSigInfo: info.RemoteCommitInfo.CommitSigInfo,
+			RevKey:        info.LocalRevKey,
+			NextRevKey:    info.LocalNextRevKey,
+		}
+		commitReq.Sig, err = signRPCMessage(channel.LocalWallet(), commitReq)
+		if err != nil {
+			break
+		}
+		var commitResp *wwire.HtlcCommitSigResp
+		commitResp, err = channel.PeerRPC.SendChannelHtlcCommitSigReq(commitReq)
+		if err != nil {
+			Log.Errorf("SendChannelHtlcCommitSigReq %s failed. %v", channelId, err)
+			break
+		}
+		info.RemoteRev = commitResp.Rev
+		info.LocalCommitInfo.CommitSigInfo = commitResp.CommitSigInfo
+
+		err = p.ReceiveRevocation(info, info.RemoteRev)
+		if err != nil {
+			Log.Errorf("ReceiveRevocation %s failed. %v", channelId, err)
+			break
+		}
+		err = p.ReceiveNewCommitment(info, &info.LocalCommitInfo.CommitSigInfo, nil)
+		if err != nil {
+			Log.Errorf("ReceiveNewCommitment %s failed. %v", channelId, err)
+			break
+		}
+		info.LocalRev, err = p.RevokeCurrentCommitment(info)
+		if err != nil {
+			Log.Errorf("RevokeCurrentCommitment %s failed. %v", channelId, err)
+			break
+		}
+
+		revReq := &wwire.HtlcRevokeAndAckReq{
+			ChannelId: channelId,
+			Id:        resvId,
+			Rev:       info.LocalRev,
+		}
+		revReq.Sig, err = signRPCMessage(channel.LocalWallet(), revReq)
+		if err != nil {
+			break
+		}
+		_, err = channel.PeerRPC.SendChannelHtlcRevokeAndAckReq(revReq)
+		if err != nil {
+			Log.Errorf("SendChannelHtlcRevokeAndAckReq %s failed. %v", channelId, err)
+			break
+		}
+		err = p.TestAcceptance_SatsNet([]*swire.MsgTx{channel.LocalCommitment.DeAnchorTx})
+		if err != nil {
+			Log.Errorf("TestAcceptance_SatsNet %s failed. %v", channelId, err)
+			break
+		}
+
+		channel.CommitHeight += 1
+		channel.UpdateTime = resvId
+		p.saveChannelToDB(channel)
+		break
+	}
+
+	channel.ResvId = 0
+	if err != nil {
+		if info.SignedPunishTx != nil && oldChannel.RemoteCommitment != nil &&
+			oldChannel.RemoteCommitment.CommitTx != nil {
+			p.GetWatchTower().RemoveCommitTx(oldChannel, oldChannel.RemoteCommitment.CommitTx.TxID())
+		}
+		p.saveChannelToDB(oldChannel)
+		p.enableChannel(oldChannel)
+		if resvId != 0 {
+			_ = channel.PeerRPC.SendActionResultNfty(resvId, RESV_TYPE_HTLC, -1, err.Error())
+		}
+		return nil, err
+	}
+
+	p.SendMessageToUpper(MSG_UTXO_UNLOCKED_LOCKED, channelId)
+	if add == nil {
+		return &wwire.UpdateAddHtlc{}, nil
+	}
+	return add, nil
+}
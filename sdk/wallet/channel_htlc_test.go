@@ -0,0 +1,184 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+	wwire "github.com/sat20-labs/sat20wallet/sdk/wire"
+)
+
+func newHtlcTestChannel(local, remote int64) *ChannelInDB {
+	channel := &ChannelInDB{
+		LocalCommitment:  NewChannelCommitment(),
+		RemoteCommitment: NewChannelCommitment(),
+	}
+	channel.SetCommitLocalValue(&PLAIN_ASSET, indexer.NewDefaultDecimal(local))
+	channel.SetCommitRemoteValue(&PLAIN_ASSET, indexer.NewDefaultDecimal(remote))
+	return channel
+}
+
+func TestApplyHtlcUpdate(t *testing.T) {
+	preimage := make([]byte, 32)
+	preimage[0] = 1
+	hash := sha256.Sum256(preimage)
+
+	channel := newHtlcTestChannel(10000, 5000)
+	add := &wwire.UpdateAddHtlc{HtlcId: 1, Amount: 3000, PaymentHash: hash[:], Expiry: 1000}
+	if err := applyHtlcUpdate(channel, add, nil, nil); err != nil {
+		t.Fatalf("add htlc failed, %v", err)
+	}
+	if channel.GetCommitLocalValue(&PLAIN_ASSET).Int64() != 7000 {
+		t.Fatalf("local balance %d", channel.GetCommitLocalValue(&PLAIN_ASSET).Int64())
+	}
+	if len(channel.RemoteCommitment.Htlcs) != 1 || !channel.RemoteCommitment.Htlcs[0].Incoming {
+		t.Fatalf("remote commitment should see an incoming htlc")
+	}
+	if err := applyHtlcUpdate(channel, add, nil, nil); err == nil {
+		t.Fatalf("duplicated htlc should fail")
+	}
+	if err := channel.checkNoPendingHtlcs(); err == nil {
+		t.Fatalf("channel with pending htlc can't be closed or spliced")
+	}
+
+	settle := &wwire.UpdateSettleHtlc{HtlcId: 1, Preimage: make([]byte, 32)}
+	if err := applyHtlcUpdate(channel, nil, settle, nil); err == nil {
+		t.Fatalf("wrong preimage should fail")
+	}
+	settle.Preimage = preimage
+	if err := applyHtlcUpdate(channel, nil, settle, nil); err != nil {
+		t.Fatalf("settle htlc failed, %v", err)
+	}
+	if channel.GetCommitRemoteValue(&PLAIN_ASSET).Int64() != 8000 || channel.TotalSatSent != 3000 {
+		t.Fatalf("remote balance %d, sent %d", channel.GetCommitRemoteValue(&PLAIN_ASSET).Int64(), channel.TotalSatSent)
+	}
+	if len(channel.GetCommitHtlcs()) != 0 {
+		t.Fatalf("htlc should be removed")
+	}
+	if err := channel.checkNoPendingHtlcs(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 对端提供的htlc失败后退回对端
+	add = &wwire.UpdateAddHtlc{HtlcId: 2, Incoming: true, Amount: 2000, PaymentHash: hash[:], Expiry: 1000}
+	if err := applyHtlcUpdate(channel, add, nil, nil); err != nil {
+		t.Fatalf("add htlc failed, %v", err)
+	}
+	if err := applyHtlcUpdate(channel, nil, nil, &wwire.UpdateFailHtlc{HtlcId: 2}); err != nil {
+		t.Fatalf("fail htlc failed, %v", err)
+	}
+	if channel.GetCommitRemoteValue(&PLAIN_ASSET).Int64() != 8000 {
+		t.Fatalf("remote balance %d", channel.GetCommitRemoteValue(&PLAIN_ASSET).Int64())
+	}
+
+	add = &wwire.UpdateAddHtlc{HtlcId: 3, Amount: 20000, PaymentHash: hash[:], Expiry: 1000}
+	if err := applyHtlcUpdate(channel, add, nil, nil); err == nil {
+		t.Fatalf("htlc over balance should fail")
+	}
+}
+
+func TestCheckHtlcAddResp(t *testing.T) {
+	hash := sha256.Sum256([]byte("htlc"))
+	req := &wwire.UpdateAddHtlc{Incoming: true, Amount: 1000, PaymentHash: hash[:]}
+	resp := &wwire.UpdateAddHtlc{HtlcId: 5, Incoming: true, Amount: 1000, PaymentHash: hash[:], Expiry: 200}
+	if _, err := checkHtlcAddResp(req, resp, 144, 100); err == nil {
+		t.Fatalf("expiry too close should fail")
+	}
+	resp.Expiry = 300
+	if _, err := checkHtlcAddResp(req, resp, 144, 100); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	resp.Amount = 900
+	if _, err := checkHtlcAddResp(req, resp, 144, 100); err == nil {
+		t.Fatalf("amount mismatch should fail")
+	}
+}
+
+func TestHtlcNeedForceClose(t *testing.T) {
+	incoming := &ChannelHtlc{Incoming: true, Expiry: 1000}
+	// 没有原像，等对端超时失败
+	if incoming.needForceClose(144, 999) {
+		t.Fatalf("incoming htlc without preimage should not force close")
+	}
+	incoming.Preimage = []byte{1}
+	deadline := 1000 - 144 - HTLC_MIN_EXPIRY_DELTA
+	if incoming.needForceClose(144, deadline-1) {
+		t.Fatalf("incoming htlc should not force close before %d", deadline)
+	}
+	if !incoming.needForceClose(144, deadline) {
+		t.Fatalf("incoming htlc should force close at %d", deadline)
+	}
+
+	outgoing := &ChannelHtlc{Expiry: 1000}
+	if outgoing.needForceClose(144, 999) {
+		t.Fatalf("outgoing htlc should not force close before expiry")
+	}
+	if !outgoing.needForceClose(144, 1000) {
+		t.Fatalf("expired outgoing htlc should force close")
+	}
+}
+
+func TestChannelHTLCScriptPaths(t *testing.T) {
+	senderKey, _ := btcec.NewPrivateKey()
+	receiverKey, _ := btcec.NewPrivateKey()
+	revokeKey, _ := btcec.NewPrivateKey()
+	preimage := make([]byte, 32)
+	preimage[31] = 7
+	hash := sha256.Sum256(preimage)
+
+	const csvDelay, expiry = 6, 500
+	// commitment 属于发送方，超时分支需要等 CSV
+	script, err := utils.ChannelHTLCScript(csvDelay, expiry, senderKey.PubKey(), receiverKey.PubKey(),
+		revokeKey.PubKey(), hash[:], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script) > utils.ChannelHtlcScriptSize {
+		t.Fatalf("script size %d", len(script))
+	}
+	pkScript, _ := utils.WitnessScriptHash(script)
+	prevOut := wire.NewTxOut(10000, pkScript)
+
+	run := func(key *btcec.PrivateKey, sequence, lockTime uint32, witness func(sig []byte) wire.TxWitness) error {
+		tx := wire.NewMsgTx(2)
+		tx.LockTime = lockTime
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 0}, Sequence: sequence})
+		tx.AddTxOut(wire.NewTxOut(9000, []byte{txscript.OP_1}))
+		fetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+		sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+		sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, 0, prevOut.Value, script, txscript.SigHashAll, key)
+		if err != nil {
+			return err
+		}
+		tx.TxIn[0].Witness = witness(sig)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, 0, txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, fetcher)
+		if err != nil {
+			return err
+		}
+		return vm.Execute()
+	}
+
+	revoke := func(sig []byte) wire.TxWitness { return wire.TxWitness{sig, {1}, script} }
+	success := func(sig []byte) wire.TxWitness { return wire.TxWitness{sig, preimage, {1}, {}, script} }
+	timeout := func(sig []byte) wire.TxWitness { return wire.TxWitness{sig, {}, {}, script} }
+
+	if err := run(revokeKey, wire.MaxTxInSequenceNum, 0, revoke); err != nil {
+		t.Fatalf("revoke path failed, %v", err)
+	}
+	if err := run(receiverKey, wire.MaxTxInSequenceNum-1, 0, success); err != nil {
+		t.Fatalf("success path failed, %v", err)
+	}
+	if err := run(senderKey, csvDelay, expiry, timeout); err != nil {
+		t.Fatalf("timeout path failed, %v", err)
+	}
+	if err := run(senderKey, csvDelay, expiry-1, timeout); err == nil {
+		t.Fatalf("timeout path before expiry should fail")
+	}
+	if err := run(senderKey, wire.MaxTxInSequenceNum-1, expiry, timeout); err == nil {
+		t.Fatalf("timeout path without csv should fail")
+	}
+}
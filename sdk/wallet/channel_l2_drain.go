@@ -277,7 +277,11 @@ func (p *Manager) DrainChannelL2BeforeReopen(channelAddr string) (string, []stri
 	}
 
 	localWallet := p.wallet
-	peerPubKey := p.GetServerNode().Pubkey.SerializeCompressed()
+	peer, err := p.getPeerNodeWithChannelAddress(localWallet, channelAddr)
+	if err != nil {
+		return "", nil, err
+	}
+	peerPubKey := peer.Pubkey.SerializeCompressed()
 	localKey := localWallet.GetPaymentPubKey().SerializeCompressed()
 	witness, pkScript, err := GetP2WSHscript(localKey, peerPubKey)
 	if err != nil {
@@ -331,7 +335,7 @@ func (p *Manager) DrainChannelL2BeforeReopen(channelAddr string) (string, []stri
 	if err != nil {
 		return "", nil, err
 	}
-	peerSig, err := p.serverNode.client.SendSigReq(&req, msgSig)
+	peerSig, err := peer.client.SendSigReq(&req, msgSig)
 	if err != nil {
 		return "", nil, fmt.Errorf("SendSigReq failed: %v", err)
 	}
@@ -87,12 +87,14 @@ func (p *Manager) HasChannel() bool {
 	return len(p.channelMap) != 0 || len(p.fundingChannelMap) != 0
 }
 
+// 跟默认服务节点的通道优先，没有时返回跟其他节点的通道
 func (p *Manager) GetActiveChannel() *Channel {
-	channelId, err := p.GetChannelAddress()
-	if err != nil {
-		return nil
+	for _, channelId := range p.getChannelAddresses() {
+		if c := p.GetActiveChannelWithId(channelId); c != nil {
+			return c
+		}
 	}
-	return p.GetActiveChannelWithId(channelId)
+	return nil
 }
 
 func (p *Manager) GetActiveChannelWithId(channelId string) *Channel {
@@ -115,23 +117,24 @@ func (p *Manager) GetActiveChannelWithId(channelId string) *Channel {
 	return nil
 }
 
+// 包括正在开通的通道，跟默认服务节点的通道优先
 func (p *Manager) GetCurrentChannel() *Channel {
 	if p.wallet == nil {
 		return nil
 	}
 
-	channelId, err := p.GetChannelAddress()
-	if err != nil {
-		return nil
-	}
-	c := p.GetActiveChannelWithId(channelId)
-	if c != nil {
-		return c
+	channelIds := p.getChannelAddresses()
+	for _, channelId := range channelIds {
+		if c := p.GetActiveChannelWithId(channelId); c != nil {
+			return c
+		}
 	}
 
-	for _, c := range p.GetFundingReservations() {
-		if c.ChannelId == channelId {
-			return c.Channel
+	for _, channelId := range channelIds {
+		for _, c := range p.GetFundingReservations() {
+			if c.ChannelId == channelId {
+				return c.Channel
+			}
 		}
 	}
 	return nil
@@ -177,11 +180,14 @@ func (p *Manager) GetAllChannels() map[string]*Channel {
 	return result
 }
 
+// 没有 PeerNodeId 的旧通道属于默认服务节点；对端未知时返回nil，不能发给其他节点
 func (p *Manager) GetPeerNodeClient(channel *ChannelInDB) NodeRPCClient {
-	if p.serverNode == nil {
+	node := p.getPeerNode(channel.PeerNodeId)
+	if node == nil {
+		Log.Errorf("unknown peer node %x", channel.PeerNodeId)
 		return nil
 	}
-	return p.serverNode.client
+	return node.RPCClient()
 }
 
 func (p *Manager) saveChannelToDB(c *Channel) error {
@@ -201,6 +207,8 @@ func (p *Manager) SaveChannelToDB(c *Channel) error {
 	if err := p.SaveChannelInDB(&c.ChannelInDB); err != nil {
 		return err
 	}
+	p.labelChannel(c)
+	p.updateStaticChannelBackup(c)
 	if p.channelBackupHandler == nil {
 		return nil
 	}
@@ -43,6 +43,7 @@ func (p *Manager) enableUtxosInChannel(channelId, txId string, needLock bool) {
 func (p *Manager) BroadcastTxsIrreversibleL1(txs []*wire.MsgTx, action string) (bool, error) {
 	err := p.BroadcastTxs(txs)
 	if err == nil {
+		p.annotateL1Txs(txs, action)
 		return true, nil
 	}
 	if !isBroadcastResultUnknown(err) {
@@ -51,6 +52,12 @@ func (p *Manager) BroadcastTxsIrreversibleL1(txs []*wire.MsgTx, action string) (
 	if p.areL1TxsVisible(txs) {
 		Log.Warnf("%s L1 broadcast returned an unknown network error, but txs are visible. %v", action, err)
 		p.lockL1Txs(txs)
+		for _, tx := range txs {
+			if tx != nil {
+				p.onTxBroadcasted(tx)
+			}
+		}
+		p.annotateL1Txs(txs, action)
 		return true, nil
 	}
 	Log.Warnf("%s L1 broadcast result is unknown and txs are not visible yet. Keep pending for retry. %v", action, err)
@@ -58,6 +65,14 @@ func (p *Manager) BroadcastTxsIrreversibleL1(txs []*wire.MsgTx, action string) (
 	return false, nil
 }
 
+func (p *Manager) annotateL1Txs(txs []*wire.MsgTx, action string) {
+	for _, tx := range txs {
+		if tx != nil {
+			p.annotateActivity(false, tx.TxID(), "", action, "")
+		}
+	}
+}
+
 func (p *Manager) lockL1Txs(txs []*wire.MsgTx) {
 	for _, tx := range txs {
 		if tx == nil {
@@ -209,6 +224,9 @@ func (p *Manager) HandleChannelReservationStatus(sendTxInL1 bool) {
 	now := time.Now().UnixMicro()
 
 	if sendTxInL1 {
+		p.checkRemoteCommitBroadcasted()
+		p.checkHtlcDeadline()
+
 		for _, resv := range p.GetFundingReservations() {
 			if resv == nil || resv.Channel == nil {
 				continue
@@ -287,7 +305,7 @@ func (p *Manager) HandleChannelReservationStatus(sendTxInL1 bool) {
 				if p.GetIndexerRPCClient().IsTxConfirmed(txId) {
 					Log.Infof("L1 Tx confirmed: %s", txId)
 					_ = p.HandleChannelForceCloseConfirmed(resv)
-				} else if resv.Channel.LocalCommitment.CommitTx != nil {
+				} else if !resv.RemoteCommit && resv.Channel.LocalCommitment.CommitTx != nil {
 					txs := append([]*wire.MsgTx{}, resv.Channel.LocalCommitment.PrevTxs...)
 					txs = append(txs, resv.Channel.LocalCommitment.CommitTx)
 					txs = append(txs, resv.Channel.LocalCommitment.NextTxs...)
@@ -452,8 +470,8 @@ func (p *Manager) HandleChannelReady(resv *FundingReservation) error {
 	}
 	p.EnableChannel(channel)
 	p.SendMessageToUpper(MSG_CHANNEL_OPENED, channel.ChannelId)
-	if resv.IsInitiator && p.serverNode != nil && p.serverNode.RPCClient() != nil {
-		_ = p.serverNode.RPCClient().SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, 0, "")
+	if client := p.GetPeerNodeClient(&channel.ChannelInDB); resv.IsInitiator && client != nil {
+		_ = client.SendActionResultNfty(resv.Id, RESV_TYPE_OPEN, 0, "")
 	}
 	resv.Status = RS_CLOSED
 	if err := p.SaveWalletReservation(resv); err != nil {
@@ -489,6 +507,7 @@ func (p *Manager) HandlePaymentFinished(resv *PaymentReservation) error {
 	if err := p.SaveWalletReservation(resv); err != nil {
 		return err
 	}
+	p.recordChannelPayment(resv)
 	p.SendMessageToUpper(MSG_UTXO_UNLOCKED_LOCKED, resv.ChannelId)
 	if resv.IsInitiator && resv.Channel != nil && resv.Channel.PeerRPC != nil {
 		_ = resv.Channel.PeerRPC.SendActionResultNfty(resv.Id, RESV_TYPE_PAYMENT, 0, "")
@@ -20,38 +20,9 @@ const (
 	SPLICING_REASON_CONTRACT string = "contract"
 )
 
+// 跟默认服务节点开通道
 func (p *Manager) OpenChannel(feeRate int64, amt int64, utxos []string, memo string) (string, error) {
-	start := time.Now()
-	Log.Infof("OpenChannel %d", amt)
-	if p.wallet == nil {
-		return "", fmt.Errorf("wallet is not created/unlocked")
-	}
-	if !p.IsReady() {
-		return "", fmt.Errorf("not ready")
-	}
-	if !p.CheckSuperNodeStatus() {
-		return "", fmt.Errorf("peer is offline")
-	}
-	if p.ServerIsBootstrapNode() {
-		pubkey := p.wallet.GetPaymentPubKey().SerializeCompressed()
-		if !p.HasStaked(pubkey) {
-			addr, _ := indexer.GetCoreNodeChannelAddress(pubkey, GetChainParam())
-			return "", fmt.Errorf("channel address %s has no stake assets", addr)
-		}
-	}
-
-	channelAddr, err := p.GetChannelAddress()
-	if err != nil {
-		return "", err
-	}
-	l2DrainTxId, err := p.DrainChannelL2BeforeOpenIfNeeded(channelAddr)
-	if err != nil {
-		return "", err
-	}
-
-	result, err := p.FunderInitFundingProcess(feeRate, amt, utxos, memo, l2DrainTxId)
-	Log.Infof("OpenChannel finished: %s, %v", result, time.Since(start))
-	return result, err
+	return p.OpenChannelWithPeer("", feeRate, amt, utxos, memo)
 }
 
 func (p *Manager) CloseChannel(channelId string, feeRate int64, force bool) (string, string, error) {
@@ -234,43 +205,58 @@ func (p *Manager) LockToChannel(channelId string, assetName string, amt string,
 		return "", 0, fmt.Errorf("channel %s is not ready", channelId)
 	}
 
+	resv, utxos, fees, err := p.newLockReservation(channel, assetName, amt, utxos, fees, memo)
+	if err != nil {
+		return "", 0, err
+	}
+	resv.InitRuntime()
+
+	txId, err := p.InitLockProcess(resv, utxos, fees)
+	Log.Infof("LockToChannel %s finished, %v", txId, time.Since(start))
+	return txId, resv.Id, err
+}
+
+// 构造锁定资产到通道的 reservation，没有指定utxo时自动选择，不修改通道的数据
+func (p *Manager) newLockReservation(channel *Channel, assetName string, amt string,
+	utxos, fees []string, memo []byte) (*PaymentReservation, []string, []string, error) {
+
 	asset := ParseAssetString(assetName)
 	if asset == nil {
-		return "", 0, fmt.Errorf("invalid asset name %s", assetName)
+		return nil, nil, nil, fmt.Errorf("invalid asset name %s", assetName)
 	}
 	if err := rejectRGB11STPAsset(asset); err != nil {
-		return "", 0, err
+		return nil, nil, nil, err
 	}
 	tickerInfo := p.getTickerInfo(asset)
 	if tickerInfo == nil {
-		return "", 0, fmt.Errorf("can't get ticker %s info", assetName)
+		return nil, nil, nil, fmt.Errorf("can't get ticker %s info", assetName)
 	}
 	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
 	if err != nil {
-		return "", 0, err
+		return nil, nil, nil, err
 	}
 	if dAmt.Sign() < 0 {
-		return "", 0, fmt.Errorf("invalid amt")
+		return nil, nil, nil, fmt.Errorf("invalid amt")
 	}
 
-	oldChannel, err := p.LoadChannel(channelId)
+	oldChannel, err := p.LoadChannel(channel.ChannelId)
 	if err != nil {
-		return "", 0, err
+		return nil, nil, nil, err
 	}
 	if len(utxos) == 0 && len(fees) == 0 {
 		utxos, fees, err = p.GetUtxosWithAssetV2_SatsNet("", DEFAULT_FEE_SATSNET, dAmt, asset, nil)
 		if err != nil {
-			return "", -1, err
+			return nil, nil, nil, err
 		}
 	} else if len(utxos) == 0 {
 		utxos, err = p.GetUtxosWithAsset_SatsNet("", dAmt, asset, nil)
 		if err != nil {
-			return "", -1, err
+			return nil, nil, nil, err
 		}
 	} else if len(fees) == 0 {
 		fees, err = p.GetUtxosForFee_SatsNet("", DEFAULT_FEE_SATSNET, nil)
 		if err != nil {
-			return "", -1, err
+			return nil, nil, nil, err
 		}
 	}
 
@@ -278,7 +264,7 @@ func (p *Manager) LockToChannel(channelId string, assetName string, amt string,
 	if channel.HasProtocolAsset(indexer.PROTOCOL_NAME_BRC20) {
 		priv, err := btcec.NewPrivateKey()
 		if err != nil {
-			return "", -1, err
+			return nil, nil, nil, err
 		}
 		revealPrivKey = priv.Serialize()
 	}
@@ -301,11 +287,7 @@ func (p *Manager) LockToChannel(channelId string, assetName string, amt string,
 		},
 		TickerInfo: tickerInfo,
 	}
-	resv.InitRuntime()
-
-	txId, err := p.InitLockProcess(resv, utxos, fees)
-	Log.Infof("LockToChannel %s finished, %v", txId, time.Since(start))
-	return txId, resv.Id, err
+	return resv, utxos, fees, nil
 }
 
 func (p *Manager) LockToChannelWithExpand(channelId string, assetName string, amt string, feeRate int64) (string, int64, error) {
@@ -368,6 +350,9 @@ func (p *Manager) SplicingOut(channelId string, destAddr string,
 
 	channel.Mutex.Lock()
 	defer channel.Mutex.Unlock()
+	if err := channel.checkNoPendingHtlcs(); err != nil {
+		return "", 0, err
+	}
 
 	asset := ParseAssetString(assetName)
 	if asset == nil {
@@ -388,8 +373,9 @@ func (p *Manager) SplicingOut(channelId string, destAddr string,
 	if dAmt.Sign() < 0 {
 		return "", 0, fmt.Errorf("invalid amt")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	var address string
@@ -467,6 +453,9 @@ func (p *Manager) SplicingIn(channelId string, assetName string, amt string,
 
 	channel.Mutex.Lock()
 	defer channel.Mutex.Unlock()
+	if err := channel.checkNoPendingHtlcs(); err != nil {
+		return "", 0, err
+	}
 
 	asset := ParseAssetString(assetName)
 	if asset == nil {
@@ -486,8 +475,9 @@ func (p *Manager) SplicingIn(channelId string, assetName string, amt string,
 	if dAmt.Sign() <= 0 {
 		return "", 0, fmt.Errorf("invalid amt")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	var fundingAddress string
@@ -801,26 +791,34 @@ func (p *Manager) ExpandAsset(channelId string, assetName string) ([]string, str
 }
 
 func (p *Manager) ReopenChannel(expandAll bool) (string, error) {
+	return p.ReopenChannelWithPeer("", expandAll)
+}
+
+func (p *Manager) ReopenChannelWithPeer(nodeId string, expandAll bool) (string, error) {
 	start := time.Now()
-	Log.Infof("ReopenChannel")
+	Log.Infof("ReopenChannel %s", nodeId)
 	if p.wallet == nil {
 		return "", fmt.Errorf("wallet is not created/unlocked")
 	}
 	if !p.IsReady() {
 		return "", fmt.Errorf("not ready")
 	}
-	if !p.CheckSuperNodeStatus() {
-		return "", fmt.Errorf("peer is offline")
+	peer, err := p.getPeerNodeWithHex(nodeId)
+	if err != nil {
+		return "", err
 	}
-	if channel := p.GetActiveChannel(); channel != nil {
-		return "", fmt.Errorf("channel %s exists", channel.ChannelId)
+	if !p.isNodeOnline(peer, true) {
+		return "", fmt.Errorf("peer is offline")
 	}
 
-	address, err := p.GetChannelAddress()
+	address, err := p.getChannelAddressWithNode(peer)
 	if err != nil {
 		Log.Errorf("GetChannelAddress failed. %v", err)
 		return "", err
 	}
+	if channel := p.GetActiveChannelWithId(address); channel != nil {
+		return "", fmt.Errorf("channel %s exists", channel.ChannelId)
+	}
 	if p.HasContractInChannel(address) {
 		return "", fmt.Errorf("contract exists, not allow to reopen channel")
 	}
@@ -845,7 +843,7 @@ func (p *Manager) ReopenChannel(expandAll bool) (string, error) {
 	}
 	fundingFeeCfg := feeCfg
 	if !hasLedgerHistory {
-		fundingFeeCfg = NewFeeConfig()
+		fundingFeeCfg = p.GetPeerFeeConfig(nodeKey(peer))
 	}
 	minFundingValue := feeCfg.MinCapacity()
 
@@ -870,7 +868,7 @@ func (p *Manager) ReopenChannel(expandAll bool) (string, error) {
 		fundingOutput = OutputInfoToOutput(output)
 	}
 
-	channelId, err := p.FunderInitReOpenProcess(fundingAmount, fundingOutput, "reopen", needSendFundingTx, false, fundingFeeCfg, l2DrainTxId)
+	channelId, err := p.funderInitReOpenProcess(peer, fundingAmount, fundingOutput, "reopen", needSendFundingTx, false, fundingFeeCfg, l2DrainTxId)
 	if err != nil {
 		Log.Errorf("funderInitReOpenProcess failed, %v", err)
 		return "", err
@@ -0,0 +1,546 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+const PEER_STATUS_REFRESH_INTERVAL = 3 * 60 // 秒
+
+type peerStatus struct {
+	online      bool
+	refreshTime int64
+	feeCfg      *ChannelFeeConfig // 最近一次开通道时对端给出的费用设置
+}
+
+type PeerInfo struct {
+	NodeId         string            `json:"nodeId"`
+	Host           string            `json:"host"`
+	NodeType       string            `json:"nodeType"`
+	IsDefault      bool              `json:"isDefault"`
+	Online         bool              `json:"online"`
+	ChannelAddress string            `json:"channelAddress"`
+	FeeCfg         *ChannelFeeConfig `json:"feeCfg"`
+}
+
+type ChannelSummary struct {
+	ChannelId     string          `json:"channelId"`
+	Address       string          `json:"address"`
+	Status        int             `json:"status"`
+	Capacity      int64           `json:"capacity"`
+	PeerNodeId    string          `json:"peerNodeId"`
+	PeerHost      string          `json:"peerHost"`
+	PeerOnline    bool            `json:"peerOnline"`
+	IsDefaultPeer bool            `json:"isDefaultPeer"`
+	PendingHtlcs  int             `json:"pendingHtlcs"`
+	LocalBalance  []*DisplayAsset `json:"localBalance"`
+	RemoteBalance []*DisplayAsset `json:"remoteBalance"`
+}
+
+func nodeKey(node *Node) string {
+	if node == nil || node.NodeId == nil {
+		return ""
+	}
+	return hex.EncodeToString(node.NodeId.SerializeCompressed())
+}
+
+func (p *Manager) isDefaultPeer(node *Node) bool {
+	return node != nil && p.serverNode != nil && nodeKey(node) == nodeKey(p.serverNode)
+}
+
+// 通过 AddPeerNode 和 DiscoverPeerNodes 加入的节点保存在数据库中，重启后恢复
+type PeerNodeInDB struct {
+	NodeId  string
+	Url     string
+	AddTime int64
+}
+
+func GetPeerNodeDBKey(nodeId string) string {
+	return GetDBKeyPrefix() + DB_KEY_PEER_NODE + nodeId
+}
+
+func savePeerNode(kvdb indexer.KVDB, peer *PeerNodeInDB) error {
+	buf, err := EncodeToBytes(peer)
+	if err != nil {
+		Log.Errorf("savePeerNode EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetPeerNodeDBKey(peer.NodeId)), buf)
+}
+
+func deletePeerNode(kvdb indexer.KVDB, nodeId string) error {
+	return kvdb.Delete([]byte(GetPeerNodeDBKey(nodeId)))
+}
+
+func loadAllPeerNodes(kvdb indexer.KVDB) []*PeerNodeInDB {
+	result := make([]*PeerNodeInDB, 0)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_PEER_NODE)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var peer PeerNodeInDB
+		err := DecodeFromBytes(v, &peer)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result = append(result, &peer)
+		return nil
+	})
+	return result
+}
+
+func (p *Manager) addPeerNode(node *Node) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.peerNodes == nil {
+		p.peerNodes = make(map[string]*Node)
+	}
+	p.peerNodes[nodeKey(node)] = node
+}
+
+// 加入并保存到数据库
+func (p *Manager) addAndSavePeerNode(node *Node, url string) error {
+	if p.db != nil {
+		err := savePeerNode(p.db, &PeerNodeInDB{
+			NodeId:  nodeKey(node),
+			Url:     url,
+			AddTime: time.Now().Unix(),
+		})
+		if err != nil {
+			Log.Errorf("savePeerNode %s failed. %v", nodeKey(node), err)
+			return err
+		}
+	}
+	p.addPeerNode(node)
+	return nil
+}
+
+// 启动时恢复保存的节点，配置文件中的节点优先
+func (p *Manager) loadPeerNodes() {
+	if p.db == nil {
+		return
+	}
+	for _, peer := range loadAllPeerNodes(p.db) {
+		pubkey, err := utils.ParsePubkey(peer.NodeId)
+		if err != nil {
+			Log.Errorf("invalid peer node id %s in db", peer.NodeId)
+			continue
+		}
+		if p.getPeerNode(pubkey.SerializeCompressed()) != nil {
+			continue
+		}
+		scheme, host, proxy := parseNodeUrl(peer.Url)
+		p.addPeerNode(NewNode(NewNodeClient(scheme, host, proxy, p.http), host, SERVER_NODE, pubkey, pubkey))
+	}
+}
+
+// 返回保存的节点地址，通道备份中使用
+func (p *Manager) getPeerNodeUrl(nodeId string) string {
+	if p.db == nil {
+		return ""
+	}
+	buf, err := p.db.Read([]byte(GetPeerNodeDBKey(nodeId)))
+	if err != nil {
+		return ""
+	}
+	var peer PeerNodeInDB
+	if err := DecodeFromBytes(buf, &peer); err != nil {
+		return ""
+	}
+	return peer.Url
+}
+
+// nodeId 为空时返回默认服务节点
+func (p *Manager) getPeerNode(nodeId []byte) *Node {
+	if len(nodeId) == 0 {
+		return p.serverNode
+	}
+	key := hex.EncodeToString(nodeId)
+	if key == nodeKey(p.serverNode) {
+		return p.serverNode
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.peerNodes[key]
+}
+
+func (p *Manager) getPeerNodeWithHex(nodeId string) (*Node, error) {
+	if nodeId == "" {
+		if p.serverNode == nil {
+			return nil, fmt.Errorf("server node is not configured")
+		}
+		return p.serverNode, nil
+	}
+	pubkey, err := utils.ParsePubkey(nodeId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node id %s", nodeId)
+	}
+	node := p.getPeerNode(pubkey.SerializeCompressed())
+	if node == nil {
+		return nil, fmt.Errorf("unknown peer node %s", nodeId)
+	}
+	return node, nil
+}
+
+// 根据公钥找到节点，包括引导节点
+func (p *Manager) findNode(pubkey []byte) *Node {
+	if len(pubkey) == 0 {
+		return nil
+	}
+	if node := p.getPeerNode(pubkey); node != nil {
+		return node
+	}
+	for _, node := range p.bootstrapNode {
+		if node.Pubkey != nil && bytes.Equal(node.Pubkey.SerializeCompressed(), pubkey) {
+			return node
+		}
+	}
+	return nil
+}
+
+// 根据通道地址找到对端节点。通道地址是本地公钥跟对端公钥的2-2多签，用每个已知节点
+// （包括引导节点）的公钥计算，找不到时返回错误，不能把请求发给其他节点
+func (p *Manager) getPeerNodeWithChannelAddress(localWallet common.Wallet, channelAddr string) (*Node, error) {
+	if localWallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	localKey := localWallet.GetPaymentPubKey().SerializeCompressed()
+	nodes := append(p.GetPeerNodes(), p.bootstrapNode...)
+	for _, node := range nodes {
+		if node == nil || node.Pubkey == nil {
+			continue
+		}
+		addr, err := GetP2WSHaddress(node.Pubkey.SerializeCompressed(), localKey)
+		if err == nil && addr == channelAddr {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("can't find peer node of channel %s", channelAddr)
+}
+
+// 当前钱包跟每个节点的通道地址，默认服务节点排第一个
+func (p *Manager) getChannelAddresses() []string {
+	result := make([]string, 0)
+	for _, node := range p.GetPeerNodes() {
+		addr, err := p.getChannelAddressWithNode(node)
+		if err == nil {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// 默认服务节点排第一个
+func (p *Manager) GetPeerNodes() []*Node {
+	p.mutex.RLock()
+	result := make([]*Node, 0, len(p.peerNodes)+1)
+	for _, node := range p.peerNodes {
+		result = append(result, node)
+	}
+	p.mutex.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return nodeKey(result[i]) < nodeKey(result[j])
+	})
+	if p.serverNode != nil {
+		result = append([]*Node{p.serverNode}, result...)
+	}
+	return result
+}
+
+// url: http://host[:port]/stp/testnet
+func (p *Manager) AddPeerNode(nodeId, url string) (*Node, error) {
+	pubkey, err := utils.ParsePubkey(nodeId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node id %s", nodeId)
+	}
+	if node := p.getPeerNode(pubkey.SerializeCompressed()); node != nil {
+		return node, nil
+	}
+	if url == "" {
+		return nil, fmt.Errorf("empty node url")
+	}
+	scheme, host, proxy := parseNodeUrl(url)
+	node := NewNode(NewNodeClient(scheme, host, proxy, p.http), host, SERVER_NODE, pubkey, pubkey)
+	if err := p.addAndSavePeerNode(node, url); err != nil {
+		return nil, err
+	}
+	Log.Infof("peer node %s %s added", nodeId, host)
+	return node, nil
+}
+
+func (p *Manager) RemovePeerNode(nodeId string) error {
+	node, err := p.getPeerNodeWithHex(nodeId)
+	if err != nil {
+		return err
+	}
+	if p.isDefaultPeer(node) {
+		return fmt.Errorf("can't remove default server node")
+	}
+	for _, c := range p.GetAllChannels() {
+		if hex.EncodeToString(c.PeerNodeId) == nodeKey(node) && c.Status > CS_CLOSED {
+			return fmt.Errorf("channel %s with peer %s exists", c.ChannelId, nodeId)
+		}
+	}
+
+	if p.db != nil {
+		if err := deletePeerNode(p.db, nodeKey(node)); err != nil {
+			return err
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.peerNodes, nodeKey(node))
+	delete(p.peerStatus, nodeKey(node))
+	return nil
+}
+
+// 通过引导节点发现其他服务节点，返回新加入的节点
+func (p *Manager) DiscoverPeerNodes() ([]*Node, error) {
+	var lastErr error
+	result := make([]*Node, 0)
+	for _, bootstrap := range p.GetBootstrapNodes() {
+		client := bootstrap.RPCClient()
+		if client == nil {
+			continue
+		}
+		nodes, err := client.GetServerNodesReq()
+		if err != nil {
+			Log.Warnf("GetServerNodesReq from %s failed, %v", bootstrap.Host, err)
+			lastErr = err
+			continue
+		}
+		for _, n := range nodes {
+			if n.NodeId == nil || n.Pubkey == nil || n.Host == "" {
+				continue
+			}
+			if !n.NodeId.IsEqual(n.Pubkey) {
+				Log.Warnf("skip peer node %s with different payment key", nodeKey(n))
+				continue
+			}
+			if p.getPeerNode(n.NodeId.SerializeCompressed()) != nil {
+				continue
+			}
+			scheme, host, proxy := parseNodeUrl(n.Host)
+			node := NewNode(NewNodeClient(scheme, host, proxy, p.http), host, SERVER_NODE, n.NodeId, n.Pubkey)
+			if err := p.addAndSavePeerNode(node, n.Host); err != nil {
+				continue
+			}
+			result = append(result, node)
+		}
+		lastErr = nil
+		break
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
+
+// 在线状态缓存一段时间，force 为 true 时重新检查
+func (p *Manager) isNodeOnline(node *Node, force bool) bool {
+	key := nodeKey(node)
+	now := time.Now().Unix()
+	p.mutex.RLock()
+	status := p.peerStatus[key]
+	if !force && status != nil && now-status.refreshTime <= PEER_STATUS_REFRESH_INTERVAL {
+		online := status.online
+		p.mutex.RUnlock()
+		return online
+	}
+	p.mutex.RUnlock()
+
+	online := false
+	if client := node.RPCClient(); client != nil {
+		online = client.SendActionResultNfty(0, "", 0, "") == nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.peerStatus == nil {
+		p.peerStatus = make(map[string]*peerStatus)
+	}
+	status = p.peerStatus[key]
+	if status == nil {
+		status = &peerStatus{}
+		p.peerStatus[key] = status
+	}
+	status.online = online
+	status.refreshTime = now
+	return online
+}
+
+func (p *Manager) setPeerFeeConfig(nodeId []byte, feeCfg *ChannelFeeConfig) {
+	if len(nodeId) == 0 || feeCfg == nil {
+		return
+	}
+	key := hex.EncodeToString(nodeId)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.peerStatus == nil {
+		p.peerStatus = make(map[string]*peerStatus)
+	}
+	status := p.peerStatus[key]
+	if status == nil {
+		status = &peerStatus{}
+		p.peerStatus[key] = status
+	}
+	status.feeCfg = feeCfg.Clone()
+}
+
+// 对端的费用设置，还没跟对端开过通道时使用默认设置
+func (p *Manager) GetPeerFeeConfig(nodeId string) *ChannelFeeConfig {
+	node, err := p.getPeerNodeWithHex(nodeId)
+	if err != nil {
+		return NewFeeConfig()
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if status := p.peerStatus[nodeKey(node)]; status != nil && status.feeCfg != nil {
+		return status.feeCfg.Clone()
+	}
+	return NewFeeConfig()
+}
+
+func (p *Manager) GetPeerInfos() []*PeerInfo {
+	result := make([]*PeerInfo, 0)
+	for _, node := range p.GetPeerNodes() {
+		info := &PeerInfo{
+			NodeId:    nodeKey(node),
+			Host:      node.Host,
+			NodeType:  node.NodeType,
+			IsDefault: p.isDefaultPeer(node),
+			Online:    p.isNodeOnline(node, false),
+			FeeCfg:    p.GetPeerFeeConfig(nodeKey(node)),
+		}
+		info.ChannelAddress, _ = p.getChannelAddressWithNode(node)
+		result = append(result, info)
+	}
+	return result
+}
+
+func (p *Manager) getChannelAddressWithNode(node *Node) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	if node == nil || node.Pubkey == nil {
+		return "", fmt.Errorf("peer node is not initialized")
+	}
+	return GetP2WSHaddress(node.Pubkey.SerializeCompressed(),
+		p.wallet.GetPaymentPubKey().SerializeCompressed())
+}
+
+func (p *Manager) GetChannelAddressWithPeer(nodeId string) (string, error) {
+	node, err := p.getPeerNodeWithHex(nodeId)
+	if err != nil {
+		return "", err
+	}
+	return p.getChannelAddressWithNode(node)
+}
+
+// 跟某个对端的当前通道，包括正在开通的通道
+func (p *Manager) GetChannelWithPeer(nodeId string) *Channel {
+	channelId, err := p.GetChannelAddressWithPeer(nodeId)
+	if err != nil {
+		return nil
+	}
+	c := p.GetActiveChannelWithId(channelId)
+	if c != nil {
+		return c
+	}
+	for _, c := range p.GetFundingReservations() {
+		if c.ChannelId == channelId {
+			return c.Channel
+		}
+	}
+	return nil
+}
+
+func (p *Manager) OpenChannelWithPeer(nodeId string, feeRate int64, amt int64, utxos []string, memo string) (string, error) {
+	start := time.Now()
+	Log.Infof("OpenChannelWithPeer %s %d", nodeId, amt)
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	if !p.IsReady() {
+		return "", fmt.Errorf("not ready")
+	}
+	peer, err := p.getPeerNodeWithHex(nodeId)
+	if err != nil {
+		return "", err
+	}
+	if !p.isNodeOnline(peer, true) {
+		return "", fmt.Errorf("peer is offline")
+	}
+	if p.isBootstrapPeer(peer) {
+		pubkey := p.wallet.GetPaymentPubKey().SerializeCompressed()
+		if !p.HasStaked(pubkey) {
+			addr, _ := indexer.GetCoreNodeChannelAddress(pubkey, GetChainParam())
+			return "", fmt.Errorf("channel address %s has no stake assets", addr)
+		}
+	}
+
+	channelAddr, err := p.getChannelAddressWithNode(peer)
+	if err != nil {
+		return "", err
+	}
+	l2DrainTxId, err := p.DrainChannelL2BeforeOpenIfNeeded(channelAddr)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := p.funderInitFundingProcess(peer, feeRate, amt, utxos, memo, l2DrainTxId)
+	Log.Infof("OpenChannelWithPeer finished: %s, %v", result, time.Since(start))
+	return result, err
+}
+
+func (p *Manager) isBootstrapPeer(node *Node) bool {
+	if node == nil || node.Pubkey == nil {
+		return false
+	}
+	return hex.EncodeToString(node.Pubkey.SerializeCompressed()) == indexer.GetBootstrapPubKey()
+}
+
+func (p *Manager) ListChannels() []*ChannelSummary {
+	channels := p.GetAllChannels()
+	result := make([]*ChannelSummary, 0, len(channels))
+	for _, c := range channels {
+		peer := p.getPeerNode(c.PeerNodeId)
+
+		c.Mutex.RLock()
+		summary := &ChannelSummary{
+			ChannelId:    c.ChannelId,
+			Address:      c.Address,
+			Status:       int(c.Status),
+			Capacity:     c.Capacity,
+			PeerNodeId:   hex.EncodeToString(c.PeerNodeId),
+			PendingHtlcs: len(c.GetCommitHtlcs()),
+		}
+		if c.LocalCommitment != nil {
+			summary.LocalBalance = convertBalance(c.LocalCommitment.LocalBalance)
+			summary.RemoteBalance = convertBalance(c.LocalCommitment.RemoteBalance)
+		}
+		c.Mutex.RUnlock()
+
+		if peer != nil {
+			summary.PeerHost = peer.Host
+			summary.IsDefaultPeer = p.isDefaultPeer(peer)
+			summary.PeerOnline = p.isNodeOnline(peer, false)
+		}
+		result = append(result, summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PeerNodeId != result[j].PeerNodeId {
+			return result[i].PeerNodeId < result[j].PeerNodeId
+		}
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	return result
+}
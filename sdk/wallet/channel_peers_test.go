@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	indexerdb "github.com/sat20-labs/indexer/indexer/db"
+)
+
+func TestParseNodeUrl(t *testing.T) {
+	cases := []struct {
+		url, scheme, host, proxy string
+	}{
+		{"https://apiprd.sat20.org/stp/testnet", "https", "apiprd.sat20.org", "stp/testnet"},
+		{"http://127.0.0.1:9529/stp/mainnet", "http", "127.0.0.1:9529", "stp/mainnet"},
+		{"127.0.0.1:9529/proxy", "http", "127.0.0.1:9529", "proxy"},
+		{"seed.sat20.org", "http", "seed.sat20.org", ""},
+	}
+	for _, c := range cases {
+		scheme, host, proxy := parseNodeUrl(c.url)
+		if scheme != c.scheme || host != c.host || proxy != c.proxy {
+			t.Fatalf("%s: got %s %s %s", c.url, scheme, host, proxy)
+		}
+	}
+}
+
+func TestPeerNodeRegistry(t *testing.T) {
+	serverKey, _ := btcec.NewPrivateKey()
+	peerKey, _ := btcec.NewPrivateKey()
+	server := NewNode(nil, "server", SERVER_NODE, serverKey.PubKey(), serverKey.PubKey())
+	peer := NewNode(nil, "peer", SERVER_NODE, peerKey.PubKey(), peerKey.PubKey())
+
+	mgr := &Manager{serverNode: server}
+	mgr.addPeerNode(peer)
+
+	if mgr.getPeerNode(nil) != server {
+		t.Fatalf("empty node id should select default server")
+	}
+	if mgr.getPeerNode(peerKey.PubKey().SerializeCompressed()) != peer {
+		t.Fatalf("peer node not found")
+	}
+	other, _ := btcec.NewPrivateKey()
+	if mgr.getPeerNode(other.PubKey().SerializeCompressed()) != nil {
+		t.Fatalf("unknown peer should not be found")
+	}
+	nodes := mgr.GetPeerNodes()
+	if len(nodes) != 2 || nodes[0] != server {
+		t.Fatalf("default server should be listed first")
+	}
+
+	mgr.setPeerFeeConfig(peer.NodeId.SerializeCompressed(), &ChannelFeeConfig{ManageFee: 1})
+	if mgr.GetPeerFeeConfig(nodeKey(peer)).ManageFee != 1 {
+		t.Fatalf("peer fee config not recorded")
+	}
+	if mgr.GetPeerFeeConfig("").ManageFee != NewFeeConfig().ManageFee {
+		t.Fatalf("default server should use default fee config")
+	}
+}
+
+func TestPeerNodePersist(t *testing.T) {
+	database := indexerdb.NewKVDB(t.TempDir())
+	defer database.Close()
+	serverKey, _ := btcec.NewPrivateKey()
+	peerKey, _ := btcec.NewPrivateKey()
+	server := NewNode(nil, "server", SERVER_NODE, serverKey.PubKey(), serverKey.PubKey())
+	peerId := hex.EncodeToString(peerKey.PubKey().SerializeCompressed())
+	url := "http://127.0.0.1:9529/stp/testnet"
+
+	mgr := &Manager{serverNode: server, db: database}
+	if _, err := mgr.AddPeerNode(peerId, url); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后恢复
+	mgr = &Manager{serverNode: server, db: database}
+	if mgr.GetPeerNodeClient(&ChannelInDB{PeerNodeId: peerKey.PubKey().SerializeCompressed()}) != nil {
+		t.Fatalf("unknown peer should not fall back to default server")
+	}
+	mgr.loadPeerNodes()
+	node := mgr.getPeerNode(peerKey.PubKey().SerializeCompressed())
+	if node == nil || node.Host != "127.0.0.1:9529" {
+		t.Fatalf("peer node not restored")
+	}
+	if mgr.getPeerNodeUrl(peerId) != url {
+		t.Fatalf("peer node url not saved")
+	}
+
+	if err := mgr.RemovePeerNode(peerId); err != nil {
+		t.Fatal(err)
+	}
+	mgr = &Manager{serverNode: server, db: database}
+	mgr.loadPeerNodes()
+	if mgr.getPeerNode(peerKey.PubKey().SerializeCompressed()) != nil {
+		t.Fatalf("removed peer should not be restored")
+	}
+}
@@ -35,6 +35,7 @@ type ClosingDataInDB struct {
 	PreTxs          []*wire.MsgTx
 	CloseTx         *wire.MsgTx
 	DeAnchorTx      *swire.MsgTx
+	RemoteCommit    bool // 对端广播了它的commitment
 }
 
 func (p *ClosingDataInDB) GetType() string {
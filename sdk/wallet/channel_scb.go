@@ -0,0 +1,400 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	db "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+/*
+静态通道备份（SCB），格式见 docs/static-channel-backup.md
+只保存恢复通道需要的静态信息：通道点、对端公钥、派生索引、通道脚本，通道余额和承诺交易都不保存，
+所以只有通道点变化（打开、拼接、关闭）时才需要更新。设备丢失后，用助记词恢复钱包，再用备份文件
+向对端请求最新的通道数据，再请求对端强制关闭通道，清扫自己的输出。
+备份文件用钱包主密钥通过 BIP85 派生的密钥加密（AES-256-GCM），只要有助记词就可以解密。
+每个钱包（助记词）一个备份文件，包括所有子账户的通道。
+*/
+
+const (
+	DB_KEY_STATIC_CHANNEL_BACKUP = "scb-" // scb-channelId
+
+	SCB_FORMAT  = "sat20-channel-backup"
+	SCB_VERSION = 1
+	SCB_CIPHER  = "aes-256-gcm"
+
+	SCB_KEY_SIZE  = 32
+	SCB_KEY_INDEX = 0
+)
+
+// 单个通道的静态信息
+type StaticChannelBackup struct {
+	ChannelId     string `json:"channelId"`
+	ChannelPoint  string `json:"channelPoint"`      // txid:vout，拼接后会变化
+	PeerNodeId    string `json:"peerNodeId"`        // hex
+	PeerUrl       string `json:"peerUrl,omitempty"` // 对端不是配置中的节点时，恢复时用来连接对端
+	IsInitiator   bool   `json:"isInitiator"`
+	SubAccount    uint32 `json:"subAccount"` // 派生本地通道密钥的子账户
+	PaymentKey    string `json:"paymentKey"` // 本地通道公钥，hex，用来检查子账户
+	FundingScript []byte `json:"fundingScript"`
+	RedeemScript  []byte `json:"redeemScript"`
+	CsvDelay      uint16 `json:"csvDelay"`
+	Capacity      int64  `json:"capacity"`
+	FundingTime   int64  `json:"fundingTime"`
+
+	Fingerprint string `json:"-"` // 本地钱包的指纹，只保存在数据库中
+}
+
+// 明文信封
+type StaticChannelBackupFile struct {
+	Format      string `json:"format"`
+	Version     int    `json:"version"`
+	Network     string `json:"network"`
+	Cipher      string `json:"cipher"`
+	Fingerprint string `json:"fingerprint"` // 钱包指纹，恢复时找到对应的钱包
+	Created     int64  `json:"created"`
+	Data        []byte `json:"data"` // nonce + 加密后的 StaticChannelBackupPayload
+}
+
+type StaticChannelBackupPayload struct {
+	Format   string                 `json:"format"`
+	Version  int                    `json:"version"`
+	Network  string                 `json:"network"`
+	Created  int64                  `json:"created"`
+	Channels []*StaticChannelBackup `json:"channels"`
+}
+
+// 通道打开后，或者还在关闭过程中，都需要备份
+func needStaticChannelBackup(c *ChannelInDB) bool {
+	if c == nil || c.ChanPoint == nil || c.ChanPoint.OutPointStr == "" {
+		return false
+	}
+	switch c.Status {
+	case CS_CLOSED, CS_CLOSED_FORCELY, CS_CLOSED_UNEXPECTED,
+		CS_CLOSING_CONFIRMED, CS_CLOSE_FORCELY_SWEEP_CONFIRMED:
+		return false
+	}
+	return true
+}
+
+func NewStaticChannelBackup(c *ChannelInDB, fingerprint string) *StaticChannelBackup {
+	result := &StaticChannelBackup{
+		ChannelId:     c.ChannelId,
+		ChannelPoint:  c.ChanPoint.OutPointStr,
+		PeerNodeId:    hex.EncodeToString(c.PeerNodeId),
+		IsInitiator:   c.IsInitiator,
+		SubAccount:    c.LocalChanCfg.WalletId,
+		FundingScript: c.GetChannelPkScript(),
+		RedeemScript:  c.RedeemScript,
+		CsvDelay:      c.CsvDelay,
+		Capacity:      c.Capacity,
+		FundingTime:   c.FundingTime,
+		Fingerprint:   fingerprint,
+	}
+	if c.LocalChanCfg.PaymentKey != nil {
+		result.PaymentKey = hex.EncodeToString(c.LocalChanCfg.PaymentKey.SerializeCompressed())
+	}
+	return result
+}
+
+// BIP85 HEX 应用：m/83696968'/128169'/{num_bytes}'/{index}'
+func (p *InternalWallet) DeriveChannelBackupKey() ([]byte, error) {
+	if p.masterkey == nil {
+		return nil, fmt.Errorf("wallet has no master key")
+	}
+	entropy, err := DeriveBip85Entropy(p.masterkey,
+		[]uint32{BIP85_PURPOSE, BIP85_APP_HEX, SCB_KEY_SIZE, SCB_KEY_INDEX})
+	if err != nil {
+		return nil, err
+	}
+	return entropy[:SCB_KEY_SIZE], nil
+}
+
+func channelBackupKey(w common.Wallet) ([]byte, error) {
+	wallet, ok := w.(*InternalWallet)
+	if !ok {
+		return nil, fmt.Errorf("only internal wallet supports static channel backup")
+	}
+	return wallet.DeriveChannelBackupKey()
+}
+
+func encryptStaticChannelBackup(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, nil)
+	return append(nonce, ciphertext...), nil
+}
+
+func decryptStaticChannelBackup(key, payload []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return aead.Open(nil, payload[:aead.NonceSize()], payload[aead.NonceSize():], nil)
+}
+
+// 生成加密的备份文件，通道按 ChannelId 排序
+func EncodeStaticChannelBackup(key []byte, fingerprint string, channels []*StaticChannelBackup) ([]byte, error) {
+	sorted := make([]*StaticChannelBackup, len(channels))
+	copy(sorted, channels)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ChannelId < sorted[j].ChannelId
+	})
+
+	now := time.Now().Unix()
+	payload := StaticChannelBackupPayload{
+		Format:   SCB_FORMAT,
+		Version:  SCB_VERSION,
+		Network:  GetChainParam().Name,
+		Created:  now,
+		Channels: sorted,
+	}
+	data, err := json.Marshal(&payload)
+	if err != nil {
+		return nil, err
+	}
+	en, err := encryptStaticChannelBackup(key, data)
+	if err != nil {
+		Log.Errorf("encryptStaticChannelBackup failed. %v", err)
+		return nil, err
+	}
+
+	file := StaticChannelBackupFile{
+		Format:      SCB_FORMAT,
+		Version:     SCB_VERSION,
+		Network:     payload.Network,
+		Cipher:      SCB_CIPHER,
+		Fingerprint: fingerprint,
+		Created:     now,
+		Data:        en,
+	}
+	return json.Marshal(&file)
+}
+
+// 只解析明文信封
+func ParseStaticChannelBackupFile(data []byte) (*StaticChannelBackupFile, error) {
+	var file StaticChannelBackupFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel backup file, %v", err)
+	}
+	if file.Format != SCB_FORMAT {
+		return nil, fmt.Errorf("invalid channel backup file format %s", file.Format)
+	}
+	if file.Version < 1 || file.Version > SCB_VERSION {
+		return nil, fmt.Errorf("unsupported channel backup file version %d", file.Version)
+	}
+	if file.Cipher != SCB_CIPHER {
+		return nil, fmt.Errorf("unsupported channel backup file cipher %s", file.Cipher)
+	}
+	return &file, nil
+}
+
+// 解密备份文件，检查版本和网络
+func DecodeStaticChannelBackup(data, key []byte) (*StaticChannelBackupPayload, error) {
+	file, err := ParseStaticChannelBackupFile(data)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decryptStaticChannelBackup(key, file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("channel backup file is corrupted or belongs to another wallet")
+	}
+
+	var payload StaticChannelBackupPayload
+	err = json.Unmarshal(plain, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel backup file data, %v", err)
+	}
+	if payload.Format != file.Format || payload.Version != file.Version || payload.Network != file.Network {
+		return nil, fmt.Errorf("channel backup file header mismatch")
+	}
+	if payload.Network != GetChainParam().Name {
+		return nil, fmt.Errorf("channel backup file is for %s, current network is %s",
+			payload.Network, GetChainParam().Name)
+	}
+	return &payload, nil
+}
+
+func GetStaticChannelBackupDBKey(channelId string) string {
+	return GetDBKeyPrefix() + DB_KEY_STATIC_CHANNEL_BACKUP + channelId
+}
+
+func saveStaticChannelBackup(kvdb db.KVDB, backup *StaticChannelBackup) error {
+	buf, err := EncodeToBytes(backup)
+	if err != nil {
+		Log.Errorf("saveStaticChannelBackup EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetStaticChannelBackupDBKey(backup.ChannelId)), buf)
+}
+
+func deleteStaticChannelBackup(kvdb db.KVDB, channelId string) error {
+	return kvdb.Delete([]byte(GetStaticChannelBackupDBKey(channelId)))
+}
+
+func loadAllStaticChannelBackups(kvdb db.KVDB) map[string]*StaticChannelBackup {
+	result := make(map[string]*StaticChannelBackup)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_STATIC_CHANNEL_BACKUP)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var backup StaticChannelBackup
+		err := DecodeFromBytes(v, &backup)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result[backup.ChannelId] = &backup
+		return nil
+	})
+	return result
+}
+
+func (p *Manager) SetStaticChannelBackupHandler(handler StaticChannelBackupHandler) {
+	if handler == nil {
+		handler = noopStaticChannelBackupHandler{}
+	}
+	p.scbMutex.Lock()
+	defer p.scbMutex.Unlock()
+	p.scbHandler = handler
+}
+
+// 调用者需要持有 scbMutex
+func (p *Manager) loadStaticChannelBackupsLocked() {
+	if p.scbEntries != nil {
+		return
+	}
+	if p.db == nil {
+		p.scbEntries = make(map[string]*StaticChannelBackup)
+		return
+	}
+	p.scbEntries = loadAllStaticChannelBackups(p.db)
+}
+
+// 调用者需要持有 scbMutex
+func (p *Manager) staticChannelBackupsLocked(fingerprint string) []*StaticChannelBackup {
+	p.loadStaticChannelBackupsLocked()
+	result := make([]*StaticChannelBackup, 0)
+	for _, backup := range p.scbEntries {
+		if backup.Fingerprint == fingerprint {
+			result = append(result, backup)
+		}
+	}
+	return result
+}
+
+// 调用者需要持有 scbMutex，返回静态信息是否有变化
+func (p *Manager) updateStaticChannelBackupLocked(c *Channel, fingerprint string) bool {
+	p.loadStaticChannelBackupsLocked()
+	old := p.scbEntries[c.ChannelId]
+	if !needStaticChannelBackup(&c.ChannelInDB) {
+		if old == nil {
+			return false
+		}
+		delete(p.scbEntries, c.ChannelId)
+		if p.db != nil {
+			if err := deleteStaticChannelBackup(p.db, c.ChannelId); err != nil {
+				Log.Errorf("deleteStaticChannelBackup %s failed. %v", c.ChannelId, err)
+			}
+		}
+		return true
+	}
+
+	backup := NewStaticChannelBackup(&c.ChannelInDB, fingerprint)
+	backup.PeerUrl = p.getPeerNodeUrl(backup.PeerNodeId)
+	if old != nil && reflect.DeepEqual(old, backup) {
+		return false
+	}
+	p.scbEntries[c.ChannelId] = backup
+	if p.db != nil {
+		if err := saveStaticChannelBackup(p.db, backup); err != nil {
+			Log.Errorf("saveStaticChannelBackup %s failed. %v", c.ChannelId, err)
+		}
+	}
+	return true
+}
+
+// 通道保存时调用，静态信息有变化时重新导出这个钱包的备份文件，
+// 交给 StaticChannelBackupHandler 保存，同时通知上层
+func (p *Manager) updateStaticChannelBackup(c *Channel) {
+	w := c.LocalWallet()
+	if w == nil {
+		return
+	}
+	fingerprint := walletFingerprint(w)
+
+	p.scbMutex.Lock()
+	if !p.updateStaticChannelBackupLocked(c, fingerprint) {
+		p.scbMutex.Unlock()
+		return
+	}
+	handler := p.scbHandler
+	channels := p.staticChannelBackupsLocked(fingerprint)
+	p.scbMutex.Unlock()
+
+	key, err := channelBackupKey(w)
+	if err != nil {
+		Log.Warnf("channel %s: skip static channel backup. %v", c.ChannelId, err)
+		return
+	}
+	data, err := EncodeStaticChannelBackup(key, fingerprint, channels)
+	if err != nil {
+		Log.Errorf("EncodeStaticChannelBackup failed. %v", err)
+		return
+	}
+	if handler != nil {
+		if err := handler.BackupStaticChannels(fingerprint, data); err != nil {
+			Log.Errorf("BackupStaticChannels failed. %v", err)
+		}
+	}
+	p.SendMessageToUpper(MSG_CHANNEL_BACKUP_UPDATED, string(data))
+	Log.Infof("static channel backup exported, channel %s changed", c.ChannelId)
+}
+
+// 导出当前钱包的静态通道备份文件
+func (p *Manager) ExportStaticChannelBackup() ([]byte, error) {
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	key, err := channelBackupKey(p.wallet)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := walletFingerprint(p.wallet)
+	channels := p.GetAllChannels()
+
+	p.scbMutex.Lock()
+	defer p.scbMutex.Unlock()
+	// 升级前打开的通道还没有记录
+	for _, c := range channels {
+		if c.LocalWallet() != nil && walletFingerprint(c.LocalWallet()) == fingerprint {
+			p.updateStaticChannelBackupLocked(c, fingerprint)
+		}
+	}
+	return EncodeStaticChannelBackup(key, fingerprint, p.staticChannelBackupsLocked(fingerprint))
+}
@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	wwire "github.com/sat20-labs/sat20wallet/sdk/wire"
+)
+
+/*
+用静态通道备份恢复通道
+1. 用助记词派生的密钥解密备份文件，只处理当前子账户的通道，其他子账户需要切换后再恢复
+2. 向对端发送同步请求，对端返回它保存的通道数据
+3. 检查通道脚本跟备份一致后，请求对端广播它自己最新的承诺交易。对端返回的本地承诺交易
+   可能是已经撤销的状态，自己广播会被对端惩罚，所以恢复时不能广播本地承诺交易
+4. 监控线程发现对端的承诺交易上链后进入关闭流程：自己的资产直接回到通道地址，htlc输出
+   由 BuildSignedHtlcSweepTxForClient 清扫
+对端不在线或者没有通道数据时无法恢复，可以稍后重试，已经恢复的通道会被跳过
+*/
+
+const (
+	SCB_SYNC_REASON = "scb-recover"
+
+	SCB_RECOVER_EXISTS     = "exists"    // 本地已经有这个通道
+	SCB_RECOVER_SKIPPED    = "skipped"   // 属于其他子账户
+	SCB_RECOVER_CLOSING    = "closing"   // 已经恢复，通道在关闭过程中
+	SCB_RECOVER_PEER_CLOSE = "peerclose" // 已经请求对端强制关闭，等待对端的承诺交易上链
+	SCB_RECOVER_FAILED     = "failed"
+)
+
+type ChannelRecoveryResult struct {
+	ChannelId  string `json:"channelId"`
+	PeerNodeId string `json:"peerNodeId"`
+	SubAccount uint32 `json:"subAccount"`
+	Status     string `json:"status"`
+	CommitTxId string `json:"commitTxId,omitempty"` // 对端的承诺交易
+	Error      string `json:"error,omitempty"`
+}
+
+// 解密当前钱包的备份文件，不做任何恢复操作
+func (p *Manager) ReadStaticChannelBackup(data []byte) (*StaticChannelBackupPayload, error) {
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	file, err := ParseStaticChannelBackupFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if file.Fingerprint != "" && file.Fingerprint != walletFingerprint(p.wallet) {
+		return nil, fmt.Errorf("channel backup file belongs to another wallet")
+	}
+	key, err := channelBackupKey(p.wallet)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeStaticChannelBackup(data, key)
+}
+
+// feeRate 是请求对端强制关闭时建议的费率，0 表示使用默认值
+func (p *Manager) RecoverChannelsFromStaticBackup(data []byte, feeRate int64) ([]*ChannelRecoveryResult, error) {
+	payload, err := p.ReadStaticChannelBackup(data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ChannelRecoveryResult, 0, len(payload.Channels))
+	for _, backup := range payload.Channels {
+		result := &ChannelRecoveryResult{
+			ChannelId:  backup.ChannelId,
+			PeerNodeId: backup.PeerNodeId,
+			SubAccount: backup.SubAccount,
+		}
+		results = append(results, result)
+
+		err := p.recoverChannelFromStaticBackup(backup, feeRate, result)
+		if err != nil {
+			Log.Errorf("recover channel %s failed. %v", backup.ChannelId, err)
+			result.Status = SCB_RECOVER_FAILED
+			result.Error = err.Error()
+			continue
+		}
+		Log.Infof("recover channel %s: %s %s", backup.ChannelId, result.Status, result.CommitTxId)
+	}
+	return results, nil
+}
+
+func (p *Manager) recoverChannelFromStaticBackup(backup *StaticChannelBackup, feeRate int64,
+	result *ChannelRecoveryResult) error {
+	if channel, err := p.LoadChannelInDB(backup.ChannelId); err == nil && channel.Status != CS_CLOSED {
+		result.Status = SCB_RECOVER_EXISTS
+		return nil
+	}
+	if backup.SubAccount != p.wallet.GetSubAccount() {
+		result.Status = SCB_RECOVER_SKIPPED
+		return nil
+	}
+	paymentKey := hex.EncodeToString(p.wallet.GetPaymentPubKey().SerializeCompressed())
+	if backup.PaymentKey != "" && backup.PaymentKey != paymentKey {
+		return fmt.Errorf("payment key mismatch, backup %s, wallet %s", backup.PaymentKey, paymentKey)
+	}
+	peerNodeId, err := hex.DecodeString(backup.PeerNodeId)
+	if err != nil {
+		return fmt.Errorf("invalid peer node id %s", backup.PeerNodeId)
+	}
+
+	node := p.getPeerNode(peerNodeId)
+	if node == nil && backup.PeerUrl != "" {
+		node, err = p.AddPeerNode(backup.PeerNodeId, backup.PeerUrl)
+		if err != nil {
+			return err
+		}
+	}
+	if node == nil || node.RPCClient() == nil {
+		return fmt.Errorf("unknown peer %s, add it with AddPeerNode first", backup.PeerNodeId)
+	}
+	client := node.RPCClient()
+	err = p.SyncChannel(SCB_SYNC_REASON, client)
+	if err != nil {
+		return fmt.Errorf("peer %s can't provide channel data, %v", backup.PeerNodeId, err)
+	}
+
+	channel := p.GetChannel(backup.ChannelId)
+	if channel == nil {
+		channelInDB, err := p.LoadChannelInDB(backup.ChannelId)
+		if err != nil {
+			return fmt.Errorf("peer %s has no data for channel %s", backup.PeerNodeId, backup.ChannelId)
+		}
+		channel = NewChannel(channelInDB, p)
+	}
+	// 通道地址永远不变，通道点在拼接后会变化
+	if !bytes.Equal(channel.GetChannelPkScript(), backup.FundingScript) {
+		return fmt.Errorf("channel %s funding script mismatch", backup.ChannelId)
+	}
+	if channel.ChanPoint != nil && channel.ChanPoint.OutPointStr != backup.ChannelPoint {
+		Log.Infof("channel %s point changed from %s to %s", backup.ChannelId,
+			backup.ChannelPoint, channel.ChanPoint.OutPointStr)
+	}
+	if channel.Status != CS_READY {
+		result.Status = SCB_RECOVER_CLOSING
+		return nil
+	}
+
+	channel.Mutex.Lock()
+	defer channel.Mutex.Unlock()
+	commitTxId, err := p.requestPeerForceClose(channel, client, feeRate)
+	if err != nil {
+		return err
+	}
+	// 监控线程检查启用的通道，发现对端的承诺交易上链后进入关闭流程
+	if p.GetChannel(channel.ChannelId) == nil {
+		p.EnableChannel(channel)
+	}
+	result.Status = SCB_RECOVER_PEER_CLOSE
+	result.CommitTxId = commitTxId
+	return nil
+}
+
+// 请求对端广播它自己最新的承诺交易，返回该交易的txid
+func (p *Manager) requestPeerForceClose(channel *Channel, client NodeRPCClient, feeRate int64) (string, error) {
+	if channel.RemoteCommitment == nil || channel.RemoteCommitment.CommitTx == nil {
+		return "", fmt.Errorf("channel %s has no peer commitment", channel.ChannelId)
+	}
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", err
+	}
+	localWallet := channel.LocalWallet()
+	req := &wwire.CloseChannelRequest{
+		MsgHeader:    wwire.NewMsgHeader(),
+		ChannelId:    channel.ChannelId,
+		CommitHeight: channel.CommitHeight,
+		FeeRate:      feeRate,
+		NodeId:       localWallet.GetNodePubKey().SerializeCompressed(),
+		Force:        true,
+	}
+	sig, err := signRPCMessage(localWallet, req)
+	if err != nil {
+		return "", err
+	}
+	_, err = client.SendChannelCloseReq(&wwire.ChannelCloseReq{
+		CloseChannelRequest: *req,
+		Sig:                 sig,
+	})
+	if err != nil {
+		return "", fmt.Errorf("request peer to force close channel %s failed, %v", channel.ChannelId, err)
+	}
+	return channel.RemoteCommitment.CommitTx.TxID(), nil
+}
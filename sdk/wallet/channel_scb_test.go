@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+func TestBip85HexEntropy(t *testing.T) {
+	// BIP85 HEX 测试向量
+	masterkey, err := hdkeychain.NewKeyFromString("xprv9s21ZrQH143K2LBWUUQRFXhucrQqBpKdRRxNVq2zBqsx8HVqFk2uYo8kmbaLLHRdqtQpUm98uKfu3vca1LqdGhUtyoFnCNkfmXRyPXLjbKb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entropy, err := DeriveBip85Entropy(masterkey, []uint32{BIP85_PURPOSE, BIP85_APP_HEX, 64, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "492db4698cf3b73a5a24998aa3e9d7fa96275d85724a91e71aa2d645442f878555d078fd1f1f67e368976f04137b1f7a0d19232136ca50c44614af72b5582a5c"
+	if hex.EncodeToString(entropy) != expected {
+		t.Fatalf("derived %x, expected %s", entropy, expected)
+	}
+}
+
+func TestStaticChannelBackupFile(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, SCB_KEY_SIZE)
+	channels := []*StaticChannelBackup{
+		{ChannelId: "tb1qchannel2", ChannelPoint: "aa:0", PeerNodeId: "02aa", PeerUrl: "http://peer/stp/testnet", SubAccount: 1,
+			FundingScript: []byte{0, 32, 1}, CsvDelay: 144, Capacity: 100000},
+		{ChannelId: "tb1qchannel1", ChannelPoint: "bb:1", PeerNodeId: "03bb", IsInitiator: true},
+	}
+	data, err := EncodeStaticChannelBackup(key, "fp", channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ParseStaticChannelBackupFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Fingerprint != "fp" || file.Version != SCB_VERSION {
+		t.Fatalf("invalid envelope %s %d", file.Fingerprint, file.Version)
+	}
+	if bytes.Contains(data, []byte("tb1qchannel1")) {
+		t.Fatal("channel data should be encrypted")
+	}
+
+	payload, err := DecodeStaticChannelBackup(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Channels) != 2 || payload.Channels[0].ChannelId != "tb1qchannel1" {
+		t.Fatalf("channels should be sorted, got %v", payload.Channels)
+	}
+	c := payload.Channels[1]
+	if c.ChannelPoint != "aa:0" || c.PeerUrl != "http://peer/stp/testnet" || c.SubAccount != 1 || c.CsvDelay != 144 || !bytes.Equal(c.FundingScript, []byte{0, 32, 1}) {
+		t.Fatalf("channel data mismatch %v", c)
+	}
+
+	if _, err := DecodeStaticChannelBackup(data, bytes.Repeat([]byte{2}, SCB_KEY_SIZE)); err == nil {
+		t.Fatal("wrong key should fail")
+	}
+
+	// 修改信封中的版本
+	file.Version = SCB_VERSION + 1
+	tampered, _ := json.Marshal(file)
+	if _, err := DecodeStaticChannelBackup(tampered, key); err == nil {
+		t.Fatal("unsupported version should fail")
+	}
+	file.Version = SCB_VERSION
+	file.Data[len(file.Data)-1] ^= 1
+	tampered, _ = json.Marshal(file)
+	if _, err := DecodeStaticChannelBackup(tampered, key); err == nil {
+		t.Fatal("tampered data should fail")
+	}
+}
+
+func TestNeedStaticChannelBackup(t *testing.T) {
+	c := NewChannelInDB()
+	if needStaticChannelBackup(c) {
+		t.Fatal("channel without channel point should not be backed up")
+	}
+	c.ChanPoint = &TxOutput{OutPointStr: "aa:0"}
+	for status, need := range map[ChannelStatus]bool{
+		CS_FUNDING_BROADCASTED:           true,
+		CS_READY:                         true,
+		CS_CLOSE_FORCELY_BROADCASTED:     true,
+		CS_CLOSING_CONFIRMED:             false,
+		CS_CLOSE_FORCELY_SWEEP_CONFIRMED: false,
+		CS_CLOSED:                        false,
+	} {
+		c.Status = status
+		if needStaticChannelBackup(c) != need {
+			t.Fatalf("status %d: expected %v", status, need)
+		}
+	}
+}
@@ -53,7 +53,6 @@ func (p *Manager) CreateAndVerifyPunishTx(channel *Channel, rev []byte, feeRate
 	commitsecret, commitpoint := btcec.PrivKeyFromBytes(rev)
 	keyRing := DeriveCommitmentKeys(commitpoint, 1, bootstrapKey, nil, channel)
 
-	revPrivKey := channel.LocalWallet().DeriveRevocationPrivKey(commitsecret)
 	commitTx := channel.RemoteCommitment.CommitTx
 	serverCommit := channel.IsInitiator
 
@@ -62,18 +61,27 @@ func (p *Manager) CreateAndVerifyPunishTx(channel *Channel, rev []byte, feeRate
 		Log.Errorf("GenerateChannelScript2 failed. %v", err)
 		return nil, err
 	}
+	htlcCount := len(channel.RemoteCommitment.Htlcs)
 	remoteIndex, _, err := FindOutputIndexes(commitTx, toLocalScript.PkScript(), toRemoteScript.PkScript())
-	if err != nil {
+	if err != nil && htlcCount == 0 {
 		Log.Errorf("findOutputIndexes failed. %v", err)
 		return nil, err
 	}
-	if len(remoteIndex) == 0 {
+	if len(remoteIndex) == 0 && htlcCount == 0 {
 		Log.Warning("no remote output")
 		return nil, nil
 	}
 
-	punishTx, prevFetcher, err := CreatePunishmentTx(channel.RemoteCommitment, revPrivKey, channel.LocalChanCfg.PaymentKey,
-		remoteIndex, toLocalScript.WitnessScriptToSign(), feeRate)
+	var punishTx *wire.MsgTx
+	var prevFetcher txscript.PrevOutputFetcher
+	if signer, ok := channel.LocalWallet().(punishTxSigner); ok {
+		punishTx, prevFetcher, err = CreatePunishmentTxWithSigner(channel.RemoteCommitment, signer, commitsecret,
+			channel.LocalChanCfg.PaymentKey, remoteIndex, toLocalScript.WitnessScriptToSign(), feeRate)
+	} else {
+		revPrivKey := channel.LocalWallet().DeriveRevocationPrivKey(commitsecret)
+		punishTx, prevFetcher, err = CreatePunishmentTx(channel.RemoteCommitment, revPrivKey, channel.LocalChanCfg.PaymentKey,
+			remoteIndex, toLocalScript.WitnessScriptToSign(), feeRate)
+	}
 	if err != nil {
 		Log.Errorf("Failed to create punish tx: %v", err)
 		return nil, err
@@ -93,6 +101,11 @@ func (p *Manager) CreateAndVerifyPunishTx(channel *Channel, rev []byte, feeRate
 }
 
 func (p *Manager) BuildSignedSweepTxForClient(channel *Channel, height int, feeRate int64) (*SignedSweepTxPackage, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
+	}
+
 	// client sweep 花费的是本地 commitment tx 中属于自己的 delayed output。
 	// 如果本地 commitment 没有 local output，说明没有需要等待 CSV 后清扫的资产。
 	commitSecret := channel.LocalWallet().GetCommitSecret(channel.PeerNodeId, uint32(channel.CommitHeight))
@@ -159,11 +172,221 @@ func (p *Manager) BuildSignedSweepTxForClient(channel *Channel, height int, feeR
 	return result, nil
 }
 
+// 返回commitTx中还没有被花费的htlc，以及是否由本地所有的commitment
+func (p *Manager) getUnspentCommitHtlcs(channel *Channel, commitTx *wire.MsgTx) ([]*ChannelHtlc, bool, error) {
+	if commitTx == nil {
+		return nil, false, fmt.Errorf("no commit tx")
+	}
+	var htlcs []*ChannelHtlc
+	ownCommit := false
+	commitTxId := commitTx.TxID()
+	if channel.LocalCommitment.CommitTx != nil && channel.LocalCommitment.CommitTx.TxID() == commitTxId {
+		htlcs = channel.LocalCommitment.Htlcs
+		ownCommit = true
+	} else if channel.RemoteCommitment.CommitTx != nil && channel.RemoteCommitment.CommitTx.TxID() == commitTxId {
+		htlcs = channel.RemoteCommitment.Htlcs
+	} else {
+		return nil, false, fmt.Errorf("unknown commit tx %s", commitTxId)
+	}
+
+	var result []*ChannelHtlc
+	var utxos []string
+	for _, htlc := range htlcs {
+		if _, ok := findHtlcOutput(commitTx, htlc); !ok {
+			continue
+		}
+		result = append(result, htlc)
+		utxos = append(utxos, fmt.Sprintf("%s:%d", commitTxId, htlc.OutputIndex))
+	}
+	if len(utxos) == 0 {
+		return nil, ownCommit, nil
+	}
+	existing, err := p.l1IndexerClient.GetExistingUtxos(utxos)
+	if err != nil {
+		return nil, ownCommit, err
+	}
+	existingMap := make(map[string]bool)
+	for _, utxo := range existing {
+		existingMap[utxo] = true
+	}
+	unspent := make([]*ChannelHtlc, 0, len(result))
+	for i, htlc := range result {
+		if existingMap[utxos[i]] {
+			unspent = append(unspent, htlc)
+		}
+	}
+	return unspent, ownCommit, nil
+}
+
+// 是否还有需要等待超时后才能取回的htlc输出
+func (p *Manager) hasPendingHtlcOutputs(channel *Channel, commitTx *wire.MsgTx, height int) (bool, error) {
+	htlcs, ownCommit, err := p.getUnspentCommitHtlcs(channel, commitTx)
+	if err != nil {
+		return false, err
+	}
+	for _, htlc := range htlcs {
+		// htlc.Incoming 是所有者视角
+		weAreSender := htlc.Incoming != ownCommit
+		if weAreSender && !htlc.IsExpired(height) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// htlc sweep 花费 commitment tx 中属于自己的 htlc 输出：自己是发送方时
+// 在超时后取回，自己是接收方时用原像领取。本地 commitment 上需要满足 CSV。
+func (p *Manager) BuildSignedHtlcSweepTxForClient(channel *Channel, commitTx *wire.MsgTx,
+	height int, feeRate int64) (*SignedSweepTxPackage, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
+	}
+	htlcs, ownCommit, err := p.getUnspentCommitHtlcs(channel, commitTx)
+	if err != nil {
+		return nil, err
+	}
+
+	recvPkScript, err := GetP2TRpkScript(channel.LocalChanCfg.PaymentKey)
+	if err != nil {
+		return nil, err
+	}
+	sequence := uint32(wire.MaxTxInSequenceNum - 1)
+	if ownCommit {
+		sequence = uint32(channel.CsvDelay)
+	}
+
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.LockTime = uint32(height)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var weightEstimate utils.TxWeightEstimator
+	var witnesses []wire.TxWitness
+	var scripts [][]byte
+	var value int64
+	hash := commitTx.TxHash()
+	for _, htlc := range htlcs {
+		var witness wire.TxWitness
+		weAreSender := htlc.Incoming != ownCommit
+		if weAreSender {
+			if !htlc.IsExpired(height) {
+				continue
+			}
+			witness = wire.TxWitness{nil, {}, {}, htlc.WitnessScript}
+			weightEstimate.AddWitnessInput(utils.ChannelHtlcTimeoutWitnessSize)
+		} else {
+			if len(htlc.Preimage) == 0 {
+				continue
+			}
+			witness = wire.TxWitness{nil, htlc.Preimage, {1}, {}, htlc.WitnessScript}
+			weightEstimate.AddWitnessInput(utils.ChannelHtlcSuccessWitnessSize)
+		}
+		txOut := commitTx.TxOut[htlc.OutputIndex]
+		outPoint := wire.NewOutPoint(&hash, uint32(htlc.OutputIndex))
+		sweepTx.AddTxIn(&wire.TxIn{PreviousOutPoint: *outPoint, Sequence: sequence})
+		prevFetcher.AddPrevOut(*outPoint, txOut)
+		witnesses = append(witnesses, witness)
+		scripts = append(scripts, htlc.WitnessScript)
+		value += txOut.Value
+	}
+	if len(sweepTx.TxIn) == 0 {
+		return nil, nil
+	}
+
+	weightEstimate.AddP2TROutput()
+	fee := weightEstimate.Fee(feeRate)
+	if value < fee+330 {
+		Log.Warningf("%s htlc outputs too small to sweep", commitTx.TxID())
+		return nil, nil
+	}
+	sweepTx.AddTxOut(wire.NewTxOut(value-fee, recvPkScript))
+
+	result := &SignedSweepTxPackage{
+		SweepTx:     sweepTx,
+		Txs:         []*wire.MsgTx{sweepTx},
+		PrevFetcher: prevFetcher,
+		Fee:         fee,
+		CommitTxId:  commitTx.TxID(),
+	}
+
+	// 每个htlc的脚本都不一样，逐个输入签名
+	for i, txIn := range sweepTx.TxIn {
+		txIn.Witness = witnesses[i]
+		_, err = PartialSignTxWithWallet(channel.LocalWallet(), sweepTx, prevFetcher, scripts[i], true, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Signed = true
+	result.SweepTxId = sweepTx.TxID()
+
+	err = VerifySignedTx(sweepTx, prevFetcher)
+	if err != nil {
+		Log.Errorf("VerifySignedTx failed, %v", err)
+		return nil, err
+	}
+	result.Verified = true
+	result.Broadcastable = true
+	return result, nil
+}
+
 func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btcec.PrivateKey,
 	recvPubKey *btcec.PublicKey, outputIndex []int,
 	commitmentScript []byte, feeRate int64) (*wire.MsgTx, txscript.PrevOutputFetcher, error) {
 	// 构建并签名惩罚交易。这里不依赖引导节点签名，只使用已经收到的
 	// revocation 私钥花费对端旧 commitment 的可惩罚输出。
+	punishTx, prevFetcher, scripts, err := createUnsignedPunishmentTx(remoteCommit, recvPubKey,
+		outputIndex, commitmentScript, feeRate)
+	if err != nil || punishTx == nil {
+		return nil, nil, err
+	}
+
+	sigHashes := txscript.NewTxSigHashes(punishTx, prevFetcher)
+	for i, txIn := range punishTx.TxIn {
+		preOut := prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		sigScript, err := txscript.RawTxInWitnessSignature(punishTx, sigHashes, i,
+			preOut.Value, scripts[i], txscript.SigHashAll, revocationPrivKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+		txIn.Witness = punishWitness(sigScript, scripts[i])
+	}
+
+	PrintJsonTx(punishTx, "punish TX")
+	return punishTx, prevFetcher, nil
+}
+
+// 私钥在外部签名器中的钱包，revocation 私钥由签名器派生，惩罚交易也由签名器签名
+type punishTxSigner interface {
+	SignPunishTx(commitsecret *btcec.PrivateKey, tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
+		scripts [][]byte) error
+}
+
+func CreatePunishmentTxWithSigner(remoteCommit *ChannelCommitment, signer punishTxSigner,
+	commitsecret *btcec.PrivateKey, recvPubKey *btcec.PublicKey, outputIndex []int,
+	commitmentScript []byte, feeRate int64) (*wire.MsgTx, txscript.PrevOutputFetcher, error) {
+	punishTx, prevFetcher, scripts, err := createUnsignedPunishmentTx(remoteCommit, recvPubKey,
+		outputIndex, commitmentScript, feeRate)
+	if err != nil || punishTx == nil {
+		return nil, nil, err
+	}
+	err = signer.SignPunishTx(commitsecret, punishTx, prevFetcher, scripts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+
+	PrintJsonTx(punishTx, "punish TX")
+	return punishTx, prevFetcher, nil
+}
+
+// commitment script 和 htlc script 的第二个 witness 元素都选择 revocation 分支。
+func punishWitness(sig, script []byte) wire.TxWitness {
+	return wire.TxWitness{sig, []byte{1}, script}
+}
+
+// 返回未签名的惩罚交易，scripts 是每个输入对应的 witness script
+func createUnsignedPunishmentTx(remoteCommit *ChannelCommitment, recvPubKey *btcec.PublicKey,
+	outputIndex []int, commitmentScript []byte,
+	feeRate int64) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, [][]byte, error) {
 	oldCommitTx := remoteCommit.CommitTx
 	remoteBalance := remoteCommit.LocalBalance
 	punishTx := wire.NewMsgTx(2)
@@ -172,7 +395,7 @@ func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btce
 
 	recvPkScript, err := GetP2TRpkScript(recvPubKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("GetP2TRpkScript failed: %v", err)
+		return nil, nil, nil, fmt.Errorf("GetP2TRpkScript failed: %v", err)
 	}
 
 	brc20OutputCount := 0
@@ -213,7 +436,7 @@ func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btce
 		// 当前实现要求构造 commitment 时已经准备好 BRC20 transfer inscription。
 		// punish 时先广播/花费 reveal tx 的输出，而不是在惩罚阶段临时 mint。
 		if len(remoteCommit.NextTxs) == 0 {
-			return nil, nil, fmt.Errorf("should construct brc20 transfer inscription before")
+			return nil, nil, nil, fmt.Errorf("should construct brc20 transfer inscription before")
 		}
 		for i, tx := range remoteCommit.NextTxs {
 			if i%2 == 0 {
@@ -233,7 +456,7 @@ func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btce
 		}
 
 		if len(plainSats) == 0 {
-			return nil, nil, fmt.Errorf("no enough plain sats to pay network fee")
+			return nil, nil, nil, fmt.Errorf("no enough plain sats to pay network fee")
 		}
 	}
 
@@ -248,6 +471,22 @@ func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btce
 		weightEstimate.AddNestedP2WSHInput(int64(len(commitmentScript)))
 		prevFetcher.AddPrevOut(*outPoint, plain.TxOut())
 	}
+	// 旧 commitment 中的 htlc 输出都是白聪，同样走 revocation 分支，
+	// 跟 plain sats 一起归集到最后的输出。
+	htlcScripts := make(map[wire.OutPoint][]byte)
+	for _, htlc := range remoteCommit.Htlcs {
+		txOut, ok := findHtlcOutput(oldCommitTx, htlc)
+		if !ok {
+			continue
+		}
+		feeValue += txOut.Value
+		outPoint := wire.NewOutPoint(&hash, uint32(htlc.OutputIndex))
+		txIn := wire.NewTxIn(outPoint, nil, nil)
+		punishTx.AddTxIn(txIn)
+		weightEstimate.AddWitnessInput(utils.ChannelHtlcPenaltyWitnessSize)
+		prevFetcher.AddPrevOut(*outPoint, txOut)
+		htlcScripts[*outPoint] = htlc.WitnessScript
+	}
 	weightEstimate.AddP2TROutput()
 	requiredFee1 := weightEstimate.Fee(feeRate)
 	if feeValue >= requiredFee1+330 {
@@ -257,23 +496,18 @@ func CreatePunishmentTx(remoteCommit *ChannelCommitment, revocationPrivKey *btce
 
 	if len(punishTx.TxOut) == 0 {
 		Log.Errorf("%s output too small to punish", oldCommitTx.TxID())
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 
-	sigHashes := txscript.NewTxSigHashes(punishTx, prevFetcher)
-	for i, txIn := range punishTx.TxIn {
-		preOut := prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
-		sigScript, err := txscript.RawTxInWitnessSignature(punishTx, sigHashes, i,
-			preOut.Value, commitmentScript, txscript.SigHashAll, revocationPrivKey)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to sign transaction: %v", err)
+	scripts := make([][]byte, 0, len(punishTx.TxIn))
+	for _, txIn := range punishTx.TxIn {
+		script := commitmentScript
+		if htlcScript, ok := htlcScripts[txIn.PreviousOutPoint]; ok {
+			script = htlcScript
 		}
-		// commitment script 的第二个 witness 元素选择 revocation 分支。
-		txIn.Witness = wire.TxWitness{sigScript, []byte{1}, commitmentScript}
+		scripts = append(scripts, script)
 	}
-
-	PrintJsonTx(punishTx, "punish TX")
-	return punishTx, prevFetcher, nil
+	return punishTx, prevFetcher, scripts, nil
 }
 
 func (p *Manager) CreateSweepTx(commit *ChannelCommitment, outputIndex []int,
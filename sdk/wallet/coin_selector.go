@@ -0,0 +1,335 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+)
+
+/*
+白聪的选币策略
+1. bnb：分支定界，寻找不需要找零的组合（多出的聪小于找零的成本，直接作为网络费），找不到时使用原来的规则
+2. consolidate：费率低的时候，多花费一些小的utxo，减少以后的utxo数量
+3. privacy：同一个地址的utxo一起花费，尽量不把不同地址的utxo放在同一个交易中，花费后地址不再有余额，避免地址重用
+4. legacy：原来的规则
+coin control：指定必须花费和不能花费的utxo
+*/
+
+const (
+	BNB_MAX_TRIES = 100000
+
+	DEFAULT_CONSOLIDATE_FEERATE    = 5  // sat/vB，低于这个费率时合并utxo
+	DEFAULT_CONSOLIDATE_MAX_INPUTS = 50 // 一次最多合并的utxo
+)
+
+var (
+	ErrInsufficientCoins     = errors.New("no enough plain sats")
+	ErrNoChangelessSelection = errors.New("no changeless selection")
+	ErrIncludedCoinNotFound  = errors.New("included utxo is not available")
+)
+
+// 候选的utxo
+type CoinCandidate struct {
+	OutPoint string
+	Value    int64 // 可用的白聪数量
+	PkScript []byte
+}
+
+type CoinSelectParams struct {
+	Target       int64 // 需要的聪数量，包括输出和不含输入的网络费
+	InputFee     int64 // 每增加一个输入增加的网络费
+	CostOfChange int64 // 增加找零输出的成本（找零输出的网络费+最小找零），多出的聪小于这个值时不需要找零
+	FeeRate      int64
+}
+
+// 指定必须花费和不能花费的utxo
+type CoinControl struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+func (p *CoinControl) hasInclude() bool {
+	return p != nil && len(p.Include) != 0
+}
+
+type CoinSelector interface {
+	Name() string
+	Select(coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error)
+}
+
+func selectWithFallback(fallback CoinSelector, coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	if fallback == nil {
+		return nil, ErrInsufficientCoins
+	}
+	return fallback.Select(coins, params)
+}
+
+func effectiveValue(coin *CoinCandidate, params *CoinSelectParams) int64 {
+	return coin.Value - params.InputFee
+}
+
+// 按照有效值从大到小排序，有效值不大于0的utxo不用
+func sortedByEffectiveValue(coins []*CoinCandidate, params *CoinSelectParams) []*CoinCandidate {
+	result := make([]*CoinCandidate, 0, len(coins))
+	for _, coin := range coins {
+		if effectiveValue(coin, params) > 0 {
+			result = append(result, coin)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Value != result[j].Value {
+			return result[i].Value > result[j].Value
+		}
+		return result[i].OutPoint < result[j].OutPoint
+	})
+	return result
+}
+
+func totalEffectiveValue(coins []*CoinCandidate, params *CoinSelectParams) int64 {
+	var total int64
+	for _, coin := range coins {
+		total += effectiveValue(coin, params)
+	}
+	return total
+}
+
+// 先扣除必须花费的utxo，再用selector选择剩下的部分
+func SelectCoins(selector CoinSelector, coins []*CoinCandidate,
+	params *CoinSelectParams, control *CoinControl) ([]*CoinCandidate, error) {
+
+	if control == nil {
+		return selector.Select(coins, params)
+	}
+	excluded := make(map[string]bool, len(control.Exclude))
+	for _, utxo := range control.Exclude {
+		excluded[utxo] = true
+	}
+	included := make(map[string]bool, len(control.Include))
+	for _, utxo := range control.Include {
+		if !excluded[utxo] {
+			included[utxo] = true
+		}
+	}
+	var must, rest []*CoinCandidate
+	for _, coin := range coins {
+		if excluded[coin.OutPoint] {
+			continue
+		}
+		if included[coin.OutPoint] {
+			must = append(must, coin)
+		} else {
+			rest = append(rest, coin)
+		}
+	}
+	// 指定的utxo不可用时不能忽略
+	if len(must) != len(included) {
+		return nil, ErrIncludedCoinNotFound
+	}
+	if len(must) == 0 {
+		return selector.Select(rest, params)
+	}
+
+	localParams := *params
+	localParams.Target -= totalEffectiveValue(must, params)
+	if localParams.Target <= 0 {
+		return must, nil
+	}
+	selected, err := selector.Select(rest, &localParams)
+	if err != nil {
+		return nil, err
+	}
+	return append(must, selected...), nil
+}
+
+// 从大到小选择，直到足够
+type GreedyCoinSelector struct{}
+
+func (p *GreedyCoinSelector) Name() string {
+	return COIN_SELECT_LEGACY
+}
+
+func (p *GreedyCoinSelector) Select(coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	var total int64
+	selected := make([]*CoinCandidate, 0)
+	for _, coin := range sortedByEffectiveValue(coins, params) {
+		selected = append(selected, coin)
+		total += effectiveValue(coin, params)
+		if total >= params.Target {
+			return selected, nil
+		}
+	}
+	return nil, ErrInsufficientCoins
+}
+
+// 分支定界，寻找有效值在 [Target, Target+CostOfChange] 之间，浪费最少的组合
+type BnBCoinSelector struct {
+	Fallback CoinSelector // 没有找到时使用，nil 返回 ErrNoChangelessSelection
+}
+
+func (p *BnBCoinSelector) Name() string {
+	return COIN_SELECT_BNB
+}
+
+func (p *BnBCoinSelector) Select(coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	selected, err := selectBnB(sortedByEffectiveValue(coins, params), params)
+	if err != nil && p.Fallback != nil {
+		return p.Fallback.Select(coins, params)
+	}
+	return selected, err
+}
+
+// pool 需要按照有效值从大到小排序
+func selectBnB(pool []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	n := len(pool)
+	values := make([]int64, n)
+	remaining := make([]int64, n+1)
+	for i := n - 1; i >= 0; i-- {
+		values[i] = effectiveValue(pool[i], params)
+		remaining[i] = remaining[i+1] + values[i]
+	}
+	target := params.Target
+	upper := target + params.CostOfChange
+	if remaining[0] < target {
+		return nil, ErrInsufficientCoins
+	}
+
+	var best []int
+	var bestWaste int64 = -1
+	current := make([]int, 0, n)
+	tries := 0
+	var search func(i int, sum int64)
+	search = func(i int, sum int64) {
+		tries++
+		if tries > BNB_MAX_TRIES || bestWaste == 0 || sum > upper {
+			return
+		}
+		if sum >= target {
+			waste := sum - target
+			if bestWaste < 0 || waste < bestWaste {
+				bestWaste = waste
+				best = append(best[:0], current...)
+			}
+			return
+		}
+		if i == n || sum+remaining[i] < target {
+			return
+		}
+		current = append(current, i)
+		search(i+1, sum+values[i])
+		current = current[:len(current)-1]
+		// 不选这个utxo时，跳过后面同样大小的utxo，它们的组合已经在上面的分支中搜索过
+		j := i + 1
+		for j < n && values[j] == values[i] {
+			j++
+		}
+		search(j, sum)
+	}
+	search(0, 0)
+
+	if bestWaste < 0 {
+		return nil, ErrNoChangelessSelection
+	}
+	result := make([]*CoinCandidate, 0, len(best))
+	for _, i := range best {
+		result = append(result, pool[i])
+	}
+	return result, nil
+}
+
+// 费率不超过 MaxFeeRate 时，从小到大花费最多 MaxInputs 个utxo
+type ConsolidateCoinSelector struct {
+	MaxFeeRate int64
+	MaxInputs  int
+	Fallback   CoinSelector
+}
+
+func (p *ConsolidateCoinSelector) Name() string {
+	return COIN_SELECT_CONSOLIDATE
+}
+
+func (p *ConsolidateCoinSelector) Select(coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	if params.FeeRate > p.MaxFeeRate {
+		return selectWithFallback(p.Fallback, coins, params)
+	}
+	pool := sortedByEffectiveValue(coins, params)
+	maxInputs := p.MaxInputs
+	if maxInputs <= 0 || maxInputs > len(pool) {
+		maxInputs = len(pool)
+	}
+	// 最大的那个用来保证足够，其他的从小到大
+	if maxInputs == 0 {
+		return nil, ErrInsufficientCoins
+	}
+	selected := []*CoinCandidate{pool[0]}
+	total := effectiveValue(pool[0], params)
+	for i := len(pool) - 1; i > 0 && len(selected) < maxInputs; i-- {
+		selected = append(selected, pool[i])
+		total += effectiveValue(pool[i], params)
+	}
+	if total >= params.Target {
+		return selected, nil
+	}
+	return selectWithFallback(p.Fallback, coins, params)
+}
+
+// 同一个锁定脚本的utxo作为一组，整组花费，并且优先只花费一组
+type PrivacyCoinSelector struct {
+	Fallback CoinSelector
+}
+
+func (p *PrivacyCoinSelector) Name() string {
+	return COIN_SELECT_PRIVACY
+}
+
+func (p *PrivacyCoinSelector) Select(coins []*CoinCandidate, params *CoinSelectParams) ([]*CoinCandidate, error) {
+	groups := make(map[string][]*CoinCandidate)
+	for _, coin := range coins {
+		if effectiveValue(coin, params) <= 0 {
+			continue
+		}
+		key := hex.EncodeToString(coin.PkScript)
+		groups[key] = append(groups[key], coin)
+	}
+	// 每组作为一个候选，有效值是组内的总和
+	groupCoins := make([]*CoinCandidate, 0, len(groups))
+	members := make(map[string][]*CoinCandidate, len(groups))
+	for key, group := range groups {
+		var value int64
+		for _, coin := range group {
+			value += coin.Value
+		}
+		value -= int64(len(group)-1) * params.InputFee
+		groupCoins = append(groupCoins, &CoinCandidate{OutPoint: key, Value: value})
+		members[key] = group
+	}
+	expand := func(selected []*CoinCandidate) []*CoinCandidate {
+		result := make([]*CoinCandidate, 0)
+		for _, group := range selected {
+			sorted := members[group.OutPoint]
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return sorted[i].OutPoint < sorted[j].OutPoint
+			})
+			result = append(result, sorted...)
+		}
+		return result
+	}
+
+	pool := sortedByEffectiveValue(groupCoins, params)
+	if selected, err := selectBnB(pool, params); err == nil {
+		return expand(selected), nil
+	}
+	// 能够满足需要的最小的一组
+	for i := len(pool) - 1; i >= 0; i-- {
+		if effectiveValue(pool[i], params) >= params.Target {
+			return expand(pool[i : i+1]), nil
+		}
+	}
+	if p.Fallback != nil {
+		selected, err := p.Fallback.Select(groupCoins, params)
+		if err != nil {
+			return nil, err
+		}
+		return expand(selected), nil
+	}
+	return nil, ErrInsufficientCoins
+}
@@ -0,0 +1,220 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+func testCoin(outpoint string, value int64, pkScript string) *CoinCandidate {
+	return &CoinCandidate{OutPoint: outpoint, Value: value, PkScript: []byte(pkScript)}
+}
+
+func coinOutPoints(coins []*CoinCandidate) string {
+	result := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		result = append(result, coin.OutPoint)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestCoinSelectors(t *testing.T) {
+	basic := []*CoinCandidate{
+		testCoin("a:0", 1000, "A"),
+		testCoin("b:0", 600, "A"),
+		testCoin("c:0", 400, "A"),
+		testCoin("d:0", 250, "A"),
+	}
+	consolidate := []*CoinCandidate{
+		testCoin("a:0", 10000, "A"),
+		testCoin("b:0", 100, "A"),
+		testCoin("c:0", 200, "A"),
+		testCoin("d:0", 300, "A"),
+		testCoin("e:0", 5000, "A"),
+	}
+	privacy := []*CoinCandidate{
+		testCoin("a:1", 400, "A"),
+		testCoin("a:0", 300, "A"),
+		testCoin("b:0", 1000, "B"),
+		testCoin("c:0", 650, "C"),
+	}
+
+	cases := []struct {
+		name     string
+		selector CoinSelector
+		coins    []*CoinCandidate
+		params   CoinSelectParams
+		expected string
+		err      error
+	}{
+		{"greedy", &GreedyCoinSelector{}, basic,
+			CoinSelectParams{Target: 1500}, "a:0,b:0", nil},
+		{"greedy insufficient", &GreedyCoinSelector{}, basic,
+			CoinSelectParams{Target: 5000}, "", ErrInsufficientCoins},
+		{"bnb exact", &BnBCoinSelector{}, basic,
+			CoinSelectParams{Target: 850, CostOfChange: 10}, "b:0,d:0", nil},
+		{"bnb within cost of change", &BnBCoinSelector{}, basic,
+			CoinSelectParams{Target: 990, CostOfChange: 20}, "a:0", nil},
+		{"bnb input fee", &BnBCoinSelector{}, basic,
+			CoinSelectParams{Target: 980, InputFee: 10}, "b:0,c:0", nil},
+		{"bnb no match", &BnBCoinSelector{}, basic,
+			CoinSelectParams{Target: 700, CostOfChange: 10}, "", ErrNoChangelessSelection},
+		{"bnb fallback", &BnBCoinSelector{Fallback: &GreedyCoinSelector{}}, basic,
+			CoinSelectParams{Target: 700, CostOfChange: 10}, "a:0", nil},
+		{"bnb insufficient", &BnBCoinSelector{}, basic,
+			CoinSelectParams{Target: 5000}, "", ErrInsufficientCoins},
+		{"consolidate low fee", &ConsolidateCoinSelector{MaxFeeRate: 5, MaxInputs: 3}, consolidate,
+			CoinSelectParams{Target: 1000, FeeRate: 2}, "a:0,b:0,c:0", nil},
+		{"consolidate high fee", &ConsolidateCoinSelector{MaxFeeRate: 5, MaxInputs: 3, Fallback: &GreedyCoinSelector{}},
+			consolidate, CoinSelectParams{Target: 1000, FeeRate: 10}, "a:0", nil},
+		{"consolidate high fee without fallback", &ConsolidateCoinSelector{MaxFeeRate: 5}, consolidate,
+			CoinSelectParams{Target: 1000, FeeRate: 10}, "", ErrInsufficientCoins},
+		{"privacy exact group", &PrivacyCoinSelector{}, privacy,
+			CoinSelectParams{Target: 700}, "a:0,a:1", nil},
+		{"privacy smallest group", &PrivacyCoinSelector{}, privacy,
+			CoinSelectParams{Target: 800}, "b:0", nil},
+		{"privacy fallback", &PrivacyCoinSelector{Fallback: &GreedyCoinSelector{}}, privacy,
+			CoinSelectParams{Target: 2000}, "b:0,a:0,a:1,c:0", nil},
+		{"privacy insufficient", &PrivacyCoinSelector{}, privacy,
+			CoinSelectParams{Target: 2000}, "", ErrInsufficientCoins},
+	}
+
+	for _, c := range cases {
+		params := c.params
+		selected, err := c.selector.Select(c.coins, &params)
+		if err != c.err {
+			t.Fatalf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+		if got := coinOutPoints(selected); got != c.expected {
+			t.Fatalf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestSelectCoinsWithCoinControl(t *testing.T) {
+	coins := []*CoinCandidate{
+		testCoin("a:0", 1000, "A"),
+		testCoin("b:0", 600, "A"),
+		testCoin("c:0", 400, "A"),
+		testCoin("d:0", 250, "A"),
+	}
+
+	cases := []struct {
+		name     string
+		control  *CoinControl
+		target   int64
+		expected string
+	}{
+		{"no control", nil, 900, "a:0"},
+		{"exclude", &CoinControl{Exclude: []string{"a:0"}}, 900, "b:0,c:0"},
+		{"include and exclude", &CoinControl{Include: []string{"d:0"}, Exclude: []string{"a:0"}}, 900, "d:0,b:0,c:0"},
+		{"include enough", &CoinControl{Include: []string{"c:0", "d:0"}}, 500, "c:0,d:0"},
+		{"exclude wins", &CoinControl{Include: []string{"a:0"}, Exclude: []string{"a:0"}}, 500, "b:0"},
+	}
+	for _, c := range cases {
+		selected, err := SelectCoins(&GreedyCoinSelector{}, coins, &CoinSelectParams{Target: c.target}, c.control)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := coinOutPoints(selected); got != c.expected {
+			t.Fatalf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestManagerCoinSelection(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+
+	if manager.GetCoinSelection() != COIN_SELECT_BNB {
+		t.Fatalf("default coin selection should be bnb")
+	}
+	manager.cfg.Wallet.CoinSelection = COIN_SELECT_PRIVACY
+	if _, ok := manager.getCoinSelector().(*PrivacyCoinSelector); !ok {
+		t.Fatalf("coin selection from config is not used")
+	}
+	if err := manager.SetCoinSelection("random"); err == nil {
+		t.Fatalf("invalid coin selection should fail")
+	}
+	if err := manager.SetCoinSelection(COIN_SELECT_LEGACY); err != nil {
+		t.Fatal(err)
+	}
+	if manager.getCoinSelector() != nil {
+		t.Fatalf("legacy coin selection should use the old rules")
+	}
+
+	excluded := map[string]bool{"a:0": true}
+	merged := manager.coinControlExcluded(excluded, &CoinControl{Exclude: []string{"b:0"}})
+	if !merged["a:0"] || !merged["b:0"] || len(excluded) != 1 {
+		t.Fatalf("unexpected excluded utxos %v %v", merged, excluded)
+	}
+
+	// legacy 也要花费指定的utxo
+	coins := []*CoinCandidate{
+		testCoin("a:0", 1000, "A"),
+		testCoin("b:0", 600, "A"),
+	}
+	params := &CoinSelectParams{Target: 500}
+	selected, err := manager.selectCoins(coins, params, nil)
+	if err != nil || selected != nil {
+		t.Fatalf("legacy without coin control should use the old rules")
+	}
+	selected, err = manager.selectCoins(coins, params, &CoinControl{Include: []string{"b:0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := coinOutPoints(selected); got != "b:0" {
+		t.Fatalf("expected b:0, got %s", got)
+	}
+	_, err = manager.selectCoins(coins, params, &CoinControl{Include: []string{"c:0"}})
+	if err == nil {
+		t.Fatalf("unavailable included utxo should fail")
+	}
+}
+
+func TestSelectAssetCoins(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+
+	if v, ok := assetCoinValue(indexer.NewDecimal(5, 2), indexer.NewDecimal(1, 2)); !ok || v != 5 {
+		t.Fatalf("unexpected asset value %d %v", v, ok)
+	}
+	if _, ok := assetCoinValue(indexer.NewDecimal(5, 1), indexer.NewDecimal(1, 2)); ok {
+		t.Fatalf("different precision should use the old rules")
+	}
+
+	coins := []*CoinCandidate{
+		testCoin("a:0", 1000, "A"),
+		testCoin("b:0", 600, "A"),
+		testCoin("c:0", 400, "B"),
+		testCoin("d:0", 300, "B"),
+	}
+	// 资产不需要找零
+	selected, err := manager.selectAssetCoins(coins, indexer.NewDecimal(700, 0), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := coinOutPoints(selected); got != "c:0,d:0" {
+		t.Fatalf("expected c:0,d:0, got %s", got)
+	}
+	// L1 不合并资产utxo
+	manager.cfg.Wallet.CoinSelection = COIN_SELECT_CONSOLIDATE
+	selected, _ = manager.selectAssetCoins(coins, indexer.NewDecimal(1000, 0), false, nil)
+	if got := coinOutPoints(selected); got != "a:0" {
+		t.Fatalf("expected a:0, got %s", got)
+	}
+	selected, _ = manager.selectAssetCoins(coins, indexer.NewDecimal(1000, 0), true, nil)
+	if len(selected) != 4 {
+		t.Fatalf("satsnet should consolidate asset utxos, got %s", coinOutPoints(selected))
+	}
+	selected, err = manager.selectAssetCoins(coins, indexer.NewDecimal(1000, 0), true,
+		&CoinControl{Exclude: []string{"a:0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := coinOutPoints(selected); got != "b:0,d:0,c:0" {
+		t.Fatalf("expected b:0,d:0,c:0, got %s", got)
+	}
+}
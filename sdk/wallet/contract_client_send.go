@@ -62,8 +62,9 @@ func (p *Manager) coSendOrdxWithStubWithMaxConfirmedInputHeight(localWallet comm
 		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
 	}
 	assetName := GetAssetName(tickerInfo)
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	channelID, err := p.GetChannelAddress()
@@ -167,8 +168,9 @@ func (p *Manager) coBatchSendV3WithMaxConfirmedInputHeight(localWallet common.Wa
 		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
 	}
 	assetName := GetAssetName(tickerInfo)
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 	channelID := ExtractChannelId(contractURL)
 	if channelID == "" {
@@ -180,7 +182,6 @@ func (p *Manager) coBatchSendV3WithMaxConfirmedInputHeight(localWallet common.Wa
 		prevFetcher *txscript.MultiPrevOutFetcher
 		fee         int64
 		inscribes   []*InscribeResv
-		err         error
 	)
 	switch asset.Protocol {
 	case "":
@@ -265,8 +266,9 @@ func (p *Manager) coGenerateStubUtxos(localWallet common.Wallet, n int, feeRate
 	if err != nil {
 		return "", 0, err
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	dest := &SendAssetInfo{
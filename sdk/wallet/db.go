@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcwallet/snacl"
 	db "github.com/sat20-labs/indexer/common"
@@ -16,17 +17,25 @@ import (
 )
 
 const (
-	DB_KEY_STATUS = "wallet-status"
-	DB_KEY_WALLET = "wallet-id-"
+	DB_KEY_STATUS       = "wallet-status"
+	DB_KEY_WALLET       = "wallet-id-"
+	DB_KEY_UNLOCK_STATE = "wallet-unlock-state"
 
 	DB_KEY_RESV        = "resv-"
 	DB_KEY_TICKER_INFO = "t-"
 	DB_KEY_CHANNEL     = "c-"
+	DB_KEY_PEER_NODE   = "pn-" // pn-nodeId
 
 	DB_KEY_UTXO          = "u-"  // u-network-address-utxo
 	DB_KEY_LOCKEDUTXO    = "l-"  // l-network-address-utxo
 	DB_KEY_LOCK_LASTTIME = "lt-" // lt-network-address
 
+	DB_KEY_LABEL      = "lb-" // lb-type-ref
+	DB_KEY_TX_HISTORY = "th-" // th-layer-address-txid
+
+	DB_KEY_LIQUIDITY_POLICY   = "lqp-" // lqp-assetName
+	DB_KEY_LIQUIDITY_DECISION = "lqd-" // lqd-id
+
 	DB_KEY_TEMPLATE_CONTRACT        = "tc-"    // tc-url-
 	DB_KEY_TC_INVOKE_HISTORY        = "tch-"   // tch-url-id
 	DB_KEY_TC_INVOKE_ITEM           = "tci-"   // tci-url-inutxo -> id
@@ -95,9 +104,10 @@ type STPClientStatus struct {
 }
 
 const (
-	WALLET_TYPE_MNEMONIC int = 0
-	WALLET_TYPE_PRIVKEY  int = 1
-	WALLET_TYPE_MONITOR  int = 2
+	WALLET_TYPE_MNEMONIC  int = 0
+	WALLET_TYPE_PRIVKEY   int = 1
+	WALLET_TYPE_MONITOR   int = 2
+	WALLET_TYPE_WATCHONLY int = 3
 )
 
 type WalletInDB struct {
@@ -105,10 +115,12 @@ type WalletInDB struct {
 	Mnemonic     []byte // 加密后的数据
 	Salt         []byte
 	Accounts     int // 用户启用的子账户数量
-	Type         int // 0: 默认钱包，有助记词；1: 私钥钱包； 2: 观察钱包
+	Type         int // 0: 默认钱包，有助记词；1: 私钥钱包； 2: 观察钱包； 3: 扩展公钥观察钱包，保存的是描述符
 	Name         string
 	AccountNames map[uint32]string
 	AccountDIDs  map[uint32]string
+	Kdf          *KdfParams       // 密钥派生参数，nil: 旧的记录，使用snacl，Salt中保存snacl的参数
+	Bip85        *Bip85Derivation // 从主钱包派生的子钱包，nil: 独立的钱包
 }
 
 func getWalletDBKey(id int64) string {
@@ -578,30 +590,18 @@ func (p *Manager) saveMnemonic(mn, password string, wallet common.Wallet) error
 }
 
 func (p *Manager) saveSecret(secret, password string, ty int, w common.Wallet) error {
-	key, err := p.newSnaclKey(password)
-	if err != nil {
-		Log.Errorf("newSnaclKey failed. %v", err)
-		return err
-	}
-
-	en, err := key.Encrypt([]byte(secret))
-	if err != nil {
-		Log.Errorf("Encrypt failed. %v", err)
-		return err
-	}
-
-	salt := key.Marshal()
-
 	wallet := WalletInDB{
 		Id:           w.GetId(),
-		Mnemonic:     en,
-		Salt:         salt,
 		Accounts:     1,
 		Type:         ty,
 		Name:         defaultWalletName(len(p.walletInfoMap)),
 		AccountNames: map[uint32]string{0: defaultAccountName(0)},
 		AccountDIDs:  make(map[uint32]string),
 	}
+	err := p.encryptWalletSecret(secret, password, &wallet)
+	if err != nil {
+		return err
+	}
 
 	err = saveWallet(p.db, &wallet)
 	if err != nil {
@@ -616,23 +616,11 @@ func (p *Manager) saveSecret(secret, password string, ty int, w common.Wallet) e
 }
 
 func (p *Manager) saveWalletSecretWithPassword(mn, password string, wallet *WalletInDB) error {
-	key, err := p.newSnaclKey(password)
+	err := p.encryptWalletSecret(mn, password, wallet)
 	if err != nil {
-		Log.Errorf("NewSecretKey failed. %v", err)
 		return err
 	}
 
-	en, err := key.Encrypt([]byte(mn))
-	if err != nil {
-		Log.Errorf("Encrypt failed. %v", err)
-		return err
-	}
-
-	salt := key.Marshal()
-
-	wallet.Mnemonic = en
-	wallet.Salt = salt
-
 	err = saveWallet(p.db, wallet)
 	if err != nil {
 		return err
@@ -641,20 +629,19 @@ func (p *Manager) saveWalletSecretWithPassword(mn, password string, wallet *Wall
 	return nil
 }
 
+// 所有的密码检查都经过这里，连续失败后锁定
 func (p *Manager) loadWalletSecret(w *WalletInfo, password string) (string, error) {
-	key, err := p.restoreSnaclKey(w.Salt, password)
-	if err != nil {
-		Log.Errorf("restoreSnaclKey failed. %v", err)
+	if err := p.checkUnlockAllowed(time.Now()); err != nil {
 		return "", err
 	}
-
-	mnemonic, err := key.Decrypt(w.Mnemonic)
+	secret, err := p.decryptWalletSecret(&w.WalletInDB, password)
+	p.recordUnlockResult(err, time.Now())
 	if err != nil {
-		Log.Errorf("Decrypt failed. %v", err)
+		Log.Errorf("decryptWalletSecret failed. %v", err)
 		return "", err
 	}
 
-	return string(mnemonic), nil
+	return secret, nil
 }
 
 func (p *Manager) restoreSnaclKey(salt []byte, password string) (*snacl.SecretKey, error) {
@@ -0,0 +1,279 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+/*
+输出描述符（BIP380/381/382/386），只支持单签的账户级别扩展公钥:
+  tr([73c5da0a/86'/0'/0']xpub.../0/*)
+  wpkh([73c5da0a/84'/0'/0']xpub.../<0;1>/*)
+  sh(wpkh([73c5da0a/49'/0'/0']xpub.../0/*))
+  pkh([73c5da0a/44'/0'/0']xpub.../0/*)
+也可以直接使用扩展公钥 xpub...，这时作为p2tr地址的账户公钥。
+地址 index 对应 xpub/0/index，找零地址对应 xpub/1/index，跟 InternalWallet 的子账户一致。
+*/
+
+type AccountDescriptor struct {
+	AddrType    string
+	AccountKey  *hdkeychain.ExtendedKey // 账户扩展公钥
+	Fingerprint []byte                  // 主密钥指纹，没有时是账户公钥自己的指纹
+	OriginPath  []uint32                // 主密钥到账户公钥的路径，可能为空
+}
+
+var descriptorWrappers = []struct {
+	prefix   string
+	suffix   string
+	addrType string
+}{
+	{"tr(", ")", ADDRESS_TYPE_P2TR},
+	{"wpkh(", ")", ADDRESS_TYPE_P2WPKH},
+	{"sh(wpkh(", "))", ADDRESS_TYPE_P2SH_P2WPKH},
+	{"pkh(", ")", ADDRESS_TYPE_P2PKH},
+}
+
+const descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+const descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+func descriptorPolyMod(c uint64, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// BIP380 描述符校验和
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls := uint64(0)
+	clsCount := 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("invalid character %q in descriptor", ch)
+		}
+		c = descriptorPolyMod(c, uint64(pos&31))
+		cls = cls*3 + uint64(pos>>5)
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolyMod(c, cls)
+			cls = 0
+			clsCount = 0
+		}
+	}
+	if clsCount > 0 {
+		c = descriptorPolyMod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	result := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		result[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(result), nil
+}
+
+func parseDerivationPath(path string) ([]uint32, error) {
+	result := make([]uint32, 0)
+	if path == "" {
+		return result, nil
+	}
+	for _, element := range strings.Split(path, "/") {
+		hardened := false
+		if strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h") ||
+			strings.HasSuffix(element, "H") {
+			hardened = true
+			element = element[:len(element)-1]
+		}
+		value, err := strconv.ParseUint(element, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path element %s", element)
+		}
+		if hardened {
+			value += hdkeychain.HardenedKeyStart
+		}
+		result = append(result, uint32(value))
+	}
+	return result, nil
+}
+
+// 解析描述符或者扩展公钥
+func ParseAccountDescriptor(desc string, params *chaincfg.Params) (*AccountDescriptor, error) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return nil, fmt.Errorf("empty descriptor")
+	}
+
+	if i := strings.LastIndex(desc, "#"); i >= 0 {
+		checksum, err := DescriptorChecksum(desc[:i])
+		if err != nil {
+			return nil, err
+		}
+		if checksum != desc[i+1:] {
+			return nil, fmt.Errorf("invalid descriptor checksum %s, expected %s", desc[i+1:], checksum)
+		}
+		desc = desc[:i]
+	}
+
+	result := &AccountDescriptor{AddrType: ADDRESS_TYPE_P2TR}
+	keyExpr := desc
+	if strings.Contains(desc, "(") {
+		found := false
+		for _, w := range descriptorWrappers {
+			if strings.HasPrefix(desc, w.prefix) && strings.HasSuffix(desc, w.suffix) {
+				keyExpr = desc[len(w.prefix) : len(desc)-len(w.suffix)]
+				result.AddrType = w.addrType
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported descriptor %s", desc)
+		}
+	}
+
+	// key origin
+	if strings.HasPrefix(keyExpr, "[") {
+		end := strings.Index(keyExpr, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid key origin in %s", keyExpr)
+		}
+		origin := keyExpr[1:end]
+		keyExpr = keyExpr[end+1:]
+		fp, path, _ := strings.Cut(origin, "/")
+		fingerprint, err := hex.DecodeString(fp)
+		if err != nil || len(fingerprint) != 4 {
+			return nil, fmt.Errorf("invalid fingerprint %s", fp)
+		}
+		result.Fingerprint = fingerprint
+		result.OriginPath, err = parseDerivationPath(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 只支持账户公钥，后面可以跟 /0/* 或者 /<0;1>/*
+	xpub, suffix, _ := strings.Cut(keyExpr, "/")
+	switch suffix {
+	case "", "0/*", "<0;1>/*":
+	default:
+		return nil, fmt.Errorf("unsupported derivation %s, only /0/* or /<0;1>/* is supported", suffix)
+	}
+
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended public key. %v", err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("private extended key is not allowed in watch-only wallet")
+	}
+	if !key.IsForNet(params) {
+		return nil, fmt.Errorf("extended public key is not for %s", params.Name)
+	}
+	result.AccountKey = key
+
+	if result.Fingerprint == nil {
+		// 没有key origin，以账户公钥作为根
+		pubKey, err := key.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		hash := btcutil.Hash160(pubKey.SerializeCompressed())
+		result.Fingerprint = hash[:4]
+		result.OriginPath = []uint32{}
+	}
+	return result, nil
+}
+
+// psbt中使用的指纹格式
+func (p *AccountDescriptor) FingerprintUint32() uint32 {
+	return binary.LittleEndian.Uint32(p.Fingerprint)
+}
+
+func (p *AccountDescriptor) String() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	sb.WriteString(hex.EncodeToString(p.Fingerprint))
+	if len(p.OriginPath) != 0 {
+		sb.WriteString(strings.TrimPrefix(DerivationPathToString(p.OriginPath), "m"))
+	}
+	sb.WriteString("]")
+	sb.WriteString(p.AccountKey.String())
+	sb.WriteString("/<0;1>/*")
+	keyExpr := sb.String()
+
+	var desc string
+	for _, w := range descriptorWrappers {
+		if w.addrType == p.AddrType {
+			desc = w.prefix + keyExpr + w.suffix
+			break
+		}
+	}
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return desc
+	}
+	return desc + "#" + checksum
+}
+
+// 导出指定地址类型的账户描述符，用于创建观察钱包
+func (p *InternalWallet) GetAccountDescriptor(addrType string) (string, error) {
+	if p.masterkey == nil {
+		return "", fmt.Errorf("can't export descriptor without master key")
+	}
+	if !IsSupportedAddressType(addrType) {
+		return "", fmt.Errorf("unsupported address type %s", addrType)
+	}
+	fingerprint := make([]byte, 4)
+	binary.LittleEndian.PutUint32(fingerprint, p.GetMasterFingerprint())
+
+	p.mutex.Lock()
+	purposeKey, err := p.getPurposeKey(getPurposeFromAddrType(addrType))
+	p.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	accountKey, err := generateAccountKey2(purposeKey, 0)
+	if err != nil {
+		return "", err
+	}
+	accountPubKey, err := accountKey.Neuter()
+	if err != nil {
+		return "", err
+	}
+
+	desc := &AccountDescriptor{
+		AddrType:    addrType,
+		AccountKey:  accountPubKey,
+		Fingerprint: fingerprint,
+		OriginPath:  walletDerivationPath(addrType, 0, 0)[:3],
+	}
+	return desc.String(), nil
+}
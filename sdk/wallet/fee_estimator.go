@@ -0,0 +1,203 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+L1 费率估算
+1. 按确认目标分成几种模式：fast（下一个区块），normal（3个区块左右），economy（6个区块以上），custom（指定费率）
+2. 费率来源可以替换：索引器（mempool的统计），静态费率表，本地测试网络
+3. 所有的费率都限制在 [最低转发费率, 费率上限] 之间，指定的费率超过上限时拒绝构造交易
+
+所有接受 feeRate 参数的接口，feeRate 的约定：
+大于0 是指定的费率(custom)，0 使用默认模式(normal)，负数是 FEE_TARGET_* 确认目标
+*/
+
+const (
+	FEE_MODE_FAST    = "fast"
+	FEE_MODE_NORMAL  = "normal"
+	FEE_MODE_ECONOMY = "economy"
+	FEE_MODE_CUSTOM  = "custom"
+
+	FEE_TARGET_FAST    int64 = -1
+	FEE_TARGET_NORMAL  int64 = -2
+	FEE_TARGET_ECONOMY int64 = -3
+
+	FEE_ESTIMATOR_INDEXER = "indexer"
+	FEE_ESTIMATOR_STATIC  = "static"
+	FEE_ESTIMATOR_LOCAL   = "local"
+
+	DEFAULT_MIN_RELAY_FEE     int64 = 1
+	DEFAULT_MAX_FEE_RATE      int64 = 1000
+	DEFAULT_FALLBACK_FEE      int64 = 10
+	FEE_ESTIMATE_REFRESH_TIME       = 3 * 60 // 秒
+)
+
+// sat/vB
+type FeeEstimates struct {
+	Fast    int64  `json:"fast"`
+	Normal  int64  `json:"normal"`
+	Economy int64  `json:"economy"`
+	Source  string `json:"source"`
+}
+
+type FeeEstimator interface {
+	Name() string
+	EstimateFees() (*FeeEstimates, error)
+}
+
+// 确认目标对应的费率模式
+func FeeTargetFromMode(mode string, customFeeRate int64) (int64, error) {
+	switch mode {
+	case FEE_MODE_FAST:
+		return FEE_TARGET_FAST, nil
+	case "", FEE_MODE_NORMAL:
+		return FEE_TARGET_NORMAL, nil
+	case FEE_MODE_ECONOMY:
+		return FEE_TARGET_ECONOMY, nil
+	case FEE_MODE_CUSTOM:
+		if customFeeRate <= 0 {
+			return 0, fmt.Errorf("custom fee rate should be positive")
+		}
+		return customFeeRate, nil
+	default:
+		return 0, fmt.Errorf("invalid fee mode %s", mode)
+	}
+}
+
+func (p *FeeEstimates) rate(target int64) (int64, error) {
+	switch target {
+	case 0, FEE_TARGET_NORMAL:
+		return p.Normal, nil
+	case FEE_TARGET_FAST:
+		return p.Fast, nil
+	case FEE_TARGET_ECONOMY:
+		return p.Economy, nil
+	default:
+		return 0, fmt.Errorf("invalid fee target %d", target)
+	}
+}
+
+// 保证 fast >= normal >= economy，并且都在限制之内
+func (p *FeeEstimates) normalize(minRelayFee, maxFeeRate int64) {
+	clamp := func(rate int64) int64 {
+		if rate < minRelayFee {
+			return minRelayFee
+		}
+		if rate > maxFeeRate {
+			return maxFeeRate
+		}
+		return rate
+	}
+	p.Fast = clamp(p.Fast)
+	p.Normal = clamp(p.Normal)
+	p.Economy = clamp(p.Economy)
+	if p.Normal > p.Fast {
+		p.Normal = p.Fast
+	}
+	if p.Economy > p.Normal {
+		p.Economy = p.Normal
+	}
+}
+
+// 从一组费率中得到各个模式的费率：最高的是fast，最低的是economy，中间的是normal
+func feeEstimatesFromRates(rates []int64, source string) (*FeeEstimates, error) {
+	valid := make([]int64, 0, len(rates))
+	for _, rate := range rates {
+		if rate > 0 {
+			valid = append(valid, rate)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("no fee rate from %s", source)
+	}
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i] > valid[j]
+	})
+	return &FeeEstimates{
+		Fast:    valid[0],
+		Normal:  valid[len(valid)/2],
+		Economy: valid[len(valid)-1],
+		Source:  source,
+	}, nil
+}
+
+// 索引器提供的费率
+type IndexerFeeEstimator struct {
+	client IndexerRPCClient
+}
+
+func NewIndexerFeeEstimator(client IndexerRPCClient) *IndexerFeeEstimator {
+	return &IndexerFeeEstimator{client: client}
+}
+
+func (p *IndexerFeeEstimator) Name() string {
+	return FEE_ESTIMATOR_INDEXER
+}
+
+func (p *IndexerFeeEstimator) EstimateFees() (*FeeEstimates, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("indexer client is not set")
+	}
+	if c, ok := p.client.(interface{ GetFeeRates() ([]int64, error) }); ok {
+		rates, err := c.GetFeeRates()
+		if err == nil {
+			return feeEstimatesFromRates(rates, p.Name())
+		}
+		Log.Warningf("GetFeeRates failed. %v", err)
+	}
+	return feeEstimatesFromRates([]int64{p.client.GetFeeRate()}, p.Name())
+}
+
+// 静态费率表，用于没有可靠费率来源的环境
+type StaticFeeEstimator struct {
+	Fees FeeEstimates
+}
+
+func NewStaticFeeEstimator(fees map[string]int64) *StaticFeeEstimator {
+	estimator := &StaticFeeEstimator{
+		Fees: FeeEstimates{
+			Fast:    DEFAULT_FALLBACK_FEE,
+			Normal:  DEFAULT_FALLBACK_FEE,
+			Economy: DEFAULT_FALLBACK_FEE,
+		},
+	}
+	if rate, ok := fees[FEE_MODE_FAST]; ok {
+		estimator.Fees.Fast = rate
+	}
+	if rate, ok := fees[FEE_MODE_NORMAL]; ok {
+		estimator.Fees.Normal = rate
+	}
+	if rate, ok := fees[FEE_MODE_ECONOMY]; ok {
+		estimator.Fees.Economy = rate
+	}
+	return estimator
+}
+
+func (p *StaticFeeEstimator) Name() string {
+	return FEE_ESTIMATOR_STATIC
+}
+
+func (p *StaticFeeEstimator) EstimateFees() (*FeeEstimates, error) {
+	fees := p.Fees
+	fees.Source = p.Name()
+	return &fees, nil
+}
+
+// 测试网络和本地网络，区块不拥挤，使用最低费率
+type LocalFeeEstimator struct{}
+
+func (p *LocalFeeEstimator) Name() string {
+	return FEE_ESTIMATOR_LOCAL
+}
+
+func (p *LocalFeeEstimator) EstimateFees() (*FeeEstimates, error) {
+	return &FeeEstimates{
+		Fast:    DEFAULT_MIN_RELAY_FEE,
+		Normal:  DEFAULT_MIN_RELAY_FEE,
+		Economy: DEFAULT_MIN_RELAY_FEE,
+		Source:  p.Name(),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+func TestFeeEstimatesFromRates(t *testing.T) {
+	fees, err := feeEstimatesFromRates([]int64{5, 0, 30, 12}, FEE_ESTIMATOR_INDEXER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.Fast != 30 || fees.Normal != 12 || fees.Economy != 5 {
+		t.Fatalf("unexpected fees %+v", fees)
+	}
+	if _, err := feeEstimatesFromRates([]int64{0}, FEE_ESTIMATOR_INDEXER); err == nil {
+		t.Fatal("empty rates should fail")
+	}
+
+	fees = &FeeEstimates{Fast: 5000, Normal: 8000, Economy: 0}
+	fees.normalize(2, 1000)
+	if fees.Fast != 1000 || fees.Normal != 1000 || fees.Economy != 2 {
+		t.Fatalf("unexpected normalized fees %+v", fees)
+	}
+}
+
+func TestResolveFeeRate(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+	manager.cfg.Wallet.MinRelayFee = 2
+	manager.cfg.Wallet.MaxFeeRate = 100
+	manager.cfg.Wallet.FeeEstimator = FEE_ESTIMATOR_STATIC
+	manager.cfg.Wallet.StaticFees = map[string]int64{
+		FEE_MODE_FAST: 200, FEE_MODE_NORMAL: 20, FEE_MODE_ECONOMY: 1,
+	}
+
+	cases := []struct {
+		target   int64
+		expected int64
+	}{
+		{0, 20},
+		{FEE_TARGET_NORMAL, 20},
+		{FEE_TARGET_FAST, 100},
+		{FEE_TARGET_ECONOMY, 2},
+		{1, 2},
+		{50, 50},
+	}
+	for _, c := range cases {
+		rate, err := manager.ResolveFeeRate(c.target)
+		if err != nil || rate != c.expected {
+			t.Fatalf("target %d: rate %d, expected %d, %v", c.target, rate, c.expected, err)
+		}
+	}
+	if _, err := manager.ResolveFeeRate(101); err == nil {
+		t.Fatal("fee rate above the cap should be rejected")
+	}
+	if _, err := manager.ResolveFeeRate(-10); err == nil {
+		t.Fatal("invalid target should be rejected")
+	}
+	if manager.GetFeeRate() != 20 || manager.GetFeeRateByTarget(-10) != 20 {
+		t.Fatal("default fee rate should be normal")
+	}
+
+	manager.SetFeeEstimator(&LocalFeeEstimator{})
+	if fees := manager.GetFeeEstimates(); fees.Source != FEE_ESTIMATOR_LOCAL || fees.Fast != 2 {
+		t.Fatalf("unexpected fees %+v", fees)
+	}
+
+	target, err := FeeTargetFromMode(FEE_MODE_CUSTOM, 7)
+	if err != nil || target != 7 {
+		t.Fatalf("custom target %d, %v", target, err)
+	}
+	if _, err := FeeTargetFromMode("slow", 0); err == nil {
+		t.Fatal("invalid mode should fail")
+	}
+}
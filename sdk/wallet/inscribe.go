@@ -322,7 +322,7 @@ func (builder *InscriptionBuilder) buildEmptyRevealTx(revealOutValue, revealFeeR
 	tx := wire.NewMsgTx(DefaultTxVersion)
 
 	in := wire.NewTxIn(&wire.OutPoint{Index: 0}, nil, nil)
-	in.Sequence = DefaultSequenceNum
+	in.Sequence = DefaultSequenceNum // 支持RBF加速
 	tx.AddTxIn(in)
 	scriptPubKey, err := AddrToPkScript(builder.RevealAddr, builder.Network)
 	if err != nil {
@@ -427,7 +427,7 @@ func (builder *InscriptionBuilder) buildCommitTxV2(commitTxPrevOutputList PrevOu
 		builder.CommitTxPrevOutputFetcher.AddPrevOut(*outPoint, txOut)
 
 		in := wire.NewTxIn(outPoint, nil, nil)
-		in.Sequence = DefaultSequenceNum
+		in.Sequence = DefaultSequenceNum // 支持RBF加速
 		tx.AddTxIn(in)
 		switch builder.ScriptType {
 		case SCRIPT_TYPE_TAPROOTKEYSPEND:
@@ -54,6 +54,7 @@ func NewManager(cfg *common.Config, db db.KVDB) *Manager {
 		l1IndexerClient:      l1IndexerMgr,
 		l2IndexerClient:      l2IndexerMgr,
 		channelBackupHandler: noopChannelBackupHandler{},
+		scbHandler:           noopStaticChannelBackupHandler{},
 		bInited:              false,
 	}
 
@@ -129,6 +130,7 @@ func (p *Manager) Stop() {
 		p.btcLuckyMiner.Stop()
 	}
 	p.stopActionMonitor()
+	p.stopSignerServer()
 	p.l1IndexerClient.Stop()
 	p.l2IndexerClient.Stop()
 }
@@ -142,6 +144,9 @@ func (p *Manager) Close() {
 func (p *Manager) CreateWallet(password string) (int64, string, error) {
 	releaseRGB11Scope := p.beginRGB11ScopeChange()
 	defer releaseRGB11Scope()
+	if err := p.checkAddWalletPassword(password); err != nil {
+		return -1, "", err
+	}
 	// if p.wallet != nil {
 	// 	return "", fmt.Errorf("wallet has been created, please unlock it first")
 	// }
@@ -211,6 +216,9 @@ func (p *Manager) CreateMonitorWallet(address string) (int64, error) {
 func (p *Manager) ImportWallet(mnemonic string, password string) (int64, error) {
 	releaseRGB11Scope := p.beginRGB11ScopeChange()
 	defer releaseRGB11Scope()
+	if err := p.checkAddWalletPassword(password); err != nil {
+		return -1, err
+	}
 	// Log.Infof("ImportWallet %s %s", mnemonic, password)
 	// if p.wallet != nil {
 	// 	return fmt.Errorf("wallet exists, not allow to import new wallet")
@@ -257,12 +265,20 @@ func (p *Manager) ImportWallet(mnemonic string, password string) (int64, error)
 	if err := p.refreshDKVSRegistrations(); err != nil {
 		Log.Warningf("refresh DKVS registrations after wallet import failed: %v", err)
 	}
+
+	// 找回在其他子账户中的资金，同步执行，避免和恢复备份等操作同时修改子账户
+	if _, err := p.discoverAccounts(id, 0); err != nil {
+		Log.Warningf("discover accounts after wallet import failed: %v", err)
+	}
 	return id, nil
 }
 
 func (p *Manager) ImportWalletWithPrivateKey(privKey string, password string) (int64, error) {
 	releaseRGB11Scope := p.beginRGB11ScopeChange()
 	defer releaseRGB11Scope()
+	if err := p.checkAddWalletPassword(password); err != nil {
+		return -1, err
+	}
 
 	privKeyBytes, err := hex.DecodeString(privKey)
 	if err != nil {
@@ -301,6 +317,9 @@ func (p *Manager) ImportWalletWithPrivateKey(privKey string, password string) (i
 func (p *Manager) ChangePassword(oldPS, newPS string) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	if err := p.checkNewPassword(newPS); err != nil {
+		return err
+	}
 
 	for id, v := range p.walletInfoMap {
 		mnemonic, err := p.loadWalletSecret(v, oldPS)
@@ -366,25 +385,20 @@ func (p *Manager) unlockWallet(password string) (int64, error) {
 		secret, err := p.loadWalletSecret(walletInfo, password)
 		if err != nil {
 			Log.Errorf("loadWalletSecret %d failed. %v", walletInfo.Id, err)
-			return -1, fmt.Errorf("password is incorrect")
+			return -1, passwordError(err)
 		}
-		switch walletInfo.Type {
-		case WALLET_TYPE_MNEMONIC:
-			walletInfo.Wallet = NewInternalWalletWithMnemonic(string(secret), "", GetChainParam())
-			if walletInfo.Wallet == nil {
-				Log.Errorf("NewInternalWalletWithMnemonic failed")
-				continue
-			}
-		case WALLET_TYPE_PRIVKEY:
-			privKeyBytes, err := hex.DecodeString(string(secret))
+		wallet, err := newWalletFromSecret(walletInfo.Type, secret)
+		if err != nil {
+			Log.Errorf("newWalletFromSecret %d failed, %v", walletInfo.Id, err)
+			continue
+		}
+		walletInfo.Wallet = wallet
+
+		// 旧的记录或者KDF参数变化，用当前配置重新加密
+		if p.needKdfUpgrade(&walletInfo.WalletInDB) {
+			err = p.saveWalletSecretWithPassword(secret, password, &walletInfo.WalletInDB)
 			if err != nil {
-				Log.Errorf("hex.DecodeString failed, %v", err)
-				continue
-			}
-			walletInfo.Wallet, _, _ = NewInternalWalletWithPrivKey(privKeyBytes, GetChainParam())
-			if walletInfo.Wallet == nil {
-				Log.Errorf("NewInternalWalletWithPrivKey failed")
-				continue
+				Log.Errorf("upgrade wallet %d encryption failed. %v", walletInfo.Id, err)
 			}
 		}
 	}
@@ -745,6 +759,11 @@ func (p *Manager) SignPsbts(psbtsHex []string, bExtract bool) ([]string, error)
 }
 
 func (p *Manager) SignPsbt(psbtHex string, bExtract bool) (string, error) {
+	return p.SignPsbtWithPolicy(psbtHex, bExtract, nil)
+}
+
+// 签名前检查交易（资产，dust，网络费），override 为调用方明确允许的例外
+func (p *Manager) SignPsbtWithPolicy(psbtHex string, bExtract bool, override *TxPolicyOverride) (string, error) {
 	if p.wallet == nil {
 		return "", fmt.Errorf("wallet is not created/unlocked")
 	}
@@ -768,13 +787,23 @@ func (p *Manager) SignPsbt(psbtHex string, bExtract bool) (string, error) {
 	// 	fmt.Printf("sig flag: %x\n", input.SighashType)
 	// }
 
+	err = p.checkPsbtPolicy(packet, override)
+	if err != nil {
+		Log.Errorf("checkPsbtPolicy failed, %v", err)
+		return "", err
+	}
+
 	err = p.wallet.SignPsbt(packet)
 	if err != nil {
 		Log.Errorf("SignPsbt failed, %v", err)
 		return "", err
 	}
 
-	err = psbt.MaybeFinalizeAll(packet)
+	return finalizePsbt(packet, bExtract)
+}
+
+func finalizePsbt(packet *psbt.Packet, bExtract bool) (string, error) {
+	err := psbt.MaybeFinalizeAll(packet)
 	if err != nil {
 		Log.Errorf("MaybeFinalizeAll failed, %v", err)
 		return "", err
@@ -790,8 +819,12 @@ func (p *Manager) SignPsbt(psbtHex string, bExtract bool) (string, error) {
 		return EncodeMsgTx(finalTx)
 	}
 
+	return encodePsbt(packet)
+}
+
+func encodePsbt(packet *psbt.Packet) (string, error) {
 	var buf bytes.Buffer
-	err = packet.Serialize(&buf)
+	err := packet.Serialize(&buf)
 	if err != nil {
 		Log.Errorf("Serialize failed, %v", err)
 		return "", err
@@ -800,6 +833,70 @@ func (p *Manager) SignPsbt(psbtHex string, bExtract bool) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+func decodePsbt(psbtHex string) (*psbt.Packet, error) {
+	hexBytes, err := hex.DecodeString(psbtHex)
+	if err != nil {
+		return nil, err
+	}
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(hexBytes), false)
+	if err != nil {
+		Log.Errorf("NewFromRawBytes failed, %v", err)
+		return nil, err
+	}
+	return packet, nil
+}
+
+// 为psbt中属于本钱包的输入和输出填写BIP32派生路径（主密钥指纹+路径），方便外部签名器签名
+func (p *Manager) FillPsbtDerivation(psbtHex string) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	w, ok := p.wallet.(PsbtDerivationWallet)
+	if !ok {
+		return "", fmt.Errorf("wallet does not support derivation path")
+	}
+
+	packet, err := decodePsbt(psbtHex)
+	if err != nil {
+		return "", err
+	}
+	err = w.FillPsbtDerivation(packet)
+	if err != nil {
+		Log.Errorf("FillPsbtDerivation failed, %v", err)
+		return "", err
+	}
+
+	return encodePsbt(packet)
+}
+
+// 根据每个输入中的BIP32派生路径派生私钥签名，可以同时花费多个子账户和找零地址的utxo
+func (p *Manager) SignPsbtByDerivation(psbtHex string, bExtract bool, override *TxPolicyOverride) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	w, ok := p.wallet.(PsbtDerivationWallet)
+	if !ok {
+		return "", fmt.Errorf("wallet does not support derivation path")
+	}
+
+	packet, err := decodePsbt(psbtHex)
+	if err != nil {
+		return "", err
+	}
+	err = p.checkPsbtPolicy(packet, override)
+	if err != nil {
+		Log.Errorf("checkPsbtPolicy failed, %v", err)
+		return "", err
+	}
+	err = w.SignPsbtByDerivation(packet)
+	if err != nil {
+		Log.Errorf("SignPsbtByDerivation failed, %v", err)
+		return "", err
+	}
+
+	return finalizePsbt(packet, bExtract)
+}
+
 func (p *Manager) SignPsbts_SatsNet(psbtsHex []string, bExtract bool) ([]string, error) {
 	result := make([]string, 0, len(psbtsHex))
 	for i, psbt := range psbtsHex {
@@ -814,6 +911,10 @@ func (p *Manager) SignPsbts_SatsNet(psbtsHex []string, bExtract bool) ([]string,
 }
 
 func (p *Manager) SignPsbt_SatsNet(psbtHex string, bExtract bool) (string, error) {
+	return p.SignPsbtWithPolicy_SatsNet(psbtHex, bExtract, nil)
+}
+
+func (p *Manager) SignPsbtWithPolicy_SatsNet(psbtHex string, bExtract bool, override *TxPolicyOverride) (string, error) {
 	if p.wallet == nil {
 		return "", fmt.Errorf("wallet is not created/unlocked")
 	}
@@ -828,6 +929,12 @@ func (p *Manager) SignPsbt_SatsNet(psbtHex string, bExtract bool) (string, error
 		return "", err
 	}
 
+	err = p.checkPsbtPolicy_SatsNet(packet, override)
+	if err != nil {
+		Log.Errorf("checkPsbtPolicy_SatsNet failed, %v", err)
+		return "", err
+	}
+
 	err = p.wallet.SignPsbt_SatsNet(packet)
 	if err != nil {
 		Log.Errorf("SignPsbt_SatsNet failed, %v", err)
@@ -915,14 +1022,7 @@ func (p *Manager) GetUtxoWithAddressFromTx(txId, address string) (string, error)
 }
 
 func (p *Manager) GetChannelAddress() (string, error) {
-	if p.wallet == nil {
-		return "", fmt.Errorf("wallet is not created/unlocked")
-	}
-	if p.serverNode == nil || p.serverNode.Pubkey == nil {
-		return "", fmt.Errorf("server node is not initialized")
-	}
-	return GetP2WSHaddress(p.serverNode.Pubkey.SerializeCompressed(),
-		p.wallet.GetPaymentPubKey().SerializeCompressed())
+	return p.getChannelAddressWithNode(p.serverNode)
 }
 
 // 对某个btc的名字设置属性
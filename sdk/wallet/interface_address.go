@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+// 当前子账户下指定类型的地址
+func (p *Manager) GetAddressByType(addrType string) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	if !IsSupportedAddressType(addrType) {
+		return "", fmt.Errorf("unsupported address type %s", addrType)
+	}
+	addr := p.wallet.GetAddressByType(addrType, p.wallet.GetSubAccount())
+	if addr == "" {
+		return "", fmt.Errorf("can't get %s address", addrType)
+	}
+	return addr, nil
+}
+
+// 当前子账户下所有类型的地址，key: 地址类型
+func (p *Manager) GetAllTypeAddresses() (map[string]string, error) {
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	return p.wallet.GetAddressesByIndex(p.wallet.GetSubAccount()), nil
+}
+
+// 根据输入的锁定脚本估算签名后的大小
+func addInputWeightByPkScript(weightEstimate *utils.TxWeightEstimator, pkScript []byte) error {
+	switch {
+	case txscript.IsPayToTaproot(pkScript):
+		weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		weightEstimate.AddP2WKHInput()
+	case txscript.IsPayToScriptHash(pkScript):
+		// 钱包只会产生P2SH-P2WPKH
+		weightEstimate.AddNestedP2WKHInput()
+	case txscript.IsPayToPubKeyHash(pkScript):
+		weightEstimate.AddP2PKHInput()
+	default:
+		return fmt.Errorf("unsupported pkScript %x", pkScript)
+	}
+	return nil
+}
+
+// 将当前子账户下非p2tr地址（P2WPKH, P2SH-P2WPKH, P2PKH）中的白聪归集到p2tr地址。
+// 只处理白聪，带有资产的utxo不会被转移，避免资产被误烧毁。
+func (p *Manager) SweepAddressTypes(feeRate int64) (string, int64, error) {
+	if p.wallet == nil {
+		return "", 0, fmt.Errorf("wallet is not created/unlocked")
+	}
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
+	}
+
+	index := p.wallet.GetSubAccount()
+	destAddr := p.wallet.GetAddress()
+	destPkScript, err := GetPkScriptFromAddress(destAddr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var weightEstimate utils.TxWeightEstimator
+	total := int64(0)
+	for _, addrType := range SupportedAddressTypes {
+		if addrType == ADDRESS_TYPE_P2TR {
+			continue
+		}
+		address := p.wallet.GetAddressByType(addrType, index)
+		if address == "" {
+			continue
+		}
+		utxos := p.l1IndexerClient.GetUtxoListWithTicker(address, &indexer.ASSET_PLAIN_SAT)
+		if len(utxos) == 0 {
+			continue
+		}
+		p.utxoLockerL1.Reload(address)
+		for _, u := range utxos {
+			if p.utxoLockerL1.IsLocked(u.OutPoint) {
+				continue
+			}
+			output := OutputInfoToOutput(u)
+			if err := addInputWeightByPkScript(&weightEstimate, output.OutValue.PkScript); err != nil {
+				Log.Warnf("skip utxo %s, %v", u.OutPoint, err)
+				continue
+			}
+			tx.AddTxIn(output.TxIn())
+			prevFetcher.AddPrevOut(*output.OutPoint(), &output.OutValue)
+			total += output.OutValue.Value
+		}
+	}
+	if len(tx.TxIn) == 0 {
+		return "", 0, fmt.Errorf(ERR_NO_SATS)
+	}
+
+	weightEstimate.AddP2TROutput()
+	fee := weightEstimate.Fee(feeRate)
+	if total-fee < 330 {
+		return "", 0, fmt.Errorf("no enough plain sats, required %d but only %d", fee+330, total)
+	}
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: destPkScript,
+		Value:    total - fee,
+	})
+
+	// 支持RBF加速
+	enableRBF(tx)
+	signedTx, err := p.SignTx(tx, prevFetcher)
+	if err != nil {
+		Log.Errorf("SignTx failed. %v", err)
+		return "", 0, err
+	}
+
+	txId, err := p.BroadcastTx(signedTx)
+	if err != nil {
+		Log.Errorf("BroadcastTx failed. %v", err)
+		return "", 0, err
+	}
+	Log.Infof("SweepAddressTypes succeed. %s %d", txId, fee)
+
+	return txId, fee, nil
+}
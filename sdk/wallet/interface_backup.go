@@ -0,0 +1,383 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+/*
+钱包备份文件，格式见 docs/wallet-backup-file.md
+外层是明文的json信封，内层是加密的json数据。跟钱包在数据库中保存助记词一样使用 vault 的加密方式：
+按照配置的KDF（默认argon2id）派生密钥 + XSalsa20-Poly1305，密文带有认证，密码错误或者文件被修改都无法解密。
+信封中的 format/version/network 在加密数据中也有一份，解密后比较，防止被篡改。
+*/
+
+const (
+	WALLET_BACKUP_FORMAT  = "sat20-wallet-backup"
+	WALLET_BACKUP_VERSION = 1
+	WALLET_BACKUP_CIPHER  = "xsalsa20poly1305"
+)
+
+// 明文信封
+type WalletBackupFile struct {
+	Format  string     `json:"format"`
+	Version int        `json:"version"`
+	Network string     `json:"network"`
+	Cipher  string     `json:"cipher"`
+	Created int64      `json:"created"`
+	Kdf     *KdfParams `json:"kdf"`  // 派生密钥的参数和salt
+	Data    []byte     `json:"data"` // 加密后的 WalletBackupPayload
+}
+
+type WalletBackupPayload struct {
+	Format  string              `json:"format"`
+	Version int                 `json:"version"`
+	Network string              `json:"network"`
+	Created int64               `json:"created"`
+	Wallets []*WalletBackupItem `json:"wallets"`
+
+	// 可选
+	LockedUtxos         map[string]*LockedUtxo `json:"lockedUtxos,omitempty"`
+	LockedUtxos_SatsNet map[string]*LockedUtxo `json:"lockedUtxosSatsNet,omitempty"`
+	Channels            map[string][]byte      `json:"channels,omitempty"` // channelId -> 通道备份数据 (ChannelInDB)
+}
+
+type WalletBackupItem struct {
+	Id           int64             `json:"id"`
+	Type         int               `json:"type"`
+	Name         string            `json:"name"`
+	Fingerprint  string            `json:"fingerprint,omitempty"`
+	Secret       string            `json:"secret"` // 助记词，私钥或者描述符，跟 Type 对应
+	Accounts     int               `json:"accounts"`
+	AccountNames map[uint32]string `json:"accountNames,omitempty"`
+	AccountDIDs  map[uint32]string `json:"accountDIDs,omitempty"`
+	Bip85        *Bip85Derivation  `json:"bip85,omitempty"` // 从主钱包派生的子钱包
+}
+
+type WalletExportOptions struct {
+	LockedUtxos bool
+	Channels    bool
+}
+
+type WalletImportResult struct {
+	Imported    []int64 `json:"imported"`
+	Skipped     []int64 `json:"skipped"` // 已经存在的钱包，使用备份文件中的id
+	LockedUtxos int     `json:"lockedUtxos"`
+	Channels    int     `json:"channels"`
+}
+
+// 根据保存的秘密数据恢复钱包
+func newWalletFromSecret(ty int, secret string) (common.Wallet, error) {
+	switch ty {
+	case WALLET_TYPE_MNEMONIC:
+		wallet := NewInternalWalletWithMnemonic(secret, "", GetChainParam())
+		if wallet == nil {
+			return nil, fmt.Errorf("NewInternalWalletWithMnemonic failed")
+		}
+		return wallet, nil
+	case WALLET_TYPE_PRIVKEY:
+		privKeyBytes, err := hex.DecodeString(secret)
+		if err != nil {
+			return nil, err
+		}
+		wallet, _, err := NewInternalWalletWithPrivKey(privKeyBytes, GetChainParam())
+		if err != nil {
+			return nil, err
+		}
+		return wallet, nil
+	case WALLET_TYPE_WATCHONLY:
+		return NewWatchOnlyWallet(secret, GetChainParam())
+	default:
+		return nil, fmt.Errorf("unsupported wallet type %d", ty)
+	}
+}
+
+// 导出钱包到加密的备份文件，ids为空时导出所有钱包。password 是钱包密码，同时也用来加密备份文件
+func (p *Manager) ExportWalletFile(ids []int64, password string, options *WalletExportOptions) ([]byte, error) {
+	if options == nil {
+		options = &WalletExportOptions{}
+	}
+	if len(ids) == 0 {
+		for _, entry := range p.GetWalletCatalog() {
+			ids = append(ids, entry.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no wallet")
+	}
+
+	now := time.Now().Unix()
+	payload := WalletBackupPayload{
+		Format:  WALLET_BACKUP_FORMAT,
+		Version: WALLET_BACKUP_VERSION,
+		Network: GetChainParam().Name,
+		Created: now,
+	}
+
+	p.mutex.Lock()
+	for _, id := range ids {
+		info, ok := p.walletInfoMap[id]
+		if !ok {
+			p.mutex.Unlock()
+			return nil, fmt.Errorf("can't find wallet %d", id)
+		}
+		secret, err := p.loadWalletSecret(info, password)
+		if err != nil {
+			p.mutex.Unlock()
+			return nil, passwordError(err)
+		}
+		payload.Wallets = append(payload.Wallets, &WalletBackupItem{
+			Id:           info.Id,
+			Type:         info.Type,
+			Name:         info.Name,
+			Fingerprint:  walletFingerprint(info.Wallet),
+			Secret:       secret,
+			Accounts:     info.Accounts,
+			AccountNames: info.AccountNames,
+			AccountDIDs:  info.AccountDIDs,
+			Bip85:        info.Bip85,
+		})
+	}
+	p.mutex.Unlock()
+
+	if options.LockedUtxos {
+		if p.utxoLockerL1 != nil {
+			payload.LockedUtxos = p.utxoLockerL1.GetLockedUtxoList()
+		}
+		if p.utxoLockerL2 != nil {
+			payload.LockedUtxos_SatsNet = p.utxoLockerL2.GetLockedUtxoList()
+		}
+	}
+	if options.Channels {
+		prefix := GetDBKeyPrefix() + DB_KEY_BACKUP_CHANNEL
+		items, err := GetItemsFromDB([]byte(prefix), p.db)
+		if err != nil {
+			Log.Errorf("GetItemsFromDB %s failed. %v", prefix, err)
+			return nil, err
+		}
+		payload.Channels = make(map[string][]byte)
+		for k, v := range items {
+			payload.Channels[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+
+	data, err := json.Marshal(&payload)
+	if err != nil {
+		return nil, err
+	}
+	params, err := p.newKdfParams()
+	if err != nil {
+		return nil, err
+	}
+	en, err := encryptVaultSecret(data, password, params)
+	if err != nil {
+		Log.Errorf("encryptVaultSecret failed. %v", err)
+		return nil, err
+	}
+
+	file := WalletBackupFile{
+		Format:  WALLET_BACKUP_FORMAT,
+		Version: WALLET_BACKUP_VERSION,
+		Network: payload.Network,
+		Cipher:  WALLET_BACKUP_CIPHER,
+		Created: now,
+		Kdf:     params,
+		Data:    en,
+	}
+	return json.MarshalIndent(&file, "", "  ")
+}
+
+// 解密备份文件，检查版本和网络
+func DecodeWalletFile(data []byte, password string) (*WalletBackupPayload, error) {
+	var file WalletBackupFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet file, %v", err)
+	}
+	if file.Format != WALLET_BACKUP_FORMAT {
+		return nil, fmt.Errorf("invalid wallet file format %s", file.Format)
+	}
+	if file.Version < 1 || file.Version > WALLET_BACKUP_VERSION {
+		return nil, fmt.Errorf("unsupported wallet file version %d", file.Version)
+	}
+	if file.Cipher != WALLET_BACKUP_CIPHER {
+		return nil, fmt.Errorf("unsupported wallet file cipher %s", file.Cipher)
+	}
+
+	if file.Kdf == nil {
+		return nil, fmt.Errorf("invalid wallet file, no kdf params")
+	}
+	plain, err := decryptVaultSecret(file.Data, password, file.Kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload WalletBackupPayload
+	err = json.Unmarshal(plain, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet file data, %v", err)
+	}
+	if payload.Format != file.Format || payload.Version != file.Version || payload.Network != file.Network {
+		return nil, fmt.Errorf("wallet file header mismatch")
+	}
+	if payload.Network != GetChainParam().Name {
+		return nil, fmt.Errorf("wallet file is for %s, current network is %s", payload.Network, GetChainParam().Name)
+	}
+	return &payload, nil
+}
+
+// 从备份文件中导入钱包。filePassword 是备份文件的密码，password 是本地钱包的密码，
+// 导入的钱包用本地密码加密保存。已经存在的钱包（相同指纹）会被跳过，不会切换当前钱包
+func (p *Manager) ImportWalletFile(data []byte, filePassword, password string) (*WalletImportResult, error) {
+	payload, err := DecodeWalletFile(data, filePassword)
+	if err != nil {
+		Log.Errorf("DecodeWalletFile failed. %v", err)
+		return nil, err
+	}
+
+	releaseRGB11Scope := p.beginRGB11ScopeChange()
+	defer releaseRGB11Scope()
+
+	result := &WalletImportResult{}
+	p.mutex.Lock()
+	// 本地已经有钱包，需要用同一个密码
+	for _, info := range p.walletInfoMap {
+		if _, err := p.loadWalletSecret(info, password); err != nil {
+			p.mutex.Unlock()
+			return nil, passwordError(err)
+		}
+		break
+	}
+
+	existing := make(map[string]bool)
+	for _, info := range p.walletInfoMap {
+		if key := walletBackupKey(info.Wallet); key != "" {
+			existing[key] = true
+		}
+	}
+
+	items := payload.Wallets
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	for _, item := range items {
+		wallet, err := newWalletFromSecret(item.Type, item.Secret)
+		if err != nil {
+			p.mutex.Unlock()
+			Log.Errorf("restore wallet %d failed. %v", item.Id, err)
+			return nil, err
+		}
+		key := walletBackupKey(wallet)
+		if key != "" && existing[key] {
+			result.Skipped = append(result.Skipped, item.Id)
+			continue
+		}
+
+		err = p.saveSecret(item.Secret, password, item.Type, wallet)
+		if err != nil {
+			p.mutex.Unlock()
+			return nil, err
+		}
+		info := p.walletInfoMap[wallet.GetId()]
+		if strings.TrimSpace(item.Name) != "" {
+			info.Name = item.Name
+		}
+		if item.Accounts > info.Accounts {
+			info.Accounts = item.Accounts
+		}
+		for k, v := range item.AccountNames {
+			info.AccountNames[k] = v
+		}
+		for k, v := range item.AccountDIDs {
+			info.AccountDIDs[k] = v
+		}
+		if info.Bip85 == nil && item.Bip85 != nil {
+			derivation := *item.Bip85
+			info.Bip85 = &derivation
+		}
+		normalizeWalletInfoMetadata(info, len(p.walletInfoMap)-1)
+		if err := saveWallet(p.db, &info.WalletInDB); err != nil {
+			p.mutex.Unlock()
+			return nil, err
+		}
+		if err := p.queueAccountMutationLocked(accountManagementMutation{
+			Type: accountMutationAddWallet, Fingerprint: walletFingerprint(wallet),
+			WalletID: info.Id,
+		}); err != nil {
+			Log.Errorf("queue managed wallet import failed: %v", err)
+		}
+		if key != "" {
+			existing[key] = true
+		}
+		result.Imported = append(result.Imported, info.Id)
+	}
+
+	if p.wallet == nil && len(result.Imported) != 0 {
+		info := p.walletInfoMap[result.Imported[0]]
+		p.wallet = info.Wallet
+		p.status.CurrentWallet = info.Id
+		p.status.CurrentAccount = 0
+		_ = p.rgbManager.selectRGB11Scope()
+		_ = p.rgbManager.rebuildRGB11Locks()
+		p.saveStatus()
+	}
+	if len(result.Imported) != 0 {
+		p.markDKVSStateDirty()
+	}
+	p.mutex.Unlock()
+
+	result.LockedUtxos += importLockedUtxos(p.utxoLockerL1, payload.LockedUtxos)
+	result.LockedUtxos += importLockedUtxos(p.utxoLockerL2, payload.LockedUtxos_SatsNet)
+	for channelId, buf := range payload.Channels {
+		if _, err := LoadBackupChannelInDB(p.db, channelId); err == nil {
+			continue
+		}
+		var channel ChannelInDB
+		if err := DecodeFromBytes(buf, &channel); err != nil || channel.ChannelId != channelId {
+			Log.Errorf("invalid channel backup %s. %v", channelId, err)
+			continue
+		}
+		if err := SaveBackupChannelInDB(p.db, &channel); err != nil {
+			continue
+		}
+		result.Channels++
+	}
+
+	if len(result.Imported) != 0 {
+		if err := p.refreshDKVSRegistrations(); err != nil {
+			Log.Warningf("refresh DKVS registrations after wallet file import failed: %v", err)
+		}
+	}
+	Log.Infof("ImportWalletFile imported %v, skipped %v", result.Imported, result.Skipped)
+	return result, nil
+}
+
+// 导入时判断钱包是否已经存在。观察钱包没有节点公钥，用描述符判断
+func walletBackupKey(w common.Wallet) string {
+	if watchOnly, ok := w.(*WatchOnlyWallet); ok {
+		return "watchonly:" + watchOnly.GetDescriptor()
+	}
+	return walletFingerprint(w)
+}
+
+// 只恢复锁定原因，预留中的utxo是临时状态，不恢复
+func importLockedUtxos(locker *UtxoLocker, lockedUtxos map[string]*LockedUtxo) int {
+	if locker == nil {
+		return 0
+	}
+	count := 0
+	for utxo, lock := range lockedUtxos {
+		if lock == nil || lock.ReservationID != "" || locker.IsLocked(utxo) {
+			continue
+		}
+		if err := locker.LockUtxo(utxo, lock.Reason); err != nil {
+			continue
+		}
+		count++
+	}
+	return count
+}
@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWalletFileRoundTrip(t *testing.T) {
+	oldChain := _chain
+	_chain = "testnet"
+	defer func() { _chain = oldChain }()
+
+	source := newAccountManagementAutoTestManager(t)
+	mnemonicID, err := source.ImportWallet(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"password",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKeyID, err := source.ImportWalletWithPrivateKey(
+		"0000000000000000000000000000000000000000000000000000000000000001", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	childID, err := source.CreateChildWallet(mnemonicID, 0, 12, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := NewInternalWalletWithMnemonic(
+		"legal winner thank year wave sausage worth useful legal winner thank yellow", "", GetChainParam())
+	desc, err := watched.GetAccountDescriptor(ADDRESS_TYPE_P2TR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.ImportWatchOnlyWallet(desc, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.UpdateWalletName(mnemonicID, "Savings"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.EnsureAccount(mnemonicID, 2, "Trading", "did:sat20:test"); err != nil {
+		t.Fatal(err)
+	}
+	utxo := "0000000000000000000000000000000000000000000000000000000000000001:0"
+	if err := source.utxoLockerL1.LockUtxo(utxo, "ordinals"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.ExportWalletFile(nil, "wrong", nil); err == nil {
+		t.Fatal("export with wrong password should fail")
+	}
+	data, err := source.ExportWalletFile(nil, "password", &WalletExportOptions{LockedUtxos: true})
+	if err != nil {
+		t.Fatalf("ExportWalletFile failed. %v", err)
+	}
+
+	if _, err := DecodeWalletFile(data, "wrong"); err == nil {
+		t.Fatal("decode with wrong password should fail")
+	}
+	var file WalletBackupFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Format != WALLET_BACKUP_FORMAT || file.Version != WALLET_BACKUP_VERSION {
+		t.Fatalf("invalid header %s %d", file.Format, file.Version)
+	}
+	// 跟钱包数据库使用同样的KDF
+	if file.Cipher != WALLET_BACKUP_CIPHER || file.Kdf == nil || file.Kdf.Algo != KDF_ARGON2ID {
+		t.Fatalf("unexpected cipher %s %+v", file.Cipher, file.Kdf)
+	}
+	file.Data[len(file.Data)-1] ^= 1
+	tampered, _ := json.Marshal(&file)
+	if _, err := DecodeWalletFile(tampered, "password"); err == nil {
+		t.Fatal("tampered file should be rejected")
+	}
+
+	target := newAccountManagementAutoTestManager(t)
+	result, err := target.ImportWalletFile(data, "password", "local")
+	if err != nil {
+		t.Fatalf("ImportWalletFile failed. %v", err)
+	}
+	if len(result.Imported) != 4 || len(result.Skipped) != 0 || result.LockedUtxos != 1 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	if target.GetWallet() == nil {
+		t.Fatal("imported wallet should be selected")
+	}
+	if !target.IsLocked("", utxo) {
+		t.Fatal("locked utxo is not restored")
+	}
+
+	sourceCatalog := source.GetWalletCatalog()
+	targetCatalog := target.GetWalletCatalog()
+	if len(targetCatalog) != len(sourceCatalog) {
+		t.Fatalf("catalog size %d, expected %d", len(targetCatalog), len(sourceCatalog))
+	}
+	for i, expected := range sourceCatalog {
+		got := targetCatalog[i]
+		if got.Name != expected.Name || got.Fingerprint != expected.Fingerprint ||
+			len(got.Accounts) != len(expected.Accounts) {
+			t.Fatalf("wallet %d mismatch: %+v, expected %+v", i, got, expected)
+		}
+		for j, account := range expected.Accounts {
+			if got.Accounts[j].Name != account.Name || got.Accounts[j].DID != account.DID ||
+				got.Accounts[j].Address != account.Address {
+				t.Fatalf("account %d/%d mismatch", i, j)
+			}
+		}
+	}
+	if target.GetMnemonic(targetCatalog[0].ID, "local") != source.GetMnemonic(mnemonicID, "password") {
+		t.Fatal("mnemonic mismatch")
+	}
+	if target.GetMnemonic(targetCatalog[1].ID, "local") != source.GetMnemonic(privKeyID, "password") {
+		t.Fatal("private key mismatch")
+	}
+	child := target.walletInfoMap[targetCatalog[2].ID]
+	if child.Bip85 == nil || *child.Bip85 != *source.walletInfoMap[childID].Bip85 {
+		t.Fatalf("bip85 derivation is not restored %+v", child.Bip85)
+	}
+
+	// 再次导入，钱包已经存在
+	if _, err := target.ImportWalletFile(data, "password", "wrong"); err == nil {
+		t.Fatal("import with wrong local password should fail")
+	}
+	result, err = target.ImportWalletFile(data, "password", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 观察钱包没有指纹，同样不会重复导入
+	if len(result.Imported) != 0 || len(result.Skipped) != 4 {
+		t.Fatalf("unexpected reimport result %+v", result)
+	}
+}
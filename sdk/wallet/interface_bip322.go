@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// 签名结果是base64编码，跟其他钱包一致。address为空时使用当前地址
+func (p *Manager) SignMessageBIP322(msg, address, format string) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	if address == "" {
+		address = p.wallet.GetAddress()
+	}
+	sig, err := SignMessageBIP322(p.wallet, []byte(msg), address, format)
+	if err != nil {
+		Log.Errorf("SignMessageBIP322 %s failed. %v", address, err)
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+func (p *Manager) VerifyMessageBIP322(address, msg, signature string) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("signature should be base64 encoded, %v", err)
+	}
+	err = VerifyMessageBIP322(address, []byte(msg), sig)
+	if err != nil {
+		Log.Infof("VerifyMessageBIP322 %s failed. %v", address, err)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/sat20-labs/sat20wallet/sdk/account"
+)
+
+// 从主钱包通过BIP85派生子钱包。主钱包必须是助记词钱包，password是本地钱包的密码。
+// 子钱包跟其他钱包一样保存，但是不切换当前钱包
+func (p *Manager) CreateChildWallet(parentId int64, index uint32, words int, password string) (int64, error) {
+	if _, err := bip85EntropySize(words); err != nil {
+		return -1, err
+	}
+
+	p.mutex.Lock()
+
+	parent, ok := p.walletInfoMap[parentId]
+	if !ok {
+		p.mutex.Unlock()
+		return -1, fmt.Errorf("can't find wallet %d", parentId)
+	}
+	if parent.Type != WALLET_TYPE_MNEMONIC {
+		p.mutex.Unlock()
+		return -1, fmt.Errorf("wallet %d is not a mnemonic wallet", parentId)
+	}
+	parentMnemonic, err := p.loadWalletSecret(parent, password)
+	if err != nil {
+		p.mutex.Unlock()
+		return -1, err
+	}
+	parentWallet := NewInternalWalletWithMnemonic(parentMnemonic, "", GetChainParam())
+	if parentWallet == nil {
+		p.mutex.Unlock()
+		return -1, fmt.Errorf("invalid mnemonic of wallet %d", parentId)
+	}
+	mnemonic, err := parentWallet.DeriveChildMnemonic(words, index)
+	if err != nil {
+		p.mutex.Unlock()
+		Log.Errorf("DeriveChildMnemonic failed. %v", err)
+		return -1, err
+	}
+	wallet := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	if wallet == nil {
+		p.mutex.Unlock()
+		return -1, fmt.Errorf("NewWalletWithMnemonic failed")
+	}
+	derivation := &Bip85Derivation{
+		Parent: walletFingerprint(parentWallet),
+		Index:  index,
+		Words:  words,
+	}
+	fingerprint := walletFingerprint(wallet)
+	for id, info := range p.walletInfoMap {
+		if (info.Wallet != nil && walletFingerprint(info.Wallet) == fingerprint) ||
+			(info.Bip85 != nil && *info.Bip85 == *derivation) {
+			p.mutex.Unlock()
+			return -1, fmt.Errorf("child wallet %d/%d exists, wallet id %d", index, words, id)
+		}
+	}
+
+	err = p.saveMnemonic(mnemonic, password, wallet)
+	if err != nil {
+		p.mutex.Unlock()
+		return -1, err
+	}
+	info := p.walletInfoMap[wallet.GetId()]
+	info.Bip85 = derivation
+	info.Name = fmt.Sprintf("%s #%d", parent.Name, index)
+	err = saveWallet(p.db, &info.WalletInDB)
+	if err != nil {
+		p.mutex.Unlock()
+		return -1, err
+	}
+	if err := p.queueAccountMutationLocked(accountManagementMutation{
+		Type: accountMutationAddWallet, Fingerprint: fingerprint,
+		WalletID: wallet.GetId(),
+	}); err != nil {
+		Log.Errorf("queue managed child wallet creation failed: %v", err)
+	}
+	p.markDKVSStateDirty()
+
+	id := wallet.GetId()
+	p.mutex.Unlock()
+	if err := p.refreshDKVSRegistrations(); err != nil {
+		Log.Warningf("refresh DKVS registrations after child wallet creation failed: %v", err)
+	}
+	return id, nil
+}
+
+// 导出账户备份时，子钱包只记录派生信息，不保存助记词。
+// mnemonics 是已经导出的钱包的助记词，key是钱包指纹，value是在备份中的位置
+func childBackupDerivation(info *WalletInfo, mnemonic string,
+	positions map[string]int, mnemonics []string) *account.ChildDerivation {
+	if info.Bip85 == nil {
+		return nil
+	}
+	position, ok := positions[info.Bip85.Parent]
+	if !ok {
+		return nil
+	}
+	// 确认能派生出同样的助记词，否则保存完整的助记词
+	derived, err := deriveChildMnemonic(mnemonics[position], info.Bip85.Words, info.Bip85.Index)
+	if err != nil || derived != mnemonic {
+		Log.Warningf("wallet %d can't be derived from its parent, keep the mnemonic in backup", info.Id)
+		return nil
+	}
+	return &account.ChildDerivation{
+		Parent: uint32(position),
+		Index:  info.Bip85.Index,
+		Words:  uint32(info.Bip85.Words),
+	}
+}
+
+// 恢复备份中子钱包的助记词，返回每个钱包的派生信息
+func resolveBackupChildWallets(backup *account.Backup) ([]*Bip85Derivation, error) {
+	result := make([]*Bip85Derivation, len(backup.Wallets))
+	for i := range backup.Wallets {
+		item := &backup.Wallets[i]
+		if item.Derivation == nil {
+			continue
+		}
+		if int(item.Derivation.Parent) >= i {
+			return nil, fmt.Errorf("restore wallet %q: invalid parent", item.Name)
+		}
+		parent := NewInternalWalletWithMnemonic(backup.Wallets[item.Derivation.Parent].Mnemonic, "", GetChainParam())
+		if parent == nil {
+			return nil, fmt.Errorf("restore wallet %q: invalid parent mnemonic", item.Name)
+		}
+		mnemonic, err := parent.DeriveChildMnemonic(int(item.Derivation.Words), item.Derivation.Index)
+		if err != nil {
+			return nil, fmt.Errorf("restore wallet %q: %w", item.Name, err)
+		}
+		if item.Mnemonic != "" && item.Mnemonic != mnemonic {
+			return nil, fmt.Errorf("restore wallet %q: mnemonic mismatch with derivation", item.Name)
+		}
+		item.Mnemonic = mnemonic
+		result[i] = &Bip85Derivation{
+			Parent: walletFingerprint(parent),
+			Index:  item.Derivation.Index,
+			Words:  int(item.Derivation.Words),
+		}
+	}
+	return result, nil
+}
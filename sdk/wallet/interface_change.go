@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+
+	indexer "github.com/sat20-labs/indexer/common"
+	indexerwire "github.com/sat20-labs/indexer/rpcserver/wire"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+/*
+找零链：每个子账户(index)对应一个找零地址 m/86'/0'/0'/1/index
+打开配置 wallet.changeAddress 后，BuildBatchSendTx* 中的白聪找零（包括网络费找零）输出到找零地址，
+选择白聪时也会同时使用找零地址上的白聪。资产的找零仍然回到原地址，因为资产的查询都是基于地址的。
+通道中的操作不使用找零地址。
+关闭配置以后，找零地址上的资金仍然属于钱包：资产汇总、余额和交易记录都会包括所有子账户的找零地址。
+*/
+
+const DEFAULT_GAP_LIMIT = 20
+
+func (p *Manager) isChangeAddressEnabled() bool {
+	return p.cfg != nil && p.cfg.Wallet.ChangeAddress
+}
+
+// 当前子账户的找零地址
+func (p *Manager) GetChangeAddress() (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	addr := p.wallet.GetChangeAddressByIndex(p.wallet.GetSubAccount())
+	if addr == "" {
+		return "", fmt.Errorf("wallet has no change address")
+	}
+	return addr, nil
+}
+
+// 从address发送时，使用的找零地址。没有找零地址时返回空
+func (p *Manager) getChangeAddressFor(address string, inChannel bool) string {
+	if !p.isChangeAddressEnabled() {
+		return ""
+	}
+	return p.currentChangeAddress(address, inChannel)
+}
+
+// 当前子账户的找零地址，不检查配置
+func (p *Manager) currentChangeAddress(address string, inChannel bool) string {
+	if inChannel || p.wallet == nil {
+		return ""
+	}
+	if address != p.wallet.GetAddress() {
+		return ""
+	}
+	return p.wallet.GetChangeAddressByIndex(p.wallet.GetSubAccount())
+}
+
+// 当前钱包所有子账户的找零地址，key: 子账户的地址
+func (p *Manager) walletChangeAddresses() map[string]string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result := make(map[string]string)
+	if p.wallet == nil {
+		return result
+	}
+	accounts := 1
+	if info := p.walletInfoMap[p.status.CurrentWallet]; info != nil && info.Accounts > accounts {
+		accounts = info.Accounts
+	}
+	for index := uint32(0); index < uint32(accounts); index++ {
+		changeAddr := p.wallet.GetChangeAddressByIndex(index)
+		if changeAddr == "" {
+			continue
+		}
+		result[p.wallet.GetAddressByIndex(index)] = changeAddr
+	}
+	return result
+}
+
+// address 的找零地址，不是本钱包子账户的地址时返回空
+func (p *Manager) changeAddressOf(address string) string {
+	return p.walletChangeAddresses()[address]
+}
+
+// 白聪找零的锁定脚本，没有找零地址时返回defaultPkScript
+func (p *Manager) getChangePkScript(address string, inChannel bool, defaultPkScript []byte) []byte {
+	changeAddr := p.getChangeAddressFor(address, inChannel)
+	if changeAddr == "" {
+		return defaultPkScript
+	}
+	pkScript, err := GetPkScriptFromAddress(changeAddr)
+	if err != nil {
+		Log.Errorf("GetPkScriptFromAddress %s failed. %v", changeAddr, err)
+		return defaultPkScript
+	}
+	return pkScript
+}
+
+// 找零地址上的白聪，关闭配置以后也可以继续花费
+func (p *Manager) getChangeAddressPlainUtxos(address string, inChannel bool) []*indexerwire.TxOutputInfo {
+	changeAddr := p.currentChangeAddress(address, inChannel)
+	if changeAddr == "" {
+		return nil
+	}
+	p.utxoLockerL1.Reload(changeAddr)
+	return p.l1IndexerClient.GetUtxoListWithTicker(changeAddr, &indexer.ASSET_PLAIN_SAT)
+}
+
+// 合并两个白聪列表，保持从大到小的顺序
+func mergePlainUtxos(utxos, more []*indexerwire.TxOutputInfo) []*indexerwire.TxOutputInfo {
+	if len(more) == 0 {
+		return utxos
+	}
+	result := make([]*indexerwire.TxOutputInfo, 0, len(utxos)+len(more))
+	result = append(result, utxos...)
+	result = append(result, more...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Value > result[j].Value
+	})
+	return result
+}
+
+// 子账户下是否有被使用过的地址，只查询配置的索引器，不把地址发送给第三方
+func (p *Manager) isAccountUsed(w common.Wallet, index uint32) bool {
+	addresses := make([]string, 0, len(SupportedAddressTypes)+1)
+	for _, addr := range w.GetAddressesByIndex(index) {
+		addresses = append(addresses, addr)
+	}
+	if len(addresses) == 0 {
+		addresses = append(addresses, w.GetAddressByIndex(index))
+	}
+	if changeAddr := w.GetChangeAddressByIndex(index); changeAddr != "" {
+		addresses = append(addresses, changeAddr)
+	}
+	for _, addr := range addresses {
+		if addr == "" {
+			continue
+		}
+		if len(p.l1IndexerClient.GetAllUtxosWithAddress(addr)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// BIP44方式的gap limit扫描：从子账户0开始，连续gapLimit个子账户都没有utxo时停止。
+// 返回有utxo的子账户
+func (p *Manager) scanUsedAccounts(w common.Wallet, gapLimit int) []uint32 {
+	if gapLimit <= 0 {
+		gapLimit = DEFAULT_GAP_LIMIT
+	}
+	result := make([]uint32, 0)
+	gap := 0
+	for index := uint32(0); gap < gapLimit; index++ {
+		if p.isAccountUsed(w, index) {
+			result = append(result, index)
+			gap = 0
+		} else {
+			gap++
+		}
+	}
+	return result
+}
+
+// 扫描钱包id下被使用过的子账户，并且添加到钱包的子账户列表中
+func (p *Manager) discoverAccounts(id int64, gapLimit int) ([]uint32, error) {
+	if p.l1IndexerClient == nil {
+		return nil, fmt.Errorf("no indexer")
+	}
+	if gapLimit <= 0 && p.cfg != nil {
+		gapLimit = p.cfg.Wallet.GapLimit
+	}
+	p.mutex.RLock()
+	info := p.walletInfoMap[id]
+	var w common.Wallet
+	if info != nil && info.Wallet != nil {
+		w = info.Wallet.Clone()
+	}
+	p.mutex.RUnlock()
+	if w == nil {
+		return nil, fmt.Errorf("can't find wallet %d", id)
+	}
+
+	used := p.scanUsedAccounts(w, gapLimit)
+	if len(used) == 0 {
+		return used, nil
+	}
+
+	// 只添加新的子账户，已有的子账户保留原来的名字
+	last := used[len(used)-1]
+	p.mutex.RLock()
+	accounts := p.walletInfoMap[id].Accounts
+	p.mutex.RUnlock()
+	for index := uint32(accounts); index <= last; index++ {
+		err := p.EnsureAccount(id, index, "", "")
+		if err != nil {
+			Log.Errorf("EnsureAccount %d %d failed. %v", id, index, err)
+			return nil, err
+		}
+	}
+	Log.Infof("wallet %d discovered accounts %v", id, used)
+	return used, nil
+}
+
+// 扫描当前钱包被使用过的子账户，gapLimit为0时使用配置或者默认值
+func (p *Manager) DiscoverAccounts(gapLimit int) ([]uint32, error) {
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	return p.discoverAccounts(p.status.CurrentWallet, gapLimit)
+}
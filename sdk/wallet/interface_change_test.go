@@ -0,0 +1,45 @@
+package wallet
+
+import (
+	"testing"
+)
+
+func TestWalletChangeAddresses(t *testing.T) {
+	w, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := newAccountManagementAutoTestManager(t)
+	manager.wallet = w
+	manager.status.CurrentWallet = w.GetId()
+	manager.walletInfoMap[w.GetId()] = &WalletInfo{
+		WalletInDB: WalletInDB{Id: w.GetId(), Accounts: 2},
+		Wallet:     w,
+	}
+
+	// 没有打开找零配置，以前找零地址上的资金仍然属于钱包
+	if manager.getChangeAddressFor(w.GetAddress(), false) != "" {
+		t.Fatalf("change address should be disabled")
+	}
+	changes := manager.walletChangeAddresses()
+	if len(changes) != 2 || changes[w.GetAddressByIndex(1)] != w.GetChangeAddressByIndex(1) {
+		t.Fatalf("unexpected change addresses %v", changes)
+	}
+	if manager.changeAddressOf(w.GetAddress()) != w.GetChangeAddressByIndex(0) {
+		t.Fatalf("unexpected change address of %s", w.GetAddress())
+	}
+
+	pkScripts := manager.walletPkScripts()
+	if len(pkScripts) != 3 {
+		t.Fatalf("expected 3 pkScripts, got %d", len(pkScripts))
+	}
+	for _, addr := range changes {
+		pkScript, err := GetPkScriptFromAddress(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isPkScriptIn(pkScript, pkScripts) {
+			t.Fatalf("change address %s is not in wallet pkScripts", addr)
+		}
+	}
+}
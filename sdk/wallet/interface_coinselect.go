@@ -0,0 +1,375 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	indexerwire "github.com/sat20-labs/indexer/rpcserver/wire"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+const (
+	COIN_SELECT_BNB         = "bnb"
+	COIN_SELECT_CONSOLIDATE = "consolidate"
+	COIN_SELECT_PRIVACY     = "privacy"
+	COIN_SELECT_LEGACY      = "legacy"
+)
+
+// 设置选币策略，空字符串恢复配置中的策略
+func (p *Manager) SetCoinSelection(strategy string) error {
+	switch strategy {
+	case "", COIN_SELECT_BNB, COIN_SELECT_CONSOLIDATE, COIN_SELECT_PRIVACY, COIN_SELECT_LEGACY:
+	default:
+		return fmt.Errorf("invalid coin selection %s", strategy)
+	}
+	p.coinMutex.Lock()
+	defer p.coinMutex.Unlock()
+	p.coinSelection = strategy
+	return nil
+}
+
+func (p *Manager) GetCoinSelection() string {
+	p.coinMutex.RLock()
+	defer p.coinMutex.RUnlock()
+	if p.coinSelection != "" {
+		return p.coinSelection
+	}
+	if p.cfg != nil && p.cfg.Wallet.CoinSelection != "" {
+		return p.cfg.Wallet.CoinSelection
+	}
+	return COIN_SELECT_BNB
+}
+
+// legacy 返回nil，使用原来的规则
+func (p *Manager) getCoinSelector() CoinSelector {
+	switch p.GetCoinSelection() {
+	case COIN_SELECT_LEGACY:
+		return nil
+	case COIN_SELECT_CONSOLIDATE:
+		maxFeeRate := int64(DEFAULT_CONSOLIDATE_FEERATE)
+		if p.cfg != nil && p.cfg.Wallet.ConsolidateFeeRate > 0 {
+			maxFeeRate = p.cfg.Wallet.ConsolidateFeeRate
+		}
+		return &ConsolidateCoinSelector{
+			MaxFeeRate: maxFeeRate,
+			MaxInputs:  DEFAULT_CONSOLIDATE_MAX_INPUTS,
+			Fallback:   &BnBCoinSelector{},
+		}
+	case COIN_SELECT_PRIVACY:
+		return &PrivacyCoinSelector{Fallback: &GreedyCoinSelector{}}
+	default:
+		return &BnBCoinSelector{}
+	}
+}
+
+// 原来的规则也不能选择 coin control 中排除的utxo和标记为不可花费的utxo，返回新的map
+func (p *Manager) coinControlExcluded(excludedUtxoMap map[string]bool, control *CoinControl) map[string]bool {
+	var exclude []string
+	if control != nil {
+		exclude = append(exclude, control.Exclude...)
+	}
+	exclude = append(exclude, p.unspendableOutputs()...)
+	if len(exclude) == 0 {
+		return excludedUtxoMap
+	}
+	result := make(map[string]bool, len(excludedUtxoMap)+len(exclude))
+	for k, v := range excludedUtxoMap {
+		result[k] = v
+	}
+	for _, utxo := range exclude {
+		result[utxo] = true
+	}
+	return result
+}
+
+// 按照选币策略选择，失败时返回nil，由调用方使用原来的规则。
+// 指定了必须花费的utxo时，legacy 也用从大到小的规则补足，失败时返回错误，调用方不能再使用原来的规则
+func (p *Manager) selectCoins(candidates []*CoinCandidate, params *CoinSelectParams,
+	control *CoinControl) ([]*CoinCandidate, error) {
+	return p.selectCoinsWith(p.getCoinSelector(), candidates, params, control)
+}
+
+func (p *Manager) selectCoinsWith(selector CoinSelector, candidates []*CoinCandidate,
+	params *CoinSelectParams, control *CoinControl) ([]*CoinCandidate, error) {
+	if params.Target <= 0 {
+		return nil, nil
+	}
+	if selector == nil {
+		if !control.hasInclude() {
+			return nil, nil
+		}
+		selector = &GreedyCoinSelector{}
+	}
+	selected, err := SelectCoins(selector, candidates, params, control)
+	if err != nil {
+		Log.Debugf("%s coin selection failed. %v", selector.Name(), err)
+		if control.hasInclude() {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return selected, nil
+}
+
+// L1 白聪选币的参数，weightEstimate 是还没有增加这些输入的交易
+func coinSelectParamsL1(target, feeRate int64, weightEstimate *utils.TxWeightEstimator, inChannel bool) *CoinSelectParams {
+	withInput := *weightEstimate
+	if inChannel {
+		withInput.AddWitnessInput(utils.MultiSigWitnessSize)
+	} else {
+		withInput.AddTaprootKeySpendInput(txscript.SigHashDefault)
+	}
+	withChange := *weightEstimate
+	withChange.AddP2TROutput()
+	base := weightEstimate.Fee(feeRate)
+	return &CoinSelectParams{
+		Target: target + base,
+		// 每个输入多算1聪，避免取整带来的误差
+		InputFee:     withInput.Fee(feeRate) - base + 1,
+		CostOfChange: withChange.Fee(feeRate) - base + DefaultMinChangeValue,
+		FeeRate:      feeRate,
+	}
+}
+
+// L1 可以用来支付的白聪
+func (p *Manager) plainCoinCandidates(utxos []*indexerwire.TxOutputInfo, excludedUtxoMap map[string]bool,
+	excludeRecentBlock bool, maxConfirmedInputHeight int) ([]*CoinCandidate, map[string]*indexerwire.TxOutputInfo) {
+
+	candidates := make([]*CoinCandidate, 0, len(utxos))
+	utxoMap := make(map[string]*indexerwire.TxOutputInfo, len(utxos))
+	for _, u := range utxos {
+		if _, ok := excludedUtxoMap[u.OutPoint]; ok {
+			continue
+		}
+		if p.utxoLockerL1.IsLocked(u.OutPoint) {
+			continue
+		}
+		if excludeRecentBlock && p.IsRecentBlockUtxo(u.UtxoId) {
+			continue
+		}
+		if p.confirmedUtxoAfterHeight(u.UtxoId, maxConfirmedInputHeight) {
+			continue
+		}
+		if u.Value == 330 {
+			continue
+		}
+		if _, ok := utxoMap[u.OutPoint]; ok {
+			continue
+		}
+		utxoMap[u.OutPoint] = u
+		candidates = append(candidates, &CoinCandidate{
+			OutPoint: u.OutPoint,
+			Value:    u.Value,
+			PkScript: u.PkScript,
+		})
+	}
+	return candidates, utxoMap
+}
+
+// 聪网可以用来支付的白聪，聪网的网络费是固定的，不需要考虑输入的大小
+func (p *Manager) plainCoinCandidates_SatsNet(utxos []*indexerwire.TxOutputInfo,
+	excludedUtxoMap map[string]bool) ([]*CoinCandidate, map[string]*TxOutput_SatsNet) {
+
+	candidates := make([]*CoinCandidate, 0, len(utxos))
+	utxoMap := make(map[string]*TxOutput_SatsNet, len(utxos))
+	for _, u := range utxos {
+		if _, ok := excludedUtxoMap[u.OutPoint]; ok {
+			continue
+		}
+		if p.utxoLockerL2.IsLocked(u.OutPoint) {
+			continue
+		}
+		if _, ok := utxoMap[u.OutPoint]; ok {
+			continue
+		}
+		output := OutputInfoToOutput_SatsNet(u)
+		utxoMap[u.OutPoint] = output
+		candidates = append(candidates, &CoinCandidate{
+			OutPoint: u.OutPoint,
+			Value:    output.GetPlainSat(),
+			PkScript: u.PkScript,
+		})
+	}
+	return candidates, utxoMap
+}
+
+// 资产数量的最小单位，精度和需要的数量不同或者超出int64时返回false
+func assetCoinValue(amt, requiredAmt *Decimal) (int64, bool) {
+	if requiredAmt == nil || requiredAmt.Value == nil {
+		return 0, false
+	}
+	if amt == nil || amt.Value == nil {
+		return 0, true
+	}
+	if amt.Precision != requiredAmt.Precision || !amt.Value.IsInt64() {
+		return 0, false
+	}
+	return amt.Value.Int64(), true
+}
+
+// 资产按照数量选币，不考虑网络费：bnb 寻找不需要资产找零的组合。
+// L1 每个输入都要支付网络费，不使用合并策略。失败时返回nil，由调用方使用原来的规则
+func (p *Manager) selectAssetCoins(candidates []*CoinCandidate, requiredAmt *Decimal,
+	satsNet bool, control *CoinControl) ([]*CoinCandidate, error) {
+	target, ok := assetCoinValue(requiredAmt, requiredAmt)
+	if !ok || candidates == nil {
+		return nil, nil
+	}
+	selector := p.getCoinSelector()
+	if _, ok := selector.(*ConsolidateCoinSelector); ok && !satsNet {
+		selector = &BnBCoinSelector{}
+	}
+	return p.selectCoinsWith(selector, candidates, &CoinSelectParams{Target: target}, control)
+}
+
+// L1 包含指定资产的utxo，有资产数量不能转换时返回nil
+func (p *Manager) assetCoinCandidates(utxos []*indexerwire.TxOutputInfo, excludedUtxoMap map[string]bool,
+	assetName *indexer.AssetName, requiredAmt *Decimal,
+	excludeRecentBlock bool, maxConfirmedInputHeight int) ([]*CoinCandidate, map[string]*TxOutput) {
+
+	candidates := make([]*CoinCandidate, 0, len(utxos))
+	utxoMap := make(map[string]*TxOutput, len(utxos))
+	for _, u := range utxos {
+		if _, ok := excludedUtxoMap[u.OutPoint]; ok {
+			continue
+		}
+		if p.utxoLockerL1.IsLocked(u.OutPoint) {
+			continue
+		}
+		if excludeRecentBlock && p.IsRecentBlockUtxo(u.UtxoId) {
+			continue
+		}
+		if p.confirmedUtxoAfterHeight(u.UtxoId, maxConfirmedInputHeight) {
+			continue
+		}
+		if _, ok := utxoMap[u.OutPoint]; ok {
+			continue
+		}
+		txOut := OutputInfoToOutput(u)
+		if HasMultiAsset(txOut, assetName) {
+			continue
+		}
+		value, ok := assetCoinValue(txOut.GetAsset(assetName), requiredAmt)
+		if !ok {
+			return nil, nil
+		}
+		utxoMap[u.OutPoint] = txOut
+		candidates = append(candidates, &CoinCandidate{
+			OutPoint: u.OutPoint,
+			Value:    value,
+			PkScript: u.PkScript,
+		})
+	}
+	return candidates, utxoMap
+}
+
+// 聪网包含指定资产的utxo，有资产数量不能转换时返回nil
+func (p *Manager) assetCoinCandidates_SatsNet(utxos []*indexerwire.TxOutputInfo, excludedUtxoMap map[string]bool,
+	assetName *indexer.AssetName, requiredAmt *Decimal) ([]*CoinCandidate, map[string]*TxOutput_SatsNet) {
+
+	candidates := make([]*CoinCandidate, 0, len(utxos))
+	utxoMap := make(map[string]*TxOutput_SatsNet, len(utxos))
+	for _, u := range utxos {
+		if _, ok := excludedUtxoMap[u.OutPoint]; ok {
+			continue
+		}
+		if p.utxoLockerL2.IsLocked(u.OutPoint) {
+			continue
+		}
+		if _, ok := utxoMap[u.OutPoint]; ok {
+			continue
+		}
+		output := OutputInfoToOutput_SatsNet(u)
+		value, ok := assetCoinValue(output.GetAsset(assetName), requiredAmt)
+		if !ok {
+			return nil, nil
+		}
+		utxoMap[u.OutPoint] = output
+		candidates = append(candidates, &CoinCandidate{
+			OutPoint: u.OutPoint,
+			Value:    value,
+			PkScript: u.PkScript,
+		})
+	}
+	return candidates, utxoMap
+}
+
+// 用选好的白聪作为输入，网络费足够时加入交易，返回找零和不含找零输出的网络费
+func (p *Manager) addSelectedPlainCoins(coins []*CoinCandidate, utxoMap map[string]*indexerwire.TxOutputInfo,
+	tx *wire.MsgTx, weightEstimate *utils.TxWeightEstimator, requiredValue, feeRate int64,
+	inChannel bool) (*txscript.MultiPrevOutFetcher, int64, int64, bool) {
+
+	localWeightEstimate := *weightEstimate
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	txIns := make([]*wire.TxIn, 0, len(coins))
+	var total int64
+	for _, coin := range coins {
+		txOut := OutputInfoToOutput(utxoMap[coin.OutPoint])
+		outpoint := txOut.OutPoint()
+		out := txOut.OutValue
+		txIns = append(txIns, wire.NewTxIn(outpoint, nil, nil))
+		prevFetcher.AddPrevOut(*outpoint, &out)
+		if inChannel {
+			localWeightEstimate.AddWitnessInput(utils.MultiSigWitnessSize)
+		} else {
+			localWeightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		}
+		total += out.Value
+	}
+	fee0 := localWeightEstimate.Fee(feeRate)
+	changeOutput := total - requiredValue - fee0
+	if changeOutput < 0 {
+		return nil, 0, 0, false
+	}
+	*weightEstimate = localWeightEstimate
+	for _, txIn := range txIns {
+		tx.AddTxIn(txIn)
+	}
+	return prevFetcher, changeOutput, fee0, true
+}
+
+// 按照选币策略选择支付网络费的白聪，feeValue 是已经有的网络费
+func (p *Manager) selectFeeCoinsV3(feeOutputs []*indexerwire.TxOutputInfo, excludedUtxoMap map[string]bool,
+	feeValue, feeRate int64, weightEstimate *utils.TxWeightEstimator,
+	excludeRecentBlock, inChannel bool, maxConfirmedInputHeight int, control *CoinControl) ([]*TxOutput, int64, bool) {
+
+	candidates, utxoMap := p.plainCoinCandidates(feeOutputs, excludedUtxoMap,
+		excludeRecentBlock, maxConfirmedInputHeight)
+	// 需要的是网络费中还不够的部分
+	coins, err := p.selectCoins(candidates, coinSelectParamsL1(-feeValue, feeRate, weightEstimate, inChannel), control)
+	if err != nil || len(coins) == 0 {
+		return nil, 0, false
+	}
+	localWeightEstimate := *weightEstimate
+	selected := make([]*TxOutput, 0, len(coins))
+	for _, coin := range coins {
+		output := OutputInfoToOutput(utxoMap[coin.OutPoint])
+		selected = append(selected, output)
+		feeValue += output.OutValue.Value
+		if inChannel {
+			localWeightEstimate.AddWitnessInput(utils.MultiSigWitnessSize)
+		} else {
+			localWeightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		}
+	}
+	if feeValue < localWeightEstimate.Fee(feeRate) {
+		return nil, 0, false
+	}
+	*weightEstimate = localWeightEstimate
+	return selected, feeValue, true
+}
+
+// requiredValue 已经包含了网络费，不需要考虑输入的大小
+func (p *Manager) selectFlatFeeCoins(utxos []*indexerwire.TxOutputInfo, excludedUtxoMap map[string]bool,
+	requiredValue int64, excludeRecentBlock bool) []*TxOutput {
+
+	candidates, utxoMap := p.plainCoinCandidates(utxos, excludedUtxoMap, excludeRecentBlock, 0)
+	coins, _ := p.selectCoins(candidates, &CoinSelectParams{Target: requiredValue}, nil)
+	result := make([]*TxOutput, 0, len(coins))
+	for _, coin := range coins {
+		result = append(result, utxoMap[coin.OutPoint].ToTxOutput())
+	}
+	return result
+}
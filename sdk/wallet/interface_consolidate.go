@@ -0,0 +1,442 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/indexer/indexer/runes/runestone"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+/*
+合并碎片utxo
+1. 白聪：多个utxo合并成一个输出，网络费从合并的聪中扣除
+2. ordx：资产utxo的所有聪按顺序进入第一个输出，保持聪的范围，网络费由另外的白聪支付
+3. runes：资产utxo合并到一个330聪的输出，通过 runestone edict 指定全部资产，网络费由另外的白聪支付
+被锁定的utxo，通道正在使用的utxo，coin control 排除的utxo，都不会被合并
+生成计划时预留所有输入（UtxoLocker.TryReserve），执行后转为广播的锁定，执行失败或者取消时释放
+*/
+
+const (
+	DEFAULT_CONSOLIDATE_MIN_UTXOS = 10
+	CONSOLIDATE_LOCK_REASON       = "consolidate"
+)
+
+type ConsolidationPlan struct {
+	Address   string   `json:"address"`
+	AssetName string   `json:"assetName"`
+	Protocol  string   `json:"protocol"`
+	Inputs    []string `json:"inputs"`    // 被合并的utxo
+	FeeInputs []string `json:"feeInputs"` // 支付网络费的白聪
+	AssetAmt  string   `json:"assetAmt"`  // 合并后的资产数量
+	Value     int64    `json:"value"`     // 合并后的utxo的聪数量
+	FeeRate   int64    `json:"feeRate"`
+	Fee       int64    `json:"fee"`
+
+	tx            *wire.MsgTx
+	prevFetcher   *txscript.MultiPrevOutFetcher
+	reservationID string
+}
+
+func (p *ConsolidationPlan) reservedUtxos() []string {
+	result := make([]string, 0, len(p.tx.TxIn))
+	for _, txIn := range p.tx.TxIn {
+		result = append(result, txIn.PreviousOutPoint.String())
+	}
+	return result
+}
+
+// 在监控线程中，费率足够低的时候自动合并
+type AutoConsolidation struct {
+	Assets     []string `json:"assets"`
+	MaxFeeRate int64    `json:"maxFeeRate"`
+	MinUtxos   int      `json:"minUtxos"`
+}
+
+// 通道的资金utxo，以及开通道和拼接过程中预留的utxo
+func (p *Manager) channelManagedUtxos() map[string]bool {
+	result := make(map[string]bool)
+	add := func(outputs ...*TxOutput) {
+		for _, output := range outputs {
+			if output != nil {
+				result[output.OutPointStr] = true
+			}
+		}
+	}
+	for _, channel := range p.GetAllChannels() {
+		if channel != nil {
+			add(channel.GetChanPoint())
+		}
+	}
+	for _, resv := range p.GetFundingReservations() {
+		if resv != nil {
+			add(resv.FundingUtxos...)
+		}
+	}
+	for _, resv := range p.GetSplicingReservations() {
+		if resv != nil {
+			add(resv.SplicingInputs...)
+			add(resv.Fees...)
+			add(resv.StubUtxo)
+		}
+	}
+	return result
+}
+
+// 只合并单一资产的utxo，从小到大，最多 maxInputs 个
+func pickConsolidationInputs(outputs []*TxOutput, assetName *indexer.AssetName, maxInputs int) []*TxOutput {
+	plain := indexer.IsPlainAsset(assetName)
+	result := make([]*TxOutput, 0, len(outputs))
+	for _, output := range outputs {
+		if plain {
+			// 330聪的utxo是特意切割出来的桩
+			if output.OutValue.Value <= 330 {
+				continue
+			}
+		} else if HasMultiAsset(output, assetName) {
+			continue
+		}
+		result = append(result, output)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !plain {
+			cmp := result[i].GetAsset(assetName).Cmp(result[j].GetAsset(assetName))
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		if result[i].OutValue.Value != result[j].OutValue.Value {
+			return result[i].OutValue.Value < result[j].OutValue.Value
+		}
+		return result[i].OutPointStr < result[j].OutPointStr
+	})
+	if maxInputs > 0 && len(result) > maxInputs {
+		result = result[:maxInputs]
+	}
+	return result
+}
+
+// 构造合并某个资产utxo的交易，不签名也不广播
+func (p *Manager) PlanConsolidation(address, assetNameStr string, feeRate int64) (*ConsolidationPlan, error) {
+	if address == "" {
+		if p.wallet == nil {
+			return nil, fmt.Errorf("wallet is not created/unlocked")
+		}
+		address = p.wallet.GetAddress()
+	}
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	var assetName *AssetName
+	name := ParseAssetString(assetNameStr)
+	if name == nil {
+		return nil, fmt.Errorf("invalid asset name %s", assetNameStr)
+	}
+	if indexer.IsPlainAsset(name) {
+		assetName = &AssetName{AssetName: ASSET_PLAIN_SAT}
+	} else {
+		tickerInfo := p.getTickerInfo(name)
+		if tickerInfo == nil {
+			return nil, fmt.Errorf("can't get ticker %s info", assetNameStr)
+		}
+		assetName = GetAssetName(tickerInfo)
+	}
+
+	excluded := p.coinControlExcluded(p.channelManagedUtxos(), nil)
+	utxos := p.l1IndexerClient.GetUtxoListWithTicker(address, &assetName.AssetName)
+	p.utxoLockerL1.Reload(address)
+	outputs := make([]*TxOutput, 0, len(utxos))
+	for _, u := range utxos {
+		if excluded[u.OutPoint] || p.utxoLockerL1.IsLocked(u.OutPoint) {
+			continue
+		}
+		outputs = append(outputs, OutputInfoToOutput(u))
+	}
+	selected := pickConsolidationInputs(outputs, &assetName.AssetName, DEFAULT_CONSOLIDATE_MAX_INPUTS)
+	if len(selected) < 2 {
+		return nil, fmt.Errorf("no enough utxos to consolidate, %d", len(selected))
+	}
+
+	plan := &ConsolidationPlan{
+		Address:   address,
+		AssetName: assetName.String(),
+		Protocol:  assetName.Protocol,
+		FeeRate:   feeRate,
+	}
+	switch assetName.Protocol {
+	case "": // btc
+		err = p.buildConsolidationTx_btc(plan, selected)
+	case indexer.PROTOCOL_NAME_ORDX, indexer.PROTOCOL_NAME_RUNES:
+		for _, output := range selected {
+			excluded[output.OutPointStr] = true
+		}
+		err = p.buildConsolidationTx_asset(plan, selected, assetName, excluded)
+	default:
+		return nil, fmt.Errorf("PlanConsolidation unsupport protocol %s", assetName.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 执行之前，其他交易不能使用这些utxo
+	plan.reservationID = "consolidate:" + plan.tx.TxHash().String()
+	err = p.utxoLockerL1.TryReserve(plan.reservedUtxos(), CONSOLIDATE_LOCK_REASON, plan.reservationID)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// 不执行计划时释放预留的utxo
+func (p *Manager) CancelConsolidation(plan *ConsolidationPlan) error {
+	if plan == nil || plan.tx == nil || plan.reservationID == "" {
+		return nil
+	}
+	return p.utxoLockerL1.ReleaseReservation(plan.reservedUtxos(), plan.reservationID)
+}
+
+func (p *Manager) buildConsolidationTx_btc(plan *ConsolidationPlan, selected []*TxOutput) error {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var weightEstimate utils.TxWeightEstimator
+	var total int64
+	for _, output := range selected {
+		tx.AddTxIn(output.TxIn())
+		prevFetcher.AddPrevOut(*output.OutPoint(), &output.OutValue)
+		weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		total += output.OutValue.Value
+		plan.Inputs = append(plan.Inputs, output.OutPointStr)
+	}
+	weightEstimate.AddP2TROutput()
+	fee := weightEstimate.Fee(plan.FeeRate)
+	value := total - fee
+	if value < 330 {
+		return fmt.Errorf("no enough plain sats to consolidate, total %d, fee %d", total, fee)
+	}
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: selected[0].OutValue.PkScript,
+		Value:    value,
+	})
+
+	plan.AssetAmt = fmt.Sprintf("%d", value)
+	plan.Value = value
+	plan.Fee = fee
+	plan.tx = tx
+	plan.prevFetcher = prevFetcher
+	return nil
+}
+
+func (p *Manager) buildConsolidationTx_asset(plan *ConsolidationPlan, selected []*TxOutput,
+	assetName *AssetName, excluded map[string]bool) error {
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var weightEstimate utils.TxWeightEstimator
+	var total int64
+	var totalAsset *Decimal
+	for _, output := range selected {
+		tx.AddTxIn(output.TxIn())
+		prevFetcher.AddPrevOut(*output.OutPoint(), &output.OutValue)
+		weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		total += output.OutValue.Value
+		totalAsset = totalAsset.Add(output.GetAsset(&assetName.AssetName))
+		plan.Inputs = append(plan.Inputs, output.OutPointStr)
+	}
+	changePkScript := selected[0].OutValue.PkScript
+
+	var value int64
+	var nullDataScript []byte
+	if assetName.Protocol == indexer.PROTOCOL_NAME_RUNES {
+		runeId, err := p.getRuneIdFromName(&assetName.AssetName)
+		if err != nil {
+			return err
+		}
+		value = 330
+		edicts := []runestone.Edict{{
+			ID:     *runeId,
+			Output: 0,
+			Amount: totalAsset.ToUint128(),
+		}}
+		nullDataScript, err = EncipherRunePayloadWithPointer(edicts, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		// 所有的聪按顺序进入第一个输出，资产所在的聪不会进入网络费
+		value = total
+	}
+	txOut := &wire.TxOut{
+		PkScript: changePkScript,
+		Value:    value,
+	}
+	tx.AddTxOut(txOut)
+	weightEstimate.AddTxOutput(txOut)
+	if len(nullDataScript) > 0 {
+		weightEstimate.AddOutput(nullDataScript)
+	}
+
+	utxoMgr := NewUtxoMgr(plan.Address, p.l1IndexerClient)
+	fee, err := p.buildBatchSendTxV3_fee(utxoMgr, excluded, false, false,
+		tx, &weightEstimate, prevFetcher, total-value, plan.FeeRate, changePkScript)
+	if err != nil {
+		return err
+	}
+	if len(nullDataScript) > 0 {
+		tx.AddTxOut(&wire.TxOut{
+			PkScript: nullDataScript,
+			Value:    0,
+		})
+	}
+	for _, txIn := range tx.TxIn[len(selected):] {
+		plan.FeeInputs = append(plan.FeeInputs, txIn.PreviousOutPoint.String())
+	}
+
+	plan.AssetAmt = totalAsset.String()
+	plan.Value = value
+	plan.Fee = fee
+	plan.tx = tx
+	plan.prevFetcher = prevFetcher
+	return nil
+}
+
+// 签名并广播 PlanConsolidation 构造的交易，失败时释放预留的utxo
+func (p *Manager) ExecuteConsolidation(plan *ConsolidationPlan) (string, error) {
+	if plan == nil || plan.tx == nil {
+		return "", fmt.Errorf("invalid consolidation plan")
+	}
+	txId, err := p.executeConsolidation(plan)
+	if err != nil {
+		if err2 := p.CancelConsolidation(plan); err2 != nil {
+			Log.Errorf("CancelConsolidation failed. %v", err2)
+		}
+		return "", err
+	}
+	return txId, nil
+}
+
+func (p *Manager) executeConsolidation(plan *ConsolidationPlan) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	if plan.Address != p.wallet.GetAddress() {
+		return "", fmt.Errorf("can't sign utxos of %s", plan.Address)
+	}
+	// 预留已经被释放或者被其他交易占用时，需要重新生成计划
+	managed := p.channelManagedUtxos()
+	for _, utxo := range plan.reservedUtxos() {
+		if managed[utxo] {
+			return "", fmt.Errorf("utxo %s is in use, plan again", utxo)
+		}
+	}
+	err := p.utxoLockerL1.TryReserve(plan.reservedUtxos(), CONSOLIDATE_LOCK_REASON, plan.reservationID)
+	if err != nil {
+		return "", fmt.Errorf("%w, plan again", err)
+	}
+
+	tx := plan.tx.Copy()
+	// 支持RBF加速
+	enableRBF(tx)
+	tx, err = p.SignTx(tx, plan.prevFetcher)
+	if err != nil {
+		Log.Errorf("SignTx failed. %v", err)
+		return "", err
+	}
+	err = p.BroadcastTxs([]*wire.MsgTx{tx})
+	if err != nil {
+		Log.Errorf("BroadcastTxs failed. %v", err)
+		return "", err
+	}
+	// 和其他广播的交易一样锁定，确认后自动解锁
+	err = p.utxoLockerL1.FinalizeReservation(plan.reservedUtxos(), plan.reservationID, "broadcasted")
+	if err != nil {
+		Log.Errorf("FinalizeReservation failed. %v", err)
+	}
+
+	Log.Infof("ExecuteConsolidation %s succeed. %s %d inputs, fee %d", plan.AssetName,
+		tx.TxID(), len(plan.Inputs), plan.Fee)
+	return tx.TxID(), nil
+}
+
+// nil 恢复使用配置
+func (p *Manager) SetAutoConsolidation(auto *AutoConsolidation) {
+	p.coinMutex.Lock()
+	defer p.coinMutex.Unlock()
+	if auto == nil {
+		p.autoConsolidation = nil
+		return
+	}
+	p.autoConsolidation = &AutoConsolidation{
+		Assets:     append([]string(nil), auto.Assets...),
+		MaxFeeRate: auto.MaxFeeRate,
+		MinUtxos:   auto.MinUtxos,
+	}
+}
+
+func (p *Manager) GetAutoConsolidation() *AutoConsolidation {
+	p.coinMutex.RLock()
+	defer p.coinMutex.RUnlock()
+	result := &AutoConsolidation{}
+	if p.autoConsolidation != nil {
+		*result = *p.autoConsolidation
+		result.Assets = append([]string(nil), p.autoConsolidation.Assets...)
+	} else if p.cfg != nil {
+		result.Assets = append([]string(nil), p.cfg.Wallet.AutoConsolidate...)
+		result.MaxFeeRate = p.cfg.Wallet.ConsolidateFeeRate
+		result.MinUtxos = p.cfg.Wallet.ConsolidateMinUtxos
+	}
+	if result.MaxFeeRate <= 0 {
+		result.MaxFeeRate = DEFAULT_CONSOLIDATE_FEERATE
+	}
+	if result.MinUtxos <= 0 {
+		result.MinUtxos = DEFAULT_CONSOLIDATE_MIN_UTXOS
+	}
+	return result
+}
+
+// 每个新区块检查一次，费率不超过 MaxFeeRate 时合并utxo数量达到 MinUtxos 的资产
+func (p *Manager) handleConsolidationMonitorTick(sendTxInL1 bool) {
+	if p == nil || !sendTxInL1 || p.wallet == nil {
+		return
+	}
+	auto := p.GetAutoConsolidation()
+	if len(auto.Assets) == 0 {
+		return
+	}
+	height := p.GetSyncHeightL1()
+	if height <= 0 {
+		return
+	}
+	p.coinMutex.Lock()
+	if p.consolidateLastHeight == height {
+		p.coinMutex.Unlock()
+		return
+	}
+	p.consolidateLastHeight = height
+	p.coinMutex.Unlock()
+
+	feeRate := p.GetFeeRateByTarget(FEE_TARGET_ECONOMY)
+	if feeRate > auto.MaxFeeRate {
+		return
+	}
+	for _, asset := range auto.Assets {
+		plan, err := p.PlanConsolidation("", asset, feeRate)
+		if err != nil {
+			Log.Debugf("PlanConsolidation %s failed. %v", asset, err)
+			continue
+		}
+		if len(plan.Inputs) < auto.MinUtxos {
+			if err := p.CancelConsolidation(plan); err != nil {
+				Log.Errorf("CancelConsolidation %s failed. %v", asset, err)
+			}
+			continue
+		}
+		_, err = p.ExecuteConsolidation(plan)
+		if err != nil {
+			Log.Errorf("ExecuteConsolidation %s failed. %v", asset, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	"github.com/sat20-labs/indexer/indexer/runes/runestone"
+	indexerwire "github.com/sat20-labs/indexer/rpcserver/wire"
+)
+
+func TestPickConsolidationInputs(t *testing.T) {
+	plain := []*TxOutput{
+		{OutPointStr: "a:0", OutValue: *wire.NewTxOut(5000, nil)},
+		{OutPointStr: "b:0", OutValue: *wire.NewTxOut(330, nil)},
+		{OutPointStr: "c:0", OutValue: *wire.NewTxOut(1000, nil)},
+		{OutPointStr: "d:0", OutValue: *wire.NewTxOut(1000, nil)},
+	}
+	selected := pickConsolidationInputs(plain, &indexer.ASSET_PLAIN_SAT, 2)
+	if len(selected) != 2 || selected[0].OutPointStr != "c:0" || selected[1].OutPointStr != "d:0" {
+		t.Fatalf("unexpected plain inputs %v", selected)
+	}
+
+	asset := indexer.NewAssetNameFromString("runes:f:TEST•RUNE")
+	other := indexer.NewAssetNameFromString("runes:f:OTHER•RUNE")
+	withAsset := func(outpoint string, amt int64, names ...*indexer.AssetName) *TxOutput {
+		output := &TxOutput{OutPointStr: outpoint, OutValue: *wire.NewTxOut(330, nil)}
+		for _, name := range names {
+			output.Assets = append(output.Assets, indexer.AssetInfo{
+				Name: *name, Amount: *indexer.NewDefaultDecimal(amt), BindingSat: 0,
+			})
+		}
+		return output
+	}
+	outputs := []*TxOutput{
+		withAsset("a:0", 500, asset),
+		withAsset("b:0", 100, asset),
+		withAsset("c:0", 50, asset, other),
+		withAsset("d:0", 300, asset),
+	}
+	selected = pickConsolidationInputs(outputs, asset, 0)
+	if len(selected) != 3 || selected[0].OutPointStr != "b:0" ||
+		selected[1].OutPointStr != "d:0" || selected[2].OutPointStr != "a:0" {
+		t.Fatalf("unexpected asset inputs %v", selected)
+	}
+}
+
+type consolidationTestIndexer struct {
+	IndexerRPCClient
+	utxos map[string][]*indexerwire.TxOutputInfo // key: 资产名字
+}
+
+func (f *consolidationTestIndexer) GetUtxoListWithTicker(address string, ticker *indexer.AssetName) []*indexerwire.TxOutputInfo {
+	return f.utxos[ticker.String()]
+}
+
+func newConsolidationTestManager(t *testing.T, utxos map[string][]*indexerwire.TxOutputInfo,
+	tickers ...*indexer.TickerInfo) *Manager {
+	t.Helper()
+	manager := newAccountManagementAutoTestManager(t)
+	l1 := NewIndexerRPCClientMgr()
+	l1.Set(&consolidationTestIndexer{utxos: utxos})
+	manager.l1IndexerClient = l1
+	for _, ticker := range tickers {
+		manager.tickerInfoMap[ticker.AssetName.String()] = ticker
+	}
+	return manager
+}
+
+func consolidationTestOutput(index int, value int64, pkScript []byte, assets ...*indexer.DisplayAsset) *indexerwire.TxOutputInfo {
+	return &indexerwire.TxOutputInfo{
+		OutPoint: fmt.Sprintf("%064x:0", index),
+		Value:    value,
+		PkScript: pkScript,
+		Assets:   assets,
+	}
+}
+
+// 输入减去输出等于网络费
+func checkConsolidationFee(t *testing.T, plan *ConsolidationPlan) {
+	t.Helper()
+	var in, out int64
+	for _, txIn := range plan.tx.TxIn {
+		in += plan.prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint).Value
+	}
+	for _, txOut := range plan.tx.TxOut {
+		out += txOut.Value
+	}
+	if in-out != plan.Fee || plan.Fee <= 0 {
+		t.Fatalf("fee mismatch, inputs %d outputs %d fee %d", in, out, plan.Fee)
+	}
+}
+
+func TestPlanConsolidationRunes(t *testing.T) {
+	w, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := w.GetAddress()
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runeName := indexer.NewAssetNameFromString("runes:f:TEST•RUNE")
+	ticker := &indexer.TickerInfo{AssetName: *runeName, DisplayName: "840000:1", Divisibility: 0}
+	runeAsset := func(amt int64) *indexer.DisplayAsset {
+		return &indexer.DisplayAsset{AssetName: *runeName, Amount: fmt.Sprintf("%d", amt)}
+	}
+	manager := newConsolidationTestManager(t, map[string][]*indexerwire.TxOutputInfo{
+		runeName.String(): {
+			consolidationTestOutput(1, 330, pkScript, runeAsset(100)),
+			consolidationTestOutput(2, 330, pkScript, runeAsset(200)),
+			consolidationTestOutput(3, 330, pkScript, runeAsset(300)),
+		},
+		indexer.ASSET_PLAIN_SAT.String(): {
+			consolidationTestOutput(4, 100000, pkScript),
+		},
+	}, ticker)
+
+	plan, err := manager.PlanConsolidation(address, runeName.String(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Inputs) != 3 || len(plan.FeeInputs) != 1 || plan.AssetAmt != "600" || plan.Value != 330 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	tx := plan.tx
+	if tx.TxOut[0].Value != 330 || !bytes.Equal(tx.TxOut[0].PkScript, pkScript) {
+		t.Fatalf("unexpected asset output %+v", tx.TxOut[0])
+	}
+
+	// 最后一个输出是runestone，所有的资产都转到第一个输出
+	nullData := tx.TxOut[len(tx.TxOut)-1]
+	if nullData.Value != 0 {
+		t.Fatalf("runestone output should have no value")
+	}
+	stone := runestone.Runestone{}
+	result, err := stone.DecipherFromPkScript(nullData.PkScript)
+	if err != nil || result.Runestone == nil {
+		t.Fatalf("invalid runestone %v", err)
+	}
+	runeId, err := runestone.RuneIdFromString("840000:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edicts := result.Runestone.Edicts
+	if len(edicts) != 1 || edicts[0].ID != *runeId || edicts[0].Output != 0 ||
+		edicts[0].Amount != indexer.NewDefaultDecimal(600).ToUint128() {
+		t.Fatalf("unexpected edicts %v", edicts)
+	}
+	checkConsolidationFee(t, plan)
+}
+
+func TestPlanConsolidationOrdx(t *testing.T) {
+	w, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := w.GetAddress()
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ordxName := indexer.NewAssetNameFromString("ordx:f:pearl")
+	ticker := &indexer.TickerInfo{AssetName: *ordxName, N: 1, Divisibility: 0}
+	ordxAsset := func(amt int64) *indexer.DisplayAsset {
+		return &indexer.DisplayAsset{
+			AssetName:  *ordxName,
+			Amount:     fmt.Sprintf("%d", amt),
+			BindingSat: 1,
+			Offsets:    indexer.AssetOffsets{{Start: 0, End: amt}},
+		}
+	}
+	manager := newConsolidationTestManager(t, map[string][]*indexerwire.TxOutputInfo{
+		ordxName.String(): {
+			consolidationTestOutput(1, 1000, pkScript, ordxAsset(1000)),
+			consolidationTestOutput(2, 2000, pkScript, ordxAsset(2000)),
+		},
+		indexer.ASSET_PLAIN_SAT.String(): {
+			consolidationTestOutput(3, 100000, pkScript),
+		},
+	}, ticker)
+
+	plan, err := manager.PlanConsolidation(address, ordxName.String(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Inputs) != 2 || len(plan.FeeInputs) != 1 || plan.AssetAmt != "3000" || plan.Value != 3000 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	// 资产的聪全部按顺序进入第一个输出，网络费由白聪支付，没有runestone
+	tx := plan.tx
+	if tx.TxOut[0].Value != 3000 || !bytes.Equal(tx.TxOut[0].PkScript, pkScript) {
+		t.Fatalf("unexpected asset output %+v", tx.TxOut[0])
+	}
+	for i, txIn := range tx.TxIn[:2] {
+		if txIn.PreviousOutPoint.String() != plan.Inputs[i] {
+			t.Fatalf("asset inputs should come first, %s", txIn.PreviousOutPoint)
+		}
+	}
+	for _, txOut := range tx.TxOut {
+		if IsNullDataScript(txOut.PkScript) {
+			t.Fatalf("ordx consolidation should not have null data")
+		}
+	}
+	checkConsolidationFee(t, plan)
+}
+
+func TestConsolidationReservation(t *testing.T) {
+	w, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := w.GetAddress()
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := newConsolidationTestManager(t, map[string][]*indexerwire.TxOutputInfo{
+		indexer.ASSET_PLAIN_SAT.String(): {
+			consolidationTestOutput(1, 10000, pkScript),
+			consolidationTestOutput(2, 20000, pkScript),
+		},
+	})
+
+	plan, err := manager.PlanConsolidation(address, indexer.ASSET_PLAIN_SAT.String(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 计划中的utxo被预留，不能再被其他计划使用
+	for _, utxo := range plan.Inputs {
+		if !manager.utxoLockerL1.IsLocked(utxo) {
+			t.Fatalf("utxo %s is not reserved", utxo)
+		}
+	}
+	if _, err := manager.PlanConsolidation(address, indexer.ASSET_PLAIN_SAT.String(), 2); err == nil {
+		t.Fatalf("reserved utxos should not be planned again")
+	}
+
+	// 执行失败时释放
+	if _, err := manager.ExecuteConsolidation(plan); err == nil {
+		t.Fatalf("execute without wallet should fail")
+	}
+	for _, utxo := range plan.Inputs {
+		if manager.utxoLockerL1.IsLocked(utxo) {
+			t.Fatalf("utxo %s is not released", utxo)
+		}
+	}
+
+	plan, err = manager.PlanConsolidation(address, indexer.ASSET_PLAIN_SAT.String(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CancelConsolidation(plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, utxo := range plan.Inputs {
+		if manager.utxoLockerL1.IsLocked(utxo) {
+			t.Fatalf("utxo %s is not released", utxo)
+		}
+	}
+}
@@ -124,8 +124,9 @@ func (p *Manager) DeployContract_Remote(templateName, contractContent string,
 	if p.wallet == nil {
 		return "", 0, "", fmt.Errorf("wallet is not created/unlocked")
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, "", err
 	}
 
 	contract, err := ContractContentUnMarsh(templateName, contractContent)
@@ -316,6 +317,7 @@ func (p *Manager) InvokeContract_Satsnet(contractURL string, jsonInvokeParam str
 		return "", err
 	}
 	Log.Infof("invoke contract %s with txId %s", contractURL, tx.TxID())
+	p.onContractInvoked(contractURL, channelAddr, tx.TxID(), true)
 
 	return tx.TxID(), nil
 }
@@ -390,6 +392,7 @@ func (p *Manager) InvokeContractV2_Satsnet(contractURL string, jsonInvokeParam s
 	}
 
 	Log.Infof("invoke contract %s with txId %s", contractURL, txId)
+	p.onContractInvoked(contractURL, channelAddr, txId, true)
 
 	return txId, nil
 }
@@ -401,18 +404,42 @@ func (p *Manager) InvokeContractV2(contractURL string, jsonInvokeParam string,
 		return "", fmt.Errorf("wallet is not created/unlocked")
 	}
 
-	channelAddr, _, _, err := ParseContractURL(contractURL)
+	dest, nullDataScript, err := p.prepareInvokeContractV2(contractURL, jsonInvokeParam, assetName, amt)
+	if err != nil {
+		return "", err
+	}
+	channelAddr := dest.Address
+
+	// TODO 等主网支持多个op_return，就必须加上参数
+	// 这是默认行为，在主网只要有交易往这里面转资产，就自动触发穿越行为
+	// 原因：一方面op_return能写入的数据太少，另一方面runes还会占有，而主网只能有一个op_return
+	txId, fee, err := p.BatchSendAssetsV3([]*SendAssetInfo{dest}, assetName, feeRate, nullDataScript, "", false)
 	if err != nil {
+		Log.Errorf("BatchSendAssetsV3 %s failed", channelAddr)
 		return "", err
 	}
+	Log.Infof("invoke contract %s with txId %s %d", contractURL, txId, fee)
+	p.onContractInvoked(contractURL, channelAddr, txId, false)
+
+	return txId, nil
+}
+
+// 调用合约需要发送的资产和 OP_RETURN 数据
+func (p *Manager) prepareInvokeContractV2(contractURL string, jsonInvokeParam string,
+	assetName string, amt string) (*SendAssetInfo, []byte, error) {
+
+	channelAddr, _, _, err := ParseContractURL(contractURL)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// 调用合约的费用
 	runtime, fee, err := p.QueryFeeForInvokeContract(contractURL, jsonInvokeParam)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	if !runtime.IsActive() {
-		return "", fmt.Errorf("contract is not active")
+		return nil, nil, fmt.Errorf("contract is not active")
 	}
 
 	var nullDataScript []byte
@@ -420,16 +447,16 @@ func (p *Manager) InvokeContractV2(contractURL string, jsonInvokeParam string,
 	if asset.Protocol != indexer.PROTOCOL_NAME_RUNES { // TODO 等主网支持多个op_return后打开
 		wrapperParam, err := ConvertInvokeParam(jsonInvokeParam, true)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 		buf, err := wrapperParam.EncodeV2()
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 
 		_, asssetName, tc, err := ParseContractURL(contractURL)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 		relativePath := GenerateContractRelativePath(asssetName, tc)
 
@@ -441,23 +468,23 @@ func (p *Manager) InvokeContractV2(contractURL string, jsonInvokeParam string,
 
 		invoice, err := AbbrInvokeContractInvoice(&invoke)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 		nullDataScript, err = sindexer.NullDataScript(sindexer.CONTENT_TYPE_INVOKECONTRACT, invoice)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 	}
 
 	name := indexer.NewAssetNameFromString(assetName)
 	tickerInfo := p.getTickerInfo(name)
 	if tickerInfo == nil {
-		return "", fmt.Errorf("can't get ticker %s info", name)
+		return nil, nil, fmt.Errorf("can't get ticker %s info", name)
 	}
 
 	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	value := fee
@@ -473,17 +500,7 @@ func (p *Manager) InvokeContractV2(contractURL string, jsonInvokeParam string,
 		AssetAmt:  dAmt,
 	}
 
-	// TODO 等主网支持多个op_return，就必须加上参数
-	// 这是默认行为，在主网只要有交易往这里面转资产，就自动触发穿越行为
-	// 原因：一方面op_return能写入的数据太少，另一方面runes还会占有，而主网只能有一个op_return
-	txId, fee, err := p.BatchSendAssetsV3([]*SendAssetInfo{dest}, assetName, feeRate, nullDataScript, "", false)
-	if err != nil {
-		Log.Errorf("BatchSendAssetsV3 %s failed", channelAddr)
-		return "", err
-	}
-	Log.Infof("invoke contract %s with txId %s %d", contractURL, txId, fee)
-
-	return txId, nil
+	return dest, nullDataScript, nil
 }
 
 // 一个特殊的invoke
@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+)
+
+// 替换费率来源，nil 恢复默认
+func (p *Manager) SetFeeEstimator(estimator FeeEstimator) {
+	p.feeMutex.Lock()
+	defer p.feeMutex.Unlock()
+	p.feeEstimator = estimator
+	p.feeEstimatesL1 = nil
+	p.refreshTimeL1 = 0
+}
+
+// 没有指定时，根据配置选择；测试网络默认使用本地的费率
+func (p *Manager) getFeeEstimator() FeeEstimator {
+	if p.feeEstimator != nil {
+		return p.feeEstimator
+	}
+	var name string
+	if p.cfg != nil {
+		name = p.cfg.Wallet.FeeEstimator
+	}
+	switch name {
+	case FEE_ESTIMATOR_STATIC:
+		p.feeEstimator = NewStaticFeeEstimator(p.cfg.Wallet.StaticFees)
+	case FEE_ESTIMATOR_LOCAL:
+		p.feeEstimator = &LocalFeeEstimator{}
+	case FEE_ESTIMATOR_INDEXER:
+		p.feeEstimator = NewIndexerFeeEstimator(p.l1IndexerClient)
+	default:
+		if IsTestNet() {
+			p.feeEstimator = &LocalFeeEstimator{}
+		} else {
+			p.feeEstimator = NewIndexerFeeEstimator(p.l1IndexerClient)
+		}
+	}
+	return p.feeEstimator
+}
+
+// 最低转发费率和费率上限
+func (p *Manager) feeRateLimits() (int64, int64) {
+	minRelayFee := DEFAULT_MIN_RELAY_FEE
+	maxFeeRate := DEFAULT_MAX_FEE_RATE
+	if p.cfg != nil {
+		if p.cfg.Wallet.MinRelayFee > 0 {
+			minRelayFee = p.cfg.Wallet.MinRelayFee
+		}
+		if p.cfg.Wallet.MaxFeeRate > 0 {
+			maxFeeRate = p.cfg.Wallet.MaxFeeRate
+		}
+	}
+	if maxFeeRate < minRelayFee {
+		maxFeeRate = minRelayFee
+	}
+	return minRelayFee, maxFeeRate
+}
+
+// 各个模式的费率，缓存3分钟
+func (p *Manager) GetFeeEstimates() *FeeEstimates {
+	p.feeMutex.Lock()
+	defer p.feeMutex.Unlock()
+
+	now := time.Now().Unix()
+	if p.feeEstimatesL1 == nil || now-p.refreshTimeL1 > FEE_ESTIMATE_REFRESH_TIME {
+		minRelayFee, maxFeeRate := p.feeRateLimits()
+		estimator := p.getFeeEstimator()
+		fees, err := estimator.EstimateFees()
+		if err != nil {
+			Log.Errorf("%s EstimateFees failed. %v", estimator.Name(), err)
+			if p.feeEstimatesL1 == nil {
+				// 下次再尝试
+				fees = &FeeEstimates{
+					Fast:    DEFAULT_FALLBACK_FEE,
+					Normal:  DEFAULT_FALLBACK_FEE,
+					Economy: DEFAULT_FALLBACK_FEE,
+					Source:  "fallback",
+				}
+				fees.normalize(minRelayFee, maxFeeRate)
+				return fees
+			}
+		} else {
+			fees.normalize(minRelayFee, maxFeeRate)
+			p.feeEstimatesL1 = fees
+			p.refreshTimeL1 = now
+		}
+	}
+	fees := *p.feeEstimatesL1
+	return &fees
+}
+
+// 将 feeRate 参数（指定的费率或者确认目标）转换为实际的费率
+func (p *Manager) ResolveFeeRate(feeRate int64) (int64, error) {
+	if feeRate > 0 {
+		minRelayFee, maxFeeRate := p.feeRateLimits()
+		if feeRate > maxFeeRate {
+			return 0, fmt.Errorf("fee rate %d exceeds the max fee rate %d", feeRate, maxFeeRate)
+		}
+		if feeRate < minRelayFee {
+			Log.Warningf("fee rate %d is lower than min relay fee %d", feeRate, minRelayFee)
+			return minRelayFee, nil
+		}
+		return feeRate, nil
+	}
+	return p.GetFeeEstimates().rate(feeRate)
+}
+
+// 确认目标对应的费率，feeRate 大于0时直接返回，无效的目标使用 normal 模式
+func (p *Manager) GetFeeRateByTarget(feeRate int64) int64 {
+	if feeRate > 0 {
+		return feeRate
+	}
+	fees := p.GetFeeEstimates()
+	rate, err := fees.rate(feeRate)
+	if err != nil {
+		Log.Warningf("%v, use normal fee rate", err)
+		return fees.Normal
+	}
+	return rate
+}
+
+type FeePreview struct {
+	Mode    string `json:"mode"`
+	FeeRate int64  `json:"feeRate"` // sat/vB
+	VSize   int64  `json:"vsize"`
+	Fee     int64  `json:"fee"`
+}
+
+// 按照各个费率模式构造发送交易（不签名，不广播），返回交易大小和网络费，给界面选择费率。
+// customFeeRate 大于0时增加 custom 模式
+func (p *Manager) PreviewSendFees(destAddr string, assetName string,
+	amt string, customFeeRate int64) ([]*FeePreview, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	name := ParseAssetString(assetName)
+	if name == nil {
+		return nil, fmt.Errorf("invalid asset name %s", assetName)
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", assetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
+	if err != nil {
+		return nil, err
+	}
+	if dAmt.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amt")
+	}
+	newName := GetAssetName(tickerInfo)
+	localAddress := p.wallet.GetAddress()
+
+	modes := []string{FEE_MODE_FAST, FEE_MODE_NORMAL, FEE_MODE_ECONOMY}
+	if customFeeRate > 0 {
+		modes = append(modes, FEE_MODE_CUSTOM)
+	}
+	result := make([]*FeePreview, 0, len(modes))
+	for _, mode := range modes {
+		target, err := FeeTargetFromMode(mode, customFeeRate)
+		if err != nil {
+			return nil, err
+		}
+		feeRate, err := p.ResolveFeeRate(target)
+		if err != nil {
+			return nil, err
+		}
+
+		var tx *wire.MsgTx
+		var prevFetcher *txscript.MultiPrevOutFetcher
+		var fee int64
+		switch name.Protocol {
+		case "": // btc
+			tx, prevFetcher, fee, err = p.BuildBatchSendTx_btc(localAddress, destAddr, dAmt.Int64(), 1, nil, feeRate, nil, true, nil)
+		case indexer.PROTOCOL_NAME_ORDX:
+			tx, prevFetcher, fee, err = p.BuildBatchSendTx_ordx(localAddress, destAddr, newName, dAmt, 1, feeRate, nil, nil)
+		case indexer.PROTOCOL_NAME_RUNES:
+			tx, prevFetcher, fee, err = p.BuildBatchSendTx_runes(localAddress, destAddr, newName, dAmt, 1, feeRate, nil, nil)
+		default:
+			// brc20 需要先铸造transfer铭文，不能预先构造
+			return nil, fmt.Errorf("preview unsupport protocol %s", name.Protocol)
+		}
+		if err != nil {
+			Log.Errorf("build %s tx failed. %v", mode, err)
+			return nil, err
+		}
+
+		inputs := make([]*TxOutput, 0, len(tx.TxIn))
+		for _, in := range tx.TxIn {
+			txOut := prevFetcher.FetchPrevOutput(in.PreviousOutPoint)
+			if txOut == nil {
+				return nil, fmt.Errorf("can't find input %s", in.PreviousOutPoint.String())
+			}
+			inputs = append(inputs, &TxOutput{OutValue: *txOut})
+		}
+		result = append(result, &FeePreview{
+			Mode:    mode,
+			FeeRate: feeRate,
+			VSize:   estimateTxWeight(tx, inputs).VSize(),
+			Fee:     fee,
+		})
+	}
+	return result, nil
+}
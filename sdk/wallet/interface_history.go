@@ -0,0 +1,561 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+// 调用者需要持有 activityMutex
+func (p *Manager) loadActivitiesLocked() {
+	if p.activities != nil {
+		return
+	}
+	if p.db == nil {
+		p.activities = make(map[string]*ActivityItem)
+		return
+	}
+	p.activities = loadAllActivities(p.db)
+}
+
+func (p *Manager) saveActivityLocked(item *ActivityItem) {
+	p.activities[item.key()] = item
+	if p.db != nil {
+		err := saveActivity(p.db, item)
+		if err != nil {
+			Log.Errorf("saveActivity %s failed. %v", item.key(), err)
+		}
+	}
+}
+
+// 在锁内修改已有的记录，不存在时返回false
+func (p *Manager) modifyActivity(key string, fn func(item *ActivityItem)) bool {
+	p.activityMutex.Lock()
+	defer p.activityMutex.Unlock()
+	p.loadActivitiesLocked()
+	item, ok := p.activities[key]
+	if !ok {
+		return false
+	}
+	fn(item)
+	p.saveActivityLocked(item)
+	return true
+}
+
+// 新的记录，已经存在时保留标记的类型和操作，更新计算出来的资产变化
+func (p *Manager) recordActivity(item *ActivityItem) {
+	p.activityMutex.Lock()
+	defer p.activityMutex.Unlock()
+	p.loadActivitiesLocked()
+	old, ok := p.activities[item.key()]
+	if ok && old.Resolved && old.Status != ACTIVITY_STATUS_PENDING {
+		return
+	}
+	if ok {
+		item.Type = old.Type
+		item.Action = old.Action
+		item.Ref = old.Ref
+		item.CreateTime = old.CreateTime
+		if old.Status != ACTIVITY_STATUS_PENDING {
+			item.Status = old.Status
+			item.Height = old.Height
+			item.ConfirmTime = old.ConfirmTime
+		}
+	}
+	p.saveActivityLocked(item)
+}
+
+func (p *Manager) hasActivity(address string, satsNet bool, txId string) bool {
+	p.activityMutex.Lock()
+	defer p.activityMutex.Unlock()
+	p.loadActivitiesLocked()
+	_, ok := p.activities[activityKey(address, satsNet, txId, 0)]
+	return ok
+}
+
+// 本钱包当前账户的交易记录，包括通道支付和合约调用，按时间倒序
+func (p *Manager) GetActivity(filter *ActivityFilter) ([]*ActivityItem, int) {
+	if p.wallet == nil {
+		return nil, 0
+	}
+	address := p.wallet.GetAddress()
+
+	p.activityMutex.Lock()
+	p.loadActivitiesLocked()
+	items := make([]*ActivityItem, 0, len(p.activities))
+	for _, item := range p.activities {
+		if item.Address == address {
+			items = append(items, item)
+		}
+	}
+	page, total := filterActivities(items, filter)
+	result := make([]*ActivityItem, len(page))
+	for i, item := range page {
+		result[i] = item.Clone()
+	}
+	p.activityMutex.Unlock()
+
+	for _, item := range result {
+		if item.TxId != "" {
+			item.Label = p.GetLabelText(LABEL_TYPE_TX, item.TxId)
+		}
+	}
+	return result, total
+}
+
+func (p *Manager) GetActivityItem(txId string, satsNet bool) *ActivityItem {
+	if p.wallet == nil {
+		return nil
+	}
+	p.activityMutex.Lock()
+	p.loadActivitiesLocked()
+	item, ok := p.activities[activityKey(p.wallet.GetAddress(), satsNet, txId, 0)]
+	if ok {
+		item = item.Clone()
+	}
+	p.activityMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	item.Label = p.GetLabelText(LABEL_TYPE_TX, item.TxId)
+	return item
+}
+
+// 标记产生这个交易的操作
+func (p *Manager) annotateActivity(satsNet bool, txId, typ, action, ref string) {
+	if p.wallet == nil || txId == "" {
+		return
+	}
+	ok := p.modifyActivity(activityKey(p.wallet.GetAddress(), satsNet, txId, 0), func(item *ActivityItem) {
+		if typ != "" {
+			item.Type = typ
+		}
+		if action != "" {
+			item.Action = action
+		}
+		if ref != "" {
+			item.Ref = ref
+		}
+	})
+	if !ok {
+		Log.Debugf("annotateActivity can't find tx %s", txId)
+	}
+}
+
+// 广播成功后调用
+// 先记录交易，以便马上标记操作；查询输入需要访问索引器，在后台完成
+func (p *Manager) onTxBroadcasted(tx *wire.MsgTx) {
+	p.labelChangeOutputs(tx)
+	item := p.newTxActivity(tx.TxID(), false)
+	if item == nil {
+		return
+	}
+	p.recordActivity(item)
+	go func() {
+		inputs := p.recordTx(tx)
+		var txIds []string
+		for i, txIn := range tx.TxIn {
+			if inputs[i] != nil && inputs[i].Mine {
+				txIds = append(txIds, txIn.PreviousOutPoint.Hash.String())
+			}
+		}
+		p.recordInputTxs(item.Address, false, txIds)
+	}()
+}
+
+func (p *Manager) onTxBroadcasted_SatsNet(tx *swire.MsgTx) {
+	p.labelChangeOutputs_SatsNet(tx)
+	item := p.newTxActivity(tx.TxID(), true)
+	if item == nil {
+		return
+	}
+	p.recordActivity(item)
+	go func() {
+		inputs := p.recordTx_SatsNet(tx)
+		var txIds []string
+		for i, txIn := range tx.TxIn {
+			if inputs[i] != nil && inputs[i].Mine {
+				txIds = append(txIds, txIn.PreviousOutPoint.Hash.String())
+			}
+		}
+		p.recordInputTxs(item.Address, true, txIds)
+	}()
+}
+
+// 花费的输入来自的交易还没有记录时补充记录，这些收到后已经花费的交易扫描utxo时找不到
+func (p *Manager) recordInputTxs(address string, satsNet bool, txIds []string) {
+	recorded := make(map[string]bool)
+	for _, txId := range txIds {
+		if recorded[txId] || p.hasActivity(address, satsNet, txId) {
+			continue
+		}
+		recorded[txId] = true
+		p.recordTxFromIndexer(satsNet, txId)
+	}
+}
+
+func (p *Manager) onContractInvoked(contractURL, contractAddr, txId string, satsNet bool) {
+	p.labelContractInvoke(contractURL, contractAddr, txId)
+	p.annotateActivity(satsNet, txId, ACTIVITY_CONTRACT_INVOKE, "invoke", contractURL)
+}
+
+func (p *Manager) walletPkScripts_SatsNet() [][]byte {
+	pkScript, err := GetPkScriptFromAddress_SatsNet(p.wallet.GetAddress())
+	if err != nil {
+		return nil
+	}
+	return [][]byte{pkScript}
+}
+
+func newActivityIO(pkScript []byte, value int64, assets swire.TxAssets,
+	pkScripts [][]byte, satsNet bool) *activityIO {
+	var address string
+	if satsNet {
+		address, _ = AddrFromPkScript_SatsNet(pkScript)
+	} else {
+		address, _ = AddrFromPkScript(pkScript)
+	}
+	return &activityIO{
+		Address: address,
+		Mine:    isPkScriptIn(pkScript, pkScripts),
+		Value:   value,
+		Assets:  assets,
+	}
+}
+
+// 输入已经被花费，索引器可能找不到，这时从原始交易中获取，但没有资产信息
+func (p *Manager) activityInput(utxo string, pkScripts [][]byte) *activityIO {
+	output, err := p.l1IndexerClient.GetTxOutput(utxo)
+	if err != nil || output == nil {
+		output, err = p.GetTxOutFromRawTx(utxo)
+		if err != nil {
+			return nil
+		}
+	}
+	return newActivityIO(output.OutValue.PkScript, output.OutValue.Value, output.Assets, pkScripts, false)
+}
+
+func (p *Manager) activityInput_SatsNet(outpoint swire.OutPoint, pkScripts [][]byte) *activityIO {
+	output, err := p.l2IndexerClient.GetTxOutput(outpoint.String())
+	if err == nil && output != nil {
+		return newActivityIO(output.OutValue.PkScript, output.OutValue.Value, output.Assets, pkScripts, true)
+	}
+	txHex, err := p.l2IndexerClient.GetRawTx(outpoint.Hash.String())
+	if err != nil {
+		return nil
+	}
+	tx, err := DecodeMsgTx_SatsNet(txHex)
+	if err != nil || int(outpoint.Index) >= len(tx.TxOut) {
+		return nil
+	}
+	txOut := tx.TxOut[outpoint.Index]
+	return newActivityIO(txOut.PkScript, txOut.Value, txOut.Assets, pkScripts, true)
+}
+
+// 一层交易输出的资产需要索引器处理后才知道，withAssets 为 true 时查询属于本钱包的输出的资产
+func (p *Manager) activityOutputs(tx *wire.MsgTx, pkScripts [][]byte, withAssets bool) ([]*activityIO, bool) {
+	txId := tx.TxID()
+	outputs := make([]*activityIO, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		outputs[i] = newActivityIO(txOut.PkScript, txOut.Value, nil, pkScripts, false)
+		if !withAssets || !outputs[i].Mine {
+			continue
+		}
+		output, err := p.l1IndexerClient.GetTxOutput(fmt.Sprintf("%s:%d", txId, i))
+		if err != nil || output == nil {
+			return outputs, false
+		}
+		outputs[i].Assets = output.Assets
+	}
+	return outputs, true
+}
+
+// 还没有计算资产变化的交易记录
+func (p *Manager) newTxActivity(txId string, satsNet bool) *ActivityItem {
+	if p.wallet == nil {
+		return nil
+	}
+	return &ActivityItem{
+		Type:       ACTIVITY_TX,
+		Address:    p.wallet.GetAddress(),
+		TxId:       txId,
+		SatsNet:    satsNet,
+		Status:     ACTIVITY_STATUS_PENDING,
+		CreateTime: time.Now().Unix(),
+	}
+}
+
+// 返回输入的信息，找不到的为nil
+func (p *Manager) recordTx(tx *wire.MsgTx) []*activityIO {
+	item := p.newTxActivity(tx.TxID(), false)
+	if item == nil {
+		return nil
+	}
+	pkScripts := p.walletPkScripts()
+	inputs := make([]*activityIO, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		inputs[i] = p.activityInput(txIn.PreviousOutPoint.String(), pkScripts)
+	}
+	outputs, _ := p.activityOutputs(tx, pkScripts, false)
+
+	item.Inputs = inputs
+	fillActivityDeltas(item, inputs, outputs)
+	p.recordActivity(item)
+	return inputs
+}
+
+// 二层交易的输出直接带有资产，不需要等索引器
+func (p *Manager) recordTx_SatsNet(tx *swire.MsgTx) []*activityIO {
+	item := p.newTxActivity(tx.TxID(), true)
+	if item == nil {
+		return nil
+	}
+	pkScripts := p.walletPkScripts_SatsNet()
+	inputs := make([]*activityIO, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		inputs[i] = p.activityInput_SatsNet(txIn.PreviousOutPoint, pkScripts)
+	}
+	outputs := make([]*activityIO, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		outputs[i] = newActivityIO(txOut.PkScript, txOut.Value, txOut.Assets, pkScripts, true)
+	}
+
+	item.Resolved = true
+	fillActivityDeltas(item, inputs, outputs)
+	p.recordActivity(item)
+	return inputs
+}
+
+// 通道内的支付，如果支付交易已经记录，只修改类型
+func (p *Manager) recordChannelPayment(resv *PaymentReservation) {
+	if p.wallet == nil {
+		return
+	}
+	address := p.wallet.GetAddress()
+	item := &ActivityItem{
+		Type:       ACTIVITY_CHANNEL_PAYMENT,
+		Address:    address,
+		ResvId:     resv.Id,
+		SatsNet:    true,
+		Ref:        resv.ChannelId,
+		Status:     ACTIVITY_STATUS_CONFIRMED,
+		CreateTime: time.Now().Unix(),
+		Resolved:   true,
+	}
+	if resv.PaymentTx != nil {
+		item.TxId = resv.PaymentTx.TxID()
+		item.Status = ACTIVITY_STATUS_PENDING
+	}
+	if item.TxId != "" && p.modifyActivity(item.key(), func(old *ActivityItem) {
+		old.Type = ACTIVITY_CHANNEL_PAYMENT
+		old.ResvId = resv.Id
+		old.Ref = resv.ChannelId
+		if old.Action == "" {
+			if resv.IsUnlock {
+				old.Action = "unlock"
+			} else {
+				old.Action = "lock"
+			}
+		}
+	}) {
+		return
+	}
+
+	var assetName string
+	if resv.AssetName != nil {
+		assetName = resv.AssetName.String()
+	}
+	var amt *Decimal
+	if resv.IsUnlock {
+		item.Action = "unlock"
+		item.Direction = ACTIVITY_DIRECTION_RECEIVE
+		for i, dest := range resv.DestAddr {
+			if dest == address && i < len(resv.DestAmt) {
+				amt = amt.Add(resv.DestAmt[i])
+			}
+		}
+		if amt != nil && amt.Sign() != 0 {
+			item.Deltas = []*AssetDelta{{AssetName: assetName, Amount: amt.String()}}
+		}
+	} else {
+		item.Action = "lock"
+		item.Direction = ACTIVITY_DIRECTION_SEND
+		amt = resv.Amt
+		if amt != nil && amt.Sign() != 0 {
+			item.Deltas = []*AssetDelta{{AssetName: assetName, Amount: "-" + amt.String()}}
+		}
+	}
+	p.recordActivity(item)
+}
+
+func (p *Manager) activityIndexer(satsNet bool) *IndexerRPCClientMgr {
+	if satsNet {
+		return p.l2IndexerClient
+	}
+	return p.l1IndexerClient
+}
+
+func (p *Manager) pendingActivities(address string, satsNet bool) []*ActivityItem {
+	p.activityMutex.Lock()
+	defer p.activityMutex.Unlock()
+	p.loadActivitiesLocked()
+	var result []*ActivityItem
+	for _, item := range p.activities {
+		if item.Address == address && item.SatsNet == satsNet && item.TxId != "" &&
+			(item.Status == ACTIVITY_STATUS_PENDING || !item.Resolved) {
+			result = append(result, item.Clone())
+		}
+	}
+	return result
+}
+
+// 一层交易确认后，从索引器获取输出的资产，重新计算资产变化
+func (p *Manager) resolveActivity(item *ActivityItem) bool {
+	txHex, err := p.l1IndexerClient.GetRawTx(item.TxId)
+	if err != nil {
+		return false
+	}
+	tx, err := DecodeMsgTx(txHex)
+	if err != nil {
+		return false
+	}
+	pkScripts := p.walletPkScripts()
+	outputs, ok := p.activityOutputs(tx, pkScripts, true)
+	if !ok {
+		return false
+	}
+	inputs := item.Inputs
+	if len(inputs) != len(tx.TxIn) {
+		inputs = make([]*activityIO, len(tx.TxIn))
+		for i, txIn := range tx.TxIn {
+			inputs[i] = p.activityInput(txIn.PreviousOutPoint.String(), pkScripts)
+		}
+	}
+	fillActivityDeltas(item, inputs, outputs)
+	return true
+}
+
+func (p *Manager) updateActivityStatus(address string, satsNet bool, now int64) {
+	client := p.activityIndexer(satsNet)
+	for _, item := range p.pendingActivities(address, satsNet) {
+		if item.Status == ACTIVITY_STATUS_PENDING {
+			height, err := client.GetTxHeight(item.TxId)
+			if err == nil && height > 0 {
+				item.Status = ACTIVITY_STATUS_CONFIRMED
+				item.Height = height
+				item.ConfirmTime = now
+			} else if now-item.CreateTime > ACTIVITY_DROPPED_INTERVAL {
+				if _, err := client.GetRawTx(item.TxId); err != nil {
+					item.Status = ACTIVITY_STATUS_DROPPED
+				}
+			}
+		}
+		if item.SatsNet && !item.Resolved && item.Status == ACTIVITY_STATUS_CONFIRMED {
+			// 广播后的后台任务没有完成，重新从索引器获取交易计算
+			p.recordTxFromIndexer(true, item.TxId)
+		}
+		if item.Status == ACTIVITY_STATUS_CONFIRMED && !item.Resolved && !item.SatsNet && p.resolveActivity(item) {
+			item.Resolved = true
+			item.Inputs = nil
+		}
+		if item.Status == ACTIVITY_STATUS_DROPPED {
+			item.Resolved = true
+			item.Inputs = nil
+		}
+
+		p.modifyActivity(item.key(), func(old *ActivityItem) {
+			old.Status = item.Status
+			old.Height = item.Height
+			old.ConfirmTime = item.ConfirmTime
+			if item.Resolved && !old.Resolved {
+				old.Resolved = true
+				old.Inputs = nil
+				old.Direction = item.Direction
+				old.Value = item.Value
+				old.Fee = item.Fee
+				old.Deltas = item.Deltas
+				old.Counterparties = item.Counterparties
+			}
+		})
+	}
+}
+
+// 扫描本钱包的utxo，记录还没有记录的交易，主要是收到的交易
+func (p *Manager) scanActivities(address string, satsNet bool) {
+	addresses := []string{address}
+	if !satsNet {
+		changePkScript := p.getChangePkScript(address, false, nil)
+		if changePkScript != nil {
+			changeAddr, err := AddrFromPkScript(changePkScript)
+			if err == nil && changeAddr != address {
+				addresses = append(addresses, changeAddr)
+			}
+		}
+	}
+
+	client := p.activityIndexer(satsNet)
+	scanned := make(map[string]bool)
+	for _, addr := range addresses {
+		for _, utxo := range client.GetAllUtxosWithAddress(addr) {
+			if utxo == nil {
+				continue
+			}
+			txId, _, _ := strings.Cut(utxo.OutPoint, ":")
+			if txId == "" || scanned[txId] || p.hasActivity(address, satsNet, txId) {
+				continue
+			}
+			scanned[txId] = true
+			if len(scanned) > ACTIVITY_SCAN_MAX_TX {
+				return
+			}
+			p.recordTxFromIndexer(satsNet, txId)
+		}
+	}
+}
+
+func (p *Manager) recordTxFromIndexer(satsNet bool, txId string) {
+	txHex, err := p.activityIndexer(satsNet).GetRawTx(txId)
+	if err != nil {
+		Log.Debugf("GetRawTx %s failed. %v", txId, err)
+		return
+	}
+	if satsNet {
+		tx, err := DecodeMsgTx_SatsNet(txHex)
+		if err != nil {
+			return
+		}
+		p.recordTx_SatsNet(tx)
+		return
+	}
+	tx, err := DecodeMsgTx(txHex)
+	if err != nil {
+		return
+	}
+	p.recordTx(tx)
+}
+
+func (p *Manager) handleActivityMonitorTick(sendTxInL1 bool) {
+	if p == nil || p.wallet == nil {
+		return
+	}
+	satsNet := !sendTxInL1
+	index := 0
+	if satsNet {
+		index = 1
+	}
+	now := time.Now().Unix()
+	p.activityMutex.Lock()
+	if now-p.activityScanTime[index] < ACTIVITY_SCAN_INTERVAL {
+		p.activityMutex.Unlock()
+		return
+	}
+	p.activityScanTime[index] = now
+	p.activityMutex.Unlock()
+
+	address := p.wallet.GetAddress()
+	p.updateActivityStatus(address, satsNet, now)
+	p.scanActivities(address, satsNet)
+}
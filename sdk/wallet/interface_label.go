@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+// 自动添加的标签，已经有标签时不覆盖
+const (
+	LABEL_CHANGE          = "change"
+	LABEL_CHANNEL         = "channel"
+	LABEL_CHANNEL_FUNDING = "channel funding"
+	LABEL_CONTRACT        = "contract"
+	LABEL_CONTRACT_INVOKE = "invoke"
+)
+
+// 调用者需要持有 labelMutex
+func (p *Manager) loadLabelsLocked() {
+	if p.labels != nil {
+		return
+	}
+	if p.db == nil {
+		p.labels = make(map[string]*Label)
+		return
+	}
+	p.labels = loadAllLabels(p.db)
+}
+
+// Label 为空（没有 label，origin 和 spendable）时删除这个标签
+func (p *Manager) SetLabel(label *Label) error {
+	if label == nil || !IsValidLabelType(label.Type) {
+		return fmt.Errorf("invalid label type")
+	}
+	if label.Ref == "" {
+		return fmt.Errorf("label ref is required")
+	}
+	if label.Spendable != nil && label.Type != LABEL_TYPE_OUTPUT {
+		return fmt.Errorf("spendable is only for output label")
+	}
+
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	return p.setLabelLocked(label.Clone())
+}
+
+func (p *Manager) setLabelLocked(label *Label) error {
+	if label.isEmpty() {
+		if p.db != nil {
+			if err := deleteLabel(p.db, label.Type, label.Ref); err != nil {
+				return err
+			}
+		}
+		delete(p.labels, label.key())
+		return nil
+	}
+	if p.db != nil {
+		if err := saveLabel(p.db, label); err != nil {
+			return err
+		}
+	}
+	p.labels[label.key()] = label
+	return nil
+}
+
+func (p *Manager) DeleteLabel(typ, ref string) error {
+	return p.SetLabel(&Label{Type: typ, Ref: ref})
+}
+
+func (p *Manager) GetLabel(typ, ref string) *Label {
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	label, ok := p.labels[labelKey(typ, ref)]
+	if !ok {
+		return nil
+	}
+	return label.Clone()
+}
+
+// 只返回标签的文字，没有时返回空
+func (p *Manager) GetLabelText(typ, ref string) string {
+	label := p.GetLabel(typ, ref)
+	if label == nil {
+		return ""
+	}
+	return label.Label
+}
+
+// typ 为空时返回所有标签
+func (p *Manager) GetLabels(typ string) []*Label {
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	result := make([]*Label, 0, len(p.labels))
+	for _, label := range p.labels {
+		if typ == "" || label.Type == typ {
+			result = append(result, label.Clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Ref < result[j].Ref
+	})
+	return result
+}
+
+// 导入 BIP329 JSONL，覆盖已有的标签，返回导入的数量
+func (p *Manager) ImportLabels(data []byte) (int, error) {
+	labels, err := ParseBIP329(data)
+	if err != nil {
+		return 0, err
+	}
+
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	for i, label := range labels {
+		err = p.setLabelLocked(label)
+		if err != nil {
+			return i, err
+		}
+	}
+	Log.Infof("ImportLabels %d labels", len(labels))
+	return len(labels), nil
+}
+
+func (p *Manager) ExportLabels() ([]byte, error) {
+	return ExportBIP329(p.GetLabels(""))
+}
+
+// 只在没有标签时添加
+func (p *Manager) setAutoLabel(typ, ref, text string) {
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	if _, ok := p.labels[labelKey(typ, ref)]; ok {
+		return
+	}
+	err := p.setLabelLocked(&Label{Type: typ, Ref: ref, Label: text})
+	if err != nil {
+		Log.Errorf("set label %s %s failed. %v", typ, ref, err)
+	}
+}
+
+// spendable 为 false 的输出
+func (p *Manager) unspendableOutputs() []string {
+	p.labelMutex.Lock()
+	defer p.labelMutex.Unlock()
+	p.loadLabelsLocked()
+	var result []string
+	for _, label := range p.labels {
+		if label.Type == LABEL_TYPE_OUTPUT && label.Spendable != nil && !*label.Spendable {
+			result = append(result, label.Ref)
+		}
+	}
+	return result
+}
+
+// 交易中回到本钱包的输出标记为找零，全部输出都属于本钱包时（比如合并utxo）不是找零
+func (p *Manager) labelChangeOutputs(tx *wire.MsgTx) {
+	if p.wallet == nil {
+		return
+	}
+	pkScripts := p.walletPkScripts()
+	var change []int
+	external := false
+	for i, txOut := range tx.TxOut {
+		if IsNullDataScript(txOut.PkScript) {
+			continue
+		}
+		if isPkScriptIn(txOut.PkScript, pkScripts) {
+			change = append(change, i)
+		} else {
+			external = true
+		}
+	}
+	if !external {
+		return
+	}
+	txId := tx.TxID()
+	for _, i := range change {
+		p.setAutoLabel(LABEL_TYPE_OUTPUT, fmt.Sprintf("%s:%d", txId, i), LABEL_CHANGE)
+	}
+}
+
+func (p *Manager) labelChangeOutputs_SatsNet(tx *swire.MsgTx) {
+	if p.wallet == nil {
+		return
+	}
+	pkScripts := p.walletPkScripts_SatsNet()
+	var change []int
+	external := false
+	for i, txOut := range tx.TxOut {
+		if len(txOut.PkScript) == 0 || IsNullDataScript(txOut.PkScript) {
+			continue
+		}
+		if isPkScriptIn(txOut.PkScript, pkScripts) {
+			change = append(change, i)
+		} else {
+			external = true
+		}
+	}
+	if !external {
+		return
+	}
+	txId := tx.TxID()
+	for _, i := range change {
+		p.setAutoLabel(LABEL_TYPE_OUTPUT, fmt.Sprintf("%s:%d", txId, i), LABEL_CHANGE)
+	}
+}
+
+func (p *Manager) labelChannel(c *Channel) {
+	if c == nil || c.ChannelId == "" {
+		return
+	}
+	p.setAutoLabel(LABEL_TYPE_ADDR, c.ChannelId, LABEL_CHANNEL)
+	if c.ChanPoint != nil && c.ChanPoint.OutPointStr != "" {
+		p.setAutoLabel(LABEL_TYPE_OUTPUT, c.ChanPoint.OutPointStr,
+			fmt.Sprintf("%s %s", LABEL_CHANNEL_FUNDING, c.ChannelId))
+	}
+}
+
+func (p *Manager) labelContractInvoke(contractURL, contractAddr, txId string) {
+	p.setAutoLabel(LABEL_TYPE_ADDR, contractAddr, fmt.Sprintf("%s %s", LABEL_CONTRACT, contractURL))
+	p.setAutoLabel(LABEL_TYPE_TX, txId, fmt.Sprintf("%s %s", LABEL_CONTRACT_INVOKE, contractURL))
+}
+
+// 给锁定的utxo加上标签
+func (p *Manager) fillLockedUtxoLabels(utxos map[string]*LockedUtxo) {
+	for utxo, locked := range utxos {
+		if locked != nil {
+			locked.Label = p.GetLabelText(LABEL_TYPE_OUTPUT, utxo)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	indexer "github.com/sat20-labs/indexer/common"
+)
+
+const (
+	ACTION_LIQUIDITY = "liquidity"
+
+	LIQUIDITY_CHECK_INTERVAL = 10 * 60 // 秒，同一个资产两次检查的最小间隔
+	LIQUIDITY_FEE_WINDOW     = 24 * 3600
+	LIQUIDITY_MAX_DECISIONS  = 1000 // 内存中最多保留的记录
+)
+
+// 调用者需要持有 liquidityMutex
+func (p *Manager) loadLiquidityLocked() {
+	if p.liquidityPolicies != nil {
+		return
+	}
+	if p.db == nil {
+		p.liquidityPolicies = make(map[string]*LiquidityPolicy)
+		p.liquidityDecisions = make([]*LiquidityDecision, 0)
+		return
+	}
+	p.liquidityPolicies = loadAllLiquidityPolicies(p.db)
+	p.liquidityDecisions = loadAllLiquidityDecisions(p.db)
+	sort.Slice(p.liquidityDecisions, func(i, j int) bool {
+		return p.liquidityDecisions[i].Id < p.liquidityDecisions[j].Id
+	})
+	if len(p.liquidityDecisions) > LIQUIDITY_MAX_DECISIONS {
+		p.liquidityDecisions = p.liquidityDecisions[len(p.liquidityDecisions)-LIQUIDITY_MAX_DECISIONS:]
+	}
+}
+
+func (p *Manager) SetLiquidityPolicy(policy *LiquidityPolicy) error {
+	if policy == nil {
+		return fmt.Errorf("invalid liquidity policy")
+	}
+	policy = policy.Clone()
+	name := ParseAssetString(policy.AssetName)
+	if name == nil {
+		return fmt.Errorf("invalid asset name %s", policy.AssetName)
+	}
+	if err := rejectRGB11STPAsset(name); err != nil {
+		return err
+	}
+	policy.AssetName = name.String()
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	policy.UpdateTime = time.Now().Unix()
+
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.loadLiquidityLocked()
+	if p.db != nil {
+		if err := saveLiquidityPolicy(p.db, policy); err != nil {
+			return err
+		}
+	}
+	p.liquidityPolicies[policy.AssetName] = policy
+	Log.Infof("SetLiquidityPolicy %s: target %d%%, L2 [%s, %s], max daily fee %d/%d", policy.AssetName,
+		policy.TargetLocalRatio, policy.MinL2Balance, policy.MaxL2Balance, policy.MaxDailyFee,
+		policy.MaxDailyFee_SatsNet)
+	return nil
+}
+
+func (p *Manager) DeleteLiquidityPolicy(assetName string) error {
+	name := ParseAssetString(assetName)
+	if name == nil {
+		return fmt.Errorf("invalid asset name %s", assetName)
+	}
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.loadLiquidityLocked()
+	if p.db != nil {
+		if err := deleteLiquidityPolicy(p.db, name.String()); err != nil {
+			return err
+		}
+	}
+	delete(p.liquidityPolicies, name.String())
+	return nil
+}
+
+func (p *Manager) GetLiquidityPolicies() []*LiquidityPolicy {
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.loadLiquidityLocked()
+	result := make([]*LiquidityPolicy, 0, len(p.liquidityPolicies))
+	for _, policy := range p.liquidityPolicies {
+		result = append(result, policy.Clone())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AssetName < result[j].AssetName
+	})
+	return result
+}
+
+// 最近的记录在前，assetName 为空时返回所有资产的记录，limit 为0时不限
+func (p *Manager) GetLiquidityDecisions(assetName string, limit int) []*LiquidityDecision {
+	if assetName != "" {
+		if name := ParseAssetString(assetName); name != nil {
+			assetName = name.String()
+		}
+	}
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.loadLiquidityLocked()
+	result := make([]*LiquidityDecision, 0)
+	for i := len(p.liquidityDecisions) - 1; i >= 0; i-- {
+		d := p.liquidityDecisions[i]
+		if assetName != "" && d.AssetName != assetName {
+			continue
+		}
+		n := *d
+		result = append(result, &n)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+func (p *Manager) addLiquidityDecision(decision *LiquidityDecision) {
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.loadLiquidityLocked()
+	if p.db != nil {
+		if err := saveLiquidityDecision(p.db, decision); err != nil {
+			Log.Errorf("saveLiquidityDecision %d failed. %v", decision.Id, err)
+		}
+	}
+	p.liquidityDecisions = append(p.liquidityDecisions, decision)
+	if len(p.liquidityDecisions) > LIQUIDITY_MAX_DECISIONS {
+		p.liquidityDecisions = p.liquidityDecisions[1:]
+	}
+}
+
+// 超出预算时每次检查都会跳过，同一个资产在同一层连续跳过同一个动作时只更新最后一条记录，Id 不变
+func (p *Manager) addLiquiditySkipped(decision *LiquidityDecision) {
+	l1 := isLiquidityActionInL1(decision.Action)
+	p.liquidityMutex.Lock()
+	p.loadLiquidityLocked()
+	for i := len(p.liquidityDecisions) - 1; i >= 0; i-- {
+		last := p.liquidityDecisions[i]
+		if last.AssetName != decision.AssetName || isLiquidityActionInL1(last.Action) != l1 {
+			continue
+		}
+		if last.Skipped == "" || last.Action != decision.Action {
+			break
+		}
+		decision.Id = last.Id
+		if p.db != nil {
+			if err := saveLiquidityDecision(p.db, decision); err != nil {
+				Log.Errorf("saveLiquidityDecision %d failed. %v", decision.Id, err)
+			}
+		}
+		p.liquidityDecisions[i] = decision
+		p.liquidityMutex.Unlock()
+		return
+	}
+	p.liquidityMutex.Unlock()
+	p.addLiquidityDecision(decision)
+}
+
+// 最近24小时内执行成功的动作在这一层支付的网络费
+func (p *Manager) liquidityFeeSpent(assetName string, l1 bool, now int64) int64 {
+	p.liquidityMutex.Lock()
+	p.loadLiquidityLocked()
+	decisions := make([]*LiquidityDecision, 0)
+	for _, d := range p.liquidityDecisions {
+		if d.AssetName == assetName && isLiquidityActionInL1(d.Action) == l1 &&
+			d.Error == "" && d.Skipped == "" && now-d.Time < LIQUIDITY_FEE_WINDOW {
+			decisions = append(decisions, d)
+		}
+	}
+	p.liquidityMutex.Unlock()
+
+	fee := int64(0)
+	for _, d := range decisions {
+		fee += p.liquidityActualFee(d)
+	}
+	return fee
+}
+
+// 交易记录解析后使用实际支付的网络费，之前使用估算值
+func (p *Manager) liquidityActualFee(decision *LiquidityDecision) int64 {
+	p.liquidityMutex.Lock()
+	fee := decision.Fee
+	p.liquidityMutex.Unlock()
+	if fee > 0 {
+		return fee
+	}
+	item := p.GetActivityItem(decision.TxId, !isLiquidityActionInL1(decision.Action))
+	if item == nil || !item.Resolved || item.Fee <= 0 {
+		return decision.EstimatedFee
+	}
+
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	decision.Fee = item.Fee
+	if p.db != nil {
+		if err := saveLiquidityDecision(p.db, decision); err != nil {
+			Log.Errorf("saveLiquidityDecision %d failed. %v", decision.Id, err)
+		}
+	}
+	return item.Fee
+}
+
+func (p *Manager) isLiquiditySplicing() bool {
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	return p.liquiditySplicing
+}
+
+func (p *Manager) setLiquiditySplicing(splicing bool) {
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	p.liquiditySplicing = splicing
+}
+
+// 同一个资产在同一层每 LIQUIDITY_CHECK_INTERVAL 秒最多检查一次
+func (p *Manager) liquidityCheckDue(assetName string, l1 bool, now int64) bool {
+	key := fmt.Sprintf("%s-%v", assetName, l1)
+	p.liquidityMutex.Lock()
+	defer p.liquidityMutex.Unlock()
+	if p.liquidityLastCheck == nil {
+		p.liquidityLastCheck = make(map[string]int64)
+	}
+	if now-p.liquidityLastCheck[key] < LIQUIDITY_CHECK_INTERVAL {
+		return false
+	}
+	p.liquidityLastCheck[key] = now
+	return true
+}
+
+func (p *Manager) liquidityChannel(policy *LiquidityPolicy) *Channel {
+	if policy.ChannelId != "" {
+		return p.GetChannel(policy.ChannelId)
+	}
+	return p.GetCurrentChannel()
+}
+
+func (p *Manager) estimateLiquidityFee(channel *Channel, action string, asset *AssetName,
+	dAmt *Decimal, feeRate int64) int64 {
+	switch action {
+	case LIQUIDITY_ACTION_SPLICING_IN:
+		n, v := channel.NeedStubUtxo(asset)
+		return CalcFee_SplicingIn(3, 3, &asset.AssetName, feeRate, n, v)
+	case LIQUIDITY_ACTION_SPLICING_OUT:
+		return CalcFee_SplicingOut(3, 4, asset, dAmt, feeRate, channel.IsInitiator, channel.FeeCfg)
+	default:
+		return DEFAULT_FEE_SATSNET
+	}
+}
+
+func (p *Manager) executeLiquidityAction(channel *Channel, decision *LiquidityDecision) (string, int64, error) {
+	switch decision.Action {
+	case LIQUIDITY_ACTION_LOCK:
+		return p.LockToChannel(channel.ChannelId, decision.AssetName, decision.Amt, nil, nil, nil)
+	case LIQUIDITY_ACTION_UNLOCK:
+		return p.UnlockFromChannel(channel.ChannelId, "", decision.AssetName, decision.Amt, nil, nil)
+	case LIQUIDITY_ACTION_SPLICING_IN:
+		return p.SplicingIn(channel.ChannelId, decision.AssetName, decision.Amt, nil, nil, nil, nil,
+			decision.FeeRate, SPLICING_REASON_LOCAL)
+	case LIQUIDITY_ACTION_SPLICING_OUT:
+		return p.SplicingOut(channel.ChannelId, p.wallet.GetAddress(), decision.AssetName, decision.Amt,
+			nil, nil, nil, decision.FeeRate, SPLICING_REASON_LOCAL, nil)
+	}
+	return "", 0, fmt.Errorf("unknown liquidity action %s", decision.Action)
+}
+
+// 检查一个资产，需要时执行一个动作，返回执行的记录，没有执行或者在后台拼接时返回 nil
+func (p *Manager) runLiquidityPolicy(policy *LiquidityPolicy, l1 bool, now int64) *LiquidityDecision {
+	channel := p.liquidityChannel(policy)
+	if channel == nil {
+		return nil
+	}
+	channel.Mutex.RLock()
+	ready := channel.Status == CS_READY && channel.ResvId == 0 && channel.IsInitiator
+	channel.Mutex.RUnlock()
+	if !ready || !p.IsPeerOnline(channel.PeerNodeId) {
+		return nil
+	}
+
+	name := ParseAssetString(policy.AssetName)
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		Log.Warnf("liquidity policy %s: can't get ticker info", policy.AssetName)
+		return nil
+	}
+	asset := GetAssetName(tickerInfo)
+	feeRate := int64(0)
+	if l1 {
+		feeRate = p.GetFeeRate()
+	}
+
+	channel.Mutex.RLock()
+	local := channel.GetCommitLocalValue(asset)
+	remote := channel.GetCommitRemoteValue(asset)
+	channel.Mutex.RUnlock()
+	state := &liquidityState{
+		Local:  decimalToRat(local),
+		Remote: decimalToRat(remote),
+		L1:     new(big.Rat),
+		L2:     new(big.Rat),
+	}
+	var l1Balance, l2Balance *Decimal
+	if l1 {
+		l1Balance = p.GetAssetBalance("", name)
+		state.L1 = decimalToRat(l1Balance)
+		if indexer.IsPlainAsset(name) {
+			// 白聪需要留下拼接的网络费
+			fee := p.estimateLiquidityFee(channel, LIQUIDITY_ACTION_SPLICING_IN, asset, nil, feeRate)
+			state.L1.Sub(state.L1, big.NewRat(fee, 1))
+			if state.L1.Sign() < 0 {
+				state.L1.SetInt64(0)
+			}
+		}
+	} else {
+		l2Balance = p.GetAssetBalance_SatsNet("", name)
+		state.L2 = decimalToRat(l2Balance)
+	}
+
+	action, amt, reason := decideLiquidity(policy, state, tickerInfo.Divisibility, l1)
+	if action == "" {
+		return nil
+	}
+	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
+	if err != nil {
+		Log.Errorf("liquidity policy %s: invalid amount %s. %v", policy.AssetName, amt, err)
+		return nil
+	}
+	if indexer.IsPlainAsset(name) && l1 && dAmt.Int64() < DUST_LIMIT {
+		return nil
+	}
+
+	decision := &LiquidityDecision{
+		Id:            time.Now().UnixMicro(),
+		AssetName:     policy.AssetName,
+		ChannelId:     channel.ChannelId,
+		Action:        action,
+		Amt:           amt,
+		Reason:        reason,
+		LocalBalance:  ratToAmount(state.Local, tickerInfo.Divisibility),
+		RemoteBalance: ratToAmount(state.Remote, tickerInfo.Divisibility),
+		FeeRate:       feeRate,
+		EstimatedFee:  p.estimateLiquidityFee(channel, action, asset, dAmt, feeRate),
+		Time:          now,
+	}
+	if l1Balance != nil {
+		decision.L1Balance = l1Balance.String()
+	}
+	if l2Balance != nil {
+		decision.L2Balance = l2Balance.String()
+	}
+	if maxFee := policy.maxDailyFee(l1); maxFee > 0 {
+		spent := p.liquidityFeeSpent(policy.AssetName, l1, now)
+		if spent+decision.EstimatedFee > maxFee {
+			decision.Skipped = fmt.Sprintf("fee %d + %d exceeds daily budget %d",
+				spent, decision.EstimatedFee, maxFee)
+			Log.Infof("liquidity policy %s: skip %s %s, %s", policy.AssetName, action, amt, decision.Skipped)
+			p.addLiquiditySkipped(decision)
+			return nil
+		}
+	}
+
+	if l1 {
+		// 拼接需要和对端多次交互并等待广播，在后台执行，不阻塞监控线程
+		p.setLiquiditySplicing(true)
+		go func() {
+			defer p.setLiquiditySplicing(false)
+			p.executeLiquidityDecision(channel, decision)
+			p.notifyLiquidityDecision(decision)
+		}()
+		return nil
+	}
+	p.executeLiquidityDecision(channel, decision)
+	return decision
+}
+
+func (p *Manager) executeLiquidityDecision(channel *Channel, decision *LiquidityDecision) {
+	Log.Infof("liquidity policy %s: %s %s in channel %s, %s", decision.AssetName, decision.Action,
+		decision.Amt, channel.ChannelId, decision.Reason)
+	var err error
+	decision.TxId, decision.ResvId, err = p.executeLiquidityAction(channel, decision)
+	if err != nil {
+		Log.Errorf("liquidity policy %s: %s %s failed. %v", decision.AssetName, decision.Action,
+			decision.Amt, err)
+		decision.Error = err.Error()
+	} else {
+		p.annotateActivity(!isLiquidityActionInL1(decision.Action), decision.TxId, "",
+			ACTION_LIQUIDITY, decision.Reason)
+	}
+	p.addLiquidityDecision(decision)
+}
+
+func (p *Manager) notifyLiquidityDecision(decision *LiquidityDecision) {
+	event := &ActionStatusEvent{
+		Event:      ACTION_STATUS_EVENT_COMPLETED,
+		Resv:       p.GetResv(decision.ResvId),
+		Action:     ACTION_LIQUIDITY,
+		SendTxInL1: isLiquidityActionInL1(decision.Action),
+	}
+	if decision.Error != "" {
+		event.Event = ACTION_STATUS_EVENT_FAILED
+		event.Err = fmt.Errorf("%s", decision.Error)
+	}
+	p.notifyActionStatus(event)
+}
+
+// 聪网上的锁定和解锁在L2监控线程，拼接在L1监控线程检查，后台执行
+func (p *Manager) handleLiquidityMonitorTick(sendTxInL1 bool) {
+	if p == nil || p.wallet == nil || !p.IsReady() {
+		return
+	}
+	now := time.Now().Unix()
+	for _, policy := range p.GetLiquidityPolicies() {
+		// 上一次拼接还没有结束
+		if sendTxInL1 && p.isLiquiditySplicing() {
+			return
+		}
+		if policy.Disabled || !p.liquidityCheckDue(policy.AssetName, sendTxInL1, now) {
+			continue
+		}
+		decision := p.runLiquidityPolicy(policy, sendTxInL1, now)
+		if decision != nil {
+			p.notifyLiquidityDecision(decision)
+		}
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
 	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+	stxscript "github.com/sat20-labs/satoshinet/txscript"
+	swire "github.com/sat20-labs/satoshinet/wire"
 	indexer "github.com/sat20-labs/indexer/common"
 )
 
@@ -40,7 +43,9 @@ func (p *Manager) IsLocked(address, utxo string) bool {
 
 func (p *Manager) GetLockedUtxoList(address string) (map[string]*LockedUtxo, error) {
 	p.utxoLockerL1.Reload(address)
-	return p.utxoLockerL1.GetLockedUtxoList(), nil
+	result := p.utxoLockerL1.GetLockedUtxoList()
+	p.fillLockedUtxoLabels(result)
+	return result, nil
 }
 
 func (p *Manager) LockUtxo_SatsNet(address, utxo, reason string) error {
@@ -60,7 +65,9 @@ func (p *Manager) IsLocked_SatsNet(address, utxo string) bool {
 
 func (p *Manager) GetLockedUtxoList_SatsNet(address string) (map[string]*LockedUtxo, error) {
 	p.utxoLockerL2.Reload(address)
-	return p.utxoLockerL2.GetLockedUtxoList(), nil
+	result := p.utxoLockerL2.GetLockedUtxoList()
+	p.fillLockedUtxoLabels(result)
+	return result, nil
 }
 
 func (p *Manager) GetUtxosWithAssetForJS(address, amt, assetName string) ([]string, error) {
@@ -206,9 +213,16 @@ func (p *Manager) GetTxAssetInfoFromPsbt(psbtStr string) (*TxAssetInfo, error) {
 		return nil, err
 	}
 
-	tx := packet.UnsignedTx
+	result, _, _, err := p.getTxAssetInfo(packet.UnsignedTx, txHex, packet.IsComplete())
+	return result, err
+}
+
+// complete 为true时按协议规则分配资产，同时返回每个输出分配到的资产，以及剩下的作为网络费的部分
+func (p *Manager) getTxAssetInfo(tx *wire.MsgTx, txHex string, complete bool) (
+	*TxAssetInfo, []*TxOutput, *TxOutput, error) {
+
 	result := &TxAssetInfo{
-		TxId: packet.UnsignedTx.TxID(),
+		TxId: tx.TxID(),
 		TxHex: txHex,
 		InputAssets:  make([]*indexer.AssetsInUtxo, len(tx.TxIn)),
 		OutputAssets: make([]*indexer.AssetsInUtxo, len(tx.TxOut)),
@@ -221,7 +235,7 @@ func (p *Manager) GetTxAssetInfoFromPsbt(psbtStr string) (*TxAssetInfo, error) {
 		info, err := p.getL1TxOutput(utxo)
 		if err != nil {
 			Log.Errorf("can't find output info for utxo %s", utxo)
-			return nil, err
+			return nil, nil, nil, err
 		}
 		utxoInfo := info.ToAssetsInUtxo()
 		
@@ -235,24 +249,35 @@ func (p *Manager) GetTxAssetInfoFromPsbt(psbtStr string) (*TxAssetInfo, error) {
 	}
 
 	// 如果是完整的psbt，按协议规则分配资产
-	if packet.IsComplete() {
+	var outputs []*TxOutput
+	if complete {
 		// 按协议规则分配资产
+		outputs = make([]*TxOutput, len(tx.TxOut))
 		for i, txOut := range tx.TxOut {
+			if txOut.Value == 0 {
+				// OP_RETURN 不分配聪
+				result.OutputAssets[i] = &indexer.AssetsInUtxo{
+					OutPoint: fmt.Sprintf("%s:%d", tx.TxID(), i),
+					PkScript: txOut.PkScript,
+				}
+				continue
+			}
 			var err error
 			var curr *indexer.TxOutput
 			curr, input, err = input.Cut(txOut.Value)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 
 			var utxoInfo *indexer.AssetsInUtxo
 			if curr != nil {
 				utxoInfo = curr.ToAssetsInUtxo()
 			} else {
-				return nil, fmt.Errorf("inputs have no enough asset for output %d", i)
+				return nil, nil, nil, fmt.Errorf("inputs have no enough asset for output %d", i)
 			}
 			// TODO 需要支持runes协议分配资产
 			result.OutputAssets[i] = utxoInfo
+			outputs[i] = curr
 		}
 	} else {
 		for i, txOut := range tx.TxOut {
@@ -269,7 +294,7 @@ func (p *Manager) GetTxAssetInfoFromPsbt(psbtStr string) (*TxAssetInfo, error) {
 
 	
 
-	return result, nil
+	return result, outputs, input, nil
 }
 
 func GetTxAssetInfoFromPsbt_SatsNet(psbtStr string) (*TxAssetInfo, error) {
@@ -286,13 +311,18 @@ func GetTxAssetInfoFromPsbt_SatsNet(psbtStr string) (*TxAssetInfo, error) {
 		return nil, err
 	}
 
+	return getTxAssetInfo_SatsNet(packet.UnsignedTx, txHex, PsbtPrevOutputFetcher_SatsNet(packet))
+}
+
+func getTxAssetInfo_SatsNet(tx *swire.MsgTx, txHex string,
+	prevOutputFetcher stxscript.PrevOutputFetcher) (*TxAssetInfo, error) {
+
 	result := TxAssetInfo{
-		TxId: packet.UnsignedTx.TxID(),
+		TxId: tx.TxID(),
 		TxHex: txHex,
 	}
 
-	prevOutputFetcher := PsbtPrevOutputFetcher_SatsNet(packet)
-	for _, txIn := range packet.UnsignedTx.TxIn {
+	for _, txIn := range tx.TxIn {
 		utxoInfo := indexer.AssetsInUtxo{
 			OutPoint: txIn.PreviousOutPoint.String(),
 		}
@@ -313,9 +343,9 @@ func GetTxAssetInfoFromPsbt_SatsNet(psbtStr string) (*TxAssetInfo, error) {
 		result.InputAssets = append(result.InputAssets, &utxoInfo)
 	}
 
-	for i, txOut := range packet.UnsignedTx.TxOut {
+	for i, txOut := range tx.TxOut {
 		utxoInfo := indexer.AssetsInUtxo{
-			OutPoint: fmt.Sprintf("%s:%d", packet.UnsignedTx.TxID(), i),
+			OutPoint: fmt.Sprintf("%s:%d", tx.TxID(), i),
 		}
 		
 		utxoInfo.PkScript = txOut.PkScript
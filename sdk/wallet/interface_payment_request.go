@@ -0,0 +1,330 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+)
+
+const (
+	PAYMENT_REQUEST_HRP         = "sat20pay"
+	PAYMENT_REQUEST_HRP_TESTNET = "tsat20pay"
+	PAYMENT_REQUEST_VERSION     = 1
+
+	DEFAULT_PAYMENT_REQUEST_EXPIRY = 3600 // 秒
+
+	PAYMENT_DEST_ADDRESS = 0 // 付到SatsNet地址
+	PAYMENT_DEST_CHANNEL = 1 // 付到收款方的通道地址
+)
+
+// 收款请求，由收款方钱包签名，bech32编码后可以做成二维码
+type PaymentRequest struct {
+	Version         int    `json:"version"`
+	AssetName       string `json:"assetName"`
+	Amount          string `json:"amount"`
+	DestType        int    `json:"destType"`
+	Destination     string `json:"destination"`
+	Timestamp       int64  `json:"timestamp"`
+	Expiry          int64  `json:"expiry"` // 秒，从 Timestamp 开始计算
+	DescriptionHash []byte `json:"descriptionHash,omitempty"`
+	PaymentHash     []byte `json:"paymentHash,omitempty"` // 有值时通过通道的htlc支付
+	PubKey          []byte `json:"pubKey"`
+	Sig             []byte `json:"sig,omitempty"`
+}
+
+type PaymentRequestResult struct {
+	TxId   string `json:"txId,omitempty"`
+	HtlcId uint64 `json:"htlcId,omitempty"`
+}
+
+func paymentRequestHrp() string {
+	if IsTestNet() {
+		return PAYMENT_REQUEST_HRP_TESTNET
+	}
+	return PAYMENT_REQUEST_HRP
+}
+
+func (p *PaymentRequest) IsExpired(now int64) bool {
+	return p.Expiry > 0 && now > p.Timestamp+p.Expiry
+}
+
+// 签名的内容，不包括签名本身
+func (p *PaymentRequest) payload() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(p.Version))
+	if err := wire.WriteVarString(&buf, 0, p.AssetName); err != nil {
+		return nil, err
+	}
+	if err := wire.WriteVarString(&buf, 0, p.Amount); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(byte(p.DestType))
+	if err := wire.WriteVarString(&buf, 0, p.Destination); err != nil {
+		return nil, err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(p.Timestamp)); err != nil {
+		return nil, err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(p.Expiry)); err != nil {
+		return nil, err
+	}
+	for _, b := range [][]byte{p.DescriptionHash, p.PaymentHash, p.PubKey} {
+		if err := wire.WriteVarBytes(&buf, 0, b); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *PaymentRequest) Encode() (string, error) {
+	payload, err := p.payload()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	buf.Write(payload)
+	if err := wire.WriteVarBytes(&buf, 0, p.Sig); err != nil {
+		return "", err
+	}
+	data, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(paymentRequestHrp(), data)
+}
+
+func (p *PaymentRequest) Verify() error {
+	if len(p.Sig) == 0 {
+		return fmt.Errorf("payment request is not signed")
+	}
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	if !VerifyMessageWithPubKey(p.PubKey, payload, p.Sig) {
+		return fmt.Errorf("invalid payment request signature")
+	}
+	return nil
+}
+
+func ParsePaymentRequest(request string) (*PaymentRequest, error) {
+	hrp, data, err := bech32.DecodeNoLimit(request)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != paymentRequestHrp() {
+		return nil, fmt.Errorf("invalid payment request prefix %s", hrp)
+	}
+	raw, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(raw)
+	req := &PaymentRequest{}
+	readByte := func() int {
+		b, e := r.ReadByte()
+		if e != nil && err == nil {
+			err = e
+		}
+		return int(b)
+	}
+	readString := func(name string) string {
+		s, e := wire.ReadVarString(r, 0)
+		if e != nil && err == nil {
+			err = fmt.Errorf("read %s failed, %v", name, e)
+		}
+		return s
+	}
+	readInt := func(name string) int64 {
+		n, e := wire.ReadVarInt(r, 0)
+		if e != nil && err == nil {
+			err = fmt.Errorf("read %s failed, %v", name, e)
+		}
+		return int64(n)
+	}
+	readBytes := func(name string) []byte {
+		b, e := wire.ReadVarBytes(r, 0, 256, name)
+		if e != nil && err == nil {
+			err = e
+		}
+		if len(b) == 0 {
+			return nil
+		}
+		return b
+	}
+
+	req.Version = readByte()
+	if err == nil && req.Version != PAYMENT_REQUEST_VERSION {
+		return nil, fmt.Errorf("unsupported payment request version %d", req.Version)
+	}
+	req.AssetName = readString("assetName")
+	req.Amount = readString("amount")
+	req.DestType = readByte()
+	req.Destination = readString("destination")
+	req.Timestamp = readInt("timestamp")
+	req.Expiry = readInt("expiry")
+	req.DescriptionHash = readBytes("descriptionHash")
+	req.PaymentHash = readBytes("paymentHash")
+	req.PubKey = readBytes("pubKey")
+	req.Sig = readBytes("sig")
+	if err != nil {
+		return nil, err
+	}
+	if _, e := r.ReadByte(); e != io.EOF {
+		return nil, fmt.Errorf("unexpected data after payment request")
+	}
+	if req.DestType != PAYMENT_DEST_ADDRESS && req.DestType != PAYMENT_DEST_CHANNEL {
+		return nil, fmt.Errorf("unknown destination type %d", req.DestType)
+	}
+	if len(req.PaymentHash) != 0 && len(req.PaymentHash) != 32 {
+		return nil, fmt.Errorf("invalid payment hash")
+	}
+
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// channelId 不为空时收款到自己的通道地址，否则收款到 destAddr，默认是当前钱包地址
+func (p *Manager) CreatePaymentRequest(assetName, amt, channelId, destAddr string,
+	expiry int64, description string, paymentHash string) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	name := ParseAssetString(assetName)
+	if name == nil {
+		return "", fmt.Errorf("invalid asset name %s", assetName)
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return "", fmt.Errorf("can't get ticker %s info", assetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
+	if err != nil {
+		return "", err
+	}
+	if dAmt.Sign() <= 0 {
+		return "", fmt.Errorf("invalid amount %s", amt)
+	}
+	if expiry <= 0 {
+		expiry = DEFAULT_PAYMENT_REQUEST_EXPIRY
+	}
+
+	req := &PaymentRequest{
+		Version:   PAYMENT_REQUEST_VERSION,
+		AssetName: name.String(),
+		Amount:    dAmt.String(),
+		Timestamp: time.Now().Unix(),
+		Expiry:    expiry,
+		PubKey:    p.wallet.GetPaymentPubKey().SerializeCompressed(),
+	}
+	if channelId != "" {
+		channel := p.GetChannel(channelId)
+		if channel == nil {
+			return "", fmt.Errorf("can't find channel %s", channelId)
+		}
+		req.DestType = PAYMENT_DEST_CHANNEL
+		req.Destination = channel.Address
+	} else {
+		if destAddr == "" {
+			destAddr = p.wallet.GetAddress()
+		}
+		req.DestType = PAYMENT_DEST_ADDRESS
+		req.Destination = destAddr
+	}
+	if description != "" {
+		hash := sha256.Sum256([]byte(description))
+		req.DescriptionHash = hash[:]
+	}
+	if paymentHash != "" {
+		req.PaymentHash, err = hex.DecodeString(paymentHash)
+		if err != nil || len(req.PaymentHash) != 32 {
+			return "", fmt.Errorf("invalid payment hash %s", paymentHash)
+		}
+		if !indexer.IsPlainAsset(name) {
+			return "", fmt.Errorf("htlc only supports plain sats")
+		}
+	}
+
+	payload, err := req.payload()
+	if err != nil {
+		return "", err
+	}
+	req.Sig, err = p.wallet.SignMessage(payload)
+	if err != nil {
+		return "", err
+	}
+	return req.Encode()
+}
+
+func (p *Manager) DecodePaymentRequest(request string) (*PaymentRequest, error) {
+	return ParsePaymentRequest(request)
+}
+
+// channelId 不为空时从该通道付款，请求中有 paymentHash 时用htlc付款，否则直接解锁到收款地址；
+// channelId 为空时直接在SatsNet上转账，收款方是通道时必须通过通道付款，不能直接转到通道地址
+func (p *Manager) PayRequest(request string, channelId string, feeUtxos []string) (*PaymentRequestResult, error) {
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	req, err := ParsePaymentRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if req.IsExpired(time.Now().Unix()) {
+		return nil, fmt.Errorf("payment request expired")
+	}
+
+	if channelId == "" {
+		// htlc支付必须通过通道
+		if len(req.PaymentHash) != 0 {
+			return nil, fmt.Errorf("payment request with payment hash should be paid through a channel")
+		}
+		// 直接转到通道地址的资产不在通道的状态中，收款方无法使用
+		if req.DestType == PAYMENT_DEST_CHANNEL {
+			return nil, fmt.Errorf("payment request to a channel should be paid through a channel")
+		}
+		tx, err := p.SendAssets_SatsNet(req.Destination, req.AssetName, req.Amount, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &PaymentRequestResult{TxId: tx.TxID()}, nil
+	}
+
+	if len(req.PaymentHash) != 0 {
+		channel := p.GetChannel(channelId)
+		if channel == nil {
+			return nil, fmt.Errorf("can't find channel %s", channelId)
+		}
+		name := ParseAssetString(req.AssetName)
+		if name == nil || !indexer.IsPlainAsset(name) {
+			return nil, fmt.Errorf("htlc only supports plain sats")
+		}
+		dAmt, err := indexer.NewDecimalFromString(req.Amount, 0)
+		if err != nil {
+			return nil, err
+		}
+		expiry := p.GetSyncHeightL1() + int(channel.CsvDelay) + 2*HTLC_MIN_EXPIRY_DELTA
+		htlcId, err := p.AddChannelHtlc(channelId, dAmt.Int64(), hex.EncodeToString(req.PaymentHash),
+			expiry, hex.EncodeToString(req.PubKey), nil)
+		if err != nil {
+			return nil, err
+		}
+		return &PaymentRequestResult{HtlcId: htlcId}, nil
+	}
+
+	txId, _, err := p.UnlockFromChannel(channelId, req.Destination, req.AssetName, req.Amount, feeUtxos, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentRequestResult{TxId: txId}, nil
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+func TestPaymentRequestEncode(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey()
+	descHash := sha256.Sum256([]byte("coffee"))
+	payHash := sha256.Sum256([]byte("preimage"))
+	req := &PaymentRequest{
+		Version:         PAYMENT_REQUEST_VERSION,
+		AssetName:       "::",
+		Amount:          "1000",
+		DestType:        PAYMENT_DEST_ADDRESS,
+		Destination:     "tb1pzv2yq6pe9sy0mx6m4f4ldkf0n5xf8rpnyp0x6d4n2gkpuskn4u2qa7hk0p",
+		Timestamp:       1700000000,
+		Expiry:          600,
+		DescriptionHash: descHash[:],
+		PaymentHash:     payHash[:],
+		PubKey:          privKey.PubKey().SerializeCompressed(),
+	}
+	payload, err := req.payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Sig = ecdsa.Sign(privKey, chainhash.HashB(payload)).Serialize()
+
+	encoded, err := req.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParsePaymentRequest(encoded)
+	if err != nil {
+		t.Fatalf("ParsePaymentRequest failed, %v", err)
+	}
+	if decoded.Amount != req.Amount || decoded.Destination != req.Destination ||
+		decoded.Expiry != req.Expiry || !bytes.Equal(decoded.PaymentHash, req.PaymentHash) {
+		t.Fatalf("decoded request mismatch %+v", decoded)
+	}
+	if decoded.IsExpired(1700000600) || !decoded.IsExpired(1700000601) {
+		t.Fatalf("unexpected expiry")
+	}
+
+	// 改了金额后签名不再有效
+	req.Amount = "100000"
+	tampered, err := req.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePaymentRequest(tampered); err == nil {
+		t.Fatalf("tampered request should fail")
+	}
+
+	// 签名正确但收款类型未知
+	req.Amount = "1000"
+	req.DestType = 2
+	payload, err = req.payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Sig = ecdsa.Sign(privKey, chainhash.HashB(payload)).Serialize()
+	unknown, err := req.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePaymentRequest(unknown); err == nil || !strings.Contains(err.Error(), "destination type") {
+		t.Fatalf("unknown destination type should fail, got %v", err)
+	}
+	if _, err := ParsePaymentRequest("bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"); err == nil {
+		t.Fatalf("address is not a payment request")
+	}
+}
+
+func TestPayRequestToChannel(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	manager.wallet = NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	if manager.wallet == nil {
+		t.Fatal("NewInternalWalletWithMnemonic failed")
+	}
+
+	privKey, _ := btcec.NewPrivateKey()
+	req := &PaymentRequest{
+		Version:     PAYMENT_REQUEST_VERSION,
+		AssetName:   "::",
+		Amount:      "1000",
+		DestType:    PAYMENT_DEST_CHANNEL,
+		Destination: "tb1pzv2yq6pe9sy0mx6m4f4ldkf0n5xf8rpnyp0x6d4n2gkpuskn4u2qa7hk0p",
+		Timestamp:   time.Now().Unix(),
+		Expiry:      600,
+		PubKey:      privKey.PubKey().SerializeCompressed(),
+	}
+	payload, err := req.payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Sig = ecdsa.Sign(privKey, chainhash.HashB(payload)).Serialize()
+	encoded, err := req.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不能直接转到收款方的通道地址
+	if _, err := manager.PayRequest(encoded, "", nil); err == nil || !strings.Contains(err.Error(), "through a channel") {
+		t.Fatalf("payment to a channel without channel id should fail, got %v", err)
+	}
+}
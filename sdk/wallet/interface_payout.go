@@ -0,0 +1,763 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+/*
+批量发放（空投）
+1. 收款文件：json 数组 [{"address", "amt", "value"}]，或者 csv 每行 address,amt[,value]，第一行可以是标题
+2. 创建时检查所有地址和数量，保存为 reservation，重启后由监控线程继续发送
+3. 按标准交易的限制分批：每批先签名并保存交易，再广播，重启后只会重新广播保存的交易，不会重复支付
+4. 支持 L1 的白聪、ordx、runes，以及聪网的所有资产；brc20 需要先铸造 transfer，不支持
+*/
+
+const (
+	RS_PAYOUT_STARTED   ResvStatus = 0x2600
+	RS_PAYOUT_COMPLETED ResvStatus = RS_CONFIRMED
+)
+
+const (
+	PAYOUT_BATCH_SIGNED      = 0 // 已签名保存，还没有广播成功
+	PAYOUT_BATCH_BROADCASTED = 1
+
+	PAYOUT_MAX_RECIPIENTS         = 200 // 每个交易最多的收款人
+	PAYOUT_MAX_RECIPIENTS_RUNES   = 8   // runestone 只能放在一个80字节的 OP_RETURN 中
+	PAYOUT_MAX_RECIPIENTS_SATSNET = 200
+	PAYOUT_MAX_TX_WEIGHT          = 400000 // 标准交易的最大weight
+	PAYOUT_RETRY_INTERVAL         = 60     // 秒，失败后监控线程重试的间隔
+)
+
+type PayoutRecipient struct {
+	Address string `json:"address"`
+	Amt     string `json:"amt"`             // 资产数量，白聪时是聪数量
+	Value   int64  `json:"value,omitempty"` // 资产之外额外发送的聪
+	TxId    string `json:"txId,omitempty"`
+}
+
+type PayoutBatch struct {
+	Recipients []int // 在 PayoutReservation.Recipients 中的序号
+	TxId       string
+	TxHex      string // 签名后的交易
+	Fee        int64
+	Status     int
+}
+
+type PayoutReservation struct {
+	ReservationBase
+	AssetName  string
+	SatsNet    bool
+	FeeRate    int64
+	Memo       []byte
+	Recipients []*PayoutRecipient
+	Batches    []*PayoutBatch
+	CreateTime int64
+	LastError  string
+
+	running bool
+	lastRun int64
+}
+
+func (p *PayoutReservation) GetType() string {
+	return RESV_TYPE_PAYOUT
+}
+
+func (p *PayoutReservation) GetStructInDB() any {
+	return p
+}
+
+// 还没有进入任何交易的收款人
+func (p *PayoutReservation) pendingRecipients() []int {
+	result := make([]int, 0)
+	for i, r := range p.Recipients {
+		if r.TxId == "" {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func (p *PayoutReservation) tryStart() bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.running {
+		return false
+	}
+	p.running = true
+	p.lastRun = time.Now().Unix()
+	return true
+}
+
+func (p *PayoutReservation) stop(err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.running = false
+	if err != nil {
+		p.LastError = err.Error()
+	} else {
+		p.LastError = ""
+	}
+}
+
+type PayoutResult struct {
+	Address string `json:"address"`
+	Amt     string `json:"amt"`
+	Value   int64  `json:"value"`
+	TxId    string `json:"txId"`
+	Status  string `json:"status"` // pending, signed, broadcasted
+}
+
+type PayoutReport struct {
+	Id         int64           `json:"id"`
+	AssetName  string          `json:"assetName"`
+	SatsNet    bool            `json:"satsNet"`
+	Status     string          `json:"status"` // running, completed, cancelled
+	Total      int             `json:"total"`
+	Sent       int             `json:"sent"`
+	Fee        int64           `json:"fee"`
+	LastError  string          `json:"lastError"`
+	TxIds      []string        `json:"txIds"`
+	Recipients []*PayoutResult `json:"recipients"`
+}
+
+// 每个收款人一行：address,amt,value,txid,status
+func (p *PayoutReport) CSV() string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"address", "amt", "value", "txid", "status"})
+	for _, r := range p.Recipients {
+		w.Write([]string{r.Address, r.Amt, strconv.FormatInt(r.Value, 10), r.TxId, r.Status})
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// 解析收款文件，只检查格式，地址和数量在 NewPayout 中检查
+func ParsePayoutRecipients(data []byte) ([]*PayoutRecipient, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payout file")
+	}
+
+	var result []*PayoutRecipient
+	if data[0] == '[' {
+		err := json.Unmarshal(data, &result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json payout file, %v", err)
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		reader.Comment = '#'
+		line := 0
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid csv payout file, %v", err)
+			}
+			line++
+			if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+				continue
+			}
+			if len(record) < 2 || len(record) > 3 {
+				return nil, fmt.Errorf("line %d: expected address,amt[,value]", line)
+			}
+			recipient := &PayoutRecipient{
+				Address: strings.TrimSpace(record[0]),
+				Amt:     strings.TrimSpace(record[1]),
+			}
+			if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+				recipient.Value, err = strconv.ParseInt(strings.TrimSpace(record[2]), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid value %s", line, record[2])
+				}
+			}
+			result = append(result, recipient)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no recipient in payout file")
+	}
+	for i, r := range result {
+		if r == nil || r.Address == "" || r.Amt == "" {
+			return nil, fmt.Errorf("recipient %d: address and amt are required", i)
+		}
+		if r.Value < 0 {
+			return nil, fmt.Errorf("recipient %d: invalid value %d", i, r.Value)
+		}
+		r.TxId = ""
+	}
+	return result, nil
+}
+
+func payoutBatchSize(protocol string, satsNet bool) int {
+	if satsNet {
+		return PAYOUT_MAX_RECIPIENTS_SATSNET
+	}
+	if protocol == indexer.PROTOCOL_NAME_RUNES {
+		return PAYOUT_MAX_RECIPIENTS_RUNES
+	}
+	return PAYOUT_MAX_RECIPIENTS
+}
+
+// 创建发放任务并保存，由 RunPayout 或者监控线程发送
+func (p *Manager) NewPayout(data []byte, assetName string, satsNet bool,
+	feeRate int64, memo []byte) (*PayoutReservation, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	recipients, err := ParsePayoutRecipients(data)
+	if err != nil {
+		return nil, err
+	}
+	if satsNet {
+		if !IsValidNullData_SatsNet(memo) {
+			return nil, fmt.Errorf("invalid length of null data %d", len(memo))
+		}
+	} else if !IsValidNullData(memo) {
+		return nil, fmt.Errorf("invalid length of null data %d", len(memo))
+	}
+
+	name := ParseAssetString(assetName)
+	if name == nil {
+		return nil, fmt.Errorf("invalid asset name %s", assetName)
+	}
+	if !satsNet {
+		switch name.Protocol {
+		case "", indexer.PROTOCOL_NAME_ORDX, indexer.PROTOCOL_NAME_RUNES:
+		default:
+			return nil, fmt.Errorf("payout unsupport protocol %s", name.Protocol)
+		}
+		if name.Protocol == indexer.PROTOCOL_NAME_RUNES && len(memo) != 0 {
+			return nil, fmt.Errorf("do not attach memo when send runes asset")
+		}
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", assetName)
+	}
+
+	for i, r := range recipients {
+		if satsNet {
+			_, err = GetPkScriptFromAddress_SatsNet(r.Address)
+		} else {
+			_, err = GetPkScriptFromAddress(r.Address)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %s %v", i, r.Address, err)
+		}
+		dAmt, err := indexer.NewDecimalFromString(r.Amt, tickerInfo.Divisibility)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: invalid amt %s, %v", i, r.Amt, err)
+		}
+		if dAmt.Sign() <= 0 {
+			return nil, fmt.Errorf("recipient %d: invalid amt %s", i, r.Amt)
+		}
+		if satsNet {
+			continue
+		}
+		if indexer.IsPlainAsset(name) {
+			if dAmt.Int64()+r.Value < 330 {
+				return nil, fmt.Errorf("recipient %d: value should be larger than 330", i)
+			}
+		} else if r.Value != 0 && r.Value < 330 {
+			return nil, fmt.Errorf("recipient %d: value should be larger than 330", i)
+		}
+	}
+
+	resv := &PayoutReservation{
+		ReservationBase: NewReservationBase(p.GenerateNewResvId(), true, RS_PAYOUT_STARTED, p.wallet),
+		AssetName:       name.String(),
+		SatsNet:         satsNet,
+		FeeRate:         feeRate,
+		Memo:            memo,
+		Recipients:      recipients,
+		CreateTime:      time.Now().Unix(),
+	}
+	p.addResv(resv)
+	err = p.SaveWalletReservation(resv)
+	if err != nil {
+		p.DelResvWithId(resv.Id)
+		return nil, err
+	}
+	Log.Infof("NewPayout %d: %d recipients of %s", resv.Id, len(recipients), resv.AssetName)
+	return resv, nil
+}
+
+func (p *Manager) GetPayout(id int64) *PayoutReservation {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.payoutMap[id]
+}
+
+func (p *Manager) GetPayouts() []*PayoutReservation {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result := make([]*PayoutReservation, 0, len(p.payoutMap))
+	for _, resv := range p.payoutMap {
+		result = append(result, resv)
+	}
+	return result
+}
+
+func (p *Manager) savePayout(resv *PayoutReservation) error {
+	resv.RLock()
+	defer resv.RUnlock()
+	return p.SaveWalletReservation(resv)
+}
+
+// 发送所有还没有发送的批次，直到完成或者出错。出错后可以再次调用，监控线程也会重试
+func (p *Manager) RunPayout(id int64) error {
+	if p.wallet == nil {
+		return fmt.Errorf("wallet is not created/unlocked")
+	}
+	resv := p.GetPayout(id)
+	if resv == nil {
+		return fmt.Errorf("can't find payout %d", id)
+	}
+	if resv.GetStatus() != RS_PAYOUT_STARTED {
+		return fmt.Errorf("payout %d is not running, status 0x%x", id, resv.GetStatus())
+	}
+	if resv.WalletId != p.wallet.GetWalletId() {
+		return fmt.Errorf("payout %d belongs to another wallet", id)
+	}
+	if !resv.tryStart() {
+		return fmt.Errorf("payout %d is running", id)
+	}
+
+	err := p.runPayout(resv)
+	resv.stop(err)
+	if saveErr := p.savePayout(resv); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		Log.Errorf("RunPayout %d failed. %v", id, err)
+	}
+	return err
+}
+
+func (p *Manager) runPayout(resv *PayoutReservation) error {
+	// 先把已经签名的交易广播出去
+	for _, batch := range resv.Batches {
+		if batch.Status == PAYOUT_BATCH_SIGNED {
+			err := p.broadcastPayoutBatch(resv, batch)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	name := ParseAssetString(resv.AssetName)
+	if name == nil {
+		return fmt.Errorf("invalid asset name %s", resv.AssetName)
+	}
+	maxSize := payoutBatchSize(name.Protocol, resv.SatsNet)
+	for {
+		if resv.GetStatus() != RS_PAYOUT_STARTED {
+			// 被取消
+			return nil
+		}
+		pending := resv.pendingRecipients()
+		if len(pending) == 0 {
+			break
+		}
+
+		// 构造失败时减少收款人重试，比如 runestone 太大，或者交易超过标准大小
+		size := min(maxSize, len(pending))
+		var batch *PayoutBatch
+		var err error
+		for {
+			batch, err = p.buildPayoutBatch(resv, pending[:size])
+			if err == nil || size == 1 {
+				break
+			}
+			Log.Warnf("buildPayoutBatch with %d recipients failed, retry with %d. %v", size, size/2, err)
+			size /= 2
+		}
+		if err != nil {
+			return err
+		}
+
+		// 先保存签名后的交易，再广播
+		resv.Lock()
+		resv.Batches = append(resv.Batches, batch)
+		for _, i := range batch.Recipients {
+			resv.Recipients[i].TxId = batch.TxId
+		}
+		resv.Unlock()
+		err = p.savePayout(resv)
+		if err != nil {
+			return err
+		}
+
+		err = p.broadcastPayoutBatch(resv, batch)
+		if err != nil {
+			return err
+		}
+		Log.Infof("payout %d: batch %d sent %s, %d recipients, fee %d", resv.Id,
+			len(resv.Batches)-1, batch.TxId, len(batch.Recipients), batch.Fee)
+	}
+
+	resv.Lock()
+	resv.Status = RS_PAYOUT_COMPLETED
+	resv.Unlock()
+	Log.Infof("payout %d completed, %d recipients in %d txs", resv.Id, len(resv.Recipients), len(resv.Batches))
+	return nil
+}
+
+func (p *Manager) payoutDest(resv *PayoutReservation, indexes []int) ([]*SendAssetInfo, error) {
+	name := ParseAssetString(resv.AssetName)
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", resv.AssetName)
+	}
+	dest := make([]*SendAssetInfo, 0, len(indexes))
+	for _, i := range indexes {
+		r := resv.Recipients[i]
+		dAmt, err := indexer.NewDecimalFromString(r.Amt, tickerInfo.Divisibility)
+		if err != nil {
+			return nil, err
+		}
+		info := &SendAssetInfo{
+			Address:   r.Address,
+			Value:     r.Value,
+			AssetName: name,
+			AssetAmt:  dAmt,
+		}
+		if indexer.IsPlainAsset(name) {
+			info.Value += dAmt.Int64()
+			info.AssetAmt = nil
+		}
+		dest = append(dest, info)
+	}
+	return dest, nil
+}
+
+// 构造并签名一批交易，不广播
+func (p *Manager) buildPayoutBatch(resv *PayoutReservation, indexes []int) (*PayoutBatch, error) {
+	dest, err := p.payoutDest(resv, indexes)
+	if err != nil {
+		return nil, err
+	}
+	batch := &PayoutBatch{
+		Recipients: append([]int(nil), indexes...),
+		Status:     PAYOUT_BATCH_SIGNED,
+	}
+
+	if resv.SatsNet {
+		name := ParseAssetString(resv.AssetName)
+		var totalValue int64
+		var totalAmt *Decimal
+		for _, d := range dest {
+			totalAmt = totalAmt.Add(d.AssetAmt)
+			totalValue += d.Value
+		}
+		utxos, fees, err := p.GetUtxosWithAssetV2_SatsNet("", totalValue, totalAmt, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		tx, prevFetcher, err := p.BuildBatchSendTxV2_SatsNet("", dest, name, utxos, fees, resv.Memo)
+		if err != nil {
+			return nil, err
+		}
+		tx, err = p.SignTx_SatsNet(tx, prevFetcher)
+		if err != nil {
+			return nil, err
+		}
+		batch.TxHex, err = EncodeMsgTx_SatsNet(tx)
+		if err != nil {
+			return nil, err
+		}
+		batch.TxId = tx.TxID()
+		batch.Fee = DEFAULT_FEE_SATSNET
+		return batch, nil
+	}
+
+	// 不设置RBF，加速后txid会变化，无法跟踪发送的进度
+	tx, prevFetcher, fee, _, err := p.buildBatchSendAssetsV3(dest, resv.AssetName,
+		resv.FeeRate, resv.Memo, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	tx, err = p.SignTx(tx, prevFetcher)
+	if err != nil {
+		return nil, err
+	}
+	weight := GetTransactionWeight2(tx)
+	if weight > PAYOUT_MAX_TX_WEIGHT {
+		return nil, fmt.Errorf("tx weight %d exceeds standard limit", weight)
+	}
+	batch.TxHex, err = EncodeMsgTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	batch.TxId = tx.TxID()
+	batch.Fee = fee
+	return batch, nil
+}
+
+const (
+	payoutBatchPending  = iota // 无法确认交易状态，保持待广播，下次重试
+	payoutBatchOnChain         // 交易已经在链上或者内存池中
+	payoutBatchConflict        // 输入已经被其他交易花费
+)
+
+const PAYOUT_TX_QUERY_RETRY = 3
+
+type payoutTxChecker interface {
+	GetRawTx(tx string) (string, error)
+	GetTxHeight(tx string) (int, error)
+	GetExistingUtxos(utxos []string) ([]string, error)
+	GetUtxoSpentTx(utxo string) (string, error)
+}
+
+// 广播失败后检查交易状态。只有确认输入被其他交易花费时才放弃这一批，
+// 查询失败或者找不到花费交易时保持待广播，否则收款人会被重复付款
+func checkPayoutBatchTx(client payoutTxChecker, txId string, inputs []string) int {
+	for i := 0; i < PAYOUT_TX_QUERY_RETRY; i++ {
+		if _, err := client.GetRawTx(txId); err == nil {
+			return payoutBatchOnChain
+		}
+		if _, err := client.GetTxHeight(txId); err == nil {
+			return payoutBatchOnChain
+		}
+	}
+
+	existing, err := client.GetExistingUtxos(inputs)
+	if err != nil || len(existing) == len(inputs) {
+		return payoutBatchPending
+	}
+	unspent := make(map[string]bool)
+	for _, utxo := range existing {
+		unspent[utxo] = true
+	}
+	for _, utxo := range inputs {
+		if unspent[utxo] {
+			continue
+		}
+		spentTx, err := client.GetUtxoSpentTx(utxo)
+		if err != nil || spentTx == "" {
+			continue
+		}
+		if spentTx == txId {
+			return payoutBatchOnChain
+		}
+		return payoutBatchConflict
+	}
+	return payoutBatchPending
+}
+
+// 放弃这一批，收款人会进入新的批次
+func (p *PayoutReservation) dropBatch(batch *PayoutBatch) {
+	p.Lock()
+	defer p.Unlock()
+	for _, i := range batch.Recipients {
+		if p.Recipients[i].TxId == batch.TxId {
+			p.Recipients[i].TxId = ""
+		}
+	}
+	for i, b := range p.Batches {
+		if b == batch {
+			p.Batches = append(p.Batches[:i], p.Batches[i+1:]...)
+			break
+		}
+	}
+}
+
+// 广播保存的交易。交易已经在链上或者内存池中时只锁定utxo；
+// 交易无效并且输入已经被其他交易花费时，放弃这一批，收款人会进入新的批次
+func (p *Manager) broadcastPayoutBatch(resv *PayoutReservation, batch *PayoutBatch) error {
+	var err error
+	var inputs []string
+	var lockUtxos func()
+	client := p.l1IndexerClient
+	if resv.SatsNet {
+		client = p.l2IndexerClient
+		var tx *swire.MsgTx
+		tx, err = DecodeMsgTx_SatsNet(batch.TxHex)
+		if err != nil {
+			return err
+		}
+		for _, txIn := range tx.TxIn {
+			inputs = append(inputs, txIn.PreviousOutPoint.String())
+		}
+		lockUtxos = func() { p.utxoLockerL2.LockUtxosWithTx_SatsNet(tx) }
+		if _, rawErr := client.GetRawTx(batch.TxId); rawErr == nil {
+			lockUtxos()
+		} else {
+			_, err = p.BroadcastTx_SatsNet(tx)
+		}
+	} else {
+		var tx *wire.MsgTx
+		tx, err = DecodeMsgTx(batch.TxHex)
+		if err != nil {
+			return err
+		}
+		for _, txIn := range tx.TxIn {
+			inputs = append(inputs, txIn.PreviousOutPoint.String())
+		}
+		lockUtxos = func() { p.utxoLockerL1.LockUtxosWithTx(tx) }
+		if _, rawErr := client.GetRawTx(batch.TxId); rawErr == nil {
+			lockUtxos()
+		} else {
+			err = p.BroadcastTxs([]*wire.MsgTx{tx})
+		}
+	}
+
+	if err != nil {
+		switch checkPayoutBatchTx(client, batch.TxId, inputs) {
+		case payoutBatchOnChain:
+			Log.Infof("payout %d: tx %s is already on chain", resv.Id, batch.TxId)
+			lockUtxos()
+		case payoutBatchConflict:
+			Log.Warnf("payout %d: inputs of tx %s have been spent by another tx, drop it. %v", resv.Id, batch.TxId, err)
+			resv.dropBatch(batch)
+			return p.savePayout(resv)
+		default:
+			return fmt.Errorf("broadcast payout tx %s failed, %v", batch.TxId, err)
+		}
+	}
+
+	resv.Lock()
+	batch.Status = PAYOUT_BATCH_BROADCASTED
+	resv.Unlock()
+	return p.savePayout(resv)
+}
+
+// 取消后不再发送新的批次，已经广播的交易不受影响
+func (p *Manager) CancelPayout(id int64) error {
+	resv := p.GetPayout(id)
+	if resv == nil {
+		return fmt.Errorf("can't find payout %d", id)
+	}
+	resv.Lock()
+	if resv.Status != RS_PAYOUT_STARTED {
+		resv.Unlock()
+		return fmt.Errorf("payout %d is not running, status 0x%x", id, resv.Status)
+	}
+	resv.Status = RS_CLOSED
+	resv.Unlock()
+	return p.savePayout(resv)
+}
+
+func (p *Manager) GetPayoutReport(id int64) (*PayoutReport, error) {
+	resv := p.GetPayout(id)
+	if resv == nil {
+		return nil, fmt.Errorf("can't find payout %d", id)
+	}
+	resv.RLock()
+	defer resv.RUnlock()
+
+	report := &PayoutReport{
+		Id:        resv.Id,
+		AssetName: resv.AssetName,
+		SatsNet:   resv.SatsNet,
+		Total:     len(resv.Recipients),
+		LastError: resv.LastError,
+	}
+	switch resv.Status {
+	case RS_PAYOUT_STARTED:
+		report.Status = "running"
+	case RS_PAYOUT_COMPLETED:
+		report.Status = "completed"
+	default:
+		report.Status = "cancelled"
+	}
+
+	batchStatus := make(map[string]int)
+	for _, batch := range resv.Batches {
+		batchStatus[batch.TxId] = batch.Status
+		report.Fee += batch.Fee
+		report.TxIds = append(report.TxIds, batch.TxId)
+	}
+	for _, r := range resv.Recipients {
+		result := &PayoutResult{
+			Address: r.Address,
+			Amt:     r.Amt,
+			Value:   r.Value,
+			TxId:    r.TxId,
+			Status:  "pending",
+		}
+		if r.TxId != "" {
+			if batchStatus[r.TxId] == PAYOUT_BATCH_BROADCASTED {
+				result.Status = "broadcasted"
+				report.Sent++
+			} else {
+				result.Status = "signed"
+			}
+		}
+		report.Recipients = append(report.Recipients, result)
+	}
+	return report, nil
+}
+
+// 同一层只允许一个后台发送
+func (p *Manager) tryStartPayoutRunning(index int) bool {
+	p.payoutMutex.Lock()
+	defer p.payoutMutex.Unlock()
+	if p.payoutRunning[index] {
+		return false
+	}
+	p.payoutRunning[index] = true
+	return true
+}
+
+func (p *Manager) stopPayoutRunning(index int) {
+	p.payoutMutex.Lock()
+	defer p.payoutMutex.Unlock()
+	p.payoutRunning[index] = false
+}
+
+// 重启后继续发送，失败的任务每 PAYOUT_RETRY_INTERVAL 秒重试一次
+func (p *Manager) handlePayoutMonitorTick(sendTxInL1 bool) {
+	if p == nil || p.wallet == nil {
+		return
+	}
+	index := 0
+	if !sendTxInL1 {
+		index = 1
+	}
+	now := time.Now().Unix()
+	walletId := p.wallet.GetWalletId()
+	var ids []int64
+	for _, resv := range p.GetPayouts() {
+		resv.RLock()
+		ready := resv.Status == RS_PAYOUT_STARTED && resv.SatsNet != sendTxInL1 &&
+			resv.WalletId == walletId && !resv.running && now-resv.lastRun >= PAYOUT_RETRY_INTERVAL
+		resv.RUnlock()
+		if ready {
+			ids = append(ids, resv.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	// 上一轮发送还没有结束
+	if !p.tryStartPayoutRunning(index) {
+		return
+	}
+	// 发送需要签名和广播多个批次，在后台执行，不阻塞监控线程
+	go func() {
+		defer p.stopPayoutRunning(index)
+		for _, id := range ids {
+			p.RunPayout(id)
+		}
+	}()
+}
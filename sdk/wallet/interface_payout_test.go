@@ -0,0 +1,147 @@
+package wallet
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePayoutRecipients(t *testing.T) {
+	csvData := "address,amt,value\n# comment\naddr1, 100\naddr2,0.5,330\n"
+	recipients, err := ParsePayoutRecipients([]byte(csvData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 || recipients[0].Address != "addr1" || recipients[0].Amt != "100" ||
+		recipients[1].Amt != "0.5" || recipients[1].Value != 330 {
+		t.Fatalf("unexpected csv recipients %v", recipients)
+	}
+
+	jsonData := `[{"address":"addr1","amt":"100"},{"address":"addr2","amt":"1","value":1000,"txId":"x"}]`
+	recipients, err = ParsePayoutRecipients([]byte(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 || recipients[1].Value != 1000 || recipients[1].TxId != "" {
+		t.Fatalf("unexpected json recipients %v", recipients)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"address,amt\n",
+		"addr1\n",
+		"addr1,1,2,3\n",
+		"addr1,1,abc\n",
+		`[{"address":"addr1"}]`,
+		`[{"address":"addr1","amt":"1","value":-1}]`,
+	} {
+		if _, err := ParsePayoutRecipients([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestPayoutReportCSV(t *testing.T) {
+	report := &PayoutReport{
+		Recipients: []*PayoutResult{
+			{Address: "addr1", Amt: "100", TxId: "tx1", Status: "broadcasted"},
+			{Address: "addr2", Amt: "1", Value: 330, Status: "pending"},
+		},
+	}
+	expected := "address,amt,value,txid,status\naddr1,100,0,tx1,broadcasted\naddr2,1,330,,pending\n"
+	if csv := report.CSV(); csv != expected {
+		t.Fatalf("unexpected csv %q", csv)
+	}
+}
+
+type testPayoutTxChecker struct {
+	rawTxErrs int               // GetRawTx 前几次失败
+	onChain   bool              // 交易是否在链上
+	existing  []string          // 没有被花费的输入
+	spentTx   map[string]string // 输入被哪个交易花费
+	queryErr  bool
+}
+
+func (p *testPayoutTxChecker) GetRawTx(tx string) (string, error) {
+	if p.rawTxErrs > 0 {
+		p.rawTxErrs--
+		return "", fmt.Errorf("timeout")
+	}
+	if p.onChain {
+		return "raw", nil
+	}
+	return "", fmt.Errorf("not found")
+}
+
+func (p *testPayoutTxChecker) GetTxHeight(tx string) (int, error) {
+	return 0, fmt.Errorf("not found")
+}
+
+func (p *testPayoutTxChecker) GetExistingUtxos(utxos []string) ([]string, error) {
+	if p.queryErr {
+		return nil, fmt.Errorf("timeout")
+	}
+	return p.existing, nil
+}
+
+func (p *testPayoutTxChecker) GetUtxoSpentTx(utxo string) (string, error) {
+	return p.spentTx[utxo], nil
+}
+
+func TestCheckPayoutBatchTx(t *testing.T) {
+	inputs := []string{"in:0", "in:1"}
+	cases := []struct {
+		name    string
+		checker *testPayoutTxChecker
+		result  int
+	}{
+		// 恢复时重新广播，交易已经确认，第一次查询失败
+		{"confirmed after transient error", &testPayoutTxChecker{rawTxErrs: 1, onChain: true}, payoutBatchOnChain},
+		{"spent by batch tx", &testPayoutTxChecker{spentTx: map[string]string{"in:0": "batch", "in:1": "batch"}}, payoutBatchOnChain},
+		{"spent by unknown tx", &testPayoutTxChecker{}, payoutBatchPending},
+		{"query failed", &testPayoutTxChecker{queryErr: true}, payoutBatchPending},
+		{"inputs unspent", &testPayoutTxChecker{existing: inputs}, payoutBatchPending},
+		{"spent by other tx", &testPayoutTxChecker{existing: []string{"in:1"}, spentTx: map[string]string{"in:0": "other"}}, payoutBatchConflict},
+	}
+	for _, c := range cases {
+		result := checkPayoutBatchTx(c.checker, "batch", inputs)
+		if result != c.result {
+			t.Fatalf("%s: expected %d, got %d", c.name, c.result, result)
+		}
+	}
+}
+
+func TestPayoutDropBatch(t *testing.T) {
+	resv := &PayoutReservation{
+		Recipients: []*PayoutRecipient{{Address: "a", TxId: "tx1"}, {Address: "b", TxId: "tx2"}},
+	}
+	batch1 := &PayoutBatch{Recipients: []int{0}, TxId: "tx1"}
+	batch2 := &PayoutBatch{Recipients: []int{1}, TxId: "tx2"}
+	resv.Batches = []*PayoutBatch{batch1, batch2}
+
+	resv.dropBatch(batch1)
+	if len(resv.Batches) != 1 || resv.Batches[0] != batch2 {
+		t.Fatalf("batch not dropped %v", resv.Batches)
+	}
+	pending := resv.pendingRecipients()
+	if len(pending) != 1 || pending[0] != 0 || resv.Recipients[1].TxId != "tx2" {
+		t.Fatalf("unexpected pending recipients %v", pending)
+	}
+}
+
+func TestPayoutRunning(t *testing.T) {
+	manager := &Manager{}
+	if !manager.tryStartPayoutRunning(0) {
+		t.Fatalf("should start")
+	}
+	if manager.tryStartPayoutRunning(0) {
+		t.Fatalf("payout is running")
+	}
+	// 两层互不影响
+	if !manager.tryStartPayoutRunning(1) {
+		t.Fatalf("should start in layer 2")
+	}
+	manager.stopPayoutRunning(0)
+	if !manager.tryStartPayoutRunning(0) {
+		t.Fatalf("should start again")
+	}
+}
@@ -0,0 +1,278 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	stxscript "github.com/sat20-labs/satoshinet/txscript"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+/*
+交易预览：构造交易但不签名，不广播，不锁定utxo，不修改数据库
+返回未签名的交易，每个输出的资产，网络费，找零，将被花费的utxo，以及可能的问题：
+1. dust：L1 输出的聪数量低于输出脚本对应的 dust 值，和签名前的检查相同
+2. burn：资产没有分配到任何输出，会被当作网络费烧掉
+3. misaligned：输出的聪数量不足以承载绑定的资产
+*/
+
+const (
+	PREVIEW_WARNING_DUST       = "dust"
+	PREVIEW_WARNING_BURN       = "burn"
+	PREVIEW_WARNING_MISALIGNED = "misaligned"
+)
+
+type TxPreview struct {
+	TxAssetInfo
+	Fee      int64    `json:"fee"`
+	Change   int64    `json:"change"`   // 回到本钱包的白聪
+	Consumed []string `json:"consumed"` // 广播后会被锁定的utxo
+	Warnings []string `json:"warnings"`
+}
+
+func previewWarning(kind, format string, args ...any) string {
+	return kind + ": " + fmt.Sprintf(format, args...)
+}
+
+// 资产绑定的聪不够
+func misalignedAssets(index int, value int64, assets indexer.TxAssets) []string {
+	var warnings []string
+	for _, asset := range assets {
+		if asset.BindingSat == 0 {
+			continue
+		}
+		required := indexer.GetBindingSatNum(&asset.Amount, asset.BindingSat)
+		if value < required {
+			warnings = append(warnings, previewWarning(PREVIEW_WARNING_MISALIGNED,
+				"output %d has %d sats but %s %s needs %d", index, value,
+				asset.Amount.String(), asset.Name.String(), required))
+		}
+	}
+	return warnings
+}
+
+// 没有分配到输出的资产，runes 这类不绑定聪的资产按协议规则分配，不在这里检查
+func burnedAssets(leftover indexer.TxAssets) []string {
+	var warnings []string
+	for _, asset := range leftover {
+		if indexer.IsPlainAsset(&asset.Name) || asset.BindingSat == 0 || asset.Amount.Sign() == 0 {
+			continue
+		}
+		warnings = append(warnings, previewWarning(PREVIEW_WARNING_BURN,
+			"%s %s is not assigned to any output", asset.Amount.String(), asset.Name.String()))
+	}
+	return warnings
+}
+
+func dustOutputs(tx *wire.MsgTx) []string {
+	var warnings []string
+	for i, txOut := range tx.TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.NullDataTy {
+			continue
+		}
+		if txOut.Value < dustThreshold(txOut) {
+			warnings = append(warnings, previewWarning(PREVIEW_WARNING_DUST,
+				"output %d has only %d sats", i, txOut.Value))
+		}
+	}
+	return warnings
+}
+
+func isPkScriptIn(pkScript []byte, pkScripts [][]byte) bool {
+	for _, s := range pkScripts {
+		if bytes.Equal(pkScript, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Manager) newTxPreview(tx *wire.MsgTx, fee int64) (*TxPreview, error) {
+	txHex, err := EncodeMsgTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	info, outputs, leftover, err := p.getTxAssetInfo(tx, txHex, true)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &TxPreview{
+		TxAssetInfo: *info,
+		Fee:         fee,
+		Warnings:    dustOutputs(tx),
+	}
+	for _, txIn := range tx.TxIn {
+		preview.Consumed = append(preview.Consumed, txIn.PreviousOutPoint.String())
+	}
+	pkScripts := p.walletPkScripts()
+	for i, output := range outputs {
+		if output == nil {
+			continue
+		}
+		preview.Warnings = append(preview.Warnings, misalignedAssets(i, output.OutValue.Value, output.Assets)...)
+		if len(output.Assets) == 0 && isPkScriptIn(tx.TxOut[i].PkScript, pkScripts) {
+			preview.Change += tx.TxOut[i].Value
+		}
+	}
+	if leftover != nil {
+		preview.Warnings = append(preview.Warnings, burnedAssets(leftover.Assets)...)
+	}
+	return preview, nil
+}
+
+func (p *Manager) newTxPreview_SatsNet(tx *swire.MsgTx, prevFetcher stxscript.PrevOutputFetcher) (*TxPreview, error) {
+	txHex, err := EncodeMsgTx_SatsNet(tx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := getTxAssetInfo_SatsNet(tx, txHex, prevFetcher)
+	if err != nil {
+		return nil, err
+	}
+	changePkScript, err := GetP2TRpkScript(p.wallet.GetPaymentPubKey())
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &TxPreview{TxAssetInfo: *info}
+	var input TxOutput_SatsNet
+	for _, txIn := range tx.TxIn {
+		preview.Consumed = append(preview.Consumed, txIn.PreviousOutPoint.String())
+		prevOut := prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			return nil, fmt.Errorf("can't find output info for utxo %s", txIn.PreviousOutPoint.String())
+		}
+		input.Merge(TxOutToOutput(prevOut))
+	}
+	for i, txOut := range tx.TxOut {
+		output := TxOutToOutput(txOut)
+		if err := input.Subtract(output); err != nil {
+			return nil, fmt.Errorf("inputs have no enough asset for output %d, %v", i, err)
+		}
+		preview.Warnings = append(preview.Warnings, misalignedAssets(i, txOut.Value, txOut.Assets)...)
+		if bytes.Equal(txOut.PkScript, changePkScript) {
+			preview.Change += output.GetPlainSat()
+		}
+	}
+	// 剩下的白聪是网络费
+	preview.Fee = input.GetPlainSat()
+	preview.Warnings = append(preview.Warnings, burnedAssets(input.OutValue.Assets)...)
+	return preview, nil
+}
+
+// BatchSendAssetsV3 的预览
+func (p *Manager) PreviewBatchSendAssetsV3(dest []*SendAssetInfo,
+	assetNameStr string, feeRate int64, memo []byte, autoAdjust bool) (*TxPreview, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	if !IsValidNullData(memo) {
+		return nil, fmt.Errorf("invalid length of null data %d", len(memo))
+	}
+	name := ParseAssetString(assetNameStr)
+	if name != nil && name.Protocol == indexer.PROTOCOL_NAME_BRC20 {
+		// brc20 需要先构造并锁定 commit 交易
+		return nil, fmt.Errorf("preview of brc20 transfer is not supported")
+	}
+
+	tx, _, fee, _, err := p.buildBatchSendAssetsV3(dest, assetNameStr, feeRate, memo, autoAdjust, nil)
+	if err != nil {
+		return nil, err
+	}
+	enableRBF(tx)
+	return p.newTxPreview(tx, fee)
+}
+
+// 单个目标地址的预览，amt 按资产精度解析
+func (p *Manager) PreviewSendAssetsV3(destAddr string, assetName string, amt string,
+	feeRate int64, memo []byte) (*TxPreview, error) {
+
+	name := indexer.NewAssetNameFromString(assetName)
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", assetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
+	if err != nil {
+		return nil, err
+	}
+	dest := &SendAssetInfo{
+		Address:   destAddr,
+		AssetName: name,
+		AssetAmt:  dAmt,
+	}
+	if indexer.IsPlainAsset(name) {
+		dest.Value = dAmt.Int64()
+		dest.AssetAmt = nil
+	}
+	return p.PreviewBatchSendAssetsV3([]*SendAssetInfo{dest}, assetName, feeRate, memo, false)
+}
+
+// SendAssetsV3_SatsNet 的预览
+func (p *Manager) PreviewSendAssetsV3_SatsNet(destAddr string,
+	assetName string, amt string, value int64, memo []byte) (*TxPreview, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	tx, prevFetcher, err := p.buildSendAssetsV3Tx_SatsNet(destAddr, assetName, amt, value, memo, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.newTxPreview_SatsNet(tx, prevFetcher)
+}
+
+// LockToChannel 的预览，只构造锁定资产的交易，不和对端交换承诺交易
+func (p *Manager) PreviewLockToChannel(channelId string, assetName string, amt string,
+	utxos, fees []string, memo []byte) (*TxPreview, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	channel := p.GetChannel(channelId)
+	if channel == nil {
+		return nil, fmt.Errorf("can't find channel %s", channelId)
+	}
+	if !channel.IsInitiator {
+		return nil, fmt.Errorf("can't perform this action from remote peer")
+	}
+	channel.Mutex.Lock()
+	defer channel.Mutex.Unlock()
+	if channel.Status != CS_READY {
+		return nil, fmt.Errorf("channel %s is not ready", channelId)
+	}
+
+	resv, utxos, fees, err := p.newLockReservation(channel, assetName, amt, utxos, fees, memo)
+	if err != nil {
+		return nil, err
+	}
+	err = p.AllowLock(resv, utxos, fees, DEFAULT_FEE_SATSNET)
+	if err != nil {
+		return nil, fmt.Errorf("not allow lock, %v", err)
+	}
+	tx, prevFetcher, err := CreateLockTx(resv.OldChannel, resv.AssetName, resv.Amt,
+		resv.Utxos, resv.Fees, memo)
+	if err != nil {
+		return nil, err
+	}
+	return p.newTxPreview_SatsNet(tx, prevFetcher)
+}
+
+// InvokeContractV2 的预览
+func (p *Manager) PreviewInvokeContractV2(contractURL string, jsonInvokeParam string,
+	assetName string, amt string, feeRate int64) (*TxPreview, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	dest, nullDataScript, err := p.prepareInvokeContractV2(contractURL, jsonInvokeParam, assetName, amt)
+	if err != nil {
+		return nil, err
+	}
+	return p.PreviewBatchSendAssetsV3([]*SendAssetInfo{dest}, assetName, feeRate, nullDataScript, false)
+}
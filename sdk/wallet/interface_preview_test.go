@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+func TestPreviewWarnings(t *testing.T) {
+	ordx := swire.NewAssetNameFromString("ordx:f:pearl")
+	runes := swire.NewAssetNameFromString("runes:f:840000_1")
+	if ordx == nil || runes == nil {
+		t.Fatalf("invalid asset name")
+	}
+	assets := indexer.TxAssets{
+		{Name: *ordx, Amount: *indexer.NewDefaultDecimal(100), BindingSat: 1},
+		{Name: *runes, Amount: *indexer.NewDefaultDecimal(100)},
+	}
+
+	cases := []struct {
+		name     string
+		warnings []string
+		expected []string
+	}{
+		{"aligned", misalignedAssets(0, 100, assets), nil},
+		{"misaligned", misalignedAssets(1, 99, assets), []string{PREVIEW_WARNING_MISALIGNED + ": output 1"}},
+		{"burn", burnedAssets(assets), []string{PREVIEW_WARNING_BURN + ": 100 ordx:f:pearl"}},
+		{"burn nothing", burnedAssets(indexer.TxAssets{
+			{Name: *ordx, Amount: *indexer.NewDefaultDecimal(0), BindingSat: 1},
+		}), nil},
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	p2tr := append([]byte{txscript.OP_1, txscript.OP_DATA_32}, make([]byte, 32)...)
+	tx.AddTxOut(wire.NewTxOut(329, p2tr))
+	tx.AddTxOut(wire.NewTxOut(330, p2tr))
+	cases = append(cases, struct {
+		name     string
+		warnings []string
+		expected []string
+	}{"dust", dustOutputs(tx), []string{PREVIEW_WARNING_DUST + ": output 1"}})
+
+	for _, c := range cases {
+		if len(c.warnings) != len(c.expected) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.expected, c.warnings)
+		}
+		for i, prefix := range c.expected {
+			if !strings.HasPrefix(c.warnings[i], prefix) {
+				t.Fatalf("%s: expected %s, got %s", c.name, prefix, c.warnings[i])
+			}
+		}
+	}
+}
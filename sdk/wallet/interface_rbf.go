@@ -0,0 +1,389 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+/*
+L1交易的加速
+1. RBF (BIP125)：发送的交易设置 opt-in RBF 的 sequence，还没确认时可以用更高的费率重新构造。
+   保留原交易所有的输入和输出，只调整最后的白聪找零，不够时再增加白聪输入，
+   这样资产输出的聪的位置（ordx的绑定聪）和runestone的edicts都不会变化。
+2. CPFP：花费原交易中属于我们的白聪输出，用子交易为整个交易包支付足够的网络费
+*/
+
+const (
+	// BIP125 替换交易的最低增量费率，sat/vB
+	RBF_INCREMENTAL_FEERATE = 1
+)
+
+// 所有输入都设置 opt-in RBF，签名之前调用
+func enableRBF(tx *wire.MsgTx) {
+	for _, in := range tx.TxIn {
+		if in.Sequence > DefaultSequenceNum {
+			in.Sequence = DefaultSequenceNum
+		}
+	}
+}
+
+// BIP125：任何一个输入的 sequence 小于 0xfffffffe
+func signalsRBF(tx *wire.MsgTx) bool {
+	for _, in := range tx.TxIn {
+		if in.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// 费率向上取整，sat/vB
+func txFeeRate(fee, vsize int64) int64 {
+	if vsize <= 0 {
+		return 0
+	}
+	return (fee + vsize - 1) / vsize
+}
+
+// 按照输入和输出的类型估算交易大小，没有签名的交易也可以估算
+func estimateTxWeight(tx *wire.MsgTx, inputs []*TxOutput) *utils.TxWeightEstimator {
+	var weightEstimate utils.TxWeightEstimator
+	for _, input := range inputs {
+		switch txscript.GetScriptClass(input.OutValue.PkScript) {
+		case txscript.PubKeyHashTy:
+			weightEstimate.AddP2PKHInput()
+		case txscript.WitnessV0PubKeyHashTy:
+			weightEstimate.AddP2WKHInput()
+		case txscript.ScriptHashTy:
+			weightEstimate.AddNestedP2WKHInput()
+		default:
+			weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		}
+	}
+	for _, txOut := range tx.TxOut {
+		weightEstimate.AddTxOutput(txOut)
+	}
+	return &weightEstimate
+}
+
+// 本钱包的地址和找零地址
+func (p *Manager) walletPkScripts() [][]byte {
+	address := p.wallet.GetAddress()
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		return nil
+	}
+	result := [][]byte{pkScript}
+	// 包括所有用过的找零地址
+	for _, changeAddr := range p.walletChangeAddresses() {
+		changePkScript, err := GetPkScriptFromAddress(changeAddr)
+		if err != nil || bytes.Equal(changePkScript, pkScript) {
+			continue
+		}
+		result = append(result, changePkScript)
+	}
+	return result
+}
+
+// 找到可以调整的白聪找零：属于本钱包，没有资产，并且后面的输出都没有聪（OP_RETURN），
+// 调整它的聪数量不会影响其他输出的聪的位置。-1 表示没有
+func findFeeChangeOutput(tx *wire.MsgTx, outputs []*TxOutput, pkScripts [][]byte) int {
+	for i := len(tx.TxOut) - 1; i >= 0; i-- {
+		txOut := tx.TxOut[i]
+		if txOut.Value == 0 {
+			continue
+		}
+		if len(outputs[i].Assets) != 0 {
+			return -1
+		}
+		for _, pkScript := range pkScripts {
+			if bytes.Equal(txOut.PkScript, pkScript) {
+				return i
+			}
+		}
+		return -1
+	}
+	return -1
+}
+
+func prevOutputMap(tx *wire.MsgTx, inputs []*TxOutput) map[string]*TxOutput {
+	result := make(map[string]*TxOutput, len(inputs))
+	for i, input := range inputs {
+		result[tx.TxIn[i].PreviousOutPoint.String()] = input.Clone()
+	}
+	return result
+}
+
+// 资产的数量和聪的位置都一致
+func sameOutputAssets(a, b *TxOutput) bool {
+	if len(a.Assets) != len(b.Assets) {
+		return false
+	}
+	if len(a.Assets) == 0 {
+		return true
+	}
+	return a.Assets.Equal(b.Assets) && reflect.DeepEqual(a.Offsets, b.Offsets)
+}
+
+// 加载还没有确认的交易，以及它的输入和重建资产后的输出
+func (p *Manager) loadUnconfirmedTx(txId string) (*wire.MsgTx, []*TxOutput, []*TxOutput, int64, error) {
+	if p.l1IndexerClient.IsTxConfirmed(txId) {
+		return nil, nil, nil, 0, fmt.Errorf("tx %s has been confirmed", txId)
+	}
+	txHex, err := p.l1IndexerClient.GetRawTx(txId)
+	if err != nil {
+		Log.Errorf("GetRawTx %s failed. %v", txId, err)
+		return nil, nil, nil, 0, err
+	}
+	tx, err := DecodeMsgTx(txHex)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	inputs, outputs, err := p.RebuildTxOutput(tx, nil)
+	if err != nil {
+		Log.Errorf("RebuildTxOutput %s failed. %v", txId, err)
+		return nil, nil, nil, 0, err
+	}
+	if len(inputs) != len(tx.TxIn) {
+		return nil, nil, nil, 0, fmt.Errorf("tx %s has invalid inputs", txId)
+	}
+	var fee int64
+	for _, input := range inputs {
+		fee += input.OutValue.Value
+	}
+	for _, txOut := range tx.TxOut {
+		fee -= txOut.Value
+	}
+	if fee < 0 {
+		return nil, nil, nil, 0, fmt.Errorf("tx %s has invalid fee %d", txId, fee)
+	}
+	return tx, inputs, outputs, fee, nil
+}
+
+// 用新的费率替换还没有确认的交易，返回新的交易id和网络费
+func (p *Manager) BumpFee(txId string, newFeeRate int64) (string, int64, error) {
+	if p.wallet == nil {
+		return "", 0, fmt.Errorf("wallet is not created/unlocked")
+	}
+
+	newFeeRate, err := p.ResolveFeeRate(newFeeRate)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tx, inputs, outputs, oldFee, err := p.loadUnconfirmedTx(txId)
+	if err != nil {
+		return "", 0, err
+	}
+	if !signalsRBF(tx) {
+		return "", 0, fmt.Errorf("tx %s does not signal RBF", txId)
+	}
+	oldFeeRate := txFeeRate(oldFee, GetTxVirtualSize2(tx))
+	if newFeeRate < oldFeeRate+RBF_INCREMENTAL_FEERATE {
+		return "", 0, fmt.Errorf("new fee rate should be at least %d", oldFeeRate+RBF_INCREMENTAL_FEERATE)
+	}
+
+	newTx := tx.Copy()
+	for _, in := range newTx.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+	}
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, input := range inputs {
+		prevFetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, &input.OutValue)
+	}
+
+	// 原交易的输入和输出都不能再用来支付网络费
+	excluded := make(map[string]bool)
+	for _, in := range tx.TxIn {
+		excluded[in.PreviousOutPoint.String()] = true
+	}
+	for i := range tx.TxOut {
+		excluded[fmt.Sprintf("%s:%d", txId, i)] = true
+	}
+
+	address := p.wallet.GetAddress()
+	changePkScript := p.getChangePkScript(address, false, nil)
+	if changePkScript == nil {
+		changePkScript, err = GetPkScriptFromAddress(address)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	changeIndex := findFeeChangeOutput(tx, outputs, p.walletPkScripts())
+	weightEstimate := estimateTxWeight(newTx, inputs)
+
+	// 找零输出至少保留330聪，不删除输出，避免影响runestone中的输出序号
+	var keep, feeValue int64
+	feeValue = oldFee
+	if changeIndex >= 0 {
+		keep = DefaultMinChangeValue
+		feeValue += newTx.TxOut[changeIndex].Value - keep
+	}
+	var selected []*TxOutput
+	if feeValue < weightEstimate.Fee(newFeeRate) {
+		selected, feeValue, err = p.SelectUtxosForFeeV3(NewUtxoMgr(address, p.l1IndexerClient),
+			excluded, feeValue, newFeeRate, weightEstimate, false, false)
+		if err != nil {
+			Log.Errorf("SelectUtxosForFeeV3 failed. %v", err)
+			return "", 0, err
+		}
+		for _, output := range selected {
+			txIn := output.TxIn()
+			txIn.Sequence = DefaultSequenceNum
+			newTx.AddTxIn(txIn)
+			prevFetcher.AddPrevOut(*output.OutPoint(), &output.OutValue)
+		}
+	}
+
+	fee := weightEstimate.Fee(newFeeRate)
+	if changeIndex >= 0 {
+		newTx.TxOut[changeIndex].Value = feeValue - fee + keep
+	} else {
+		weightEstimate.AddP2TROutput()
+		fee1 := weightEstimate.Fee(newFeeRate)
+		if feeValue-fee1 >= DefaultMinChangeValue {
+			newTx.AddTxOut(&wire.TxOut{PkScript: changePkScript, Value: feeValue - fee1})
+			fee = fee1
+		} else {
+			fee = feeValue
+		}
+	}
+	if fee < oldFee+weightEstimate.VSize()*RBF_INCREMENTAL_FEERATE {
+		return "", 0, fmt.Errorf("replacement fee %d is too low", fee)
+	}
+
+	// 确认资产输出没有变化
+	prevMap := prevOutputMap(tx, inputs)
+	for _, output := range selected {
+		prevMap[output.OutPointStr] = output.Clone()
+	}
+	_, newOutputs, err := p.RebuildTxOutput(newTx, prevMap)
+	if err != nil {
+		Log.Errorf("RebuildTxOutput failed. %v", err)
+		return "", 0, err
+	}
+	for i := range tx.TxOut {
+		if i == changeIndex {
+			continue
+		}
+		if newTx.TxOut[i].Value != tx.TxOut[i].Value || !sameOutputAssets(outputs[i], newOutputs[i]) {
+			return "", 0, fmt.Errorf("output %d changed in replacement tx", i)
+		}
+	}
+
+	newTx, err = p.SignTx(newTx, prevFetcher)
+	if err != nil {
+		Log.Errorf("SignTx failed. %v", err)
+		return "", 0, err
+	}
+	_, err = p.BroadcastTx(newTx)
+	if err != nil {
+		Log.Errorf("BroadcastTx failed. %v", err)
+		return "", 0, err
+	}
+	// 原交易的输出锁定转移到新的交易
+	err = p.utxoLockerL1.ReplaceTx(tx, newTx)
+	if err != nil {
+		Log.Errorf("ReplaceTx %s failed. %v", txId, err)
+	}
+
+	Log.Infof("BumpFee %s -> %s, fee %d -> %d", txId, newTx.TxID(), oldFee, fee)
+	return newTx.TxID(), fee, nil
+}
+
+// 花费原交易中属于本钱包的白聪输出，使整个交易包的费率达到feeRate，返回子交易id和子交易的网络费
+func (p *Manager) CPFP(txId string, feeRate int64) (string, int64, error) {
+	if p.wallet == nil {
+		return "", 0, fmt.Errorf("wallet is not created/unlocked")
+	}
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tx, _, outputs, parentFee, err := p.loadUnconfirmedTx(txId)
+	if err != nil {
+		return "", 0, err
+	}
+	parentVSize := GetTxVirtualSize2(tx)
+	parentDeficit := feeRate*parentVSize - parentFee
+	if parentDeficit <= 0 {
+		return "", 0, fmt.Errorf("fee rate of tx %s is already %d", txId, txFeeRate(parentFee, parentVSize))
+	}
+
+	// 选择最大的白聪输出
+	pkScripts := p.walletPkScripts()
+	var input *TxOutput
+	for i, txOut := range tx.TxOut {
+		if len(outputs[i].Assets) != 0 {
+			continue
+		}
+		for _, pkScript := range pkScripts {
+			if bytes.Equal(txOut.PkScript, pkScript) {
+				if input == nil || txOut.Value > input.OutValue.Value {
+					input = outputs[i]
+				}
+				break
+			}
+		}
+	}
+	if input == nil {
+		return "", 0, fmt.Errorf("tx %s has no plain sats output of this wallet", txId)
+	}
+	if p.utxoLockerL1.IsLocked(input.OutPointStr) {
+		return "", 0, fmt.Errorf("output %s is locked", input.OutPointStr)
+	}
+
+	address := p.wallet.GetAddress()
+	changePkScript := p.getChangePkScript(address, false, input.OutValue.PkScript)
+	childTx := wire.NewMsgTx(wire.TxVersion)
+	txIn := input.TxIn()
+	txIn.Sequence = DefaultSequenceNum
+	childTx.AddTxIn(txIn)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	prevFetcher.AddPrevOut(*input.OutPoint(), &input.OutValue)
+	childTx.AddTxOut(&wire.TxOut{PkScript: changePkScript, Value: 0})
+	weightEstimate := estimateTxWeight(childTx, []*TxOutput{input})
+
+	// 子交易同时支付父交易不足的网络费，保留330聪的找零
+	feeValue := input.OutValue.Value - parentDeficit - DefaultMinChangeValue
+	if feeValue < weightEstimate.Fee(feeRate) {
+		excluded := map[string]bool{input.OutPointStr: true}
+		var selected []*TxOutput
+		selected, feeValue, err = p.SelectUtxosForFeeV3(NewUtxoMgr(address, p.l1IndexerClient),
+			excluded, feeValue, feeRate, weightEstimate, false, false)
+		if err != nil {
+			Log.Errorf("SelectUtxosForFeeV3 failed. %v", err)
+			return "", 0, err
+		}
+		for _, output := range selected {
+			txIn := output.TxIn()
+			txIn.Sequence = DefaultSequenceNum
+			childTx.AddTxIn(txIn)
+			prevFetcher.AddPrevOut(*output.OutPoint(), &output.OutValue)
+		}
+	}
+	childFee := weightEstimate.Fee(feeRate)
+	childTx.TxOut[0].Value = feeValue - childFee + DefaultMinChangeValue
+	childFee += parentDeficit
+
+	childTx, err = p.SignTx(childTx, prevFetcher)
+	if err != nil {
+		Log.Errorf("SignTx failed. %v", err)
+		return "", 0, err
+	}
+	_, err = p.BroadcastTx(childTx)
+	if err != nil {
+		Log.Errorf("BroadcastTx failed. %v", err)
+		return "", 0, err
+	}
+
+	Log.Infof("CPFP %s with %s, fee %d", txId, childTx.TxID(), childFee)
+	return childTx.TxID(), childFee, nil
+}
@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+)
+
+func TestEnableRBF(t *testing.T) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil, nil))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{2}}, nil, nil))
+	if signalsRBF(tx) {
+		t.Fatal("final sequence should not signal RBF")
+	}
+	tx.TxIn[1].Sequence = 10
+	enableRBF(tx)
+	if !signalsRBF(tx) || tx.TxIn[0].Sequence != DefaultSequenceNum || tx.TxIn[1].Sequence != 10 {
+		t.Fatalf("unexpected sequence %x %x", tx.TxIn[0].Sequence, tx.TxIn[1].Sequence)
+	}
+	if txFeeRate(1001, 100) != 11 {
+		t.Fatal("fee rate should round up")
+	}
+}
+
+func TestFindFeeChangeOutput(t *testing.T) {
+	ourPkScript := []byte{0x51, 0x20, 0x01}
+	otherPkScript := []byte{0x51, 0x20, 0x02}
+	asset := indexer.NewAssetNameFromString("ordx:f:test")
+	if asset == nil {
+		t.Fatal("invalid asset name")
+	}
+
+	build := func(outs ...*wire.TxOut) (*wire.MsgTx, []*TxOutput) {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		outputs := make([]*TxOutput, 0, len(outs))
+		for _, out := range outs {
+			tx.AddTxOut(out)
+			outputs = append(outputs, &TxOutput{OutValue: *out})
+		}
+		return tx, outputs
+	}
+	pkScripts := [][]byte{ourPkScript}
+
+	// 资产输出，白聪找零，OP_RETURN
+	tx, outputs := build(wire.NewTxOut(330, otherPkScript), wire.NewTxOut(5000, ourPkScript),
+		wire.NewTxOut(0, []byte{0x6a}))
+	outputs[0].Assets = indexer.TxAssets{{Name: *asset, Amount: *indexer.NewDefaultDecimal(330), BindingSat: 1}}
+	if index := findFeeChangeOutput(tx, outputs, pkScripts); index != 1 {
+		t.Fatalf("change output %d, expected 1", index)
+	}
+
+	// 最后的输出带有资产，不能调整
+	tx, outputs = build(wire.NewTxOut(5000, ourPkScript), wire.NewTxOut(330, ourPkScript))
+	outputs[1].Assets = indexer.TxAssets{{Name: *asset, Amount: *indexer.NewDefaultDecimal(330), BindingSat: 1}}
+	if index := findFeeChangeOutput(tx, outputs, pkScripts); index != -1 {
+		t.Fatalf("asset output %d should not be used as change", index)
+	}
+
+	// 最后的输出不属于本钱包
+	tx, outputs = build(wire.NewTxOut(5000, ourPkScript), wire.NewTxOut(1000, otherPkScript))
+	if index := findFeeChangeOutput(tx, outputs, pkScripts); index != -1 {
+		t.Fatalf("output %d should not be used as change", index)
+	}
+}
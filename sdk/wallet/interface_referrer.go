@@ -104,8 +104,9 @@ func (p *Manager) RegisterAsReferrer(name string, feeRate int64) (string, error)
 
 	// 暂时没有开放注册，必须由服务端提供白名单注册
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", err
 	}
 
 	// 必须是该名字的持有人才能注册
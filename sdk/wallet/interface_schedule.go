@@ -0,0 +1,606 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	indexer "github.com/sat20-labs/indexer/common"
+	indexerwire "github.com/sat20-labs/indexer/rpcserver/wire"
+)
+
+/*
+定时发送
+1. 在指定高度发送一次，或者从指定高度开始每隔 Interval 个区块发送一次，最多 Count 次（0 表示不限）
+2. 创建时和每次发送后，为下一次发送预留utxo（UtxoLocker.TryReserve），其他交易不会花费这些utxo
+3. 到达高度后由监控线程释放预留的utxo，只用预留的utxo构造交易，跟 BatchSendAssetsV3 或者 SendAssetsV3_SatsNet 一样发送
+4. 广播前先保存交易id，重启后根据交易id判断是否已经发送，不会重复支付
+5. 每次发送成功或者失败都通过 ActionStatusEvent 通知
+L1 按一层的高度，聪网按聪网的高度
+*/
+
+const (
+	RS_SCHEDULE_STARTED   ResvStatus = 0x2700
+	RS_SCHEDULE_COMPLETED ResvStatus = RS_CONFIRMED
+)
+
+const (
+	ACTION_SCHEDULED_SEND = "scheduled send"
+
+	SCHEDULE_LOCK_REASON       = "schedule"
+	SCHEDULE_RETRY_INTERVAL    = 60  // 秒，失败后监控线程重试的间隔
+	SCHEDULE_FEE_RESERVE_VSIZE = 300 // 按这个大小预留 L1 网络费
+)
+
+type ScheduledRun struct {
+	Height int    `json:"height"` // 发送时的高度
+	Time   int64  `json:"time"`
+	TxId   string `json:"txId"` // 广播前保存
+	Fee    int64  `json:"fee"`
+}
+
+type ScheduledSendReservation struct {
+	ReservationBase
+	Address     string
+	AssetName   string
+	Amt         string // 资产数量，白聪时是聪数量
+	Value       int64  // 资产之外额外发送的聪
+	SatsNet     bool
+	FeeRate     int64
+	Memo        []byte
+	StartHeight int
+	Interval    int // 区块数，0 表示只发送一次
+	Count       int // 最多发送的次数，0 表示不限
+	NextHeight  int
+	Runs        []*ScheduledRun
+	Sending     *ScheduledRun // 已经开始发送，还不知道结果
+	Reserved    []string      // 为下一次发送预留的utxo
+	CreateTime  int64
+	LastError   string
+
+	running bool
+	lastRun int64
+}
+
+func (p *ScheduledSendReservation) GetType() string {
+	return RESV_TYPE_SCHEDULE
+}
+
+func (p *ScheduledSendReservation) GetStructInDB() any {
+	return p
+}
+
+func (p *ScheduledSendReservation) reservationID() string {
+	return fmt.Sprintf("%s-%d", RESV_TYPE_SCHEDULE, p.Id)
+}
+
+func (p *ScheduledSendReservation) tryStart() bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.running {
+		return false
+	}
+	p.running = true
+	p.lastRun = time.Now().Unix()
+	return true
+}
+
+func (p *ScheduledSendReservation) stop(err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.running = false
+	if err != nil {
+		p.LastError = err.Error()
+	} else {
+		p.LastError = ""
+	}
+}
+
+// 已经发送 runs 次，返回下一次发送的高度，0 表示已经完成。错过的发送不再补发
+func nextScheduleHeight(next, interval, count, runs, height int) int {
+	if interval <= 0 || (count > 0 && runs >= count) {
+		return 0
+	}
+	for next <= height {
+		next += interval
+	}
+	return next
+}
+
+type ScheduledSendInfo struct {
+	Id          int64           `json:"id"`
+	Address     string          `json:"address"`
+	AssetName   string          `json:"assetName"`
+	Amt         string          `json:"amt"`
+	Value       int64           `json:"value"`
+	SatsNet     bool            `json:"satsNet"`
+	StartHeight int             `json:"startHeight"`
+	Interval    int             `json:"interval"`
+	Count       int             `json:"count"`
+	NextHeight  int             `json:"nextHeight"`
+	Status      string          `json:"status"` // running, completed, cancelled
+	Runs        []*ScheduledRun `json:"runs"`
+	Reserved    []string        `json:"reserved"`
+	LastError   string          `json:"lastError"`
+}
+
+func (p *ScheduledSendReservation) Info() *ScheduledSendInfo {
+	p.RLock()
+	defer p.RUnlock()
+	info := &ScheduledSendInfo{
+		Id:          p.Id,
+		Address:     p.Address,
+		AssetName:   p.AssetName,
+		Amt:         p.Amt,
+		Value:       p.Value,
+		SatsNet:     p.SatsNet,
+		StartHeight: p.StartHeight,
+		Interval:    p.Interval,
+		Count:       p.Count,
+		NextHeight:  p.NextHeight,
+		Runs:        make([]*ScheduledRun, 0, len(p.Runs)),
+		Reserved:    append([]string(nil), p.Reserved...),
+		LastError:   p.LastError,
+	}
+	for _, run := range p.Runs {
+		r := *run
+		info.Runs = append(info.Runs, &r)
+	}
+	switch p.Status {
+	case RS_SCHEDULE_STARTED:
+		info.Status = "running"
+	case RS_SCHEDULE_COMPLETED:
+		info.Status = "completed"
+	default:
+		info.Status = "cancelled"
+	}
+	return info
+}
+
+// 创建定时发送并预留utxo。interval 为0时只在 startHeight 发送一次
+func (p *Manager) NewScheduledSend(address, assetName, amt string, value int64, satsNet bool,
+	feeRate int64, memo []byte, startHeight, interval, count int) (*ScheduledSendReservation, error) {
+
+	if p.wallet == nil {
+		return nil, fmt.Errorf("wallet is not created/unlocked")
+	}
+	if startHeight <= 0 || interval < 0 || count < 0 {
+		return nil, fmt.Errorf("invalid schedule, height %d, interval %d, count %d", startHeight, interval, count)
+	}
+	if interval == 0 {
+		count = 1
+	}
+	if value < 0 {
+		return nil, fmt.Errorf("invalid value %d", value)
+	}
+	var err error
+	if satsNet {
+		_, err = GetPkScriptFromAddress_SatsNet(address)
+	} else {
+		_, err = GetPkScriptFromAddress(address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s, %v", address, err)
+	}
+	if satsNet {
+		if !IsValidNullData_SatsNet(memo) {
+			return nil, fmt.Errorf("invalid length of null data %d", len(memo))
+		}
+	} else if !IsValidNullData(memo) {
+		return nil, fmt.Errorf("invalid length of null data %d", len(memo))
+	}
+
+	name := ParseAssetString(assetName)
+	if name == nil {
+		return nil, fmt.Errorf("invalid asset name %s", assetName)
+	}
+	if !satsNet && name.Protocol == indexer.PROTOCOL_NAME_RUNES && len(memo) != 0 {
+		return nil, fmt.Errorf("do not attach memo when send runes asset")
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", assetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amt %s, %v", amt, err)
+	}
+	if dAmt.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amt %s", amt)
+	}
+	if !satsNet {
+		if indexer.IsPlainAsset(name) {
+			if dAmt.Int64()+value < DUST_LIMIT {
+				return nil, fmt.Errorf("value should be larger than %d", DUST_LIMIT)
+			}
+		} else if value != 0 && value < DUST_LIMIT {
+			return nil, fmt.Errorf("value should be larger than %d", DUST_LIMIT)
+		}
+	}
+
+	resv := &ScheduledSendReservation{
+		ReservationBase: NewReservationBase(p.GenerateNewResvId(), true, RS_SCHEDULE_STARTED, p.wallet),
+		Address:         address,
+		AssetName:       name.String(),
+		Amt:             dAmt.String(),
+		Value:           value,
+		SatsNet:         satsNet,
+		FeeRate:         feeRate,
+		Memo:            memo,
+		StartHeight:     startHeight,
+		Interval:        interval,
+		Count:           count,
+		NextHeight:      startHeight,
+		CreateTime:      time.Now().Unix(),
+	}
+	err = p.reserveScheduledUtxos(resv)
+	if err != nil {
+		return nil, err
+	}
+	p.addResv(resv)
+	err = p.SaveWalletReservation(resv)
+	if err != nil {
+		p.releaseScheduledUtxos(resv)
+		p.DelResvWithId(resv.Id)
+		return nil, err
+	}
+	Log.Infof("NewScheduledSend %d: %s %s to %s at %d, interval %d, count %d", resv.Id,
+		resv.Amt, resv.AssetName, address, startHeight, interval, count)
+	return resv, nil
+}
+
+func (p *Manager) GetScheduledSend(id int64) *ScheduledSendReservation {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.scheduleMap[id]
+}
+
+func (p *Manager) GetScheduledSends() []*ScheduledSendReservation {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result := make([]*ScheduledSendReservation, 0, len(p.scheduleMap))
+	for _, resv := range p.scheduleMap {
+		result = append(result, resv)
+	}
+	return result
+}
+
+func (p *Manager) saveScheduledSend(resv *ScheduledSendReservation) error {
+	resv.RLock()
+	defer resv.RUnlock()
+	return p.SaveWalletReservation(resv)
+}
+
+// 取消后释放预留的utxo，已经发送的交易不受影响
+func (p *Manager) CancelScheduledSend(id int64) error {
+	resv := p.GetScheduledSend(id)
+	if resv == nil {
+		return fmt.Errorf("can't find scheduled send %d", id)
+	}
+	resv.Lock()
+	if resv.Status != RS_SCHEDULE_STARTED {
+		resv.Unlock()
+		return fmt.Errorf("scheduled send %d is not running, status 0x%x", id, resv.Status)
+	}
+	if resv.running {
+		resv.Unlock()
+		return fmt.Errorf("scheduled send %d is sending", id)
+	}
+	resv.Status = RS_CLOSED
+	resv.Unlock()
+	p.releaseScheduledUtxos(resv)
+	return p.saveScheduledSend(resv)
+}
+
+func (p *Manager) scheduleLocker(satsNet bool) *UtxoLocker {
+	if satsNet {
+		return p.utxoLockerL2
+	}
+	return p.utxoLockerL1
+}
+
+// 选择下一次发送需要的utxo，L1 按 SCHEDULE_FEE_RESERVE_VSIZE 预留网络费
+func (p *Manager) scheduledUtxos(resv *ScheduledSendReservation) ([]string, error) {
+	name := ParseAssetString(resv.AssetName)
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, fmt.Errorf("can't get ticker %s info", resv.AssetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(resv.Amt, tickerInfo.Divisibility)
+	if err != nil {
+		return nil, err
+	}
+
+	if resv.SatsNet {
+		assets, plains, err := p.GetUtxosWithAssetV2_SatsNet("", resv.Value+DEFAULT_FEE_SATSNET, dAmt, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		return append(assets, plains...), nil
+	}
+
+	feeRate := resv.FeeRate
+	if feeRate <= 0 {
+		feeRate = p.GetFeeRate()
+	}
+	fee := feeRate * SCHEDULE_FEE_RESERVE_VSIZE
+	if indexer.IsPlainAsset(name) {
+		return p.GetUtxosForFee("", dAmt.Int64()+resv.Value+fee, nil, false)
+	}
+	assets, plains, err := p.GetUtxosWithAssetV2("", resv.Value+fee, dAmt, name, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(assets, plains...), nil
+}
+
+func (p *Manager) reserveScheduledUtxos(resv *ScheduledSendReservation) error {
+	utxos, err := p.scheduledUtxos(resv)
+	if err != nil {
+		Log.Errorf("scheduled send %d: select utxos failed. %v", resv.Id, err)
+		return err
+	}
+	err = p.scheduleLocker(resv.SatsNet).TryReserve(utxos, SCHEDULE_LOCK_REASON, resv.reservationID())
+	if err != nil {
+		Log.Errorf("scheduled send %d: reserve utxos failed. %v", resv.Id, err)
+		return err
+	}
+	resv.Lock()
+	resv.Reserved = normalizedReservationUtxos(utxos)
+	resv.Unlock()
+	return nil
+}
+
+// 发送失败后重新预留原来的utxo，已经不能预留的重新选择
+func (p *Manager) restoreScheduledUtxos(resv *ScheduledSendReservation, utxos []string) {
+	if len(utxos) != 0 {
+		err := p.scheduleLocker(resv.SatsNet).TryReserve(utxos, SCHEDULE_LOCK_REASON, resv.reservationID())
+		if err == nil {
+			resv.Lock()
+			resv.Reserved = normalizedReservationUtxos(utxos)
+			resv.Unlock()
+			return
+		}
+		Log.Warnf("scheduled send %d: reserve utxos again failed. %v", resv.Id, err)
+	}
+	if err := p.reserveScheduledUtxos(resv); err != nil {
+		Log.Warnf("scheduled send %d: reserve utxos failed. %v", resv.Id, err)
+	}
+}
+
+// 除了预留的utxo，地址上其他的utxo都不能使用
+func (p *Manager) scheduledExcludedUtxos(resv *ScheduledSendReservation) (map[string]bool, error) {
+	resv.RLock()
+	reserved := make(map[string]bool, len(resv.Reserved))
+	for _, utxo := range resv.Reserved {
+		reserved[utxo] = true
+	}
+	resv.RUnlock()
+	if len(reserved) == 0 {
+		return nil, fmt.Errorf("no reserved utxos")
+	}
+
+	address := p.wallet.GetAddress()
+	var utxos []*indexerwire.TxOutputInfo
+	if resv.SatsNet {
+		utxos = p.l2IndexerClient.GetAllUtxosWithAddress(address)
+	} else {
+		utxos = p.l1IndexerClient.GetAllUtxosWithAddress(address)
+		utxos = append(utxos, p.getChangeAddressPlainUtxos(address, false)...)
+	}
+	if len(utxos) == 0 {
+		return nil, fmt.Errorf("can't get utxos of %s", address)
+	}
+	excluded := make(map[string]bool)
+	for _, u := range utxos {
+		if !reserved[u.OutPoint] {
+			excluded[u.OutPoint] = true
+		}
+	}
+	return excluded, nil
+}
+
+func (p *Manager) releaseScheduledUtxos(resv *ScheduledSendReservation) {
+	resv.RLock()
+	reserved := resv.Reserved
+	resv.RUnlock()
+	if len(reserved) == 0 {
+		return
+	}
+	err := p.scheduleLocker(resv.SatsNet).ReleaseReservation(reserved, resv.reservationID())
+	if err != nil {
+		Log.Warnf("scheduled send %d: release utxos failed. %v", resv.Id, err)
+	}
+	resv.Lock()
+	resv.Reserved = nil
+	resv.Unlock()
+}
+
+// 重启前已经开始发送：广播前保存的交易在本地交易记录或者索引器中，认为已经发送
+func (p *Manager) findScheduledTx(resv *ScheduledSendReservation, run *ScheduledRun) bool {
+	if run.TxId == "" {
+		return false
+	}
+	if p.GetActivityItem(run.TxId, resv.SatsNet) != nil {
+		return true
+	}
+	client := p.l1IndexerClient
+	if resv.SatsNet {
+		client = p.l2IndexerClient
+	}
+	if client == nil {
+		return false
+	}
+	_, err := client.GetRawTx(run.TxId)
+	return err == nil
+}
+
+func (p *Manager) sendScheduled(resv *ScheduledSendReservation, excluded map[string]bool,
+	beforeBroadcast func(txId string, fee int64) error) (string, int64, error) {
+	if resv.SatsNet {
+		txId, err := p.sendAssetsV3_SatsNet(resv.Address, resv.AssetName, resv.Amt, resv.Value, resv.Memo,
+			excluded, beforeBroadcast)
+		return txId, DEFAULT_FEE_SATSNET, err
+	}
+
+	name := ParseAssetString(resv.AssetName)
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return "", 0, fmt.Errorf("can't get ticker %s info", resv.AssetName)
+	}
+	dAmt, err := indexer.NewDecimalFromString(resv.Amt, tickerInfo.Divisibility)
+	if err != nil {
+		return "", 0, err
+	}
+	dest := &SendAssetInfo{
+		Address:   resv.Address,
+		Value:     resv.Value,
+		AssetName: name,
+		AssetAmt:  dAmt,
+	}
+	if indexer.IsPlainAsset(name) {
+		dest.Value += dAmt.Int64()
+		dest.AssetAmt = nil
+	}
+	return p.batchSendAssetsV3([]*SendAssetInfo{dest}, resv.AssetName, resv.FeeRate, resv.Memo,
+		SCHEDULE_LOCK_REASON, false, excluded, beforeBroadcast)
+}
+
+// 记录发送结果，计算下一次发送的高度并预留utxo
+func (p *Manager) finishScheduledRun(resv *ScheduledSendReservation, run *ScheduledRun, height int) {
+	resv.Lock()
+	resv.Runs = append(resv.Runs, run)
+	resv.Sending = nil
+	resv.NextHeight = nextScheduleHeight(resv.NextHeight, resv.Interval, resv.Count, len(resv.Runs), height)
+	if resv.NextHeight == 0 {
+		resv.Status = RS_SCHEDULE_COMPLETED
+	}
+	resv.Unlock()
+	p.annotateActivity(resv.SatsNet, run.TxId, "", ACTION_SCHEDULED_SEND, "")
+	Log.Infof("scheduled send %d: sent %s at %d, next %d", resv.Id, run.TxId, run.Height, resv.NextHeight)
+
+	if resv.NextHeight != 0 {
+		if err := p.reserveScheduledUtxos(resv); err != nil {
+			// 下次发送前再选择utxo
+			resv.Lock()
+			resv.LastError = err.Error()
+			resv.Unlock()
+		}
+	}
+}
+
+func (p *Manager) runScheduledSend(resv *ScheduledSendReservation, height int) error {
+	resv.RLock()
+	sending := resv.Sending
+	resv.RUnlock()
+	if sending != nil {
+		if p.findScheduledTx(resv, sending) {
+			p.releaseScheduledUtxos(resv)
+			p.finishScheduledRun(resv, sending, height)
+			return p.saveScheduledSend(resv)
+		}
+		Log.Infof("scheduled send %d: tx %s sent at %d not found, retry", resv.Id, sending.TxId, sending.Height)
+	}
+
+	// 上次没有预留成功的，现在预留
+	resv.RLock()
+	reserved := resv.Reserved
+	resv.RUnlock()
+	if len(reserved) == 0 {
+		err := p.reserveScheduledUtxos(resv)
+		if err != nil {
+			return err
+		}
+		resv.RLock()
+		reserved = resv.Reserved
+		resv.RUnlock()
+	}
+	excluded, err := p.scheduledExcludedUtxos(resv)
+	if err != nil {
+		return err
+	}
+
+	run := &ScheduledRun{Height: height, Time: time.Now().Unix()}
+	resv.Lock()
+	resv.Sending = run
+	resv.Unlock()
+	err = p.saveScheduledSend(resv)
+	if err != nil {
+		return err
+	}
+
+	// 广播前保存交易id，广播后即使没有保存结果，重启后也能找到交易
+	beforeBroadcast := func(txId string, fee int64) error {
+		resv.Lock()
+		run.TxId = txId
+		run.Fee = fee
+		resv.Unlock()
+		return p.saveScheduledSend(resv)
+	}
+	p.releaseScheduledUtxos(resv)
+	txId, fee, err := p.sendScheduled(resv, excluded, beforeBroadcast)
+	if err != nil {
+		resv.Lock()
+		// 已经有交易id的，广播的结果不确定，下次先检查交易是否已经发送
+		if run.TxId == "" {
+			resv.Sending = nil
+		}
+		resv.Unlock()
+		p.restoreScheduledUtxos(resv, reserved)
+		if saveErr := p.saveScheduledSend(resv); saveErr != nil {
+			Log.Errorf("saveScheduledSend %d failed. %v", resv.Id, saveErr)
+		}
+		return err
+	}
+
+	run.TxId = txId
+	run.Fee = fee
+	p.finishScheduledRun(resv, run, height)
+	return p.saveScheduledSend(resv)
+}
+
+// 到达高度后发送，失败的每 SCHEDULE_RETRY_INTERVAL 秒重试一次
+func (p *Manager) handleScheduleMonitorTick(sendTxInL1 bool) {
+	if p == nil || p.wallet == nil {
+		return
+	}
+	client := p.l2IndexerClient
+	if sendTxInL1 {
+		client = p.l1IndexerClient
+	}
+	if client == nil {
+		return
+	}
+	height := client.GetSyncHeight()
+	now := time.Now().Unix()
+	walletId := p.wallet.GetWalletId()
+	for _, resv := range p.GetScheduledSends() {
+		resv.RLock()
+		ready := resv.Status == RS_SCHEDULE_STARTED && resv.SatsNet != sendTxInL1 &&
+			resv.WalletId == walletId && !resv.running && height >= resv.NextHeight &&
+			now-resv.lastRun >= SCHEDULE_RETRY_INTERVAL
+		resv.RUnlock()
+		if !ready || !resv.tryStart() {
+			continue
+		}
+
+		err := p.runScheduledSend(resv, height)
+		resv.stop(err)
+		if saveErr := p.saveScheduledSend(resv); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		event := &ActionStatusEvent{
+			Event:      ACTION_STATUS_EVENT_COMPLETED,
+			Resv:       resv,
+			ResvType:   RESV_TYPE_SCHEDULE,
+			Action:     ACTION_SCHEDULED_SEND,
+			Status:     resv.GetStatus(),
+			SendTxInL1: sendTxInL1,
+		}
+		if err != nil {
+			Log.Errorf("scheduled send %d failed. %v", resv.Id, err)
+			event.Event = ACTION_STATUS_EVENT_FAILED
+			event.Err = err
+		}
+		p.notifyActionStatus(event)
+	}
+}
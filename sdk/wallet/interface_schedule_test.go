@@ -0,0 +1,75 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextScheduleHeight(t *testing.T) {
+	for _, c := range []struct {
+		next, interval, count, runs, height int
+		expected                            int
+	}{
+		{100, 0, 1, 1, 100, 0},    // 只发送一次
+		{100, 10, 0, 1, 100, 110}, // 不限次数
+		{100, 10, 3, 2, 100, 110}, // 还剩一次
+		{100, 10, 3, 3, 100, 0},   // 已经发送3次
+		{100, 10, 0, 1, 125, 130}, // 错过的不补发
+		{100, 1000, 0, 5, 100, 1100},
+	} {
+		next := nextScheduleHeight(c.next, c.interval, c.count, c.runs, c.height)
+		if next != c.expected {
+			t.Fatalf("%+v: got %d", c, next)
+		}
+	}
+}
+
+func TestFindScheduledTx(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	w, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.wallet = w
+
+	resv := &ScheduledSendReservation{Address: "tb1qdest"}
+	sentTxId := strings.Repeat("a", 64)
+	otherTxId := strings.Repeat("b", 64)
+	// 同一个收款人的另一笔交易不能当作这次发送
+	manager.recordActivity(&ActivityItem{
+		Type:           ACTIVITY_TX,
+		Address:        w.GetAddress(),
+		TxId:           otherTxId,
+		Direction:      ACTIVITY_DIRECTION_SEND,
+		Counterparties: []string{resv.Address},
+		Status:         ACTIVITY_STATUS_PENDING,
+		CreateTime:     time.Now().Unix(),
+	})
+
+	run := &ScheduledRun{Height: 100, Time: time.Now().Unix() - 10}
+	if manager.findScheduledTx(resv, run) {
+		t.Fatal("run without txid should not be found")
+	}
+	run.TxId = sentTxId
+	if manager.findScheduledTx(resv, run) {
+		t.Fatal("unrelated tx should not match")
+	}
+
+	manager.recordActivity(&ActivityItem{
+		Type:           ACTIVITY_TX,
+		Address:        w.GetAddress(),
+		TxId:           sentTxId,
+		Direction:      ACTIVITY_DIRECTION_SEND,
+		Counterparties: []string{resv.Address},
+		Status:         ACTIVITY_STATUS_PENDING,
+		CreateTime:     time.Now().Unix(),
+	})
+	if !manager.findScheduledTx(resv, run) {
+		t.Fatal("recorded tx should be found")
+	}
+	resv.SatsNet = true
+	if manager.findScheduledTx(resv, run) {
+		t.Fatal("tx in the other layer should not match")
+	}
+}
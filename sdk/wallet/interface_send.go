@@ -560,12 +560,18 @@ func (p *Manager) BatchSendAssets_SatsNet(destAddr string,
 // 发送资产到一个地址上
 func (p *Manager) SendAssets_SatsNet(destAddr string,
 	assetName string, amt string, memo []byte) (*swire.MsgTx, error) {
-	return p.sendAssets_SatsNet(destAddr, assetName, amt, memo, true)
+	return p.sendAssets_SatsNet(destAddr, assetName, amt, memo, true, nil)
+}
+
+// 发送资产到一个地址上，control 指定必须花费和不能花费的白聪utxo
+func (p *Manager) SendAssetsWithCoinControl_SatsNet(destAddr string,
+	assetName string, amt string, memo []byte, control *CoinControl) (*swire.MsgTx, error) {
+	return p.sendAssets_SatsNet(destAddr, assetName, amt, memo, true, control)
 }
 
 // 发送资产到一个地址上
 func (p *Manager) sendAssets_SatsNet(destAddr string,
-	assetName string, amt string, memo []byte, autoAdjust bool) (*swire.MsgTx, error) {
+	assetName string, amt string, memo []byte, autoAdjust bool, control *CoinControl) (*swire.MsgTx, error) {
 
 	if p.wallet == nil {
 		return nil, fmt.Errorf("wallet is not created/unlocked")
@@ -618,24 +624,52 @@ func (p *Manager) sendAssets_SatsNet(destAddr string,
 	prevFetcher := stxscript.NewMultiPrevOutFetcher(nil)
 	var input TxOutput_SatsNet
 	var assetAmt *Decimal
+	excludedUtxoMap := p.coinControlExcluded(nil, control)
 	usedUtxos := make(map[string]bool)
-	for _, out := range outputs {
-		if p.utxoLockerL2.IsLocked(out.OutPoint) {
-			continue
-		}
-		output := OutputInfoToOutput_SatsNet(out)
+	for utxo := range excludedUtxoMap {
+		usedUtxos[utxo] = true
+	}
+	addInput := func(output *TxOutput_SatsNet) {
 		outpoint := output.OutPoint()
 		txOut := output.OutValue
-
 		assetAmt = assetAmt.Add(output.GetAsset(name))
 		txIn := swire.NewTxIn(outpoint, nil, nil)
 		tx.AddTxIn(txIn)
 		prevFetcher.AddPrevOut(*outpoint, &txOut)
 		input.Merge(output)
-		usedUtxos[out.OutPoint] = true
-
-		if assetAmt.Cmp(expectedAmt) >= 0 {
-			break
+		usedUtxos[output.OutPointStr] = true
+	}
+	// 白聪的 control 用于选择资产，其他资产的 control 用于选择网络费
+	var assetControl *CoinControl
+	if indexer.IsPlainAsset(name) {
+		assetControl = control
+	}
+	// 先按照选币策略选择，找不到合适的组合时使用下面的规则
+	candidates, utxoMap := p.assetCoinCandidates_SatsNet(outputs, excludedUtxoMap, name, expectedAmt)
+	coins, err := p.selectAssetCoins(candidates, expectedAmt, true, assetControl)
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) != 0 {
+		for _, coin := range coins {
+			addInput(utxoMap[coin.OutPoint])
+		}
+	} else {
+		// 原来的规则不能保证花费指定的utxo
+		if assetControl.hasInclude() {
+			return nil, fmt.Errorf("can't spend the included utxos")
+		}
+		for _, out := range outputs {
+			if _, ok := excludedUtxoMap[out.OutPoint]; ok {
+				continue
+			}
+			if p.utxoLockerL2.IsLocked(out.OutPoint) {
+				continue
+			}
+			addInput(OutputInfoToOutput_SatsNet(out))
+			if assetAmt.Cmp(expectedAmt) >= 0 {
+				break
+			}
 		}
 	}
 	if assetAmt.Cmp(expectedAmt) < 0 {
@@ -647,42 +681,14 @@ func (p *Manager) sendAssets_SatsNet(destAddr string,
 		}
 	}
 
-	var feeOutputs []*indexerwire.TxOutputInfo
 	if !indexer.IsPlainAsset(name) {
-		feeValue := input.GetPlainSat()
-		if feeValue < DEFAULT_FEE_SATSNET {
-			feeOutputs = p.l2IndexerClient.GetUtxoListWithTicker(address, &indexer.ASSET_PLAIN_SAT)
-			if len(feeOutputs) == 0 {
-				Log.Errorf("no plain sats")
-				return nil, fmt.Errorf("no plain sats")
-			}
-
-			for _, out := range feeOutputs {
-				if p.utxoLockerL2.IsLocked(out.OutPoint) {
-					continue
-				}
-				_, ok := usedUtxos[out.OutPoint]
-				if ok {
-					continue
-				}
-				output := OutputInfoToOutput_SatsNet(out)
-				outpoint := output.OutPoint()
-				txOut := output.OutValue
-
-				feeValue += out.Value
-				txIn := swire.NewTxIn(outpoint, nil, nil)
-				tx.AddTxIn(txIn)
-				prevFetcher.AddPrevOut(*outpoint, &txOut)
-				input.Merge(output)
-
-				if feeValue >= DEFAULT_FEE_SATSNET {
-					break
-				}
-			}
-
-			if feeValue < DEFAULT_FEE_SATSNET {
-				return nil, fmt.Errorf("no enough fee")
-			}
+		feeOutputs, _, err := p.selectUtxosForFeeWithControl_SatsNet(address, usedUtxos,
+			input.GetPlainSat(), control)
+		if err != nil {
+			return nil, err
+		}
+		for _, output := range feeOutputs {
+			addInput(output)
 		}
 	}
 
@@ -914,30 +920,72 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 	if p.wallet == nil {
 		return "", fmt.Errorf("wallet is not created/unlocked")
 	}
+	return p.sendAssetsV3_SatsNet(destAddr, assetName, amt, value, memo, nil, nil)
+}
+
+// excluded 中的utxo不使用，beforeBroadcast 在广播前调用，返回错误时不广播
+func (p *Manager) sendAssetsV3_SatsNet(destAddr string,
+	assetName string, amt string, value int64, memo []byte,
+	excluded map[string]bool, beforeBroadcast func(txId string, fee int64) error) (string, error) {
+
+	tx, prevFetcher, err := p.buildSendAssetsV3Tx_SatsNet(destAddr, assetName, amt, value, memo, excluded)
+	if err != nil {
+		return "", err
+	}
+
+	// sign
+	tx, err = p.SignTx_SatsNet(tx, prevFetcher)
+	if err != nil {
+		Log.Errorf("SignTx_SatsNet failed. %v", err)
+		return "", err
+	}
+
+	PrintJsonTx_SatsNet(tx, "SendAssetsV2_SatsNet")
+	if beforeBroadcast != nil {
+		err = beforeBroadcast(tx.TxID(), DEFAULT_FEE_SATSNET)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	txid, err := p.BroadcastTx_SatsNet(tx)
+	if err != nil {
+		Log.Errorf("BroadCastTx_SatsNet failed. %v", err)
+		return "", err
+	}
+
+	return txid, nil
+}
+
+// 构造 SendAssetsV3_SatsNet 的交易，不签名
+func (p *Manager) buildSendAssetsV3Tx_SatsNet(destAddr string,
+	assetName string, amt string, value int64, memo []byte, excluded map[string]bool) (
+	*swire.MsgTx, *stxscript.MultiPrevOutFetcher, error) {
+
 	name := ParseAssetString(assetName)
 	if name == nil {
-		return "", fmt.Errorf("invalid asset name %s", assetName)
+		return nil, nil, fmt.Errorf("invalid asset name %s", assetName)
 	}
 	tickerInfo := p.getTickerInfo(name)
 	if tickerInfo == nil {
-		return "", fmt.Errorf("can't get ticker %s info", assetName)
+		return nil, nil, fmt.Errorf("can't get ticker %s info", assetName)
 	}
 	dAmt, err := indexer.NewDecimalFromString(amt, tickerInfo.Divisibility)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	if dAmt.Sign() <= 0 {
-		return "", fmt.Errorf("invalid amt")
+		return nil, nil, fmt.Errorf("invalid amt")
 	}
 	if !IsValidNullData_SatsNet(memo) {
-		return "", fmt.Errorf("invalid length of null data %d", len(memo))
+		return nil, nil, fmt.Errorf("invalid length of null data %d", len(memo))
 	}
 
 	address := p.wallet.GetAddress()
 	outputs := p.l2IndexerClient.GetUtxoListWithTicker(address, name)
 	if len(outputs) == 0 {
 		Log.Errorf("no asset %s", assetName)
-		return "", fmt.Errorf("no asset %s", assetName)
+		return nil, nil, fmt.Errorf("no asset %s", assetName)
 	}
 
 	Log.Infof("SendAssetsV3_SatsNet %s %s", assetName, amt)
@@ -945,11 +993,11 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 
 	addr, err := sbtcutil.DecodeAddress(destAddr, GetChainParam_SatsNet())
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	assetPkScript, err := stxscript.PayToAddrScript(addr)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	satsNum := value + DEFAULT_FEE_SATSNET
@@ -965,7 +1013,7 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 	var assetAmt *Decimal
 	usedUtxos := make(map[string]bool)
 	for _, out := range outputs {
-		if p.utxoLockerL2.IsLocked(out.OutPoint) {
+		if p.utxoLockerL2.IsLocked(out.OutPoint) || excluded[out.OutPoint] {
 			continue
 		}
 		output := OutputInfoToOutput_SatsNet(out)
@@ -986,7 +1034,7 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 		}
 	}
 	if assetAmt.Cmp(expectedAmt) < 0 {
-		return "", fmt.Errorf("not enough asset %s", assetName)
+		return nil, nil, fmt.Errorf("not enough asset %s", assetName)
 	}
 
 	var feeOutputs []*indexerwire.TxOutputInfo
@@ -996,11 +1044,11 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 			feeOutputs = p.l2IndexerClient.GetUtxoListWithTicker(address, &indexer.ASSET_PLAIN_SAT)
 			if len(feeOutputs) == 0 {
 				Log.Errorf("no plain sats")
-				return "", fmt.Errorf("no plain sats")
+				return nil, nil, fmt.Errorf("no plain sats")
 			}
 
 			for _, out := range feeOutputs {
-				if p.utxoLockerL2.IsLocked(out.OutPoint) {
+				if p.utxoLockerL2.IsLocked(out.OutPoint) || excluded[out.OutPoint] {
 					continue
 				}
 				_, ok := usedUtxos[out.OutPoint]
@@ -1023,7 +1071,7 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 			}
 
 			if feeValue < satsNum {
-				return "", fmt.Errorf("no enough fee")
+				return nil, nil, fmt.Errorf("no enough fee")
 			}
 		}
 	}
@@ -1038,7 +1086,7 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 	tx.AddTxOut(txOut0)
 	err = input.Subtract(TxOutToOutput(txOut0))
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	feeAsset := swire.AssetInfo{
@@ -1048,40 +1096,25 @@ func (p *Manager) SendAssetsV3_SatsNet(destAddr string,
 	}
 	err = input.SubAsset(&feeAsset)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	changePkScript, err := GetP2TRpkScript(p.wallet.GetPaymentPubKey())
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	SplitChangeAsset(&input, changePkScript, tx)
 
 	// attached data
 	if memo != nil {
 		if len(memo) > stxscript.MaxDataCarrierSize {
-			return "", fmt.Errorf("attached data too large")
+			return nil, nil, fmt.Errorf("attached data too large")
 		}
 		txOut3 := swire.NewTxOut(0, nil, memo)
 		tx.AddTxOut(txOut3)
 	}
 
-	// sign
-	tx, err = p.SignTx_SatsNet(tx, prevFetcher)
-	if err != nil {
-		Log.Errorf("SignTx_SatsNet failed. %v", err)
-		return "", err
-	}
-
-	PrintJsonTx_SatsNet(tx, "SendAssetsV2_SatsNet")
-
-	txid, err := p.BroadcastTx_SatsNet(tx)
-	if err != nil {
-		Log.Errorf("BroadCastTx_SatsNet failed. %v", err)
-		return "", err
-	}
-
-	return txid, nil
+	return tx, prevFetcher, nil
 }
 
 func (p *Manager) GenerateStubUtxos(localWallet common.Wallet,
@@ -1089,7 +1122,7 @@ func (p *Manager) GenerateStubUtxos(localWallet common.Wallet,
 	//
 	tx, fee, err := p.BatchSendAssetsWithWallet(localWallet,
 		localWallet.GetAddress(), indexer.ASSET_PLAIN_SAT.String(),
-		"330", n, feeRate, nil)
+		"330", n, feeRate, nil, nil)
 	if err != nil {
 		return "", fee, err
 	}
@@ -1104,17 +1137,20 @@ func (p *Manager) GenerateStubUtxosV2(n int, excludedUtxoMap map[string]bool,
 	}
 	destAddr := p.wallet.GetAddress()
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	tx, prevFetcher, fee, err := p.BuildBatchSendTx_btc(destAddr, destAddr,
-		330, n, excludedUtxoMap, feeRate, nil, false)
+		330, n, excludedUtxoMap, feeRate, nil, false, nil)
 	if err != nil {
 		Log.Errorf("buildBatchSendTx failed. %v", err)
 		return nil, 0, err
 	}
 
+	// 支持RBF加速
+	enableRBF(tx)
 	// sign
 	tx, err = p.SignTx(tx, prevFetcher)
 	if err != nil {
@@ -1145,12 +1181,21 @@ func (p *Manager) BatchSendPlainSats(destAddr string, value int64, n int,
 // 发送资产到一个地址上，拆分n个输出
 func (p *Manager) BatchSendAssets(destAddr string, assetName string,
 	amt string, n int, feeRate int64, memo []byte) (*wire.MsgTx, int64, error) {
-	return p.BatchSendAssetsWithWallet(p.wallet, destAddr, assetName, amt, n, feeRate, memo)
+	return p.BatchSendAssetsWithWallet(p.wallet, destAddr, assetName, amt, n, feeRate, memo, nil)
+}
+
+// 发送资产到一个地址上，拆分n个输出，control 指定必须花费和不能花费的白聪utxo
+func (p *Manager) BatchSendAssetsWithCoinControl(destAddr string, assetName string,
+	amt string, n int, feeRate int64, memo []byte, control *CoinControl) (*wire.MsgTx, int64, error) {
+	if p.wallet == nil {
+		return nil, 0, fmt.Errorf("wallet is not created/unlocked")
+	}
+	return p.BatchSendAssetsWithWallet(p.wallet, destAddr, assetName, amt, n, feeRate, memo, control)
 }
 
 // 发送资产到一个地址上，拆分n个输出
 func (p *Manager) BatchSendAssetsWithWallet(localWallet common.Wallet, destAddr string, assetName string,
-	amt string, n int, feeRate int64, memo []byte) (*wire.MsgTx, int64, error) {
+	amt string, n int, feeRate int64, memo []byte, control *CoinControl) (*wire.MsgTx, int64, error) {
 
 	if localWallet == nil {
 		localWallet = p.wallet
@@ -1173,8 +1218,9 @@ func (p *Manager) BatchSendAssetsWithWallet(localWallet common.Wallet, destAddr
 	if !IsValidNullData(memo) {
 		return nil, 0, fmt.Errorf("invalid length of null data %d", len(memo))
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	var tx *wire.MsgTx
@@ -1186,13 +1232,13 @@ func (p *Manager) BatchSendAssetsWithWallet(localWallet common.Wallet, destAddr
 	localAddress := localWallet.GetAddress()
 	switch name.Protocol {
 	case "": // btc
-		tx, prevFetcher, fee, err = p.BuildBatchSendTx_btc(localAddress, destAddr, dAmt.Int64(), n, nil, feeRate, memo, true)
+		tx, prevFetcher, fee, err = p.BuildBatchSendTx_btc(localAddress, destAddr, dAmt.Int64(), n, nil, feeRate, memo, true, control)
 	case indexer.PROTOCOL_NAME_ORDX:
-		tx, prevFetcher, fee, err = p.BuildBatchSendTx_ordx(localAddress, destAddr, newName, dAmt, n, feeRate, memo)
+		tx, prevFetcher, fee, err = p.BuildBatchSendTx_ordx(localAddress, destAddr, newName, dAmt, n, feeRate, memo, control)
 	case indexer.PROTOCOL_NAME_RUNES:
-		tx, prevFetcher, fee, err = p.BuildBatchSendTx_runes(localAddress, destAddr, newName, dAmt, n, feeRate, memo)
+		tx, prevFetcher, fee, err = p.BuildBatchSendTx_runes(localAddress, destAddr, newName, dAmt, n, feeRate, memo, control)
 	case indexer.PROTOCOL_NAME_BRC20:
-		tx, prevFetcher, fee, inscribe, err = p.BuildBatchSendTx_brc20(localAddress, destAddr, newName, dAmt, n, feeRate, memo)
+		tx, prevFetcher, fee, inscribe, err = p.BuildBatchSendTx_brc20(localAddress, destAddr, newName, dAmt, n, feeRate, memo, control)
 	default:
 		return nil, 0, fmt.Errorf("buildBatchSendTx unsupport protocol %s", name.Protocol)
 	}
@@ -1206,8 +1252,10 @@ func (p *Manager) BatchSendAssetsWithWallet(localWallet common.Wallet, destAddr
 		}
 	}()
 
+	// 支持RBF加速
+	enableRBF(tx)
 	// sign
-	tx, err = SignTxWithWallet(localWallet, tx, prevFetcher)
+	tx, err = p.SignTxWithPolicy(localWallet, tx, prevFetcher, nil)
 	if err != nil {
 		Log.Errorf("SignTx failed. %v", err)
 		return nil, 0, err
@@ -1240,7 +1288,12 @@ func (p *Manager) BatchSendAssetsWithWallet(localWallet common.Wallet, destAddr
 // 从p2tr地址发出
 func (p *Manager) BuildBatchSendTx_btc(localAddress string, destAddr string, amt int64, n int,
 	excludedUtxoMap map[string]bool,
-	feeRate int64, memo []byte, autoAdjust bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	feeRate int64, memo []byte, autoAdjust bool, control *CoinControl) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	if amt < 330 {
 		return nil, nil, 0, fmt.Errorf("amount too small")
@@ -1271,9 +1324,9 @@ func (p *Manager) BuildBatchSendTx_btc(localAddress string, destAddr string, amt
 	}
 
 	required := amt * int64(n)
-	prevFetcher, changePkScript, outputValue, changeOutput, fee0, err := p.SelectUtxosForPlainSats(
+	prevFetcher, changePkScript, outputValue, changeOutput, fee0, err := p.selectUtxosForPlainSatsWithHeight(
 		localAddress, excludedUtxoMap,
-		required, feeRate, tx, &weightEstimate, false, false, autoAdjust)
+		required, feeRate, tx, &weightEstimate, false, false, autoAdjust, 0, control)
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -1321,6 +1374,11 @@ func (p *Manager) BuildSendTx_garbage(destAddr string, inputs []string, required
 	excludedUtxoMap map[string]bool,
 	feeRate int64, memo []byte) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	if len(inputs) == 0 {
 		if required < 330 {
 			return nil, nil, 0, fmt.Errorf("amount too small")
@@ -1447,7 +1505,12 @@ func AdjustInputsForSplicingIn(inputs []*TxOutput, name *AssetName) ([]*TxOutput
 // 给同一个地址发送n等分资产
 func (p *Manager) BuildBatchSendTx_ordx(localAddr, destAddr string,
 	name *AssetName, amt *Decimal, n int, feeRate int64,
-	memo []byte) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	memo []byte, control *CoinControl) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	addr, err := btcutil.DecodeAddress(destAddr, GetChainParam())
 	if err != nil {
@@ -1551,8 +1614,8 @@ func (p *Manager) BuildBatchSendTx_ordx(localAddr, destAddr string,
 	if feeValue < fee0 {
 		// 增加fee
 		var selected []*TxOutput
-		selected, feeValue, err = p.SelectUtxosForFee(localAddr, nil,
-			feeValue, feeRate, &weightEstimate, false, false)
+		selected, feeValue, err = p.selectUtxosForFeeV3WithHeight(NewUtxoMgr(localAddr, p.l1IndexerClient), nil,
+			feeValue, feeRate, &weightEstimate, false, false, 0, control)
 		if err != nil {
 			return nil, nil, 0, err
 		}
@@ -1594,7 +1657,12 @@ func (p *Manager) BuildBatchSendTx_ordx(localAddr, destAddr string,
 // 给同一个地址发送n等分资产
 func (p *Manager) BuildBatchSendTx_runes(localAddr, destAddr string,
 	name *AssetName, amt *Decimal, n int, feeRate int64,
-	memo []byte) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	memo []byte, control *CoinControl) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	addr, err := btcutil.DecodeAddress(destAddr, GetChainParam())
 	if err != nil {
@@ -1673,9 +1741,9 @@ func (p *Manager) BuildBatchSendTx_runes(localAddr, destAddr string,
 	if feeValue < fee0 {
 		// 增加fee
 		var selected []*TxOutput
-		selected, feeValue, err = p.SelectUtxosForFee(
-			localAddr, nil, feeValue,
-			feeRate, &weightEstimate, false, false)
+		selected, feeValue, err = p.selectUtxosForFeeV3WithHeight(
+			NewUtxoMgr(localAddr, p.l1IndexerClient), nil, feeValue,
+			feeRate, &weightEstimate, false, false, 0, control)
 		if err != nil {
 			return nil, nil, 0, err
 		}
@@ -1882,7 +1950,12 @@ func (p *Manager) selectUtxosForBRC20WithHeight(utxomgr *UtxoMgr, excludedUtxoMa
 // 给同一个地址发送n等分资产. brc20只支持n==1的情况
 func (p *Manager) BuildBatchSendTx_brc20(localAddr, destAddr string,
 	name *AssetName, amt *Decimal, n int, feeRate int64,
-	memo []byte) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, *InscribeResv, error) {
+	memo []byte, control *CoinControl) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, *InscribeResv, error) {
+
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
 
 	if n != 1 {
 		return nil, nil, 0, nil, fmt.Errorf("not support")
@@ -1942,9 +2015,9 @@ func (p *Manager) BuildBatchSendTx_brc20(localAddr, destAddr string,
 	if feeValue < fee0 {
 		// 增加fee
 		var selected []*TxOutput
-		selected, feeValue, err = p.SelectUtxosForFeeV3(
+		selected, feeValue, err = p.selectUtxosForFeeV3WithHeight(
 			utxomgr, nil, feeValue,
-			feeRate, &weightEstimate, false, false)
+			feeRate, &weightEstimate, false, false, 0, control)
 		if err != nil {
 			return nil, nil, 0, nil, err
 		}
@@ -1997,8 +2070,9 @@ func (p *Manager) SendAssets(destAddr string, assetName string,
 	if !IsValidNullData(memo) {
 		return nil, fmt.Errorf("invalid length of null data %d", len(memo))
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 
 	tx, _, err := p.BatchSendAssets(destAddr, assetName, amt, 1, feeRate, memo)
@@ -2016,8 +2090,9 @@ func (p *Manager) SendGarbage(destAddr string, utxos []string,
 		return nil, fmt.Errorf("wallet is not created/unlocked")
 	}
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 
 	tx, prevFetcher, _, err := p.BuildSendTx_garbage(destAddr,
@@ -2027,6 +2102,8 @@ func (p *Manager) SendGarbage(destAddr string, utxos []string,
 		return nil, err
 	}
 
+	// 支持RBF加速
+	enableRBF(tx)
 	// sign
 	tx, err = p.SignTx(tx, prevFetcher)
 	if err != nil {
@@ -2439,7 +2516,7 @@ func (p *Manager) SelectUtxosForPlainSats(
 ) (*txscript.MultiPrevOutFetcher, []byte, int64, int64, int64, error) {
 
 	return p.selectUtxosForPlainSatsWithHeight(address, excludedUtxoMap,
-		requiredValue, feeRate, tx, weightEstimate, excludeRecentBlock, inChannel, autoAdjust, 0)
+		requiredValue, feeRate, tx, weightEstimate, excludeRecentBlock, inChannel, autoAdjust, 0, nil)
 }
 
 func (p *Manager) selectUtxosForPlainSatsWithHeight(
@@ -2447,7 +2524,7 @@ func (p *Manager) selectUtxosForPlainSatsWithHeight(
 	requiredValue int64, feeRate int64,
 	tx *wire.MsgTx, weightEstimate *utils.TxWeightEstimator,
 	excludeRecentBlock, inChannel, autoAdjust bool,
-	maxConfirmedInputHeight int,
+	maxConfirmedInputHeight int, control *CoinControl,
 ) (*txscript.MultiPrevOutFetcher, []byte, int64, int64, int64, error) {
 	/* 规则：
 	1. 先根据目标输出的value，先选1个，或者最多5个utxo，其聪数量不大于value
@@ -2457,11 +2534,36 @@ func (p *Manager) selectUtxosForPlainSatsWithHeight(
 	*/
 
 	utxos := p.l1IndexerClient.GetUtxoListWithTicker(address, &indexer.ASSET_PLAIN_SAT)
-	if len(utxos) == 0 {
+	changeUtxos := p.getChangeAddressPlainUtxos(address, inChannel)
+	if len(utxos) == 0 && len(changeUtxos) == 0 {
 		return nil, nil, 0, 0, 0, fmt.Errorf("no plain sats")
 	}
-	changePkScript := utxos[0].PkScript
+	var changePkScript []byte
+	if len(utxos) != 0 {
+		changePkScript = utxos[0].PkScript
+	}
+	changePkScript = p.getChangePkScript(address, inChannel, changePkScript)
+	utxos = mergePlainUtxos(utxos, changeUtxos)
 	p.utxoLockerL1.Reload(address)
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, control)
+
+	// 先按照选币策略选择，找不到合适的组合时使用下面的规则
+	candidates, utxoMap := p.plainCoinCandidates(utxos, excludedUtxoMap, excludeRecentBlock, maxConfirmedInputHeight)
+	coins, err := p.selectCoins(candidates, coinSelectParamsL1(requiredValue, feeRate, weightEstimate, inChannel), control)
+	if err != nil {
+		return nil, nil, 0, 0, 0, err
+	}
+	if len(coins) != 0 {
+		prevFetcher, changeOutput, fee0, ok := p.addSelectedPlainCoins(coins, utxoMap,
+			tx, weightEstimate, requiredValue, feeRate, inChannel)
+		if ok {
+			return prevFetcher, changePkScript, requiredValue, changeOutput, fee0, nil
+		}
+	}
+	// 原来的规则不能保证花费指定的utxo
+	if control.hasInclude() {
+		return nil, nil, 0, 0, 0, fmt.Errorf("can't spend the included utxos with enough fee")
+	}
 
 	selected := make(map[string]*indexerwire.TxOutputInfo)
 	localWeightEstimate := *weightEstimate
@@ -2642,6 +2744,28 @@ func (p *Manager) selectUtxosForAssetWithHeight(address string, excludedUtxoMap
 	}
 	p.utxoLockerL1.Reload(address)
 
+	// 先按照选币策略选择，找不到合适的组合时使用下面的规则
+	candidates, utxoMap := p.assetCoinCandidates(utxos, excludedUtxoMap, assetName, requiredAmt,
+		excludeRecentBlock, maxConfirmedInputHeight)
+	if coins, _ := p.selectAssetCoins(candidates, requiredAmt, false, nil); len(coins) != 0 {
+		total := int64(0)
+		var totalAsset *Decimal
+		selected := make([]*TxOutput, 0, len(coins))
+		for _, coin := range coins {
+			txOut := utxoMap[coin.OutPoint]
+			totalAsset = totalAsset.Add(txOut.GetAsset(assetName))
+			AlignAsset(txOut, assetName)
+			selected = append(selected, txOut)
+			if inChannel {
+				weightEstimate.AddWitnessInput(utils.MultiSigWitnessSize)
+			} else {
+				weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+			}
+			total += txOut.OutValue.Value
+		}
+		return selected, totalAsset, total, nil
+	}
+
 	localWeightEstimate := *weightEstimate
 	// 先选满足条件的utxo
 	total := int64(0)
@@ -2755,7 +2879,7 @@ func (p *Manager) SelectUtxosForFeeV3(
 	excludeRecentBlock, inChannel bool) ([]*TxOutput, int64, error) {
 
 	return p.selectUtxosForFeeV3WithHeight(utxoMgr, excludedUtxoMap,
-		feeValue, feeRate, weightEstimate, excludeRecentBlock, inChannel, 0)
+		feeValue, feeRate, weightEstimate, excludeRecentBlock, inChannel, 0, nil)
 }
 
 func (p *Manager) selectUtxosForFeeV3WithHeight(
@@ -2763,7 +2887,7 @@ func (p *Manager) selectUtxosForFeeV3WithHeight(
 	feeValue int64, feeRate int64,
 	weightEstimate *utils.TxWeightEstimator,
 	excludeRecentBlock, inChannel bool,
-	maxConfirmedInputHeight int) ([]*TxOutput, int64, error) {
+	maxConfirmedInputHeight int, control *CoinControl) ([]*TxOutput, int64, error) {
 
 	fee0 := weightEstimate.Fee(feeRate)
 	localFeeValue := feeValue
@@ -2774,10 +2898,22 @@ func (p *Manager) selectUtxosForFeeV3WithHeight(
 	}
 
 	feeOutputs := utxoMgr.GetUtxoListWithTicker(&indexer.ASSET_PLAIN_SAT)
+	feeOutputs = mergePlainUtxos(feeOutputs,
+		p.getChangeAddressPlainUtxos(utxoMgr.GetAddress(), inChannel))
 	if len(feeOutputs) == 0 {
 		Log.Errorf("no plain sats")
 		return nil, 0, fmt.Errorf("no plain sats")
 	}
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, control)
+	if selected, value, ok := p.selectFeeCoinsV3(feeOutputs, excludedUtxoMap, feeValue, feeRate,
+		weightEstimate, excludeRecentBlock, inChannel, maxConfirmedInputHeight, control); ok {
+		utxoMgr.RemoveOutputs(selected)
+		return selected, value, nil
+	}
+	// 原来的规则不能保证花费指定的utxo
+	if control.hasInclude() {
+		return nil, 0, fmt.Errorf("can't spend the included utxos with enough fee")
+	}
 
 	localWeightEstimate := *weightEstimate
 	selected := make([]*TxOutput, 0)
@@ -2870,6 +3006,21 @@ func (p *Manager) SelectUtxosForAsset_SatsNet(address string,
 	}
 	p.utxoLockerL2.Reload(address)
 
+	// 先按照选币策略选择，找不到合适的组合时使用下面的规则
+	candidates, utxoMap := p.assetCoinCandidates_SatsNet(utxos, excludedUtxoMap, assetName, requiredAmt)
+	if coins, _ := p.selectAssetCoins(candidates, requiredAmt, true, nil); len(coins) != 0 {
+		totalPlainSats := int64(0)
+		var totalAsset *Decimal
+		selected := make([]*TxOutput_SatsNet, 0, len(coins))
+		for _, coin := range coins {
+			txOut := utxoMap[coin.OutPoint]
+			selected = append(selected, txOut)
+			totalPlainSats += txOut.GetPlainSat()
+			totalAsset = totalAsset.Add(txOut.GetAsset(assetName))
+		}
+		return selected, totalAsset, totalPlainSats, nil
+	}
+
 	// 先选满足条件的utxo
 	totalPlainSats := int64(0)
 	var totalAsset *Decimal
@@ -2921,6 +3072,12 @@ func (p *Manager) SelectUtxosForAsset_SatsNet(address string,
 // 选择合适大小的utxo，而不是从最大的utxo选择
 func (p *Manager) SelectUtxosForFee_SatsNet(address string, excludedUtxoMap map[string]bool,
 	feeValue int64) ([]*TxOutput_SatsNet, int64, error) {
+	return p.selectUtxosForFeeWithControl_SatsNet(address, excludedUtxoMap, feeValue, nil)
+}
+
+// control 指定必须花费和不能花费的白聪utxo
+func (p *Manager) selectUtxosForFeeWithControl_SatsNet(address string, excludedUtxoMap map[string]bool,
+	feeValue int64, control *CoinControl) ([]*TxOutput_SatsNet, int64, error) {
 	requiredFee := DEFAULT_FEE_SATSNET - feeValue
 	if requiredFee <= 0 {
 		// 不需要新增加fee
@@ -2932,6 +3089,25 @@ func (p *Manager) SelectUtxosForFee_SatsNet(address string, excludedUtxoMap map[
 		Log.Errorf("no plain sats")
 		return nil, 0, fmt.Errorf("no plain sats")
 	}
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, control)
+	candidates, utxoMap := p.plainCoinCandidates_SatsNet(feeOutputs, excludedUtxoMap)
+	coins, err := p.selectCoins(candidates, &CoinSelectParams{Target: requiredFee}, control)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(coins) != 0 {
+		var total int64
+		result := make([]*TxOutput_SatsNet, 0, len(coins))
+		for _, coin := range coins {
+			result = append(result, utxoMap[coin.OutPoint])
+			total += coin.Value
+		}
+		return result, total, nil
+	}
+	// 原来的规则不能保证花费指定的utxo
+	if control.hasInclude() {
+		return nil, 0, fmt.Errorf("can't spend the included utxos")
+	}
 
 	var totalPlainSats int64
 	bigger := make([]*TxOutput_SatsNet, 0)
@@ -3009,6 +3185,10 @@ func (p *Manager) SelectUtxosForFeeV2(
 	if requiredValue == 0 {
 		requiredValue = MAX_FEE
 	}
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, nil)
+	if selected := p.selectFlatFeeCoins(utxos, excludedUtxoMap, requiredValue, excludeRecentBlock); len(selected) != 0 {
+		return selected, nil
+	}
 
 	bigger := make([]*indexerwire.TxOutputInfo, 0)
 	result := make([]*TxOutput, 0)
@@ -3073,6 +3253,11 @@ func (p *Manager) SelectUtxosForFeeV2WithMgr(
 	if requiredValue == 0 {
 		requiredValue = MAX_FEE
 	}
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, nil)
+	if selected := p.selectFlatFeeCoins(utxos, excludedUtxoMap, requiredValue, excludeRecentBlock); len(selected) != 0 {
+		utxoMgr.RemoveOutputs(selected)
+		return selected, nil
+	}
 
 	bigger := make([]*indexer.AssetsInUtxo, 0)
 	result := make([]*TxOutput, 0)
@@ -3143,6 +3328,12 @@ func (p *Manager) SelectUtxosForAssetV2_SatsNet(address string,
 // 选择合适大小的utxo，而不是从最大的utxo选择
 func (p *Manager) SelectUtxosForFeeV2_SatsNet(address string, excludedUtxoMap map[string]bool,
 	requiredValue int64) ([]string, error) {
+	return p.selectUtxosForFeeV2WithControl_SatsNet(address, excludedUtxoMap, requiredValue, nil)
+}
+
+// control 指定必须花费和不能花费的白聪utxo
+func (p *Manager) selectUtxosForFeeV2WithControl_SatsNet(address string, excludedUtxoMap map[string]bool,
+	requiredValue int64, control *CoinControl) ([]string, error) {
 	if address == "" {
 		address = p.wallet.GetAddress()
 	}
@@ -3152,6 +3343,23 @@ func (p *Manager) SelectUtxosForFeeV2_SatsNet(address string, excludedUtxoMap ma
 	if requiredValue == 0 {
 		requiredValue = DEFAULT_FEE_SATSNET
 	}
+	excludedUtxoMap = p.coinControlExcluded(excludedUtxoMap, control)
+	candidates, _ := p.plainCoinCandidates_SatsNet(utxos, excludedUtxoMap)
+	coins, err := p.selectCoins(candidates, &CoinSelectParams{Target: requiredValue}, control)
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) != 0 {
+		result := make([]string, 0, len(coins))
+		for _, coin := range coins {
+			result = append(result, coin.OutPoint)
+		}
+		return result, nil
+	}
+	// 原来的规则不能保证花费指定的utxo
+	if control.hasInclude() {
+		return nil, fmt.Errorf("can't spend the included utxos")
+	}
 
 	bigger := make([]*TxOutput_SatsNet, 0)
 	result := make([]string, 0)
@@ -3204,6 +3412,11 @@ func (p *Manager) SelectUtxosForFeeV2_SatsNet(address string, excludedUtxoMap ma
 func (p *Manager) BuildSendOrdxTxWithStub(srcAddress string, excluded map[string]bool, destAddr string, assetName *AssetName,
 	amt int64, stub string, feeRate int64, memo []byte, excludeRecentBlock, inChannel bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildSendOrdxTxWithStubWithHeight(srcAddress, excluded, destAddr, assetName,
 		amt, stub, feeRate, memo, excludeRecentBlock, inChannel, 0)
 }
@@ -3368,7 +3581,7 @@ func (p *Manager) buildSendOrdxTxWithStubWithHeight(srcAddress string, excluded
 		// 增加fee
 		var selected []*TxOutput
 		selected, feeValue, err = p.selectUtxosForFeeV3WithHeight(NewUtxoMgr(srcAddress, p.l1IndexerClient), excluded, feeValue,
-			feeRate, &weightEstimate, excludeRecentBlock, inChannel, maxConfirmedInputHeight)
+			feeRate, &weightEstimate, excludeRecentBlock, inChannel, maxConfirmedInputHeight, nil)
 		if err != nil {
 			return nil, nil, 0, err
 		}
@@ -3409,6 +3622,11 @@ func (p *Manager) buildSendOrdxTxWithStubWithHeight(srcAddress string, excluded
 
 func (p *Manager) BuildSendOrdxTxWithStubFromAddress(localWallet common.Wallet, srcAddress string, destAddr string, assetName *AssetName,
 	amt int64, stub string, feeRate int64, memo []byte, inChannel, excludeRecentBlock bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildSendOrdxTxWithStubFromAddressWithHeight(localWallet, srcAddress, destAddr, assetName,
 		amt, stub, feeRate, memo, inChannel, excludeRecentBlock, 0)
 }
@@ -3460,6 +3678,11 @@ func (p *Manager) batchSendSourceContext(localWallet common.Wallet, srcAddress s
 
 func (p *Manager) BuildBatchSendTxV3BTCFromAddress(srcAddress string, dest []*SendAssetInfo,
 	feeRate int64, memo []byte, inChannel, excludeRecentBlock bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithAddressHeight_btc(srcAddress, dest, feeRate, memo,
 		inChannel, excludeRecentBlock, 0)
 }
@@ -3476,6 +3699,11 @@ func (p *Manager) buildBatchSendTxWithAddressHeight_btc(srcAddress string, dest
 
 func (p *Manager) BuildBatchSendTxV3OrdxFromAddress(localWallet common.Wallet, srcAddress string, dest []*SendAssetInfo,
 	assetName *AssetName, feeRate int64, memo []byte, inChannel, excludeRecentBlock, payFeeByLocalAddress bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithAddressHeight_ordx(localWallet, srcAddress, dest, assetName,
 		feeRate, memo, inChannel, excludeRecentBlock, payFeeByLocalAddress, 0)
 }
@@ -3492,6 +3720,11 @@ func (p *Manager) buildBatchSendTxWithAddressHeight_ordx(localWallet common.Wall
 
 func (p *Manager) BuildBatchSendTxV3RunesFromAddress(localWallet common.Wallet, srcAddress string, dest []*SendAssetInfo,
 	assetName *AssetName, feeRate int64, inChannel, excludeRecentBlock, payFeeByLocalAddress bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithAddressHeight_runes(localWallet, srcAddress, dest, assetName,
 		feeRate, inChannel, excludeRecentBlock, payFeeByLocalAddress, 0)
 }
@@ -3508,6 +3741,11 @@ func (p *Manager) buildBatchSendTxWithAddressHeight_runes(localWallet common.Wal
 
 func (p *Manager) BuildBatchSendTxV3BRC20FromAddress(localWallet common.Wallet, srcAddress string, dest []*SendAssetInfo,
 	assetName *AssetName, feeRate int64, memo []byte, inChannel, excludeRecentBlock, payFeeByLocalAddress bool) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, []*InscribeResv, error) {
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+
 	return p.buildBatchSendTxWithAddressHeight_brc20(localWallet, srcAddress, dest, assetName,
 		feeRate, memo, inChannel, excludeRecentBlock, payFeeByLocalAddress, 0)
 }
@@ -3528,6 +3766,15 @@ func (p *Manager) BatchSendAssetsV3(dest []*SendAssetInfo,
 	assetNameStr string, feeRate int64, memo []byte,
 	reason string, autoAdjust bool) (string, int64, error) {
 
+	return p.batchSendAssetsV3(dest, assetNameStr, feeRate, memo, reason, autoAdjust, nil, nil)
+}
+
+// excluded 中的utxo不使用，beforeBroadcast 在广播前调用，返回错误时不广播
+func (p *Manager) batchSendAssetsV3(dest []*SendAssetInfo,
+	assetNameStr string, feeRate int64, memo []byte,
+	reason string, autoAdjust bool, excluded map[string]bool,
+	beforeBroadcast func(txId string, fee int64) error) (string, int64, error) {
+
 	if p.wallet == nil {
 		return "", 0, fmt.Errorf("wallet is not created/unlocked")
 	}
@@ -3535,46 +3782,8 @@ func (p *Manager) BatchSendAssetsV3(dest []*SendAssetInfo,
 		return "", 0, fmt.Errorf("invalid length of null data %d", len(memo))
 	}
 
-	name := ParseAssetString(assetNameStr)
-	if name == nil {
-		return "", 0, fmt.Errorf("invalid asset name %s", assetNameStr)
-	}
-	tickerInfo := p.getTickerInfo(name)
-	if tickerInfo == nil {
-		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
-	}
-	assetName := GetAssetName(tickerInfo)
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
-	}
-
-	var tx *wire.MsgTx
-	var prevFetcher *txscript.MultiPrevOutFetcher
-	var fee int64
-	var err error
-
-	srcAddr := p.wallet.GetAddress()
-	var inscribes []*InscribeResv
-	excluded := make(map[string]bool)
-	switch name.Protocol {
-	case "": // btc
-		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_btc(srcAddr, excluded,
-			dest, feeRate, memo, false, false, autoAdjust)
-	case indexer.PROTOCOL_NAME_ORDX:
-		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_ordx(srcAddr, excluded,
-			dest, assetName, feeRate, memo, false, false, p.wallet, false)
-	case indexer.PROTOCOL_NAME_RUNES:
-		if len(memo) != 0 { // TODO 等主网支持多个op_return后再修改
-			return "", 0, fmt.Errorf("do not attach memo when send runes asset")
-		}
-		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_runes(srcAddr, excluded,
-			dest, assetName, feeRate, false, false, p.wallet, false)
-	case indexer.PROTOCOL_NAME_BRC20:
-		tx, prevFetcher, fee, inscribes, err = p.BuildBatchSendTxV3_brc20(srcAddr,
-			excluded, dest, assetName, feeRate, memo, false, false, p.wallet, false)
-	default:
-		return "", 0, fmt.Errorf("BatchSendAssetsV3 unsupport protocol %s", name.Protocol)
-	}
+	tx, prevFetcher, fee, inscribes, err := p.buildBatchSendAssetsV3(dest, assetNameStr,
+		feeRate, memo, autoAdjust, excluded)
 	if err != nil {
 		return "", 0, err
 	}
@@ -3588,6 +3797,8 @@ func (p *Manager) BatchSendAssetsV3(dest []*SendAssetInfo,
 		}
 	}()
 
+	// 支持RBF加速
+	enableRBF(tx)
 	// sign
 	tx, err = p.SignTx(tx, prevFetcher)
 	if err != nil {
@@ -3610,6 +3821,12 @@ func (p *Manager) BatchSendAssetsV3(dest []*SendAssetInfo,
 	} else {
 		txs = []*wire.MsgTx{tx}
 	}
+	if beforeBroadcast != nil {
+		err = beforeBroadcast(tx.TxID(), fee)
+		if err != nil {
+			return "", 0, err
+		}
+	}
 
 	err = p.BroadcastTxs(txs)
 	if err != nil {
@@ -3625,12 +3842,70 @@ func (p *Manager) BatchSendAssetsV3(dest []*SendAssetInfo,
 	return tx.TxID(), fee, nil
 }
 
+// 构造 BatchSendAssetsV3 的交易，不签名
+func (p *Manager) buildBatchSendAssetsV3(dest []*SendAssetInfo,
+	assetNameStr string, feeRate int64, memo []byte, autoAdjust bool, excluded map[string]bool) (
+	*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, []*InscribeResv, error) {
+
+	name := ParseAssetString(assetNameStr)
+	if name == nil {
+		return nil, nil, 0, nil, fmt.Errorf("invalid asset name %s", assetNameStr)
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return nil, nil, 0, nil, fmt.Errorf("can't get ticker %s info", assetNameStr)
+	}
+	assetName := GetAssetName(tickerInfo)
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+
+	var tx *wire.MsgTx
+	var prevFetcher *txscript.MultiPrevOutFetcher
+	var fee int64
+
+	srcAddr := p.wallet.GetAddress()
+	var inscribes []*InscribeResv
+	if excluded == nil {
+		excluded = make(map[string]bool)
+	}
+	switch name.Protocol {
+	case "": // btc
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_btc(srcAddr, excluded,
+			dest, feeRate, memo, false, false, autoAdjust)
+	case indexer.PROTOCOL_NAME_ORDX:
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_ordx(srcAddr, excluded,
+			dest, assetName, feeRate, memo, false, false, p.wallet, false)
+	case indexer.PROTOCOL_NAME_RUNES:
+		if len(memo) != 0 { // TODO 等主网支持多个op_return后再修改
+			return nil, nil, 0, nil, fmt.Errorf("do not attach memo when send runes asset")
+		}
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_runes(srcAddr, excluded,
+			dest, assetName, feeRate, false, false, p.wallet, false)
+	case indexer.PROTOCOL_NAME_BRC20:
+		tx, prevFetcher, fee, inscribes, err = p.BuildBatchSendTxV3_brc20(srcAddr,
+			excluded, dest, assetName, feeRate, memo, false, false, p.wallet, false)
+	default:
+		return nil, nil, 0, nil, fmt.Errorf("BatchSendAssetsV3 unsupport protocol %s", name.Protocol)
+	}
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+	return tx, prevFetcher, fee, inscribes, nil
+}
+
 // 给多个地址发送不同数量的白聪，支持全部发送
 func (p *Manager) BuildBatchSendTxV3_btc(srcAddress string, excluded map[string]bool,
 	dest []*SendAssetInfo,
 	feeRate int64, memo []byte, excludeRecentBlock, inChannel, autoAdjust bool,
 ) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithHeight_btc(srcAddress, excluded, dest, feeRate,
 		memo, excludeRecentBlock, inChannel, autoAdjust, 0)
 }
@@ -3673,7 +3948,7 @@ func (p *Manager) buildBatchSendTxWithHeight_btc(srcAddress string, excluded map
 
 	prevFetcher, changePkScript, outputValue, changeOutput, fee0, err :=
 		p.selectUtxosForPlainSatsWithHeight(srcAddress, excluded, requiredValue, feeRate,
-			tx, &weightEstimate, excludeRecentBlock, inChannel, autoAdjust, maxConfirmedInputHeight)
+			tx, &weightEstimate, excludeRecentBlock, inChannel, autoAdjust, maxConfirmedInputHeight, nil)
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -3734,13 +4009,14 @@ func (p *Manager) buildBatchSendTxV3FeeWithHeight(
 	tx *wire.MsgTx, weightEstimate *utils.TxWeightEstimator, prevFetcher *txscript.MultiPrevOutFetcher,
 	feeValue, feeRate int64, changePkScript []byte, maxConfirmedInputHeight int) (int64, error) {
 
+	changePkScript = p.getChangePkScript(utxoMgr.GetAddress(), inChannel, changePkScript)
 	fee0 := weightEstimate.Fee(feeRate)
 	if feeValue < fee0 {
 		// 增加fee
 		var err error
 		var selected []*TxOutput
 		selected, feeValue, err = p.selectUtxosForFeeV3WithHeight(utxoMgr, excluded, feeValue,
-			feeRate, weightEstimate, excludeRecentBlock, inChannel, maxConfirmedInputHeight)
+			feeRate, weightEstimate, excludeRecentBlock, inChannel, maxConfirmedInputHeight, nil)
 		if err != nil {
 			return 0, err
 		}
@@ -3791,6 +4067,11 @@ func (p *Manager) BuildBatchSendTxV3_ordx(srcAddress string, excluded map[string
 	localWallet common.Wallet, payFeeByLocalAddress bool) (
 	*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithHeight_ordx(srcAddress, excluded, dest, assetName,
 		feeRate, memo, excludeRecentBlock, inChannel, localWallet, payFeeByLocalAddress, 0)
 }
@@ -4010,6 +4291,11 @@ func (p *Manager) BuildBatchSendTxV3_runes(srcAddress string, excluded map[strin
 	localWallet common.Wallet, payFeeByLocalAddress bool) (
 	*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	return p.buildBatchSendTxWithHeight_runes(srcAddress, excluded, dest, assetName,
 		feeRate, excludeRecentBlock, inChannel, localWallet, payFeeByLocalAddress, 0)
 }
@@ -4156,6 +4442,11 @@ func (p *Manager) BuildBatchSendTxV3_brc20(srcAddress string, excludedUtxoMap ma
 	localWallet common.Wallet, payFeeByLocalAddress bool) (
 	*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, []*InscribeResv, error) {
 
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, nil, 0, nil, err
+	}
+
 	return p.buildBatchSendTxWithHeight_brc20(srcAddress, excludedUtxoMap, dest, assetName,
 		feeRate, memo, excludeRecentBlock, inChannel, localWallet, payFeeByLocalAddress, 0)
 }
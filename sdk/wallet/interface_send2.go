@@ -59,8 +59,9 @@ func (p *Manager) CoBatchSendAssetsV3FromAddress(localWallet common.Wallet, dest
 		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
 	}
 	assetName := GetAssetName(tickerInfo)
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	excluded := make(map[string]bool)
@@ -76,7 +77,6 @@ func (p *Manager) CoBatchSendAssetsV3FromAddress(localWallet common.Wallet, dest
 	var prevFetcher *txscript.MultiPrevOutFetcher
 	var fee int64
 	var inscribes []*InscribeResv
-	var err error
 	switch name.Protocol {
 	case "":
 		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_btc(channelId,
@@ -164,8 +164,9 @@ func (p *Manager) CoBatchSendV4(localWallet common.Wallet, dest []*SendAssetInfo
 		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
 	}
 	assetName := GetAssetName(tickerInfo)
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
 	}
 
 	excluded := make(map[string]bool)
@@ -173,7 +174,6 @@ func (p *Manager) CoBatchSendV4(localWallet common.Wallet, dest []*SendAssetInfo
 	var prevFetcher *txscript.MultiPrevOutFetcher
 	var fee int64
 	var inscribes []*InscribeResv
-	var err error
 	switch asset.Protocol {
 	case "":
 		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_btc(channelId,
@@ -274,7 +274,7 @@ func (p *Manager) CoBatchSendV4_SatsNet(localWallet common.Wallet, dest []*SendA
 	}
 	totalValue += DEFAULT_FEE_SATSNET
 
-	witness, peerPubKey, err := p.channelWitness(localWallet, channelId)
+	witness, peerPubKey, client, err := p.channelPeer(localWallet, channelId)
 	if err != nil {
 		return "", err
 	}
@@ -289,6 +289,9 @@ func (p *Manager) CoBatchSendV4_SatsNet(localWallet common.Wallet, dest []*SendA
 		return "", err
 	}
 
+	if err := p.checkCoSignTxPolicy_SatsNet(tx, prevFetcher); err != nil {
+		return "", err
+	}
 	sigs, err := PartialSignTxWithWallet_SatsNet(localWallet, tx, prevFetcher, witness, peerPubKey)
 	if err != nil {
 		return "", err
@@ -331,7 +334,7 @@ func (p *Manager) CoBatchSendV4_SatsNet(localWallet common.Wallet, dest []*SendA
 	if err != nil {
 		return "", err
 	}
-	peerSig, err := p.serverNode.client.SendSigReq(&req, sig)
+	peerSig, err := client.SendSigReq(&req, sig)
 	if err != nil {
 		return "", err
 	}
@@ -458,6 +461,9 @@ func (p *Manager) addToSignData(localWallet common.Wallet, tx *wire.MsgTx, prevF
 
 	var sigs [][]byte
 	if prevFetcher != nil {
+		if err := p.checkCoSignTxPolicy(tx, prevFetcher); err != nil {
+			return nil, nil, nil, err
+		}
 		var err error
 		sigs, err = PartialSignTxWithWallet(localWallet, tx, prevFetcher, witness, hasSelectingPath, peerPubKey)
 		if err != nil {
@@ -507,6 +513,7 @@ func (p *Manager) addToSignData_SatsNet(localWallet common.Wallet, tx *swire.Msg
 	signData []*RemoteSignData, txsSignInfo []*wwire.TxSignInfo,
 	txs []*swire.MsgTx, notSign bool) ([]*RemoteSignData, []*wwire.TxSignInfo, []*swire.MsgTx, error) {
 
+	// 这里都是 deAnchor 交易，资产和聪离开聪网是预期的，由同一批次中的 L1 交易检查
 	sigs, err := PartialSignTxWithWallet_SatsNet(localWallet, tx, prevFetcher, witness, peerPubKey)
 	if err != nil {
 		return nil, nil, nil, err
@@ -544,6 +551,10 @@ func (p *Manager) reqRemoteSignAndBroadcast(localWallet common.Wallet, witness,
 	reason, channelId string, signData []*RemoteSignData, txsSignInfo []*wwire.TxSignInfo,
 	txs []*wire.MsgTx, txs2 []*swire.MsgTx, md []byte) (*wire.MsgTx, int64, error) {
 
+	node := p.findNode(peerPubKey)
+	if node == nil || node.RPCClient() == nil {
+		return nil, 0, fmt.Errorf("can't find peer node %x", peerPubKey)
+	}
 	localKey := localWallet.GetPaymentPubKey().SerializeCompressed()
 	req := wwire.SignRequest{
 		MsgHeader:    wwire.NewMsgHeader(),
@@ -562,7 +573,7 @@ func (p *Manager) reqRemoteSignAndBroadcast(localWallet common.Wallet, witness,
 	if err != nil {
 		return nil, 0, err
 	}
-	peerSig, err := p.serverNode.client.SendSigReq(&req, sig)
+	peerSig, err := node.RPCClient().SendSigReq(&req, sig)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -611,23 +622,33 @@ func (p *Manager) ReqRemoteSignAndBroadcast(localWallet common.Wallet, witness,
 }
 
 func (p *Manager) channelWitness(localWallet common.Wallet, channelId string) ([]byte, []byte, error) {
-	if p.serverNode == nil || p.serverNode.Pubkey == nil || p.serverNode.client == nil {
-		return nil, nil, fmt.Errorf("server node is not ready")
+	witness, peerPubKey, _, err := p.channelPeer(localWallet, channelId)
+	return witness, peerPubKey, err
+}
+
+// 返回通道的见证脚本、对端公钥和对端节点
+func (p *Manager) channelPeer(localWallet common.Wallet, channelId string) ([]byte, []byte, NodeRPCClient, error) {
+	node, err := p.getPeerNodeWithChannelAddress(localWallet, channelId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if node.RPCClient() == nil {
+		return nil, nil, nil, fmt.Errorf("peer node %s is not ready", nodeKey(node))
 	}
 	localKey := localWallet.GetPaymentPubKey().SerializeCompressed()
-	peerPubKey := p.serverNode.Pubkey.SerializeCompressed()
+	peerPubKey := node.Pubkey.SerializeCompressed()
 	witness, pkScript, err := GetP2WSHscript(localKey, peerPubKey)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	channelId2, err := GetP2WSHaddressFromScript(pkScript)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	if channelId2 != channelId {
-		return nil, nil, fmt.Errorf("invalid channel %s", channelId)
+		return nil, nil, nil, fmt.Errorf("invalid channel %s", channelId)
 	}
-	return witness, peerPubKey, nil
+	return witness, peerPubKey, node.RPCClient(), nil
 }
 
 func (p *Manager) ChannelWitness(localWallet common.Wallet, channelId string) ([]byte, []byte, error) {
@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"fmt"
+)
+
+// 连接外部签名器，使用签名器中的钱包。本地不保存私钥，也不保存到数据库，每次启动时重新连接
+func (p *Manager) ConnectSigner(endpoint string) (int64, error) {
+	releaseRGB11Scope := p.beginRGB11ScopeChange()
+	defer releaseRGB11Scope()
+
+	signer, err := DialSigner(endpoint)
+	if err != nil {
+		return -1, err
+	}
+	wallet, err := NewSignerWallet(signer)
+	if err != nil {
+		signer.Close()
+		Log.Errorf("NewSignerWallet failed. %v", err)
+		return -1, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closeRemoteSigner()
+	p.wallet = wallet
+	p.status.CurrentWallet = wallet.GetId()
+	p.status.CurrentAccount = 0
+	_ = p.rgbManager.selectRGB11Scope()
+	_ = p.rgbManager.rebuildRGB11Locks()
+	p.saveStatus()
+	Log.Infof("connected to signer %s, wallet %d", endpoint, wallet.GetId())
+	return p.status.CurrentWallet, nil
+}
+
+func (p *Manager) closeRemoteSigner() {
+	w, ok := p.wallet.(*SignerWallet)
+	if !ok {
+		return
+	}
+	if remote, ok := w.GetSigner().(*RemoteSigner); ok {
+		remote.Close()
+	}
+}
+
+// 把当前钱包作为外部签名器对外提供服务，供其他机器上的Manager调用 ConnectSigner 使用
+func (p *Manager) StartSignerServer(endpoint string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.signerListener != nil {
+		return fmt.Errorf("signer server is running")
+	}
+	wallet, ok := p.wallet.(*InternalWallet)
+	if !ok {
+		return fmt.Errorf("only internal wallet can be used as a signer")
+	}
+
+	listener, err := listenSigner(endpoint)
+	if err != nil {
+		return err
+	}
+	p.signerListener = listener
+
+	signer := NewLocalSigner(wallet.Clone().(*InternalWallet), p.status.CurrentWallet)
+	go func() {
+		err := ServeSigner(listener, signer)
+		Log.Infof("signer server %s stopped. %v", endpoint, err)
+	}()
+	Log.Infof("signer server is listening on %s", endpoint)
+	return nil
+}
+
+func (p *Manager) stopSignerServer() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.signerListener != nil {
+		p.signerListener.Close()
+		p.signerListener = nil
+	}
+}
@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	indexer "github.com/sat20-labs/indexer/common"
+)
+
+/*
+观察钱包：导入账户扩展公钥或者输出描述符，例如 tr([fp/86'/0'/0']xpub.../0/*)
+可以查询余额、utxo、资产汇总，构造未签名的psbt，导出到离线机器上签名后再广播。
+*/
+
+// 导入扩展公钥或者描述符，创建观察钱包。描述符跟助记词一样加密保存
+func (p *Manager) ImportWatchOnlyWallet(descriptor string, password string) (int64, error) {
+	releaseRGB11Scope := p.beginRGB11ScopeChange()
+	defer releaseRGB11Scope()
+	if err := p.checkAddWalletPassword(password); err != nil {
+		return -1, err
+	}
+
+	wallet, err := NewWatchOnlyWallet(descriptor, GetChainParam())
+	if err != nil {
+		Log.Errorf("NewWatchOnlyWallet failed. %v", err)
+		return -1, err
+	}
+
+	p.mutex.Lock()
+
+	// 保存规范化以后的描述符
+	err = p.saveSecret(wallet.GetDescriptor(), password, WALLET_TYPE_WATCHONLY, wallet)
+	if err != nil {
+		p.mutex.Unlock()
+		return -1, err
+	}
+
+	p.wallet = wallet
+	p.status.CurrentWallet = wallet.GetId()
+	p.status.CurrentAccount = 0
+	_ = p.rgbManager.selectRGB11Scope()
+	_ = p.rgbManager.rebuildRGB11Locks()
+	p.saveStatus()
+	p.markDKVSStateDirty()
+
+	id := p.status.CurrentWallet
+	p.mutex.Unlock()
+	if err := p.refreshDKVSRegistrations(); err != nil {
+		Log.Warningf("refresh DKVS registrations after watch-only import failed: %v", err)
+	}
+
+	// 和 ImportWallet 一样，同步扫描子账户
+	if _, err := p.discoverAccounts(id, 0); err != nil {
+		Log.Warningf("discover accounts after watch-only import failed: %v", err)
+	}
+	return id, nil
+}
+
+// 导出当前钱包的账户描述符，用于在其他设备上导入观察钱包
+func (p *Manager) ExportAccountDescriptor(addrType string) (string, error) {
+	if p.wallet == nil {
+		return "", fmt.Errorf("wallet is not created/unlocked")
+	}
+	switch w := p.wallet.(type) {
+	case *InternalWallet:
+		return w.GetAccountDescriptor(addrType)
+	case *WatchOnlyWallet:
+		if addrType != w.GetAddressType() {
+			return "", fmt.Errorf("watch-only wallet has no %s descriptor", addrType)
+		}
+		return w.GetDescriptor(), nil
+	default:
+		return "", fmt.Errorf("wallet does not support descriptor")
+	}
+}
+
+// 构造未签名的psbt，用于离线签名。输入输出都填写了派生路径。
+// 不支持brc20，因为brc20的转移需要先签名铭刻交易
+func (p *Manager) BuildUnsignedPsbtV3(dest []*SendAssetInfo,
+	assetNameStr string, feeRate int64, memo []byte, autoAdjust bool) (string, int64, error) {
+
+	if p.wallet == nil {
+		return "", 0, fmt.Errorf("wallet is not created/unlocked")
+	}
+	if !IsValidNullData(memo) {
+		return "", 0, fmt.Errorf("invalid length of null data %d", len(memo))
+	}
+
+	srcAddr := p.wallet.GetAddress()
+	srcPkScript, err := GetPkScriptFromAddress(srcAddr)
+	if err != nil {
+		return "", 0, err
+	}
+	// 构造交易时按照p2tr输入估算网络费
+	if !txscript.IsPayToTaproot(srcPkScript) {
+		return "", 0, fmt.Errorf("only support p2tr address, %s", srcAddr)
+	}
+
+	name := ParseAssetString(assetNameStr)
+	if name == nil {
+		return "", 0, fmt.Errorf("invalid asset name %s", assetNameStr)
+	}
+	tickerInfo := p.getTickerInfo(name)
+	if tickerInfo == nil {
+		return "", 0, fmt.Errorf("can't get ticker %s info", assetNameStr)
+	}
+	assetName := GetAssetName(tickerInfo)
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var tx *wire.MsgTx
+	var prevFetcher *txscript.MultiPrevOutFetcher
+	var fee int64
+
+	excluded := make(map[string]bool)
+	switch name.Protocol {
+	case "": // btc
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_btc(srcAddr, excluded,
+			dest, feeRate, memo, false, false, autoAdjust)
+	case indexer.PROTOCOL_NAME_ORDX:
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_ordx(srcAddr, excluded,
+			dest, assetName, feeRate, memo, false, false, p.wallet, false)
+	case indexer.PROTOCOL_NAME_RUNES:
+		if len(memo) != 0 {
+			return "", 0, fmt.Errorf("do not attach memo when send runes asset")
+		}
+		tx, prevFetcher, fee, err = p.BuildBatchSendTxV3_runes(srcAddr, excluded,
+			dest, assetName, feeRate, false, false, p.wallet, false)
+	default:
+		return "", 0, fmt.Errorf("BuildUnsignedPsbtV3 unsupport protocol %s", name.Protocol)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	packet, err := CreatePsbt(tx, prevFetcher, nil, p.wallet)
+	if err != nil {
+		Log.Errorf("CreatePsbt failed. %v", err)
+		return "", 0, err
+	}
+
+	result, err := encodePsbt(packet)
+	if err != nil {
+		return "", 0, err
+	}
+	Log.Infof("BuildUnsignedPsbtV3 succeed. %s %d", tx.TxID(), fee)
+	return result, fee, nil
+}
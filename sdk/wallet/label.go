@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	db "github.com/sat20-labs/indexer/common"
+)
+
+/*
+BIP329 标签
+每条记录一行json：{"type": "tx", "ref": "txid", "label": "...", "origin": "...", "spendable": false}
+1. tx：txid
+2. addr：地址
+3. pubkey：公钥hex
+4. input/output：txid:vout
+5. xpub：扩展公钥
+output 的 spendable 为 false 时，构造交易不会使用这个utxo
+*/
+
+const (
+	LABEL_TYPE_TX     = "tx"
+	LABEL_TYPE_ADDR   = "addr"
+	LABEL_TYPE_PUBKEY = "pubkey"
+	LABEL_TYPE_INPUT  = "input"
+	LABEL_TYPE_OUTPUT = "output"
+	LABEL_TYPE_XPUB   = "xpub"
+)
+
+type Label struct {
+	Type      string `json:"type"`
+	Ref       string `json:"ref"`
+	Label     string `json:"label,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	Spendable *bool  `json:"spendable,omitempty"`
+}
+
+func IsValidLabelType(typ string) bool {
+	switch typ {
+	case LABEL_TYPE_TX, LABEL_TYPE_ADDR, LABEL_TYPE_PUBKEY,
+		LABEL_TYPE_INPUT, LABEL_TYPE_OUTPUT, LABEL_TYPE_XPUB:
+		return true
+	}
+	return false
+}
+
+func labelKey(typ, ref string) string {
+	return typ + "-" + ref
+}
+
+func (p *Label) key() string {
+	return labelKey(p.Type, p.Ref)
+}
+
+// 没有任何内容的标签，保存时当作删除
+func (p *Label) isEmpty() bool {
+	return p.Label == "" && p.Origin == "" && p.Spendable == nil
+}
+
+func (p *Label) Clone() *Label {
+	result := *p
+	if p.Spendable != nil {
+		spendable := *p.Spendable
+		result.Spendable = &spendable
+	}
+	return &result
+}
+
+// 解析 BIP329 JSONL，忽略未知类型的记录
+func ParseBIP329(data []byte) ([]*Label, error) {
+	result := make([]*Label, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var label Label
+		err := json.Unmarshal(text, &label)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid label record, %v", line, err)
+		}
+		if !IsValidLabelType(label.Type) {
+			Log.Warnf("line %d: unknown label type %s", line, label.Type)
+			continue
+		}
+		if label.Ref == "" {
+			return nil, fmt.Errorf("line %d: ref is required", line)
+		}
+		if label.Spendable != nil && label.Type != LABEL_TYPE_OUTPUT {
+			label.Spendable = nil
+		}
+		result = append(result, &label)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 按照类型和引用排序输出 BIP329 JSONL
+func ExportBIP329(labels []*Label) ([]byte, error) {
+	sorted := append([]*Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return sorted[i].Ref < sorted[j].Ref
+	})
+	var buf bytes.Buffer
+	for _, label := range sorted {
+		line, err := json.Marshal(label)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func GetLabelDBKey(typ, ref string) string {
+	return GetDBKeyPrefix() + DB_KEY_LABEL + labelKey(typ, ref)
+}
+
+func saveLabel(kvdb db.KVDB, label *Label) error {
+	buf, err := EncodeToBytes(label)
+	if err != nil {
+		Log.Errorf("saveLabel EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetLabelDBKey(label.Type, label.Ref)), buf)
+}
+
+func deleteLabel(kvdb db.KVDB, typ, ref string) error {
+	return kvdb.Delete([]byte(GetLabelDBKey(typ, ref)))
+}
+
+func loadAllLabels(kvdb db.KVDB) map[string]*Label {
+	result := make(map[string]*Label)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_LABEL)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var label Label
+		err := DecodeFromBytes(v, &label)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result[label.key()] = &label
+		return nil
+	})
+	return result
+}
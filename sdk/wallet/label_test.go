@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"testing"
+)
+
+func TestParseBIP329(t *testing.T) {
+	data := `{"type":"tx","ref":"tx1","label":"payment"}
+
+{"type":"output","ref":"tx1:0","label":"cold","spendable":false}
+{"type":"addr","ref":"addr1","label":"alice","spendable":true}
+{"type":"unknown","ref":"x","label":"ignored"}
+`
+	labels, err := ParseBIP329([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 {
+		t.Fatalf("expected 3 labels, got %d", len(labels))
+	}
+	if labels[1].Spendable == nil || *labels[1].Spendable {
+		t.Fatalf("output spendable should be false")
+	}
+	if labels[2].Spendable != nil {
+		t.Fatalf("spendable should be ignored for addr label")
+	}
+
+	for _, invalid := range []string{
+		`{"type":"tx","label":"no ref"}`,
+		`{"type":"tx",`,
+	} {
+		if _, err := ParseBIP329([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestExportBIP329(t *testing.T) {
+	spendable := false
+	labels := []*Label{
+		{Type: LABEL_TYPE_TX, Ref: "tx1", Label: "payment"},
+		{Type: LABEL_TYPE_OUTPUT, Ref: "tx1:0", Label: "cold", Spendable: &spendable},
+		{Type: LABEL_TYPE_ADDR, Ref: "addr1", Label: "alice"},
+	}
+	data, err := ExportBIP329(labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"addr","ref":"addr1","label":"alice"}
+{"type":"output","ref":"tx1:0","label":"cold","spendable":false}
+{"type":"tx","ref":"tx1","label":"payment"}
+`
+	if string(data) != expected {
+		t.Fatalf("unexpected export %q", string(data))
+	}
+
+	parsed, err := ParseBIP329(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(labels) {
+		t.Fatalf("round trip lost labels")
+	}
+}
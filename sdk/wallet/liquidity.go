@@ -0,0 +1,303 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	db "github.com/sat20-labs/indexer/common"
+)
+
+/*
+通道流动性策略，按资产配置
+1. 聪网上钱包余额低于 MinL2Balance 时从通道解锁，高于 MaxL2Balance 时锁定到通道，调整到两者的中间值
+2. 通道中本地余额的比例偏离 TargetLocalRatio 超过 Tolerance 时，用一层钱包的资产拼接进通道，或者拼接出通道
+3. 24小时内拼接的网络费总和不超过 MaxDailyFee，锁定和解锁的网络费总和不超过 MaxDailyFee_SatsNet，
+   交易记录解析后使用实际支付的网络费，之前使用估算值。超出预算没有执行的动作也记录下来
+聪网上的调整在L2监控线程执行，拼接在L1监控线程检查后在后台执行，每次最多执行一个动作
+*/
+
+const (
+	LIQUIDITY_ACTION_LOCK         = "lock"
+	LIQUIDITY_ACTION_UNLOCK       = "unlock"
+	LIQUIDITY_ACTION_SPLICING_IN  = "splicingIn"
+	LIQUIDITY_ACTION_SPLICING_OUT = "splicingOut"
+)
+
+type LiquidityPolicy struct {
+	AssetName           string `json:"assetName"`
+	ChannelId           string `json:"channelId"`          // 为空时使用当前通道
+	TargetLocalRatio    int    `json:"targetLocalRatio"`   // 百分比，通道中本地余额的目标比例，0 表示不拼接
+	Tolerance           int    `json:"tolerance"`          // 百分比，偏离目标超过这个值才拼接
+	MinL2Balance        string `json:"minL2Balance"`       // 空表示不检查
+	MaxL2Balance        string `json:"maxL2Balance"`       // 空表示不检查
+	MaxDailyFee         int64  `json:"maxDailyFee"`        // 聪，拼接的网络费，0 表示不限
+	MaxDailyFee_SatsNet int64  `json:"maxDailyFeeSatsNet"` // 聪，锁定和解锁的网络费，0 表示不限
+	Disabled            bool   `json:"disabled"`
+	UpdateTime          int64  `json:"updateTime"`
+}
+
+func (p *LiquidityPolicy) Clone() *LiquidityPolicy {
+	n := *p
+	return &n
+}
+
+// 拼接和聪网上的调整分别计算预算
+func (p *LiquidityPolicy) maxDailyFee(l1 bool) int64 {
+	if l1 {
+		return p.MaxDailyFee
+	}
+	return p.MaxDailyFee_SatsNet
+}
+
+func (p *LiquidityPolicy) Validate() error {
+	if p.AssetName == "" {
+		return fmt.Errorf("asset name is required")
+	}
+	if p.TargetLocalRatio < 0 || p.TargetLocalRatio >= 100 {
+		return fmt.Errorf("invalid target local ratio %d", p.TargetLocalRatio)
+	}
+	if p.Tolerance < 0 || p.Tolerance >= 100 {
+		return fmt.Errorf("invalid tolerance %d", p.Tolerance)
+	}
+	if p.MaxDailyFee < 0 {
+		return fmt.Errorf("invalid max daily fee %d", p.MaxDailyFee)
+	}
+	if p.MaxDailyFee_SatsNet < 0 {
+		return fmt.Errorf("invalid max daily fee of satsnet %d", p.MaxDailyFee_SatsNet)
+	}
+	min, err := parseLiquidityAmount(p.MinL2Balance)
+	if err != nil {
+		return fmt.Errorf("invalid min L2 balance %s", p.MinL2Balance)
+	}
+	max, err := parseLiquidityAmount(p.MaxL2Balance)
+	if err != nil {
+		return fmt.Errorf("invalid max L2 balance %s", p.MaxL2Balance)
+	}
+	if min != nil && max != nil && min.Cmp(max) > 0 {
+		return fmt.Errorf("min L2 balance %s is larger than max %s", p.MinL2Balance, p.MaxL2Balance)
+	}
+	return nil
+}
+
+// 每次执行的动作都会记录下来，包括执行失败的和超出预算没有执行的
+type LiquidityDecision struct {
+	Id            int64  `json:"id"`
+	AssetName     string `json:"assetName"`
+	ChannelId     string `json:"channelId"`
+	Action        string `json:"action"`
+	Amt           string `json:"amt"`
+	Reason        string `json:"reason"`
+	LocalBalance  string `json:"localBalance"`
+	RemoteBalance string `json:"remoteBalance"`
+	L1Balance     string `json:"l1Balance"`
+	L2Balance     string `json:"l2Balance"`
+	EstimatedFee  int64  `json:"estimatedFee"`
+	Fee           int64  `json:"fee"` // 实际支付的网络费，交易记录解析后才有
+	FeeRate       int64  `json:"feeRate"`
+	TxId          string `json:"txId"`
+	ResvId        int64  `json:"resvId"`
+	Error         string `json:"error"`
+	Skipped       string `json:"skipped"` // 没有执行的原因
+	Time          int64  `json:"time"`
+}
+
+// 拼接在一层，锁定和解锁在聪网
+func isLiquidityActionInL1(action string) bool {
+	return action == LIQUIDITY_ACTION_SPLICING_IN || action == LIQUIDITY_ACTION_SPLICING_OUT
+}
+
+// 决策时的余额
+type liquidityState struct {
+	Local  *big.Rat // 通道中本地余额
+	Remote *big.Rat // 通道中对端余额
+	L1     *big.Rat // 一层钱包中可以拼接的数量
+	L2     *big.Rat // 聪网钱包余额
+}
+
+func parseLiquidityAmount(s string) (*big.Rat, error) {
+	if s == "" {
+		return nil, nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %s", s)
+	}
+	return r, nil
+}
+
+func decimalToRat(d *Decimal) *big.Rat {
+	if d == nil {
+		return new(big.Rat)
+	}
+	r, ok := new(big.Rat).SetString(d.String())
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// 按资产精度向下取整
+func ratToAmount(r *big.Rat, divisibility int) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(divisibility)), nil)
+	n := new(big.Int).Mul(r.Num(), scale)
+	n.Quo(n, r.Denom())
+	s := n.String()
+	if divisibility == 0 {
+		return s
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if len(s) <= divisibility {
+		s = strings.Repeat("0", divisibility-len(s)+1) + s
+	}
+	s = s[:len(s)-divisibility] + "." + s[len(s)-divisibility:]
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+func minRat(a, b *big.Rat) *big.Rat {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func percentRat(n int) *big.Rat {
+	return big.NewRat(int64(n), 100)
+}
+
+// l1 为 true 时只检查拼接，否则只检查聪网余额。不需要调整时返回空的 action
+func decideLiquidity(policy *LiquidityPolicy, state *liquidityState, divisibility int, l1 bool) (string, string, string) {
+	if !l1 {
+		min, _ := parseLiquidityAmount(policy.MinL2Balance)
+		max, _ := parseLiquidityAmount(policy.MaxL2Balance)
+		target := min
+		if max != nil {
+			target = max
+			if min != nil {
+				target = new(big.Rat).Add(min, max)
+				target.Quo(target, big.NewRat(2, 1))
+			}
+		}
+
+		if min != nil && state.L2.Cmp(min) < 0 {
+			need := minRat(new(big.Rat).Sub(target, state.L2), state.Local)
+			amt := ratToAmount(need, divisibility)
+			if need.Sign() > 0 && amt != "0" {
+				return LIQUIDITY_ACTION_UNLOCK, amt, fmt.Sprintf("L2 balance %s is lower than %s",
+					ratToAmount(state.L2, divisibility), policy.MinL2Balance)
+			}
+		}
+		if max != nil && state.L2.Cmp(max) > 0 {
+			excess := new(big.Rat).Sub(state.L2, target)
+			amt := ratToAmount(excess, divisibility)
+			if excess.Sign() > 0 && amt != "0" {
+				return LIQUIDITY_ACTION_LOCK, amt, fmt.Sprintf("L2 balance %s is higher than %s",
+					ratToAmount(state.L2, divisibility), policy.MaxL2Balance)
+			}
+		}
+		return "", "", ""
+	}
+
+	if policy.TargetLocalRatio <= 0 {
+		return "", "", ""
+	}
+	total := new(big.Rat).Add(state.Local, state.Remote)
+	if total.Sign() <= 0 {
+		return "", "", ""
+	}
+	ratio := new(big.Rat).Quo(state.Local, total)
+	target := percentRat(policy.TargetLocalRatio)
+	tolerance := percentRat(policy.Tolerance)
+	oneMinusTarget := new(big.Rat).Sub(big.NewRat(1, 1), target)
+	ratioStr := new(big.Rat).Mul(ratio, big.NewRat(100, 1)).FloatString(1)
+
+	// 拼接 x 后比例达到目标: (local+x)/(total+x) = target
+	if new(big.Rat).Add(ratio, tolerance).Cmp(target) < 0 {
+		x := new(big.Rat).Sub(new(big.Rat).Mul(target, total), state.Local)
+		x.Quo(x, oneMinusTarget)
+		x = minRat(x, state.L1)
+		amt := ratToAmount(x, divisibility)
+		if x.Sign() > 0 && amt != "0" {
+			return LIQUIDITY_ACTION_SPLICING_IN, amt, fmt.Sprintf("local ratio %s%% is lower than target %d%%",
+				ratioStr, policy.TargetLocalRatio)
+		}
+	}
+	// (local-x)/(total-x) = target
+	if new(big.Rat).Sub(ratio, tolerance).Cmp(target) > 0 {
+		x := new(big.Rat).Sub(state.Local, new(big.Rat).Mul(target, total))
+		x.Quo(x, oneMinusTarget)
+		amt := ratToAmount(x, divisibility)
+		if x.Sign() > 0 && amt != "0" {
+			return LIQUIDITY_ACTION_SPLICING_OUT, amt, fmt.Sprintf("local ratio %s%% is higher than target %d%%",
+				ratioStr, policy.TargetLocalRatio)
+		}
+	}
+	return "", "", ""
+}
+
+func GetLiquidityPolicyDBKey(assetName string) string {
+	return GetDBKeyPrefix() + DB_KEY_LIQUIDITY_POLICY + assetName
+}
+
+func GetLiquidityDecisionDBKey(id int64) string {
+	return fmt.Sprintf("%s%s%d", GetDBKeyPrefix(), DB_KEY_LIQUIDITY_DECISION, id)
+}
+
+func saveLiquidityPolicy(kvdb db.KVDB, policy *LiquidityPolicy) error {
+	buf, err := EncodeToBytes(policy)
+	if err != nil {
+		Log.Errorf("saveLiquidityPolicy EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetLiquidityPolicyDBKey(policy.AssetName)), buf)
+}
+
+func deleteLiquidityPolicy(kvdb db.KVDB, assetName string) error {
+	return kvdb.Delete([]byte(GetLiquidityPolicyDBKey(assetName)))
+}
+
+func loadAllLiquidityPolicies(kvdb db.KVDB) map[string]*LiquidityPolicy {
+	result := make(map[string]*LiquidityPolicy)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_LIQUIDITY_POLICY)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var policy LiquidityPolicy
+		err := DecodeFromBytes(v, &policy)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result[policy.AssetName] = &policy
+		return nil
+	})
+	return result
+}
+
+func saveLiquidityDecision(kvdb db.KVDB, decision *LiquidityDecision) error {
+	buf, err := EncodeToBytes(decision)
+	if err != nil {
+		Log.Errorf("saveLiquidityDecision EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetLiquidityDecisionDBKey(decision.Id)), buf)
+}
+
+func loadAllLiquidityDecisions(kvdb db.KVDB) []*LiquidityDecision {
+	result := make([]*LiquidityDecision, 0)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_LIQUIDITY_DECISION)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var decision LiquidityDecision
+		err := DecodeFromBytes(v, &decision)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result = append(result, &decision)
+		return nil
+	})
+	return result
+}
@@ -0,0 +1,151 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRatToAmount(t *testing.T) {
+	cases := []struct {
+		r            *big.Rat
+		divisibility int
+		amt          string
+	}{
+		{big.NewRat(1000, 1), 0, "1000"},
+		{big.NewRat(2001, 2), 0, "1000"},
+		{big.NewRat(1, 3), 2, "0.33"},
+		{big.NewRat(3, 2), 8, "1.5"},
+		{big.NewRat(1, 1000), 2, "0"},
+		{big.NewRat(-5, 4), 2, "-1.25"},
+	}
+	for _, c := range cases {
+		amt := ratToAmount(c.r, c.divisibility)
+		if amt != c.amt {
+			t.Fatalf("%s/%d: expected %s, got %s", c.r.String(), c.divisibility, c.amt, amt)
+		}
+	}
+}
+
+func TestLiquidityPolicyValidate(t *testing.T) {
+	valid := &LiquidityPolicy{AssetName: "::", TargetLocalRatio: 50, Tolerance: 10,
+		MinL2Balance: "100", MaxL2Balance: "1000"}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []*LiquidityPolicy{
+		{AssetName: ""},
+		{AssetName: "::", TargetLocalRatio: 100},
+		{AssetName: "::", Tolerance: -1},
+		{AssetName: "::", MaxDailyFee: -1},
+		{AssetName: "::", MaxDailyFee_SatsNet: -1},
+		{AssetName: "::", MinL2Balance: "abc"},
+		{AssetName: "::", MinL2Balance: "1000", MaxL2Balance: "100"},
+	}
+	for i, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Fatalf("case %d should be invalid", i)
+		}
+	}
+}
+
+func TestDecideLiquidityL2(t *testing.T) {
+	policy := &LiquidityPolicy{AssetName: "::", MinL2Balance: "1000", MaxL2Balance: "3000"}
+	state := func(local, l2 int64) *liquidityState {
+		return &liquidityState{
+			Local:  big.NewRat(local, 1),
+			Remote: big.NewRat(0, 1),
+			L1:     big.NewRat(0, 1),
+			L2:     big.NewRat(l2, 1),
+		}
+	}
+
+	action, amt, _ := decideLiquidity(policy, state(10000, 500), 0, false)
+	if action != LIQUIDITY_ACTION_UNLOCK || amt != "1500" {
+		t.Fatalf("expected unlock 1500, got %s %s", action, amt)
+	}
+	// 通道中的余额不够
+	action, amt, _ = decideLiquidity(policy, state(800, 500), 0, false)
+	if action != LIQUIDITY_ACTION_UNLOCK || amt != "800" {
+		t.Fatalf("expected unlock 800, got %s %s", action, amt)
+	}
+	action, amt, _ = decideLiquidity(policy, state(10000, 5000), 0, false)
+	if action != LIQUIDITY_ACTION_LOCK || amt != "3000" {
+		t.Fatalf("expected lock 3000, got %s %s", action, amt)
+	}
+	action, _, _ = decideLiquidity(policy, state(10000, 2000), 0, false)
+	if action != "" {
+		t.Fatalf("expected no action, got %s", action)
+	}
+	// L1 线程不处理聪网余额
+	action, _, _ = decideLiquidity(policy, state(10000, 500), 0, true)
+	if action != "" {
+		t.Fatalf("expected no action, got %s", action)
+	}
+}
+
+func TestDecideLiquidityL1(t *testing.T) {
+	policy := &LiquidityPolicy{AssetName: "::", TargetLocalRatio: 50, Tolerance: 10}
+	state := func(local, remote, l1 int64) *liquidityState {
+		return &liquidityState{
+			Local:  big.NewRat(local, 1),
+			Remote: big.NewRat(remote, 1),
+			L1:     big.NewRat(l1, 1),
+			L2:     big.NewRat(0, 1),
+		}
+	}
+
+	// (2000+x)/(10000+x) = 0.5
+	action, amt, _ := decideLiquidity(policy, state(2000, 8000, 100000), 0, true)
+	if action != LIQUIDITY_ACTION_SPLICING_IN || amt != "6000" {
+		t.Fatalf("expected splicing in 6000, got %s %s", action, amt)
+	}
+	action, amt, _ = decideLiquidity(policy, state(2000, 8000, 1000), 0, true)
+	if action != LIQUIDITY_ACTION_SPLICING_IN || amt != "1000" {
+		t.Fatalf("expected splicing in 1000, got %s %s", action, amt)
+	}
+	// (8000-x)/(10000-x) = 0.5
+	action, amt, _ = decideLiquidity(policy, state(8000, 2000, 0), 0, true)
+	if action != LIQUIDITY_ACTION_SPLICING_OUT || amt != "6000" {
+		t.Fatalf("expected splicing out 6000, got %s %s", action, amt)
+	}
+	action, _, _ = decideLiquidity(policy, state(5500, 4500, 100000), 0, true)
+	if action != "" {
+		t.Fatalf("expected no action, got %s", action)
+	}
+	action, _, _ = decideLiquidity(policy, state(2000, 8000, 0), 0, true)
+	if action != "" {
+		t.Fatalf("expected no action without L1 balance, got %s", action)
+	}
+}
+
+func TestLiquidityFeeSpent(t *testing.T) {
+	manager := &Manager{}
+	now := int64(1700000000)
+	decisions := []*LiquidityDecision{
+		{Id: 1, AssetName: "::", Action: LIQUIDITY_ACTION_SPLICING_IN, EstimatedFee: 1000, Time: now - 100},
+		{Id: 2, AssetName: "::", Action: LIQUIDITY_ACTION_SPLICING_OUT, EstimatedFee: 1000, Fee: 800, Time: now - 100},
+		{Id: 3, AssetName: "::", Action: LIQUIDITY_ACTION_SPLICING_IN, EstimatedFee: 500, Error: "failed", Time: now - 100},
+		{Id: 4, AssetName: "::", Action: LIQUIDITY_ACTION_SPLICING_IN, EstimatedFee: 500, Time: now - LIQUIDITY_FEE_WINDOW},
+		{Id: 5, AssetName: "::", Action: LIQUIDITY_ACTION_LOCK, EstimatedFee: 10, Time: now - 100},
+		{Id: 6, AssetName: "ordx:f:pearl", Action: LIQUIDITY_ACTION_UNLOCK, EstimatedFee: 10, Time: now - 100},
+	}
+	for _, d := range decisions {
+		manager.addLiquidityDecision(d)
+	}
+	// 超出预算没有执行的动作不计入，连续跳过时只保留一条记录
+	for i := 0; i < 3; i++ {
+		manager.addLiquiditySkipped(&LiquidityDecision{Id: int64(10 + i), AssetName: "::",
+			Action: LIQUIDITY_ACTION_SPLICING_IN, EstimatedFee: 1000, Skipped: "budget", Time: now - 10})
+	}
+
+	if fee := manager.liquidityFeeSpent("::", true, now); fee != 1800 {
+		t.Fatalf("expected L1 fee 1800, got %d", fee)
+	}
+	if fee := manager.liquidityFeeSpent("::", false, now); fee != 10 {
+		t.Fatalf("expected L2 fee 10, got %d", fee)
+	}
+	all := manager.GetLiquidityDecisions("::", 0)
+	if len(all) != 6 || all[0].Skipped == "" || all[0].Id != 10 {
+		t.Fatalf("expected one skipped decision, got %d", len(all))
+	}
+}
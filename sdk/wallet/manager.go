@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"sync"
@@ -96,6 +97,17 @@ func (noopChannelBackupHandler) BackupChannel(*Channel, []byte) error {
 	return nil
 }
 
+// 静态通道备份有变化时调用，data 是加密后的备份文件，应该保存到设备之外
+type StaticChannelBackupHandler interface {
+	BackupStaticChannels(fingerprint string, data []byte) error
+}
+
+type noopStaticChannelBackupHandler struct{}
+
+func (noopStaticChannelBackupHandler) BackupStaticChannels(string, []byte) error {
+	return nil
+}
+
 // 密码只有一个，助记词可以有多组，对应不同的wallet
 type Manager struct {
 	mutex          sync.RWMutex
@@ -118,8 +130,10 @@ type Manager struct {
 	l1IndexerClient *IndexerRPCClientMgr
 	l2IndexerClient *IndexerRPCClientMgr
 
-	bootstrapNode []*Node // 引导节点，全网目前唯一，以后由基金会提供至少3个，通过MPC管理密钥
-	serverNode    *Node   // 服务节点，由引导节点更新维护，一般情况下，用户只跟一个服务节点打交道
+	bootstrapNode []*Node                // 引导节点，全网目前唯一，以后由基金会提供至少3个，通过MPC管理密钥
+	serverNode    *Node                  // 服务节点，由引导节点更新维护，一般情况下，用户只跟一个服务节点打交道
+	peerNodes     map[string]*Node       // 其他服务节点，可以同时跟多个服务节点建立通道，key: nodeId hex
+	peerStatus    map[string]*peerStatus // key: nodeId hex，包括默认服务节点
 
 	utxoLockerL1 *UtxoLocker
 	utxoLockerL2 *UtxoLocker
@@ -135,12 +149,38 @@ type Manager struct {
 	managedDataMu        sync.RWMutex
 	managedDataProviders map[string]AccountManagedDataProvider
 
-	feeRateL1             int64 // sat/vkb
-	refreshTimeL1         int64
-	feeRateL2             int64 // sat/vkb
-	refreshTimeL2         int64
-	serverOnline          bool
-	refreshTimeServerNode int64
+	coinMutex     sync.RWMutex
+	coinSelection string // 选币策略，空表示使用配置
+
+	autoConsolidation     *AutoConsolidation // 自动合并utxo，nil 表示使用配置
+	consolidateLastHeight int
+
+	labelMutex sync.RWMutex
+	labels     map[string]*Label // BIP329 标签，key: type-ref，nil 表示还没有从数据库加载
+
+	activityMutex    sync.Mutex
+	activities       map[string]*ActivityItem // 本地交易记录，key: layer-address-txid，nil 表示还没有从数据库加载
+	activityScanTime [2]int64                 // 上次检查的时间，0：一层，1：二层
+
+	liquidityMutex     sync.Mutex
+	liquidityPolicies  map[string]*LiquidityPolicy // key: assetName，nil 表示还没有从数据库加载
+	liquidityDecisions []*LiquidityDecision        // 按时间排序
+	liquidityLastCheck map[string]int64            // key: assetName-layer
+	liquiditySplicing  bool                        // 正在后台执行拼接
+
+	payoutMutex   sync.Mutex
+	payoutRunning [2]bool // 正在后台执行批量发送，0：一层，1：二层
+
+	scbMutex   sync.Mutex
+	scbHandler StaticChannelBackupHandler
+	scbEntries map[string]*StaticChannelBackup // 静态通道备份，key: channelId，nil 表示还没有从数据库加载
+
+	feeMutex       sync.Mutex
+	feeEstimator   FeeEstimator
+	feeEstimatesL1 *FeeEstimates // sat/vB
+	refreshTimeL1  int64
+	feeRateL2      int64 // sat/vkb
+	refreshTimeL2  int64
 
 	inscibeMap map[int64]*InscribeResv // key: timestamp
 
@@ -151,6 +191,8 @@ type Manager struct {
 
 	localActionPerformMap  map[int64]Reservation
 	remoteActionPerformMap map[int64]Reservation
+	payoutMap              map[int64]*PayoutReservation
+	scheduleMap            map[int64]*ScheduledSendReservation
 	resvMap                map[int64]Reservation
 
 	nodeMap    map[string]string   // key: peer address + local address -> channelId
@@ -166,6 +208,11 @@ type Manager struct {
 	btcLuckyMiner        *btclucky.Miner
 	btcLuckyLastL1Height int
 	btcLuckyRewardAddr   string
+
+	signerListener net.Listener // 作为外部签名器对外提供服务
+
+	unlockMutex sync.Mutex
+	unlockState *UnlockState // 连续解锁失败的状态
 }
 
 func (p *Manager) init() error {
@@ -185,6 +232,7 @@ func (p *Manager) init() error {
 		Log.Errorf("initDB failed. %v", err)
 		return err
 	}
+	p.loadPeerNodes()
 
 	p.watchTower = NewWatchTower(p)
 	p.bInited = true
@@ -283,31 +331,7 @@ func (p *Manager) initNode() error {
 			return fmt.Errorf("invalid AddPeers config item: %s", n)
 		}
 
-		var scheme, host, proxy string
-		// http://host[:port]/stp/testnet
-		if strings.HasPrefix(parts[2], "http://") {
-			scheme = "http"
-			host = strings.TrimPrefix(parts[2], "http://")
-		} else if strings.HasPrefix(parts[2], "https://") {
-			scheme = "https"
-			host = strings.TrimPrefix(parts[2], "https://")
-		} else {
-			scheme = "http"
-			host = parts[2]
-		}
-		if strings.HasSuffix(host, "/stp/testnet") {
-			host = strings.TrimSuffix(host, "/stp/testnet")
-			proxy = "stp/testnet"
-		} else if strings.HasSuffix(host, "/stp/mainnet") {
-			host = strings.TrimSuffix(host, "/stp/mainnet")
-			proxy = "stp/mainnet"
-		} else {
-			h, p, bfound := strings.Cut(host, "/")
-			host = h
-			if bfound {
-				proxy = p
-			}
-		}
+		scheme, host, proxy := parseNodeUrl(parts[2])
 
 		switch parts[0] {
 		case "b":
@@ -319,10 +343,15 @@ func (p *Manager) initNode() error {
 			}
 
 		case "s":
-			if p.serverNode != nil {
-				return fmt.Errorf("too many server node setting")
+			// 第一个是默认服务节点，其他的作为可选的通道节点
+			node := NewNode(NewNodeClient(scheme, host, proxy, p.http), host, SERVER_NODE, parsedPubkey, parsedPubkey)
+			if p.serverNode == nil {
+				p.serverNode = node
+			} else if p.serverNode.NodeId.IsEqual(parsedPubkey) {
+				return fmt.Errorf("duplicated server node setting %s", parts[1])
+			} else {
+				p.addPeerNode(node)
 			}
-			p.serverNode = NewNode(NewNodeClient(scheme, host, proxy, p.http), host, SERVER_NODE, parsedPubkey, parsedPubkey)
 		default:
 			Log.Errorf("not support type %s", n)
 		}
@@ -352,6 +381,34 @@ func (p *Manager) initNode() error {
 	return nil
 }
 
+// http://host[:port]/stp/testnet
+func parseNodeUrl(url string) (scheme, host, proxy string) {
+	if strings.HasPrefix(url, "http://") {
+		scheme = "http"
+		host = strings.TrimPrefix(url, "http://")
+	} else if strings.HasPrefix(url, "https://") {
+		scheme = "https"
+		host = strings.TrimPrefix(url, "https://")
+	} else {
+		scheme = "http"
+		host = url
+	}
+	if strings.HasSuffix(host, "/stp/testnet") {
+		host = strings.TrimSuffix(host, "/stp/testnet")
+		proxy = "stp/testnet"
+	} else if strings.HasSuffix(host, "/stp/mainnet") {
+		host = strings.TrimSuffix(host, "/stp/mainnet")
+		proxy = "stp/mainnet"
+	} else {
+		h, p, bfound := strings.Cut(host, "/")
+		host = h
+		if bfound {
+			proxy = p
+		}
+	}
+	return
+}
+
 func (p *Manager) checkSelf() error {
 
 	return nil
@@ -382,11 +439,7 @@ func (p *Manager) CheckSuperNodeStatus() bool {
 }
 
 func (p *Manager) ServerIsBootstrapNode() bool {
-	if p.serverNode == nil || p.serverNode.Pubkey == nil {
-		return false
-	}
-	pubkey := p.serverNode.Pubkey
-	return hex.EncodeToString(pubkey.SerializeCompressed()) == indexer.GetBootstrapPubKey()
+	return p.isBootstrapPeer(p.serverNode)
 }
 
 func (p *Manager) HasStaked(pubkey []byte) bool {
@@ -416,14 +469,12 @@ func (p *Manager) HasStaked(pubkey []byte) bool {
 	return value >= amt
 }
 
-func (p *Manager) IsPeerOnline(_ []byte) bool {
-	now := time.Now().Unix()
-	if now-p.refreshTimeServerNode > 3*60 {
-		err := p.serverNode.client.SendActionResultNfty(0, "", 0, "")
-		p.serverOnline = err == nil
-		p.refreshTimeServerNode = now
+func (p *Manager) IsPeerOnline(nodeId []byte) bool {
+	node := p.getPeerNode(nodeId)
+	if node == nil {
+		return false
 	}
-	return p.serverOnline
+	return p.isNodeOnline(node, false)
 }
 
 func (p *Manager) initResvMap() {
@@ -449,6 +500,8 @@ func (p *Manager) resetResvMapsLocked() {
 	p.splicingChannelMap = make(map[int64]*SplicingReservation)
 	p.localActionPerformMap = make(map[int64]Reservation)
 	p.remoteActionPerformMap = make(map[int64]Reservation)
+	p.payoutMap = make(map[int64]*PayoutReservation)
+	p.scheduleMap = make(map[int64]*ScheduledSendReservation)
 	p.resvMap = make(map[int64]Reservation)
 }
 
@@ -577,6 +630,10 @@ func (p *Manager) addResvLocked(r Reservation) {
 		p.localActionPerformMap[id] = r
 	case RESV_TYPE_REMOTEACTION:
 		p.remoteActionPerformMap[id] = r
+	case RESV_TYPE_PAYOUT:
+		p.payoutMap[id] = r.(*PayoutReservation)
+	case RESV_TYPE_SCHEDULE:
+		p.scheduleMap[id] = r.(*ScheduledSendReservation)
 	}
 }
 
@@ -603,24 +660,16 @@ func (p *Manager) DelResvWithId(id int64) {
 		delete(p.localActionPerformMap, id)
 	case RESV_TYPE_REMOTEACTION:
 		delete(p.remoteActionPerformMap, id)
+	case RESV_TYPE_PAYOUT:
+		delete(p.payoutMap, id)
+	case RESV_TYPE_SCHEDULE:
+		delete(p.scheduleMap, id)
 	}
 }
 
+// L1 默认（normal）模式的费率
 func (p *Manager) GetFeeRate() int64 {
-	if IsTestNet() {
-		return 1
-	}
-	now := time.Now().Unix()
-	if now-p.refreshTimeL1 > 3*60 {
-		fr := p.l1IndexerClient.GetFeeRate()
-		if fr == 0 {
-			fr = 10
-		} else {
-			p.refreshTimeL1 = now
-		}
-		p.feeRateL1 = fr
-	}
-	return p.feeRateL1
+	return p.GetFeeEstimates().Normal
 }
 
 // L2 费率，计算出来的fee再除以10
@@ -916,21 +965,32 @@ func (p *Manager) GetUtxosWithAssetV2_SatsNet(address string, plainSats int64,
 	var totalAssets *Decimal
 	var totalPlainSats int64
 	p.utxoLockerL2.Reload(address)
-	for _, u := range utxos {
-		if _, ok := excludedUtxoMap[u.OutPoint]; ok {
-			continue
-		}
-		if p.utxoLockerL2.IsLocked(u.OutPoint) {
-			continue
+	// 先按照选币策略选择资产，找不到合适的组合时使用下面的规则，白聪不够时在后面补充
+	candidates, utxoMap := p.assetCoinCandidates_SatsNet(utxos, excludedUtxoMap, assetName, expectedAssetAmt)
+	if coins, _ := p.selectAssetCoins(candidates, expectedAssetAmt, true, nil); len(coins) != 0 {
+		for _, coin := range coins {
+			output := utxoMap[coin.OutPoint]
+			totalPlainSats += output.GetPlainSat()
+			totalAssets = totalAssets.Add(output.GetAsset(assetName))
+			resultAssets = append(resultAssets, output.OutPointStr)
 		}
-		output := OutputInfoToOutput_SatsNet(u)
-		totalPlainSats += output.GetPlainSat()
-		num := output.GetAsset(assetName)
-		totalAssets = totalAssets.Add(num)
+	} else {
+		for _, u := range utxos {
+			if _, ok := excludedUtxoMap[u.OutPoint]; ok {
+				continue
+			}
+			if p.utxoLockerL2.IsLocked(u.OutPoint) {
+				continue
+			}
+			output := OutputInfoToOutput_SatsNet(u)
+			totalPlainSats += output.GetPlainSat()
+			num := output.GetAsset(assetName)
+			totalAssets = totalAssets.Add(num)
 
-		resultAssets = append(resultAssets, output.OutPointStr)
-		if totalAssets.Cmp(expectedAssetAmt) >= 0 && totalPlainSats >= plainSats {
-			break
+			resultAssets = append(resultAssets, output.OutPointStr)
+			if totalAssets.Cmp(expectedAssetAmt) >= 0 && totalPlainSats >= plainSats {
+				break
+			}
 		}
 	}
 	if totalAssets.Cmp(expectedAssetAmt) < 0 {
@@ -1086,16 +1146,26 @@ func (p *Manager) GetAssetBalance(address string, name *swire.AssetName) *Decima
 		return balance
 	}
 
-	assets := p.l1IndexerClient.GetAssetSummaryWithAddress(address)
-	if assets != nil {
+	var result *Decimal
+	addresses := []string{address}
+	// 找零地址上的资产也属于这个子账户
+	if changeAddr := p.changeAddressOf(address); changeAddr != "" {
+		addresses = append(addresses, changeAddr)
+	}
+	for _, addr := range addresses {
+		assets := p.l1IndexerClient.GetAssetSummaryWithAddress(addr)
+		if assets == nil {
+			continue
+		}
 		for _, u := range assets.Data {
 			if u.Name == *name {
-				return u.Amount.Clone()
+				result = result.Add(u.Amount.Clone())
+				break
 			}
 		}
 	}
 
-	return nil
+	return result
 }
 
 func (p *Manager) GetAssetBalance_SatsNet(address string, name *swire.AssetName) *Decimal {
@@ -1127,6 +1197,7 @@ func (p *Manager) BroadcastTx(tx *wire.MsgTx) (string, error) {
 	}
 	p.utxoLockerL1.LockUtxosWithTx(tx)
 	// tx确认后自动解锁
+	p.onTxBroadcasted(tx)
 	return txId, nil
 }
 
@@ -1143,6 +1214,7 @@ func (p *Manager) BroadcastTxs(txs []*wire.MsgTx) error {
 	for _, tx := range txs {
 		p.utxoLockerL1.LockUtxosWithTx(tx)
 		// tx确认后自动解锁
+		p.onTxBroadcasted(tx)
 	}
 	return nil
 }
@@ -1158,6 +1230,7 @@ func (p *Manager) BroadcastTx_SatsNet(tx *swire.MsgTx) (string, error) {
 		return "", err
 	}
 	p.utxoLockerL2.LockUtxosWithTx_SatsNet(tx)
+	p.onTxBroadcasted_SatsNet(tx)
 	return txId, nil
 }
 
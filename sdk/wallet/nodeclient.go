@@ -10,6 +10,7 @@ import (
 
 type NodeRPCClient interface {
 	GetNodeInfoReq(nodeId string) (*Node, error)
+	GetServerNodesReq() ([]*Node, error)
 	GetSupportedContractsReq() ([]string, error)
 	GetDeployedContractsReq() ([]string, error)
 	GetContractStatusReq(string) (string, error)
@@ -55,6 +56,9 @@ type NodeRPCClient interface {
 	SendChannelRecoverPaymentReq(req *wwire.RecoverPaymentRequireReq) (*wwire.RecoverPaymentRequireResp, error)
 	SendChannelRecoverPaymentCommitSigReq(req *wwire.RecoverPaymentCommitSigReq) (*wwire.RecoverPaymentCommitSigResp, error)
 	SendChannelRecoverPaymentRevokeAndAckReq(req *wwire.RecoverPaymentRevokeAndAckReq) (*wwire.RecoverPaymentRevokeAndAckResp, error)
+	SendChannelHtlcUpdateReq(req *wwire.HtlcUpdateReq) (*wwire.HtlcUpdateResp, error)
+	SendChannelHtlcCommitSigReq(req *wwire.HtlcCommitSigReq) (*wwire.HtlcCommitSigResp, error)
+	SendChannelHtlcRevokeAndAckReq(req *wwire.HtlcRevokeAndAckReq) (*wwire.HtlcRevokeAndAckResp, error)
 	SendRecoverPaymentReq(info *PaymentReservation) error
 	SendRecoverPaymentCommitSigReq(info *PaymentReservation) error
 	SendRecoverPaymentRevokeAndAckReq(info *PaymentReservation) error
@@ -92,6 +96,11 @@ type nodeInfoResp struct {
 	Node *nodeInfo `json:"node"`
 }
 
+type serverNodesResp struct {
+	BaseResp
+	Nodes []*nodeInfo `json:"nodes"`
+}
+
 type nodeInfo struct {
 	NodeType string `json:"nodeType"`
 	NodeId   string `json:"nodeId"`
@@ -134,24 +143,57 @@ func (p *NodeClient) GetNodeInfoReq(nodeId string) (*Node, error) {
 		return nil, fmt.Errorf("empty node info %s", nodeId)
 	}
 
+	return convertNodeInfo(result.Node), nil
+}
+
+func convertNodeInfo(info *nodeInfo) *Node {
 	node := &Node{
-		NodeType: result.Node.NodeType,
-		Host:     result.Node.Host,
+		NodeType: info.NodeType,
+		Host:     info.Host,
 	}
-	if result.Node.NodeId != "" {
-		nodeIdKey, err := utils.ParsePubkey(result.Node.NodeId)
+	if info.NodeId != "" {
+		nodeIdKey, err := utils.ParsePubkey(info.NodeId)
 		if err == nil {
 			node.NodeId = nodeIdKey
 		}
 	}
-	if result.Node.PubKey != "" {
-		pubKey, err := utils.ParsePubkey(result.Node.PubKey)
+	if info.PubKey != "" {
+		pubKey, err := utils.ParsePubkey(info.PubKey)
 		if err == nil {
 			node.Pubkey = pubKey
 		}
 	}
 
-	return node, nil
+	return node
+}
+
+// 从引导节点获取当前可用的服务节点
+func (p *NodeClient) GetServerNodesReq() ([]*Node, error) {
+	url := p.GetUrl("/info/nodes/server")
+	rsp, err := p.Http.SendGetRequest(url)
+	if err != nil {
+		Log.Errorf("SendGetRequest %v failed. %v", url, err)
+		return nil, err
+	}
+
+	var result serverNodesResp
+	if err := json.Unmarshal(rsp, &result); err != nil {
+		Log.Errorf("Unmarshal failed. %v\n%s", err, string(rsp))
+		return nil, err
+	}
+	if result.Code != 0 {
+		Log.Errorf("%v response message %s", url, result.Msg)
+		return nil, fmt.Errorf("%s", result.Msg)
+	}
+
+	nodes := make([]*Node, 0, len(result.Nodes))
+	for _, n := range result.Nodes {
+		if n == nil {
+			continue
+		}
+		nodes = append(nodes, convertNodeInfo(n))
+	}
+	return nodes, nil
 }
 
 func (p *NodeClient) GetSupportedContractsReq() ([]string, error) {
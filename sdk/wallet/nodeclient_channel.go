@@ -127,6 +127,24 @@ func (p *NodeClient) SendChannelRecoverPaymentRevokeAndAckReq(req *wwire.Recover
 	return &resp, err
 }
 
+func (p *NodeClient) SendChannelHtlcUpdateReq(req *wwire.HtlcUpdateReq) (*wwire.HtlcUpdateResp, error) {
+	var resp wwire.HtlcUpdateResp
+	err := p.postChannelProtocolReq(wwire.STP_HTLC_UPDATE_REQ, req, &resp, "SendChannelHtlcUpdateReq")
+	return &resp, err
+}
+
+func (p *NodeClient) SendChannelHtlcCommitSigReq(req *wwire.HtlcCommitSigReq) (*wwire.HtlcCommitSigResp, error) {
+	var resp wwire.HtlcCommitSigResp
+	err := p.postChannelProtocolReq(wwire.STP_HTLC_UPDATE_COMMITSIG, req, &resp, "SendChannelHtlcCommitSigReq")
+	return &resp, err
+}
+
+func (p *NodeClient) SendChannelHtlcRevokeAndAckReq(req *wwire.HtlcRevokeAndAckReq) (*wwire.HtlcRevokeAndAckResp, error) {
+	var resp wwire.HtlcRevokeAndAckResp
+	err := p.postChannelProtocolReq(wwire.STP_HTLC_UPDATE_REVOKEANDACK, req, &resp, "SendChannelHtlcRevokeAndAckReq")
+	return &resp, err
+}
+
 func (p *NodeClient) SendChannelSplicingInReq(req *wwire.SplicingInReq) (*wwire.SplicingInResp, error) {
 	var resp wwire.SplicingInResp
 	err := p.postChannelProtocolReq(wwire.STP_SPLICING_IN_REQ, req, &resp, "SendChannelSplicingInReq")
@@ -69,6 +69,9 @@ func (p *Manager) inscribe(req *InscriptionRequest) (*InscribeResv, error) {
 			return nil, err
 		}
 		Log.Infof("reveal broadcasted, txid: %s", inscribe.RevealTx.TxID())
+		for _, tx := range txs {
+			p.annotateActivity(false, tx.TxID(), "", "inscribe", "")
+		}
 	} else {
 		// 不广播，外部需要锁定输入的utxo，否则该inscribe会无效
 	}
@@ -90,8 +93,9 @@ func (p *Manager) DeployTicker_ordx(ticker string, max, lim int64, n int, feeRat
 	wallet := p.wallet
 	address := wallet.GetAddress()
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 	// 经验数据，调整 CONTENT_DEPLOY_BODY 后需要调整
 	// estimatedInputValue1 := 340*feeRate + 330
@@ -178,8 +182,9 @@ func (p *Manager) MintAsset_ordx(destAddr string, tickInfo *indexer.TickerInfo,
 	if revealOutValue < 330 {
 		revealOutValue = 330
 	}
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err = p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 	// 经验数据，调整 CONTENT_MINT_BODY 后需要调整
 	// estimatedInputValue1 := 310*feeRate + revealOutValue
@@ -395,8 +400,9 @@ func (p *Manager) InscribeMultiKeyValueInName(name string, kv map[string]string,
 	wallet := p.wallet
 	address := wallet.GetAddress()
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 	utxos := p.l1IndexerClient.GetUtxoListWithTicker(address, &indexer.ASSET_PLAIN_SAT)
 	if len(utxos) == 0 {
@@ -14,6 +14,9 @@ const (
 	RESV_TYPE_INSC         = "insc"
 	RESV_TYPE_LOCALACTION  = "localaction"
 	RESV_TYPE_REMOTEACTION = "remoteaction"
+	RESV_TYPE_PAYOUT       = "payout"
+	RESV_TYPE_SCHEDULE     = "schedule"
+	RESV_TYPE_HTLC         = "htlc"
 )
 
 type Reservation interface {
@@ -163,6 +166,14 @@ func newResvFromType(typ string) Reservation {
 		}
 	case RESV_TYPE_INSC:
 		return &InscribeResv{}
+	case RESV_TYPE_PAYOUT:
+		return &PayoutReservation{
+			ReservationBase: ReservationBase{mutex: new(sync.RWMutex)},
+		}
+	case RESV_TYPE_SCHEDULE:
+		return &ScheduledSendReservation{
+			ReservationBase: ReservationBase{mutex: new(sync.RWMutex)},
+		}
 	default:
 		return nil
 	}
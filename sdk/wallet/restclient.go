@@ -772,6 +772,42 @@ func (p *IndexerClient) GetFeeRate() int64 {
 	return ir
 }
 
+// 费率统计中的所有费率，sat/vb
+func (p *IndexerClient) GetFeeRates() ([]int64, error) {
+	url := p.GetUrl("/btc/fee/summary")
+	rsp, err := p.Http.SendGetRequest(url)
+	if err != nil {
+		Log.Errorf("SendGetRequest %v failed. %v", url, err)
+		return nil, err
+	}
+
+	var result indexerwire.FeeSummaryResp
+	if err := json.Unmarshal(rsp, &result); err != nil {
+		Log.Errorf("Unmarshal failed. %v\n%s", err, string(rsp))
+		return nil, err
+	}
+
+	if result.Code != 0 {
+		Log.Errorf("GetFeeRates response message %s", result.Msg)
+		return nil, fmt.Errorf("%s", result.Msg)
+	}
+
+	rates := make([]int64, 0, len(result.Data.List))
+	for _, item := range result.Data.List {
+		fr, err := strconv.ParseFloat(item.FeeRate, 64)
+		if err != nil {
+			Log.Errorf("ParseFloat %s failed. %v", item.FeeRate, err)
+			continue
+		}
+		ir := int64(fr)
+		if ir == 0 {
+			ir = 1
+		}
+		rates = append(rates, ir)
+	}
+	return rates, nil
+}
+
 func (p *IndexerClient) GetExistingUtxos(utxos []string) ([]string, error) {
 	req := indexerwire.UtxosReq{
 		Utxos: utxos,
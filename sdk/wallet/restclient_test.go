@@ -2532,6 +2532,10 @@ func (p *TestNodeClient) GetNodeInfoReq(nodeId string) (*Node, error) {
 	return node, nil
 }
 
+func (p *TestNodeClient) GetServerNodesReq() ([]*Node, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (p *TestNodeClient) GetSupportedContractsReq() ([]string, error) {
 	//return GetSupportedContracts(), nil
 	return nil, fmt.Errorf("not implemented")
@@ -2846,6 +2850,18 @@ func (p *TestNodeClient) SendChannelRecoverPaymentRevokeAndAckReq(req *wwire.Rec
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (p *TestNodeClient) SendChannelHtlcUpdateReq(req *wwire.HtlcUpdateReq) (*wwire.HtlcUpdateResp, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *TestNodeClient) SendChannelHtlcCommitSigReq(req *wwire.HtlcCommitSigReq) (*wwire.HtlcCommitSigResp, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *TestNodeClient) SendChannelHtlcRevokeAndAckReq(req *wwire.HtlcRevokeAndAckReq) (*wwire.HtlcRevokeAndAckResp, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (p *TestNodeClient) SendChannelSplicingInReq(req *wwire.SplicingInReq) (*wwire.SplicingInResp, error) {
 	return nil, fmt.Errorf("not implemented")
 }
@@ -376,6 +376,13 @@ func (p *IndexerRPCClientMgr) GetUnusableUtxosWithAddress(address string) ([]*Tx
 func (p *IndexerRPCClientMgr) GetFeeRate() int64 {
 	return p.getActiveIndexer().GetFeeRate()
 }
+func (p *IndexerRPCClientMgr) GetFeeRates() ([]int64, error) {
+	client, ok := p.getActiveIndexer().(interface{ GetFeeRates() ([]int64, error) })
+	if !ok {
+		return nil, fmt.Errorf("indexer does not support fee rates")
+	}
+	return client.GetFeeRates()
+}
 func (p *IndexerRPCClientMgr) GetExistingUtxos(utxos []string) ([]string, error) {
 	result, err := p.getActiveIndexer().GetExistingUtxos(utxos)
 	if shouldSwitchIndexer(err) {
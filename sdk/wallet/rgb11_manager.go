@@ -3225,7 +3225,7 @@ func (p *rgb11Manager) signRGB11PSBT(tx *wire.MsgTx, prevFetcher txscript.PrevOu
 	contractID, transitionID [32]byte, transition []byte, bundleID [32]byte,
 	mpcProof anchors.MPCProof, mpcCommitment [32]byte,
 	inputs []operations.TransitionInput, signingKeys map[int]RGB11InputSigningKey) (*psbt.Packet, *wire.MsgTx, []byte, error) {
-	packet, err := CreatePsbt(tx, prevFetcher, nil)
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		return nil, nil, nil, err
 	}
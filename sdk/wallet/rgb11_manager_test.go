@@ -184,7 +184,7 @@ func TestInternalWalletSignsTapretCarrierWithActiveSubaccountKey(t *testing.T) {
 	tx.AddTxOut(wire.NewTxOut(9_000, changeScript))
 	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
 	prevFetcher.AddPrevOut(previousOutpoint, wire.NewTxOut(10_000, carrierScript))
-	packet, err := CreatePsbt(tx, prevFetcher, nil)
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -202,7 +202,7 @@ func TestInternalWalletSignsTapretCarrierWithActiveSubaccountKey(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	wrongIndexPacket, err := CreatePsbt(tx, prevFetcher, nil)
+	wrongIndexPacket, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -213,7 +213,7 @@ func TestInternalWalletSignsTapretCarrierWithActiveSubaccountKey(t *testing.T) {
 	}
 
 	wrongRoot := sha256.Sum256([]byte("wrong RGB11 Tapret root"))
-	wrongPacket, err := CreatePsbt(tx, prevFetcher, nil)
+	wrongPacket, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -609,7 +609,7 @@ func TestRGB11SignsInternalCarrierAndActiveFeeInput(t *testing.T) {
 		signingKeys[0].Change != 1 || signingKeys[0].Index != receiveIndex {
 		t.Fatalf("unexpected mixed-key transaction inputs=%d keys=%+v", len(tx.TxIn), signingKeys)
 	}
-	packet, err := CreatePsbt(tx, prevFetcher, nil)
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -687,7 +687,7 @@ func TestRGB11WitnessBuilderSpendsTapretCarrier(t *testing.T) {
 		!bytes.Equal(roots[0].TaprootMerkleRoot, root[:]) {
 		t.Fatalf("Tapret signing roots=%x", roots)
 	}
-	packet, err := CreatePsbt(tx, prevFetcher, nil)
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
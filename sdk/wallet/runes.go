@@ -82,6 +82,20 @@ func DecipherRunePayload(pkScript []byte) ([]runestone.Edict, error) {
 	return result.Runestone.Edicts, nil
 }
 
+// 返回 runestone 的 edicts 和 pointer
+func DecipherRunestone(pkScript []byte) ([]runestone.Edict, *uint32, error) {
+	stone := runestone.Runestone{}
+
+	result, err := stone.DecipherFromPkScript(pkScript)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Runestone == nil {
+		return nil, nil, fmt.Errorf("cenotaph")
+	}
+	return result.Runestone.Edicts, result.Runestone.Pointer, nil
+}
+
 func GenEtching(displayName string, symbol int32, maxSupply int64) (*runestone.Etching, error) {
 	return GenEtchingWithTerms(displayName, symbol, maxSupply, 0, true, 0)
 }
@@ -296,8 +310,9 @@ func (p *Manager) DeployTicker_runesWithTerms(destAddr string, ticker string, sy
 	wallet := p.wallet
 	address := wallet.GetAddress()
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 	etching, err := GenEtchingWithTerms(ticker, symbol, max, limit, selfMint, divisibility)
 	if err != nil {
@@ -349,8 +364,9 @@ func (p *Manager) PrepareDeployTicker_runesWithTerms(destAddr string, ticker str
 	wallet := p.wallet
 	address := wallet.GetAddress()
 
-	if feeRate == 0 {
-		feeRate = p.GetFeeRate()
+	feeRate, err := p.ResolveFeeRate(feeRate)
+	if err != nil {
+		return nil, err
 	}
 	etching, err := GenEtchingWithTerms(ticker, symbol, max, limit, selfMint, divisibility)
 	if err != nil {
@@ -15,12 +15,25 @@ import (
 	swire "github.com/sat20-labs/satoshinet/wire"
 )
 
+// 签名前检查交易（资产，dust，网络费），见 CheckTxPolicy。
+// 钱包自己构造的交易不允许例外，TxPolicyOverride 只用于外部传入的 PSBT
 func (p *Manager) SignTx(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher) (*wire.MsgTx, error) {
-	return SignTxWithWallet(p.wallet, tx, prevFetcher)
+	return p.SignTxWithPolicy(p.wallet, tx, prevFetcher, nil)
+}
+
+// override 只对这次签名有效
+func (p *Manager) SignTxWithPolicy(localWallet common.Wallet, tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
+	override *TxPolicyOverride) (*wire.MsgTx, error) {
+	err := p.CheckTxPolicy(tx, prevFetcher, override, true)
+	if err != nil {
+		Log.Errorf("CheckTxPolicy %s failed, %v", tx.TxID(), err)
+		return nil, err
+	}
+	return SignTxWithWallet(localWallet, tx, prevFetcher)
 }
 
 func SignTxWithWallet(localWallet common.Wallet, tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher) (*wire.MsgTx, error) {
-	packet, err := CreatePsbt(tx, prevFetcher, nil)
+	packet, err := CreatePsbt(tx, prevFetcher, nil, localWallet)
 	if err != nil {
 		Log.Errorf("wallet.CreatePsbt failed, %v", err)
 		return nil, err
@@ -67,7 +80,12 @@ func (p *Manager) SignTxV2(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetche
 
 func (p *Manager) SignTx_SatsNet(tx *swire.MsgTx,
 	prevFetcher stxscript.PrevOutputFetcher) (*swire.MsgTx, error) {
-	return SignTxWithWallet_SatsNet(p.wallet, tx, prevFetcher)
+	allowedBurn, err := p.CheckTxPolicy_SatsNet(tx, prevFetcher, nil, nil)
+	if err != nil {
+		Log.Errorf("CheckTxPolicy_SatsNet %s failed, %v", tx.TxID(), err)
+		return nil, err
+	}
+	return SignTxWithWalletAllowAssetBurn_SatsNet(p.wallet, tx, prevFetcher, allowedBurn)
 }
 
 func (p *Manager) SignContractTx_SatsNet(tx *swire.MsgTx,
@@ -83,6 +101,11 @@ func (p *Manager) SignContractTx_SatsNet(tx *swire.MsgTx,
 			Amount: *indexer.NewDefaultDecimal(gasFeeAmount),
 		}}
 	}
+	allowedBurn, err := p.CheckTxPolicy_SatsNet(tx, prevFetcher, nil, allowedBurn)
+	if err != nil {
+		Log.Errorf("CheckTxPolicy_SatsNet %s failed, %v", tx.TxID(), err)
+		return nil, err
+	}
 	return SignTxWithWalletAllowAssetBurn_SatsNet(p.wallet, tx, prevFetcher, allowedBurn)
 }
 
@@ -207,7 +230,7 @@ func delayedPathWitnessSatsNet(witnessScript []byte) swire.TxWitness {
 
 func PartialSignTxWithWallet(localWallet common.Wallet, tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
 	witnessScript []byte, hasSelectingPath bool, peerPubKey []byte) ([][]byte, error) {
-	packet, err := CreatePsbt(tx, prevFetcher, witnessScript)
+	packet, err := CreatePsbt(tx, prevFetcher, witnessScript, localWallet)
 	if err != nil {
 		Log.Errorf("CreatePsbt failed, %v", err)
 		return nil, err
@@ -330,7 +353,7 @@ func (p *Manager) FinalSignTx(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFet
 func FinalSignTxWithWallet(localWallet common.Wallet, tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
 	witnessScript []byte, hasSelectingPath bool,
 	peerPubKey []byte, peerSigs [][]byte) ([][]byte, error) {
-	packet, err := CreatePsbtWithPeer(tx, prevFetcher, witnessScript, peerPubKey, peerSigs)
+	packet, err := CreatePsbtWithPeer(tx, prevFetcher, witnessScript, peerPubKey, peerSigs, localWallet)
 	if err != nil {
 		Log.Errorf("CreatePsbt failed, %v", err)
 		return nil, err
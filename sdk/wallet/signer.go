@@ -0,0 +1,261 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+)
+
+/*
+外部签名器：Manager 所在的机器不保存私钥，所有签名（psbt、消息、通道承诺交易）都交给签名器完成。
+签名器可以是HSM、离线机器或者另外一个进程，通过本地socket上的JSON-RPC调用，参考 signer_rpc.go。
+LocalSigner 是参考实现，包装 InternalWallet，可以在签名器进程中使用 ServeSigner 对外提供服务，
+签名前按 SignerPolicy 检查，参考 signer_policy.go。
+私钥（包括通道的 revocation 私钥）不离开签名器，惩罚交易也在签名器中签名。
+*/
+
+const (
+	SIGNER_KEY_PAYMENT         = "payment"
+	SIGNER_KEY_REVOCATION_BASE = "revocationBase"
+)
+
+// 签名使用的key
+type SignerKey struct {
+	Index   uint32 // 子账户，对通道钱包而言是通道钱包id
+	Channel bool   // 使用通道钱包的key签名
+	Peer    []byte // 通道对端的node pubkey，只在计算commit secret时使用
+}
+
+type SignerInfo struct {
+	Id         int64  // 钱包id
+	Network    string // chaincfg.Params.Name
+	NodePubKey []byte
+}
+
+type Signer interface {
+	GetInfo() (*SignerInfo, error)
+	GetPubKey(key *SignerKey, keyType string) ([]byte, error)
+	GetAddress(key *SignerKey, addrType string, change uint32) (string, error)
+
+	SignMessage(key *SignerKey, msg []byte) ([]byte, error)
+	SignWalletMessage(key *SignerKey, msg string) ([]byte, error)
+	SignPsbts(key *SignerKey, psbts [][]byte) ([][]byte, error)
+	SignPsbts_SatsNet(key *SignerKey, psbts [][]byte) ([][]byte, error)
+
+	// 通道
+	GetCommitSecret(key *SignerKey, index uint32) ([]byte, error)
+	// 用对端释放的commit secret派生revocation私钥，签名惩罚交易的所有输入
+	SignPunishPsbt(key *SignerKey, commitSecret []byte, psbt []byte) ([]byte, error)
+}
+
+// 参考实现，直接使用内存中的钱包签名
+type LocalSigner struct {
+	wallet *InternalWallet
+	id     int64 // 钱包在数据库中的id
+	policy *SignerPolicy
+}
+
+func NewLocalSigner(wallet *InternalWallet, id int64) *LocalSigner {
+	return &LocalSigner{wallet: wallet, id: id, policy: DefaultSignerPolicy()}
+}
+
+// nil 表示不检查
+func (p *LocalSigner) SetPolicy(policy *SignerPolicy) {
+	p.policy = policy
+}
+
+func (p *LocalSigner) channelWallet(key *SignerKey) *channelWallet {
+	return NewChannelWallet(p.wallet, key.Peer, key.Index)
+}
+
+func (p *LocalSigner) GetInfo() (*SignerInfo, error) {
+	nodePubKey := p.wallet.GetNodePubKey()
+	if nodePubKey == nil {
+		return nil, fmt.Errorf("can't get node pubkey")
+	}
+	return &SignerInfo{
+		Id:         p.id,
+		Network:    p.wallet.netParamsL1.Name,
+		NodePubKey: nodePubKey.SerializeCompressed(),
+	}, nil
+}
+
+func (p *LocalSigner) GetPubKey(key *SignerKey, keyType string) ([]byte, error) {
+	var pubKey *secp256k1.PublicKey
+	switch keyType {
+	case SIGNER_KEY_PAYMENT:
+		if key.Channel {
+			pubKey = p.channelWallet(key).GetPaymentPubKey()
+		} else {
+			pubKey = p.wallet.GetPubKeyByIndex(key.Index)
+		}
+	case SIGNER_KEY_REVOCATION_BASE:
+		pubKey = p.channelWallet(key).GetRevocationBaseKey()
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+	if pubKey == nil {
+		return nil, fmt.Errorf("can't get %s pubkey of %d", keyType, key.Index)
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+func (p *LocalSigner) GetAddress(key *SignerKey, addrType string, change uint32) (string, error) {
+	var address string
+	switch change {
+	case 0:
+		address = p.wallet.GetAddressByType(addrType, key.Index)
+	case 1:
+		if addrType == ADDRESS_TYPE_P2TR {
+			address = p.wallet.GetChangeAddressByIndex(key.Index)
+		}
+	}
+	if address == "" {
+		return "", fmt.Errorf("can't get %s address %d/%d", addrType, change, key.Index)
+	}
+	return address, nil
+}
+
+func (p *LocalSigner) SignMessage(key *SignerKey, msg []byte) ([]byte, error) {
+	if key.Channel {
+		return p.channelWallet(key).SignMessage(msg)
+	}
+	return p.wallet.SignMessageWithIndex(msg, key.Index)
+}
+
+func (p *LocalSigner) SignWalletMessage(key *SignerKey, msg string) ([]byte, error) {
+	if key.Channel {
+		return nil, fmt.Errorf("channel wallet can't sign wallet message")
+	}
+	return p.wallet.SignWalletMessageWithIndex(msg, key.Index)
+}
+
+func (p *LocalSigner) SignPsbts(key *SignerKey, psbts [][]byte) ([][]byte, error) {
+	packets := make([]*psbt.Packet, 0, len(psbts))
+	for _, data := range psbts {
+		packet, err := psbt.NewFromRawBytes(bytes.NewReader(data), false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.policy.CheckPsbt(packet); err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+
+	var err error
+	if key.Channel {
+		err = p.channelWallet(key).SignPsbts(packets)
+	} else {
+		err = p.wallet.SignPsbtsWithIndex(packets, key.Index)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, 0, len(packets))
+	for _, packet := range packets {
+		var buf bytes.Buffer
+		err := packet.Serialize(&buf)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, buf.Bytes())
+	}
+	return result, nil
+}
+
+func (p *LocalSigner) SignPsbts_SatsNet(key *SignerKey, psbts [][]byte) ([][]byte, error) {
+	packets := make([]*spsbt.Packet, 0, len(psbts))
+	for _, data := range psbts {
+		packet, err := spsbt.NewFromRawBytes(bytes.NewReader(data), false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.policy.CheckPsbt_SatsNet(packet); err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+
+	var err error
+	if key.Channel {
+		err = p.channelWallet(key).SignPsbts_SatsNet(packets)
+	} else {
+		err = p.wallet.SignPsbtsWithIndex_SatsNet(packets, key.Index)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, 0, len(packets))
+	for _, packet := range packets {
+		var buf bytes.Buffer
+		err := packet.Serialize(&buf)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, buf.Bytes())
+	}
+	return result, nil
+}
+
+func (p *LocalSigner) GetCommitSecret(key *SignerKey, index uint32) ([]byte, error) {
+	secret := p.channelWallet(key).GetCommitSecret(index)
+	if secret == nil {
+		return nil, fmt.Errorf("can't get commit secret %d", index)
+	}
+	return secret.Serialize(), nil
+}
+
+// 私钥只在这里使用，不返回给调用方
+func (p *LocalSigner) SignPunishPsbt(key *SignerKey, commitSecret []byte, data []byte) ([]byte, error) {
+	if len(commitSecret) != 32 {
+		return nil, fmt.Errorf("invalid commit secret")
+	}
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(data), false)
+	if err != nil {
+		return nil, err
+	}
+	cw := p.channelWallet(key)
+	revPrivKey := cw.DeriveRevocationPrivKey(secp256k1.PrivKeyFromBytes(commitSecret))
+	if revPrivKey == nil {
+		return nil, fmt.Errorf("can't derive revocation key")
+	}
+	recvPkScript, err := GetP2TRpkScript(cw.GetPaymentPubKey())
+	if err != nil {
+		return nil, err
+	}
+	err = p.policy.CheckPunishPsbt(packet, revPrivKey.PubKey().SerializeCompressed(), recvPkScript)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := packet.UnsignedTx
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, txIn := range tx.TxIn {
+		prevFetcher.AddPrevOut(txIn.PreviousOutPoint, packet.Inputs[i].WitnessUtxo)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, prevFetcher)
+	pubKey := revPrivKey.PubKey().SerializeCompressed()
+	for i := range tx.TxIn {
+		input := &packet.Inputs[i]
+		sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, i, input.WitnessUtxo.Value,
+			input.WitnessScript, txscript.SigHashAll, revPrivKey)
+		if err != nil {
+			return nil, err
+		}
+		input.PartialSigs = append(input.PartialSigs, &psbt.PartialSig{PubKey: pubKey, Signature: sig})
+	}
+
+	var buf bytes.Buffer
+	err = packet.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+)
+
+/*
+签名器的签名策略，在签名器进程中检查，调用签名器的机器被攻破时也不能让签名器随意签名。
+签名器看不到资产数据，只检查聪：
+1. psbt 的每个输入都要有 utxo 信息，否则无法计算网络费
+2. 网络费不能超过输入聪的 MaxFeeRatio，也不能超过 MaxFee
+3. L1 输出不能低于 dust
+4. 惩罚交易只能花费包含 revocation 公钥的脚本，并且只能转给自己的通道地址
+*/
+
+type SignerPolicy struct {
+	MaxFeeRatio float64 // 0 使用 DEFAULT_MAX_FEE_RATIO
+	MaxFee      int64   // 单个交易的最大网络费，0 不限制
+	AllowDust   bool
+}
+
+func DefaultSignerPolicy() *SignerPolicy {
+	return &SignerPolicy{MaxFeeRatio: DEFAULT_MAX_FEE_RATIO}
+}
+
+func (p *SignerPolicy) maxFeeRatio() float64 {
+	if p.MaxFeeRatio > 0 {
+		return p.MaxFeeRatio
+	}
+	return DEFAULT_MAX_FEE_RATIO
+}
+
+func (p *SignerPolicy) checkFee(inValue, outValue int64) error {
+	if err := checkFeeRatio(inValue, outValue, p.maxFeeRatio()); err != nil {
+		return err
+	}
+	if p.MaxFee > 0 && inValue-outValue > p.MaxFee {
+		return newTxPolicyError(ErrTxPolicyHighFee, -1, "fee %d is more than %d", inValue-outValue, p.MaxFee)
+	}
+	return nil
+}
+
+func (p *SignerPolicy) CheckPsbt(packet *psbt.Packet) error {
+	if p == nil {
+		return nil
+	}
+	inValue := int64(0)
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo != nil {
+			inValue += input.WitnessUtxo.Value
+			continue
+		}
+		if input.NonWitnessUtxo != nil {
+			index := packet.UnsignedTx.TxIn[i].PreviousOutPoint.Index
+			if int(index) < len(input.NonWitnessUtxo.TxOut) {
+				inValue += input.NonWitnessUtxo.TxOut[index].Value
+				continue
+			}
+		}
+		return fmt.Errorf("signer policy: input %d has no utxo", i)
+	}
+	if !p.AllowDust {
+		if err := checkDustOutputs(packet.UnsignedTx); err != nil {
+			return err
+		}
+	}
+	outValue := int64(0)
+	for _, txOut := range packet.UnsignedTx.TxOut {
+		outValue += txOut.Value
+	}
+	return p.checkFee(inValue, outValue)
+}
+
+func (p *SignerPolicy) CheckPsbt_SatsNet(packet *spsbt.Packet) error {
+	if p == nil {
+		return nil
+	}
+	inValue := int64(0)
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil {
+			return fmt.Errorf("signer policy: input %d has no utxo", i)
+		}
+		inValue += input.WitnessUtxo.Value
+	}
+	outValue := int64(0)
+	for _, txOut := range packet.UnsignedTx.TxOut {
+		outValue += txOut.Value
+	}
+	return p.checkFee(inValue, outValue)
+}
+
+// revPubKey 是签名器派生的 revocation 公钥，recvPkScript 是自己的通道地址
+func (p *SignerPolicy) CheckPunishPsbt(packet *psbt.Packet, revPubKey, recvPkScript []byte) error {
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil || len(input.WitnessScript) == 0 {
+			return fmt.Errorf("signer policy: punish input %d has no utxo or witness script", i)
+		}
+		pkScript, err := utils.WitnessScriptHash(input.WitnessScript)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pkScript, input.WitnessUtxo.PkScript) {
+			return fmt.Errorf("signer policy: punish input %d witness script mismatch", i)
+		}
+		if !bytes.Contains(input.WitnessScript, revPubKey) {
+			return fmt.Errorf("signer policy: punish input %d is not a revocable output", i)
+		}
+	}
+	for i, txOut := range packet.UnsignedTx.TxOut {
+		if !bytes.Equal(txOut.PkScript, recvPkScript) {
+			return fmt.Errorf("signer policy: punish output %d is not sent to the channel wallet", i)
+		}
+	}
+	return p.CheckPsbt(packet)
+}
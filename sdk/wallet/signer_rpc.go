@@ -0,0 +1,323 @@
+package wallet
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+签名器的JSON-RPC协议（net/rpc/jsonrpc，JSON-RPC 1.0），服务名 Signer，例如:
+  {"method":"Signer.SignPsbts","params":[{"Key":{"Index":0},"Psbts":["cHNidP8B..."]}],"id":1}
+[]byte 字段使用base64编码。
+endpoint 格式: unix:///run/sat20/signer.sock，或者直接是unix socket的路径。
+协议本身没有认证，只支持 unix socket，依靠文件权限（0600）控制访问，不支持 tcp。
+socket 先在 0700 的临时目录中创建并设置权限，再改名到 endpoint 指定的路径。
+*/
+
+const SIGNER_SERVICE_NAME = "Signer"
+
+type SignerRequest struct {
+	Key          SignerKey
+	KeyType      string   `json:",omitempty"`
+	AddrType     string   `json:",omitempty"`
+	Change       uint32   `json:",omitempty"`
+	CommitIndex  uint32   `json:",omitempty"`
+	Message      []byte   `json:",omitempty"`
+	Text         string   `json:",omitempty"`
+	Psbts        [][]byte `json:",omitempty"`
+	CommitSecret []byte   `json:",omitempty"`
+}
+
+type SignerReply struct {
+	Info  *SignerInfo `json:",omitempty"`
+	Data  []byte      `json:",omitempty"`
+	Text  string      `json:",omitempty"`
+	Psbts [][]byte    `json:",omitempty"`
+}
+
+// 服务端，把rpc请求转给Signer
+type SignerService struct {
+	signer Signer
+}
+
+func (p *SignerService) GetInfo(req *SignerRequest, reply *SignerReply) error {
+	info, err := p.signer.GetInfo()
+	if err != nil {
+		return err
+	}
+	reply.Info = info
+	return nil
+}
+
+func (p *SignerService) GetPubKey(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Data, err = p.signer.GetPubKey(&req.Key, req.KeyType)
+	return err
+}
+
+func (p *SignerService) GetAddress(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Text, err = p.signer.GetAddress(&req.Key, req.AddrType, req.Change)
+	return err
+}
+
+func (p *SignerService) SignMessage(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Data, err = p.signer.SignMessage(&req.Key, req.Message)
+	return err
+}
+
+func (p *SignerService) SignWalletMessage(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Data, err = p.signer.SignWalletMessage(&req.Key, req.Text)
+	return err
+}
+
+func (p *SignerService) SignPsbts(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Psbts, err = p.signer.SignPsbts(&req.Key, req.Psbts)
+	return err
+}
+
+func (p *SignerService) SignPsbts_SatsNet(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Psbts, err = p.signer.SignPsbts_SatsNet(&req.Key, req.Psbts)
+	return err
+}
+
+func (p *SignerService) GetCommitSecret(req *SignerRequest, reply *SignerReply) error {
+	var err error
+	reply.Data, err = p.signer.GetCommitSecret(&req.Key, req.CommitIndex)
+	return err
+}
+
+func (p *SignerService) SignPunishPsbt(req *SignerRequest, reply *SignerReply) error {
+	if len(req.Psbts) != 1 {
+		return fmt.Errorf("expected one psbt")
+	}
+	data, err := p.signer.SignPunishPsbt(&req.Key, req.CommitSecret, req.Psbts[0])
+	if err != nil {
+		return err
+	}
+	reply.Psbts = [][]byte{data}
+	return nil
+}
+
+func parseSignerEndpoint(endpoint string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(endpoint, "unix://"):
+		return "unix", strings.TrimPrefix(endpoint, "unix://"), nil
+	case strings.Contains(endpoint, "://"):
+		return "", "", fmt.Errorf("unsupported signer endpoint %s", endpoint)
+	case endpoint == "":
+		return "", "", fmt.Errorf("empty signer endpoint")
+	default:
+		return "unix", endpoint, nil
+	}
+}
+
+// 在listener上提供签名服务，直到listener被关闭
+func ServeSigner(listener net.Listener, signer Signer) error {
+	server := rpc.NewServer()
+	err := server.RegisterName(SIGNER_SERVICE_NAME, &SignerService{signer: signer})
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// 关闭时删除改名以后的socket文件
+type signerListener struct {
+	net.Listener
+	path string
+}
+
+func (p *signerListener) Close() error {
+	err := p.Listener.Close()
+	os.Remove(p.path)
+	return err
+}
+
+func listenSigner(endpoint string) (net.Listener, error) {
+	network, address, err := parseSignerEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// 删除上次遗留的socket文件，不能删除其他文件
+	if fi, err := os.Lstat(address); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// 先在只有当前用户可以访问(0700)的临时目录中创建socket，设置好权限(0600)以后再改名到address，
+	// 避免其他用户在创建和修改权限之间连接
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".signer-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmpAddress := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen(network, tmpAddress)
+	if err != nil {
+		Log.Errorf("Listen %s failed. %v", endpoint, err)
+		return nil, err
+	}
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+	if err := os.Chmod(tmpAddress, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpAddress, address); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &signerListener{Listener: listener, path: address}, nil
+}
+
+// 签名器进程使用，监听endpoint并且提供签名服务
+func ListenAndServeSigner(endpoint string, signer Signer) error {
+	listener, err := listenSigner(endpoint)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	Log.Infof("signer is listening on %s", endpoint)
+	return ServeSigner(listener, signer)
+}
+
+// 客户端，通过JSON-RPC调用外部签名器
+type RemoteSigner struct {
+	endpoint string
+	client   *rpc.Client
+}
+
+func DialSigner(endpoint string) (*RemoteSigner, error) {
+	network, address, err := parseSignerEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		Log.Errorf("Dial signer %s failed. %v", endpoint, err)
+		return nil, err
+	}
+	return NewRemoteSigner(endpoint, conn), nil
+}
+
+func NewRemoteSigner(endpoint string, conn net.Conn) *RemoteSigner {
+	return &RemoteSigner{
+		endpoint: endpoint,
+		client:   jsonrpc.NewClient(conn),
+	}
+}
+
+func (p *RemoteSigner) Close() error {
+	return p.client.Close()
+}
+
+func (p *RemoteSigner) call(method string, req *SignerRequest) (*SignerReply, error) {
+	var reply SignerReply
+	err := p.client.Call(SIGNER_SERVICE_NAME+"."+method, req, &reply)
+	if err != nil {
+		Log.Errorf("signer %s %s failed. %v", p.endpoint, method, err)
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (p *RemoteSigner) GetInfo() (*SignerInfo, error) {
+	reply, err := p.call("GetInfo", &SignerRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if reply.Info == nil {
+		return nil, fmt.Errorf("signer returns no info")
+	}
+	return reply.Info, nil
+}
+
+func (p *RemoteSigner) GetPubKey(key *SignerKey, keyType string) ([]byte, error) {
+	reply, err := p.call("GetPubKey", &SignerRequest{Key: *key, KeyType: keyType})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (p *RemoteSigner) GetAddress(key *SignerKey, addrType string, change uint32) (string, error) {
+	reply, err := p.call("GetAddress", &SignerRequest{Key: *key, AddrType: addrType, Change: change})
+	if err != nil {
+		return "", err
+	}
+	return reply.Text, nil
+}
+
+func (p *RemoteSigner) SignMessage(key *SignerKey, msg []byte) ([]byte, error) {
+	reply, err := p.call("SignMessage", &SignerRequest{Key: *key, Message: msg})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (p *RemoteSigner) SignWalletMessage(key *SignerKey, msg string) ([]byte, error) {
+	reply, err := p.call("SignWalletMessage", &SignerRequest{Key: *key, Text: msg})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (p *RemoteSigner) SignPsbts(key *SignerKey, psbts [][]byte) ([][]byte, error) {
+	reply, err := p.call("SignPsbts", &SignerRequest{Key: *key, Psbts: psbts})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Psbts, nil
+}
+
+func (p *RemoteSigner) SignPsbts_SatsNet(key *SignerKey, psbts [][]byte) ([][]byte, error) {
+	reply, err := p.call("SignPsbts_SatsNet", &SignerRequest{Key: *key, Psbts: psbts})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Psbts, nil
+}
+
+func (p *RemoteSigner) GetCommitSecret(key *SignerKey, index uint32) ([]byte, error) {
+	reply, err := p.call("GetCommitSecret", &SignerRequest{Key: *key, CommitIndex: index})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (p *RemoteSigner) SignPunishPsbt(key *SignerKey, commitSecret []byte, psbt []byte) ([]byte, error) {
+	reply, err := p.call("SignPunishPsbt", &SignerRequest{Key: *key, CommitSecret: commitSecret, Psbts: [][]byte{psbt}})
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Psbts) != 1 {
+		return nil, fmt.Errorf("signer returns %d psbts", len(reply.Psbts))
+	}
+	return reply.Psbts[0], nil
+}
@@ -0,0 +1,312 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+// 测试替身，记录每次调用使用的key
+type recordingSigner struct {
+	Signer
+	calls []string
+	keys  []SignerKey
+}
+
+func (p *recordingSigner) record(method string, key *SignerKey) {
+	p.calls = append(p.calls, method)
+	p.keys = append(p.keys, *key)
+}
+
+func (p *recordingSigner) SignMessage(key *SignerKey, msg []byte) ([]byte, error) {
+	p.record("SignMessage", key)
+	return p.Signer.SignMessage(key, msg)
+}
+
+func (p *recordingSigner) SignPsbts(key *SignerKey, psbts [][]byte) ([][]byte, error) {
+	p.record("SignPsbts", key)
+	return p.Signer.SignPsbts(key, psbts)
+}
+
+func (p *recordingSigner) GetCommitSecret(key *SignerKey, index uint32) ([]byte, error) {
+	p.record("GetCommitSecret", key)
+	return p.Signer.GetCommitSecret(key, index)
+}
+
+func newTestSignerPsbt(t *testing.T, address string) (*psbt.Packet, *txscript.MultiPrevOutFetcher) {
+	pkScript, err := GetPkScriptFromAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	outpoint := wire.OutPoint{Index: 1}
+	outpoint.Hash[0] = 1
+	tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+	prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+	tx.AddTxOut(&wire.TxOut{Value: 9000, PkScript: pkScript})
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet, prevFetcher
+}
+
+func TestRemoteSigner(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	local := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	if local == nil {
+		t.Fatal("NewInternalWalletWithMnemonic failed")
+	}
+
+	dir := t.TempDir()
+	endpoint := "unix://" + filepath.Join(dir, "signer.sock")
+	listener, err := listenSigner(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	fi, err := os.Stat(filepath.Join(dir, "signer.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("socket mode %v", fi.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temp dir not removed")
+	}
+	go ServeSigner(listener, NewLocalSigner(local.Clone().(*InternalWallet), 7))
+
+	remote, err := DialSigner(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	wallet, err := NewSignerWallet(remote)
+	if err != nil {
+		t.Fatalf("NewSignerWallet failed. %v", err)
+	}
+
+	if wallet.GetId() != 7 || !wallet.GetNodePubKey().IsEqual(local.GetNodePubKey()) {
+		t.Fatalf("wallet info mismatch")
+	}
+	wallet.SetSubAccount(1)
+	local.SetSubAccount(1)
+	if wallet.GetAddress() != local.GetAddress() ||
+		wallet.GetChangeAddressByIndex(1) != local.GetChangeAddressByIndex(1) ||
+		wallet.GetAddressByType(ADDRESS_TYPE_P2WPKH, 1) != local.GetAddressByType(ADDRESS_TYPE_P2WPKH, 1) {
+		t.Fatalf("address mismatch")
+	}
+	if !wallet.GetPubKey().IsEqual(local.GetPubKey()) ||
+		!wallet.GetRevocationBaseKey().IsEqual(local.GetRevocationBaseKey()) {
+		t.Fatalf("pubkey mismatch")
+	}
+
+	msg := []byte("hello")
+	sig1, err := wallet.SignMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig2, _ := local.SignMessage(msg)
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatalf("message signature mismatch")
+	}
+
+	// psbt 签名后写回原来的packet
+	packet, prevFetcher := newTestSignerPsbt(t, wallet.GetAddress())
+	err = wallet.SignPsbt(packet)
+	if err != nil {
+		t.Fatalf("SignPsbt failed. %v", err)
+	}
+	err = psbt.MaybeFinalizeAll(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := psbt.Extract(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifySignedTx(signedTx, prevFetcher)
+	if err != nil {
+		t.Fatalf("VerifySignedTx failed. %v", err)
+	}
+
+	// 通道
+	peer := local.GetPubKeyByIndex(5).SerializeCompressed()
+	secret := wallet.GetCommitSecret(peer, 3)
+	if secret == nil || !secret.Key.Equals(&local.GetCommitSecret(peer, 3).Key) {
+		t.Fatalf("commit secret mismatch")
+	}
+	if wallet.DeriveRevocationPrivKey(secret) != nil {
+		t.Fatalf("revocation key should not leave the signer")
+	}
+	testSignerPunishTx(t, wallet, local, secret)
+	channelWallet := wallet.CreateChannelWallet(peer, 2)
+	localChannelWallet := local.CreateChannelWallet(peer, 2)
+	if !channelWallet.GetPaymentPubKey().IsEqual(localChannelWallet.GetPaymentPubKey()) {
+		t.Fatalf("channel pubkey mismatch")
+	}
+	sig1, err = channelWallet.SignMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig2, _ = localChannelWallet.SignMessage(msg)
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatalf("channel message signature mismatch")
+	}
+}
+
+func TestSignerWalletDelegation(t *testing.T) {
+	local, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &recordingSigner{Signer: NewLocalSigner(local, 0)}
+	wallet, err := NewSignerWallet(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet.SetSubAccount(4)
+	packet, _ := newTestSignerPsbt(t, wallet.GetAddress())
+	err = wallet.SignPsbt(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wallet.SignMessageWithIndex([]byte("msg"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := local.GetPubKeyByIndex(1).SerializeCompressed()
+	if wallet.GetChannelWallet(9) != nil {
+		t.Fatalf("channel wallet should not exist")
+	}
+	cw := wallet.CreateChannelWallet(peer, 9)
+	if wallet.GetChannelWallet(9) != cw {
+		t.Fatalf("GetChannelWallet failed")
+	}
+	cw.GetCommitSecret(0)
+
+	expected := []struct {
+		method string
+		key    SignerKey
+	}{
+		{"SignPsbts", SignerKey{Index: 4}},
+		{"SignMessage", SignerKey{Index: 7}},
+		{"GetCommitSecret", SignerKey{Index: 9, Channel: true, Peer: peer}},
+	}
+	if len(recorder.calls) != len(expected) {
+		t.Fatalf("calls %v", recorder.calls)
+	}
+	for i, e := range expected {
+		key := recorder.keys[i]
+		if recorder.calls[i] != e.method || key.Index != e.key.Index ||
+			key.Channel != e.key.Channel || !bytes.Equal(key.Peer, e.key.Peer) {
+			t.Fatalf("call %d: %s %v, expected %s %v", i, recorder.calls[i], key, e.method, e.key)
+		}
+	}
+
+	if _, _, err := parseSignerEndpoint("http://127.0.0.1"); err == nil {
+		t.Fatalf("unsupported endpoint should be rejected")
+	}
+	if _, _, err := parseSignerEndpoint("tcp://127.0.0.1:9530"); err == nil {
+		t.Fatalf("tcp endpoint should be rejected")
+	}
+	network, address, err := parseSignerEndpoint("/run/signer.sock")
+	if err != nil || network != "unix" || address != "/run/signer.sock" {
+		t.Fatalf("parseSignerEndpoint failed. %s %s %v", network, address, err)
+	}
+}
+
+// 惩罚交易由签名器派生 revocation 私钥并签名，只能转给自己的通道地址
+func testSignerPunishTx(t *testing.T, wallet *SignerWallet, local *InternalWallet, secret *secp256k1.PrivateKey) {
+	revPubKey := local.DeriveRevocationPrivKey(secret).PubKey()
+	script, err := utils.CommitScriptToSelf2(144, local.GetPubKeyByIndex(3), local.GetPubKeyByIndex(4), revPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := utils.WitnessScriptHash(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPunishTx := func(recvPkScript []byte) (*wire.MsgTx, *txscript.MultiPrevOutFetcher) {
+		tx := wire.NewMsgTx(2)
+		prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+		outpoint := wire.OutPoint{Index: 0}
+		outpoint.Hash[0] = 2
+		tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+		prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+		tx.AddTxOut(&wire.TxOut{Value: 9000, PkScript: recvPkScript})
+		return tx, prevFetcher
+	}
+
+	recvPkScript, err := GetP2TRpkScript(local.GetPaymentPubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, prevFetcher := newPunishTx(recvPkScript)
+	err = wallet.SignPunishTx(secret, tx, prevFetcher, [][]byte{script})
+	if err != nil {
+		t.Fatalf("SignPunishTx failed. %v", err)
+	}
+	err = VerifySignedTx(tx, prevFetcher)
+	if err != nil {
+		t.Fatalf("VerifySignedTx failed. %v", err)
+	}
+
+	otherPkScript, err := GetP2TRpkScript(local.GetPubKeyByIndex(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, prevFetcher = newPunishTx(otherPkScript)
+	if err := wallet.SignPunishTx(secret, tx, prevFetcher, [][]byte{script}); err == nil {
+		t.Fatalf("punish tx to other address should be rejected")
+	}
+}
+
+func TestSignerPolicy(t *testing.T) {
+	local, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewLocalSigner(local, 0)
+	wallet, err := NewSignerWallet(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 网络费超过输入的一半
+	packet, _ := newTestSignerPsbt(t, wallet.GetAddress())
+	packet.Inputs[0].WitnessUtxo.Value = 100000
+	if err := wallet.SignPsbt(packet); !errors.Is(err, ErrTxPolicyHighFee) {
+		t.Fatalf("high fee should be rejected, %v", err)
+	}
+
+	packet, _ = newTestSignerPsbt(t, wallet.GetAddress())
+	packet.Inputs[0].WitnessUtxo = nil
+	if err := wallet.SignPsbt(packet); err == nil {
+		t.Fatalf("input without utxo should be rejected")
+	}
+
+	packet, _ = newTestSignerPsbt(t, wallet.GetAddress())
+	packet.UnsignedTx.TxOut[0].Value = 100
+	if err := wallet.SignPsbt(packet); !errors.Is(err, ErrTxPolicyDust) {
+		t.Fatalf("dust output should be rejected, %v", err)
+	}
+
+	signer.SetPolicy(&SignerPolicy{AllowDust: true})
+	packet, _ = newTestSignerPsbt(t, wallet.GetAddress())
+	packet.UnsignedTx.TxOut[0].Value = 100
+	if err := wallet.SignPsbt(packet); err != nil {
+		t.Fatalf("SignPsbt failed. %v", err)
+	}
+}
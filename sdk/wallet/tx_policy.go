@@ -0,0 +1,460 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	indexer "github.com/sat20-labs/indexer/common"
+	rgb11wallet "github.com/sat20-labs/sat20wallet/sdk/wallet/rgb11"
+	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+	stxscript "github.com/sat20-labs/satoshinet/txscript"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+/*
+签名前的交易检查
+1. 资产：输入中的 ordx/brc20/runes/RGB11 资产必须分配到输出，不能被当作网络费烧掉
+2. dust：L1 输出的聪数量不能低于输出脚本对应的 dust 值，和 bitcoind 的规则相同（P2TR 330，P2WPKH 294，P2PKH 546）
+3. 网络费：网络费不能超过输入聪的一定比例（网络费低于 TX_POLICY_MIN_FEE 时不检查）
+违反规则时返回 *TxPolicyError，调用方明确允许时（每次调用时传入 TxPolicyOverride）才签名。
+TxPolicyOverride 只用于外部传入的 PSBT（SignPsbtWithPolicy 等），钱包自己构造的交易通过 SignTx 签名，不允许例外
+通道的承诺交易等由双方协议校验的交易不经过这里
+*/
+
+const (
+	DUST_LIMIT            = 330 // P2TR 输出的 dust
+	DUST_RELAY_FEE_RATE   = 3   // sat/vB，和 bitcoind 的 -dustrelayfee 相同
+	DEFAULT_MAX_FEE_RATIO = 0.5
+	TX_POLICY_MIN_FEE     = 10000
+)
+
+var (
+	ErrTxPolicyAssetBurn = errors.New("assets would be burned")
+	ErrTxPolicyDust      = errors.New("output is below dust limit")
+	ErrTxPolicyHighFee   = errors.New("fee is too high")
+)
+
+type TxPolicyError struct {
+	Err    error // ErrTxPolicyAssetBurn，ErrTxPolicyDust，ErrTxPolicyHighFee
+	Index  int   // 相关的输出，-1 表示整个交易
+	Detail string
+}
+
+func (e *TxPolicyError) Error() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("tx policy: %v, output %d: %s", e.Err, e.Index, e.Detail)
+	}
+	return fmt.Sprintf("tx policy: %v: %s", e.Err, e.Detail)
+}
+
+func (e *TxPolicyError) Unwrap() error {
+	return e.Err
+}
+
+func newTxPolicyError(err error, index int, format string, args ...any) *TxPolicyError {
+	return &TxPolicyError{Err: err, Index: index, Detail: fmt.Sprintf(format, args...)}
+}
+
+// 调用方明确允许的情况
+type TxPolicyOverride struct {
+	AllowAssetBurn bool `json:"allowAssetBurn"`
+	AllowDust      bool `json:"allowDust"`
+	AllowHighFee   bool `json:"allowHighFee"`
+}
+
+func (p *TxPolicyOverride) allowAssetBurn() bool {
+	return p != nil && p.AllowAssetBurn
+}
+
+func (p *TxPolicyOverride) allowDust() bool {
+	return p != nil && p.AllowDust
+}
+
+func (p *TxPolicyOverride) allowHighFee() bool {
+	return p != nil && p.AllowHighFee
+}
+
+func (p *Manager) getMaxFeeRatio() float64 {
+	if p.cfg != nil && p.cfg.Wallet.MaxFeeRatio > 0 {
+		return p.cfg.Wallet.MaxFeeRatio
+	}
+	return DEFAULT_MAX_FEE_RATIO
+}
+
+func isRunestoneScript(pkScript []byte) bool {
+	return len(pkScript) > 1 && pkScript[0] == txscript.OP_RETURN && pkScript[1] == txscript.OP_13
+}
+
+// 花费这个输出的成本，按照输出加上典型的输入大小计算，隔离见证的签名数据只算1/4
+func dustThreshold(txOut *wire.TxOut) int64 {
+	size := txOut.SerializeSize() + 41
+	if txscript.IsWitnessProgram(txOut.PkScript) {
+		size += 107 / blockchain.WitnessScaleFactor
+	} else {
+		size += 107
+	}
+	return DUST_RELAY_FEE_RATE * int64(size)
+}
+
+func checkDustOutputs(tx *wire.MsgTx) error {
+	for i, txOut := range tx.TxOut {
+		if indexer.IsOpReturn(txOut.PkScript) {
+			continue
+		}
+		if threshold := dustThreshold(txOut); txOut.Value < threshold {
+			return newTxPolicyError(ErrTxPolicyDust, i, "%d sats, dust threshold %d", txOut.Value, threshold)
+		}
+	}
+	return nil
+}
+
+// inValue 为 0 表示不知道输入的聪数量，不检查
+func checkFeeRatio(inValue, outValue int64, maxRatio float64) error {
+	if inValue <= 0 {
+		return nil
+	}
+	fee := inValue - outValue
+	if fee <= TX_POLICY_MIN_FEE {
+		return nil
+	}
+	if float64(fee) > float64(inValue)*maxRatio {
+		return newTxPolicyError(ErrTxPolicyHighFee, -1, "fee %d is more than %.0f%% of input %d",
+			fee, maxRatio*100, inValue)
+	}
+	return nil
+}
+
+// runes 按 runestone 分配，没有 runestone 时全部给第一个非 OP_RETURN 输出
+func checkRunesBurn(tx *wire.MsgTx) error {
+	firstOutput := -1
+	for i, txOut := range tx.TxOut {
+		if !indexer.IsOpReturn(txOut.PkScript) {
+			firstOutput = i
+			break
+		}
+	}
+	if firstOutput < 0 {
+		return newTxPolicyError(ErrTxPolicyAssetBurn, -1, "runes in inputs but no spendable output")
+	}
+
+	for i, txOut := range tx.TxOut {
+		if !isRunestoneScript(txOut.PkScript) {
+			continue
+		}
+		edicts, pointer, err := DecipherRunestone(txOut.PkScript)
+		if err != nil {
+			return newTxPolicyError(ErrTxPolicyAssetBurn, i, "invalid runestone, %v", err)
+		}
+		for _, edict := range edicts {
+			if edict.Amount.IsZero() || int(edict.Output) >= len(tx.TxOut) {
+				continue
+			}
+			if indexer.IsOpReturn(tx.TxOut[edict.Output].PkScript) {
+				return newTxPolicyError(ErrTxPolicyAssetBurn, int(edict.Output),
+					"runes %s are sent to OP_RETURN", edict.ID.String())
+			}
+		}
+		if pointer != nil {
+			if int(*pointer) >= len(tx.TxOut) || indexer.IsOpReturn(tx.TxOut[*pointer].PkScript) {
+				return newTxPolicyError(ErrTxPolicyAssetBurn, int(*pointer), "runestone pointer to OP_RETURN")
+			}
+		}
+		// 只有第一个 runestone 有效
+		break
+	}
+	return nil
+}
+
+// 按聪的顺序把输入的资产分配到输出，剩下的绑定聪的资产会被烧掉
+func checkOrdinalBurn(tx *wire.MsgTx, inputs []*TxOutput) error {
+	var input *TxOutput
+	for _, info := range inputs {
+		if input == nil {
+			input = info.Clone()
+		} else {
+			input.Append(info)
+		}
+	}
+	if input == nil {
+		return nil
+	}
+	for _, txOut := range tx.TxOut {
+		if txOut.Value == 0 {
+			continue
+		}
+		var err error
+		_, input, err = input.Cut(txOut.Value)
+		if err != nil {
+			return err
+		}
+		if input == nil {
+			return nil
+		}
+	}
+	for _, asset := range input.Assets {
+		// runes 不绑定聪，由 checkRunesBurn 检查
+		if indexer.IsPlainAsset(&asset.Name) || asset.BindingSat == 0 || asset.Amount.Sign() == 0 {
+			continue
+		}
+		return newTxPolicyError(ErrTxPolicyAssetBurn, -1, "%s %s is not assigned to any output",
+			asset.Amount.String(), asset.Name.String())
+	}
+	return nil
+}
+
+// 本钱包持有 RGB11 资产的utxo，只能通过 RGB11 的流程花费
+func (p *Manager) rgb11Outpoints() map[string]bool {
+	result := make(map[string]bool)
+	if p.wallet == nil || p.db == nil {
+		return result
+	}
+	account, ok := p.localRGB11AccountForAddress(p.wallet.GetAddress())
+	if !ok {
+		return result
+	}
+	store, err := p.scopedRGB11ProjectionStore(account)
+	if err != nil {
+		return result
+	}
+	outputs, err := store.ListOutputs()
+	if err != nil {
+		return result
+	}
+	for _, output := range outputs {
+		if output == nil {
+			continue
+		}
+		for _, asset := range output.Assets {
+			if asset.Name.Protocol == rgb11wallet.Protocol {
+				result[output.OutPointStr] = true
+				break
+			}
+		}
+	}
+	return result
+}
+
+// 获取输入的资产信息，索引器找不到时（比如还没有广播的父交易的输出）当作白聪
+func (p *Manager) policyInputs(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher) ([]*TxOutput, int64) {
+	inputs := make([]*TxOutput, len(tx.TxIn))
+	inValue := int64(0)
+	for i, txIn := range tx.TxIn {
+		utxo := txIn.PreviousOutPoint.String()
+		var prevOut *wire.TxOut
+		if prevFetcher != nil {
+			prevOut = prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		}
+		var info *TxOutput
+		var err error
+		if p.l1IndexerClient != nil {
+			info, err = p.l1IndexerClient.GetTxOutput(utxo)
+		}
+		if err != nil || info == nil {
+			if prevOut == nil {
+				// 不知道输入的聪数量，无法检查
+				return nil, 0
+			}
+			info = &TxOutput{
+				OutPointStr: utxo,
+				OutValue:    *prevOut,
+			}
+		}
+		inputs[i] = info
+		inValue += info.OutValue.Value
+	}
+	return inputs, inValue
+}
+
+// 签名 L1 交易前的检查，complete 为 false 时交易还会被其他人修改（ANYONECANPAY 等），只检查 dust
+func (p *Manager) CheckTxPolicy(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
+	override *TxPolicyOverride, complete bool) error {
+
+	if !override.allowDust() {
+		if err := checkDustOutputs(tx); err != nil {
+			return err
+		}
+	}
+	if !complete || (override.allowAssetBurn() && override.allowHighFee()) {
+		return nil
+	}
+
+	inputs, inValue := p.policyInputs(tx, prevFetcher)
+	if inputs == nil {
+		return fmt.Errorf("tx policy: can't get info of inputs")
+	}
+
+	if !override.allowAssetBurn() {
+		rgb11Utxos := p.rgb11Outpoints()
+		hasRunes := false
+		for i, input := range inputs {
+			if rgb11Utxos[input.OutPointStr] {
+				return newTxPolicyError(ErrTxPolicyAssetBurn, -1,
+					"input %d carries RGB11 assets", i)
+			}
+			for _, asset := range input.Assets {
+				if asset.Name.Protocol == indexer.PROTOCOL_NAME_RUNES && asset.Amount.Sign() != 0 {
+					hasRunes = true
+				}
+			}
+		}
+		if hasRunes {
+			if err := checkRunesBurn(tx); err != nil {
+				return err
+			}
+		}
+		if err := checkOrdinalBurn(tx, inputs); err != nil {
+			return err
+		}
+	}
+
+	if !override.allowHighFee() {
+		outValue := int64(0)
+		for _, txOut := range tx.TxOut {
+			outValue += txOut.Value
+		}
+		if err := checkFeeRatio(inValue, outValue, p.getMaxFeeRatio()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 二层交易的资产直接在输出中，返回没有分配到输出的资产（会被烧掉）
+func leftoverAssets_SatsNet(tx *swire.MsgTx, prevFetcher stxscript.PrevOutputFetcher) (swire.TxAssets, int64, error) {
+	var inAssets swire.TxAssets
+	inValue := int64(0)
+	for _, txIn := range tx.TxIn {
+		txOut := prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if txOut == nil {
+			return nil, 0, fmt.Errorf("can't find output info for utxo %s", txIn.PreviousOutPoint.String())
+		}
+		inValue += txOut.Value
+		if inAssets == nil {
+			inAssets = txOut.Assets.Clone()
+		} else if err := inAssets.Merge(txOut.Assets); err != nil {
+			return nil, 0, err
+		}
+	}
+	var outAssets swire.TxAssets
+	for _, txOut := range tx.TxOut {
+		if outAssets == nil {
+			outAssets = txOut.Assets.Clone()
+		} else if err := outAssets.Merge(txOut.Assets); err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(inAssets) != 0 {
+		if err := inAssets.Split(outAssets); err != nil {
+			return nil, 0, err
+		}
+	}
+	return inAssets, inValue, nil
+}
+
+// 签名二层交易前的检查，allowedBurn 是调用方已经允许烧掉的资产（比如合约的gas），返回签名时允许烧掉的资产
+func (p *Manager) CheckTxPolicy_SatsNet(tx *swire.MsgTx, prevFetcher stxscript.PrevOutputFetcher,
+	override *TxPolicyOverride, allowedBurn swire.TxAssets) (swire.TxAssets, error) {
+
+	leftover, inValue, err := leftoverAssets_SatsNet(tx, prevFetcher)
+	if err != nil {
+		return nil, err
+	}
+	if len(leftover) != 0 {
+		if override.allowAssetBurn() {
+			allowedBurn = leftover
+		} else {
+			remaining := leftover.Clone()
+			if len(allowedBurn) != 0 {
+				allowed := allowedBurn.Clone()
+				if err := (&allowed).Split(leftover); err == nil {
+					remaining = nil
+				}
+			}
+			for _, asset := range remaining {
+				if asset.Amount.Sign() == 0 {
+					continue
+				}
+				return nil, newTxPolicyError(ErrTxPolicyAssetBurn, -1, "%s %s is not assigned to any output",
+					asset.Amount.String(), asset.Name.String())
+			}
+		}
+	}
+
+	if !override.allowHighFee() {
+		outValue := int64(0)
+		for _, txOut := range tx.TxOut {
+			outValue += txOut.Value
+		}
+		if err := checkFeeRatio(inValue, outValue, p.getMaxFeeRatio()); err != nil {
+			return nil, err
+		}
+	}
+	return allowedBurn, nil
+}
+
+// ANYONECANPAY，SINGLE 和 NONE 签名的交易，其他人还会增加输入或者输出
+func isPartialSigHash(sigHashType uint32) bool {
+	if sigHashType&uint32(txscript.SigHashAnyOneCanPay) != 0 {
+		return true
+	}
+	base := sigHashType & 0x1f
+	return base == uint32(txscript.SigHashNone) || base == uint32(txscript.SigHashSingle)
+}
+
+func psbtIsComplete(packet *psbt.Packet) bool {
+	for _, input := range packet.Inputs {
+		if len(input.FinalScriptWitness) != 0 || len(input.FinalScriptSig) != 0 {
+			continue
+		}
+		if isPartialSigHash(uint32(input.SighashType)) {
+			return false
+		}
+	}
+	return true
+}
+
+func psbtIsComplete_SatsNet(packet *spsbt.Packet) bool {
+	for _, input := range packet.Inputs {
+		if len(input.FinalScriptWitness) != 0 || len(input.FinalScriptSig) != 0 {
+			continue
+		}
+		if isPartialSigHash(uint32(input.SighashType)) {
+			return false
+		}
+	}
+	return true
+}
+
+// 跟对端一起签名的交易（通道中的资产发送等），签名前同样检查
+func (p *Manager) checkCoSignTxPolicy(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher) error {
+	err := p.CheckTxPolicy(tx, prevFetcher, nil, true)
+	if err != nil {
+		Log.Errorf("CheckTxPolicy %s failed, %v", tx.TxID(), err)
+	}
+	return err
+}
+
+func (p *Manager) checkCoSignTxPolicy_SatsNet(tx *swire.MsgTx, prevFetcher stxscript.PrevOutputFetcher) error {
+	_, err := p.CheckTxPolicy_SatsNet(tx, prevFetcher, nil, nil)
+	if err != nil {
+		Log.Errorf("CheckTxPolicy_SatsNet %s failed, %v", tx.TxID(), err)
+	}
+	return err
+}
+
+func (p *Manager) checkPsbtPolicy(packet *psbt.Packet, override *TxPolicyOverride) error {
+	return p.CheckTxPolicy(packet.UnsignedTx, PsbtPrevOutputFetcher(packet), override,
+		psbtIsComplete(packet))
+}
+
+func (p *Manager) checkPsbtPolicy_SatsNet(packet *spsbt.Packet, override *TxPolicyOverride) error {
+	if !psbtIsComplete_SatsNet(packet) {
+		return nil
+	}
+	_, err := p.CheckTxPolicy_SatsNet(packet.UnsignedTx, PsbtPrevOutputFetcher_SatsNet(packet), override, nil)
+	return err
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestCheckDustOutputs(t *testing.T) {
+	p2tr := append([]byte{txscript.OP_1, txscript.OP_DATA_32}, make([]byte, 32)...)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(DUST_LIMIT, p2tr))
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN, txscript.OP_13}))
+	if err := checkDustOutputs(tx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tx.AddTxOut(wire.NewTxOut(DUST_LIMIT-1, p2tr))
+	err := checkDustOutputs(tx)
+	if !errors.Is(err, ErrTxPolicyDust) {
+		t.Fatalf("expected dust error, got %v", err)
+	}
+	var policyErr *TxPolicyError
+	if !errors.As(err, &policyErr) || policyErr.Index != 2 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDustThreshold(t *testing.T) {
+	cases := []struct {
+		name     string
+		pkScript []byte
+		expected int64
+	}{
+		{"p2tr", append([]byte{txscript.OP_1, txscript.OP_DATA_32}, make([]byte, 32)...), 330},
+		{"p2wsh", append([]byte{txscript.OP_0, txscript.OP_DATA_32}, make([]byte, 32)...), 330},
+		{"p2wpkh", append([]byte{txscript.OP_0, txscript.OP_DATA_20}, make([]byte, 20)...), 294},
+		{"p2sh", append(append([]byte{txscript.OP_HASH160, txscript.OP_DATA_20}, make([]byte, 20)...),
+			txscript.OP_EQUAL), 540},
+		{"p2pkh", append(append([]byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20},
+			make([]byte, 20)...), txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG), 546},
+	}
+	for _, c := range cases {
+		if got := dustThreshold(wire.NewTxOut(0, c.pkScript)); got != c.expected {
+			t.Fatalf("%s: expected %d, got %d", c.name, c.expected, got)
+		}
+	}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(330, cases[4].pkScript))
+	if err := checkDustOutputs(tx); !errors.Is(err, ErrTxPolicyDust) {
+		t.Fatalf("330 sats p2pkh output should be dust, %v", err)
+	}
+}
+
+func TestCheckFeeRatio(t *testing.T) {
+	// 网络费很少时不检查比例
+	if err := checkFeeRatio(1000, 0, DEFAULT_MAX_FEE_RATIO); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := checkFeeRatio(100000, 60000, DEFAULT_MAX_FEE_RATIO); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := checkFeeRatio(100000, 40000, DEFAULT_MAX_FEE_RATIO); !errors.Is(err, ErrTxPolicyHighFee) {
+		t.Fatalf("expected high fee error, got %v", err)
+	}
+	// 不知道输入
+	if err := checkFeeRatio(0, 40000, DEFAULT_MAX_FEE_RATIO); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestIsPartialSigHash(t *testing.T) {
+	for _, c := range []struct {
+		sigHash txscript.SigHashType
+		partial bool
+	}{
+		{txscript.SigHashDefault, false},
+		{txscript.SigHashAll, false},
+		{txscript.SigHashNone, true},
+		{txscript.SigHashSingle, true},
+		{txscript.SigHashAll | txscript.SigHashAnyOneCanPay, true},
+		{txscript.SigHashSingle | txscript.SigHashAnyOneCanPay, true},
+	} {
+		if isPartialSigHash(uint32(c.sigHash)) != c.partial {
+			t.Fatalf("sighash %x expected %v", c.sigHash, c.partial)
+		}
+	}
+}
+
+func TestTxPolicyOverride(t *testing.T) {
+	p := &Manager{}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(DUST_LIMIT-1, []byte{txscript.OP_1}))
+	override := &TxPolicyOverride{AllowDust: true, AllowAssetBurn: true, AllowHighFee: true}
+	if err := p.CheckTxPolicy(tx, nil, override, true); err != nil {
+		t.Fatalf("override should allow dust, %v", err)
+	}
+	// override 只对那一次调用有效
+	if err := p.CheckTxPolicy(tx, nil, nil, true); !errors.Is(err, ErrTxPolicyDust) {
+		t.Fatalf("dust should be rejected without override, %v", err)
+	}
+	var nilOverride *TxPolicyOverride
+	if nilOverride.allowDust() {
+		t.Fatalf("nil override allows nothing")
+	}
+}
@@ -0,0 +1,334 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+
+	db "github.com/sat20-labs/indexer/common"
+	swire "github.com/sat20-labs/satoshinet/wire"
+)
+
+/*
+本地交易记录
+1. 本钱包广播的交易（包括铭刻，通道和合约调用），广播成功后记录
+2. 索引器扫描到的，属于本钱包的utxo所在的交易
+3. 通道内的支付（lock/unlock）
+每条记录按照本钱包的视角计算资产变化，负数表示转出
+*/
+
+const (
+	ACTIVITY_TX              = "tx"
+	ACTIVITY_CHANNEL_PAYMENT = "channel_payment"
+	ACTIVITY_CONTRACT_INVOKE = "contract_invoke"
+)
+
+const (
+	ACTIVITY_LAYER_L1 = "l1"
+	ACTIVITY_LAYER_L2 = "l2"
+)
+
+const (
+	ACTIVITY_DIRECTION_SEND    = "send"
+	ACTIVITY_DIRECTION_RECEIVE = "receive"
+	ACTIVITY_DIRECTION_SELF    = "self" // 所有输出都回到本钱包
+)
+
+const (
+	ACTIVITY_STATUS_PENDING   = "pending"
+	ACTIVITY_STATUS_CONFIRMED = "confirmed"
+	ACTIVITY_STATUS_DROPPED   = "dropped" // 长时间没有确认，并且索引器找不到这个交易
+)
+
+const (
+	ACTIVITY_DEFAULT_LIMIT    = 20
+	ACTIVITY_SCAN_INTERVAL    = 60        // 秒，检查确认状态和扫描索引器的间隔
+	ACTIVITY_SCAN_MAX_TX      = 20        // 每次扫描最多增加的交易
+	ACTIVITY_DROPPED_INTERVAL = 3 * 86400 // 秒，超过这个时间还没有确认，并且找不到交易，认为已经被丢弃
+)
+
+type AssetDelta struct {
+	AssetName string `json:"assetName"`
+	Amount    string `json:"amount"` // 负数表示转出
+}
+
+type ActivityItem struct {
+	Type           string        `json:"type"`
+	Address        string        `json:"address"` // 本钱包的地址
+	TxId           string        `json:"txId,omitempty"`
+	ResvId         int64         `json:"resvId,omitempty"` // 没有交易的通道支付
+	SatsNet        bool          `json:"satsNet"`
+	Direction      string        `json:"direction"`
+	Action         string        `json:"action,omitempty"` // 产生这个交易的操作，比如 inscribe，splicing-in
+	Ref            string        `json:"ref,omitempty"`    // 通道地址或者合约url
+	Value          int64         `json:"value"`            // 本钱包聪的变化，包括资产占用的聪和网络费
+	Fee            int64         `json:"fee"`              // 只有本钱包支付的交易才有
+	Deltas         []*AssetDelta `json:"deltas,omitempty"`
+	Counterparties []string      `json:"counterparties,omitempty"`
+	Status         string        `json:"status"`
+	Height         int           `json:"height"`
+	CreateTime     int64         `json:"createTime"`
+	ConfirmTime    int64         `json:"confirmTime,omitempty"`
+	Resolved       bool          `json:"-"`               // 一层交易的资产变化需要等索引器处理后才能确定
+	Inputs         []*activityIO `json:"-"`               // 还没有确定时保存输入，确定后清除
+	Label          string        `json:"label,omitempty"` // 查询时填写，不保存
+}
+
+type ActivityFilter struct {
+	Type         string `json:"type,omitempty"`
+	Layer        string `json:"layer,omitempty"`
+	AssetName    string `json:"assetName,omitempty"`
+	Counterparty string `json:"counterparty,omitempty"`
+	Status       string `json:"status,omitempty"`
+	Start        int    `json:"start,omitempty"`
+	Limit        int    `json:"limit,omitempty"`
+}
+
+func activityLayer(satsNet bool) string {
+	if satsNet {
+		return ACTIVITY_LAYER_L2
+	}
+	return ACTIVITY_LAYER_L1
+}
+
+// 同一个交易可能属于同一钱包的多个账户，所以需要加上地址
+func activityKey(address string, satsNet bool, txId string, resvId int64) string {
+	if txId == "" {
+		return fmt.Sprintf("%s-resv-%d", address, resvId)
+	}
+	return activityLayer(satsNet) + "-" + address + "-" + txId
+}
+
+func (p *ActivityItem) key() string {
+	return activityKey(p.Address, p.SatsNet, p.TxId, p.ResvId)
+}
+
+func (p *ActivityItem) Clone() *ActivityItem {
+	result := *p
+	result.Deltas = make([]*AssetDelta, len(p.Deltas))
+	for i, delta := range p.Deltas {
+		d := *delta
+		result.Deltas[i] = &d
+	}
+	result.Counterparties = append([]string(nil), p.Counterparties...)
+	return &result
+}
+
+func (p *ActivityItem) hasAsset(assetName string) bool {
+	for _, delta := range p.Deltas {
+		if delta.AssetName == assetName {
+			return true
+		}
+	}
+	return assetName == ASSET_PLAIN_SAT.String() && p.Value != 0
+}
+
+func (p *ActivityItem) hasCounterparty(address string) bool {
+	if p.Ref == address {
+		return true
+	}
+	for _, counterparty := range p.Counterparties {
+		if counterparty == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ActivityFilter) match(item *ActivityItem) bool {
+	if p.Type != "" && p.Type != item.Type {
+		return false
+	}
+	if p.Layer != "" && p.Layer != activityLayer(item.SatsNet) {
+		return false
+	}
+	if p.Status != "" && p.Status != item.Status {
+		return false
+	}
+	if p.AssetName != "" && !item.hasAsset(p.AssetName) {
+		return false
+	}
+	if p.Counterparty != "" && !item.hasCounterparty(p.Counterparty) {
+		return false
+	}
+	return true
+}
+
+// 按时间倒序过滤和分页，返回这一页的记录和符合条件的总数
+func filterActivities(items []*ActivityItem, filter *ActivityFilter) ([]*ActivityItem, int) {
+	if filter == nil {
+		filter = &ActivityFilter{}
+	}
+	matched := make([]*ActivityItem, 0)
+	for _, item := range items {
+		if filter.match(item) {
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreateTime != matched[j].CreateTime {
+			return matched[i].CreateTime > matched[j].CreateTime
+		}
+		return matched[i].key() < matched[j].key()
+	})
+
+	total := len(matched)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = ACTIVITY_DEFAULT_LIMIT
+	}
+	start := max(filter.Start, 0)
+	if start >= total {
+		return []*ActivityItem{}, total
+	}
+	return matched[start:min(start+limit, total)], total
+}
+
+// 交易的一个输入或者输出，nil 表示找不到输入的信息
+type activityIO struct {
+	Address string
+	Mine    bool
+	Value   int64
+	Assets  swire.TxAssets
+}
+
+// 按本钱包的视角计算交易的资产变化
+func fillActivityDeltas(item *ActivityItem, inputs, outputs []*activityIO) {
+	var inValue, outValue, mineIn, mineOut int64
+	inAssets := make(map[string]*Decimal)
+	outAssets := make(map[string]*Decimal)
+	var names []string
+	addAsset := func(m map[string]*Decimal, assets swire.TxAssets) {
+		for i := range assets {
+			name := assets[i].Name.String()
+			if _, ok := inAssets[name]; !ok {
+				if _, ok := outAssets[name]; !ok {
+					names = append(names, name)
+				}
+			}
+			m[name] = m[name].Add(&assets[i].Amount)
+		}
+	}
+	counterparties := make([]string, 0)
+	addCounterparty := func(address string) {
+		if address == "" {
+			return
+		}
+		for _, c := range counterparties {
+			if c == address {
+				return
+			}
+		}
+		counterparties = append(counterparties, address)
+	}
+
+	allKnown := true
+	hasMineInput := false
+	for _, in := range inputs {
+		if in == nil {
+			allKnown = false
+			continue
+		}
+		inValue += in.Value
+		if in.Mine {
+			hasMineInput = true
+			mineIn += in.Value
+			addAsset(inAssets, in.Assets)
+		}
+	}
+	external := false
+	for _, out := range outputs {
+		outValue += out.Value
+		if out.Mine {
+			mineOut += out.Value
+			addAsset(outAssets, out.Assets)
+		} else if out.Address != "" {
+			external = true
+		}
+	}
+
+	switch {
+	case !hasMineInput:
+		item.Direction = ACTIVITY_DIRECTION_RECEIVE
+		for _, in := range inputs {
+			if in != nil {
+				addCounterparty(in.Address)
+			}
+		}
+	case external:
+		item.Direction = ACTIVITY_DIRECTION_SEND
+		for _, out := range outputs {
+			if !out.Mine {
+				addCounterparty(out.Address)
+			}
+		}
+	default:
+		item.Direction = ACTIVITY_DIRECTION_SELF
+	}
+
+	item.Value = mineOut - mineIn
+	item.Fee = 0
+	if hasMineInput && allKnown && inValue > outValue {
+		item.Fee = inValue - outValue
+	}
+	item.Deltas = make([]*AssetDelta, 0, len(names))
+	for _, name := range names {
+		amount := decimalDelta(inAssets[name], outAssets[name])
+		if amount == "" {
+			continue
+		}
+		item.Deltas = append(item.Deltas, &AssetDelta{AssetName: name, Amount: amount})
+	}
+	item.Counterparties = counterparties
+}
+
+// out - in，为零时返回空
+func decimalDelta(in, out *Decimal) string {
+	if in == nil || in.Sign() == 0 {
+		if out == nil || out.Sign() == 0 {
+			return ""
+		}
+		return out.String()
+	}
+	if out == nil || out.Sign() == 0 {
+		return "-" + in.String()
+	}
+	cmp := out.Cmp(in)
+	if cmp == 0 {
+		return ""
+	}
+	if cmp > 0 {
+		return out.Sub(in).String()
+	}
+	return "-" + in.Sub(out).String()
+}
+
+func GetActivityDBKey(key string) string {
+	return GetDBKeyPrefix() + DB_KEY_TX_HISTORY + key
+}
+
+func saveActivity(kvdb db.KVDB, item *ActivityItem) error {
+	saved := *item
+	saved.Label = ""
+	buf, err := EncodeToBytes(&saved)
+	if err != nil {
+		Log.Errorf("saveActivity EncodeToBytes failed. %v", err)
+		return err
+	}
+	return kvdb.Write([]byte(GetActivityDBKey(item.key())), buf)
+}
+
+func loadAllActivities(kvdb db.KVDB) map[string]*ActivityItem {
+	result := make(map[string]*ActivityItem)
+	prefix := []byte(GetDBKeyPrefix() + DB_KEY_TX_HISTORY)
+	kvdb.BatchRead(prefix, false, func(k, v []byte) error {
+		var item ActivityItem
+		err := DecodeFromBytes(v, &item)
+		if err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", string(k), err)
+			return nil
+		}
+		result[item.key()] = &item
+		return nil
+	})
+	return result
+}
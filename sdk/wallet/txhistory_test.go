@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"testing"
+)
+
+func TestFillActivityDeltas(t *testing.T) {
+	// 发送：输入属于本钱包，一个输出给对方，一个找零
+	item := &ActivityItem{}
+	inputs := []*activityIO{{Address: "me", Mine: true, Value: 10000}}
+	outputs := []*activityIO{
+		{Address: "alice", Value: 3000},
+		{Address: "me", Mine: true, Value: 6800},
+		{Value: 0}, // OP_RETURN
+	}
+	fillActivityDeltas(item, inputs, outputs)
+	if item.Direction != ACTIVITY_DIRECTION_SEND || item.Value != -3200 || item.Fee != 200 {
+		t.Fatalf("unexpected send %+v", item)
+	}
+	if len(item.Counterparties) != 1 || item.Counterparties[0] != "alice" {
+		t.Fatalf("unexpected counterparties %v", item.Counterparties)
+	}
+
+	// 接收：输入不属于本钱包，不知道网络费
+	item = &ActivityItem{}
+	inputs = []*activityIO{{Address: "bob", Value: 5000}, nil}
+	outputs = []*activityIO{{Address: "me", Mine: true, Value: 1000}, {Address: "bob", Value: 3900}}
+	fillActivityDeltas(item, inputs, outputs)
+	if item.Direction != ACTIVITY_DIRECTION_RECEIVE || item.Value != 1000 || item.Fee != 0 {
+		t.Fatalf("unexpected receive %+v", item)
+	}
+	if len(item.Counterparties) != 1 || item.Counterparties[0] != "bob" {
+		t.Fatalf("unexpected counterparties %v", item.Counterparties)
+	}
+
+	// 所有输出都回到本钱包
+	item = &ActivityItem{}
+	inputs = []*activityIO{{Address: "me", Mine: true, Value: 1000}, {Address: "me", Mine: true, Value: 1000}}
+	outputs = []*activityIO{{Address: "me", Mine: true, Value: 1800}}
+	fillActivityDeltas(item, inputs, outputs)
+	if item.Direction != ACTIVITY_DIRECTION_SELF || item.Value != -200 || item.Fee != 200 {
+		t.Fatalf("unexpected self %+v", item)
+	}
+}
+
+func TestFilterActivities(t *testing.T) {
+	items := []*ActivityItem{
+		{Type: ACTIVITY_TX, TxId: "a", CreateTime: 1, Status: ACTIVITY_STATUS_CONFIRMED,
+			Deltas: []*AssetDelta{{AssetName: "ordx:f:test", Amount: "-10"}}},
+		{Type: ACTIVITY_TX, TxId: "b", SatsNet: true, CreateTime: 3, Status: ACTIVITY_STATUS_PENDING,
+			Counterparties: []string{"alice"}},
+		{Type: ACTIVITY_CHANNEL_PAYMENT, ResvId: 1, SatsNet: true, CreateTime: 2,
+			Status: ACTIVITY_STATUS_CONFIRMED, Ref: "channel1"},
+	}
+
+	result, total := filterActivities(items, nil)
+	if total != 3 || result[0].TxId != "b" || result[1].ResvId != 1 || result[2].TxId != "a" {
+		t.Fatalf("unexpected order %v", result)
+	}
+
+	result, total = filterActivities(items, &ActivityFilter{Layer: ACTIVITY_LAYER_L2, Start: 1, Limit: 1})
+	if total != 2 || len(result) != 1 || result[0].ResvId != 1 {
+		t.Fatalf("unexpected page %v", result)
+	}
+
+	tests := []struct {
+		filter ActivityFilter
+		total  int
+	}{
+		{ActivityFilter{Type: ACTIVITY_CHANNEL_PAYMENT}, 1},
+		{ActivityFilter{AssetName: "ordx:f:test"}, 1},
+		{ActivityFilter{Counterparty: "alice"}, 1},
+		{ActivityFilter{Counterparty: "channel1"}, 1},
+		{ActivityFilter{Status: ACTIVITY_STATUS_CONFIRMED}, 2},
+		{ActivityFilter{Start: 5}, 3},
+	}
+	for _, test := range tests {
+		if _, total := filterActivities(items, &test.filter); total != test.total {
+			t.Fatalf("filter %+v: expected %d, got %d", test.filter, test.total, total)
+		}
+	}
+}
@@ -418,6 +418,99 @@ func SenderHTLCScript(senderHtlcKey, receiverHtlcKey,
 	return builder.Script()
 }
 
+// ChannelHTLCScript constructs the witness script for an HTLC output in a
+// channel commitment transaction. Unlike SenderHTLCScript it doesn't rely on
+// second level HTLC transactions, every path spends the commitment output
+// directly:
+//
+//   - The receiver of the HTLC claiming the output with the payment preimage.
+//   - The sender of the HTLC reclaiming the output once the absolute expiry
+//     height has been reached.
+//   - The counterparty of the commitment owner sweeping the output with the
+//     revocation key in the case that a revoked commitment was broadcast.
+//
+// The owner of the commitment must wait csvTimeout blocks on its own path
+// (timeout if ownerIsSender, otherwise success), so the counterparty always
+// gets a chance to use the revocation path first.
+//
+// Possible Input Scripts:
+//
+//	REVOK: <revoke sig> 1
+//	RECVR: <recvr sig> <preimage> 1 <emptyvector>
+//	SENDR: <sendr sig> <emptyvector> <emptyvector>
+//
+// Output Script:
+//
+//	OP_IF
+//	    <revokeKey>
+//	OP_ELSE
+//	    OP_IF
+//	        OP_SIZE 32 OP_EQUALVERIFY
+//	        OP_HASH160 <ripemd160(payment hash)> OP_EQUALVERIFY
+//	        [<csvTimeout> OP_CHECKSEQUENCEVERIFY OP_DROP] <- if !ownerIsSender
+//	        <recv htlc key>
+//	    OP_ELSE
+//	        <expiry> OP_CHECKLOCKTIMEVERIFY OP_DROP
+//	        [<csvTimeout> OP_CHECKSEQUENCEVERIFY OP_DROP] <- if ownerIsSender
+//	        <sender htlc key>
+//	    OP_ENDIF
+//	OP_ENDIF
+//	OP_CHECKSIG
+func ChannelHTLCScript(csvTimeout, expiry uint32, senderHtlcKey, receiverHtlcKey,
+	revocationKey *btcec.PublicKey, paymentHash []byte, ownerIsSender bool) ([]byte, error) {
+
+	if len(paymentHash) != 32 {
+		return nil, fmt.Errorf("invalid payment hash length %d", len(paymentHash))
+	}
+
+	builder := txscript.NewScriptBuilder(txscript.WithScriptAllocSize(
+		ChannelHtlcScriptSize,
+	))
+
+	// If a valid signature using the revocation key is presented, then
+	// allow an immediate spend provided the proper signature.
+	builder.AddOp(txscript.OP_IF)
+	builder.AddData(revocationKey.SerializeCompressed())
+	builder.AddOp(txscript.OP_ELSE)
+
+	// The receiver claims the output with the payment preimage. The
+	// preimage must be exactly 32 bytes, and its hash160 must match the
+	// ripemd160 of the payment hash.
+	builder.AddOp(txscript.OP_IF)
+	builder.AddOp(txscript.OP_SIZE)
+	builder.AddInt64(32)
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_HASH160)
+	builder.AddData(Ripemd160H(paymentHash))
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	if !ownerIsSender {
+		builder.AddInt64(int64(csvTimeout))
+		builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+		builder.AddOp(txscript.OP_DROP)
+	}
+	builder.AddData(receiverHtlcKey.SerializeCompressed())
+
+	// Otherwise the sender reclaims the output after the expiry height.
+	builder.AddOp(txscript.OP_ELSE)
+	builder.AddInt64(int64(expiry))
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	if ownerIsSender {
+		builder.AddInt64(int64(csvTimeout))
+		builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+		builder.AddOp(txscript.OP_DROP)
+	}
+	builder.AddData(senderHtlcKey.SerializeCompressed())
+	builder.AddOp(txscript.OP_ENDIF)
+
+	builder.AddOp(txscript.OP_ENDIF)
+
+	// Finally, we'll validate the signature against the public key that's
+	// left on the top of the stack.
+	builder.AddOp(txscript.OP_CHECKSIG)
+
+	return builder.Script()
+}
 
 // LockTimeToSequence converts the passed relative locktime to a sequence
 // number in accordance to BIP-68.
@@ -244,6 +244,10 @@ const (
 	// should be spent using the script path and that a specific leaf script
 	// should be signed for.
 	TaprootScriptSpendSignMethod SignMethod = 3
+
+	// LegacyP2PKHSignMethod denotes that a legacy (non-SegWit) p2pkh input
+	// should be signed.
+	LegacyP2PKHSignMethod SignMethod = 4
 )
 
 // String returns a human-readable representation of the signing method.
@@ -257,6 +261,8 @@ func (s SignMethod) String() string {
 		return "taproot_key_spend"
 	case TaprootScriptSpendSignMethod:
 		return "taproot_script_spend"
+	case LegacyP2PKHSignMethod:
+		return "legacy_p2pkh"
 	default:
 		return fmt.Sprintf("unknown<%d>", s)
 	}
@@ -277,6 +283,9 @@ func (s SignMethod) PkScriptCompatible(pkScript []byte) bool {
 
 		return txscript.IsPayToTaproot(pkScript)
 
+	case LegacyP2PKHSignMethod:
+		return txscript.IsPayToPubKeyHash(pkScript)
+
 	default:
 		return false
 	}
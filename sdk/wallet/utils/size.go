@@ -576,6 +576,77 @@ const (
 	OfferedHtlcPenaltyWitnessSizeConfirmed = 1 + 1 + 73 + 1 + 33 + 1 +
 		OfferedHtlcScriptSizeConfirmed
 
+	// ChannelHtlcScriptSize 149 bytes (max)
+	//      - OP_IF: 1 byte
+	//          - OP_DATA: 1 byte (revocationkey length)
+	//          - revocationkey: 33 bytes
+	//      - OP_ELSE: 1 byte
+	//          - OP_IF: 1 byte
+	//              - OP_SIZE: 1 byte
+	//              - 32: 2 bytes
+	//              - OP_EQUALVERIFY: 1 byte
+	//              - OP_HASH160: 1 byte
+	//              - OP_DATA: 1 byte (RIPEMD160(payment hash) length)
+	//              - RIPEMD160(payment hash): 20 bytes
+	//              - OP_EQUALVERIFY: 1 byte
+	//              - OP_DATA: 1 byte (receiver key length)
+	//              - receiver key: 33 bytes
+	//          - OP_ELSE: 1 byte
+	//              - OP_DATA: 1 byte (cltv expiry length)
+	//              - cltv expiry: 4 bytes
+	//              - OP_CHECKLOCKTIMEVERIFY: 1 byte
+	//              - OP_DROP: 1 byte
+	//              - OP_DATA: 1 byte (sender key length)
+	//              - sender key: 33 bytes
+	//          - OP_ENDIF: 1 byte
+	//      - OP_ENDIF: 1 byte
+	//      - OP_CHECKSIG: 1 byte
+	//
+	// The owner of the commitment additionally waits a CSV delay on its own
+	// branch (only one of the two branches):
+	//      - OP_DATA: 1 byte (csv delay length)
+	//      - csv delay: 3 bytes
+	//      - OP_CHECKSEQUENCEVERIFY: 1 byte
+	//      - OP_DROP: 1 byte
+	ChannelHtlcScriptSize = 1 + 34 + 1 + 1 + 1 + 2 + 1 + 1 + 21 + 1 + 34 +
+		1 + 5 + 1 + 1 + 34 + 1 + 1 + 1 + 6
+
+	// ChannelHtlcSuccessWitnessSize 261 bytes
+	//      - number_of_witness_elements: 1 byte
+	//      - receiver_sig_length: 1 byte
+	//      - receiver_sig: 73 bytes
+	//      - payment_preimage_length: 1 byte
+	//      - payment_preimage: 32 bytes
+	//      - OP_TRUE_length: 1 byte
+	//      - OP_TRUE: 1 byte
+	//      - nil_length: 1 byte
+	//      - witness_script_length: 1 byte
+	//      - witness_script (channel_htlc_script)
+	ChannelHtlcSuccessWitnessSize = 1 + 1 + 73 + 1 + 32 + 1 + 1 + 1 + 1 +
+		ChannelHtlcScriptSize
+
+	// ChannelHtlcTimeoutWitnessSize 227 bytes
+	//      - number_of_witness_elements: 1 byte
+	//      - sender_sig_length: 1 byte
+	//      - sender_sig: 73 bytes
+	//      - nil_length: 1 byte
+	//      - nil_length: 1 byte
+	//      - witness_script_length: 1 byte
+	//      - witness_script (channel_htlc_script)
+	ChannelHtlcTimeoutWitnessSize = 1 + 1 + 73 + 1 + 1 + 1 +
+		ChannelHtlcScriptSize
+
+	// ChannelHtlcPenaltyWitnessSize 227 bytes
+	//      - number_of_witness_elements: 1 byte
+	//      - revocation_sig_length: 1 byte
+	//      - revocation_sig: 73 bytes
+	//      - OP_TRUE_length: 1 byte
+	//      - OP_TRUE: 1 byte
+	//      - witness_script_length: 1 byte
+	//      - witness_script (channel_htlc_script)
+	ChannelHtlcPenaltyWitnessSize = 1 + 1 + 73 + 1 + 1 + 1 +
+		ChannelHtlcScriptSize
+
 	// AnchorScriptSize 40 bytes
 	//      - pubkey_length: 1 byte
 	//      - pubkey: 33 bytes
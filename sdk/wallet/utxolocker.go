@@ -25,6 +25,7 @@ type LockedUtxo struct {
 	ReservationPreviousReason string         `json:"reservation_previous_reason,omitempty"`
 	Value                     int64          `json:"value"`
 	Assets                    swire.TxAssets `json:"assets"`
+	Label                     string         `json:"label,omitempty"` // 只在查询时填写，不保存
 }
 
 var (
@@ -115,6 +116,59 @@ func (p *UtxoLocker) UnlockUtxosWithTx(tx *wire.MsgTx) error {
 	return nil
 }
 
+// ReplaceTx moves the locks of a replaced (RBF) tx to its replacement in one
+// batch: inputs dropped by the replacement are released, new inputs are locked
+// as broadcasted, and locks or reservations on the old outputs follow the same
+// output index of the new tx.
+func (p *UtxoLocker) ReplaceTx(oldTx, newTx *wire.MsgTx) error {
+	if oldTx == nil || newTx == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.reload()
+
+	newInputs := make(map[string]bool, len(newTx.TxIn))
+	for _, in := range newTx.TxIn {
+		newInputs[in.PreviousOutPoint.String()] = true
+	}
+	puts := make(map[string]*LockedUtxo)
+	var deletes []string
+	for _, in := range oldTx.TxIn {
+		utxo := in.PreviousOutPoint.String()
+		if newInputs[utxo] {
+			continue
+		}
+		current := p.lockmap[utxo]
+		if current != nil && current.Reason == "broadcasted" && current.ReservationID == "" {
+			deletes = append(deletes, utxo)
+		}
+	}
+	for utxo := range newInputs {
+		if _, ok := p.lockmap[utxo]; !ok {
+			puts[utxo] = &LockedUtxo{LockedTime: time.Now().Unix(), Reason: "broadcasted"}
+		}
+	}
+
+	oldTxId := oldTx.TxID()
+	newTxId := newTx.TxID()
+	for i := range oldTx.TxOut {
+		utxo := fmt.Sprintf("%s:%d", oldTxId, i)
+		current := p.lockmap[utxo]
+		if current == nil {
+			continue
+		}
+		deletes = append(deletes, utxo)
+		if i < len(newTx.TxOut) {
+			puts[fmt.Sprintf("%s:%d", newTxId, i)] = cloneLockedUtxo(current)
+		}
+	}
+	if len(puts) == 0 && len(deletes) == 0 {
+		return nil
+	}
+	return p.persistReservationChangesLocked(puts, deletes)
+}
+
 func (p *UtxoLocker) LockUtxosWithTx_SatsNet(tx *swire.MsgTx) error {
 	if tx == nil {
 		return nil
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	indexerdb "github.com/sat20-labs/indexer/indexer/db"
 )
 
@@ -66,3 +68,50 @@ func TestUtxoReservationIsAtomicAndOwnerBound(t *testing.T) {
 		t.Fatalf("finalized RGB change cannot be reused: %v", err)
 	}
 }
+
+func TestUtxoLockerReplaceTx(t *testing.T) {
+	db := indexerdb.NewKVDB(t.TempDir())
+	defer db.Close()
+	locker := NewUtxoLocker(db, nil, L1_NETWORK_BITCOIN)
+	locker.Init()
+
+	outPoint := func(b byte, index uint32) *wire.OutPoint {
+		return wire.NewOutPoint(&chainhash.Hash{b}, index)
+	}
+	oldTx := wire.NewMsgTx(wire.TxVersion)
+	oldTx.AddTxIn(wire.NewTxIn(outPoint(1, 0), nil, nil))
+	oldTx.AddTxIn(wire.NewTxIn(outPoint(2, 0), nil, nil))
+	oldTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	oldTx.AddTxOut(wire.NewTxOut(2000, []byte{0x51}))
+	if err := locker.LockUtxosWithTx(oldTx); err != nil {
+		t.Fatal(err)
+	}
+	oldOutput := oldTx.TxHash().String() + ":1"
+	if err := locker.TryReserve([]string{oldOutput}, "pending", "op-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	newTx := wire.NewMsgTx(wire.TxVersion)
+	newTx.AddTxIn(wire.NewTxIn(outPoint(1, 0), nil, nil))
+	newTx.AddTxIn(wire.NewTxIn(outPoint(3, 0), nil, nil))
+	newTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	newTx.AddTxOut(wire.NewTxOut(1500, []byte{0x51}))
+	if err := locker.ReplaceTx(oldTx, newTx); err != nil {
+		t.Fatal(err)
+	}
+
+	locks := locker.GetLockedUtxoList()
+	if locks[outPoint(1, 0).String()] == nil || locks[outPoint(3, 0).String()] == nil {
+		t.Fatalf("inputs of replacement tx should be locked: %+v", locks)
+	}
+	if locks[outPoint(2, 0).String()] != nil {
+		t.Fatal("input dropped by replacement tx should be unlocked")
+	}
+	if locks[oldOutput] != nil {
+		t.Fatal("lock of replaced output should be removed")
+	}
+	moved := locks[newTx.TxHash().String()+":1"]
+	if moved == nil || moved.ReservationID != "op-a" || moved.Reason != "pending" {
+		t.Fatalf("reservation should move to replacement output: %+v", moved)
+	}
+}
@@ -0,0 +1,460 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/btcsuite/btcwallet/snacl"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+钱包秘密数据（助记词，私钥，描述符）的加密
+使用内存困难的KDF（默认argon2id，也可以是scrypt）从密码派生32字节密钥，再用XSalsa20-Poly1305（secretbox）加密。
+KDF参数和salt保存在 WalletInDB.Kdf 中。旧的记录 Kdf 为空，使用snacl（固定参数的scrypt），
+在下次解锁成功后按照当前配置重新加密保存。
+*/
+
+const (
+	KDF_ARGON2ID = "argon2id"
+	KDF_SCRYPT   = "scrypt"
+
+	DEFAULT_KDF_MEMORY       = 64 * 1024 // KB
+	DEFAULT_ARGON2_TIME      = 3
+	DEFAULT_SCRYPT_LOG_N     = 17
+	DEFAULT_KDF_THREADS      = 1
+	DEFAULT_MAX_UNLOCK       = 5
+	DEFAULT_UNLOCK_BACKOFF   = 30 // 秒
+	MAX_UNLOCK_BACKOFF       = 24 * 60 * 60
+	DEFAULT_PASSWORD_LENGTH  = 8
+	DEFAULT_PASSWORD_CLASSES = 2
+
+	vaultKeySize   = 32
+	vaultSaltSize  = 32
+	vaultNonceSize = 24
+)
+
+var (
+	ErrIncorrectPassword = errors.New("password is incorrect")
+	ErrUnlockLocked      = errors.New("too many failed unlock attempts")
+)
+
+type KdfParams struct {
+	Algo    string `json:"algo"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time,omitempty"`    // argon2id
+	Memory  uint32 `json:"memory,omitempty"`  // argon2id, KB
+	Threads uint8  `json:"threads,omitempty"` // argon2id
+	LogN    uint8  `json:"logN,omitempty"`    // scrypt
+	R       int    `json:"r,omitempty"`       // scrypt
+	P       int    `json:"p,omitempty"`       // scrypt
+}
+
+// 根据配置生成新的KDF参数，每次都使用新的salt
+func (p *Manager) newKdfParams() (*KdfParams, error) {
+	params := p.kdfConfig()
+	params.Salt = make([]byte, vaultSaltSize)
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// 当前配置的KDF参数，不包括salt
+func (p *Manager) kdfConfig() *KdfParams {
+	params := &KdfParams{
+		Algo:    KDF_ARGON2ID,
+		Time:    DEFAULT_ARGON2_TIME,
+		Memory:  DEFAULT_KDF_MEMORY,
+		Threads: DEFAULT_KDF_THREADS,
+	}
+	if p.cfg == nil {
+		return params
+	}
+	cfg := &p.cfg.Wallet
+	switch strings.ToLower(cfg.Kdf) {
+	case KDF_SCRYPT:
+		params = &KdfParams{Algo: KDF_SCRYPT, LogN: DEFAULT_SCRYPT_LOG_N, R: 8, P: 1}
+		if cfg.KdfIterations != 0 {
+			params.LogN = uint8(cfg.KdfIterations)
+		}
+	default:
+		if cfg.KdfIterations != 0 {
+			params.Time = cfg.KdfIterations
+		}
+		if cfg.KdfMemory != 0 {
+			params.Memory = cfg.KdfMemory
+		}
+		if cfg.KdfThreads != 0 {
+			params.Threads = cfg.KdfThreads
+		}
+	}
+	return params
+}
+
+// 记录的参数跟当前配置不一致，或者是旧的记录，需要重新加密
+func (p *Manager) needKdfUpgrade(w *WalletInDB) bool {
+	if w.Kdf == nil {
+		return true
+	}
+	cfg := p.kdfConfig()
+	if w.Kdf.Algo != cfg.Algo {
+		return true
+	}
+	switch cfg.Algo {
+	case KDF_SCRYPT:
+		return w.Kdf.LogN < cfg.LogN
+	default:
+		return w.Kdf.Time < cfg.Time || w.Kdf.Memory < cfg.Memory
+	}
+}
+
+func deriveVaultKey(password string, params *KdfParams) (*[vaultKeySize]byte, error) {
+	if len(params.Salt) == 0 {
+		return nil, fmt.Errorf("no kdf salt")
+	}
+	var key [vaultKeySize]byte
+	switch params.Algo {
+	case KDF_ARGON2ID:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id params")
+		}
+		copy(key[:], argon2.IDKey([]byte(password), params.Salt,
+			params.Time, params.Memory, params.Threads, vaultKeySize))
+	case KDF_SCRYPT:
+		if params.LogN == 0 || params.LogN > 30 {
+			return nil, fmt.Errorf("invalid scrypt params")
+		}
+		buf, err := scrypt.Key([]byte(password), params.Salt,
+			1<<params.LogN, params.R, params.P, vaultKeySize)
+		if err != nil {
+			return nil, err
+		}
+		copy(key[:], buf)
+	default:
+		return nil, fmt.Errorf("unsupported kdf %s", params.Algo)
+	}
+	return &key, nil
+}
+
+// 返回 nonce || 密文
+func encryptVaultSecret(secret []byte, password string, params *KdfParams) ([]byte, error) {
+	key, err := deriveVaultKey(password, params)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [vaultNonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	return secretbox.Seal(nonce[:], secret, &nonce, key), nil
+}
+
+func decryptVaultSecret(data []byte, password string, params *KdfParams) ([]byte, error) {
+	if len(data) < vaultNonceSize+secretbox.Overhead {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+	key, err := deriveVaultKey(password, params)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [vaultNonceSize]byte
+	copy(nonce[:], data[:vaultNonceSize])
+	plain, ok := secretbox.Open(nil, data[vaultNonceSize:], &nonce, key)
+	if !ok {
+		return nil, ErrIncorrectPassword
+	}
+	return plain, nil
+}
+
+// 用当前配置的KDF加密，结果写入wallet，不保存到数据库
+func (p *Manager) encryptWalletSecret(secret, password string, wallet *WalletInDB) error {
+	params, err := p.newKdfParams()
+	if err != nil {
+		return err
+	}
+	en, err := encryptVaultSecret([]byte(secret), password, params)
+	if err != nil {
+		Log.Errorf("encryptVaultSecret failed. %v", err)
+		return err
+	}
+	wallet.Mnemonic = en
+	wallet.Salt = nil
+	wallet.Kdf = params
+	return nil
+}
+
+func (p *Manager) decryptWalletSecret(wallet *WalletInDB, password string) (string, error) {
+	if wallet.Kdf == nil {
+		// 旧的记录
+		key, err := p.restoreSnaclKey(wallet.Salt, password)
+		if err != nil {
+			if errors.Is(err, snacl.ErrInvalidPassword) {
+				return "", ErrIncorrectPassword
+			}
+			return "", err
+		}
+		defer key.Zero()
+		secret, err := key.Decrypt(wallet.Mnemonic)
+		if err != nil {
+			return "", err
+		}
+		return string(secret), nil
+	}
+	secret, err := decryptVaultSecret(wallet.Mnemonic, password, wallet.Kdf)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// 连续解锁失败的状态，保存在数据库中，重启以后仍然有效
+type UnlockState struct {
+	Failures    int   `json:"failures"`
+	LockedUntil int64 `json:"lockedUntil"` // unix 秒
+}
+
+type UnlockStatus struct {
+	Failures          int   `json:"failures"`
+	RemainingAttempts int   `json:"remainingAttempts"` // -1 不限制
+	LockedUntil       int64 `json:"lockedUntil"`
+}
+
+func (p *Manager) unlockPolicy() (int, int) {
+	maxAttempts, backoff := DEFAULT_MAX_UNLOCK, DEFAULT_UNLOCK_BACKOFF
+	if p.cfg != nil {
+		if p.cfg.Wallet.MaxUnlockAttempts != 0 {
+			maxAttempts = p.cfg.Wallet.MaxUnlockAttempts
+		}
+		if p.cfg.Wallet.UnlockBackoff > 0 {
+			backoff = p.cfg.Wallet.UnlockBackoff
+		}
+	}
+	return maxAttempts, backoff
+}
+
+func (p *Manager) loadUnlockState() *UnlockState {
+	if p.unlockState != nil {
+		return p.unlockState
+	}
+	p.unlockState = &UnlockState{}
+	if p.db == nil {
+		return p.unlockState
+	}
+	buf, err := p.db.Read([]byte(DB_KEY_UNLOCK_STATE))
+	if err == nil {
+		if err := DecodeFromBytes(buf, p.unlockState); err != nil {
+			Log.Errorf("DecodeFromBytes %s failed. %v", DB_KEY_UNLOCK_STATE, err)
+			p.unlockState = &UnlockState{}
+		}
+	}
+	return p.unlockState
+}
+
+func (p *Manager) saveUnlockState() {
+	if p.db == nil {
+		return
+	}
+	buf, err := EncodeToBytes(p.unlockState)
+	if err != nil {
+		return
+	}
+	if err := p.db.Write([]byte(DB_KEY_UNLOCK_STATE), buf); err != nil {
+		Log.Errorf("save %s failed. %v", DB_KEY_UNLOCK_STATE, err)
+	}
+}
+
+// 返回给用户的密码检查错误：锁定时原样返回，带上重试的时间；只有解密失败才是密码错误
+func passwordError(err error) error {
+	if errors.Is(err, ErrUnlockLocked) {
+		return err
+	}
+	if errors.Is(err, ErrIncorrectPassword) {
+		return ErrIncorrectPassword
+	}
+	return err
+}
+
+// 锁定期间拒绝所有需要密码的操作，不再尝试密码
+func (p *Manager) checkUnlockAllowed(now time.Time) error {
+	p.unlockMutex.Lock()
+	defer p.unlockMutex.Unlock()
+	state := p.loadUnlockState()
+	if state.LockedUntil > now.Unix() {
+		return fmt.Errorf("%w, try again in %d seconds", ErrUnlockLocked, state.LockedUntil-now.Unix())
+	}
+	return nil
+}
+
+// 密码错误时增加失败次数，超过限制后按照指数退避锁定；成功后清零
+func (p *Manager) recordUnlockResult(err error, now time.Time) {
+	p.unlockMutex.Lock()
+	defer p.unlockMutex.Unlock()
+	state := p.loadUnlockState()
+	if err == nil {
+		if state.Failures != 0 || state.LockedUntil != 0 {
+			*state = UnlockState{}
+			p.saveUnlockState()
+		}
+		return
+	}
+	if !errors.Is(err, ErrIncorrectPassword) {
+		return
+	}
+	state.Failures++
+	maxAttempts, backoff := p.unlockPolicy()
+	if maxAttempts > 0 && state.Failures >= maxAttempts {
+		delay := int64(backoff)
+		for i := maxAttempts; i < state.Failures && delay < MAX_UNLOCK_BACKOFF; i++ {
+			delay *= 2
+		}
+		if delay > MAX_UNLOCK_BACKOFF {
+			delay = MAX_UNLOCK_BACKOFF
+		}
+		state.LockedUntil = now.Unix() + delay
+		Log.Warningf("%d failed unlock attempts, locked for %d seconds", state.Failures, delay)
+	}
+	p.saveUnlockState()
+}
+
+func (p *Manager) GetUnlockStatus() *UnlockStatus {
+	p.unlockMutex.Lock()
+	defer p.unlockMutex.Unlock()
+	state := p.loadUnlockState()
+	maxAttempts, _ := p.unlockPolicy()
+	status := &UnlockStatus{
+		Failures:          state.Failures,
+		RemainingAttempts: -1,
+		LockedUntil:       state.LockedUntil,
+	}
+	if maxAttempts > 0 {
+		status.RemainingAttempts = maxAttempts - state.Failures
+		if status.RemainingAttempts < 0 {
+			status.RemainingAttempts = 0
+		}
+	}
+	return status
+}
+
+const (
+	PASSWORD_TOO_SHORT       = "too_short"
+	PASSWORD_TOO_FEW_CLASSES = "too_few_character_classes"
+	PASSWORD_REPEATED        = "repeated_characters"
+	PASSWORD_COMMON          = "common_password"
+)
+
+type PasswordCheckResult struct {
+	Valid  bool     `json:"valid"`
+	Score  int      `json:"score"` // 0-4
+	Issues []string `json:"issues,omitempty"`
+}
+
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "12345678": true,
+	"123456789": true, "1234567890": true, "qwertyui": true, "qwerty123": true,
+	"11111111": true, "abc12345": true, "iloveyou": true, "bitcoin": true,
+	"bitcoin123": true, "satoshi": true, "88888888": true, "00000000": true,
+}
+
+// 检查密码强度，minLength 和 minClasses 为0时使用默认值
+func CheckPasswordStrength(password string, minLength, minClasses int) *PasswordCheckResult {
+	if minLength <= 0 {
+		minLength = DEFAULT_PASSWORD_LENGTH
+	}
+	if minClasses <= 0 {
+		minClasses = DEFAULT_PASSWORD_CLASSES
+	}
+	result := &PasswordCheckResult{}
+	length := len([]rune(password))
+
+	var lower, upper, digit, other bool
+	repeated := length > 0
+	var first rune
+	for i, c := range password {
+		if i == 0 {
+			first = c
+		} else if c != first {
+			repeated = false
+		}
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, b := range []bool{lower, upper, digit, other} {
+		if b {
+			classes++
+		}
+	}
+
+	if length < minLength {
+		result.Issues = append(result.Issues, PASSWORD_TOO_SHORT)
+	}
+	if classes < minClasses {
+		result.Issues = append(result.Issues, PASSWORD_TOO_FEW_CLASSES)
+	}
+	if repeated {
+		result.Issues = append(result.Issues, PASSWORD_REPEATED)
+	}
+	if commonPasswords[strings.ToLower(password)] {
+		result.Issues = append(result.Issues, PASSWORD_COMMON)
+	}
+
+	if !repeated && !commonPasswords[strings.ToLower(password)] {
+		if length >= 8 {
+			result.Score++
+		}
+		if length >= 12 {
+			result.Score++
+		}
+		if classes >= 3 {
+			result.Score++
+		}
+		if classes >= 4 || length >= 16 {
+			result.Score++
+		}
+	}
+	result.Valid = len(result.Issues) == 0
+	return result
+}
+
+// 使用配置中的密码策略
+func (p *Manager) CheckPasswordStrength(password string) *PasswordCheckResult {
+	if p.cfg == nil {
+		return CheckPasswordStrength(password, 0, 0)
+	}
+	return CheckPasswordStrength(password, p.cfg.Wallet.PasswordMinLength, p.cfg.Wallet.PasswordClasses)
+}
+
+// 设置新密码时检查。只有第一个钱包和修改密码时检查，其他钱包必须使用相同的密码
+func (p *Manager) checkNewPassword(password string) error {
+	if p.cfg == nil || !p.cfg.Wallet.PasswordPolicy {
+		return nil
+	}
+	result := p.CheckPasswordStrength(password)
+	if !result.Valid {
+		return fmt.Errorf("password is too weak: %s", strings.Join(result.Issues, ", "))
+	}
+	return nil
+}
+
+// 添加钱包时，只有第一个钱包需要检查密码强度
+func (p *Manager) checkAddWalletPassword(password string) error {
+	if len(p.walletInfoMap) != 0 {
+		return nil
+	}
+	return p.checkNewPassword(password)
+}
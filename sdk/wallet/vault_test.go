@@ -0,0 +1,201 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+)
+
+func TestVaultEncryption(t *testing.T) {
+	for _, params := range []*KdfParams{
+		{Algo: KDF_ARGON2ID, Salt: []byte("0123456789abcdef"), Time: 1, Memory: 1024, Threads: 1},
+		{Algo: KDF_SCRYPT, Salt: []byte("0123456789abcdef"), LogN: 10, R: 8, P: 1},
+	} {
+		en, err := encryptVaultSecret([]byte("secret"), "password", params)
+		if err != nil {
+			t.Fatalf("%s encrypt failed. %v", params.Algo, err)
+		}
+		plain, err := decryptVaultSecret(en, "password", params)
+		if err != nil || string(plain) != "secret" {
+			t.Fatalf("%s decrypt failed. %v", params.Algo, err)
+		}
+		if _, err := decryptVaultSecret(en, "wrong", params); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("%s wrong password should fail, got %v", params.Algo, err)
+		}
+	}
+}
+
+func TestLegacyWalletUpgrade(t *testing.T) {
+	oldChain := _chain
+	_chain = "testnet"
+	defer func() { _chain = oldChain }()
+
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+	manager.cfg.Wallet.KdfMemory = 1024
+	manager.cfg.Wallet.KdfIterations = 1
+	manager.cfg.Wallet.MaxUnlockAttempts = 2
+
+	// 旧版本用snacl保存的钱包
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	key, err := manager.newSnaclKey("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	en, err := key.Encrypt([]byte(mnemonic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := WalletInDB{
+		Id:           1,
+		Mnemonic:     en,
+		Salt:         key.Marshal(),
+		Accounts:     1,
+		Type:         WALLET_TYPE_MNEMONIC,
+		AccountNames: map[uint32]string{0: defaultAccountName(0)},
+		AccountDIDs:  make(map[uint32]string),
+	}
+	if err := saveWallet(manager.db, &record); err != nil {
+		t.Fatal(err)
+	}
+	manager.walletInfoMap[record.Id] = &WalletInfo{WalletInDB: record}
+
+	if _, err := manager.UnlockWallet("wrong"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("wrong password should fail, got %v", err)
+	}
+	if status := manager.GetUnlockStatus(); status.Failures != 1 || status.RemainingAttempts != 1 {
+		t.Fatalf("unexpected unlock status %+v", status)
+	}
+	if _, err := manager.UnlockWallet("password"); err != nil {
+		t.Fatalf("UnlockWallet failed. %v", err)
+	}
+	if status := manager.GetUnlockStatus(); status.Failures != 0 || status.LockedUntil != 0 {
+		t.Fatalf("unlock status is not reset %+v", status)
+	}
+
+	wallets, err := loadAllWalletFromDB(manager.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded := wallets[record.Id]
+	if upgraded == nil || upgraded.Kdf == nil || upgraded.Kdf.Algo != KDF_ARGON2ID || upgraded.Salt != nil {
+		t.Fatalf("wallet is not upgraded %+v", upgraded)
+	}
+	if manager.needKdfUpgrade(&upgraded.WalletInDB) {
+		t.Fatal("upgraded wallet should match current config")
+	}
+	if manager.GetMnemonic(record.Id, "password") != mnemonic {
+		t.Fatal("mnemonic mismatch after upgrade")
+	}
+
+	// 其他需要密码的操作同样计数，锁定后正确的密码也被拒绝
+	for i := 0; i < 2; i++ {
+		if manager.GetMnemonic(record.Id, "wrong") != "" {
+			t.Fatal("wrong password should not return mnemonic")
+		}
+	}
+	if manager.GetMnemonic(record.Id, "password") != "" {
+		t.Fatal("GetMnemonic should be locked")
+	}
+	_, err = manager.loadWalletSecret(manager.walletInfoMap[record.Id], "password")
+	if !errors.Is(err, ErrUnlockLocked) {
+		t.Fatalf("password check should be locked, got %v", err)
+	}
+	// 锁定时不能报告为密码错误，需要带上重试的时间
+	if err := passwordError(err); errors.Is(err, ErrIncorrectPassword) || !errors.Is(err, ErrUnlockLocked) {
+		t.Fatalf("locked error should be returned unchanged, got %v", err)
+	}
+}
+
+func TestUnlockBackoff(t *testing.T) {
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+	manager.cfg.Wallet.MaxUnlockAttempts = 3
+	manager.cfg.Wallet.UnlockBackoff = 10
+
+	now := time.Unix(1760000000, 0)
+	for i := 0; i < 2; i++ {
+		manager.recordUnlockResult(ErrIncorrectPassword, now)
+	}
+	if err := manager.checkUnlockAllowed(now); err != nil {
+		t.Fatalf("should not be locked yet. %v", err)
+	}
+	manager.recordUnlockResult(ErrIncorrectPassword, now)
+	if err := manager.checkUnlockAllowed(now.Add(9 * time.Second)); !errors.Is(err, ErrUnlockLocked) {
+		t.Fatalf("should be locked, got %v", err)
+	}
+	if err := manager.checkUnlockAllowed(now.Add(10 * time.Second)); err != nil {
+		t.Fatalf("lock should expire. %v", err)
+	}
+
+	// 再次失败，锁定时间翻倍
+	now = now.Add(10 * time.Second)
+	manager.recordUnlockResult(ErrIncorrectPassword, now)
+	if err := manager.checkUnlockAllowed(now.Add(19 * time.Second)); !errors.Is(err, ErrUnlockLocked) {
+		t.Fatalf("backoff should double, got %v", err)
+	}
+
+	// 其他错误不计数
+	manager.recordUnlockResult(errors.New("no wallet"), now)
+	if status := manager.GetUnlockStatus(); status.Failures != 4 || status.RemainingAttempts != 0 {
+		t.Fatalf("unexpected unlock status %+v", status)
+	}
+
+	// 状态保存在数据库中
+	manager.unlockState = nil
+	if err := manager.checkUnlockAllowed(now.Add(19 * time.Second)); !errors.Is(err, ErrUnlockLocked) {
+		t.Fatalf("lock state should be persisted, got %v", err)
+	}
+	manager.recordUnlockResult(nil, now.Add(20*time.Second))
+	if status := manager.GetUnlockStatus(); status.Failures != 0 || status.LockedUntil != 0 {
+		t.Fatalf("unlock status is not reset %+v", status)
+	}
+}
+
+func TestCheckPasswordStrength(t *testing.T) {
+	cases := []struct {
+		password string
+		valid    bool
+		issue    string
+	}{
+		{"abc1", false, PASSWORD_TOO_SHORT},
+		{"abcdefghij", false, PASSWORD_TOO_FEW_CLASSES},
+		{"password123", false, PASSWORD_COMMON},
+		{"aaaaaaaaaa", false, PASSWORD_REPEATED},
+		{"correct7horse", true, ""},
+		{"Correct-Horse-42", true, ""},
+	}
+	for _, c := range cases {
+		result := CheckPasswordStrength(c.password, 0, 0)
+		if result.Valid != c.valid {
+			t.Fatalf("%s: valid %v, expected %v, issues %v", c.password, result.Valid, c.valid, result.Issues)
+		}
+		if c.issue != "" {
+			found := false
+			for _, issue := range result.Issues {
+				found = found || issue == c.issue
+			}
+			if !found {
+				t.Fatalf("%s: expected issue %s, got %v", c.password, c.issue, result.Issues)
+			}
+		}
+	}
+	if CheckPasswordStrength("Correct-Horse-42", 0, 0).Score <= CheckPasswordStrength("correct7horse", 0, 0).Score {
+		t.Fatal("stronger password should have higher score")
+	}
+
+	manager := newAccountManagementAutoTestManager(t)
+	manager.cfg = &common.Config{}
+	if err := manager.checkNewPassword("weak"); err != nil {
+		t.Fatalf("policy is disabled. %v", err)
+	}
+	manager.cfg.Wallet.PasswordPolicy = true
+	if err := manager.checkNewPassword("weak"); err == nil {
+		t.Fatal("weak password should be rejected")
+	}
+	if _, _, err := manager.CreateWallet("weak"); err == nil {
+		t.Fatal("first wallet with weak password should be rejected")
+	}
+}
@@ -84,6 +84,31 @@ const (
 
 const DEFAULT_PURPOSE = 86
 
+// 钱包支持派生的地址类型
+const (
+	ADDRESS_TYPE_P2TR        = "P2TR"        // m/86'/0'/0'/change/index
+	ADDRESS_TYPE_P2WPKH      = "P2WPKH"      // m/84'/0'/0'/change/index
+	ADDRESS_TYPE_P2SH_P2WPKH = "P2SH-P2WPKH" // m/49'/0'/0'/change/index
+	ADDRESS_TYPE_P2PKH       = "P2PKH"       // m/44'/0'/0'/change/index
+)
+
+// 每个子账户都同时拥有这几种地址，第一个是默认地址类型
+var SupportedAddressTypes = []string{
+	ADDRESS_TYPE_P2TR,
+	ADDRESS_TYPE_P2WPKH,
+	ADDRESS_TYPE_P2SH_P2WPKH,
+	ADDRESS_TYPE_P2PKH,
+}
+
+func IsSupportedAddressType(addrType string) bool {
+	for _, t := range SupportedAddressTypes {
+		if t == addrType {
+			return true
+		}
+	}
+	return false
+}
+
 /*
 v1:
                     m/purpose'/coinType'/account'/change/index
@@ -105,7 +130,8 @@ v2: （还没实现）
 这样一个钱包下的每一个子账户(index)都可以跟节点建立通道，每个通道可以根据需要建立子通道。
 */
 
-// 可以支持其他类型，但为了方便，默认只支持p2tr地址类型。
+// 默认地址类型是p2tr，通道和资产相关的操作只使用p2tr地址。
+// 每个子账户同时可以派生 SupportedAddressTypes 中的其他地址类型，用于兼容其他钱包导入的资金。
 type InternalWallet struct {
 	masterkey              *hdkeychain.ExtendedKey
 	netParamsL1            *chaincfg.Params // L1
@@ -114,6 +140,8 @@ type InternalWallet struct {
 	purposes               map[uint32]*hdkeychain.ExtendedKey // key: purpose
 	accounts               map[uint64]*hdkeychain.ExtendedKey // key: purpose<<32+account
 	addresses              map[uint32]btcutil.Address         // key: index
+	typedPrivKeys          map[string]*secp256k1.PrivateKey   // key: addrType/change/index
+	typedAddresses         map[string]btcutil.Address         // key: addrType/change/index
 	currentIndex           uint32                             // 当前子账户
 	id                     int64
 
@@ -149,6 +177,8 @@ func NewInternalWalletWithPrivKey(privateKey []byte, param *chaincfg.Params) (*I
 		purposes:               make(map[uint32]*hdkeychain.ExtendedKey),
 		accounts:               make(map[uint64]*hdkeychain.ExtendedKey),
 		addresses:              make(map[uint32]btcutil.Address),
+		typedPrivKeys:          make(map[string]*secp256k1.PrivateKey),
+		typedAddresses:         make(map[string]btcutil.Address),
 		subWallets:             make(map[uint32]*channelWallet),
 		currentIndex:           0,
 		id:                     time.Now().UnixMicro(),
@@ -197,7 +227,8 @@ func NewInternalWalletWithMnemonic(mnemonic string, password string, param *chai
 		Log.Errorf("Mnomonic is invalid")
 		return nil
 	}
-	if strings.Count(mnemonic, " ") != 11 || strings.Count(mnemonic, "\n") > 0 ||
+	spaces := strings.Count(mnemonic, " ")
+	if (spaces != 11 && spaces != 17 && spaces != 23) || strings.Count(mnemonic, "\n") > 0 ||
 		strings.Count(mnemonic, "\t") > 0 {
 		Log.Errorf("Mnomonic has invalid char")
 		return nil
@@ -216,6 +247,8 @@ func NewInternalWalletWithMnemonic(mnemonic string, password string, param *chai
 		purposes:               make(map[uint32]*hdkeychain.ExtendedKey),
 		accounts:               make(map[uint64]*hdkeychain.ExtendedKey),
 		addresses:              make(map[uint32]btcutil.Address),
+		typedPrivKeys:          make(map[string]*secp256k1.PrivateKey),
+		typedAddresses:         make(map[string]btcutil.Address),
 		subWallets:             make(map[uint32]*channelWallet),
 		currentIndex:           0,
 		id:                     time.Now().UnixMicro(),
@@ -234,6 +267,8 @@ func (p *InternalWallet) Clone() common.Wallet {
 		purposes:               make(map[uint32]*hdkeychain.ExtendedKey),
 		accounts:               make(map[uint64]*hdkeychain.ExtendedKey),
 		addresses:              make(map[uint32]btcutil.Address),
+		typedPrivKeys:          make(map[string]*secp256k1.PrivateKey),
+		typedAddresses:         make(map[string]btcutil.Address),
 		subWallets:             make(map[uint32]*channelWallet),
 		currentIndex:           p.currentIndex,
 		id:                     p.id,
@@ -253,6 +288,12 @@ func (p *InternalWallet) Clone() common.Wallet {
 	for index, address := range p.addresses {
 		cloned.addresses[index] = address
 	}
+	for id, key := range p.typedPrivKeys {
+		cloned.typedPrivKeys[id] = key
+	}
+	for id, address := range p.typedAddresses {
+		cloned.typedAddresses[id] = address
+	}
 	return cloned
 }
 
@@ -431,6 +472,109 @@ func (p *InternalWallet) GetAddressByPath(change, index uint32) string {
 	return address.EncodeAddress()
 }
 
+func typedKeyId(addrType string, change, index uint32) string {
+	return fmt.Sprintf("%s/%d/%d", addrType, change, index)
+}
+
+// 获取指定地址类型的私钥。p2tr的外部链私钥跟支付私钥是同一个
+func (p *InternalWallet) getTypedPrivKey(addrType string, change, index uint32) (*secp256k1.PrivateKey, error) {
+	if !IsSupportedAddressType(addrType) {
+		return nil, fmt.Errorf("unsupported address type %s", addrType)
+	}
+	if p.masterkey == nil {
+		// 私钥钱包只有一个私钥，各种地址类型都使用该私钥
+		if change != 0 || index != 0 {
+			return nil, fmt.Errorf("can't derive sub account")
+		}
+		return p.paymentPrivKeys[0], nil
+	}
+	if addrType == ADDRESS_TYPE_P2TR && change == 0 {
+		key := p.getPaymentPrivKeyWithIndex(index)
+		if key == nil {
+			return nil, fmt.Errorf("can't get payment key %d", index)
+		}
+		return key, nil
+	}
+
+	id := typedKeyId(addrType, change, index)
+	key, ok := p.typedPrivKeys[id]
+	if ok {
+		return key, nil
+	}
+	key, _, err := p.getKey(addrType, change, index)
+	if err != nil {
+		return nil, err
+	}
+	p.typedPrivKeys[id] = key
+	return key, nil
+}
+
+func (p *InternalWallet) getBtcUtilAddressWithType(addrType string, change, index uint32) (btcutil.Address, error) {
+	if addrType == ADDRESS_TYPE_P2TR && change == 0 {
+		return p.getBtcUtilAddress(index)
+	}
+
+	id := typedKeyId(addrType, change, index)
+	address, ok := p.typedAddresses[id]
+	if ok {
+		return address, nil
+	}
+	key, err := p.getTypedPrivKey(addrType, change, index)
+	if err != nil {
+		return nil, err
+	}
+	address, err = getAddressFromPubKey(key.PubKey(), addrType, p.netParamsL1)
+	if err != nil {
+		return nil, err
+	}
+	p.typedAddresses[id] = address
+	return address, nil
+}
+
+// 子账户index下指定类型的地址，外部链（change=0）
+func (p *InternalWallet) GetAddressByType(addrType string, index uint32) string {
+	return p.GetAddressByPathWithType(addrType, 0, index)
+}
+
+func (p *InternalWallet) GetAddressByPathWithType(addrType string, change, index uint32) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	addr, err := p.getBtcUtilAddressWithType(addrType, change, index)
+	if err != nil {
+		Log.Errorf("getBtcUtilAddressWithType %s failed. %v", addrType, err)
+		return ""
+	}
+	return addr.EncodeAddress()
+}
+
+func (p *InternalWallet) GetPubKeyByType(addrType string, index uint32) *secp256k1.PublicKey {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key, err := p.getTypedPrivKey(addrType, 0, index)
+	if err != nil {
+		Log.Errorf("getTypedPrivKey %s failed. %v", addrType, err)
+		return nil
+	}
+	return key.PubKey()
+}
+
+// 子账户index的找零地址: m/86'/0'/0'/1/index
+func (p *InternalWallet) GetChangeAddressByIndex(index uint32) string {
+	return p.GetAddressByPathWithType(ADDRESS_TYPE_P2TR, 1, index)
+}
+
+// 返回子账户index下所有类型的地址，key: 地址类型
+func (p *InternalWallet) GetAddressesByIndex(index uint32) map[string]string {
+	result := make(map[string]string)
+	for _, addrType := range SupportedAddressTypes {
+		addr := p.GetAddressByType(addrType, index)
+		if addr != "" {
+			result[addrType] = addr
+		}
+	}
+	return result
+}
+
 // 可以直接暴露这个PrivateKey，不影响钱包私钥的安全
 func (p *InternalWallet) GetCommitRootKey(peer []byte) (*secp256k1.PrivateKey, *secp256k1.PublicKey) {
 	privkey := p.GetCommitSecret(peer, 0)
@@ -1103,9 +1247,9 @@ func magicMsgHash(msg string) []byte {
 // 支持上面所有几种不同的签名方式
 func (p *InternalWallet) SignPsbt(packet *psbt.Packet) error {
 	p.mutex.Lock()
-	privKey := p.getPaymentPrivKey()
+	keys := p.getSigningKeys(p.currentIndex)
 	p.mutex.Unlock()
-	return p.signPsbt(privKey, packet)
+	return p.signPsbtWithKeys(keys, packet)
 }
 
 // SignPsbtWithTaprootMerkleRoots signs the ordinary BIP86 inputs plus RGB11
@@ -1232,24 +1376,151 @@ func (p *InternalWallet) SignRGB11Psbt(packet *psbt.Packet, keys map[int]RGB11In
 
 func (p *InternalWallet) SignPsbtWithIndex(packet *psbt.Packet, index uint32) error {
 	p.mutex.Lock()
-	privKey := p.getPaymentPrivKeyWithIndex(index)
+	keys := p.getSigningKeys(index)
 	p.mutex.Unlock()
-	return p.signPsbt(privKey, packet)
+	return p.signPsbtWithKeys(keys, packet)
+}
+
+// psbtSigningKey 某种地址类型下的签名私钥，以及该地址对应的锁定脚本
+type psbtSigningKey struct {
+	addrType     string
+	privKey      *secp256k1.PrivateKey
+	pkScript     []byte
+	redeemScript []byte // 只有P2SH-P2WPKH需要
+}
+
+func newPsbtSigningKey(addrType string, privKey *secp256k1.PrivateKey,
+	params *chaincfg.Params) (*psbtSigningKey, error) {
+	address, err := getAddressFromPubKey(privKey.PubKey(), addrType, params)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+	key := &psbtSigningKey{
+		addrType: addrType,
+		privKey:  privKey,
+		pkScript: pkScript,
+	}
+	if addrType == ADDRESS_TYPE_P2SH_P2WPKH {
+		witnessProg, err := btcutil.NewAddressWitnessPubKeyHash(
+			btcutil.Hash160(privKey.PubKey().SerializeCompressed()), params)
+		if err != nil {
+			return nil, err
+		}
+		key.redeemScript, err = txscript.PayToAddrScript(witnessProg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// 子账户index下所有地址类型的签名私钥，第一个是p2tr，最后是p2tr找零地址的私钥
+func (p *InternalWallet) getSigningKeys(index uint32) []*psbtSigningKey {
+	result := make([]*psbtSigningKey, 0, len(SupportedAddressTypes)+1)
+	for _, addrType := range SupportedAddressTypes {
+		privKey, err := p.getTypedPrivKey(addrType, 0, index)
+		if err != nil {
+			Log.Errorf("getTypedPrivKey %s %d failed. %v", addrType, index, err)
+			continue
+		}
+		key, err := newPsbtSigningKey(addrType, privKey, p.netParamsL1)
+		if err != nil {
+			Log.Errorf("newPsbtSigningKey %s %d failed. %v", addrType, index, err)
+			continue
+		}
+		result = append(result, key)
+	}
+	if p.masterkey != nil {
+		privKey, err := p.getTypedPrivKey(ADDRESS_TYPE_P2TR, 1, index)
+		if err == nil {
+			key, err := newPsbtSigningKey(ADDRESS_TYPE_P2TR, privKey, p.netParamsL1)
+			if err == nil {
+				result = append(result, key)
+			}
+		}
+	}
+	return result
 }
 
 func (p *InternalWallet) signPsbt(privKey *secp256k1.PrivateKey, packet *psbt.Packet) error {
-	err := psbt.InputsReadyToSign(packet)
+	if privKey == nil {
+		return fmt.Errorf("no private key")
+	}
+	key, err := newPsbtSigningKey(ADDRESS_TYPE_P2TR, privKey, p.netParamsL1)
 	if err != nil {
+		Log.Errorf("newPsbtSigningKey failed. %v", err)
 		return err
 	}
+	return p.signPsbtWithKeys([]*psbtSigningKey{key}, packet)
+}
 
-	pubkey := privKey.PubKey()
-	p2trPkScript, err := GetP2TRpkScript(pubkey)
+func findPsbtSigningKey(keys []*psbtSigningKey, pkScript []byte) *psbtSigningKey {
+	for _, key := range keys {
+		if bytes.Equal(key.pkScript, pkScript) {
+			return key
+		}
+	}
+	return nil
+}
+
+// 用地址类型对应的方式对输入签名
+func signPsbtInputWithKey(in *psbt.PInput, tx *wire.MsgTx,
+	sigHashes *txscript.TxSigHashes, idx int, key *psbtSigningKey) error {
+
+	if key.addrType == ADDRESS_TYPE_P2TR {
+		witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, idx,
+			in.WitnessUtxo.Value, in.WitnessUtxo.PkScript,
+			in.SighashType, key.privKey)
+		if err != nil {
+			Log.Errorf("TaprootWitnessSignature failed. %v", err)
+			return err
+		}
+		in.TaprootKeySpendSig = witness[0]
+		return nil
+	}
+
+	// ecdsa签名不支持SigHashDefault
+	if in.SighashType == txscript.SigHashDefault {
+		in.SighashType = txscript.SigHashAll
+	}
+	if len(key.redeemScript) != 0 && len(in.RedeemScript) == 0 {
+		in.RedeemScript = key.redeemScript
+	}
+
+	signMethod, err := validateSigningMethod(in)
+	if err != nil {
+		return err
+	}
+	switch signMethod {
+	case utils.WitnessV0SignMethod:
+		return signSegWitV0(in, tx, sigHashes, idx, key.privKey)
+
+	case utils.LegacyP2PKHSignMethod:
+		return signLegacyP2PKH(in, tx, idx, key.privKey)
+
+	default:
+		return fmt.Errorf("unsupported signing method %v for %s input %d",
+			signMethod, key.addrType, idx)
+	}
+}
+
+// keys 中的第一个私钥同时用于签名多签脚本的输入
+func (p *InternalWallet) signPsbtWithKeys(keys []*psbtSigningKey, packet *psbt.Packet) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no signing key")
+	}
+	err := psbt.InputsReadyToSign(packet)
 	if err != nil {
-		Log.Errorf("CreatePkScriptForP2TR failed. %v", err)
 		return err
 	}
 
+	privKey := keys[0].privKey
+	pubkey := privKey.PubKey()
+
 	tx := packet.UnsignedTx
 	prevOutputFetcher := PsbtPrevOutputFetcher(packet)
 	sigHashes := txscript.NewTxSigHashes(tx, prevOutputFetcher)
@@ -1264,16 +1535,13 @@ func (p *InternalWallet) signPsbt(privKey *secp256k1.PrivateKey, packet *psbt.Pa
 			continue
 		}
 
-		if bytes.Equal(in.WitnessUtxo.PkScript, p2trPkScript) {
-			// 单签，目前只支持p2tr
-			witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, i,
-				in.WitnessUtxo.Value, in.WitnessUtxo.PkScript,
-				in.SighashType, privKey)
+		key := findPsbtSigningKey(keys, in.WitnessUtxo.PkScript)
+		if key != nil {
+			// 单签
+			err = signPsbtInputWithKey(in, tx, sigHashes, i, key)
 			if err != nil {
-				Log.Errorf("TaprootWitnessSignature failed. %v", err)
 				return err
 			}
-			in.TaprootKeySpendSig = witness[0]
 			continue
 		}
 
@@ -1310,21 +1578,21 @@ func (p *InternalWallet) signPsbt(privKey *secp256k1.PrivateKey, packet *psbt.Pa
 
 func (p *InternalWallet) SignPsbts(packet []*psbt.Packet) error {
 	p.mutex.Lock()
-	privKey := p.getPaymentPrivKey()
+	keys := p.getSigningKeys(p.currentIndex)
 	p.mutex.Unlock()
-	return p.signPsbts(privKey, packet)
+	return p.signPsbts(keys, packet)
 }
 
 func (p *InternalWallet) SignPsbtsWithIndex(packet []*psbt.Packet, index uint32) error {
 	p.mutex.Lock()
-	privKey := p.getPaymentPrivKeyWithIndex(index)
+	keys := p.getSigningKeys(index)
 	p.mutex.Unlock()
-	return p.signPsbts(privKey, packet)
+	return p.signPsbts(keys, packet)
 }
 
-func (p *InternalWallet) signPsbts(privKey *secp256k1.PrivateKey, packets []*psbt.Packet) error {
+func (p *InternalWallet) signPsbts(keys []*psbtSigningKey, packets []*psbt.Packet) error {
 	for i, packet := range packets {
-		err := p.signPsbt(privKey, packet)
+		err := p.signPsbtWithKeys(keys, packet)
 		if err != nil {
 			Log.Errorf("signPsbt %d failed, %v", i, err)
 			return err
@@ -2157,11 +2425,24 @@ func validateSigningMethod(in *psbt.PInput) (utils.SignMethod, error) {
 	}
 
 	switch script.Class() {
-	case txscript.WitnessV0PubKeyHashTy, txscript.ScriptHashTy,
-		txscript.WitnessV0ScriptHashTy:
+	case txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy:
+		return utils.WitnessV0SignMethod, nil
 
+	// 目前只支持P2SH-P2WPKH嵌套隔离见证
+	case txscript.ScriptHashTy:
+		if len(in.RedeemScript) == 0 {
+			return 0, fmt.Errorf("cannot sign for p2sh input " +
+				"without redeem script")
+		}
+		if !txscript.IsPayToWitnessPubKeyHash(in.RedeemScript) {
+			return 0, fmt.Errorf("unsupported p2sh redeem script, " +
+				"only nested p2wpkh is supported")
+		}
 		return utils.WitnessV0SignMethod, nil
 
+	case txscript.PubKeyHashTy:
+		return utils.LegacyP2PKHSignMethod, nil
+
 	case txscript.WitnessV1TaprootTy:
 		if len(in.TaprootBip32Derivation) == 0 {
 			return 0, fmt.Errorf("cannot sign for taproot input " +
@@ -2276,6 +2557,23 @@ func signSegWitV0(in *psbt.PInput, tx *wire.MsgTx,
 	return nil
 }
 
+// signLegacyP2PKH generates the signature script for a legacy p2pkh input. The
+// input is finalized directly since the PSBT finalizer requires the full
+// previous transaction for non-witness inputs.
+func signLegacyP2PKH(in *psbt.PInput, tx *wire.MsgTx, idx int,
+	privKey *btcec.PrivateKey) error {
+
+	sigScript, err := txscript.SignatureScript(
+		tx, idx, in.WitnessUtxo.PkScript, in.SighashType, privKey, true,
+	)
+	if err != nil {
+		return fmt.Errorf("error signing p2pkh input %d: %w", idx, err)
+	}
+	in.FinalScriptSig = sigScript
+
+	return nil
+}
+
 // signSegWitV1KeySpend attempts to generate a signature for a SegWit version 1
 // (p2tr) input and stores it in the TaprootKeySpendSig field.
 func signSegWitV1KeySpend(in *psbt.PInput, tx *wire.MsgTx,
@@ -2345,8 +2643,9 @@ func prepareScriptsV0(in *psbt.PInput) []byte {
 	}
 }
 
+// localWallet 不为nil时，为属于这个钱包的输入和找零填写派生路径
 func CreatePsbt(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
-	witnessScript []byte) (*psbt.Packet, error) {
+	witnessScript []byte, localWallet common.Wallet) (*psbt.Packet, error) {
 	packet, err := psbt.NewFromUnsignedTx(RemoveSignatures(tx))
 	if err != nil {
 		return nil, err
@@ -2373,6 +2672,9 @@ func CreatePsbt(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
 			input.SighashType = txscript.SigHashAll
 		}
 	}
+	if localWallet != nil {
+		fillPsbtDerivation(localWallet, packet)
+	}
 
 	return packet, nil
 }
@@ -2409,8 +2711,9 @@ func CreatePsbt_SatsNet(tx *swire.MsgTx, prevFetcher stxscript.PrevOutputFetcher
 	return packet, nil
 }
 
+// localWallet 同 CreatePsbt
 func CreatePsbtWithPeer(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
-	witnessScript []byte, peerPubKey []byte, peerSigs [][]byte) (*psbt.Packet, error) {
+	witnessScript []byte, peerPubKey []byte, peerSigs [][]byte, localWallet common.Wallet) (*psbt.Packet, error) {
 
 	if len(peerSigs) != len(tx.TxIn) {
 		return nil, fmt.Errorf("length of sigs is different from inputs of tx, %d %d", len(peerSigs), len(tx.TxIn))
@@ -2464,6 +2767,9 @@ func CreatePsbtWithPeer(tx *wire.MsgTx, prevFetcher txscript.PrevOutputFetcher,
 			continue
 		}
 	}
+	if localWallet != nil {
+		fillPsbtDerivation(localWallet, packet)
+	}
 
 	return packet, nil
 }
@@ -17,11 +17,20 @@ type WalletCatalogAccount struct {
 	PubKey  string `json:"pub_key,omitempty"`
 }
 
+// BIP85子钱包的主钱包，ParentID为0表示主钱包不在本地
+type WalletCatalogParent struct {
+	ParentID    int64  `json:"parent_id,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+	Index       uint32 `json:"index"`
+	Words       int    `json:"words"`
+}
+
 type WalletCatalogEntry struct {
 	ID          int64                  `json:"id"`
 	Name        string                 `json:"name"`
 	Fingerprint string                 `json:"fingerprint,omitempty"`
 	Accounts    []WalletCatalogAccount `json:"accounts"`
+	Parent      *WalletCatalogParent   `json:"parent,omitempty"`
 }
 
 func defaultWalletName(position int) string {
@@ -122,6 +131,12 @@ func (p *Manager) GetWalletCatalog() []WalletCatalogEntry {
 		ids = append(ids, id)
 	}
 	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	fingerprints := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		if info := p.walletInfoMap[id]; info != nil && info.Wallet != nil {
+			fingerprints[walletFingerprint(info.Wallet)] = id
+		}
+	}
 	result := make([]WalletCatalogEntry, 0, len(ids))
 	for position, id := range ids {
 		info := p.walletInfoMap[id]
@@ -138,6 +153,14 @@ func (p *Manager) GetWalletCatalog() []WalletCatalogEntry {
 			Fingerprint: walletFingerprint(info.Wallet),
 			Accounts:    make([]WalletCatalogAccount, 0, info.Accounts),
 		}
+		if info.Bip85 != nil {
+			entry.Parent = &WalletCatalogParent{
+				ParentID:    fingerprints[info.Bip85.Parent],
+				Fingerprint: info.Bip85.Parent,
+				Index:       info.Bip85.Index,
+				Words:       info.Bip85.Words,
+			}
+		}
 		for index := uint32(0); index < uint32(info.Accounts); index++ {
 			account := WalletCatalogAccount{
 				Index: index,
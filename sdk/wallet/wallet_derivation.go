@@ -0,0 +1,383 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
+)
+
+/*
+PSBT中的key origin信息（BIP174/BIP371）:
+  ECDSA输入/输出（P2WPKH, P2SH-P2WPKH, P2PKH）:  Bip32Derivation
+  p2tr输入/输出:                             TaprootBip32Derivation + TaprootInternalKey
+路径格式: m/purpose'/0'/0'/change/index，跟钱包地址的派生方式一致。
+
+外部签名器可以根据这些信息找到对应的私钥进行签名；
+本钱包也可以根据输入中的派生路径派生私钥签名，这样一个PSBT可以同时花费多个子账户和找零地址的utxo。
+*/
+
+// 支持BIP32派生路径的钱包
+type PsbtDerivationWallet interface {
+	GetMasterFingerprint() uint32
+	FillPsbtDerivation(packet *psbt.Packet) error
+	SignPsbtByDerivation(packet *psbt.Packet) error
+}
+
+type keyOrigin struct {
+	addrType string
+	pubKey   *secp256k1.PublicKey
+	path     []uint32
+}
+
+// 钱包地址的派生路径
+func walletDerivationPath(addrType string, change, index uint32) []uint32 {
+	return []uint32{
+		hdkeychain.HardenedKeyStart + getPurposeFromAddrType(addrType),
+		hdkeychain.HardenedKeyStart,
+		hdkeychain.HardenedKeyStart,
+		change,
+		index,
+	}
+}
+
+// 转换为 m/86'/0'/0'/0/1 格式
+func DerivationPathToString(path []uint32) string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, element := range path {
+		sb.WriteString("/")
+		if element >= hdkeychain.HardenedKeyStart {
+			sb.WriteString(strconv.FormatUint(uint64(element-hdkeychain.HardenedKeyStart), 10))
+			sb.WriteString("'")
+		} else {
+			sb.WriteString(strconv.FormatUint(uint64(element), 10))
+		}
+	}
+	return sb.String()
+}
+
+// 主密钥指纹，按照psbt中的编码方式（小端）转换为uint32
+func masterKeyFingerprint(masterkey *hdkeychain.ExtendedKey) (uint32, error) {
+	pubKey, err := masterkey.ECPubKey()
+	if err != nil {
+		return 0, err
+	}
+	hash := btcutil.Hash160(pubKey.SerializeCompressed())
+	return binary.LittleEndian.Uint32(hash[:4]), nil
+}
+
+// 非HD钱包返回0
+func (p *InternalWallet) GetMasterFingerprint() uint32 {
+	if p.masterkey == nil {
+		return 0
+	}
+	fingerprint, err := masterKeyFingerprint(p.masterkey)
+	if err != nil {
+		Log.Errorf("masterKeyFingerprint failed. %v", err)
+		return 0
+	}
+	return fingerprint
+}
+
+// 已经派生过的子账户，加上当前子账户
+func (p *InternalWallet) knownIndexes() []uint32 {
+	indexes := map[uint32]bool{p.currentIndex: true}
+	for index := range p.addresses {
+		indexes[index] = true
+	}
+	for index := range p.paymentPrivKeys {
+		indexes[index] = true
+	}
+	for id := range p.typedPrivKeys {
+		parts := strings.Split(id, "/")
+		if len(parts) != 3 {
+			continue
+		}
+		index, err := strconv.ParseUint(parts[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		indexes[uint32(index)] = true
+	}
+	result := make([]uint32, 0, len(indexes))
+	for index := range indexes {
+		result = append(result, index)
+	}
+	return result
+}
+
+// 在已知的子账户中查找锁定脚本对应的派生路径，key: 锁定脚本
+func (p *InternalWallet) collectKeyOrigins() map[string]*keyOrigin {
+	result := make(map[string]*keyOrigin)
+	for _, index := range p.knownIndexes() {
+		for change := uint32(0); change <= 1; change++ {
+			for _, addrType := range SupportedAddressTypes {
+				privKey, err := p.getTypedPrivKey(addrType, change, index)
+				if err != nil {
+					continue
+				}
+				key, err := newPsbtSigningKey(addrType, privKey, p.netParamsL1)
+				if err != nil {
+					continue
+				}
+				result[string(key.pkScript)] = &keyOrigin{
+					addrType: addrType,
+					pubKey:   privKey.PubKey(),
+					path:     walletDerivationPath(addrType, change, index),
+				}
+			}
+		}
+	}
+	return result
+}
+
+func addBip32Derivation(derivations []*psbt.Bip32Derivation,
+	origin *keyOrigin, fingerprint uint32) []*psbt.Bip32Derivation {
+	pubKey := origin.pubKey.SerializeCompressed()
+	for _, d := range derivations {
+		if bytes.Equal(d.PubKey, pubKey) {
+			return derivations
+		}
+	}
+	return append(derivations, &psbt.Bip32Derivation{
+		PubKey:               pubKey,
+		MasterKeyFingerprint: fingerprint,
+		Bip32Path:            origin.path,
+	})
+}
+
+func addTaprootBip32Derivation(derivations []*psbt.TaprootBip32Derivation,
+	origin *keyOrigin, fingerprint uint32) []*psbt.TaprootBip32Derivation {
+	xOnlyPubKey := schnorr.SerializePubKey(origin.pubKey)
+	for _, d := range derivations {
+		if bytes.Equal(d.XOnlyPubKey, xOnlyPubKey) {
+			return derivations
+		}
+	}
+	return append(derivations, &psbt.TaprootBip32Derivation{
+		XOnlyPubKey:          xOnlyPubKey,
+		MasterKeyFingerprint: fingerprint,
+		Bip32Path:            origin.path,
+	})
+}
+
+// 为属于本钱包的输入和输出（找零）填写派生路径。只查找已经派生过的子账户，包括找零链
+func (p *InternalWallet) FillPsbtDerivation(packet *psbt.Packet) error {
+	if p.masterkey == nil {
+		// 私钥钱包没有派生路径
+		return nil
+	}
+	fingerprint, err := masterKeyFingerprint(p.masterkey)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	origins := p.collectKeyOrigins()
+	p.mutex.Unlock()
+
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+		if in.WitnessUtxo == nil {
+			continue
+		}
+		origin, ok := origins[string(in.WitnessUtxo.PkScript)]
+		if !ok {
+			continue
+		}
+		if origin.addrType == ADDRESS_TYPE_P2TR {
+			if len(in.TaprootMerkleRoot) != 0 {
+				continue
+			}
+			in.TaprootInternalKey = schnorr.SerializePubKey(origin.pubKey)
+			in.TaprootBip32Derivation = addTaprootBip32Derivation(
+				in.TaprootBip32Derivation, origin, fingerprint)
+		} else {
+			in.Bip32Derivation = addBip32Derivation(in.Bip32Derivation, origin, fingerprint)
+		}
+	}
+
+	for i, txOut := range packet.UnsignedTx.TxOut {
+		origin, ok := origins[string(txOut.PkScript)]
+		if !ok {
+			continue
+		}
+		out := &packet.Outputs[i]
+		if origin.addrType == ADDRESS_TYPE_P2TR {
+			out.TaprootInternalKey = schnorr.SerializePubKey(origin.pubKey)
+			out.TaprootBip32Derivation = addTaprootBip32Derivation(
+				out.TaprootBip32Derivation, origin, fingerprint)
+		} else {
+			out.Bip32Derivation = addBip32Derivation(out.Bip32Derivation, origin, fingerprint)
+		}
+	}
+	return nil
+}
+
+func (p *InternalWallet) derivePrivKeyByPath(path []uint32) (*secp256k1.PrivateKey, error) {
+	if p.masterkey == nil {
+		return nil, fmt.Errorf("can't derive key without master key")
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty derivation path")
+	}
+	key := p.masterkey
+	var err error
+	for _, element := range path {
+		key, err = key.Derive(element)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key.ECPrivKey()
+}
+
+// 根据派生路径得到输入的签名私钥，必须是本钱包的主密钥指纹，并且派生出的公钥跟psbt中的一致
+func (p *InternalWallet) getDerivationSigningKey(in *psbt.PInput,
+	fingerprint uint32) (*psbtSigningKey, *secp256k1.PrivateKey, error) {
+
+	var privKey *secp256k1.PrivateKey
+	for _, d := range in.TaprootBip32Derivation {
+		if d.MasterKeyFingerprint != fingerprint || len(d.LeafHashes) != 0 {
+			continue
+		}
+		key, err := p.derivePrivKeyByPath(d.Bip32Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !bytes.Equal(schnorr.SerializePubKey(key.PubKey()), d.XOnlyPubKey) {
+			return nil, nil, fmt.Errorf("pubkey mismatch with derivation path %s",
+				DerivationPathToString(d.Bip32Path))
+		}
+		privKey = key
+		break
+	}
+	if privKey == nil {
+		for _, d := range in.Bip32Derivation {
+			if d.MasterKeyFingerprint != fingerprint {
+				continue
+			}
+			key, err := p.derivePrivKeyByPath(d.Bip32Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !bytes.Equal(key.PubKey().SerializeCompressed(), d.PubKey) {
+				return nil, nil, fmt.Errorf("pubkey mismatch with derivation path %s",
+					DerivationPathToString(d.Bip32Path))
+			}
+			privKey = key
+			break
+		}
+	}
+	if privKey == nil {
+		return nil, nil, nil
+	}
+
+	// 根据锁定脚本确定地址类型
+	for _, addrType := range SupportedAddressTypes {
+		key, err := newPsbtSigningKey(addrType, privKey, p.netParamsL1)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(key.pkScript, in.WitnessUtxo.PkScript) {
+			return key, privKey, nil
+		}
+	}
+	// 可能是多签脚本
+	return nil, privKey, nil
+}
+
+// 遍历每个输入的派生路径，派生对应的私钥进行签名。没有本钱包派生路径的输入会被忽略
+func (p *InternalWallet) SignPsbtByDerivation(packet *psbt.Packet) error {
+	if p.masterkey == nil {
+		return fmt.Errorf("can't sign by derivation path without master key")
+	}
+	fingerprint, err := masterKeyFingerprint(p.masterkey)
+	if err != nil {
+		return err
+	}
+	err = psbt.InputsReadyToSign(packet)
+	if err != nil {
+		return err
+	}
+
+	tx := packet.UnsignedTx
+	prevOutputFetcher := PsbtPrevOutputFetcher(packet)
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutputFetcher)
+	signed := 0
+	for i := range tx.TxIn {
+		in := &packet.Inputs[i]
+		if in.WitnessUtxo == nil {
+			continue
+		}
+		if len(in.FinalScriptWitness) > 0 || len(in.FinalScriptSig) > 0 || len(in.TaprootKeySpendSig) > 0 {
+			continue
+		}
+
+		key, privKey, err := p.getDerivationSigningKey(in, fingerprint)
+		if err != nil {
+			Log.Errorf("input %d: %v", i, err)
+			return err
+		}
+		if privKey == nil {
+			continue
+		}
+		if key != nil {
+			err = signPsbtInputWithKey(in, tx, sigHashes, i, key)
+			if err != nil {
+				return err
+			}
+			signed++
+			continue
+		}
+
+		// 多签脚本，只签名，由调用方组装见证
+		if len(in.WitnessScript) == 0 {
+			continue
+		}
+		mulpkScript, err := utils.WitnessScriptHash(in.WitnessScript)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(in.WitnessUtxo.PkScript, mulpkScript) {
+			continue
+		}
+		sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, i, in.WitnessUtxo.Value,
+			in.WitnessScript, in.SighashType, privKey)
+		if err != nil {
+			return err
+		}
+		in.PartialSigs = append(in.PartialSigs, &psbt.PartialSig{
+			PubKey:    privKey.PubKey().SerializeCompressed(),
+			Signature: sig,
+		})
+		signed++
+	}
+	if signed == 0 {
+		return fmt.Errorf("no input matches the derivation path of this wallet")
+	}
+	return nil
+}
+
+// 如果钱包支持，为psbt填写派生路径
+func fillPsbtDerivation(localWallet common.Wallet, packet *psbt.Packet) {
+	w, ok := localWallet.(PsbtDerivationWallet)
+	if !ok {
+		return
+	}
+	err := w.FillPsbtDerivation(packet)
+	if err != nil {
+		Log.Warnf("FillPsbtDerivation failed. %v", err)
+	}
+}
@@ -66,6 +66,15 @@ func (p *MonitorWallet) GetPubKeyByIndex(uint32) *secp256k1.PublicKey {
 func (p *MonitorWallet) GetAddressByIndex(uint32) string {
 	return p.address
 }
+func (p *MonitorWallet) GetAddressByType(addrType string, index uint32) string {
+	return ""
+}
+func (p *MonitorWallet) GetAddressesByIndex(uint32) map[string]string {
+	return nil
+}
+func (p *MonitorWallet) GetChangeAddressByIndex(uint32) string {
+	return ""
+}
 func (p *MonitorWallet) GetNodePubKey() *secp256k1.PublicKey {
 	return nil
 }
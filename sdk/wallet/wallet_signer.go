@@ -0,0 +1,473 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+)
+
+// 使用外部签名器的钱包，本地不保存任何私钥，公钥和地址从签名器获取后缓存
+type SignerWallet struct {
+	signer       Signer
+	info         *SignerInfo
+	nodePubKey   *secp256k1.PublicKey
+	currentIndex uint32
+
+	pubKeys        map[uint32]*secp256k1.PublicKey // key: index
+	addresses      map[string]string               // key: addrType/change/index
+	channelWallets map[uint32]*signerChannelWallet
+
+	mutex sync.RWMutex
+}
+
+func NewSignerWallet(signer Signer) (*SignerWallet, error) {
+	info, err := signer.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info.Network != GetChainParam().Name {
+		return nil, fmt.Errorf("signer is running on %s, expected %s", info.Network, GetChainParam().Name)
+	}
+	nodePubKey, err := secp256k1.ParsePubKey(info.NodePubKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node pubkey. %v", err)
+	}
+	return &SignerWallet{
+		signer:         signer,
+		info:           info,
+		nodePubKey:     nodePubKey,
+		pubKeys:        make(map[uint32]*secp256k1.PublicKey),
+		addresses:      make(map[string]string),
+		channelWallets: make(map[uint32]*signerChannelWallet),
+	}, nil
+}
+
+func (p *SignerWallet) GetSigner() Signer {
+	return p.signer
+}
+
+func (p *SignerWallet) Clone() common.Wallet {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	cloned := &SignerWallet{
+		signer:         p.signer,
+		info:           p.info,
+		nodePubKey:     p.nodePubKey,
+		currentIndex:   p.currentIndex,
+		pubKeys:        make(map[uint32]*secp256k1.PublicKey),
+		addresses:      make(map[string]string),
+		channelWallets: make(map[uint32]*signerChannelWallet),
+	}
+	for k, v := range p.pubKeys {
+		cloned.pubKeys[k] = v
+	}
+	for k, v := range p.addresses {
+		cloned.addresses[k] = v
+	}
+	return cloned
+}
+
+func (p *SignerWallet) CloneByPubKey(pubkey []byte) common.Wallet {
+	i := uint32(0)
+	for ; i < 1000; i++ {
+		pk := p.GetPubKeyByIndex(i)
+		if pk == nil {
+			return nil
+		}
+		if bytes.Equal(pk.SerializeCompressed(), pubkey) {
+			break
+		}
+	}
+	if i >= 1000 {
+		return nil
+	}
+	n := p.Clone()
+	n.SetSubAccount(i)
+	return n
+}
+
+func (p *SignerWallet) GetId() int64 {
+	return p.info.Id
+}
+
+func (p *SignerWallet) GetWalletId() common.WalletId {
+	return common.WalletId{
+		Id:           p.info.Id,
+		SubAccountId: p.GetSubAccount(),
+	}
+}
+
+func (p *SignerWallet) SetSubAccount(id uint32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.currentIndex = id
+}
+
+func (p *SignerWallet) GetSubAccount() uint32 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.currentIndex
+}
+
+func (p *SignerWallet) GetPubKey() *secp256k1.PublicKey {
+	return p.GetPubKeyByIndex(p.GetSubAccount())
+}
+
+func (p *SignerWallet) GetAddress() string {
+	return p.GetAddressByIndex(p.GetSubAccount())
+}
+
+func (p *SignerWallet) GetPubKeyByIndex(index uint32) *secp256k1.PublicKey {
+	p.mutex.RLock()
+	pubKey, ok := p.pubKeys[index]
+	p.mutex.RUnlock()
+	if ok {
+		return pubKey
+	}
+
+	data, err := p.signer.GetPubKey(&SignerKey{Index: index}, SIGNER_KEY_PAYMENT)
+	if err != nil {
+		Log.Errorf("GetPubKey %d failed. %v", index, err)
+		return nil
+	}
+	pubKey, err = secp256k1.ParsePubKey(data)
+	if err != nil {
+		Log.Errorf("ParsePubKey failed. %v", err)
+		return nil
+	}
+	p.mutex.Lock()
+	p.pubKeys[index] = pubKey
+	p.mutex.Unlock()
+	return pubKey
+}
+
+func (p *SignerWallet) getAddress(addrType string, change, index uint32) string {
+	id := fmt.Sprintf("%s/%d/%d", addrType, change, index)
+	p.mutex.RLock()
+	address, ok := p.addresses[id]
+	p.mutex.RUnlock()
+	if ok {
+		return address
+	}
+
+	address, err := p.signer.GetAddress(&SignerKey{Index: index}, addrType, change)
+	if err != nil {
+		Log.Errorf("GetAddress %s failed. %v", id, err)
+		return ""
+	}
+	p.mutex.Lock()
+	p.addresses[id] = address
+	p.mutex.Unlock()
+	return address
+}
+
+func (p *SignerWallet) GetAddressByIndex(index uint32) string {
+	return p.getAddress(ADDRESS_TYPE_P2TR, 0, index)
+}
+
+func (p *SignerWallet) GetAddressByType(addrType string, index uint32) string {
+	if !IsSupportedAddressType(addrType) {
+		return ""
+	}
+	return p.getAddress(addrType, 0, index)
+}
+
+func (p *SignerWallet) GetAddressesByIndex(index uint32) map[string]string {
+	result := make(map[string]string)
+	for _, addrType := range SupportedAddressTypes {
+		addr := p.getAddress(addrType, 0, index)
+		if addr != "" {
+			result[addrType] = addr
+		}
+	}
+	return result
+}
+
+func (p *SignerWallet) GetChangeAddressByIndex(index uint32) string {
+	return p.getAddress(ADDRESS_TYPE_P2TR, 1, index)
+}
+
+func (p *SignerWallet) GetNodePubKey() *secp256k1.PublicKey {
+	return p.nodePubKey
+}
+
+func (p *SignerWallet) currentKey() *SignerKey {
+	return &SignerKey{Index: p.GetSubAccount()}
+}
+
+func (p *SignerWallet) GetCommitSecret(peer []byte, index uint32) *secp256k1.PrivateKey {
+	key := p.currentKey()
+	key.Peer = peer
+	return signerGetCommitSecret(p.signer, key, index)
+}
+
+// revocation 私钥不离开签名器，惩罚交易用 SignPunishTx 签名
+func (p *SignerWallet) DeriveRevocationPrivKey(commitsecret *secp256k1.PrivateKey) *secp256k1.PrivateKey {
+	return nil
+}
+
+// 签名器派生 revocation 私钥，签名结果写回 tx 的 witness
+func (p *SignerWallet) SignPunishTx(commitsecret *secp256k1.PrivateKey, tx *wire.MsgTx,
+	prevFetcher txscript.PrevOutputFetcher, scripts [][]byte) error {
+	if commitsecret == nil || len(scripts) != len(tx.TxIn) {
+		return fmt.Errorf("invalid punish tx")
+	}
+	packet, err := psbt.NewFromUnsignedTx(RemoveSignatures(tx))
+	if err != nil {
+		return err
+	}
+	for i, txIn := range tx.TxIn {
+		preOut := prevFetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if preOut == nil {
+			return fmt.Errorf("can't find outpoint %s", txIn.PreviousOutPoint)
+		}
+		packet.Inputs[i].WitnessUtxo = preOut
+		packet.Inputs[i].WitnessScript = scripts[i]
+		packet.Inputs[i].SighashType = txscript.SigHashAll
+	}
+	var buf bytes.Buffer
+	err = packet.Serialize(&buf)
+	if err != nil {
+		return err
+	}
+	signed, err := p.signer.SignPunishPsbt(p.currentKey(), commitsecret.Serialize(), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	packet, err = psbt.NewFromRawBytes(bytes.NewReader(signed), false)
+	if err != nil {
+		return err
+	}
+	if len(packet.Inputs) != len(tx.TxIn) {
+		return fmt.Errorf("signer returns %d inputs, expected %d", len(packet.Inputs), len(tx.TxIn))
+	}
+	for i, txIn := range tx.TxIn {
+		if len(packet.Inputs[i].PartialSigs) == 0 {
+			return fmt.Errorf("input %d is not signed", i)
+		}
+		txIn.Witness = punishWitness(packet.Inputs[i].PartialSigs[0].Signature, scripts[i])
+	}
+	return nil
+}
+
+func (p *SignerWallet) GetRevocationBaseKey() *secp256k1.PublicKey {
+	return signerGetPubKey(p.signer, p.currentKey(), SIGNER_KEY_REVOCATION_BASE)
+}
+
+func (p *SignerWallet) GetPaymentPubKey() *secp256k1.PublicKey {
+	return p.GetPubKey()
+}
+
+func (p *SignerWallet) SignMessage(msg []byte) ([]byte, error) {
+	return p.signer.SignMessage(p.currentKey(), msg)
+}
+
+func (p *SignerWallet) SignWalletMessage(msg string) ([]byte, error) {
+	return p.signer.SignWalletMessage(p.currentKey(), msg)
+}
+
+func (p *SignerWallet) SignPsbt(packet *psbt.Packet) error {
+	return signerSignPsbts(p.signer, p.currentKey(), []*psbt.Packet{packet})
+}
+
+func (p *SignerWallet) SignPsbt_SatsNet(packet *spsbt.Packet) error {
+	return signerSignPsbts_SatsNet(p.signer, p.currentKey(), []*spsbt.Packet{packet})
+}
+
+func (p *SignerWallet) SignPsbts(packets []*psbt.Packet) error {
+	return signerSignPsbts(p.signer, p.currentKey(), packets)
+}
+
+func (p *SignerWallet) SignPsbts_SatsNet(packets []*spsbt.Packet) error {
+	return signerSignPsbts_SatsNet(p.signer, p.currentKey(), packets)
+}
+
+func (p *SignerWallet) SignMessageWithIndex(msg []byte, index uint32) ([]byte, error) {
+	return p.signer.SignMessage(&SignerKey{Index: index}, msg)
+}
+
+func (p *SignerWallet) SignWalletMessageWithIndex(msg string, index uint32) ([]byte, error) {
+	return p.signer.SignWalletMessage(&SignerKey{Index: index}, msg)
+}
+
+func (p *SignerWallet) SignPsbtWithIndex(packet *psbt.Packet, index uint32) error {
+	return signerSignPsbts(p.signer, &SignerKey{Index: index}, []*psbt.Packet{packet})
+}
+
+func (p *SignerWallet) SignPsbtWithIndex_SatsNet(packet *spsbt.Packet, index uint32) error {
+	return signerSignPsbts_SatsNet(p.signer, &SignerKey{Index: index}, []*spsbt.Packet{packet})
+}
+
+func (p *SignerWallet) SignPsbtsWithIndex(packets []*psbt.Packet, index uint32) error {
+	return signerSignPsbts(p.signer, &SignerKey{Index: index}, packets)
+}
+
+func (p *SignerWallet) SignPsbtsWithIndex_SatsNet(packets []*spsbt.Packet, index uint32) error {
+	return signerSignPsbts_SatsNet(p.signer, &SignerKey{Index: index}, packets)
+}
+
+func (p *SignerWallet) CreateChannelWallet(peer []byte, id uint32) common.ChannelWallet {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	subWallet := &signerChannelWallet{
+		signer: p.signer,
+		peerId: peer,
+		id:     id,
+	}
+	p.channelWallets[id] = subWallet
+	return subWallet
+}
+
+func (p *SignerWallet) GetChannelWallet(id uint32) common.ChannelWallet {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	subWallet, ok := p.channelWallets[id]
+	if !ok {
+		return nil
+	}
+	return subWallet
+}
+
+// 通道钱包，承诺交易的签名也交给签名器
+type signerChannelWallet struct {
+	signer Signer
+	peerId []byte
+	id     uint32
+}
+
+func (p *signerChannelWallet) key() *SignerKey {
+	return &SignerKey{Index: p.id, Channel: true, Peer: p.peerId}
+}
+
+func (p *signerChannelWallet) GetSubAccount() uint32 {
+	return p.id
+}
+
+func (p *signerChannelWallet) GetCommitSecret(index uint32) *secp256k1.PrivateKey {
+	return signerGetCommitSecret(p.signer, p.key(), index)
+}
+
+// revocation 私钥不离开签名器
+func (p *signerChannelWallet) DeriveRevocationPrivKey(commitsecret *secp256k1.PrivateKey) *secp256k1.PrivateKey {
+	return nil
+}
+
+func (p *signerChannelWallet) GetRevocationBaseKey() *secp256k1.PublicKey {
+	return signerGetPubKey(p.signer, p.key(), SIGNER_KEY_REVOCATION_BASE)
+}
+
+func (p *signerChannelWallet) GetPaymentPubKey() *secp256k1.PublicKey {
+	return signerGetPubKey(p.signer, p.key(), SIGNER_KEY_PAYMENT)
+}
+
+func (p *signerChannelWallet) SignMessage(msg []byte) ([]byte, error) {
+	return p.signer.SignMessage(p.key(), msg)
+}
+
+func (p *signerChannelWallet) SignPsbt(packet *psbt.Packet) error {
+	return signerSignPsbts(p.signer, p.key(), []*psbt.Packet{packet})
+}
+
+func (p *signerChannelWallet) SignPsbt_SatsNet(packet *spsbt.Packet) error {
+	return signerSignPsbts_SatsNet(p.signer, p.key(), []*spsbt.Packet{packet})
+}
+
+func (p *signerChannelWallet) SignPsbts(packets []*psbt.Packet) error {
+	return signerSignPsbts(p.signer, p.key(), packets)
+}
+
+func (p *signerChannelWallet) SignPsbts_SatsNet(packets []*spsbt.Packet) error {
+	return signerSignPsbts_SatsNet(p.signer, p.key(), packets)
+}
+
+func signerGetPubKey(signer Signer, key *SignerKey, keyType string) *secp256k1.PublicKey {
+	data, err := signer.GetPubKey(key, keyType)
+	if err != nil {
+		Log.Errorf("GetPubKey %s %d failed. %v", keyType, key.Index, err)
+		return nil
+	}
+	pubKey, err := secp256k1.ParsePubKey(data)
+	if err != nil {
+		Log.Errorf("ParsePubKey failed. %v", err)
+		return nil
+	}
+	return pubKey
+}
+
+func signerGetCommitSecret(signer Signer, key *SignerKey, index uint32) *secp256k1.PrivateKey {
+	data, err := signer.GetCommitSecret(key, index)
+	if err != nil || len(data) != 32 {
+		Log.Errorf("GetCommitSecret %d failed. %v", index, err)
+		return nil
+	}
+	return secp256k1.PrivKeyFromBytes(data)
+}
+
+// 签名后的结果写回原来的packet，调用方持有的指针仍然有效
+func signerSignPsbts(signer Signer, key *SignerKey, packets []*psbt.Packet) error {
+	data := make([][]byte, 0, len(packets))
+	for _, packet := range packets {
+		var buf bytes.Buffer
+		err := packet.Serialize(&buf)
+		if err != nil {
+			return err
+		}
+		data = append(data, buf.Bytes())
+	}
+	signed, err := signer.SignPsbts(key, data)
+	if err != nil {
+		return err
+	}
+	if len(signed) != len(packets) {
+		return fmt.Errorf("signer returns %d psbts, expected %d", len(signed), len(packets))
+	}
+	for i, b := range signed {
+		packet, err := psbt.NewFromRawBytes(bytes.NewReader(b), false)
+		if err != nil {
+			return err
+		}
+		if packet.UnsignedTx.TxHash() != packets[i].UnsignedTx.TxHash() {
+			return fmt.Errorf("signer changed the transaction %d", i)
+		}
+		*packets[i] = *packet
+	}
+	return nil
+}
+
+func signerSignPsbts_SatsNet(signer Signer, key *SignerKey, packets []*spsbt.Packet) error {
+	data := make([][]byte, 0, len(packets))
+	for _, packet := range packets {
+		var buf bytes.Buffer
+		err := packet.Serialize(&buf)
+		if err != nil {
+			return err
+		}
+		data = append(data, buf.Bytes())
+	}
+	signed, err := signer.SignPsbts_SatsNet(key, data)
+	if err != nil {
+		return err
+	}
+	if len(signed) != len(packets) {
+		return fmt.Errorf("signer returns %d psbts, expected %d", len(signed), len(packets))
+	}
+	for i, b := range signed {
+		packet, err := spsbt.NewFromRawBytes(bytes.NewReader(b), false)
+		if err != nil {
+			return err
+		}
+		if packet.UnsignedTx.TxHash() != packets[i].UnsignedTx.TxHash() {
+			return fmt.Errorf("signer changed the transaction %d", i)
+		}
+		*packets[i] = *packet
+	}
+	return nil
+}
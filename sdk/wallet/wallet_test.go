@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	indexerwire "github.com/sat20-labs/indexer/rpcserver/wire"
 	"github.com/sat20-labs/sat20wallet/sdk/wallet/utils"
 )
 
@@ -357,3 +361,360 @@ func TestDecryptErrors(t *testing.T) {
 		t.Fatalf("expected auth error when decrypting with wrong shared secret")
 	}
 }
+
+func TestAddressTypes(t *testing.T) {
+	// BIP44/49/84/86 test vectors
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	wallet := NewInternalWalletWithMnemonic(mnemonic, "", &chaincfg.MainNetParams)
+	if wallet == nil {
+		t.Fatal("NewInternalWalletWithMnemonic failed")
+	}
+
+	expected := map[string]string{
+		ADDRESS_TYPE_P2TR:        "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		ADDRESS_TYPE_P2WPKH:      "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		ADDRESS_TYPE_P2SH_P2WPKH: "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf",
+		ADDRESS_TYPE_P2PKH:       "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+	}
+	addresses := wallet.GetAddressesByIndex(0)
+	for addrType, addr := range expected {
+		if addresses[addrType] != addr {
+			t.Fatalf("%s address %s, expected %s", addrType, addresses[addrType], addr)
+		}
+	}
+	if wallet.GetAddress() != expected[ADDRESS_TYPE_P2TR] {
+		t.Fatalf("default address should be p2tr")
+	}
+
+	cloned := wallet.Clone()
+	if cloned.GetAddressByType(ADDRESS_TYPE_P2WPKH, 0) != expected[ADDRESS_TYPE_P2WPKH] {
+		t.Fatalf("cloned wallet has different address")
+	}
+	if wallet.GetAddressByType("P2WSH", 0) != "" {
+		t.Fatalf("unsupported address type should return empty address")
+	}
+}
+
+func TestSignPsbtWithAddressTypes(t *testing.T) {
+	wallet, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet.SetSubAccount(1)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, addrType := range SupportedAddressTypes {
+		pkScript, err := GetPkScriptFromAddress(wallet.GetAddressByType(addrType, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outpoint := wire.OutPoint{Index: uint32(i)}
+		outpoint.Hash[0] = byte(i + 1)
+		tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+		prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+	}
+	destPkScript, err := GetPkScriptFromAddress(wallet.GetAddressByIndex(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(&wire.TxOut{Value: 39000, PkScript: destPkScript})
+
+	signedTx, err := SignTxWithWallet(wallet, tx, prevFetcher)
+	if err != nil {
+		t.Fatalf("SignTxWithWallet failed. %v", err)
+	}
+	if len(signedTx.TxIn[3].SignatureScript) == 0 {
+		t.Fatalf("p2pkh input should have signature script")
+	}
+}
+
+func TestPsbtDerivation(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	wallet := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	if wallet == nil {
+		t.Fatal("NewInternalWalletWithMnemonic failed")
+	}
+	fingerprint := wallet.GetMasterFingerprint()
+	if fingerprint != 0x0adac573 { // 73c5da0a
+		t.Fatalf("invalid master fingerprint %x", fingerprint)
+	}
+
+	type spend struct {
+		addrType string
+		change   uint32
+		index    uint32
+	}
+	spends := []spend{
+		{ADDRESS_TYPE_P2TR, 0, 0},
+		{ADDRESS_TYPE_P2TR, 1, 3},
+		{ADDRESS_TYPE_P2WPKH, 0, 2},
+		{ADDRESS_TYPE_P2SH_P2WPKH, 1, 5},
+		{ADDRESS_TYPE_P2PKH, 0, 7},
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, s := range spends {
+		pkScript, err := GetPkScriptFromAddress(wallet.GetAddressByPathWithType(s.addrType, s.change, s.index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outpoint := wire.OutPoint{Index: uint32(i)}
+		outpoint.Hash[0] = byte(i + 1)
+		tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+		prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+	}
+	changePkScript, err := GetPkScriptFromAddress(wallet.GetAddressByPathWithType(ADDRESS_TYPE_P2TR, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(&wire.TxOut{Value: 48000, PkScript: changePkScript})
+
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wallet.FillPsbtDerivation(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range spends {
+		in := &packet.Inputs[i]
+		var path []uint32
+		var fp uint32
+		if s.addrType == ADDRESS_TYPE_P2TR {
+			if len(in.TaprootBip32Derivation) != 1 || len(in.TaprootInternalKey) == 0 {
+				t.Fatalf("input %d has no taproot derivation", i)
+			}
+			path = in.TaprootBip32Derivation[0].Bip32Path
+			fp = in.TaprootBip32Derivation[0].MasterKeyFingerprint
+		} else {
+			if len(in.Bip32Derivation) != 1 {
+				t.Fatalf("input %d has no derivation", i)
+			}
+			path = in.Bip32Derivation[0].Bip32Path
+			fp = in.Bip32Derivation[0].MasterKeyFingerprint
+		}
+		expected := DerivationPathToString(walletDerivationPath(s.addrType, s.change, s.index))
+		if DerivationPathToString(path) != expected || fp != fingerprint {
+			t.Fatalf("input %d derivation %s %x, expected %s", i, DerivationPathToString(path), fp, expected)
+		}
+	}
+	if DerivationPathToString(packet.Outputs[0].TaprootBip32Derivation[0].Bip32Path) != "m/86'/0'/0'/1/0" {
+		t.Fatalf("change output has invalid derivation")
+	}
+
+	// 新的钱包实例没有任何缓存，只能根据派生路径签名
+	signer := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	err = signer.SignPsbtByDerivation(packet)
+	if err != nil {
+		t.Fatalf("SignPsbtByDerivation failed. %v", err)
+	}
+	err = psbt.MaybeFinalizeAll(packet)
+	if err != nil {
+		t.Fatalf("MaybeFinalizeAll failed. %v", err)
+	}
+	signedTx, err := psbt.Extract(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifySignedTx(signedTx, prevFetcher)
+	if err != nil {
+		t.Fatalf("VerifySignedTx failed. %v", err)
+	}
+
+	// 其他钱包不能签名
+	other, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet2, err := CreatePsbt(tx, prevFetcher, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet.FillPsbtDerivation(packet2)
+	if other.SignPsbtByDerivation(packet2) == nil {
+		t.Fatalf("other wallet should not sign")
+	}
+}
+
+func TestChangeAddress(t *testing.T) {
+	wallet, _, err := NewInteralWallet(GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet.SetSubAccount(2)
+	changeAddr := wallet.GetChangeAddressByIndex(2)
+	if changeAddr == "" || changeAddr == wallet.GetAddressByIndex(2) {
+		t.Fatalf("invalid change address %s", changeAddr)
+	}
+	if changeAddr != wallet.GetAddressByPath(1, 2) {
+		t.Fatalf("change address should be m/86'/0'/0'/1/2")
+	}
+
+	// 同时花费接收地址和找零地址
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, addr := range []string{wallet.GetAddressByIndex(2), changeAddr} {
+		pkScript, err := GetPkScriptFromAddress(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outpoint := wire.OutPoint{Index: uint32(i)}
+		outpoint.Hash[0] = byte(i + 1)
+		tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+		prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+	}
+	destPkScript, err := GetPkScriptFromAddress(wallet.GetAddressByIndex(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(&wire.TxOut{Value: 19000, PkScript: destPkScript})
+
+	_, err = SignTxWithWallet(wallet, tx, prevFetcher)
+	if err != nil {
+		t.Fatalf("SignTxWithWallet failed. %v", err)
+	}
+
+	// 私钥钱包没有找零地址
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet2, _, err := NewInternalWalletWithPrivKey(privKey.Serialize(), GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet2.GetChangeAddressByIndex(0) != "" {
+		t.Fatalf("private key wallet should have no change address")
+	}
+}
+
+func TestMergePlainUtxos(t *testing.T) {
+	utxos := []*indexerwire.TxOutputInfo{
+		{OutPoint: "a:0", Value: 5000},
+		{OutPoint: "a:1", Value: 1000},
+	}
+	more := []*indexerwire.TxOutputInfo{
+		{OutPoint: "b:0", Value: 3000},
+		{OutPoint: "b:1", Value: 500},
+	}
+	result := mergePlainUtxos(utxos, more)
+	expected := []string{"a:0", "b:0", "a:1", "b:1"}
+	if len(result) != len(expected) {
+		t.Fatalf("invalid length %d", len(result))
+	}
+	for i, u := range result {
+		if u.OutPoint != expected[i] {
+			t.Fatalf("%d: %s, expected %s", i, u.OutPoint, expected[i])
+		}
+	}
+	if len(mergePlainUtxos(utxos, nil)) != 2 {
+		t.Fatalf("merge nil failed")
+	}
+}
+
+func TestWatchOnlyWallet(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	// BIP86 测试向量
+	mainnet := NewInternalWalletWithMnemonic(mnemonic, "", &chaincfg.MainNetParams)
+	desc, err := mainnet.GetAccountDescriptor(ADDRESS_TYPE_P2TR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "tr([73c5da0a/86'/0'/0']xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ/<0;1>/*)#"
+	if len(desc) != len(expected)+8 || desc[:len(expected)] != expected {
+		t.Fatalf("invalid descriptor %s", desc)
+	}
+
+	wallet := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	for _, addrType := range []string{ADDRESS_TYPE_P2TR, ADDRESS_TYPE_P2WPKH, ADDRESS_TYPE_P2SH_P2WPKH} {
+		desc, err := wallet.GetAccountDescriptor(addrType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watcher, err := NewWatchOnlyWallet(desc, GetChainParam())
+		if err != nil {
+			t.Fatalf("NewWatchOnlyWallet %s failed. %v", desc, err)
+		}
+		if watcher.GetDescriptor() != desc || watcher.GetMasterFingerprint() != wallet.GetMasterFingerprint() {
+			t.Fatalf("descriptor mismatch %s %s", watcher.GetDescriptor(), desc)
+		}
+		for index := uint32(0); index < 3; index++ {
+			if watcher.GetAddressByType(addrType, index) != wallet.GetAddressByPathWithType(addrType, 0, index) {
+				t.Fatalf("%s address %d mismatch", addrType, index)
+			}
+		}
+	}
+
+	// 校验和错误，私钥，其他网络
+	desc, _ = wallet.GetAccountDescriptor(ADDRESS_TYPE_P2TR)
+	if _, err := NewWatchOnlyWallet(desc[:len(desc)-1]+"q", GetChainParam()); err == nil {
+		t.Fatalf("invalid checksum should be rejected")
+	}
+	if _, err := NewWatchOnlyWallet(desc, &chaincfg.MainNetParams); err == nil {
+		t.Fatalf("descriptor of other network should be rejected")
+	}
+	if _, err := NewWatchOnlyWallet("wsh(multi(1,xpub))", GetChainParam()); err == nil {
+		t.Fatalf("unsupported descriptor should be rejected")
+	}
+
+	watcher, err := NewWatchOnlyWallet(desc, GetChainParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.SetSubAccount(1)
+	if watcher.GetAddress() != wallet.GetAddressByIndex(1) ||
+		watcher.GetChangeAddressByIndex(1) != wallet.GetChangeAddressByIndex(1) {
+		t.Fatalf("watch-only address mismatch")
+	}
+
+	// 观察钱包构造psbt，助记词钱包根据派生路径签名
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, addr := range []string{watcher.GetAddress(), watcher.GetChangeAddressByIndex(1)} {
+		pkScript, err := GetPkScriptFromAddress(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outpoint := wire.OutPoint{Index: uint32(i)}
+		outpoint.Hash[0] = byte(i + 1)
+		tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+		prevFetcher.AddPrevOut(outpoint, &wire.TxOut{Value: 10000, PkScript: pkScript})
+	}
+	destPkScript, err := GetPkScriptFromAddress(wallet.GetAddressByIndex(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(&wire.TxOut{Value: 19000, PkScript: destPkScript})
+
+	packet, err := CreatePsbt(tx, prevFetcher, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watcher.SignPsbt(packet) != ErrWatchOnlyWallet {
+		t.Fatalf("watch-only wallet should not sign")
+	}
+	err = watcher.FillPsbtDerivation(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewInternalWalletWithMnemonic(mnemonic, "", GetChainParam())
+	err = signer.SignPsbtByDerivation(packet)
+	if err != nil {
+		t.Fatalf("SignPsbtByDerivation failed. %v", err)
+	}
+	err = psbt.MaybeFinalizeAll(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := psbt.Extract(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifySignedTx(signedTx, prevFetcher)
+	if err != nil {
+		t.Fatalf("VerifySignedTx failed. %v", err)
+	}
+}
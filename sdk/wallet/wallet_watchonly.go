@@ -0,0 +1,360 @@
+package wallet
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/sat20-labs/sat20wallet/sdk/common"
+	spsbt "github.com/sat20-labs/satoshinet/btcutil/psbt"
+)
+
+var ErrWatchOnlyWallet = errors.New("watch-only wallet can't sign")
+
+// 观察钱包，由账户扩展公钥或者描述符创建，可以查询余额，构造未签名的psbt，但不能签名。
+// 子账户(index)对应描述符中的地址 xpub/0/index
+type WatchOnlyWallet struct {
+	desc         *AccountDescriptor
+	netParams    *chaincfg.Params
+	pubKeys      map[uint64]*secp256k1.PublicKey // key: change<<32+index
+	addresses    map[uint64]btcutil.Address      // key: change<<32+index
+	currentIndex uint32
+	id           int64
+
+	mutex sync.RWMutex
+}
+
+func NewWatchOnlyWallet(descriptor string, param *chaincfg.Params) (*WatchOnlyWallet, error) {
+	desc, err := ParseAccountDescriptor(descriptor, param)
+	if err != nil {
+		return nil, err
+	}
+	return &WatchOnlyWallet{
+		desc:      desc,
+		netParams: param,
+		pubKeys:   make(map[uint64]*secp256k1.PublicKey),
+		addresses: make(map[uint64]btcutil.Address),
+		id:        time.Now().UnixMicro(),
+	}, nil
+}
+
+func (p *WatchOnlyWallet) Clone() common.Wallet {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	cloned := &WatchOnlyWallet{
+		desc:         p.desc,
+		netParams:    p.netParams,
+		pubKeys:      make(map[uint64]*secp256k1.PublicKey),
+		addresses:    make(map[uint64]btcutil.Address),
+		currentIndex: p.currentIndex,
+		id:           p.id,
+	}
+	for k, v := range p.pubKeys {
+		cloned.pubKeys[k] = v
+	}
+	for k, v := range p.addresses {
+		cloned.addresses[k] = v
+	}
+	return cloned
+}
+
+func (p *WatchOnlyWallet) CloneByPubKey(pubkey []byte) common.Wallet {
+	return nil
+}
+
+func (p *WatchOnlyWallet) GetId() int64 {
+	return p.id
+}
+
+func (p *WatchOnlyWallet) GetWalletId() common.WalletId {
+	return common.WalletId{
+		Id:           p.id,
+		SubAccountId: p.GetSubAccount(),
+	}
+}
+
+// 描述符，带校验和
+func (p *WatchOnlyWallet) GetDescriptor() string {
+	return p.desc.String()
+}
+
+func (p *WatchOnlyWallet) GetAddressType() string {
+	return p.desc.AddrType
+}
+
+func (p *WatchOnlyWallet) SetSubAccount(id uint32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.currentIndex = id
+}
+
+func (p *WatchOnlyWallet) GetSubAccount() uint32 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.currentIndex
+}
+
+func (p *WatchOnlyWallet) getPubKey(change, index uint32) (*secp256k1.PublicKey, error) {
+	id := uint64(change)<<32 + uint64(index)
+	pubKey, ok := p.pubKeys[id]
+	if ok {
+		return pubKey, nil
+	}
+	changeKey, err := p.desc.AccountKey.Derive(change)
+	if err != nil {
+		return nil, err
+	}
+	key, err := changeKey.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err = key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	p.pubKeys[id] = pubKey
+	return pubKey, nil
+}
+
+func (p *WatchOnlyWallet) getAddress(change, index uint32) (btcutil.Address, error) {
+	id := uint64(change)<<32 + uint64(index)
+	address, ok := p.addresses[id]
+	if ok {
+		return address, nil
+	}
+	pubKey, err := p.getPubKey(change, index)
+	if err != nil {
+		return nil, err
+	}
+	address, err = getAddressFromPubKey(pubKey, p.desc.AddrType, p.netParams)
+	if err != nil {
+		return nil, err
+	}
+	p.addresses[id] = address
+	return address, nil
+}
+
+func (p *WatchOnlyWallet) getAddressString(change, index uint32) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	address, err := p.getAddress(change, index)
+	if err != nil {
+		Log.Errorf("getAddress %d %d failed. %v", change, index, err)
+		return ""
+	}
+	return address.EncodeAddress()
+}
+
+func (p *WatchOnlyWallet) GetPubKey() *secp256k1.PublicKey {
+	return p.GetPubKeyByIndex(p.GetSubAccount())
+}
+
+func (p *WatchOnlyWallet) GetAddress() string {
+	return p.GetAddressByIndex(p.GetSubAccount())
+}
+
+func (p *WatchOnlyWallet) GetPubKeyByIndex(index uint32) *secp256k1.PublicKey {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pubKey, err := p.getPubKey(0, index)
+	if err != nil {
+		Log.Errorf("getPubKey %d failed. %v", index, err)
+		return nil
+	}
+	return pubKey
+}
+
+func (p *WatchOnlyWallet) GetAddressByIndex(index uint32) string {
+	return p.getAddressString(0, index)
+}
+
+// 观察钱包只有描述符中的地址类型
+func (p *WatchOnlyWallet) GetAddressByType(addrType string, index uint32) string {
+	if addrType != p.desc.AddrType {
+		return ""
+	}
+	return p.GetAddressByIndex(index)
+}
+
+func (p *WatchOnlyWallet) GetAddressesByIndex(index uint32) map[string]string {
+	result := make(map[string]string)
+	addr := p.GetAddressByIndex(index)
+	if addr != "" {
+		result[p.desc.AddrType] = addr
+	}
+	return result
+}
+
+// 找零地址只支持p2tr
+func (p *WatchOnlyWallet) GetChangeAddressByIndex(index uint32) string {
+	if p.desc.AddrType != ADDRESS_TYPE_P2TR {
+		return ""
+	}
+	return p.getAddressString(1, index)
+}
+
+func (p *WatchOnlyWallet) GetNodePubKey() *secp256k1.PublicKey {
+	return nil
+}
+
+func (p *WatchOnlyWallet) GetCommitSecret(peer []byte, index uint32) *secp256k1.PrivateKey {
+	return nil
+}
+
+func (p *WatchOnlyWallet) DeriveRevocationPrivKey(commitsecret *secp256k1.PrivateKey) *secp256k1.PrivateKey {
+	return nil
+}
+
+func (p *WatchOnlyWallet) GetRevocationBaseKey() *secp256k1.PublicKey {
+	return nil
+}
+
+func (p *WatchOnlyWallet) GetPaymentPubKey() *secp256k1.PublicKey {
+	return p.GetPubKey()
+}
+
+func (p *WatchOnlyWallet) SignMessage(msg []byte) ([]byte, error) {
+	return nil, ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignWalletMessage(msg string) ([]byte, error) {
+	return nil, ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbt(packet *psbt.Packet) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbt_SatsNet(packet *spsbt.Packet) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbts(packet []*psbt.Packet) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbts_SatsNet(packet []*spsbt.Packet) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignMessageWithIndex(msg []byte, index uint32) ([]byte, error) {
+	return nil, ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignWalletMessageWithIndex(msg string, index uint32) ([]byte, error) {
+	return nil, ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbtWithIndex(packet *psbt.Packet, index uint32) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbtWithIndex_SatsNet(packet *spsbt.Packet, index uint32) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbtsWithIndex(packet []*psbt.Packet, index uint32) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) SignPsbtsWithIndex_SatsNet(packet []*spsbt.Packet, index uint32) error {
+	return ErrWatchOnlyWallet
+}
+
+func (p *WatchOnlyWallet) CreateChannelWallet(peer []byte, id uint32) common.ChannelWallet {
+	return nil
+}
+
+func (p *WatchOnlyWallet) GetChannelWallet(id uint32) common.ChannelWallet {
+	return nil
+}
+
+// PsbtDerivationWallet
+
+func (p *WatchOnlyWallet) GetMasterFingerprint() uint32 {
+	return p.desc.FingerprintUint32()
+}
+
+func (p *WatchOnlyWallet) SignPsbtByDerivation(packet *psbt.Packet) error {
+	return ErrWatchOnlyWallet
+}
+
+// 为属于本钱包的输入和输出填写派生路径，离线签名器根据这些信息签名
+func (p *WatchOnlyWallet) FillPsbtDerivation(packet *psbt.Packet) error {
+	p.mutex.Lock()
+	indexes := map[uint32]bool{p.currentIndex: true}
+	for id := range p.addresses {
+		indexes[uint32(id)] = true
+	}
+	origins := make(map[string]*keyOrigin)
+	for index := range indexes {
+		for change := uint32(0); change <= 1; change++ {
+			address, err := p.getAddress(change, index)
+			if err != nil {
+				continue
+			}
+			pkScript, err := txscript.PayToAddrScript(address)
+			if err != nil {
+				continue
+			}
+			pubKey, _ := p.getPubKey(change, index)
+			path := make([]uint32, 0, len(p.desc.OriginPath)+2)
+			path = append(path, p.desc.OriginPath...)
+			path = append(path, change, index)
+			origins[string(pkScript)] = &keyOrigin{
+				addrType: p.desc.AddrType,
+				pubKey:   pubKey,
+				path:     path,
+			}
+		}
+	}
+	p.mutex.Unlock()
+
+	fingerprint := p.desc.FingerprintUint32()
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+		if in.WitnessUtxo == nil {
+			continue
+		}
+		origin, ok := origins[string(in.WitnessUtxo.PkScript)]
+		if !ok {
+			continue
+		}
+		if origin.addrType == ADDRESS_TYPE_P2TR {
+			in.TaprootInternalKey = schnorr.SerializePubKey(origin.pubKey)
+			in.TaprootBip32Derivation = addTaprootBip32Derivation(
+				in.TaprootBip32Derivation, origin, fingerprint)
+		} else {
+			in.Bip32Derivation = addBip32Derivation(in.Bip32Derivation, origin, fingerprint)
+			if origin.addrType == ADDRESS_TYPE_P2SH_P2WPKH && len(in.RedeemScript) == 0 {
+				witnessProg, err := btcutil.NewAddressWitnessPubKeyHash(
+					btcutil.Hash160(origin.pubKey.SerializeCompressed()), p.netParams)
+				if err == nil {
+					in.RedeemScript, _ = txscript.PayToAddrScript(witnessProg)
+				}
+			}
+		}
+	}
+	for i, txOut := range packet.UnsignedTx.TxOut {
+		origin, ok := origins[string(txOut.PkScript)]
+		if !ok {
+			continue
+		}
+		out := &packet.Outputs[i]
+		if origin.addrType == ADDRESS_TYPE_P2TR {
+			out.TaprootInternalKey = schnorr.SerializePubKey(origin.pubKey)
+			out.TaprootBip32Derivation = addTaprootBip32Derivation(
+				out.TaprootBip32Derivation, origin, fingerprint)
+		} else {
+			out.Bip32Derivation = addBip32Derivation(out.Bip32Derivation, origin, fingerprint)
+		}
+	}
+	return nil
+}
@@ -578,6 +578,30 @@ func importWalletWithPrivKey(this js.Value, p []js.Value) any {
 	return js.Global().Get("Promise").New(handler)
 }
 
+func checkPasswordStrength(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "password parameter should be a string")
+	}
+	password := p[0].String()
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		return jsSafeData(_mgr.CheckPasswordStrength(password)), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func getUnlockStatus(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		return jsSafeData(_mgr.GetUnlockStatus()), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
 func unlockWallet(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
@@ -611,6 +635,45 @@ func unlockWallet(this js.Value, p []js.Value) any {
 	return js.Global().Get("Promise").New(handler)
 }
 
+func createChildWallet(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 4 {
+		return createJsRet(nil, -1, "Expected 4 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "parent wallet id should be a string")
+	}
+	parentId, err := strconv.ParseInt(p[0].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	if p[1].Type() != js.TypeNumber || p[2].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "index and words should be numbers")
+	}
+	index := p[1].Int()
+	words := p[2].Int()
+	if index < 0 {
+		return createJsRet(nil, -1, "invalid index")
+	}
+	if p[3].Type() != js.TypeString {
+		return createJsRet(nil, -1, "password parameter should be a string")
+	}
+	password := p[3].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		id, err := _mgr.CreateChildWallet(parentId, uint32(index), words, password)
+		if err != nil {
+			return nil, -1, err.Error()
+		}
+		return map[string]any{
+			"walletId": fmt.Sprintf("%d", id),
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
 func getAllWallets(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
@@ -1114,6 +1177,202 @@ func getAllChannels(this js.Value, p []js.Value) any {
 	return createJsRet(map[string]any{"channels": string(channelsJSON)}, 0, "ok")
 }
 
+func listChannels(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	channels := _mgr.ListChannels()
+	items := make([]any, 0, len(channels))
+	for _, c := range channels {
+		items = append(items, jsSafeData(c))
+	}
+	return createJsRet(map[string]interface{}{
+		"items": items,
+	}, 0, "ok")
+}
+
+func getPeerNodes(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		peers := _mgr.GetPeerInfos()
+		items := make([]any, 0, len(peers))
+		for _, peer := range peers {
+			items = append(items, jsSafeData(peer))
+		}
+		return map[string]interface{}{"items": items}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func addPeerNode(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+	if p[0].Type() != js.TypeString || p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "nodeId and url parameters should be strings")
+	}
+	node, err := _mgr.AddPeerNode(p[0].String(), p[1].String())
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{"host": node.Host}, 0, "ok")
+}
+
+func removePeerNode(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 1 {
+		return createJsRet(nil, -1, "Expected 1 parameter")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "nodeId parameter should be a string")
+	}
+	if err := _mgr.RemovePeerNode(p[0].String()); err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(nil, 0, "ok")
+}
+
+func discoverPeerNodes(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		nodes, err := _mgr.DiscoverPeerNodes()
+		if err != nil {
+			wallet.Log.Errorf("DiscoverPeerNodes error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{"count": len(nodes)}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func openChannelWithPeer(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 5 {
+		return createJsRet(nil, -1, "Expected 5 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "nodeId parameter should be a string")
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	if p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "amount parameter should be a string")
+	}
+	utxoList, err := getStringVector(p[3])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	if p[4].Type() != js.TypeString {
+		return createJsRet(nil, -1, "memo parameter should be a string")
+	}
+
+	nodeId := p[0].String()
+	feeRate, err := strconv.ParseInt(p[1].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	amt, err := strconv.ParseInt(p[2].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	memo := p[4].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		channel, err := _mgr.OpenChannelWithPeer(nodeId, feeRate, amt, utxoList, memo)
+		if err != nil {
+			wallet.Log.Errorf("OpenChannelWithPeer error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{"channel": channel}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func reopenChannelWithPeer(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "nodeId parameter should be a string")
+	}
+	if p[1].Type() != js.TypeBoolean {
+		return createJsRet(nil, -1, "expandAll parameter should be a boolean")
+	}
+	nodeId := p[0].String()
+	expandAll := p[1].Bool()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		txId, err := _mgr.ReopenChannelWithPeer(nodeId, expandAll)
+		if err != nil {
+			wallet.Log.Errorf("ReopenChannelWithPeer error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{"txId": txId}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func exportStaticChannelBackup(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	data, err := _mgr.ExportStaticChannelBackup()
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{"data": string(data)}, 0, "ok")
+}
+
+// input: data, feeRate
+func recoverChannelsFromStaticBackup(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "data parameter should be a string")
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	data := []byte(p[0].String())
+	feeRate, err := strconv.ParseInt(p[1].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		results, err := _mgr.RecoverChannelsFromStaticBackup(data, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("RecoverChannelsFromStaticBackup error: %v", err)
+			return nil, -1, err.Error()
+		}
+		items := make([]any, 0, len(results))
+		for _, result := range results {
+			items = append(items, jsSafeData(result))
+		}
+		return map[string]interface{}{"items": items}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
 func reservationStatus(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
@@ -1207,85 +1466,315 @@ func unlockFromChannel(this js.Value, p []js.Value) any {
 	return js.Global().Get("Promise").New(handler)
 }
 
-func lockToChannel(this js.Value, p []js.Value) any {
+// input: channel, amount, paymentHash, expiry, destNodeId
+func addChannelHtlc(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
 	if len(p) < 5 {
 		return createJsRet(nil, -1, "Expected 5 parameters")
 	}
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "channel Id parameter should be a string")
-	}
-	if p[1].Type() != js.TypeString {
-		return createJsRet(nil, -1, "assetName parameter should be a string")
-	}
-	if p[2].Type() != js.TypeString {
-		return createJsRet(nil, -1, "amount parameter should be a string")
+	for i := 0; i < 3; i++ {
+		if p[i].Type() != js.TypeString {
+			return createJsRet(nil, -1, fmt.Sprintf("parameter %d should be a string", i))
+		}
 	}
-	utxoList, err := getStringVector(p[3])
+	channel := p[0].String()
+	amt, err := strconv.ParseInt(p[1].String(), 10, 64)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-	feeList, err := getStringVector(p[4])
-	if err != nil {
-		return createJsRet(nil, -1, err.Error())
+	paymentHash := p[2].String()
+	if p[3].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "expiry parameter should be a number")
 	}
-	channel := p[0].String()
-	assetName := p[1].String()
-	amt := p[2].String()
+	expiry := p[3].Int()
+	if p[4].Type() != js.TypeString {
+		return createJsRet(nil, -1, "destNodeId parameter should be a string")
+	}
+	destNodeId := p[4].String()
 
 	handler := createAsyncJsHandler(func() (interface{}, int, string) {
-		lockTxId, _, err := _mgr.LockToChannel(channel, assetName, amt, utxoList, feeList, nil)
+		htlcId, err := _mgr.AddChannelHtlc(channel, amt, paymentHash, expiry, destNodeId, nil)
 		if err != nil {
-			wallet.Log.Errorf("LockToChannel error: %v", err)
+			wallet.Log.Errorf("AddChannelHtlc error: %v", err)
 			return nil, -1, err.Error()
 		}
-		return map[string]interface{}{"lockTxId": lockTxId}, 0, "ok"
+		return map[string]interface{}{"htlcId": strconv.FormatUint(htlcId, 10)}, 0, "ok"
 	})
 	return js.Global().Get("Promise").New(handler)
 }
 
-func lockToChannelWithExpand(this js.Value, p []js.Value) any {
+// input: channel, paymentHash, amount
+func acceptChannelHtlc(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	if len(p) < 4 {
-		return createJsRet(nil, -1, "Expected 4 parameters")
-	}
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "channel Id parameter should be a string")
-	}
-	if p[1].Type() != js.TypeString {
-		return createJsRet(nil, -1, "assetName parameter should be a string")
-	}
-	if p[2].Type() != js.TypeString {
-		return createJsRet(nil, -1, "amount parameter should be a string")
+	if len(p) < 3 {
+		return createJsRet(nil, -1, "Expected 3 parameters")
 	}
-	if p[3].Type() != js.TypeString {
-		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	for i := 0; i < 3; i++ {
+		if p[i].Type() != js.TypeString {
+			return createJsRet(nil, -1, fmt.Sprintf("parameter %d should be a string", i))
+		}
 	}
-	feeRate, err := strconv.ParseInt(p[3].String(), 10, 64)
+	channel := p[0].String()
+	paymentHash := p[1].String()
+	amt, err := strconv.ParseInt(p[2].String(), 10, 64)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-	channel := p[0].String()
-	assetName := p[1].String()
-	amt := p[2].String()
 
 	handler := createAsyncJsHandler(func() (interface{}, int, string) {
-		lockTxId, id, err := _mgr.LockToChannelWithExpand(channel, assetName, amt, feeRate)
+		htlcId, err := _mgr.AcceptChannelHtlc(channel, paymentHash, amt)
 		if err != nil {
-			wallet.Log.Errorf("LockToChannelWithExpand error: %v", err)
+			wallet.Log.Errorf("AcceptChannelHtlc error: %v", err)
 			return nil, -1, err.Error()
 		}
-		return map[string]interface{}{"lockTxId": lockTxId, "id": id}, 0, "ok"
+		return map[string]interface{}{"htlcId": strconv.FormatUint(htlcId, 10)}, 0, "ok"
 	})
 	return js.Global().Get("Promise").New(handler)
 }
 
-func batchUnlockFromChannel(this js.Value, p []js.Value) any {
-	if _mgr == nil {
+func parseChannelHtlcRef(p []js.Value) (string, uint64, string, string) {
+	if len(p) < 3 {
+		return "", 0, "", "Expected 3 parameters"
+	}
+	for i := 0; i < 3; i++ {
+		if p[i].Type() != js.TypeString {
+			return "", 0, "", fmt.Sprintf("parameter %d should be a string", i)
+		}
+	}
+	htlcId, err := strconv.ParseUint(p[1].String(), 10, 64)
+	if err != nil {
+		return "", 0, "", err.Error()
+	}
+	return p[0].String(), htlcId, p[2].String(), ""
+}
+
+// input: channel, htlcId, preimage
+func settleChannelHtlc(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	channel, htlcId, preimage, msg := parseChannelHtlcRef(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		err := _mgr.SettleChannelHtlc(channel, htlcId, preimage)
+		if err != nil {
+			wallet.Log.Errorf("SettleChannelHtlc error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return nil, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+// input: channel, htlcId, reason
+func failChannelHtlc(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	channel, htlcId, reason, msg := parseChannelHtlcRef(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		err := _mgr.FailChannelHtlc(channel, htlcId, reason)
+		if err != nil {
+			wallet.Log.Errorf("FailChannelHtlc error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return nil, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func getChannelHtlcs(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 1 {
+		return createJsRet(nil, -1, "Expected 1 parameter")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "channel parameter should be a string")
+	}
+	htlcs, err := _mgr.GetChannelHtlcs(p[0].String())
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	items := make([]any, 0, len(htlcs))
+	for _, htlc := range htlcs {
+		items = append(items, jsSafeData(htlc))
+	}
+	return createJsRet(map[string]interface{}{
+		"items": items,
+	}, 0, "ok")
+}
+
+// input: assetName, amount, channel, address, expiry, description, paymentHash
+func createPaymentRequest(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 7 {
+		return createJsRet(nil, -1, "Expected 7 parameters")
+	}
+	for i := 0; i < 7; i++ {
+		if i == 4 {
+			continue
+		}
+		if p[i].Type() != js.TypeString {
+			return createJsRet(nil, -1, fmt.Sprintf("parameter %d should be a string", i))
+		}
+	}
+	if p[4].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "expiry parameter should be a number")
+	}
+
+	request, err := _mgr.CreatePaymentRequest(p[0].String(), p[1].String(), p[2].String(), p[3].String(),
+		int64(p[4].Int()), p[5].String(), p[6].String())
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{
+		"request": request,
+	}, 0, "ok")
+}
+
+func decodePaymentRequest(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 1 {
+		return createJsRet(nil, -1, "Expected 1 parameter")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "request parameter should be a string")
+	}
+	req, err := _mgr.DecodePaymentRequest(p[0].String())
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(jsSafeData(req), 0, "ok")
+}
+
+// input: request, channel, feeUtxos
+func payRequest(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 3 {
+		return createJsRet(nil, -1, "Expected 3 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "request parameter should be a string")
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "channel parameter should be a string")
+	}
+	feeUtxoList, err := getStringVector(p[2])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	request := p[0].String()
+	channel := p[1].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		result, err := _mgr.PayRequest(request, channel, feeUtxoList)
+		if err != nil {
+			wallet.Log.Errorf("PayRequest error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return jsSafeData(result), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func lockToChannel(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 5 {
+		return createJsRet(nil, -1, "Expected 5 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "channel Id parameter should be a string")
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "assetName parameter should be a string")
+	}
+	if p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "amount parameter should be a string")
+	}
+	utxoList, err := getStringVector(p[3])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	feeList, err := getStringVector(p[4])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	channel := p[0].String()
+	assetName := p[1].String()
+	amt := p[2].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		lockTxId, _, err := _mgr.LockToChannel(channel, assetName, amt, utxoList, feeList, nil)
+		if err != nil {
+			wallet.Log.Errorf("LockToChannel error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{"lockTxId": lockTxId}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func lockToChannelWithExpand(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 4 {
+		return createJsRet(nil, -1, "Expected 4 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "channel Id parameter should be a string")
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "assetName parameter should be a string")
+	}
+	if p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "amount parameter should be a string")
+	}
+	if p[3].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	feeRate, err := strconv.ParseInt(p[3].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	channel := p[0].String()
+	assetName := p[1].String()
+	amt := p[2].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		lockTxId, id, err := _mgr.LockToChannelWithExpand(channel, assetName, amt, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("LockToChannelWithExpand error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{"lockTxId": lockTxId, "id": id}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func batchUnlockFromChannel(this js.Value, p []js.Value) any {
+	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
 	if len(p) < 5 {
@@ -1781,6 +2270,59 @@ func signMessage(this js.Value, p []js.Value) any {
 	return js.Global().Get("Promise").New(handler)
 }
 
+func signMessageBIP322(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "message parameter should be a string")
+	}
+	msg := p[0].String()
+	address := ""
+	if len(p) > 1 && p[1].Type() == js.TypeString {
+		address = p[1].String()
+	}
+	format := ""
+	if len(p) > 2 && p[2].Type() == js.TypeString {
+		format = p[2].String()
+	}
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		result, err := _mgr.SignMessageBIP322(msg, address, format)
+		if err != nil {
+			return nil, -1, err.Error()
+		}
+		return map[string]any{
+			"signature": result,
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func verifyMessageBIP322(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 3 || p[0].Type() != js.TypeString ||
+		p[1].Type() != js.TypeString || p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "address, message and signature must be strings")
+	}
+	address := p[0].String()
+	msg := p[1].String()
+	signature := p[2].String()
+
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		valid, err := _mgr.VerifyMessageBIP322(address, msg, signature)
+		if err != nil {
+			return nil, -1, err.Error()
+		}
+		return map[string]any{
+			"valid": valid,
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
 func signData(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
@@ -1830,6 +2372,11 @@ func signPsbt(this js.Value, p []js.Value) any {
 	}
 	extract := p[1].Bool()
 
+	override, err := parseTxPolicyOverride(p, 2)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
 	// result, err := _mgr.SignPsbt(psbtHex)
 	// if err != nil {
 	// 	return createJsRet(nil, -1, err.Error())
@@ -1840,7 +2387,7 @@ func signPsbt(this js.Value, p []js.Value) any {
 	// return createJsRet(data, 0, "ok")
 
 	handler := createAsyncJsHandler(func() (interface{}, int, string) {
-		result, err := _mgr.SignPsbt(psbtHex, extract)
+		result, err := _mgr.SignPsbtWithPolicy(psbtHex, extract, override)
 		if err != nil {
 			return nil, -1, err.Error()
 		}
@@ -1917,6 +2464,11 @@ func signPsbt_SatsNet(this js.Value, p []js.Value) any {
 	}
 	extract := p[1].Bool()
 
+	override, err := parseTxPolicyOverride(p, 2)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
 	// result, err := _mgr.SignPsbt_SatsNet(psbtHex)
 	// if err != nil {
 	// 	return createJsRet(nil, -1, err.Error())
@@ -1928,7 +2480,7 @@ func signPsbt_SatsNet(this js.Value, p []js.Value) any {
 
 	wallet.Log.Infof("SignPsbt_SatsNet  input: %s", psbtHex)
 	handler := createAsyncJsHandler(func() (interface{}, int, string) {
-		result, err := _mgr.SignPsbt_SatsNet(psbtHex, extract)
+		result, err := _mgr.SignPsbtWithPolicy_SatsNet(psbtHex, extract, override)
 		if err != nil {
 			return nil, -1, err.Error()
 		}
@@ -2211,222 +2763,878 @@ func finalizeSellOrder_SatsNet(this js.Value, p []js.Value) any {
 	if p[5].Type() != js.TypeNumber {
 		return createJsRet(nil, -1, "serviceFee parameter should be a number")
 	}
-	serviceFee := p[5].Int()
-
-	if p[6].Type() != js.TypeNumber {
-		return createJsRet(nil, -1, "networkFee parameter should be a number")
+	serviceFee := p[5].Int()
+
+	if p[6].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "networkFee parameter should be a number")
+	}
+	networkFee := p[6].Int()
+
+	result, err := wallet.FinalizeSellOrder_SatsNet(psbt, utxoList, address1, address2, network, int64(serviceFee), int64(networkFee))
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	wallet.Log.Infof("FinalizeSellOrder: %s", result)
+
+	data := map[string]any{
+		"psbt": result,
+	}
+
+	return createJsRet(data, 0, "ok")
+}
+
+func addInputsToPsbt_SatsNet(this js.Value, p []js.Value) any {
+
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "psbt parameter should be a string")
+	}
+	psbt := p[0].String()
+
+	utxoList, err := getStringVector(p[1])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	result, err := wallet.AddInputsToPsbt_SatsNet(psbt, utxoList)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	data := map[string]any{
+		"psbt": result,
+	}
+
+	return createJsRet(data, 0, "ok")
+}
+
+func addOutputsToPsbt_SatsNet(this js.Value, p []js.Value) any {
+
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "psbt parameter should be a string")
+	}
+	psbt := p[0].String()
+
+	utxoList, err := getStringVector(p[1])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	result, err := wallet.AddOutputsToPsbt_SatsNet(psbt, utxoList)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	data := map[string]any{
+		"psbt": result,
+	}
+
+	return createJsRet(data, 0, "ok")
+}
+
+func getVersion(this js.Value, p []js.Value) any {
+	data := map[string]any{
+		"version": wallet.SOFTWARE_VERSION,
+	}
+	return createJsRet(data, 0, "ok")
+}
+
+func callbackFunc(event string, data interface{}) {
+	if _callback != nil {
+		_callback.(js.Value).Invoke(event, js.ValueOf(data))
+	}
+}
+
+func registerCallbacks(this js.Value, args []js.Value) interface{} {
+	code := 0
+	msg := "ok"
+	if len(args) != 1 {
+		return nil
+	}
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	_callback = args[0]
+	_mgr.RegisterCallback(callbackFunc)
+	return createJsRet(nil, code, msg)
+
+}
+
+func getStringVector(p js.Value) ([]string, error) {
+	if p.Type() != js.TypeObject || !p.InstanceOf(js.Global().Get("Array")) {
+		return nil, fmt.Errorf("parameter should be an string array")
+	}
+	arrayLength := p.Length()
+	strs := make([]string, arrayLength)
+	for i := 0; i < arrayLength; i++ {
+		item := p.Index(i)
+		if item.Type() != js.TypeString {
+			return nil, fmt.Errorf("Array item at index %d is not a string", i)
+		}
+		strs[i] = item.String()
+	}
+	return strs, nil
+}
+
+func sendGarbage(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 4 {
+		return createJsRet(nil, -1, "Expected 4 parameters")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "destAddr parameter should be a string")
+	}
+	destAddress := p[0].String()
+
+	utxos, err := getStringVector(p[1])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	// amount
+	p2 := p[2]
+	if p2.Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "amount parameter should be a number")
+	}
+	amt := p2.Int()
+
+	if p[3].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	feeRate := p[3].String()
+	feeRate64, err := strconv.ParseInt(feeRate, 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		tx, err := _mgr.SendGarbage(destAddress, utxos, int64(amt), feeRate64)
+		if err != nil {
+			wallet.Log.Errorf("SendGarbage error: %v", err)
+			return nil, -1, err.Error()
+		}
+
+		return map[string]interface{}{
+			"txId": tx.TxID(),
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func sendAssets(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 4 {
+		return createJsRet(nil, -1, "Expected 4 parameters")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "destAddr parameter should be a string")
+	}
+	destAddress := p[0].String()
+
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "asset name parameter should be a string")
+	}
+	assetName := p[1].String()
+
+	// amount
+	p2 := p[2]
+	if p2.Type() != js.TypeString {
+		return createJsRet(nil, -1, "amount parameter should be a string")
+	}
+	amt := p2.String()
+
+	if p[3].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	feeRate := p[3].String()
+	feeRate64, err := strconv.ParseInt(feeRate, 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	control, err := parseCoinControl(p, 4)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		tx, _, err := _mgr.BatchSendAssetsWithCoinControl(destAddress, assetName, amt, 1, feeRate64, nil, control)
+		if err != nil {
+			wallet.Log.Errorf("SendAssets error: %v", err)
+			return nil, -1, err.Error()
+		}
+
+		return map[string]interface{}{
+			"txId": tx.TxID(),
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func getFeeEstimates(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	handler := createAsyncJsHandler(func() (interface{}, int, string) {
+		return jsSafeData(_mgr.GetFeeEstimates()), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func setCoinSelection(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 1 {
+		return createJsRet(nil, -1, "Expected 1 parameter")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "strategy parameter should be a string")
+	}
+	strategy := p[0].String()
+
+	err := _mgr.SetCoinSelection(strategy)
+	if err != nil {
+		wallet.Log.Errorf("SetCoinSelection error: %v", err)
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(nil, 0, "ok")
+}
+
+func previewSendFees(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 3 {
+		return createJsRet(nil, -1, "Expected 3 parameters")
+	}
+
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "destAddr parameter should be a string")
+	}
+	destAddress := p[0].String()
+
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "asset name parameter should be a string")
+	}
+	assetName := p[1].String()
+
+	if p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "amount parameter should be a string")
+	}
+	amt := p[2].String()
+
+	var customFeeRate int64
+	if len(p) > 3 && p[3].Type() == js.TypeString {
+		var err error
+		customFeeRate, err = strconv.ParseInt(p[3].String(), 10, 64)
+		if err != nil {
+			return createJsRet(nil, -1, err.Error())
+		}
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		previews, err := _mgr.PreviewSendFees(destAddress, assetName, amt, customFeeRate)
+		if err != nil {
+			wallet.Log.Errorf("PreviewSendFees error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{
+			"previews": jsSafeData(previews),
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+// txId 交易id，feeRate 新的费率
+func parseFeeBumpParams(p []js.Value) (string, int64, string) {
+	if len(p) < 2 {
+		return "", 0, "Expected 2 parameters"
+	}
+	if p[0].Type() != js.TypeString {
+		return "", 0, "txId parameter should be a string"
+	}
+	if p[1].Type() != js.TypeString {
+		return "", 0, "feeRate parameter should be a string"
+	}
+	feeRate, err := strconv.ParseInt(p[1].String(), 10, 64)
+	if err != nil {
+		return "", 0, err.Error()
+	}
+	return p[0].String(), feeRate, ""
+}
+
+func planConsolidation(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	assetName, feeRate, msg := parseFeeBumpParams(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		plan, err := _mgr.PlanConsolidation("", assetName, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("PlanConsolidation error: %v", err)
+			return nil, -1, err.Error()
+		}
+		// 只是预览，不保留预留的utxo
+		_mgr.CancelConsolidation(plan)
+		return jsSafeData(plan), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func consolidateUtxos(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	assetName, feeRate, msg := parseFeeBumpParams(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		plan, err := _mgr.PlanConsolidation("", assetName, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("PlanConsolidation error: %v", err)
+			return nil, -1, err.Error()
+		}
+		txId, err := _mgr.ExecuteConsolidation(plan)
+		if err != nil {
+			wallet.Log.Errorf("ExecuteConsolidation error: %v", err)
+			return nil, -1, err.Error()
+		}
+
+		return map[string]interface{}{
+			"txId": txId,
+			"fee":  plan.Fee,
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func setAutoConsolidation(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 3 {
+		return createJsRet(nil, -1, "Expected 3 parameters")
+	}
+
+	assets, err := getStringVector(p[0])
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "maxFeeRate parameter should be a string")
+	}
+	maxFeeRate, err := strconv.ParseInt(p[1].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	if p[2].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "minUtxos parameter should be a number")
+	}
+
+	_mgr.SetAutoConsolidation(&wallet.AutoConsolidation{
+		Assets:     assets,
+		MaxFeeRate: maxFeeRate,
+		MinUtxos:   p[2].Int(),
+	})
+	return createJsRet(nil, 0, "ok")
+}
+
+func bumpFee(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	txId, feeRate, msg := parseFeeBumpParams(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		newTxId, fee, err := _mgr.BumpFee(txId, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("BumpFee error: %v", err)
+			return nil, -1, err.Error()
+		}
+
+		return map[string]interface{}{
+			"txId": newTxId,
+			"fee":  fee,
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func cpfp(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	txId, feeRate, msg := parseFeeBumpParams(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		childTxId, fee, err := _mgr.CPFP(txId, feeRate)
+		if err != nil {
+			wallet.Log.Errorf("CPFP error: %v", err)
+			return nil, -1, err.Error()
+		}
+
+		return map[string]interface{}{
+			"txId": childTxId,
+			"fee":  fee,
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func newPayout(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 4 {
+		return createJsRet(nil, -1, "Expected 4 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "content parameter should be a string")
+	}
+	content := p[0].String()
+	if p[1].Type() != js.TypeString {
+		return createJsRet(nil, -1, "assetName parameter should be a string")
+	}
+	assetName := p[1].String()
+	if p[2].Type() != js.TypeBoolean {
+		return createJsRet(nil, -1, "satsNet parameter should be a boolean")
+	}
+	satsNet := p[2].Bool()
+	if p[3].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	feeRate, err := strconv.ParseInt(p[3].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		resv, err := _mgr.NewPayout([]byte(content), assetName, satsNet, feeRate, nil)
+		if err != nil {
+			wallet.Log.Errorf("NewPayout error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return map[string]interface{}{
+			"id":    fmt.Sprintf("%d", resv.Id),
+			"total": len(resv.Recipients),
+		}, 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func parsePayoutId(p []js.Value) (int64, string) {
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return 0, "payout id must be a string"
+	}
+	id, err := strconv.ParseInt(p[0].String(), 10, 64)
+	if err != nil {
+		return 0, err.Error()
+	}
+	return id, ""
+}
+
+func runPayout(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	id, msg := parsePayoutId(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		err := _mgr.RunPayout(id)
+		report, _ := _mgr.GetPayoutReport(id)
+		if err != nil {
+			return jsSafeData(report), -1, err.Error()
+		}
+		return jsSafeData(report), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
+}
+
+func cancelPayout(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	id, msg := parsePayoutId(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+	if err := _mgr.CancelPayout(id); err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(nil, 0, "ok")
+}
+
+func getPayoutReport(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	id, msg := parsePayoutId(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+	report, err := _mgr.GetPayoutReport(id)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{
+		"report": jsSafeData(report),
+		"csv":    report.CSV(),
+	}, 0, "ok")
+}
+
+// input: address, assetName, amt, value, satsNet, feeRate, startHeight, interval, count
+func newScheduledSend(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+
+	if len(p) < 9 {
+		return createJsRet(nil, -1, "Expected 9 parameters")
+	}
+	for i := 0; i < 4; i++ {
+		if p[i].Type() != js.TypeString {
+			return createJsRet(nil, -1, fmt.Sprintf("parameter %d should be a string", i))
+		}
+	}
+	address := p[0].String()
+	assetName := p[1].String()
+	amt := p[2].String()
+	value, err := strconv.ParseInt(p[3].String(), 10, 64)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	if p[4].Type() != js.TypeBoolean {
+		return createJsRet(nil, -1, "satsNet parameter should be a boolean")
 	}
-	networkFee := p[6].Int()
-
-	result, err := wallet.FinalizeSellOrder_SatsNet(psbt, utxoList, address1, address2, network, int64(serviceFee), int64(networkFee))
+	satsNet := p[4].Bool()
+	if p[5].Type() != js.TypeString {
+		return createJsRet(nil, -1, "feeRate parameter should be a string")
+	}
+	feeRate, err := strconv.ParseInt(p[5].String(), 10, 64)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-	wallet.Log.Infof("FinalizeSellOrder: %s", result)
-
-	data := map[string]any{
-		"psbt": result,
+	if p[6].Type() != js.TypeNumber || p[7].Type() != js.TypeNumber || p[8].Type() != js.TypeNumber {
+		return createJsRet(nil, -1, "startHeight, interval and count should be numbers")
 	}
+	startHeight := p[6].Int()
+	interval := p[7].Int()
+	count := p[8].Int()
 
-	return createJsRet(data, 0, "ok")
+	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
+		resv, err := _mgr.NewScheduledSend(address, assetName, amt, value, satsNet, feeRate, nil,
+			startHeight, interval, count)
+		if err != nil {
+			wallet.Log.Errorf("NewScheduledSend error: %v", err)
+			return nil, -1, err.Error()
+		}
+		return jsSafeData(resv.Info()), 0, "ok"
+	})
+	return js.Global().Get("Promise").New(jsHandler)
 }
 
-func addInputsToPsbt_SatsNet(this js.Value, p []js.Value) any {
-
-	if len(p) < 2 {
-		return createJsRet(nil, -1, "Expected 2 parameters")
+func cancelScheduledSend(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "psbt parameter should be a string")
+	id, msg := parsePayoutId(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
 	}
-	psbt := p[0].String()
-
-	utxoList, err := getStringVector(p[1])
-	if err != nil {
+	if err := _mgr.CancelScheduledSend(id); err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
+	return createJsRet(nil, 0, "ok")
+}
 
-	result, err := wallet.AddInputsToPsbt_SatsNet(psbt, utxoList)
-	if err != nil {
-		return createJsRet(nil, -1, err.Error())
+func getScheduledSends(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-
-	data := map[string]any{
-		"psbt": result,
+	items := make([]any, 0)
+	for _, resv := range _mgr.GetScheduledSends() {
+		items = append(items, jsSafeData(resv.Info()))
 	}
-
-	return createJsRet(data, 0, "ok")
+	return createJsRet(map[string]interface{}{
+		"items": items,
+	}, 0, "ok")
 }
 
-func addOutputsToPsbt_SatsNet(this js.Value, p []js.Value) any {
-
-	if len(p) < 2 {
-		return createJsRet(nil, -1, "Expected 2 parameters")
+// input: policy (json)，例如 {"assetName":"ordx:f:pearl","targetLocalRatio":50,"tolerance":10,"minL2Balance":"100","maxL2Balance":"1000","maxDailyFee":5000}
+func setLiquidityPolicy(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "psbt parameter should be a string")
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "policy parameter should be a json string")
 	}
-	psbt := p[0].String()
-
-	utxoList, err := getStringVector(p[1])
+	var policy wallet.LiquidityPolicy
+	err := json.Unmarshal([]byte(p[0].String()), &policy)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-
-	result, err := wallet.AddOutputsToPsbt_SatsNet(psbt, utxoList)
+	err = _mgr.SetLiquidityPolicy(&policy)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-
-	data := map[string]any{
-		"psbt": result,
-	}
-
-	return createJsRet(data, 0, "ok")
+	return createJsRet(nil, 0, "ok")
 }
 
-func getVersion(this js.Value, p []js.Value) any {
-	data := map[string]any{
-		"version": wallet.SOFTWARE_VERSION,
+func deleteLiquidityPolicy(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	return createJsRet(data, 0, "ok")
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "assetName parameter should be a string")
+	}
+	err := _mgr.DeleteLiquidityPolicy(p[0].String())
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(nil, 0, "ok")
 }
 
-func callbackFunc(event string, data interface{}) {
-	if _callback != nil {
-		_callback.(js.Value).Invoke(event, js.ValueOf(data))
+func getLiquidityPolicies(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
+	items := make([]any, 0)
+	for _, policy := range _mgr.GetLiquidityPolicies() {
+		items = append(items, jsSafeData(policy))
+	}
+	return createJsRet(map[string]interface{}{
+		"items": items,
+	}, 0, "ok")
 }
 
-func registerCallbacks(this js.Value, args []js.Value) interface{} {
-	code := 0
-	msg := "ok"
-	if len(args) != 1 {
-		return nil
-	}
+// input: assetName (optional), limit (optional)
+func getLiquidityDecisions(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	_callback = args[0]
-	_mgr.RegisterCallback(callbackFunc)
-	return createJsRet(nil, code, msg)
-
+	assetName := ""
+	if len(p) > 0 && p[0].Type() == js.TypeString {
+		assetName = p[0].String()
+	}
+	limit := 0
+	if len(p) > 1 && p[1].Type() == js.TypeNumber {
+		limit = p[1].Int()
+	}
+	items := make([]any, 0)
+	for _, decision := range _mgr.GetLiquidityDecisions(assetName, limit) {
+		items = append(items, jsSafeData(decision))
+	}
+	return createJsRet(map[string]interface{}{
+		"items": items,
+	}, 0, "ok")
 }
 
-func getStringVector(p js.Value) ([]string, error) {
-	if p.Type() != js.TypeObject || !p.InstanceOf(js.Global().Get("Array")) {
-		return nil, fmt.Errorf("parameter should be an string array")
+func parseLabelRef(p []js.Value) (string, string, string) {
+	if len(p) < 2 {
+		return "", "", "Expected 2 parameters"
 	}
-	arrayLength := p.Length()
-	strs := make([]string, arrayLength)
-	for i := 0; i < arrayLength; i++ {
-		item := p.Index(i)
-		if item.Type() != js.TypeString {
-			return nil, fmt.Errorf("Array item at index %d is not a string", i)
-		}
-		strs[i] = item.String()
+	if p[0].Type() != js.TypeString {
+		return "", "", "type parameter should be a string"
 	}
-	return strs, nil
+	if p[1].Type() != js.TypeString {
+		return "", "", "ref parameter should be a string"
+	}
+	return p[0].String(), strings.TrimSpace(p[1].String()), ""
 }
 
-func sendGarbage(this js.Value, p []js.Value) any {
+func setLabel(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
-
-	if len(p) < 4 {
-		return createJsRet(nil, -1, "Expected 4 parameters")
+	typ, ref, msg := parseLabelRef(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
 	}
-
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "destAddr parameter should be a string")
+	if len(p) < 3 || p[2].Type() != js.TypeString {
+		return createJsRet(nil, -1, "label parameter should be a string")
 	}
-	destAddress := p[0].String()
-
-	utxos, err := getStringVector(p[1])
+	label := &wallet.Label{Type: typ, Ref: ref, Label: p[2].String()}
+	// spendable 可选，只对 output 有效
+	if len(p) > 3 && p[3].Type() == js.TypeBoolean {
+		spendable := p[3].Bool()
+		label.Spendable = &spendable
+	}
+	err := _mgr.SetLabel(label)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
+	return createJsRet(nil, 0, "ok")
+}
 
-	// amount
-	p2 := p[2]
-	if p2.Type() != js.TypeNumber {
-		return createJsRet(nil, -1, "amount parameter should be a number")
+func getLabel(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	amt := p2.Int()
+	typ, ref, msg := parseLabelRef(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+	label := _mgr.GetLabel(typ, ref)
+	if label == nil {
+		return createJsRet(nil, -1, "label not found")
+	}
+	return createJsRet(map[string]interface{}{
+		"label": jsSafeData(label),
+	}, 0, "ok")
+}
 
-	if p[3].Type() != js.TypeString {
-		return createJsRet(nil, -1, "feeRate parameter should be a string")
+func deleteLabel(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	feeRate := p[3].String()
-	feeRate64, err := strconv.ParseInt(feeRate, 10, 64)
+	typ, ref, msg := parseLabelRef(p)
+	if msg != "" {
+		return createJsRet(nil, -1, msg)
+	}
+	err := _mgr.DeleteLabel(typ, ref)
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
-
-	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
-		tx, err := _mgr.SendGarbage(destAddress, utxos, int64(amt), feeRate64)
-		if err != nil {
-			wallet.Log.Errorf("SendGarbage error: %v", err)
-			return nil, -1, err.Error()
-		}
-
-		return map[string]interface{}{
-			"txId": tx.TxID(),
-		}, 0, "ok"
-	})
-	return js.Global().Get("Promise").New(jsHandler)
+	return createJsRet(nil, 0, "ok")
 }
 
-func sendAssets(this js.Value, p []js.Value) any {
+func importLabels(this js.Value, p []js.Value) any {
 	if _mgr == nil {
 		return createJsRet(nil, -1, "Manager not initialized")
 	}
-
-	if len(p) < 4 {
-		return createJsRet(nil, -1, "Expected 4 parameters")
+	if len(p) < 1 || p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "content parameter should be a string")
 	}
-
-	if p[0].Type() != js.TypeString {
-		return createJsRet(nil, -1, "destAddr parameter should be a string")
+	count, err := _mgr.ImportLabels([]byte(p[0].String()))
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
 	}
-	destAddress := p[0].String()
+	return createJsRet(map[string]interface{}{
+		"count": count,
+	}, 0, "ok")
+}
 
-	if p[1].Type() != js.TypeString {
-		return createJsRet(nil, -1, "asset name parameter should be a string")
+func exportLabels(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
 	}
-	assetName := p[1].String()
+	content, err := _mgr.ExportLabels()
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{
+		"content": string(content),
+	}, 0, "ok")
+}
 
-	// amount
-	p2 := p[2]
-	if p2.Type() != js.TypeString {
-		return createJsRet(nil, -1, "amount parameter should be a string")
+// input: filter json, 可以为空
+// p[idx] 为 json，例如 {"allowAssetBurn":true}，只对这次调用有效，没有时全部检查
+func parseTxPolicyOverride(p []js.Value, idx int) (*wallet.TxPolicyOverride, error) {
+	if len(p) <= idx || p[idx].Type() != js.TypeString || p[idx].String() == "" {
+		return nil, nil
 	}
-	amt := p2.String()
+	var override wallet.TxPolicyOverride
+	err := json.Unmarshal([]byte(p[idx].String()), &override)
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
 
-	if p[3].Type() != js.TypeString {
-		return createJsRet(nil, -1, "feeRate parameter should be a string")
+// 可选参数，json: {"include":[utxo], "exclude":[utxo]}
+func parseCoinControl(p []js.Value, idx int) (*wallet.CoinControl, error) {
+	if len(p) <= idx || p[idx].Type() != js.TypeString || p[idx].String() == "" {
+		return nil, nil
 	}
-	feeRate := p[3].String()
-	feeRate64, err := strconv.ParseInt(feeRate, 10, 64)
+	var control wallet.CoinControl
+	err := json.Unmarshal([]byte(p[idx].String()), &control)
 	if err != nil {
-		return createJsRet(nil, -1, err.Error())
+		return nil, err
 	}
+	return &control, nil
+}
 
-	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
-		tx, err := _mgr.SendAssets(destAddress, assetName, amt, feeRate64, nil)
+func getActivity(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	var filter wallet.ActivityFilter
+	if len(p) > 0 && p[0].Type() == js.TypeString && p[0].String() != "" {
+		err := json.Unmarshal([]byte(p[0].String()), &filter)
 		if err != nil {
-			wallet.Log.Errorf("SendAssets error: %v", err)
-			return nil, -1, err.Error()
+			return createJsRet(nil, -1, err.Error())
 		}
+	}
+	items, total := _mgr.GetActivity(&filter)
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	var payload []any
+	if err := json.Unmarshal(encoded, &payload); err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
+	return createJsRet(map[string]interface{}{
+		"items": payload,
+		"total": total,
+	}, 0, "ok")
+}
 
-		return map[string]interface{}{
-			"txId": tx.TxID(),
-		}, 0, "ok"
-	})
-	return js.Global().Get("Promise").New(jsHandler)
+func getActivityItem(this js.Value, p []js.Value) any {
+	if _mgr == nil {
+		return createJsRet(nil, -1, "Manager not initialized")
+	}
+	if len(p) < 2 {
+		return createJsRet(nil, -1, "Expected 2 parameters")
+	}
+	if p[0].Type() != js.TypeString {
+		return createJsRet(nil, -1, "txId parameter should be a string")
+	}
+	if p[1].Type() != js.TypeBoolean {
+		return createJsRet(nil, -1, "satsNet parameter should be a boolean")
+	}
+	item := _mgr.GetActivityItem(p[0].String(), p[1].Bool())
+	if item == nil {
+		return createJsRet(nil, -1, "tx not found")
+	}
+	return createJsRet(map[string]interface{}{
+		"item": jsSafeData(item),
+	}, 0, "ok")
 }
 
 func sendAssets_SatsNet(this js.Value, p []js.Value) any {
@@ -2468,9 +3676,13 @@ func sendAssets_SatsNet(this js.Value, p []js.Value) any {
 			return createJsRet(nil, -1, "memo parameter should be a hex string")
 		}
 	}
+	control, err := parseCoinControl(p, 4)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
 
 	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
-		tx, err := _mgr.SendAssets_SatsNet(destAddress, assetName, amt, memo)
+		tx, err := _mgr.SendAssetsWithCoinControl_SatsNet(destAddress, assetName, amt, memo, control)
 		if err != nil {
 			wallet.Log.Errorf("SendAssets_SatsNet error: %v", err)
 			return nil, -1, err.Error()
@@ -2575,9 +3787,13 @@ func batchSendAssets(this js.Value, p []js.Value) any {
 	if err != nil {
 		return createJsRet(nil, -1, err.Error())
 	}
+	control, err := parseCoinControl(p, 5)
+	if err != nil {
+		return createJsRet(nil, -1, err.Error())
+	}
 
 	jsHandler := createAsyncJsHandler(func() (interface{}, int, string) {
-		tx, fee, err := _mgr.BatchSendAssets(destAddress, assetName, amt, n, feeRate64, nil)
+		tx, fee, err := _mgr.BatchSendAssetsWithCoinControl(destAddress, assetName, amt, n, feeRate64, nil, control)
 		if err != nil {
 			wallet.Log.Errorf("BatchSendAssets error: %v", err)
 			return nil, -1, err.Error()
@@ -3270,7 +4486,10 @@ func getAssetSummary(this js.Value, p []js.Value) any {
 		if err := json.Unmarshal(encoded, &payload); err != nil {
 			return nil, -1, err.Error()
 		}
-		return map[string]any{"assets": payload}, 0, "ok"
+		return map[string]any{
+			"assets": payload,
+			"label":  summary.Label,
+		}, 0, "ok"
 	})
 	return js.Global().Get("Promise").New(jsHandler)
 }
@@ -5253,8 +6472,14 @@ func main() {
 	// input: mnemonic, password; return: walletId
 	obj.Set("importWallet", js.FuncOf(importWallet))
 	obj.Set("importWalletWithPrivKey", js.FuncOf(importWalletWithPrivKey))
+	// input: parent walletId, index, words (12/18/24), password; return: walletId
+	obj.Set("createChildWallet", js.FuncOf(createChildWallet))
 	// input: password; return: current walletId
 	obj.Set("unlockWallet", js.FuncOf(unlockWallet))
+	// input: none; return: failures, remainingAttempts, lockedUntil
+	obj.Set("getUnlockStatus", js.FuncOf(getUnlockStatus))
+	// input: password; return: valid, score (0-4), issues
+	obj.Set("checkPasswordStrength", js.FuncOf(checkPasswordStrength))
 	// input: none; return: list of wallet id and account number
 	obj.Set("getAllWallets", js.FuncOf(getAllWallets))
 	obj.Set("getWalletCatalog", js.FuncOf(getWalletCatalog))
@@ -5287,9 +6512,26 @@ func main() {
 	obj.Set("getCurrentChannel", js.FuncOf(getCurrentChannel))
 	obj.Set("getChannelStatus", js.FuncOf(getChannelStatus))
 	obj.Set("getAllChannels", js.FuncOf(getAllChannels))
+	obj.Set("listChannels", js.FuncOf(listChannels))                                       // return: items
+	obj.Set("getPeerNodes", js.FuncOf(getPeerNodes))                                       // return: items
+	obj.Set("addPeerNode", js.FuncOf(addPeerNode))                                         // input: nodeId, url; return: host
+	obj.Set("removePeerNode", js.FuncOf(removePeerNode))                                   // input: nodeId
+	obj.Set("discoverPeerNodes", js.FuncOf(discoverPeerNodes))                             // return: count
+	obj.Set("openChannelWithPeer", js.FuncOf(openChannelWithPeer))                         // input: nodeId, feeRate, amount, utxos, memo; return: channel
+	obj.Set("reopenChannelWithPeer", js.FuncOf(reopenChannelWithPeer))                     // input: nodeId, expandAll; return: txId
+	obj.Set("exportStaticChannelBackup", js.FuncOf(exportStaticChannelBackup))             // return: data
+	obj.Set("recoverChannelsFromStaticBackup", js.FuncOf(recoverChannelsFromStaticBackup)) // input: data, feeRate; return: items
 	obj.Set("reservationStatus", js.FuncOf(reservationStatus))
 	obj.Set("allReservations", js.FuncOf(allReservations))
 	obj.Set("unlockFromChannel", js.FuncOf(unlockFromChannel))
+	obj.Set("addChannelHtlc", js.FuncOf(addChannelHtlc))             // input: channel, amount, paymentHash, expiry, destNodeId; return: htlcId
+	obj.Set("acceptChannelHtlc", js.FuncOf(acceptChannelHtlc))       // input: channel, paymentHash, amount; return: htlcId
+	obj.Set("settleChannelHtlc", js.FuncOf(settleChannelHtlc))       // input: channel, htlcId, preimage; return: none
+	obj.Set("failChannelHtlc", js.FuncOf(failChannelHtlc))           // input: channel, htlcId, reason; return: none
+	obj.Set("getChannelHtlcs", js.FuncOf(getChannelHtlcs))           // input: channel; return: items
+	obj.Set("createPaymentRequest", js.FuncOf(createPaymentRequest)) // input: assetName, amount, channel, address, expiry, description, paymentHash; return: request
+	obj.Set("decodePaymentRequest", js.FuncOf(decodePaymentRequest)) // input: request; return: request info
+	obj.Set("payRequest", js.FuncOf(payRequest))                     // input: request, channel, feeUtxos; return: txId or htlcId
 	obj.Set("lockToChannel", js.FuncOf(lockToChannel))
 	obj.Set("lockToChannelWithExpand", js.FuncOf(lockToChannelWithExpand))
 	obj.Set("batchUnlockFromChannel", js.FuncOf(batchUnlockFromChannel))
@@ -5314,6 +6556,10 @@ func main() {
 	obj.Set("signData", js.FuncOf(signData))
 	// input: message (hex string) return: signature (hex string)
 	obj.Set("signMessage", js.FuncOf(signMessage))
+	// input: message, address (optional), format (simple/full, optional); return: signature (base64)
+	obj.Set("signMessageBIP322", js.FuncOf(signMessageBIP322))
+	// input: address, message, signature (base64); return: valid
+	obj.Set("verifyMessageBIP322", js.FuncOf(verifyMessageBIP322))
 	// input: psbt(hexString); return: signed psbt (hexString)
 	obj.Set("signPsbt", js.FuncOf(signPsbt))
 	obj.Set("signPsbts", js.FuncOf(signPsbts))
@@ -5337,11 +6583,37 @@ func main() {
 	obj.Set("addInputsToPsbt_SatsNet", js.FuncOf(addInputsToPsbt_SatsNet))
 	obj.Set("addOutputsToPsbt_SatsNet", js.FuncOf(addOutputsToPsbt_SatsNet))
 
-	obj.Set("sendAssets", js.FuncOf(sendAssets))
+	obj.Set("sendAssets", js.FuncOf(sendAssets)) // input: destAddr, assetName, amt, feeRate, coinControl (json {include, exclude}, optional); return: txId
 	obj.Set("sendGarbage", js.FuncOf(sendGarbage))
-	obj.Set("sendAssets_SatsNet", js.FuncOf(sendAssets_SatsNet))
+	obj.Set("getFeeEstimates", js.FuncOf(getFeeEstimates))             // input: none; return: fast, normal, economy (sat/vB)
+	obj.Set("previewSendFees", js.FuncOf(previewSendFees))             // input: destAddr, assetName, amt, customFeeRate(optional); return: previews [mode, feeRate, vsize, fee]
+	obj.Set("setCoinSelection", js.FuncOf(setCoinSelection))           // input: strategy (bnb, consolidate, privacy, legacy, "" for config); return: none
+	obj.Set("planConsolidation", js.FuncOf(planConsolidation))         // input: assetName, feeRate; return: plan [inputs, feeInputs, assetAmt, value, fee]
+	obj.Set("consolidateUtxos", js.FuncOf(consolidateUtxos))           // input: assetName, feeRate; return: txId, fee
+	obj.Set("setAutoConsolidation", js.FuncOf(setAutoConsolidation))   // input: assets, maxFeeRate, minUtxos; return: none
+	obj.Set("bumpFee", js.FuncOf(bumpFee))                             // input: txId, feeRate; return: txId, fee
+	obj.Set("cpfp", js.FuncOf(cpfp))                                   // input: txId, feeRate; return: child txId, fee
+	obj.Set("newPayout", js.FuncOf(newPayout))                         // input: content (csv or json), assetName, satsNet, feeRate; return: id, total
+	obj.Set("runPayout", js.FuncOf(runPayout))                         // input: id; return: report
+	obj.Set("cancelPayout", js.FuncOf(cancelPayout))                   // input: id; return: none
+	obj.Set("getPayoutReport", js.FuncOf(getPayoutReport))             // input: id; return: report, csv
+	obj.Set("newScheduledSend", js.FuncOf(newScheduledSend))           // input: address, assetName, amt, value, satsNet, feeRate, startHeight, interval, count; return: info
+	obj.Set("cancelScheduledSend", js.FuncOf(cancelScheduledSend))     // input: id; return: none
+	obj.Set("getScheduledSends", js.FuncOf(getScheduledSends))         // input: none; return: items
+	obj.Set("setLiquidityPolicy", js.FuncOf(setLiquidityPolicy))       // input: policy (json); return: none
+	obj.Set("deleteLiquidityPolicy", js.FuncOf(deleteLiquidityPolicy)) // input: assetName; return: none
+	obj.Set("getLiquidityPolicies", js.FuncOf(getLiquidityPolicies))   // input: none; return: items
+	obj.Set("getLiquidityDecisions", js.FuncOf(getLiquidityDecisions)) // input: assetName (optional), limit (optional); return: items
+	obj.Set("setLabel", js.FuncOf(setLabel))                           // input: type, ref, label, spendable (optional); return: none
+	obj.Set("getLabel", js.FuncOf(getLabel))                           // input: type, ref; return: label
+	obj.Set("deleteLabel", js.FuncOf(deleteLabel))                     // input: type, ref; return: none
+	obj.Set("importLabels", js.FuncOf(importLabels))                   // input: content (BIP329 jsonl); return: count
+	obj.Set("exportLabels", js.FuncOf(exportLabels))                   // input: none; return: content (BIP329 jsonl)
+	obj.Set("getActivity", js.FuncOf(getActivity))                     // input: filter (json, optional); return: items, total
+	obj.Set("getActivityItem", js.FuncOf(getActivityItem))             // input: txId, satsNet; return: item
+	obj.Set("sendAssets_SatsNet", js.FuncOf(sendAssets_SatsNet))       // input: destAddr, assetName, amt, memo, coinControl (json {include, exclude}, optional); return: txId
 	obj.Set("batchSendAssets_SatsNet", js.FuncOf(batchSendAssets_SatsNet))
-	obj.Set("batchSendAssets", js.FuncOf(batchSendAssets))
+	obj.Set("batchSendAssets", js.FuncOf(batchSendAssets)) // input: destAddr, assetName, amt, n, feeRate, coinControl (json, optional); return: txId, fee
 	obj.Set("batchSendAssetsV2_SatsNet", js.FuncOf(batchSendAssetsV2_SatsNet))
 
 	obj.Set("getTickerInfo", js.FuncOf(getTickerInfo))
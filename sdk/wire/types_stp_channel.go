@@ -26,6 +26,10 @@ const (
 	STP_SPLICING_OUT_REQ          string = "/splicingout/require"
 	STP_SPLICING_OUT_COMMITSIG    string = "/splicingout/commitsig"
 	STP_SPLICING_OUT_REVOKEANDACK string = "/splicingout/revokeandack"
+
+	STP_HTLC_UPDATE_REQ          string = "/htlc/require"
+	STP_HTLC_UPDATE_COMMITSIG    string = "/htlc/commitsig"
+	STP_HTLC_UPDATE_REVOKEANDACK string = "/htlc/revokeandack"
 )
 
 type OpenChannelRequest struct {
@@ -81,6 +85,7 @@ type CloseChannelRequest struct {
 	FeeRate      int64  `json:"feeRate,omitempty"`
 	RevealKey    []byte `json:"revealKey,omitempty"`
 	NodeId       []byte `json:"nodeId,omitempty"`
+	Force        bool   `json:"force,omitempty"` // 请求对端广播它最新的commitment，用静态通道备份恢复时使用
 }
 
 type ClosingSigned struct {
@@ -487,3 +492,79 @@ type SplicingOutRevokeAndAckResp struct { // 仅仅简单应答
 	BaseResp
 	Id int64 `json:"id"`
 }
+
+// htlc
+// 条件支付：A->S->B 时，A 先在通道内加一个 S 可以用原像领取的 htlc，S 再给 B 加一个
+// 相同 hash 的 htlc，B 用原像结算后 S 才能拿到原像向 A 结算，整个转账是原子的
+
+type UpdateAddHtlc struct {
+	HtlcId      uint64 `json:"htlcId"`   // 由服务节点分配
+	Incoming    bool   `json:"incoming"` // 从请求方看，是否是对端提供的htlc
+	Amount      int64  `json:"amount"`   // 白聪
+	PaymentHash []byte `json:"paymentHash"`
+	Expiry      uint32 `json:"expiry"`               // 一层绝对高度
+	DestNodeId  []byte `json:"destNodeId,omitempty"` // 最终收款人
+	Memo        []byte `json:"memo,omitempty"`
+}
+
+type UpdateSettleHtlc struct {
+	HtlcId   uint64 `json:"htlcId"`
+	Preimage []byte `json:"preimage"`
+}
+
+type UpdateFailHtlc struct {
+	HtlcId uint64 `json:"htlcId"`
+	Reason string `json:"reason"`
+}
+
+type HtlcUpdateRequest struct {
+	MsgHeader
+	ChannelId    string            `json:"channel"`
+	CommitHeight int               `json:"commitHeight"`
+	Add          *UpdateAddHtlc    `json:"add,omitempty"`
+	Settle       *UpdateSettleHtlc `json:"settle,omitempty"`
+	Fail         *UpdateFailHtlc   `json:"fail,omitempty"`
+	NodeId       []byte            `json:"nodeId,omitempty"`
+}
+
+type HtlcUpdateReq struct {
+	HtlcUpdateRequest
+	Sig []byte `json:"msgSig"`
+}
+
+type HtlcUpdateResp struct { // 是否同意更新状态
+	BaseResp
+	Id         int64          `json:"id"`
+	Add        *UpdateAddHtlc `json:"add,omitempty"` // 服务节点确认后的htlc
+	RevKey     []byte         `json:"rev"`
+	NextRevKey []byte         `json:"nextRevKey"`
+	FeeRate    int64          `json:"feeRate"`
+}
+
+type HtlcCommitSigReq struct { // CommitSig
+	ChannelId string `json:"channel"`
+	Id        int64  `json:"id"`
+	CommitSigInfo
+	RevKey     []byte `json:"rev"`
+	NextRevKey []byte `json:"nextRevKey"`
+	Sig        []byte `json:"msgSig,omitempty"`
+}
+
+type HtlcCommitSigResp struct { // 包含 RevokeAndAck 和 CommitSig
+	BaseResp
+	Id int64 `json:"id"`
+	CommitSigInfo
+	Rev *RevokeAndAck `json:"rev"`
+}
+
+type HtlcRevokeAndAckReq struct { // 包含 RevokeAndAck
+	ChannelId string        `json:"channel"`
+	Id        int64         `json:"id"`
+	Rev       *RevokeAndAck `json:"rev"`
+	Sig       []byte        `json:"msgSig,omitempty"`
+}
+
+type HtlcRevokeAndAckResp struct { // 仅仅简单应答
+	BaseResp
+	Id int64 `json:"id"`
+}